@@ -111,6 +111,9 @@ const (
 	CliFlagForceInode          = "forceInode"
 	CliFlagEnableQuota         = "enableQuota"
 	CliFlagDeleteLockTime      = "delete-lock-time"
+	CliFlagTrashInterval       = "trash-interval"
+	CliFlagDirShards           = "dir-shards"
+	CliFlagMetaStoreMode       = "meta-store-mode"
 
 	//CliFlagSetDataPartitionCount	= "count" use dp-count instead
 
@@ -112,6 +112,8 @@ func formatSimpleVolView(svv *proto.SimpleVolView) string {
 	sb.WriteString(fmt.Sprintf("  Capacity                        : %v GB\n", svv.Capacity))
 	sb.WriteString(fmt.Sprintf("  Create time                     : %v\n", svv.CreateTime))
 	sb.WriteString(fmt.Sprintf("  DeleteLockTime                  : %v\n", svv.DeleteLockTime))
+	sb.WriteString(fmt.Sprintf("  TrashInterval                   : %v min\n", svv.TrashInterval))
+	sb.WriteString(fmt.Sprintf("  DirShards                       : %v\n", svv.DirShards))
 	sb.WriteString(fmt.Sprintf("  Cross zone                      : %v\n", formatEnabledDisabled(svv.CrossZone)))
 	sb.WriteString(fmt.Sprintf("  DefaultPriority                 : %v\n", svv.DefaultPriority))
 	sb.WriteString(fmt.Sprintf("  Dentry count                    : %v\n", svv.DentryCount))
@@ -135,6 +137,7 @@ func formatSimpleVolView(svv *proto.SimpleVolView) string {
 	sb.WriteString(fmt.Sprintf("  Tx conflict retry interval(ms)  : %v\n", svv.TxConflictRetryInterval))
 	sb.WriteString(fmt.Sprintf("  Tx limit interval(s)            : %v\n", svv.TxOpLimit))
 	sb.WriteString(fmt.Sprintf("  Quota                           : %v\n", formatEnabledDisabled(svv.EnableQuota)))
+	sb.WriteString(fmt.Sprintf("  MetaStoreMode                   : %v\n", svv.MetaStoreMode))
 	if svv.VolType == 1 {
 		sb.WriteString(fmt.Sprintf("  ObjBlockSize         : %v byte\n", svv.ObjBlockSize))
 		sb.WriteString(fmt.Sprintf("  CacheCapacity        : %v G\n", svv.CacheCapacity))
@@ -819,3 +822,29 @@ func formatBadDiskTableHeader() string {
 func formatBadDiskInfoRow(disk proto.BadDiskInfo) string {
 	return fmt.Sprintf(badDiskDetailTableRowPattern, disk.Address, disk.Path)
 }
+
+var trashTableRowPattern = "%-12v    %-12v    %-20v    %-10v    %v"
+
+func formatTrashTableHeader() string {
+	return fmt.Sprintf(trashTableRowPattern, "INODE", "SIZE", "DELETE TIME", "PARENT", "PATH")
+}
+
+func formatTrashTableRow(entry *proto.TrashEntry) string {
+	path := entry.Path
+	if path == "" {
+		path = entry.Name
+	}
+	return fmt.Sprintf(trashTableRowPattern, entry.Inode, formatSize(entry.Size),
+		time.Unix(entry.DeleteTime, 0).Format("2006-01-02 15:04:05"), entry.ParentId, path)
+}
+
+var snapshotTableRowPattern = "%-20v    %-8v    %-12v    %-20v    %-10v    %v"
+
+func formatSnapshotTableHeader() string {
+	return fmt.Sprintf(snapshotTableRowPattern, "NAME", "ID", "ROOT INODE", "CREATE TIME", "STATUS", "PATH")
+}
+
+func formatSnapshotTableRow(snap *proto.SnapshotInfo) string {
+	return fmt.Sprintf(snapshotTableRowPattern, snap.Name, snap.Id, snap.RootInode,
+		time.Unix(snap.CreateTime, 0).Format("2006-01-02 15:04:05"), proto.SnapshotStatusToString(snap.Status), snap.Path)
+}
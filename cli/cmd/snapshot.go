@@ -0,0 +1,184 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdVolSnapshotUse          = "snapshot [COMMAND]"
+	cmdVolSnapshotShort        = "Manage the read-only snapshots of the directories of a volume"
+	cmdVolSnapshotCreateUse    = "create [VOLUME] [SNAPSHOT] [PATH]"
+	cmdVolSnapshotCreateShort  = "Create a snapshot of the directory, which is browsable under /.snapshot of the mount point"
+	cmdVolSnapshotListUse      = "list [VOLUME]"
+	cmdVolSnapshotListShort    = "List the snapshots of the volume"
+	cmdVolSnapshotDeleteUse    = "delete [VOLUME] [SNAPSHOT]"
+	cmdVolSnapshotDeleteShort  = "Delete the snapshot"
+	cmdVolSnapshotRestoreUse   = "restore [VOLUME] [SNAPSHOT] [PATH]"
+	cmdVolSnapshotRestoreShort = "Restore the snapshot to the path, which must not exist, the directory taken the snapshot of is used if no path is given"
+)
+
+func newVolSnapshotCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   cmdVolSnapshotUse,
+		Short: cmdVolSnapshotShort,
+		Args:  cobra.MinimumNArgs(0),
+	}
+	cmd.AddCommand(
+		newVolSnapshotCreateCmd(client),
+		newVolSnapshotListCmd(client),
+		newVolSnapshotDeleteCmd(client),
+		newVolSnapshotRestoreCmd(client),
+	)
+	return cmd
+}
+
+func validSnapshotVols(client *master.MasterClient) func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return validVols(client, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func newVolSnapshotCreateCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   cmdVolSnapshotCreateUse,
+		Short: cmdVolSnapshotCreateShort,
+		Args:  cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				if err != nil {
+					errout("Error: %v\n", err)
+				}
+			}()
+			var mw *meta.MetaWrapper
+			if mw, err = newVolMetaWrapper(client, args[0]); err != nil {
+				return
+			}
+			var snap *proto.SnapshotInfo
+			if snap, err = mw.CreateSnapshot_ll(args[1], args[2]); err != nil {
+				return
+			}
+			stdout("Create snapshot [%v] of [%v] successfully, root inode %v.\n", snap.Name, snap.Path, snap.RootInode)
+		},
+		ValidArgsFunction: validSnapshotVols(client),
+	}
+	return cmd
+}
+
+func newVolSnapshotListCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:     cmdVolSnapshotListUse,
+		Short:   cmdVolSnapshotListShort,
+		Aliases: []string{"ls"},
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				if err != nil {
+					errout("Error: %v\n", err)
+				}
+			}()
+			var snapshots []*proto.SnapshotInfo
+			if snapshots, err = client.AdminAPI().ListSnapshot(args[0]); err != nil {
+				return
+			}
+			stdout("%v\n", formatSnapshotTableHeader())
+			for _, snap := range snapshots {
+				stdout("%v\n", formatSnapshotTableRow(snap))
+			}
+		},
+		ValidArgsFunction: validSnapshotVols(client),
+	}
+	return cmd
+}
+
+func newVolSnapshotDeleteCmd(client *master.MasterClient) *cobra.Command {
+	var optYes bool
+	var cmd = &cobra.Command{
+		Use:   cmdVolSnapshotDeleteUse,
+		Short: cmdVolSnapshotDeleteShort,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				if err != nil {
+					errout("Error: %v\n", err)
+				}
+			}()
+			// ask user for confirm
+			if !optYes {
+				stdout("Delete snapshot [%v] of volume [%v] (yes/no)[no]:", args[1], args[0])
+				var userConfirm string
+				_, _ = fmt.Scanln(&userConfirm)
+				if userConfirm != "yes" {
+					err = fmt.Errorf("Abort by user.\n")
+					return
+				}
+			}
+			var mw *meta.MetaWrapper
+			if mw, err = newVolMetaWrapper(client, args[0]); err != nil {
+				return
+			}
+			if err = mw.DeleteSnapshot_ll(args[1]); err != nil {
+				return
+			}
+			stdout("Delete snapshot [%v] successfully.\n", args[1])
+		},
+		ValidArgsFunction: validSnapshotVols(client),
+	}
+	cmd.Flags().BoolVarP(&optYes, "yes", "y", false, "Answer yes for all questions")
+	return cmd
+}
+
+func newVolSnapshotRestoreCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   cmdVolSnapshotRestoreUse,
+		Short: cmdVolSnapshotRestoreShort,
+		Args:  cobra.RangeArgs(2, 3),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				if err != nil {
+					errout("Error: %v\n", err)
+				}
+			}()
+			var dstPath string
+			if len(args) > 2 {
+				dstPath = args[2]
+			}
+			var mw *meta.MetaWrapper
+			if mw, err = newVolMetaWrapper(client, args[0]); err != nil {
+				return
+			}
+			var ino uint64
+			if ino, err = mw.RestoreSnapshot_ll(args[1], dstPath); err != nil {
+				return
+			}
+			stdout("Restore snapshot [%v] successfully, root inode %v.\n", args[1], ino)
+		},
+		ValidArgsFunction: validSnapshotVols(client),
+	}
+	return cmd
+}
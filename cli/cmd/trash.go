@@ -0,0 +1,199 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cmdVolTrashUse          = "trash [COMMAND]"
+	cmdVolTrashShort        = "Manage the files deleted into the trash of a volume"
+	cmdVolTrashListUse      = "list [VOLUME]"
+	cmdVolTrashListShort    = "List the files in the trash"
+	cmdVolTrashRestoreUse   = "restore [VOLUME] [INODE]..."
+	cmdVolTrashRestoreShort = "Restore the files in the trash to their original paths"
+	cmdVolTrashPurgeUse     = "purge [VOLUME] [INODE]..."
+	cmdVolTrashPurgeShort   = "Delete the files in the trash permanently, all the files are purged if no inode is given"
+)
+
+func newVolTrashCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   cmdVolTrashUse,
+		Short: cmdVolTrashShort,
+		Args:  cobra.MinimumNArgs(0),
+	}
+	cmd.AddCommand(
+		newVolTrashListCmd(client),
+		newVolTrashRestoreCmd(client),
+		newVolTrashPurgeCmd(client),
+	)
+	return cmd
+}
+
+func newVolMetaWrapper(client *master.MasterClient, volName string) (*meta.MetaWrapper, error) {
+	proto.InitBufferPool(32768)
+	var metaConfig = &meta.MetaConfig{
+		Volume:  volName,
+		Masters: client.Nodes(),
+	}
+	return meta.NewMetaWrapper(metaConfig)
+}
+
+func parseTrashInodes(args []string) (inodes []uint64, err error) {
+	for _, arg := range args {
+		var ino uint64
+		if ino, err = strconv.ParseUint(arg, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid inode %v", arg)
+		}
+		inodes = append(inodes, ino)
+	}
+	return
+}
+
+func newVolTrashListCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:     cmdVolTrashListUse,
+		Short:   cmdVolTrashListShort,
+		Aliases: []string{"ls"},
+		Args:    cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				if err != nil {
+					errout("Error: %v\n", err)
+				}
+			}()
+			var mw *meta.MetaWrapper
+			if mw, err = newVolMetaWrapper(client, args[0]); err != nil {
+				return
+			}
+			var entries []*proto.TrashEntry
+			if entries, err = mw.ListTrash_ll(); err != nil {
+				return
+			}
+			stdout("%v\n", formatTrashTableHeader())
+			for _, entry := range entries {
+				stdout("%v\n", formatTrashTableRow(entry))
+			}
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return validVols(client, toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	return cmd
+}
+
+func newVolTrashRestoreCmd(client *master.MasterClient) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   cmdVolTrashRestoreUse,
+		Short: cmdVolTrashRestoreShort,
+		Args:  cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			defer func() {
+				if err != nil {
+					errout("Error: %v\n", err)
+				}
+			}()
+			var inodes []uint64
+			if inodes, err = parseTrashInodes(args[1:]); err != nil {
+				return
+			}
+			var mw *meta.MetaWrapper
+			if mw, err = newVolMetaWrapper(client, args[0]); err != nil {
+				return
+			}
+			for _, ino := range inodes {
+				entry, restoreErr := mw.RestoreTrash_ll(ino)
+				if restoreErr != nil {
+					stdout("Restore inode %v failed: %v\n", ino, restoreErr)
+					err = fmt.Errorf("restore failed")
+					continue
+				}
+				stdout("Restore inode %v as [%v] in directory %v successfully.\n", ino, entry.Name, entry.ParentId)
+			}
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return validVols(client, toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	return cmd
+}
+
+func newVolTrashPurgeCmd(client *master.MasterClient) *cobra.Command {
+	var optYes bool
+	var cmd = &cobra.Command{
+		Use:   cmdVolTrashPurgeUse,
+		Short: cmdVolTrashPurgeShort,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			var volumeName = args[0]
+			defer func() {
+				if err != nil {
+					errout("Error: %v\n", err)
+				}
+			}()
+			var inodes []uint64
+			if inodes, err = parseTrashInodes(args[1:]); err != nil {
+				return
+			}
+			// ask user for confirm
+			if !optYes {
+				if len(inodes) == 0 {
+					stdout("Purge all the files in the trash of volume [%v] (yes/no)[no]:", volumeName)
+				} else {
+					stdout("Purge %v files in the trash of volume [%v] (yes/no)[no]:", len(inodes), volumeName)
+				}
+				var userConfirm string
+				_, _ = fmt.Scanln(&userConfirm)
+				if userConfirm != "yes" {
+					err = fmt.Errorf("Abort by user.\n")
+					return
+				}
+			}
+			var mw *meta.MetaWrapper
+			if mw, err = newVolMetaWrapper(client, volumeName); err != nil {
+				return
+			}
+			var count int
+			if count, err = mw.PurgeTrash_ll(inodes); err != nil {
+				return
+			}
+			stdout("Purge %v files successfully.\n", count)
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return validVols(client, toComplete), cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+	cmd.Flags().BoolVarP(&optYes, "yes", "y", false, "Answer yes for all questions")
+	return cmd
+}
@@ -144,6 +144,7 @@ func newUserUpdateCmd(client *master.MasterClient) *cobra.Command {
 	var optAccessKey string
 	var optSecretKey string
 	var optUserType string
+	var optPublicAccessBlock string
 	var optYes bool
 	var cmd = &cobra.Command{
 		Use:   cmdUserUpdateUse,
@@ -155,6 +156,7 @@ func newUserUpdateCmd(client *master.MasterClient) *cobra.Command {
 			var accessKey = optAccessKey
 			var secretKey = optSecretKey
 			var userType proto.UserType
+			var publicAccessBlock *proto.PublicAccessBlock
 			defer func() {
 				if err != nil {
 					errout("Error: %v\n", err)
@@ -167,6 +169,11 @@ func newUserUpdateCmd(client *master.MasterClient) *cobra.Command {
 					return
 				}
 			}
+			if optPublicAccessBlock != "" {
+				if publicAccessBlock, err = parsePublicAccessBlock(optPublicAccessBlock); err != nil {
+					return
+				}
+			}
 
 			if !optYes {
 				var displayAccessKey = "[no change]"
@@ -181,11 +188,16 @@ func newUserUpdateCmd(client *master.MasterClient) *cobra.Command {
 				if optUserType != "" {
 					displayUserType = optUserType
 				}
+				var displayPublicAccessBlock = "[no change]"
+				if optPublicAccessBlock != "" {
+					displayPublicAccessBlock = optPublicAccessBlock
+				}
 				fmt.Printf("Update CubeFS cluster user\n")
-				stdout("  User ID   : %v\n", userID)
-				stdout("  Access Key: %v\n", displayAccessKey)
-				stdout("  Secret Key: %v\n", displaySecretKey)
-				stdout("  Type      : %v\n", displayUserType)
+				stdout("  User ID            : %v\n", userID)
+				stdout("  Access Key         : %v\n", displayAccessKey)
+				stdout("  Secret Key         : %v\n", displaySecretKey)
+				stdout("  Type               : %v\n", displayUserType)
+				stdout("  Public Access Block: %v\n", displayPublicAccessBlock)
 				stdout("\nConfirm (yes/no)[yes]: ")
 				var userConfirm string
 				_, _ = fmt.Scanln(&userConfirm)
@@ -194,15 +206,16 @@ func newUserUpdateCmd(client *master.MasterClient) *cobra.Command {
 					return
 				}
 			}
-			if accessKey == "" && secretKey == "" && optUserType == "" {
+			if accessKey == "" && secretKey == "" && optUserType == "" && publicAccessBlock == nil {
 				err = fmt.Errorf("no update")
 				return
 			}
 			var param = proto.UserUpdateParam{
-				UserID:    userID,
-				AccessKey: accessKey,
-				SecretKey: secretKey,
-				Type:      userType,
+				UserID:            userID,
+				AccessKey:         accessKey,
+				SecretKey:         secretKey,
+				Type:              userType,
+				PublicAccessBlock: publicAccessBlock,
 			}
 			var userInfo *proto.UserInfo
 			if userInfo, err = client.UserAPI().UpdateUser(&param); err != nil {
@@ -217,6 +230,8 @@ func newUserUpdateCmd(client *master.MasterClient) *cobra.Command {
 	cmd.Flags().StringVar(&optAccessKey, "access-key", "", "Update user access key")
 	cmd.Flags().StringVar(&optSecretKey, "secret-key", "", "Update user secret key")
 	cmd.Flags().StringVar(&optUserType, "user-type", "", "Update user type [normal | admin]")
+	cmd.Flags().StringVar(&optPublicAccessBlock, "public-access-block", "",
+		"Update user public access block, comma separated [BlockPublicAcls,IgnorePublicAcls,BlockPublicPolicy,RestrictPublicBuckets | none]")
 	cmd.Flags().BoolVarP(&optYes, "yes", "y", false, "Answer yes for all questions")
 	return cmd
 }
@@ -424,6 +439,10 @@ func printUserInfo(userInfo *proto.UserInfo) {
 	stdout("  Secret Key : %v\n", userInfo.SecretKey)
 	stdout("  Type       : %v\n", userInfo.UserType)
 	stdout("  Create Time: %v\n", userInfo.CreateTime)
+	if block := userInfo.PublicAccessBlock; !block.IsEmpty() {
+		stdout("  Public Access Block: BlockPublicAcls(%v) IgnorePublicAcls(%v) BlockPublicPolicy(%v) RestrictPublicBuckets(%v)\n",
+			block.BlockPublicAcls, block.IgnorePublicAcls, block.BlockPublicPolicy, block.RestrictPublicBuckets)
+	}
 	if userInfo.Policy == nil {
 		return
 	}
@@ -436,3 +455,25 @@ func printUserInfo(userInfo *proto.UserInfo) {
 		stdout("%-20v    %-12v\n", vol, strings.Join(perms, ","))
 	}
 }
+
+func parsePublicAccessBlock(value string) (block *proto.PublicAccessBlock, err error) {
+	block = new(proto.PublicAccessBlock)
+	if value == "none" {
+		return
+	}
+	for _, setting := range strings.Split(value, ",") {
+		switch strings.TrimSpace(setting) {
+		case "BlockPublicAcls":
+			block.BlockPublicAcls = true
+		case "IgnorePublicAcls":
+			block.IgnorePublicAcls = true
+		case "BlockPublicPolicy":
+			block.BlockPublicPolicy = true
+		case "RestrictPublicBuckets":
+			block.RestrictPublicBuckets = true
+		default:
+			return nil, fmt.Errorf("Invalid public access block setting: %v ", setting)
+		}
+	}
+	return
+}
@@ -49,6 +49,8 @@ func newVolCmd(client *master.MasterClient) *cobra.Command {
 		newVolDeleteCmd(client),
 		newVolTransferCmd(client),
 		newVolAddDPCmd(client),
+		newVolTrashCmd(client),
+		newVolSnapshotCmd(client),
 	)
 	return cmd
 }
@@ -138,6 +140,7 @@ func newVolCreateCmd(client *master.MasterClient) *cobra.Command {
 	var optTxConflictRetryNum int64
 	var optTxConflictRetryInterval int64
 	var optDeleteLockTime int64
+	var optMetaStoreMode string
 	var optYes bool
 	var cmd = &cobra.Command{
 		Use:   cmdVolCreateUse,
@@ -202,6 +205,7 @@ func newVolCreateCmd(client *master.MasterClient) *cobra.Command {
 				stdout("  TransactionTimeout       : %v min\n", optTxTimeout)
 				stdout("  TxConflictRetryNum       : %v\n", optTxConflictRetryNum)
 				stdout("  TxConflictRetryInterval  : %v ms\n", optTxConflictRetryInterval)
+				stdout("  metaStoreMode            : %v\n", optMetaStoreMode)
 				stdout("\nConfirm (yes/no)[yes]: ")
 				var userConfirm string
 				_, _ = fmt.Scanln(&userConfirm)
@@ -217,7 +221,7 @@ func newVolCreateCmd(client *master.MasterClient) *cobra.Command {
 				optZoneName, optCacheRuleKey, optEbsBlkSize, optCacheCap,
 				optCacheAction, optCacheThreshold, optCacheTTL, optCacheHighWater,
 				optCacheLowWater, optCacheLRUInterval, dpReadOnlyWhenVolFull,
-				optTxMask, optTxTimeout, optTxConflictRetryNum, optTxConflictRetryInterval, optEnableQuota)
+				optTxMask, optTxTimeout, optTxConflictRetryNum, optTxConflictRetryInterval, optEnableQuota, optMetaStoreMode)
 			if err != nil {
 				err = fmt.Errorf("Create volume failed case:\n%v\n", err)
 				return
@@ -254,6 +258,7 @@ func newVolCreateCmd(client *master.MasterClient) *cobra.Command {
 	cmd.Flags().Int64Var(&optTxConflictRetryInterval, CliTxConflictRetryInterval, 0, "Specify retry interval[Unit: ms] for transaction conflict [10-1000]")
 	cmd.Flags().StringVar(&optEnableQuota, CliFlagEnableQuota, "false", "Enable quota (default false)")
 	cmd.Flags().Int64Var(&optDeleteLockTime, CliFlagDeleteLockTime, 0, "Specify delete lock time[Unit: hour] for volume")
+	cmd.Flags().StringVar(&optMetaStoreMode, CliFlagMetaStoreMode, "mem", "Specify where meta partitions keep the metadata: [\"mem\"|\"rocksdb\"]")
 
 	return cmd
 }
@@ -287,6 +292,8 @@ func newVolUpdateCmd(client *master.MasterClient) *cobra.Command {
 	var optTxOpLimitVal int
 	var optReplicaNum string
 	var optDeleteLockTime int64
+	var optTrashInterval int64
+	var optDirShards int
 	var optEnableQuota string
 	var confirmString = strings.Builder{}
 	var vv *proto.SimpleVolView
@@ -408,6 +415,22 @@ func newVolUpdateCmd(client *master.MasterClient) *cobra.Command {
 				confirmString.WriteString(fmt.Sprintf("  DeleteLockTime            : %v h\n", vv.DeleteLockTime))
 			}
 
+			if optTrashInterval >= 0 && optTrashInterval != vv.TrashInterval {
+				isChange = true
+				confirmString.WriteString(fmt.Sprintf("  TrashInterval             : %v min -> %v min\n", vv.TrashInterval, optTrashInterval))
+				vv.TrashInterval = optTrashInterval
+			} else {
+				confirmString.WriteString(fmt.Sprintf("  TrashInterval             : %v min\n", vv.TrashInterval))
+			}
+
+			if optDirShards >= 0 && optDirShards != vv.DirShards {
+				isChange = true
+				confirmString.WriteString(fmt.Sprintf("  DirShards                 : %v -> %v\n", vv.DirShards, optDirShards))
+				vv.DirShards = optDirShards
+			} else {
+				confirmString.WriteString(fmt.Sprintf("  DirShards                 : %v\n", vv.DirShards))
+			}
+
 			//var maskStr string
 			if optTxMask != "" {
 				var oldMask, newMask proto.TxOpMask
@@ -632,6 +655,8 @@ func newVolUpdateCmd(client *master.MasterClient) *cobra.Command {
 	cmd.Flags().StringVar(&optReplicaNum, CliFlagReplicaNum, "", "Specify data partition replicas number(default 3 for normal volume,1 for low volume)")
 	cmd.Flags().StringVar(&optEnableQuota, CliFlagEnableQuota, "", "Enable quota")
 	cmd.Flags().Int64Var(&optDeleteLockTime, CliFlagDeleteLockTime, -1, "Specify delete lock time[Unit: hour] for volume")
+	cmd.Flags().Int64Var(&optTrashInterval, CliFlagTrashInterval, -1, "Specify the time[Unit: min] the deleted files are kept in the trash, 0 disables the trash")
+	cmd.Flags().IntVar(&optDirShards, CliFlagDirShards, -1, "Specify the number of the shards of the directories created afterwards, 0 disables the sharding. Only the empty directories can be sharded, the existing directories are left unsharded")
 
 	return cmd
 
@@ -15,6 +15,7 @@
 package fs
 
 import (
+	"math"
 	"syscall"
 	"time"
 
@@ -48,6 +49,13 @@ const (
 	DeleteExtentsTimeout = 600 * time.Second
 )
 
+const (
+	// the inode of the hidden directory of the snapshots, which is not allocated by the meta nodes
+	SnapshotDirInode = math.MaxUint64
+	// the expiration duration of the snapshot list fetched from the master
+	SnapshotListExpiration = 5 * time.Second
+)
+
 const (
 	MaxSizePutOnce = int64(1) << 23
 )
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -95,22 +96,23 @@ type Dir struct {
 
 // Functions that Dir needs to implement
 var (
-	_ fs.Node                = (*Dir)(nil)
-	_ fs.NodeCreater         = (*Dir)(nil)
-	_ fs.NodeForgetter       = (*Dir)(nil)
-	_ fs.NodeMkdirer         = (*Dir)(nil)
-	_ fs.NodeMknoder         = (*Dir)(nil)
-	_ fs.NodeRemover         = (*Dir)(nil)
-	_ fs.NodeFsyncer         = (*Dir)(nil)
-	_ fs.NodeRequestLookuper = (*Dir)(nil)
-	_ fs.HandleReadDirAller  = (*Dir)(nil)
-	_ fs.NodeRenamer         = (*Dir)(nil)
-	_ fs.NodeSetattrer       = (*Dir)(nil)
-	_ fs.NodeSymlinker       = (*Dir)(nil)
-	_ fs.NodeGetxattrer      = (*Dir)(nil)
-	_ fs.NodeListxattrer     = (*Dir)(nil)
-	_ fs.NodeSetxattrer      = (*Dir)(nil)
-	_ fs.NodeRemovexattrer   = (*Dir)(nil)
+	_ fs.Node                 = (*Dir)(nil)
+	_ fs.NodeCreater          = (*Dir)(nil)
+	_ fs.NodeForgetter        = (*Dir)(nil)
+	_ fs.NodeMkdirer          = (*Dir)(nil)
+	_ fs.NodeMknoder          = (*Dir)(nil)
+	_ fs.NodeRemover          = (*Dir)(nil)
+	_ fs.NodeFsyncer          = (*Dir)(nil)
+	_ fs.NodeRequestLookuper  = (*Dir)(nil)
+	_ fs.HandleReadDirAller   = (*Dir)(nil)
+	_ fs.HandleReadDirPlusser = (*Dir)(nil)
+	_ fs.NodeRenamer          = (*Dir)(nil)
+	_ fs.NodeSetattrer        = (*Dir)(nil)
+	_ fs.NodeSymlinker        = (*Dir)(nil)
+	_ fs.NodeGetxattrer       = (*Dir)(nil)
+	_ fs.NodeListxattrer      = (*Dir)(nil)
+	_ fs.NodeSetxattrer       = (*Dir)(nil)
+	_ fs.NodeRemovexattrer    = (*Dir)(nil)
 )
 
 // NewDir returns a new directory.
@@ -183,6 +185,7 @@ func (d *Dir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.Cr
 	resp.EntryValid = LookupValidDuration
 
 	d.super.ic.Delete(d.info.Inode)
+	d.super.metaCache.Invalidate(d.info.Inode)
 
 	elapsed := time.Since(start)
 	log.LogDebugf("TRACE Create: parent(%v) req(%v) resp(%v) ino(%v) (%v)ns", d.info.Inode, req, resp, info.Inode, elapsed.Nanoseconds())
@@ -233,6 +236,7 @@ func (d *Dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error
 	d.super.fslock.Unlock()
 
 	d.super.ic.Delete(d.info.Inode)
+	d.super.metaCache.Invalidate(d.info.Inode)
 
 	elapsed := time.Since(start)
 	log.LogDebugf("TRACE Mkdir: parent(%v) req(%v) ino(%v) (%v)ns", d.info.Inode, req, info.Inode, elapsed.Nanoseconds())
@@ -256,7 +260,7 @@ func (d *Dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
 		auditlog.FormatLog("Remove", d.getCwd()+"/"+req.Name, "nil", err, time.Since(start).Microseconds(), deletedInode, 0)
 	}()
 
-	info, err := d.super.mw.Delete_ll(d.info.Inode, req.Name, req.Dir)
+	info, err := d.super.mw.Delete_ll(d.info.Inode, req.Name, req.Dir, d.fullPath(req.Name))
 	if err != nil {
 		log.LogErrorf("Remove: parent(%v) name(%v) err(%v)", d.info.Inode, req.Name, err)
 		return ParseError(err)
@@ -266,10 +270,15 @@ func (d *Dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
 		deletedInode = info.Inode
 	}
 	d.super.ic.Delete(d.info.Inode)
+	d.super.metaCache.Invalidate(d.info.Inode)
 
 	if info != nil && info.Nlink == 0 && !proto.IsDir(info.Mode) {
 		d.super.orphan.Put(info.Inode)
 		log.LogDebugf("Remove: add to orphan inode list, ino(%v)", info.Inode)
+		// recorded by the meta node as well, which evicts the inode if the client crashes before the eviction
+		if err := d.super.mw.AddOrphanInode_ll(info.Inode); err != nil {
+			log.LogWarnf("Remove: add orphan inode(%v) err(%v)", info.Inode, err)
+		}
 	}
 
 	elapsed := time.Since(start)
@@ -297,6 +306,12 @@ func (d *Dir) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.Lo
 	log.LogDebugf("TRACE Lookup: parent(%v) req(%v)", d.info.Inode, req)
 	log.LogDebugf("TRACE Lookup: parent(%v) path(%v) d.super.bcacheDir(%v)", d.info.Inode, d.getCwd(), d.super.bcacheDir)
 
+	// the snapshots are browsed through the hidden directory in the root of the volume
+	if d.info.Inode == RootInode && req.Name == proto.SnapshotDirName {
+		resp.EntryValid = SnapshotListExpiration
+		return d.super.snapshotDir, nil
+	}
+
 	if d.needDentrycache() {
 		dcachev2 = true
 	}
@@ -346,23 +361,41 @@ func (d *Dir) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.Lo
 		dummyChild := NewFile(d.super, dummyInodeInfo, DefaultFlag, d.info.Inode, req.Name)
 		return dummyChild, nil
 	}
+	child := d.childNode(info, req.Name)
+	resp.EntryValid = LookupValidDuration
+
+	log.LogDebugf("TRACE Lookup exit: parent(%v) req(%v) cost (%d)", d.info.Inode, req, time.Since(*bgTime).Microseconds())
+	return child, nil
+}
+
+// childNode returns the cached node of the child, or a new one if it is not cached.
+func (d *Dir) childNode(info *proto.InodeInfo, name string) fs.Node {
 	mode := proto.OsMode(info.Mode)
 	d.super.fslock.Lock()
-	child, ok := d.super.nodeCache[ino]
+	defer d.super.fslock.Unlock()
+	child, ok := d.super.nodeCache[info.Inode]
 	if !ok {
 		if mode.IsDir() {
-			child = NewDir(d.super, info, d.info.Inode, req.Name)
+			child = NewDir(d.super, info, d.info.Inode, name)
 		} else {
-			child = NewFile(d.super, info, DefaultFlag, d.info.Inode, req.Name)
+			child = NewFile(d.super, info, DefaultFlag, d.info.Inode, name)
 		}
-		d.super.nodeCache[ino] = child
+		d.super.nodeCache[info.Inode] = child
 	}
-	d.super.fslock.Unlock()
-
-	resp.EntryValid = LookupValidDuration
+	return child
+}
 
-	log.LogDebugf("TRACE Lookup exit: parent(%v) req(%v) cost (%d)", d.info.Inode, req, time.Since(*bgTime).Microseconds())
-	return child, nil
+// readDirLimit reads the dentries from the persisted metadata cache if the listing is cached, along with the
+// inodes of them, or from the meta nodes otherwise.
+func (d *Dir) readDirLimit(from string, limit uint64) (children []proto.Dentry, infos []*proto.InodeInfo, cached bool, err error) {
+	if children, infos, cached = d.super.metaCache.ReadDirLimit(d.info.Inode, from, limit); cached {
+		return
+	}
+	if from == "" {
+		d.super.metaCache.Fill(d.info.Inode)
+	}
+	children, err = d.super.mw.ReadDirLimit_ll(d.info.Inode, from, limit)
+	return
 }
 
 func (d *Dir) buildDcacheKey(inode uint64, name string) string {
@@ -371,7 +404,6 @@ func (d *Dir) buildDcacheKey(inode uint64, name string) string {
 
 func (d *Dir) ReadDir(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) ([]fuse.Dirent, error) {
 	var err error
-	var limit uint64 = DefaultReaddirLimit
 	start := time.Now()
 
 	bgTime := stat.BeginStat()
@@ -381,36 +413,97 @@ func (d *Dir) ReadDir(ctx context.Context, req *fuse.ReadRequest, resp *fuse.Rea
 		stat.EndStat("ReadDirLimit", err, bgTime, 1)
 		metric.SetWithLabels(err, map[string]string{exporter.Vol: d.super.volname})
 	}()
+	children, _, err := d.readDirPage(req)
+	if err != nil && err != io.EOF {
+		return make([]fuse.Dirent, 0), err
+	}
+	dirents := make([]fuse.Dirent, 0, len(children))
+	for _, child := range children {
+		dirents = append(dirents, fuse.Dirent{
+			Inode: child.Inode,
+			Type:  ParseType(child.Type),
+			Name:  child.Name,
+		})
+	}
+	elapsed := time.Since(start)
+	log.LogDebugf("TRACE ReadDir exit: ino(%v) (%v)ns %v", d.info.Inode, elapsed.Nanoseconds(), req)
+	return dirents, err
+}
+
+// ReadDirPlus handles the readdirplus request, the entries are returned along with their nodes,
+// whose attributes are fetched in batch with the page of the entries, so that the kernel does not
+// look them up one by one.
+func (d *Dir) ReadDirPlus(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) ([]fs.DirentPlus, error) {
+	var err error
+	start := time.Now()
+
+	bgTime := stat.BeginStat()
+	metric := exporter.NewTPCnt("readdirplus")
+	defer func() {
+		stat.EndStat("ReadDirPlus", err, bgTime, 1)
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: d.super.volname})
+	}()
+	children, infos, err := d.readDirPage(req)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	inodes := make(map[uint64]*proto.InodeInfo, len(infos))
+	for _, info := range infos {
+		inodes[info.Inode] = info
+	}
+	entries := make([]fs.DirentPlus, 0, len(children))
+	for _, child := range children {
+		entry := fs.DirentPlus{
+			Dirent: fuse.Dirent{
+				Inode: child.Inode,
+				Type:  ParseType(child.Type),
+				Name:  child.Name,
+			},
+			EntryValid: LookupValidDuration,
+		}
+		// the entry is left to be looked up if its inode is not fetched
+		if info := inodes[child.Inode]; info != nil && child.Name != "." && child.Name != ".." {
+			entry.Node = d.childNode(info, child.Name)
+		}
+		entries = append(entries, entry)
+	}
+	elapsed := time.Since(start)
+	log.LogDebugf("TRACE ReadDirPlus exit: ino(%v) entries(%v) (%v)ns err(%v)", d.info.Inode, len(entries),
+		elapsed.Nanoseconds(), err)
+	return entries, err
+}
+
+// readDirPage reads the page of the entries following the ones read by the handle, or the first page if
+// the offset is zero, along with the inodes of them. io.EOF is returned with the last page.
+func (d *Dir) readDirPage(req *fuse.ReadRequest) (children []proto.Dentry, infos []*proto.InodeInfo, err error) {
+	var limit uint64 = DefaultReaddirLimit
 	var dirCtx DirContext
 	if req.Offset != 0 {
 		dirCtx = d.dctx.GetCopy(req.Handle)
 	} else {
 		dirCtx = DirContext{}
 	}
-	children, err := d.super.mw.ReadDirLimit_ll(d.info.Inode, dirCtx.Name, limit)
+	children, cachedInfos, cached, err := d.readDirLimit(dirCtx.Name, limit)
 	if err != nil {
 		log.LogErrorf("readdirlimit: Readdir: ino(%v) err(%v) offset %v", d.info.Inode, err, req.Offset)
-		return make([]fuse.Dirent, 0), ParseError(err)
+		return nil, nil, ParseError(err)
 	}
 
 	if req.Offset == 0 {
 		if len(children) == 0 {
-			dirents := make([]fuse.Dirent, 0, len(children))
-			dirents = append(dirents, fuse.Dirent{
-				Inode: d.info.Inode,
-				Type:  fuse.DT_Dir,
-				Name:  ".",
-			})
 			pid := uint64(req.Pid)
 			if d.info.Inode == 1 {
 				pid = d.info.Inode
 			}
-			dirents = append(dirents, fuse.Dirent{
-				Inode: pid,
-				Type:  fuse.DT_Dir,
+			return []proto.Dentry{{
+				Name:  ".",
+				Inode: d.info.Inode,
+				Type:  uint32(os.ModeDir),
+			}, {
 				Name:  "..",
-			})
-			return dirents, io.EOF
+				Inode: pid,
+				Type:  uint32(os.ModeDir),
+			}}, nil, io.EOF
 		}
 		children = append([]proto.Dentry{{
 			Name:  ".",
@@ -426,7 +519,7 @@ func (d *Dir) ReadDir(ctx context.Context, req *fuse.ReadRequest, resp *fuse.Rea
 	// skip the first one, which is already accessed
 	childrenNr := uint64(len(children))
 	if childrenNr == 0 || (dirCtx.Name != "" && childrenNr == 1) {
-		return make([]fuse.Dirent, 0), io.EOF
+		return nil, nil, io.EOF
 	} else if childrenNr < limit {
 		err = io.EOF
 	}
@@ -439,7 +532,6 @@ func (d *Dir) ReadDir(ctx context.Context, req *fuse.ReadRequest, resp *fuse.Rea
 	d.dctx.Put(req.Handle, &dirCtx)
 
 	inodes := make([]uint64, 0, len(children))
-	dirents := make([]fuse.Dirent, 0, len(children))
 
 	log.LogDebugf("Readdir ino(%v) path(%v) d.super.bcacheDir(%v)", d.info.Inode, d.getCwd(), d.super.bcacheDir)
 	var dcache *DentryCache
@@ -453,14 +545,7 @@ func (d *Dir) ReadDir(ctx context.Context, req *fuse.ReadRequest, resp *fuse.Rea
 	}
 
 	for _, child := range children {
-		dentry := fuse.Dirent{
-			Inode: child.Inode,
-			Type:  ParseType(child.Type),
-			Name:  child.Name,
-		}
-
 		inodes = append(inodes, child.Inode)
-		dirents = append(dirents, dentry)
 		if dcachev2 {
 			info := &proto.DentryInfo{
 				Name:  d.buildDcacheKey(d.info.Inode, child.Name),
@@ -472,15 +557,16 @@ func (d *Dir) ReadDir(ctx context.Context, req *fuse.ReadRequest, resp *fuse.Rea
 		}
 	}
 
-	infos := d.super.mw.BatchInodeGet(inodes)
+	infos = cachedInfos
+	if !cached {
+		infos = d.super.mw.BatchInodeGet(inodes)
+	}
 	for _, info := range infos {
 		d.super.ic.Put(info)
 	}
 
 	d.dcache = dcache
-	elapsed := time.Since(start)
-	log.LogDebugf("TRACE ReadDir exit: ino(%v) (%v)ns %v", d.info.Inode, elapsed.Nanoseconds(), req)
-	return dirents, err
+	return
 }
 
 // ReadDirAll gets all the dentries in a directory and puts them into the cache.
@@ -498,8 +584,10 @@ func (d *Dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 	var noMore = false
 	var from = ""
 	var children []proto.Dentry
+	var infos []*proto.InodeInfo
+	var cached = true
 	for !noMore {
-		batches, err := d.super.mw.ReadDirLimit_ll(d.info.Inode, from, DefaultReaddirLimit)
+		batches, batchInfos, batchCached, err := d.readDirLimit(from, DefaultReaddirLimit)
 		if err != nil {
 			log.LogErrorf("Readdir: ino(%v) err(%v) from(%v)", d.info.Inode, err, from)
 			return make([]fuse.Dirent, 0), ParseError(err)
@@ -515,6 +603,8 @@ func (d *Dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 			batches = batches[1:]
 		}
 		children = append(children, batches...)
+		infos = append(infos, batchInfos...)
+		cached = cached && batchCached
 		from = batches[len(batches)-1].Name
 	}
 
@@ -552,7 +642,9 @@ func (d *Dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 		}
 	}
 
-	infos := d.super.mw.BatchInodeGet(inodes)
+	if !cached {
+		infos = d.super.mw.BatchInodeGet(inodes)
+	}
 	for _, info := range infos {
 		d.super.ic.Put(info)
 	}
@@ -625,6 +717,7 @@ func (d *Dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Nod
 	// }
 	d.super.ic.Delete(d.info.Inode)
 	d.super.ic.Delete(dstDir.info.Inode)
+	d.super.metaCache.Invalidate(d.info.Inode, dstDir.info.Inode)
 
 	elapsed := time.Since(start)
 	log.LogDebugf("TRACE Rename: SrcParent(%v) OldName(%v) DstParent(%v) NewName(%v) (%v)ns", d.info.Inode, req.OldName, dstDir.info.Inode, req.NewName, elapsed.Nanoseconds())
@@ -656,6 +749,9 @@ func (d *Dir) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.
 		}
 	}
 
+	// the attributes are cached in the listings of the parents
+	d.super.metaCache.Invalidate(ino)
+
 	fillAttr(info, &resp.Attr)
 
 	elapsed := time.Since(start)
@@ -685,6 +781,7 @@ func (d *Dir) Mknod(ctx context.Context, req *fuse.MknodRequest) (fs.Node, error
 	}
 
 	d.super.ic.Put(info)
+	d.super.metaCache.Invalidate(d.info.Inode)
 	child := NewFile(d.super, info, DefaultFlag, d.info.Inode, req.Name)
 
 	d.super.fslock.Lock()
@@ -716,6 +813,7 @@ func (d *Dir) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, e
 	}
 
 	d.super.ic.Put(info)
+	d.super.metaCache.Invalidate(d.info.Inode)
 	child := NewFile(d.super, info, DefaultFlag, d.info.Inode, req.NewName)
 
 	d.super.fslock.Lock()
@@ -759,6 +857,7 @@ func (d *Dir) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (fs.
 	}
 
 	d.super.ic.Put(info)
+	d.super.metaCache.Invalidate(d.info.Inode)
 
 	d.super.fslock.Lock()
 	newFile, ok := d.super.nodeCache[info.Inode]
@@ -919,6 +1018,15 @@ func (d *Dir) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest) err
 	return nil
 }
 
+// fullPath returns the path of the child in the volume, or an empty string if the path is unknown.
+func (d *Dir) fullPath(name string) string {
+	cwd := d.getCwd()
+	if strings.HasPrefix(cwd, "unknown") {
+		return ""
+	}
+	return path.Join("/", d.super.subDir, cwd, name)
+}
+
 func (d *Dir) getCwd() string {
 	dirPath := ""
 	if d.info.Inode == d.super.rootIno {
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -31,6 +32,7 @@ import (
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
 	"github.com/cubefs/cubefs/util/stat"
+	"github.com/cubefs/cubefs/util/trace"
 )
 
 // File defines the structure of a file.
@@ -40,28 +42,32 @@ type File struct {
 	idle      int32
 	parentIno uint64
 	name      string
-	sync.RWMutex
-	fReader *blobstore.Reader
-	fWriter *blobstore.Writer
+	// fWriterLock serializes the flush of fWriter, the name Lock is taken by fs.HandleLocker
+	fWriterLock sync.Mutex
+	fReader     *blobstore.Reader
+	fWriter     *blobstore.Writer
 }
 
 // Functions that File needs to implement
 var (
-	_ fs.Node              = (*File)(nil)
-	_ fs.Handle            = (*File)(nil)
-	_ fs.NodeForgetter     = (*File)(nil)
-	_ fs.NodeOpener        = (*File)(nil)
-	_ fs.HandleReleaser    = (*File)(nil)
-	_ fs.HandleReader      = (*File)(nil)
-	_ fs.HandleWriter      = (*File)(nil)
-	_ fs.HandleFlusher     = (*File)(nil)
-	_ fs.NodeFsyncer       = (*File)(nil)
-	_ fs.NodeSetattrer     = (*File)(nil)
-	_ fs.NodeReadlinker    = (*File)(nil)
-	_ fs.NodeGetxattrer    = (*File)(nil)
-	_ fs.NodeListxattrer   = (*File)(nil)
-	_ fs.NodeSetxattrer    = (*File)(nil)
-	_ fs.NodeRemovexattrer = (*File)(nil)
+	_ fs.Node                 = (*File)(nil)
+	_ fs.Handle               = (*File)(nil)
+	_ fs.NodeForgetter        = (*File)(nil)
+	_ fs.NodeOpener           = (*File)(nil)
+	_ fs.HandleReleaser       = (*File)(nil)
+	_ fs.HandleReader         = (*File)(nil)
+	_ fs.HandleWriter         = (*File)(nil)
+	_ fs.HandleFlusher        = (*File)(nil)
+	_ fs.NodeFsyncer          = (*File)(nil)
+	_ fs.NodeSetattrer        = (*File)(nil)
+	_ fs.HandleFallocater     = (*File)(nil)
+	_ fs.HandleCopyFileRanger = (*File)(nil)
+	_ fs.HandleIoctler        = (*File)(nil)
+	_ fs.NodeReadlinker       = (*File)(nil)
+	_ fs.NodeGetxattrer       = (*File)(nil)
+	_ fs.NodeListxattrer      = (*File)(nil)
+	_ fs.NodeSetxattrer       = (*File)(nil)
+	_ fs.NodeRemovexattrer    = (*File)(nil)
 )
 
 // NewFile returns a new file.
@@ -212,6 +218,14 @@ func (f *File) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenR
 	log.LogDebugf("TRACE open ino(%v) info(%v)", ino, f.info)
 	start := time.Now()
 
+	// the data of the files protected by object lock is not overwritten in place
+	if !req.Flags.IsReadOnly() && proto.IsHot(f.super.volType) {
+		if err = f.checkLocked(); err != nil {
+			log.LogErrorf("Open: ino(%v) flags(%v) err(%v)", ino, req.Flags, err)
+			return nil, ParseError(err)
+		}
+	}
+
 	if f.super.bcacheDir != "" && !f.filterFilesSuffix(f.super.bcacheFilterFiles) {
 		parentPath := f.getParentPath()
 		if parentPath != "" && !strings.HasSuffix(parentPath, "/") {
@@ -278,6 +292,26 @@ func (f *File) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenR
 	return f, nil
 }
 
+// checkLocked returns EPERM if the file is protected by object lock.
+func (f *File) checkLocked() error {
+	keys := []string{proto.XAttrKeyObjectLockMode, proto.XAttrKeyObjectLockRetainUntil, proto.XAttrKeyObjectLockLegalHold}
+	infos, err := f.super.mw.BatchGetXAttr([]uint64{f.info.Inode}, keys)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if info.Inode != f.info.Inode {
+			continue
+		}
+		lock := proto.ParseObjectLock(string(info.Get(proto.XAttrKeyObjectLockMode)),
+			string(info.Get(proto.XAttrKeyObjectLockRetainUntil)), string(info.Get(proto.XAttrKeyObjectLockLegalHold)))
+		if lock.Locked(time.Now().Unix()) {
+			return syscall.EPERM
+		}
+	}
+	return nil
+}
+
 // Release handles the release request.
 func (f *File) Release(ctx context.Context, req *fuse.ReleaseRequest) (err error) {
 
@@ -296,6 +330,10 @@ func (f *File) Release(ctx context.Context, req *fuse.ReleaseRequest) (err error
 
 	start := time.Now()
 
+	if req.ReleaseFlags&fuse.ReleaseFlockUnlock != 0 {
+		f.releaseLocks(req.LockOwner, true)
+	}
+
 	//log.LogErrorf("TRACE Release close stream: ino(%v) req(%v)", ino, req)
 	//if f.fWriter != nil {
 	//	f.fWriter.Close()
@@ -320,6 +358,15 @@ func (f *File) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadR
 		stat.StatBandWidth("Read", uint32(req.Size))
 	}()
 
+	span, ctx := trace.StartSpan(ctx, "fuse.Read")
+	span.SetTag("ino", f.info.Inode)
+	span.SetTag("offset", req.Offset)
+	span.SetTag("size", req.Size)
+	defer func() {
+		span.SetError(err)
+		span.Finish()
+	}()
+
 	log.LogDebugf("TRACE Read enter: ino(%v) offset(%v) reqsize(%v) req(%v)", f.info.Inode, req.Offset, req.Size, req)
 
 	start := time.Now()
@@ -330,7 +377,7 @@ func (f *File) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadR
 	}()
 	var size int
 	if proto.IsHot(f.super.volType) {
-		size, err = f.super.ec.Read(f.info.Inode, resp.Data[fuse.OutHeaderSize:], int(req.Offset), req.Size)
+		size, err = f.super.ec.ReadWithContext(ctx, f.info.Inode, resp.Data[fuse.OutHeaderSize:], int(req.Offset), req.Size)
 	} else {
 		size, err = f.fReader.Read(ctx, resp.Data[fuse.OutHeaderSize:], int(req.Offset), req.Size)
 	}
@@ -371,6 +418,15 @@ func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.Wri
 		stat.StatBandWidth("Write", uint32(len(req.Data)))
 	}()
 
+	span, ctx := trace.StartSpan(ctx, "fuse.Write")
+	span.SetTag("ino", f.info.Inode)
+	span.SetTag("offset", req.Offset)
+	span.SetTag("size", len(req.Data))
+	defer func() {
+		span.SetError(err)
+		span.Finish()
+	}()
+
 	ino := f.info.Inode
 	reqlen := len(req.Data)
 	log.LogDebugf("TRACE Write enter: ino(%v) offset(%v) len(%v)  flags(%v) fileflags(%v) quotaIds(%v) req(%v)",
@@ -436,7 +492,7 @@ func (f *File) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.Wri
 	var size int
 	if proto.IsHot(f.super.volType) {
 		f.super.ec.GetStreamer(ino).SetParentInode(f.parentIno)
-		if size, err = f.super.ec.Write(ino, int(req.Offset), req.Data, flags, checkFunc); err == ParseError(syscall.ENOSPC) {
+		if size, err = f.super.ec.WriteWithContext(ctx, ino, int(req.Offset), req.Data, flags, checkFunc); err == ParseError(syscall.ENOSPC) {
 			return
 		}
 	} else {
@@ -483,6 +539,16 @@ func (f *File) Flush(ctx context.Context, req *fuse.FlushRequest) (err error) {
 		stat.EndStat("Flush", err, bgTime, 1)
 	}()
 
+	// The posix locks of the owner are released on close, and the flush must not be disabled by
+	// returning ENOSYS if the locks are enabled.
+	if f.super.enableFileLock {
+		if _, ok := f.super.posixLocked.Load(f.info.Inode); ok {
+			f.releaseLocks(req.LockOwner, false)
+		}
+		if !f.super.fsyncOnClose {
+			return nil
+		}
+	}
 	if !f.super.fsyncOnClose {
 		return fuse.ENOSYS
 	}
@@ -496,9 +562,9 @@ func (f *File) Flush(ctx context.Context, req *fuse.FlushRequest) (err error) {
 	if proto.IsHot(f.super.volType) {
 		err = f.super.ec.Flush(f.info.Inode)
 	} else {
-		f.Lock()
+		f.fWriterLock.Lock()
 		err = f.fWriter.Flush(f.info.Inode, ctx)
-		f.Unlock()
+		f.fWriterLock.Unlock()
 	}
 	log.LogDebugf("TRACE Flush: ino(%v) err(%v)", f.info.Inode, err)
 	if err != nil {
@@ -595,6 +661,9 @@ func (f *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse
 		}
 	}
 
+	// the attributes are cached in the listings of the parents
+	f.super.metaCache.Invalidate(ino)
+
 	fillAttr(info, &resp.Attr)
 
 	elapsed := time.Since(start)
@@ -602,6 +671,96 @@ func (f *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse
 	return nil
 }
 
+// Fallocate handles the fallocate request. The space is not reserved by the data nodes, so the preallocation
+// only extends the size of the file unless FALLOC_FL_KEEP_SIZE is given, and the range punched or zeroed is
+// removed from the extents, which is read as zeros afterwards.
+func (f *File) Fallocate(ctx context.Context, req *fuse.FallocateRequest) (err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("Fallocate", err, bgTime, 1)
+	}()
+
+	ino := f.info.Inode
+	start := time.Now()
+	if !proto.IsHot(f.super.volType) || req.Mode&^(fuse.FallocKeepSize|fuse.FallocPunchHole|fuse.FallocZeroRange) != 0 {
+		return fuse.Errno(syscall.EOPNOTSUPP)
+	}
+	punch := req.Mode&fuse.FallocPunchHole != 0
+	zero := req.Mode&fuse.FallocZeroRange != 0
+	keepSize := req.Mode&fuse.FallocKeepSize != 0
+	if req.Length == 0 || (punch && (zero || !keepSize)) {
+		return fuse.Errno(syscall.EINVAL)
+	}
+	defer f.super.ic.Delete(ino)
+
+	if punch || zero {
+		if err = f.super.ec.PunchHole(ino, int(req.Offset), int(req.Length)); err != nil {
+			log.LogErrorf("Fallocate: punch hole ino(%v) req(%v) err(%v)", ino, req, err)
+			return ParseError(err)
+		}
+	}
+
+	if !keepSize {
+		size, _, valid := f.super.ec.FileSize(ino)
+		if !valid {
+			var info *proto.InodeInfo
+			if info, err = f.super.InodeGet(ino); err != nil {
+				log.LogErrorf("Fallocate: InodeGet failed, ino(%v) err(%v)", ino, err)
+				return ParseError(err)
+			}
+			size = int(info.Size)
+		}
+		if end := int(req.Offset + req.Length); end > size {
+			if err = f.super.ec.Truncate(f.super.mw, f.parentIno, ino, end); err != nil {
+				log.LogErrorf("Fallocate: extend ino(%v) size(%v) err(%v)", ino, end, err)
+				return ParseError(err)
+			}
+		}
+	}
+
+	elapsed := time.Since(start)
+	log.LogDebugf("TRACE Fallocate: ino(%v) req(%v) (%v)ns", ino, req, elapsed.Nanoseconds())
+	return nil
+}
+
+// CopyFileRange clones the range of the file into the destination, the data extents are shared by the files
+// on the meta node instead of being copied through the client. EOPNOTSUPP makes the kernel fall back to
+// copying the data if the files can not be cloned.
+func (f *File) CopyFileRange(ctx context.Context, req *fuse.CopyFileRangeRequest, dst fs.Handle, resp *fuse.CopyFileRangeResponse) (err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("CopyFileRange", err, bgTime, 1)
+	}()
+
+	ino := f.info.Inode
+	start := time.Now()
+	df, ok := dst.(*File)
+	if !ok || req.Flags != 0 {
+		return fuse.Errno(syscall.EOPNOTSUPP)
+	}
+	if req.Len == 0 {
+		return nil
+	}
+	// the size of the response is 32 bits
+	length := req.Len
+	if length > math.MaxUint32 {
+		length = math.MaxUint32
+	}
+	dino := df.info.Inode
+	size, err := f.super.cloneRange(ino, req.Offset, length, dino, req.OffsetOut)
+	if err != nil {
+		if err == syscall.EXDEV || err == syscall.EOPNOTSUPP {
+			return fuse.Errno(syscall.EOPNOTSUPP)
+		}
+		return ParseError(err)
+	}
+	resp.Size = int(size)
+
+	elapsed := time.Since(start)
+	log.LogDebugf("TRACE CopyFileRange: ino(%v) dino(%v) req(%v) resp(%v) (%v)ns", ino, dino, req, resp, elapsed.Nanoseconds())
+	return nil
+}
+
 // Readlink handles the readlink request.
 func (f *File) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
 	var err error
@@ -0,0 +1,214 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/depends/bazil.org/fuse"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/stat"
+)
+
+// The clone ioctls take the same arguments as FICLONE and FICLONERANGE of Linux, but are passed by pointer.
+// The VFS serves FICLONE and FICLONERANGE itself by the remap_file_range of the file system, which FUSE does
+// not support, so they fail with EOPNOTSUPP on the mount and the clones are asked by these commands instead:
+//
+//	ioctl(dst, CfsIocClone, &srcFd)
+//	ioctl(dst, CfsIocCloneRange, &struct{ srcFd int64; srcOffset, srcLength, dstOffset uint64 }{...})
+const (
+	CfsIocClone      = 0x4004cf09 // _IOW(0xcf, 9, int32)
+	CfsIocCloneRange = 0x4020cf0d // _IOW(0xcf, 13, struct file_clone_range)
+
+	fileCloneRangeSize = 32
+)
+
+// cloneRange clones the range of the file into the destination, the data extents are shared by the files on
+// the meta node instead of being copied through the client. The range is cut at the end of the file, and to
+// the end of the file if length is 0. The size cloned is returned.
+func (s *Super) cloneRange(ino, offset, length, dino, dstOffset uint64) (size uint64, err error) {
+	if !proto.IsHot(s.volType) || ino == dino || !s.mw.IsSamePartition(ino, dino) {
+		return 0, syscall.EOPNOTSUPP
+	}
+	if err = s.ec.Flush(ino); err != nil {
+		log.LogErrorf("cloneRange: flush ino(%v) err(%v)", ino, err)
+		return
+	}
+	if err = s.ec.Flush(dino); err != nil {
+		log.LogErrorf("cloneRange: flush ino(%v) err(%v)", dino, err)
+		return
+	}
+	fileSize, _, valid := s.ec.FileSize(ino)
+	if !valid {
+		var info *proto.InodeInfo
+		if info, err = s.InodeGet(ino); err != nil {
+			log.LogErrorf("cloneRange: InodeGet failed, ino(%v) err(%v)", ino, err)
+			return
+		}
+		fileSize = int(info.Size)
+	}
+	if offset >= uint64(fileSize) {
+		return 0, nil
+	}
+	size = uint64(fileSize) - offset
+	if length > 0 && length < size {
+		size = length
+	}
+
+	// the extents of both the files become shared, which are written to the new extents from now on
+	defer func() {
+		s.ic.Delete(ino)
+		s.ic.Delete(dino)
+	}()
+	if _, err = s.mw.CloneInode_ll(ino, offset, size, dino, dstOffset, 0, 0, 0); err != nil {
+		log.LogErrorf("cloneRange: ino(%v) offset(%v) size(%v) dino(%v) dstOffset(%v) err(%v)",
+			ino, offset, size, dino, dstOffset, err)
+		return 0, err
+	}
+	for _, inode := range []uint64{ino, dino} {
+		if err = s.ec.ForceRefreshExtentsCache(inode); err != nil {
+			log.LogErrorf("cloneRange: refresh extents ino(%v) err(%v)", inode, err)
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
+// Ioctl serves the clone ioctls, see CfsIocClone.
+func (f *File) Ioctl(ctx context.Context, req *fuse.IoctlRequest, resp *fuse.IoctlResponse) (err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("Ioctl", err, bgTime, 1)
+	}()
+
+	var (
+		srcFd                           int64
+		srcOffset, srcLength, dstOffset uint64
+	)
+	switch req.Cmd {
+	case CfsIocClone:
+		if len(req.Data) < 4 {
+			return fuse.Errno(syscall.EINVAL)
+		}
+		srcFd = int64(int32(binary.LittleEndian.Uint32(req.Data)))
+	case CfsIocCloneRange:
+		if len(req.Data) < fileCloneRangeSize {
+			return fuse.Errno(syscall.EINVAL)
+		}
+		srcFd = int64(binary.LittleEndian.Uint64(req.Data))
+		srcOffset = binary.LittleEndian.Uint64(req.Data[8:])
+		srcLength = binary.LittleEndian.Uint64(req.Data[16:])
+		dstOffset = binary.LittleEndian.Uint64(req.Data[24:])
+	default:
+		return fuse.ENOTTY
+	}
+
+	start := time.Now()
+	dino := f.info.Inode
+	if !f.writable(req.Uid, req.Gid) {
+		return fuse.EPERM
+	}
+	ino, err := f.super.inodeOfFd(req.Pid, srcFd)
+	if err != nil {
+		log.LogErrorf("Ioctl: ino(%v) req(%v) source fd err(%v)", dino, req, err)
+		return ParseError(err)
+	}
+	if _, err = f.super.cloneRange(ino, srcOffset, srcLength, dino, dstOffset); err != nil {
+		if err == syscall.EXDEV || err == syscall.EOPNOTSUPP {
+			return fuse.Errno(syscall.EOPNOTSUPP)
+		}
+		return ParseError(err)
+	}
+
+	elapsed := time.Since(start)
+	log.LogDebugf("TRACE Ioctl: ino(%v) dino(%v) req(%v) (%v)ns", ino, dino, req, elapsed.Nanoseconds())
+	return nil
+}
+
+// writable checks the user may write the file, which the kernel does not check for the ioctls.
+func (f *File) writable(uid, gid uint32) bool {
+	if uid == 0 {
+		return true
+	}
+	mode := f.info.Mode
+	switch {
+	case uid == f.info.Uid:
+		return mode&0200 != 0
+	case gid == f.info.Gid:
+		return mode&0020 != 0
+	default:
+		return mode&0002 != 0
+	}
+}
+
+// inodeOfFd returns the inode of the file opened for reading as the fd by the process, which must be a file
+// of the mount.
+func (s *Super) inodeOfFd(pid uint32, fd int64) (uint64, error) {
+	if fd < 0 {
+		return 0, syscall.EBADF
+	}
+	flags, err := fdFlags(pid, fd)
+	if err != nil {
+		return 0, syscall.EBADF
+	}
+	if flags&syscall.O_ACCMODE == syscall.O_WRONLY {
+		return 0, syscall.EBADF
+	}
+	var st, mst syscall.Stat_t
+	if err = syscall.Stat(fmt.Sprintf("/proc/%d/fd/%d", pid, fd), &st); err != nil {
+		return 0, syscall.EBADF
+	}
+	if err = syscall.Stat(s.mountPoint, &mst); err != nil {
+		return 0, err
+	}
+	if st.Dev != mst.Dev {
+		return 0, syscall.EXDEV
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFREG {
+		return 0, syscall.EINVAL
+	}
+	return st.Ino, nil
+}
+
+// fdFlags returns the open flags of the fd of the process.
+func fdFlags(pid uint32, fd int64) (int, error) {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/fdinfo/%d", pid, fd))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "flags:") {
+			continue
+		}
+		flags, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "flags:")), 8, 64)
+		return int(flags), err
+	}
+	if err = scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no flags of fd %v of process %v", fd, pid)
+}
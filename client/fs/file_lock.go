@@ -0,0 +1,168 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"math"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/depends/bazil.org/fuse"
+	"github.com/cubefs/cubefs/depends/bazil.org/fuse/fs"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/stat"
+)
+
+const (
+	lockWaitMinInterval = 10 * time.Millisecond
+	lockWaitMaxInterval = time.Second
+)
+
+var _ fs.HandleLocker = (*File)(nil)
+
+// fileLock converts the lock of the kernel into the advisory lock held by the meta node.
+func fileLock(lock fuse.FileLock, owner uint64, flags fuse.LockFlags) *proto.FileLock {
+	l := &proto.FileLock{
+		Owner: owner,
+		Pid:   lock.PID,
+		Start: lock.Start,
+		End:   lock.End,
+		Flock: flags&fuse.LockFlock != 0,
+	}
+	// OFFSET_MAX of the kernel means the end of file
+	if l.End >= math.MaxInt64 {
+		l.End = proto.FileLockEOF
+	}
+	switch lock.Type {
+	case fuse.LockRead:
+		l.Type = proto.FileLockRead
+	case fuse.LockWrite:
+		l.Type = proto.FileLockWrite
+	default:
+		l.Type = proto.FileLockUnlock
+	}
+	return l
+}
+
+func (f *File) setFileLock(lock *proto.FileLock) (err error) {
+	if _, err = f.super.mw.SetFileLock_ll(f.info.Inode, lock); err != nil {
+		return ParseError(err)
+	}
+	if !lock.Flock && lock.Type != proto.FileLockUnlock {
+		f.super.posixLocked.Store(f.info.Inode, struct{}{})
+	}
+	return nil
+}
+
+// Lock handles the F_SETLK and the non-blocking flock requests.
+func (f *File) Lock(ctx context.Context, req *fuse.LockRequest) (err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("Lock", err, bgTime, 1)
+	}()
+
+	err = f.setFileLock(fileLock(req.Lock, req.LockOwner, req.LockFlags))
+	log.LogDebugf("TRACE Lock: ino(%v) req(%v) err(%v)", f.info.Inode, req, err)
+	return
+}
+
+// LockWait handles the F_SETLKW and the blocking flock requests, the lock is retried until it is
+// acquired or the request is interrupted.
+func (f *File) LockWait(ctx context.Context, req *fuse.LockWaitRequest) (err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("LockWait", err, bgTime, 1)
+	}()
+
+	lock := fileLock(req.Lock, req.LockOwner, req.LockFlags)
+	interval := lockWaitMinInterval
+	for {
+		if err = f.setFileLock(lock); err != fuse.Errno(syscall.EAGAIN) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			err = fuse.EINTR
+			log.LogDebugf("TRACE LockWait interrupted: ino(%v) req(%v)", f.info.Inode, req)
+			return
+		case <-time.After(interval):
+		}
+		if interval *= 2; interval > lockWaitMaxInterval {
+			interval = lockWaitMaxInterval
+		}
+	}
+	log.LogDebugf("TRACE LockWait: ino(%v) req(%v) err(%v)", f.info.Inode, req, err)
+	return
+}
+
+// Unlock handles the F_UNLCK and LOCK_UN requests.
+func (f *File) Unlock(ctx context.Context, req *fuse.UnlockRequest) (err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("Unlock", err, bgTime, 1)
+	}()
+
+	err = f.setFileLock(fileLock(req.Lock, req.LockOwner, req.LockFlags))
+	log.LogDebugf("TRACE Unlock: ino(%v) req(%v) err(%v)", f.info.Inode, req, err)
+	return
+}
+
+// QueryLock handles the F_GETLK requests.
+func (f *File) QueryLock(ctx context.Context, req *fuse.QueryLockRequest, resp *fuse.QueryLockResponse) (err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("QueryLock", err, bgTime, 1)
+	}()
+
+	conflict, err := f.super.mw.GetFileLock_ll(f.info.Inode, fileLock(req.Lock, req.LockOwner, req.LockFlags))
+	if err != nil {
+		log.LogErrorf("QueryLock: ino(%v) req(%v) err(%v)", f.info.Inode, req, err)
+		return ParseError(err)
+	}
+	if conflict != nil {
+		resp.Lock = fuse.FileLock{
+			Start: conflict.Start,
+			End:   conflict.End,
+			Type:  fuse.LockRead,
+		}
+		if conflict.End == proto.FileLockEOF {
+			resp.Lock.End = math.MaxInt64
+		}
+		if conflict.Type == proto.FileLockWrite {
+			resp.Lock.Type = fuse.LockWrite
+		}
+		// the process id is only meaningful for the locks held by this client
+		if conflict.Session == f.super.mw.FileLockSession() {
+			resp.Lock.PID = conflict.Pid
+		}
+	}
+	log.LogDebugf("TRACE QueryLock: ino(%v) req(%v) resp(%v)", f.info.Inode, req, resp)
+	return nil
+}
+
+// releaseLocks releases the locks of the owner, which are released by the kernel on close.
+func (f *File) releaseLocks(owner uint64, flock bool) {
+	lock := &proto.FileLock{
+		Owner: owner,
+		End:   proto.FileLockEOF,
+		Type:  proto.FileLockUnlock,
+		Flock: flock,
+	}
+	if _, err := f.super.mw.SetFileLock_ll(f.info.Inode, lock); err != nil {
+		log.LogWarnf("releaseLocks: ino(%v) owner(%v) flock(%v) err(%v)", f.info.Inode, owner, flock, err)
+	}
+}
@@ -0,0 +1,530 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"container/list"
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	metaCacheListingDir    = "dirs"
+	metaCacheWatermarkFile = "watermarks"
+
+	// MaxMetaCacheLoaded is the number of the listings kept in memory.
+	MaxMetaCacheLoaded = 64
+
+	metaCacheFlushInterval   = time.Second
+	metaCacheRefreshInterval = time.Minute
+	metaCacheRetryInterval   = time.Second
+)
+
+// metaCacheListing is the listing of a directory persisted on the local disk.
+type metaCacheListing struct {
+	Dentries []proto.Dentry     `json:"dentries"` // ordered by the names
+	Inodes   []*proto.InodeInfo `json:"inodes"`
+	// the meta partitions of the directory and the children
+	Partitions []uint64 `json:"partitions"`
+
+	infos map[uint64]*proto.InodeInfo
+}
+
+// metaCacheIndex is the in-memory index of a persisted listing.
+type metaCacheIndex struct {
+	partitions []uint64
+	children   []uint64
+}
+
+// metaCacheWatermark is the last change seen from a meta partition.
+type metaCacheWatermark struct {
+	Epoch uint64 `json:"epoch"`
+	Seq   uint64 `json:"seq"`
+}
+
+// metaCacheFill is a listing being fetched from the meta nodes, which is abandoned if the directory
+// or the children are changed meanwhile.
+type metaCacheFill struct {
+	touched map[uint64]struct{}
+	dirty   bool
+}
+
+// MetaCache persists the listings of the directories along with the inodes of the children on the local disk,
+// so that listing the read-mostly directories, e.g. the datasets, does not hit the meta nodes even after the
+// client is restarted. The listings are invalidated by the changes pushed by the meta nodes: each partition
+// is watched from the last change seen, which is persisted too. A listing is served only if all the partitions
+// it depends on are being watched, and is dropped if the changes of a partition are lost.
+type MetaCache struct {
+	sync.Mutex
+	dir string
+	mw  *meta.MetaWrapper
+
+	indexed  bool
+	listings map[uint64]*metaCacheIndex
+	// the directories listing the child
+	parents map[uint64][]uint64
+	loaded  map[uint64]*list.Element
+	lruList *list.List
+	fills   map[uint64]*metaCacheFill
+
+	watermarks     map[uint64]*metaCacheWatermark
+	watermarkDirty bool
+	watching       map[uint64]bool
+	synced         map[uint64]bool
+
+	closeC chan struct{}
+}
+
+// NewMetaCache returns the cache persisted in the directory, the listings of the volume are kept in the
+// sub-directory named by the volume.
+func NewMetaCache(dir, volname string, mw *meta.MetaWrapper) (*MetaCache, error) {
+	c := &MetaCache{
+		dir:        path.Join(dir, volname),
+		mw:         mw,
+		listings:   make(map[uint64]*metaCacheIndex),
+		parents:    make(map[uint64][]uint64),
+		loaded:     make(map[uint64]*list.Element),
+		lruList:    list.New(),
+		fills:      make(map[uint64]*metaCacheFill),
+		watermarks: make(map[uint64]*metaCacheWatermark),
+		watching:   make(map[uint64]bool),
+		synced:     make(map[uint64]bool),
+		closeC:     make(chan struct{}),
+	}
+	if err := os.MkdirAll(path.Join(c.dir, metaCacheListingDir), 0755); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path.Join(c.dir, metaCacheWatermarkFile))
+	if err == nil {
+		if err = json.Unmarshal(data, &c.watermarks); err != nil {
+			log.LogWarnf("NewMetaCache: invalid watermarks, err(%v)", err)
+			c.watermarks = make(map[uint64]*metaCacheWatermark)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	go c.run()
+	return c, nil
+}
+
+// Close stops watching the changes and persists the watermarks.
+func (c *MetaCache) Close() {
+	if c == nil {
+		return
+	}
+	close(c.closeC)
+	c.flushWatermarks()
+}
+
+func (c *MetaCache) run() {
+	c.loadIndex()
+	flushTicker := time.NewTicker(metaCacheFlushInterval)
+	defer flushTicker.Stop()
+	refreshTicker := time.NewTicker(metaCacheRefreshInterval)
+	defer refreshTicker.Stop()
+	c.refreshWatchers()
+	for {
+		select {
+		case <-flushTicker.C:
+			c.flushWatermarks()
+		case <-refreshTicker.C:
+			c.refreshWatchers()
+		case <-c.closeC:
+			return
+		}
+	}
+}
+
+func (c *MetaCache) listingPath(ino uint64) string {
+	return path.Join(c.dir, metaCacheListingDir, strconv.FormatUint(ino, 10))
+}
+
+// loadIndex indexes the persisted listings, which are not served until indexed.
+func (c *MetaCache) loadIndex() {
+	entries, err := os.ReadDir(path.Join(c.dir, metaCacheListingDir))
+	if err != nil {
+		log.LogErrorf("MetaCache: read listings err(%v)", err)
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	for _, entry := range entries {
+		ino, err := strconv.ParseUint(entry.Name(), 10, 64)
+		if err != nil {
+			os.Remove(path.Join(c.dir, metaCacheListingDir, entry.Name()))
+			continue
+		}
+		listing, err := c.readListing(ino)
+		if err != nil {
+			log.LogWarnf("MetaCache: drop invalid listing(%v) err(%v)", ino, err)
+			os.Remove(c.listingPath(ino))
+			continue
+		}
+		c.indexLocked(ino, listing)
+	}
+	c.indexed = true
+	log.LogInfof("MetaCache: indexed listings(%v)", len(c.listings))
+}
+
+func (c *MetaCache) readListing(ino uint64) (*metaCacheListing, error) {
+	data, err := os.ReadFile(c.listingPath(ino))
+	if err != nil {
+		return nil, err
+	}
+	listing := &metaCacheListing{}
+	if err = json.Unmarshal(data, listing); err != nil {
+		return nil, err
+	}
+	listing.infos = make(map[uint64]*proto.InodeInfo, len(listing.Inodes))
+	for _, info := range listing.Inodes {
+		listing.infos[info.Inode] = info
+	}
+	return listing, nil
+}
+
+func (c *MetaCache) indexLocked(ino uint64, listing *metaCacheListing) {
+	index := &metaCacheIndex{
+		partitions: listing.Partitions,
+		children:   make([]uint64, 0, len(listing.Dentries)),
+	}
+	for _, dentry := range listing.Dentries {
+		index.children = append(index.children, dentry.Inode)
+		c.parents[dentry.Inode] = append(c.parents[dentry.Inode], ino)
+	}
+	c.listings[ino] = index
+}
+
+// dropLocked removes the listing of the directory.
+func (c *MetaCache) dropLocked(ino uint64) {
+	index, ok := c.listings[ino]
+	if !ok {
+		return
+	}
+	if err := os.Remove(c.listingPath(ino)); err != nil && !os.IsNotExist(err) {
+		log.LogWarnf("MetaCache: remove listing(%v) err(%v)", ino, err)
+	}
+	delete(c.listings, ino)
+	for _, child := range index.children {
+		parents := c.parents[child]
+		for i, parent := range parents {
+			if parent == ino {
+				parents = append(parents[:i], parents[i+1:]...)
+				break
+			}
+		}
+		if len(parents) == 0 {
+			delete(c.parents, child)
+		} else {
+			c.parents[child] = parents
+		}
+	}
+	if element, ok := c.loaded[ino]; ok {
+		c.lruList.Remove(element)
+		delete(c.loaded, ino)
+	}
+}
+
+// Invalidate drops the listings of the inodes and the listings containing them.
+func (c *MetaCache) Invalidate(inodes ...uint64) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	for _, ino := range inodes {
+		c.dropLocked(ino)
+		// the dentries of the sharded directory are changed in its shards
+		if owner := c.mw.DirShardOwner(ino); owner != 0 {
+			c.dropLocked(owner)
+		}
+		for _, parent := range append([]uint64(nil), c.parents[ino]...) {
+			c.dropLocked(parent)
+		}
+		for _, fill := range c.fills {
+			fill.touched[ino] = struct{}{}
+		}
+	}
+}
+
+// reset drops the listings depending on the partition, whose changes are lost.
+func (c *MetaCache) reset(pid uint64) {
+	c.Lock()
+	defer c.Unlock()
+	for ino, index := range c.listings {
+		for _, id := range index.partitions {
+			if id == pid {
+				c.dropLocked(ino)
+				break
+			}
+		}
+	}
+	for _, fill := range c.fills {
+		fill.dirty = true
+	}
+}
+
+// refreshWatchers watches the partitions not watched yet, e.g. the ones newly created.
+func (c *MetaCache) refreshWatchers() {
+	for _, pid := range c.mw.MetaPartitionIDs() {
+		c.Lock()
+		watching := c.watching[pid]
+		c.watching[pid] = true
+		c.Unlock()
+		if !watching {
+			go c.watch(pid)
+		}
+	}
+}
+
+// watch applies the changes of the partition from the watermark on.
+func (c *MetaCache) watch(pid uint64) {
+	for {
+		select {
+		case <-c.closeC:
+			return
+		default:
+		}
+		c.Lock()
+		var epoch, seq uint64
+		if wm, ok := c.watermarks[pid]; ok {
+			epoch, seq = wm.Epoch, wm.Seq
+		}
+		c.Unlock()
+
+		resp, err := c.mw.WatchChanges_ll(pid, epoch, seq)
+		if err != nil {
+			log.LogWarnf("MetaCache: watch partition(%v) err(%v)", pid, err)
+			c.Lock()
+			c.synced[pid] = false
+			if err == syscall.ENOENT {
+				delete(c.watching, pid)
+				c.Unlock()
+				return
+			}
+			c.Unlock()
+			time.Sleep(metaCacheRetryInterval)
+			continue
+		}
+		if resp.Reset {
+			log.LogInfof("MetaCache: reset partition(%v) epoch(%v) seq(%v)", pid, resp.Epoch, resp.Seq)
+			c.reset(pid)
+		} else if len(resp.Inodes) > 0 {
+			c.Invalidate(resp.Inodes...)
+		}
+		c.Lock()
+		c.watermarks[pid] = &metaCacheWatermark{Epoch: resp.Epoch, Seq: resp.Seq}
+		c.watermarkDirty = true
+		c.synced[pid] = true
+		c.Unlock()
+	}
+}
+
+// flushWatermarks persists the watermarks, whose changes have been applied to the persisted listings.
+func (c *MetaCache) flushWatermarks() {
+	c.Lock()
+	if !c.watermarkDirty {
+		c.Unlock()
+		return
+	}
+	data, err := json.Marshal(c.watermarks)
+	c.watermarkDirty = false
+	c.Unlock()
+	if err != nil {
+		return
+	}
+	filename := path.Join(c.dir, metaCacheWatermarkFile)
+	if err = os.WriteFile(filename+".tmp", data, 0644); err == nil {
+		err = os.Rename(filename+".tmp", filename)
+	}
+	if err != nil {
+		log.LogWarnf("MetaCache: flush watermarks err(%v)", err)
+		c.Lock()
+		c.watermarkDirty = true
+		c.Unlock()
+	}
+}
+
+// getLocked returns the listing of the directory if it can be served.
+func (c *MetaCache) getLocked(ino uint64) *metaCacheListing {
+	index, ok := c.listings[ino]
+	if !ok {
+		return nil
+	}
+	for _, pid := range index.partitions {
+		if !c.synced[pid] {
+			return nil
+		}
+	}
+	if element, ok := c.loaded[ino]; ok {
+		c.lruList.MoveToFront(element)
+		return element.Value.(*metaCacheListing)
+	}
+	listing, err := c.readListing(ino)
+	if err != nil {
+		log.LogWarnf("MetaCache: read listing(%v) err(%v)", ino, err)
+		c.dropLocked(ino)
+		return nil
+	}
+	if c.lruList.Len() >= MaxMetaCacheLoaded {
+		back := c.lruList.Back()
+		c.lruList.Remove(back)
+		for id, element := range c.loaded {
+			if element == back {
+				delete(c.loaded, id)
+				break
+			}
+		}
+	}
+	c.loaded[ino] = c.lruList.PushFront(listing)
+	return listing
+}
+
+// ReadDirLimit returns the dentries of the directory from the name on as ReadDirLimit_ll does, along with
+// the inodes of them. ok is false if the listing is not cached.
+func (c *MetaCache) ReadDirLimit(ino uint64, from string, limit uint64) (dentries []proto.Dentry, infos []*proto.InodeInfo, ok bool) {
+	if c == nil {
+		return nil, nil, false
+	}
+	c.Lock()
+	defer c.Unlock()
+	listing := c.getLocked(ino)
+	if listing == nil {
+		return nil, nil, false
+	}
+	i := sort.Search(len(listing.Dentries), func(i int) bool { return listing.Dentries[i].Name >= from })
+	end := len(listing.Dentries)
+	if limit > 0 && uint64(end-i) > limit {
+		end = i + int(limit)
+	}
+	dentries = append([]proto.Dentry(nil), listing.Dentries[i:end]...)
+	infos = make([]*proto.InodeInfo, 0, len(dentries))
+	for _, dentry := range dentries {
+		if info, ok := listing.infos[dentry.Inode]; ok {
+			// the info is copied since its expiration is set by the inode cache
+			copied := *info
+			infos = append(infos, &copied)
+		}
+	}
+	return dentries, infos, true
+}
+
+// Fill fetches the listing of the directory from the meta nodes in the background.
+func (c *MetaCache) Fill(ino uint64) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	if !c.indexed || c.fills[ino] != nil || c.listings[ino] != nil {
+		c.Unlock()
+		return
+	}
+	fill := &metaCacheFill{touched: make(map[uint64]struct{})}
+	c.fills[ino] = fill
+	c.Unlock()
+	go c.fill(ino, fill)
+}
+
+func (c *MetaCache) fill(ino uint64, fill *metaCacheFill) {
+	listing, err := c.fetch(ino)
+	c.Lock()
+	defer c.Unlock()
+	delete(c.fills, ino)
+	if err != nil {
+		log.LogWarnf("MetaCache: fetch listing(%v) err(%v)", ino, err)
+		return
+	}
+	if fill.dirty {
+		return
+	}
+	if _, ok := fill.touched[ino]; ok {
+		return
+	}
+	for _, dentry := range listing.Dentries {
+		if _, ok := fill.touched[dentry.Inode]; ok {
+			return
+		}
+	}
+	// the changes applied before the partitions are watched may be missed
+	for _, pid := range listing.Partitions {
+		if !c.synced[pid] {
+			return
+		}
+	}
+	data, err := json.Marshal(listing)
+	if err != nil {
+		return
+	}
+	filename := c.listingPath(ino)
+	if err = os.WriteFile(filename+".tmp", data, 0644); err == nil {
+		err = os.Rename(filename+".tmp", filename)
+	}
+	if err != nil {
+		log.LogWarnf("MetaCache: write listing(%v) err(%v)", ino, err)
+		return
+	}
+	c.indexLocked(ino, listing)
+	log.LogDebugf("MetaCache: fill listing(%v) dentries(%v)", ino, len(listing.Dentries))
+}
+
+func (c *MetaCache) fetch(ino uint64) (*metaCacheListing, error) {
+	listing := &metaCacheListing{}
+	var from string
+	for {
+		batch, err := c.mw.ReadDirLimit_ll(ino, from, DefaultReaddirLimit)
+		if err != nil {
+			return nil, err
+		}
+		batchNr := uint64(len(batch))
+		if batchNr == 0 || (from != "" && batchNr == 1) {
+			break
+		}
+		if from != "" {
+			batch = batch[1:]
+		}
+		listing.Dentries = append(listing.Dentries, batch...)
+		if batchNr < DefaultReaddirLimit {
+			break
+		}
+		from = batch[len(batch)-1].Name
+	}
+
+	inodes := make([]uint64, 0, len(listing.Dentries))
+	partitions := map[uint64]struct{}{c.mw.MetaPartitionIDOf(ino): {}}
+	for _, shard := range c.mw.DirShards(ino) {
+		partitions[c.mw.MetaPartitionIDOf(shard)] = struct{}{}
+	}
+	for _, dentry := range listing.Dentries {
+		inodes = append(inodes, dentry.Inode)
+		partitions[c.mw.MetaPartitionIDOf(dentry.Inode)] = struct{}{}
+	}
+	if _, ok := partitions[0]; ok {
+		return nil, syscall.ENOENT
+	}
+	listing.Inodes = c.mw.BatchInodeGet(inodes)
+	for pid := range partitions {
+		listing.Partitions = append(listing.Partitions, pid)
+	}
+	return listing, nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/stretchr/testify/require"
+)
+
+const metaCacheTestVol = "vol"
+
+// writeTestListing persists the listing of the directory with the children named as given,
+// whose inodes follow the directory, the directory and the children are in the partition.
+func writeTestListing(t *testing.T, dir string, ino uint64, pid uint64, names ...string) {
+	listing := &metaCacheListing{Partitions: []uint64{pid}}
+	for i, name := range names {
+		child := ino + uint64(i) + 1
+		listing.Dentries = append(listing.Dentries, proto.Dentry{Name: name, Inode: child})
+		listing.Inodes = append(listing.Inodes, &proto.InodeInfo{Inode: child, Size: uint64(i)})
+	}
+	data, err := json.Marshal(listing)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path.Join(dir, metaCacheTestVol, metaCacheListingDir,
+		strconv.FormatUint(ino, 10)), data, 0644))
+}
+
+func newTestMetaCache(t *testing.T, dir string) *MetaCache {
+	c, err := NewMetaCache(dir, metaCacheTestVol, &meta.MetaWrapper{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		c.Lock()
+		defer c.Unlock()
+		return c.indexed
+	}, 5*time.Second, 10*time.Millisecond)
+	return c
+}
+
+// syncTestPartitions marks the partitions synced as the watchers do once the changes are applied.
+func syncTestPartitions(c *MetaCache, pids ...uint64) {
+	c.Lock()
+	defer c.Unlock()
+	for _, pid := range pids {
+		c.synced[pid] = true
+	}
+}
+
+func TestMetaCache_Load(t *testing.T) {
+	dir := t.TempDir()
+	listingDir := path.Join(dir, metaCacheTestVol, metaCacheListingDir)
+	require.NoError(t, os.MkdirAll(listingDir, 0755))
+	writeTestListing(t, dir, 1, 10, "a", "b", "c")
+	writeTestListing(t, dir, 30, 10, "d")
+	// the corrupt listings and the files not named by the inodes are dropped at indexing
+	require.NoError(t, os.WriteFile(path.Join(listingDir, "20"), []byte("{\"dentries\":[{"), 0644))
+	require.NoError(t, os.WriteFile(path.Join(listingDir, "tmp"), []byte("{}"), 0644))
+
+	c := newTestMetaCache(t, dir)
+	defer c.Close()
+	_, err := os.Stat(path.Join(listingDir, "20"))
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(path.Join(listingDir, "tmp"))
+	require.True(t, os.IsNotExist(err))
+	_, _, ok := c.ReadDirLimit(20, "", 0)
+	require.False(t, ok)
+
+	// not served until the partition is watched
+	_, _, ok = c.ReadDirLimit(1, "", 0)
+	require.False(t, ok)
+	syncTestPartitions(c, 10)
+	dentries, infos, ok := c.ReadDirLimit(1, "", 0)
+	require.True(t, ok)
+	require.Len(t, dentries, 3)
+	require.Len(t, infos, 3)
+
+	// paged from the name on as ReadDirLimit_ll does
+	dentries, infos, ok = c.ReadDirLimit(1, "b", 1)
+	require.True(t, ok)
+	require.Equal(t, []proto.Dentry{{Name: "b", Inode: 3}}, dentries)
+	require.Equal(t, uint64(3), infos[0].Inode)
+	require.Equal(t, uint64(1), infos[0].Size)
+	dentries, _, ok = c.ReadDirLimit(1, "d", 0)
+	require.True(t, ok)
+	require.Empty(t, dentries)
+
+	// the listing corrupted on the disk after indexing is dropped once read
+	require.NoError(t, os.WriteFile(path.Join(listingDir, "30"), []byte("{\"dentries\":"), 0644))
+	_, _, ok = c.ReadDirLimit(30, "", 0)
+	require.False(t, ok)
+	_, err = os.Stat(path.Join(listingDir, "30"))
+	require.True(t, os.IsNotExist(err))
+	c.Lock()
+	_, indexed := c.listings[30]
+	c.Unlock()
+	require.False(t, indexed)
+}
+
+func TestMetaCache_Expiry(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(dir, metaCacheTestVol, metaCacheListingDir), 0755))
+	writeTestListing(t, dir, 1, 10, "a", "b")
+	c := newTestMetaCache(t, dir)
+	defer c.Close()
+	syncTestPartitions(c, 10)
+
+	// the infos are copied, so that expiring them in the inode cache leaves the listing as is
+	_, infos, ok := c.ReadDirLimit(1, "", 0)
+	require.True(t, ok)
+	infos[0].SetExpiration(time.Now().Add(-time.Hour).UnixNano())
+	infos[0].Size = 100
+	_, infos, ok = c.ReadDirLimit(1, "", 0)
+	require.True(t, ok)
+	require.Equal(t, uint64(0), infos[0].Size)
+
+	// the listings are not served while the changes of the partition are not watched
+	c.Lock()
+	c.synced[10] = false
+	c.Unlock()
+	_, _, ok = c.ReadDirLimit(1, "", 0)
+	require.False(t, ok)
+	syncTestPartitions(c, 10)
+	_, _, ok = c.ReadDirLimit(1, "", 0)
+	require.True(t, ok)
+
+	// the loaded listings are evicted out of the limit and read again from the disk
+	for ino := uint64(100); ino < 100+MaxMetaCacheLoaded; ino++ {
+		writeTestListing(t, dir, ino*10, 10, "a")
+		listing, err := c.readListing(ino * 10)
+		require.NoError(t, err)
+		c.Lock()
+		c.indexLocked(ino*10, listing)
+		c.Unlock()
+		_, _, ok = c.ReadDirLimit(ino*10, "", 0)
+		require.True(t, ok)
+	}
+	c.Lock()
+	_, loaded := c.loaded[1]
+	require.Equal(t, MaxMetaCacheLoaded, c.lruList.Len())
+	require.Equal(t, MaxMetaCacheLoaded, len(c.loaded))
+	c.Unlock()
+	require.False(t, loaded)
+	_, _, ok = c.ReadDirLimit(1, "", 0)
+	require.True(t, ok)
+
+	// the listings depending on the partition whose changes are lost are dropped
+	c.reset(10)
+	_, _, ok = c.ReadDirLimit(1, "", 0)
+	require.False(t, ok)
+	_, err := os.Stat(c.listingPath(1))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestMetaCache_Invalidate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(dir, metaCacheTestVol, metaCacheListingDir), 0755))
+	writeTestListing(t, dir, 1, 10, "a", "b")
+	writeTestListing(t, dir, 10, 10, "c")
+	writeTestListing(t, dir, 20, 10, "d")
+	writeTestListing(t, dir, 30, 10, "e")
+	c := newTestMetaCache(t, dir)
+	defer c.Close()
+	syncTestPartitions(c, 10)
+	served := func(ino uint64) bool {
+		_, _, ok := c.ReadDirLimit(ino, "", 0)
+		return ok
+	}
+
+	// setattr on the child drops the listings of its parents
+	require.True(t, served(1))
+	c.Invalidate(2)
+	require.False(t, served(1))
+	_, err := os.Stat(c.listingPath(1))
+	require.True(t, os.IsNotExist(err))
+
+	// unlink drops the listing of the parent
+	require.True(t, served(10))
+	c.Invalidate(10)
+	require.False(t, served(10))
+
+	// rename drops the listings of both the source and the destination
+	require.True(t, served(20))
+	require.True(t, served(30))
+	c.Invalidate(20, 30)
+	require.False(t, served(20))
+	require.False(t, served(30))
+
+	// the listing being fetched is abandoned once the children are changed
+	fill := &metaCacheFill{touched: make(map[uint64]struct{})}
+	c.Lock()
+	c.fills[40] = fill
+	c.Unlock()
+	c.Invalidate(41)
+	c.Lock()
+	_, touched := fill.touched[41]
+	c.Unlock()
+	require.True(t, touched)
+}
+
+func TestMetaCache_Watermarks(t *testing.T) {
+	dir := t.TempDir()
+	volDir := path.Join(dir, metaCacheTestVol)
+	require.NoError(t, os.MkdirAll(volDir, 0755))
+	// the corrupt watermarks are ignored, so that the partitions are watched from the start
+	require.NoError(t, os.WriteFile(path.Join(volDir, metaCacheWatermarkFile), []byte("[1,2"), 0644))
+	c := newTestMetaCache(t, dir)
+	c.Lock()
+	require.Empty(t, c.watermarks)
+	c.watermarks[10] = &metaCacheWatermark{Epoch: 1, Seq: 5}
+	c.watermarkDirty = true
+	c.Unlock()
+	c.Close()
+
+	c = newTestMetaCache(t, dir)
+	defer c.Close()
+	c.Lock()
+	defer c.Unlock()
+	require.Equal(t, &metaCacheWatermark{Epoch: 1, Seq: 5}, c.watermarks[10])
+}
@@ -20,7 +20,8 @@ import (
 )
 
 // OrphanInodeList defines the orphan inode list, which is a list of orphan inodes.
-// An orphan inode is the inode whose nlink value is 0.
+// An orphan inode is the inode whose nlink value is 0. The orphan inodes are also recorded by the meta nodes
+// with the session of the client, which evict them if the client is gone before evicting them.
 type OrphanInodeList struct {
 	sync.RWMutex
 	cache map[uint64]*list.Element
@@ -0,0 +1,349 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/depends/bazil.org/fuse"
+	"github.com/cubefs/cubefs/depends/bazil.org/fuse/fs"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/data/stream"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// SnapshotDir is the hidden directory in the root of the volume, which lists the snapshots of the volume
+// by their names. The directory is not linked into the volume, and is not listed by the root.
+type SnapshotDir struct {
+	super *Super
+
+	sync.Mutex
+	snapshots map[string]*proto.SnapshotInfo
+	expire    time.Time
+}
+
+// Functions that SnapshotDir needs to implement
+var (
+	_ fs.Node                = (*SnapshotDir)(nil)
+	_ fs.NodeRequestLookuper = (*SnapshotDir)(nil)
+	_ fs.HandleReadDirAller  = (*SnapshotDir)(nil)
+)
+
+// NewSnapshotDir returns the directory of the snapshots.
+func NewSnapshotDir(s *Super) *SnapshotDir {
+	return &SnapshotDir{super: s}
+}
+
+// Attr sets the attributes of the directory, which is read-only.
+func (d *SnapshotDir) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Valid = SnapshotListExpiration
+	a.Inode = SnapshotDirInode
+	a.Mode = os.ModeDir | 0555
+	a.Nlink = 2
+	a.BlockSize = DefaultBlksize
+	return nil
+}
+
+// list returns the snapshots which can be browsed, the list is cached for a short while.
+func (d *SnapshotDir) list() (map[string]*proto.SnapshotInfo, error) {
+	d.Lock()
+	defer d.Unlock()
+	if d.snapshots != nil && time.Now().Before(d.expire) {
+		return d.snapshots, nil
+	}
+	list, err := d.super.mw.ListSnapshot()
+	if err != nil {
+		log.LogErrorf("SnapshotDir: list snapshots err(%v)", err)
+		return nil, fuse.EIO
+	}
+	snapshots := make(map[string]*proto.SnapshotInfo, len(list))
+	for _, snap := range list {
+		if snap.Status == proto.SnapshotStatusNormal {
+			snapshots[snap.Name] = snap
+		}
+	}
+	d.snapshots = snapshots
+	d.expire = time.Now().Add(SnapshotListExpiration)
+	return snapshots, nil
+}
+
+// Lookup returns the root of the snapshot, which is read from the version of the snapshot.
+func (d *SnapshotDir) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.LookupResponse) (fs.Node, error) {
+	snapshots, err := d.list()
+	if err != nil {
+		return nil, err
+	}
+	snap, ok := snapshots[req.Name]
+	if !ok {
+		return nil, fuse.ENOENT
+	}
+	info, err := d.super.mw.SnapshotInodeGet_ll(snap.Id, snap.RootInode)
+	if err != nil {
+		log.LogErrorf("SnapshotDir Lookup: snapshot(%v) err(%v)", snap, err)
+		return nil, ParseError(err)
+	}
+	resp.EntryValid = SnapshotListExpiration
+	log.LogDebugf("TRACE SnapshotDir Lookup: snapshot(%v)", snap)
+	return newVersionNode(d.super, snap.Id, info), nil
+}
+
+// ReadDirAll lists the snapshots.
+func (d *SnapshotDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	snapshots, err := d.list()
+	if err != nil {
+		return nil, err
+	}
+	dirents := make([]fuse.Dirent, 0, len(snapshots))
+	for name, snap := range snapshots {
+		dirents = append(dirents, fuse.Dirent{
+			Inode: snap.RootInode,
+			Type:  fuse.DT_Dir,
+			Name:  name,
+		})
+	}
+	return dirents, nil
+}
+
+// versionNode is an inode of the snapshot version, which is never changed. The inodes of the version are not
+// cached by the super, since their ids are the ones of the live inodes.
+type versionNode struct {
+	super   *Super
+	version uint64
+	info    *proto.InodeInfo
+}
+
+// newVersionNode returns the read-only node of the inode of the snapshot version.
+func newVersionNode(s *Super, version uint64, info *proto.InodeInfo) fs.Node {
+	node := versionNode{super: s, version: version, info: info}
+	if proto.IsDir(info.Mode) {
+		return &VersionDir{versionNode: node}
+	}
+	return &VersionFile{versionNode: node}
+}
+
+// Attr sets the attributes of the inode without the write permissions.
+func (n *versionNode) Attr(ctx context.Context, a *fuse.Attr) error {
+	fillAttr(n.info, a)
+	a.Mode &^= 0222
+	return nil
+}
+
+func (n *versionNode) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	if !n.super.enableXattr {
+		return fuse.ENOSYS
+	}
+	attrs, err := n.super.mw.SnapshotXAttrGetAll_ll(n.version, n.info.Inode)
+	if err != nil {
+		log.LogErrorf("Getxattr: version(%v) ino(%v) name(%v) err(%v)", n.version, n.info.Inode, req.Name, err)
+		return ParseError(err)
+	}
+	value, ok := attrs[req.Name]
+	if !ok {
+		return fuse.ErrNoXattr
+	}
+	resp.Xattr = []byte(value)
+	return nil
+}
+
+func (n *versionNode) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
+	if !n.super.enableXattr {
+		return fuse.ENOSYS
+	}
+	attrs, err := n.super.mw.SnapshotXAttrGetAll_ll(n.version, n.info.Inode)
+	if err != nil {
+		log.LogErrorf("Listxattr: version(%v) ino(%v) err(%v)", n.version, n.info.Inode, err)
+		return ParseError(err)
+	}
+	for key := range attrs {
+		resp.Append(key)
+	}
+	return nil
+}
+
+func (n *versionNode) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	return fuse.Errno(syscall.EROFS)
+}
+
+func (n *versionNode) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
+	return fuse.Errno(syscall.EROFS)
+}
+
+func (n *versionNode) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest) error {
+	return fuse.Errno(syscall.EROFS)
+}
+
+// VersionDir is a directory of the snapshot, which can be browsed but not modified.
+type VersionDir struct {
+	versionNode
+}
+
+// Functions that VersionDir needs to implement
+var (
+	_ fs.Node                = (*VersionDir)(nil)
+	_ fs.NodeRequestLookuper = (*VersionDir)(nil)
+	_ fs.HandleReadDirAller  = (*VersionDir)(nil)
+	_ fs.NodeGetxattrer      = (*VersionDir)(nil)
+	_ fs.NodeListxattrer     = (*VersionDir)(nil)
+	_ fs.NodeSetattrer       = (*VersionDir)(nil)
+)
+
+// Lookup returns the read-only node of the child in the version.
+func (d *VersionDir) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.LookupResponse) (fs.Node, error) {
+	ino, _, err := d.super.mw.SnapshotLookup_ll(d.version, d.info.Inode, req.Name)
+	if err != nil {
+		if err != syscall.ENOENT {
+			log.LogErrorf("VersionDir Lookup: version(%v) parent(%v) name(%v) err(%v)", d.version, d.info.Inode, req.Name, err)
+		}
+		return nil, ParseError(err)
+	}
+	info, err := d.super.mw.SnapshotInodeGet_ll(d.version, ino)
+	if err != nil {
+		log.LogErrorf("VersionDir Lookup: version(%v) ino(%v) err(%v)", d.version, ino, err)
+		return nil, ParseError(err)
+	}
+	resp.EntryValid = LookupValidDuration
+	log.LogDebugf("TRACE VersionDir Lookup: version(%v) parent(%v) name(%v) ino(%v)", d.version, d.info.Inode, req.Name, ino)
+	return newVersionNode(d.super, d.version, info), nil
+}
+
+// ReadDirAll lists the children of the directory in the version.
+func (d *VersionDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	children, err := d.super.mw.SnapshotReadDir_ll(d.version, d.info.Inode)
+	if err != nil {
+		log.LogErrorf("VersionDir ReadDirAll: version(%v) ino(%v) err(%v)", d.version, d.info.Inode, err)
+		return nil, ParseError(err)
+	}
+	dirents := make([]fuse.Dirent, 0, len(children))
+	for _, child := range children {
+		dirents = append(dirents, fuse.Dirent{
+			Inode: child.Inode,
+			Type:  ParseType(child.Type),
+			Name:  child.Name,
+		})
+	}
+	return dirents, nil
+}
+
+func (d *VersionDir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	return nil, nil, fuse.Errno(syscall.EROFS)
+}
+
+func (d *VersionDir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+	return nil, fuse.Errno(syscall.EROFS)
+}
+
+func (d *VersionDir) Mknod(ctx context.Context, req *fuse.MknodRequest) (fs.Node, error) {
+	return nil, fuse.Errno(syscall.EROFS)
+}
+
+func (d *VersionDir) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, error) {
+	return nil, fuse.Errno(syscall.EROFS)
+}
+
+func (d *VersionDir) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (fs.Node, error) {
+	return nil, fuse.Errno(syscall.EROFS)
+}
+
+func (d *VersionDir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	return fuse.Errno(syscall.EROFS)
+}
+
+func (d *VersionDir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+	return fuse.Errno(syscall.EROFS)
+}
+
+// VersionFile is a file of the snapshot, which can be read but not modified. The data is read by the extents of
+// the version, which are frozen on the data nodes, so the writes of the live file do not change it.
+type VersionFile struct {
+	versionNode
+
+	sync.Mutex
+	// the extents of the version, loaded once opened
+	extents *stream.ExtentCache
+}
+
+// Functions that VersionFile needs to implement
+var (
+	_ fs.Node           = (*VersionFile)(nil)
+	_ fs.NodeOpener     = (*VersionFile)(nil)
+	_ fs.HandleReader   = (*VersionFile)(nil)
+	_ fs.NodeReadlinker = (*VersionFile)(nil)
+	_ fs.NodeGetxattrer = (*VersionFile)(nil)
+	_ fs.NodeSetattrer  = (*VersionFile)(nil)
+)
+
+// Open opens the file for reading only.
+func (f *VersionFile) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	if !req.Flags.IsReadOnly() || req.Flags&fuse.OpenTruncate != 0 {
+		return nil, fuse.Errno(syscall.EROFS)
+	}
+	if !proto.IsHot(f.super.volType) {
+		return nil, fuse.Errno(syscall.ENOTSUP)
+	}
+	f.Lock()
+	defer f.Unlock()
+	if f.extents == nil {
+		extents := stream.NewExtentCache(f.info.Inode)
+		if err := extents.RefreshForce(f.info.Inode, f.getExtents); err != nil {
+			log.LogErrorf("VersionFile Open: version(%v) ino(%v) err(%v)", f.version, f.info.Inode, err)
+			return nil, ParseError(err)
+		}
+		f.extents = extents
+	}
+	// the data of the version is never changed
+	resp.Flags |= fuse.OpenKeepCache
+	return f, nil
+}
+
+func (f *VersionFile) getExtents(ino uint64) (gen uint64, size uint64, extents []proto.ExtentKey, shared bool, err error) {
+	gen, size, extents, err = f.super.mw.SnapshotGetExtents(f.version, ino)
+	return gen, size, extents, true, err
+}
+
+// Read reads the data of the version.
+func (f *VersionFile) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	f.Lock()
+	extents := f.extents
+	f.Unlock()
+	if extents == nil {
+		return fuse.Errno(syscall.EBADF)
+	}
+	size, err := f.super.ec.ReadVersion(ctx, extents, resp.Data[fuse.OutHeaderSize:], int(req.Offset), req.Size)
+	if err != nil && err != io.EOF {
+		log.LogErrorf("VersionFile Read: version(%v) ino(%v) req(%v) err(%v)", f.version, f.info.Inode, req, err)
+		return ParseError(err)
+	}
+	resp.Data = resp.Data[:size+fuse.OutHeaderSize]
+	return nil
+}
+
+// Readlink returns the target of the symlink in the version.
+func (f *VersionFile) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
+	return string(f.info.Target), nil
+}
+
+func (f *VersionFile) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	return fuse.Errno(syscall.EROFS)
+}
+
+func (f *VersionFile) Fallocate(ctx context.Context, req *fuse.FallocateRequest) error {
+	return fuse.Errno(syscall.EROFS)
+}
@@ -61,11 +61,20 @@ type Super struct {
 	nodeCache map[uint64]fs.Node
 	fslock    sync.Mutex
 
+	// the hidden directory in the root of the volume to browse the snapshots
+	snapshotDir *SnapshotDir
+	// the listings of the directories persisted on the local disk, nil if disabled
+	metaCache *MetaCache
+
 	disableDcache bool
 	fsyncOnClose  bool
 	enableXattr   bool
 	rootIno       uint64
 
+	enableFileLock bool
+	// the inodes which the posix locks have been acquired on, which are released on close
+	posixLocked sync.Map
+
 	state     fs.FSStatType
 	sockaddr  string
 	suspendCh chan interface{}
@@ -156,9 +165,16 @@ func NewSuper(opt *proto.MountOptions) (s *Super, err error) {
 	}
 	s.orphan = NewOrphanInodeList()
 	s.nodeCache = make(map[uint64]fs.Node)
+	s.snapshotDir = NewSnapshotDir(s)
+	if opt.MetaCacheDir != "" {
+		if s.metaCache, err = NewMetaCache(opt.MetaCacheDir, opt.Volname, s.mw); err != nil {
+			return nil, errors.Trace(err, "NewMetaCache failed!")
+		}
+	}
 	s.disableDcache = opt.DisableDcache
 	s.fsyncOnClose = opt.FsyncOnClose
 	s.enableXattr = opt.EnableXattr
+	s.enableFileLock = opt.EnableFileLock
 	s.bcacheCheckInterval = opt.BcacheCheckIntervalS
 	s.bcacheFilterFiles = opt.BcacheFilterFiles
 	s.bcacheBatchCnt = opt.BcacheBatchCnt
@@ -223,8 +239,9 @@ func NewSuper(opt *proto.MountOptions) (s *Super, err error) {
 		BcacheDir:         opt.BcacheDir,
 		MaxStreamerLimit:  opt.MaxStreamerLimit,
 		OnAppendExtentKey: s.mw.AppendExtentKey,
-		OnGetExtents:      s.mw.GetExtents,
+		OnGetExtents:      s.mw.GetExtentsWithShared,
 		OnTruncate:        s.mw.Truncate,
+		OnPunchHole:       s.mw.PunchHole,
 		OnEvictIcache:     s.ic.Delete,
 		OnLoadBcache:      s.bc.Get,
 		OnCacheBcache:     s.bc.Put,
@@ -714,6 +731,7 @@ func getDelInodes(src []uint64, act []*proto.InodeInfo) []uint64 {
 
 func (s *Super) Close() {
 	close(s.closeC)
+	s.metaCache.Close()
 }
 
 func (s *Super) SetTransaction(txMaskStr string, timeout int64, retryNum int64, retryInterval int64) {
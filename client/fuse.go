@@ -57,6 +57,7 @@ import (
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
 	"github.com/cubefs/cubefs/util/stat"
+	"github.com/cubefs/cubefs/util/trace"
 	"github.com/cubefs/cubefs/util/ump"
 	"github.com/jacobsa/daemonize"
 	_ "go.uber.org/automaxprocs"
@@ -447,6 +448,10 @@ func main() {
 	syslog.Printf("enable bcache %v", opt.EnableBcache)
 	exporter.Init(ModuleName, cfg)
 	exporter.RegistConsul(super.ClusterName(), ModuleName, cfg)
+	if err = trace.Init(ModuleName, cfg); err != nil {
+		syslog.Printf("init trace failed: %v", err)
+	}
+	defer trace.Close()
 
 	err = log.OutputPid(opt.Logpath, ModuleName)
 	if err != nil {
@@ -667,6 +672,14 @@ func mount(opt *proto.MountOptions) (fsConn *fuse.Conn, super *cfs.Super, err er
 		options = append(options, fuse.DefaultPermissions())
 	}
 
+	if opt.EnableFileLock {
+		options = append(options, fuse.LockingFlock(), fuse.LockingPOSIX())
+	}
+
+	if opt.EnableReaddirPlus {
+		options = append(options, fuse.ReaddirPlus())
+	}
+
 	fsConn, err = fuse.Mount(opt.MountPoint, opt.NeedRestoreFuse, options...)
 	return
 }
@@ -736,6 +749,9 @@ func parseMountOption(cfg *config.Config) (*proto.MountOptions, error) {
 	opt.EnableXattr = GlobalMountOptions[proto.EnableXattr].GetBool()
 	opt.NearRead = GlobalMountOptions[proto.NearRead].GetBool()
 	opt.EnablePosixACL = GlobalMountOptions[proto.EnablePosixACL].GetBool()
+	opt.EnableFileLock = GlobalMountOptions[proto.EnableFileLock].GetBool()
+	opt.EnableReaddirPlus = GlobalMountOptions[proto.EnableReaddirPlus].GetBool()
+	opt.MetaCacheDir = GlobalMountOptions[proto.MetaCacheDir].GetString()
 	opt.EnableSummary = GlobalMountOptions[proto.EnableSummary].GetBool()
 	opt.EnableUnixPermission = GlobalMountOptions[proto.EnableUnixPermission].GetBool()
 	opt.ReadThreads = GlobalMountOptions[proto.ReadThreads].GetInt64()
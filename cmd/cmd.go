@@ -44,6 +44,7 @@ import (
 	"github.com/cubefs/cubefs/datanode"
 	"github.com/cubefs/cubefs/master"
 	"github.com/cubefs/cubefs/metanode"
+	"github.com/cubefs/cubefs/metanode/rocksdbstore"
 	"github.com/cubefs/cubefs/util/config"
 	"github.com/cubefs/cubefs/util/log"
 	"github.com/cubefs/cubefs/util/ump"
@@ -174,6 +175,7 @@ func main() {
 	)
 	switch role {
 	case RoleMeta:
+		metanode.RegisterKVStore(rocksdbstore.Open)
 		server = metanode.NewServer()
 		module = ModuleMeta
 	case RoleMaster:
@@ -71,6 +71,7 @@ type DataPartitionMetadata struct {
 	DataPartitionCreateType int
 	LastTruncateID          uint64
 	ReplicaNum              int
+	Frozen                  *proto.FrozenExtents `json:",omitempty"`
 }
 
 type sortedPeers []proto.Peer
@@ -136,6 +137,7 @@ type DataPartition struct {
 	DataPartitionCreateType       int
 	isLoadingDataPartition        bool
 	persistMetaMutex              sync.RWMutex
+	frozen                        *frozenExtents
 }
 
 func CreateDataPartition(dpCfg *dataPartitionCfg, disk *Disk, request *proto.CreateDataPartitionRequest) (dp *DataPartition, err error) {
@@ -242,6 +244,7 @@ func LoadDataPartition(partitionDir string, disk *Disk) (dp *DataPartition, err
 	log.LogInfof("Action(LoadDataPartition) PartitionID(%v) meta(%v)", dp.partitionID, meta)
 	dp.DataPartitionCreateType = meta.DataPartitionCreateType
 	dp.lastTruncateID = meta.LastTruncateID
+	dp.frozen.merge(meta.Frozen)
 	if meta.DataPartitionCreateType == proto.NormalCreateDataPartition {
 		err = dp.StartRaft(true)
 	} else {
@@ -293,6 +296,7 @@ func newDataPartition(dpCfg *dataPartitionCfg, disk *Disk, isCreate bool) (dp *D
 		partitionStatus: proto.ReadWrite,
 		config:          dpCfg,
 		raftStatus:      RaftStatusStopped,
+		frozen:          newFrozenExtents(),
 	}
 	log.LogInfof("action[newDataPartition] dp %v replica num %v isCreate %v", partitionID, dpCfg.ReplicaNum, isCreate)
 	partition.replicasInit()
@@ -518,6 +522,7 @@ func (dp *DataPartition) PersistMetadata() (err error) {
 		DataPartitionCreateType: dp.DataPartitionCreateType,
 		CreateTime:              time.Now().Format(TimeLayout),
 		LastTruncateID:          dp.lastTruncateID,
+		Frozen:                  dp.frozen.state(),
 	}
 	if metaData, err = json.Marshal(md); err != nil {
 		return
@@ -0,0 +1,204 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/repl"
+	"github.com/cubefs/cubefs/storage"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// frozenExtents is the extents of the partition which are not modified in place, see proto.FreezeExtentsRequest.
+// The random writes hold the read lock until they are applied, so that the writes in flight are done before
+// the extents are frozen.
+type frozenExtents struct {
+	sync.RWMutex
+	below   uint64
+	extents map[uint64]struct{}
+	tiny    map[uint64]int64
+}
+
+func newFrozenExtents() *frozenExtents {
+	return &frozenExtents{
+		extents: make(map[uint64]struct{}),
+		tiny:    make(map[uint64]int64),
+	}
+}
+
+// checkLocked returns ExtentFrozenError if writing the extent at the offset modifies the frozen data.
+// The tiny extents are appended by the data node at the end, which is never frozen.
+func (f *frozenExtents) checkLocked(extentID uint64, offset int64, overwrite bool) error {
+	if storage.IsTinyExtent(extentID) {
+		if overwrite && offset < f.tiny[extentID] {
+			return storage.ExtentFrozenError
+		}
+		return nil
+	}
+	if _, ok := f.extents[extentID]; ok || extentID < f.below {
+		return storage.ExtentFrozenError
+	}
+	return nil
+}
+
+func (f *frozenExtents) check(extentID uint64, offset int64, overwrite bool) error {
+	f.RLock()
+	defer f.RUnlock()
+	return f.checkLocked(extentID, offset, overwrite)
+}
+
+// forget drops the normal extent deleted, whose id is never reused.
+func (f *frozenExtents) forget(extentID uint64) {
+	if storage.IsTinyExtent(extentID) {
+		return
+	}
+	f.Lock()
+	delete(f.extents, extentID)
+	f.Unlock()
+}
+
+// merge freezes the extents frozen in the state as well.
+func (f *frozenExtents) merge(state *proto.FrozenExtents) {
+	if state == nil {
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	if state.Below > f.below {
+		f.below = state.Below
+	}
+	for _, extentID := range state.Extents {
+		f.extents[extentID] = struct{}{}
+	}
+	for extentID, offset := range state.Tiny {
+		if offset > f.tiny[extentID] {
+			f.tiny[extentID] = offset
+		}
+	}
+}
+
+func (f *frozenExtents) state() *proto.FrozenExtents {
+	f.RLock()
+	defer f.RUnlock()
+	state := &proto.FrozenExtents{
+		Below:   f.below,
+		Extents: make([]uint64, 0, len(f.extents)),
+		Tiny:    make(map[uint64]int64, len(f.tiny)),
+	}
+	for extentID := range f.extents {
+		state.Extents = append(state.Extents, extentID)
+	}
+	sort.Slice(state.Extents, func(i, j int) bool {
+		return state.Extents[i] < state.Extents[j]
+	})
+	for extentID, offset := range f.tiny {
+		state.Tiny[extentID] = offset
+	}
+	return state
+}
+
+// freezeTinyLocked freezes the data written to the tiny extent so far.
+func (f *frozenExtents) freezeTinyLocked(store *storage.ExtentStore, extentID uint64) {
+	offset, err := store.GetTinyExtentOffset(extentID)
+	if err != nil {
+		log.LogWarnf("freezeTinyLocked: extent(%v) err(%v)", extentID, err)
+		return
+	}
+	if offset > f.tiny[extentID] {
+		f.tiny[extentID] = offset
+	}
+}
+
+// FreezeExtents freezes the extents of the request, and returns the extents frozen in the partition.
+func (dp *DataPartition) FreezeExtents(req *proto.FreezeExtentsRequest) (state *proto.FrozenExtents, err error) {
+	if !req.All && len(req.Extents) == 0 {
+		return dp.frozen.state(), nil
+	}
+	store := dp.ExtentStore()
+	dp.frozen.Lock()
+	if req.All {
+		maxExtentID, _ := store.GetMaxExtentIDAndPartitionSize()
+		if maxExtentID+1 > dp.frozen.below {
+			dp.frozen.below = maxExtentID + 1
+		}
+		for extentID := uint64(storage.TinyExtentStartID); extentID < storage.TinyExtentStartID+storage.TinyExtentCount; extentID++ {
+			dp.frozen.freezeTinyLocked(store, extentID)
+		}
+	}
+	for _, extentID := range req.Extents {
+		if storage.IsTinyExtent(extentID) {
+			dp.frozen.freezeTinyLocked(store, extentID)
+		} else {
+			dp.frozen.extents[extentID] = struct{}{}
+		}
+	}
+	dp.frozen.Unlock()
+	if err = dp.PersistMetadata(); err != nil {
+		return
+	}
+	log.LogInfof("FreezeExtents: partition(%v) all(%v) extents(%v)", dp.partitionID, req.All, len(req.Extents))
+	return dp.frozen.state(), nil
+}
+
+// syncFrozenExtents freezes the extents frozen in the primary, e.g. the partition is a new replica.
+func (dp *DataPartition) syncFrozenExtents() (err error) {
+	var conn *net.TCPConn
+	p := NewPacketToFreezeExtents(dp.partitionID, &proto.FreezeExtentsRequest{})
+	target := dp.getReplicaAddr(0)
+	if conn, err = gConnPool.GetConnect(target); err != nil {
+		return errors.Trace(err, "partition(%v) get host(%v) connect", dp.partitionID, target)
+	}
+	defer func() {
+		gConnPool.PutConnect(conn, err != nil)
+	}()
+	if err = p.WriteToConn(conn); err != nil {
+		return errors.Trace(err, "partition(%v) write to host(%v)", dp.partitionID, target)
+	}
+	if err = p.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+		return errors.Trace(err, "partition(%v) read from host(%v)", dp.partitionID, target)
+	}
+	if p.ResultCode == proto.OpArgMismatchErr {
+		// the primary is not upgraded yet, nothing is frozen
+		log.LogWarnf("syncFrozenExtents: partition(%v) host(%v) does not freeze extents", dp.partitionID, target)
+		return nil
+	}
+	if p.ResultCode != proto.OpOk {
+		return errors.NewErrorf("partition(%v) result code not ok (%v) from host(%v)", dp.partitionID, p.ResultCode, target)
+	}
+	state := new(proto.FrozenExtents)
+	if err = json.Unmarshal(p.Data[:p.Size], state); err != nil {
+		return
+	}
+	dp.frozen.merge(state)
+	return dp.PersistMetadata()
+}
+
+func NewPacketToFreezeExtents(partitionID uint64, req *proto.FreezeExtentsRequest) (p *repl.Packet) {
+	p = new(repl.Packet)
+	p.Opcode = proto.OpFreezeExtents
+	p.PartitionID = partitionID
+	p.ExtentType = proto.NormalExtentType
+	p.Magic = proto.ProtoMagic
+	p.ReqID = proto.GenerateRequestID()
+	p.Data, _ = json.Marshal(req)
+	p.Size = uint32(len(p.Data))
+	return
+}
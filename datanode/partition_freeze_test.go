@@ -0,0 +1,106 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package datanode
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrozenExtents(t *testing.T) {
+	f := newFrozenExtents()
+	require.NoError(t, f.check(1025, 0, true))
+
+	f.merge(&proto.FrozenExtents{Below: 1030, Extents: []uint64{2000}, Tiny: map[uint64]int64{1: 8192}})
+	// the normal extents frozen are neither overwritten nor appended
+	require.Equal(t, storage.ExtentFrozenError, f.check(1025, 0, true))
+	require.Equal(t, storage.ExtentFrozenError, f.check(1029, 4096, false))
+	require.Equal(t, storage.ExtentFrozenError, f.check(2000, 0, false))
+	require.NoError(t, f.check(1030, 0, true))
+	// the tiny extents are frozen below the offsets
+	require.Equal(t, storage.ExtentFrozenError, f.check(1, 4096, true))
+	require.NoError(t, f.check(1, 8192, true))
+	require.NoError(t, f.check(1, 4096, false))
+	require.NoError(t, f.check(2, 0, true))
+
+	// the state frozen never goes back
+	f.merge(&proto.FrozenExtents{Below: 1026, Tiny: map[uint64]int64{1: 4096}})
+	state := f.state()
+	require.Equal(t, uint64(1030), state.Below)
+	require.Equal(t, []uint64{2000}, state.Extents)
+	require.Equal(t, int64(8192), state.Tiny[1])
+
+	f.forget(2000)
+	f.forget(1)
+	require.NoError(t, f.check(2000, 0, true))
+	require.Equal(t, storage.ExtentFrozenError, f.check(1, 0, true))
+}
+
+func TestFreezeAllExtents(t *testing.T) {
+	dir, err := os.MkdirTemp("", "testFreezeAllExtents")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store, err := storage.NewExtentStore(dir, 1, 1<<30, proto.PartitionTypeNormal, true)
+	require.NoError(t, err)
+	defer store.Close()
+	dp := &DataPartition{
+		partitionID: 1,
+		path:        dir,
+		config:      &dataPartitionCfg{VolName: "vol", PartitionID: 1},
+		extentStore: store,
+		frozen:      newFrozenExtents(),
+	}
+
+	// the extent of the file open, which is written before the snapshot is taken
+	data := []byte("data written before the snapshot")
+	crc := crc32.ChecksumIEEE(data)
+	extentID, err := store.NextExtentID()
+	require.NoError(t, err)
+	require.NoError(t, store.Create(extentID))
+	require.NoError(t, store.Write(extentID, 0, int64(len(data)), data, crc, storage.AppendWriteType, true))
+	tinyID := uint64(storage.TinyExtentStartID)
+	require.NoError(t, store.Write(tinyID, 0, int64(len(data)), data, crc, storage.AppendWriteType, true))
+	tinyOffset, err := store.GetTinyExtentOffset(tinyID)
+	require.NoError(t, err)
+
+	state, err := dp.FreezeExtents(&proto.FreezeExtentsRequest{All: true})
+	require.NoError(t, err)
+	require.Equal(t, extentID+1, state.Below)
+	require.Equal(t, tinyOffset, state.Tiny[tinyID])
+
+	// the writes of the file already open are neither applied in place nor appended to the extent,
+	// the client writes them to the new extents instead
+	require.Equal(t, storage.ExtentFrozenError, dp.frozen.check(extentID, 0, true))
+	require.Equal(t, storage.ExtentFrozenError, dp.frozen.check(extentID, int64(len(data)), false))
+	require.Equal(t, storage.ExtentFrozenError, dp.frozen.check(tinyID, 0, true))
+	require.NoError(t, dp.frozen.check(tinyID, tinyOffset, false))
+	newExtentID, err := store.NextExtentID()
+	require.NoError(t, err)
+	require.NoError(t, dp.frozen.check(newExtentID, 0, true))
+
+	// the state frozen is persisted with the partition
+	data, err = os.ReadFile(path.Join(dir, DataPartitionMetadataFileName))
+	require.NoError(t, err)
+	meta := &DataPartitionMetadata{}
+	require.NoError(t, json.Unmarshal(data, meta))
+	require.Equal(t, state, meta.Frozen)
+}
@@ -332,6 +332,13 @@ func (dp *DataPartition) StartRaftAfterRepair(isLoad bool) {
 				continue
 			}
 
+			// the new replica must not accept overwriting the frozen extents once it is the leader
+			if err = dp.syncFrozenExtents(); err != nil {
+				log.LogErrorf("PartitionID(%v) sync frozen extents err(%v)", dp.partitionID, err)
+				timer.Reset(5 * time.Second)
+				continue
+			}
+
 			// start raft
 			dp.DataPartitionCreateType = proto.NormalCreateDataPartition
 			dp.PersistMetadata()
@@ -41,6 +41,7 @@ import (
 	"github.com/cubefs/cubefs/util/config"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/trace"
 
 	"github.com/xtaci/smux"
 )
@@ -189,6 +190,9 @@ func doStart(server common.Server, cfg *config.Config) (err error) {
 	}
 
 	exporter.Init(ModuleName, cfg)
+	if err = trace.Init(ModuleName, cfg); err != nil {
+		return
+	}
 	s.registerMetrics()
 	s.register(cfg)
 
@@ -253,6 +257,7 @@ func doShutdown(server common.Server) {
 	s.stopSmuxService()
 	s.closeSmuxConnPool()
 	MasterClient.Stop()
+	trace.Close()
 }
 
 func (s *DataNode) parseConfig(cfg *config.Config) (err error) {
@@ -169,6 +169,8 @@ func (s *DataNode) OperatePacket(p *repl.Packet, c net.Conn) (err error) {
 		s.handlePacketToReadTinyDeleteRecordFile(p, c)
 	case proto.OpBroadcastMinAppliedID:
 		s.handleBroadcastMinAppliedID(p)
+	case proto.OpFreezeExtents:
+		s.handlePacketToFreezeExtents(p)
 	default:
 		p.PackErrorBody(repl.ErrorUnknownOp.Error(), repl.ErrorUnknownOp.Error()+strconv.Itoa(int(p.Opcode)))
 	}
@@ -437,6 +439,7 @@ func (s *DataNode) handleMarkDeletePacket(p *repl.Packet, c net.Conn) {
 			p.PartitionID, p.ExtentID)
 		partition.disk.allocCheckLimit(proto.IopsWriteType, 1)
 		partition.ExtentStore().MarkDelete(p.ExtentID, 0, 0)
+		partition.frozen.forget(p.ExtentID)
 	}
 
 	return
@@ -465,6 +468,7 @@ func (s *DataNode) handleBatchMarkDeletePacket(p *repl.Packet, c net.Conn) {
 				log.LogInfof(fmt.Sprintf("recive DeleteExtent (%v) from (%v)", ext, c.RemoteAddr().String()))
 				partition.disk.allocCheckLimit(proto.IopsWriteType, 1)
 				store.MarkDelete(ext.ExtentId, int64(ext.ExtentOffset), int64(ext.Size))
+				partition.frozen.forget(ext.ExtentId)
 			} else {
 				log.LogInfof("delete limiter reach(%v), remote (%v) try again.", deleteLimiteRater.Limit(), c.RemoteAddr().String())
 				err = storage.TryAgainError
@@ -490,6 +494,11 @@ func (s *DataNode) handleWritePacket(p *repl.Packet) {
 			p.PacketOkReply()
 		}
 	}()
+	span := p.Span.StartChild("datanode.ExtentWrite")
+	defer func() {
+		span.SetError(err)
+		span.Finish()
+	}()
 	partition := p.Object.(*DataPartition)
 	shallDegrade := p.ShallDegrade()
 	if !shallDegrade {
@@ -502,6 +511,10 @@ func (s *DataNode) handleWritePacket(p *repl.Packet) {
 		err = storage.BrokenDiskError
 		return
 	}
+	// the clients append to new extents instead of the frozen ones
+	if err = partition.frozen.check(p.ExtentID, p.ExtentOffset, false); err != nil {
+		return
+	}
 	store := partition.ExtentStore()
 	if p.ExtentType == proto.TinyExtentType {
 		if !shallDegrade {
@@ -586,12 +599,22 @@ func (s *DataNode) handleRandomWritePacket(p *repl.Packet) {
 		err = raft.ErrNotLeader
 		return
 	}
+	// the clients write the data overwriting the frozen extents to new extents instead,
+	// the extents are not frozen until the writes in flight are applied
+	partition.frozen.RLock()
+	defer partition.frozen.RUnlock()
+	if err = partition.frozen.checkLocked(p.ExtentID, p.ExtentOffset, true); err != nil {
+		return
+	}
 	shallDegrade := p.ShallDegrade()
 	if !shallDegrade {
 		metricPartitionIOLabels = GetIoMetricLabels(partition, "randwrite")
 		partitionIOMetric = exporter.NewTPCnt(MetricPartitionIOName)
 	}
+	span := p.Span.StartChild("datanode.RaftSubmit")
 	err = partition.RandomWriteSubmit(p)
+	span.SetError(err)
+	span.Finish()
 	if !shallDegrade {
 		s.metrics.MetricIOBytes.AddWithLabels(int64(p.Size), metricPartitionIOLabels)
 		partitionIOMetric.SetWithLabels(err, metricPartitionIOLabels)
@@ -608,6 +631,32 @@ func (s *DataNode) handleRandomWritePacket(p *repl.Packet) {
 
 }
 
+// Handle OpFreezeExtents packet.
+func (s *DataNode) handlePacketToFreezeExtents(p *repl.Packet) {
+	var (
+		err   error
+		state *proto.FrozenExtents
+		data  []byte
+	)
+	defer func() {
+		if err != nil {
+			p.PackErrorBody(ActionFreezeExtents, err.Error())
+		}
+	}()
+	partition := p.Object.(*DataPartition)
+	req := new(proto.FreezeExtentsRequest)
+	if err = json.Unmarshal(p.Data[:p.Size], req); err != nil {
+		return
+	}
+	if state, err = partition.FreezeExtents(req); err != nil {
+		return
+	}
+	if data, err = json.Marshal(state); err != nil {
+		return
+	}
+	p.PacketOkWithBody(data)
+}
+
 func (s *DataNode) handleStreamReadPacket(p *repl.Packet, connect net.Conn, isRepairRead bool) {
 	var (
 		err error
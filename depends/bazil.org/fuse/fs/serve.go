@@ -293,11 +293,32 @@ func nodeAttr(ctx context.Context, n Node, attr *fuse.Attr) error {
 // Other FUSE requests can be handled by implementing methods from the
 // Handle* interfaces. The most common to implement are HandleReader,
 // HandleReadDirer, and HandleWriter.
-//
-// TODO implement methods: Getlk, Setlk, Setlkw
 type Handle interface {
 }
 
+// HandleLocker handles the flock(2) and fcntl(2) locks, which requires the
+// LockingFlock or LockingPOSIX mount option. Otherwise the locks are handled
+// locally by the kernel.
+//
+// The flock(2) locks are distinguished by fuse.LockFlock in the LockFlags,
+// which always cover the whole file.
+type HandleLocker interface {
+	// Lock tries to acquire a lock, and returns syscall.EAGAIN if a
+	// conflicting lock is held.
+	Lock(ctx context.Context, req *fuse.LockRequest) error
+
+	// LockWait acquires a lock, waiting until the conflicting locks are
+	// released. ctx is canceled if the request is interrupted.
+	LockWait(ctx context.Context, req *fuse.LockWaitRequest) error
+
+	// Unlock releases the locks of the owner in the range.
+	Unlock(ctx context.Context, req *fuse.UnlockRequest) error
+
+	// QueryLock returns a lock conflicting with req.Lock in resp.Lock,
+	// leaving resp.Lock.Type as fuse.LockUnlock if there is none.
+	QueryLock(ctx context.Context, req *fuse.QueryLockRequest, resp *fuse.QueryLockResponse) error
+}
+
 type HandleFlusher interface {
 	// Flush is called each time the file or directory is closed.
 	// Because there can be multiple file descriptors referring to a
@@ -305,6 +326,23 @@ type HandleFlusher interface {
 	Flush(ctx context.Context, req *fuse.FlushRequest) error
 }
 
+type HandleFallocater interface {
+	// Fallocate preallocates, punches or zeroes the range of the file
+	// as fallocate(2) does.
+	Fallocate(ctx context.Context, req *fuse.FallocateRequest) error
+}
+
+type HandleCopyFileRanger interface {
+	// CopyFileRange copies the range of the file into the file of
+	// the handle dst as copy_file_range(2) does.
+	CopyFileRange(ctx context.Context, req *fuse.CopyFileRangeRequest, dst Handle, resp *fuse.CopyFileRangeResponse) error
+}
+
+type HandleIoctler interface {
+	// Ioctl runs the ioctl(2) command on the file.
+	Ioctl(ctx context.Context, req *fuse.IoctlRequest, resp *fuse.IoctlResponse) error
+}
+
 type HandleReadAller interface {
 	ReadAll(ctx context.Context) ([]byte, error)
 }
@@ -317,6 +355,31 @@ type HandleReadDirAller interface {
 	ReadDirAll(ctx context.Context) ([]fuse.Dirent, error)
 }
 
+// A DirentPlus is a directory entry along with the node it names,
+// which is looked up by the kernel as Lookup does.
+type DirentPlus struct {
+	fuse.Dirent
+
+	// Node is the node of the entry, the entry is returned without
+	// being looked up if Node is nil.
+	Node Node
+
+	// EntryValid is the cache timeout for the name, the default is
+	// used if it is zero.
+	EntryValid time.Duration
+}
+
+type HandleReadDirPlusser interface {
+	// ReadDirPlus returns the entries of the directory along with
+	// their nodes, so that the kernel does not need to look them up.
+	// The req.Offset is the number of the entries returned before,
+	// and io.EOF is returned with the last entries.
+	//
+	// The handles not implementing it are read as HandleReadDirer or
+	// HandleReadDirAller, and the entries are returned without nodes.
+	ReadDirPlus(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) ([]DirentPlus, error)
+}
+
 type HandleReader interface {
 	// Read requests to read data from the handle.
 	//
@@ -997,6 +1060,12 @@ type serveHandle struct {
 	handle   Handle
 	readData []byte
 	nodeID   fuse.NodeID
+
+	// the entries read by readdirplus, the offset of an entry is its index + 1
+	// readPlus buffers the entries read but not served yet, the first of which is at readPlusOffset
+	readPlus       []DirentPlus
+	readPlusOffset int64
+	readPlusEOF    bool
 }
 
 // NodeRef is deprecated. It remains here to decrease code churn on
@@ -1819,7 +1888,11 @@ func (c *Server) handleRequest(ctx context.Context, node Node, snode *serveNode,
 		}
 		handle := shandle.handle
 		s := &fuse.ReadResponse{}
-		if r.Dir {
+		if r.Dir && r.Plus {
+			if err := c.readDirPlus(ctx, snode, shandle, r, s); err != nil {
+				return err
+			}
+		} else if r.Dir {
 			s.Data = make([]byte, r.Size)
 
 			// detect rewinddir(3) or similar seek and refresh
@@ -1929,6 +2002,129 @@ func (c *Server) handleRequest(ctx context.Context, node Node, snode *serveNode,
 		r.Respond()
 		return nil
 
+	case *fuse.FallocateRequest:
+		shandle := c.getHandle(r.Handle)
+		if shandle == nil {
+			return fuse.ESTALE
+		}
+		h, ok := shandle.handle.(HandleFallocater)
+		if !ok {
+			return fuse.ENOSYS
+		}
+		if err := h.Fallocate(ctx, r); err != nil {
+			return err
+		}
+		done(nil)
+		r.Respond()
+		return nil
+
+	case *fuse.CopyFileRangeRequest:
+		shandle := c.getHandle(r.Handle)
+		if shandle == nil {
+			return fuse.ESTALE
+		}
+		dhandle := c.getHandle(r.HandleOut)
+		if dhandle == nil {
+			return fuse.ESTALE
+		}
+		h, ok := shandle.handle.(HandleCopyFileRanger)
+		if !ok {
+			return fuse.ENOSYS
+		}
+		s := &fuse.CopyFileRangeResponse{}
+		if err := h.CopyFileRange(ctx, r, dhandle.handle, s); err != nil {
+			return err
+		}
+		done(s)
+		r.Respond(s)
+		return nil
+
+	case *fuse.IoctlRequest:
+		shandle := c.getHandle(r.Handle)
+		if shandle == nil {
+			return fuse.ESTALE
+		}
+		h, ok := shandle.handle.(HandleIoctler)
+		if !ok {
+			return fuse.ENOTTY
+		}
+		s := &fuse.IoctlResponse{}
+		if err := h.Ioctl(ctx, r, s); err != nil {
+			return err
+		}
+		done(s)
+		r.Respond(s)
+		return nil
+
+	case *fuse.LockRequest:
+		shandle := c.getHandle(r.Handle)
+		if shandle == nil {
+			return fuse.ESTALE
+		}
+		h, ok := shandle.handle.(HandleLocker)
+		if !ok {
+			return fuse.ENOSYS
+		}
+		if err := h.Lock(ctx, r); err != nil {
+			return err
+		}
+		done(nil)
+		r.Respond()
+		return nil
+
+	case *fuse.LockWaitRequest:
+		shandle := c.getHandle(r.Handle)
+		if shandle == nil {
+			return fuse.ESTALE
+		}
+		h, ok := shandle.handle.(HandleLocker)
+		if !ok {
+			return fuse.ENOSYS
+		}
+		if err := h.LockWait(ctx, r); err != nil {
+			return err
+		}
+		done(nil)
+		r.Respond()
+		return nil
+
+	case *fuse.UnlockRequest:
+		shandle := c.getHandle(r.Handle)
+		if shandle == nil {
+			return fuse.ESTALE
+		}
+		h, ok := shandle.handle.(HandleLocker)
+		if !ok {
+			return fuse.ENOSYS
+		}
+		if err := h.Unlock(ctx, r); err != nil {
+			return err
+		}
+		done(nil)
+		r.Respond()
+		return nil
+
+	case *fuse.QueryLockRequest:
+		shandle := c.getHandle(r.Handle)
+		if shandle == nil {
+			return fuse.ESTALE
+		}
+		h, ok := shandle.handle.(HandleLocker)
+		if !ok {
+			return fuse.ENOSYS
+		}
+		resp := &fuse.QueryLockResponse{
+			Lock: fuse.FileLock{
+				Type: fuse.LockUnlock,
+			},
+		}
+		if err := h.QueryLock(ctx, r, resp); err != nil {
+			return err
+		}
+		done(resp)
+		r.Respond(resp)
+		return nil
+
 	case *fuse.ReleaseRequest:
 		shandle := c.getHandle(r.Handle)
 		if shandle == nil {
@@ -2042,6 +2238,106 @@ func (c *Server) handleRequest(ctx context.Context, node Node, snode *serveNode,
 	panic("not reached")
 }
 
+// readDirPlus responds to the readdirplus request with the entries which fit in the buffer.
+// The entries are read page by page as the offset goes, and dropped once served, so that
+// only a page is buffered however large the directory is. The nodes are saved only for the
+// entries responded, as the kernel looks up all of them.
+func (c *Server) readDirPlus(ctx context.Context, snode *serveNode, shandle *serveHandle, r *fuse.ReadRequest, s *fuse.ReadResponse) error {
+	// detect rewinddir(3) or similar seek and refresh contents,
+	// the entries dropped are read again from the start
+	if r.Offset == 0 || r.Offset < shandle.readPlusOffset {
+		shandle.readPlus = nil
+		shandle.readPlusOffset = 0
+		shandle.readPlusEOF = false
+	}
+
+	for {
+		if skip := r.Offset - shandle.readPlusOffset; skip > 0 {
+			if skip > int64(len(shandle.readPlus)) {
+				skip = int64(len(shandle.readPlus))
+			}
+			shandle.readPlus = shandle.readPlus[skip:]
+			shandle.readPlusOffset += skip
+		}
+		if len(shandle.readPlus) > 0 || shandle.readPlusEOF {
+			break
+		}
+		dirs, err := c.readDirPlusEntries(ctx, shandle, r, s)
+		if err == io.EOF {
+			shandle.readPlusEOF = true
+		} else if err != nil {
+			return err
+		}
+		if len(dirs) == 0 {
+			shandle.readPlusEOF = true
+		}
+		shandle.readPlus = append(shandle.readPlus, dirs...)
+	}
+
+	s.Data = make([]byte, 0, r.Size)
+	served := 0
+	for ; served < len(shandle.readPlus); served++ {
+		dir := shandle.readPlus[served]
+		if dir.Inode == 0 {
+			dir.Inode = c.dynamicInode(snode.inode, dir.Name)
+		}
+		if len(s.Data)+fuse.DirentPlusSize(dir.Name) > r.Size {
+			break
+		}
+		entry := fuse.DirentPlus{Dirent: dir.Dirent}
+		if dir.Node != nil && dir.Name != "." && dir.Name != ".." {
+			initLookupResponse(&entry.Entry)
+			if dir.EntryValid != 0 {
+				entry.Entry.EntryValid = dir.EntryValid
+			}
+			if err := c.saveLookup(ctx, &entry.Entry, snode, dir.Name, dir.Node); err != nil {
+				// responded without being looked up
+				entry.Entry = fuse.LookupResponse{}
+			}
+		}
+		s.Data = fuse.AppendDirentPlus(s.Data, entry, uint64(shandle.readPlusOffset)+uint64(served)+1, r.Header.Conn.Protocol())
+	}
+	// drop the entries served, the page is released once all of them are
+	shandle.readPlus = shandle.readPlus[served:]
+	shandle.readPlusOffset += int64(served)
+	if len(shandle.readPlus) == 0 {
+		shandle.readPlus = nil
+	}
+	return nil
+}
+
+// readDirPlusEntries reads the next entries of the directory, the handles not implementing
+// HandleReadDirPlusser return the entries without nodes.
+func (c *Server) readDirPlusEntries(ctx context.Context, shandle *serveHandle, r *fuse.ReadRequest, s *fuse.ReadResponse) ([]DirentPlus, error) {
+	req := *r
+	req.Offset = shandle.readPlusOffset + int64(len(shandle.readPlus))
+	switch h := shandle.handle.(type) {
+	case HandleReadDirPlusser:
+		return h.ReadDirPlus(ctx, &req, s)
+	case HandleReadDirer:
+		dirs, err := h.ReadDir(ctx, &req, s)
+		return direntsPlus(dirs), err
+	case HandleReadDirAller:
+		if req.Offset != 0 {
+			return nil, io.EOF
+		}
+		dirs, err := h.ReadDirAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return direntsPlus(dirs), io.EOF
+	}
+	return nil, io.EOF
+}
+
+func direntsPlus(dirs []fuse.Dirent) []DirentPlus {
+	plus := make([]DirentPlus, 0, len(dirs))
+	for _, dir := range dirs {
+		plus = append(plus, DirentPlus{Dirent: dir})
+	}
+	return plus
+}
+
 func (c *Server) saveLookup(ctx context.Context, s *fuse.LookupResponse, snode *serveNode, elem string, n2 Node) error {
 	if err := nodeAttr(ctx, n2, &s.Attr); err != nil {
 		return err
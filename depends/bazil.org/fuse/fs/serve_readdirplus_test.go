@@ -0,0 +1,140 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/cubefs/cubefs/depends/bazil.org/fuse"
+	"golang.org/x/net/context"
+)
+
+const readDirPlusTestPage = 4
+
+type readDirPlusTestNode struct {
+	inode uint64
+}
+
+func (n *readDirPlusTestNode) Attr(ctx context.Context, a *fuse.Attr) error {
+	a.Inode = n.inode
+	return nil
+}
+
+// readDirPlusTestDir returns the entries page by page from the offset.
+type readDirPlusTestDir struct {
+	entries []DirentPlus
+	offsets []int64
+}
+
+func (d *readDirPlusTestDir) ReadDirPlus(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) ([]DirentPlus, error) {
+	d.offsets = append(d.offsets, req.Offset)
+	end := req.Offset + readDirPlusTestPage
+	if end >= int64(len(d.entries)) {
+		return d.entries[req.Offset:], io.EOF
+	}
+	return d.entries[req.Offset:end], nil
+}
+
+type readDirPlusTestEntry struct {
+	name string
+	node uint64
+	off  uint64
+}
+
+func parseDirentPlus(data []byte) (entries []readDirPlusTestEntry) {
+	entrySize := fuse.DirentPlusSize("") - 24
+	for len(data) > 0 {
+		dirent := data[entrySize:]
+		namelen := binary.LittleEndian.Uint32(dirent[16:])
+		name := string(dirent[24 : 24+namelen])
+		entries = append(entries, readDirPlusTestEntry{
+			name: name,
+			node: binary.LittleEndian.Uint64(data),
+			off:  binary.LittleEndian.Uint64(dirent[8:]),
+		})
+		data = data[fuse.DirentPlusSize(name):]
+	}
+	return
+}
+
+func TestReadDirPlus(t *testing.T) {
+	dir := &readDirPlusTestDir{}
+	for i := 0; i < 10; i++ {
+		dir.entries = append(dir.entries, DirentPlus{
+			Dirent: fuse.Dirent{Inode: uint64(i + 2), Name: fmt.Sprintf("f%d", i)},
+			Node:   &readDirPlusTestNode{inode: uint64(i + 2)},
+		})
+	}
+	c := New(nil, nil)
+	snode := &serveNode{inode: 1}
+	// as the root is saved at serving
+	c.node = append(c.node, nil, snode)
+	shandle := &serveHandle{handle: dir}
+	conn := &fuse.Conn{}
+
+	read := func(offset int64, count int) []readDirPlusTestEntry {
+		r := &fuse.ReadRequest{Header: fuse.Header{Conn: conn}, Dir: true, Plus: true, Offset: offset,
+			Size: count * fuse.DirentPlusSize("f0")}
+		s := &fuse.ReadResponse{}
+		if err := c.readDirPlus(context.Background(), snode, shandle, r, s); err != nil {
+			t.Fatalf("readdirplus at %v: %v", offset, err)
+		}
+		if len(shandle.readPlus) > readDirPlusTestPage {
+			t.Fatalf("%v entries buffered, more than a page", len(shandle.readPlus))
+		}
+		return parseDirentPlus(s.Data)
+	}
+	check := func(entries []readDirPlusTestEntry, first, count int) {
+		if len(entries) != count {
+			t.Fatalf("got %v entries, want %v", len(entries), count)
+		}
+		for i, entry := range entries {
+			want := first + i
+			if entry.name != dir.entries[want].Name || entry.off != uint64(want+1) {
+				t.Fatalf("entry %v: got %v off %v, want %v off %v", i, entry.name, entry.off,
+					dir.entries[want].Name, want+1)
+			}
+			if entry.node == 0 {
+				t.Fatalf("entry %v is not looked up", entry.name)
+			}
+		}
+	}
+
+	// the buffer fits 3 of the page read, the rest is kept for the next request
+	check(read(0, 3), 0, 3)
+	if len(shandle.readPlus) != 1 {
+		t.Fatalf("%v entries buffered, want 1", len(shandle.readPlus))
+	}
+	// resumes from the entries buffered without reading the next page
+	check(read(3, 3), 3, 1)
+	check(read(4, 10), 4, 4)
+	check(read(8, 10), 8, 2)
+	check(read(10, 10), 0, 0)
+	if want := []int64{0, 4, 8}; fmt.Sprint(dir.offsets) != fmt.Sprint(want) {
+		t.Fatalf("read at %v, want %v", dir.offsets, want)
+	}
+
+	// seeks back to the entries dropped, which are read again from the start
+	dir.offsets = nil
+	check(read(5, 2), 5, 2)
+	if want := []int64{0, 4}; fmt.Sprint(dir.offsets) != fmt.Sprint(want) {
+		t.Fatalf("read at %v, want %v", dir.offsets, want)
+	}
+	// rewinds
+	check(read(0, 1), 0, 1)
+}
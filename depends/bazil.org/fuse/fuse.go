@@ -352,6 +352,9 @@ const (
 	// ESTALE is used by Serve to respond to violations of the FUSE protocol.
 	ESTALE = Errno(syscall.ESTALE)
 
+	// ENOTTY indicates that the ioctl command is not supported.
+	ENOTTY = Errno(syscall.ENOTTY)
+
 	ENOENT = Errno(syscall.ENOENT)
 	EIO    = Errno(syscall.EIO)
 	EPERM  = Errno(syscall.EPERM)
@@ -821,14 +824,15 @@ loop:
 			Flags:  openFlags(in.Flags),
 		}
 
-	case opRead, opReaddir:
+	case opRead, opReaddir, opReaddirplus:
 		in := (*readIn)(m.data())
 		if m.len() < readInSize(c.proto) {
 			goto corrupt
 		}
 		r := &ReadRequest{
 			Header: m.Header(),
-			Dir:    m.hdr.Opcode == opReaddir,
+			Dir:    m.hdr.Opcode == opReaddir || m.hdr.Opcode == opReaddirplus,
+			Plus:   m.hdr.Opcode == opReaddirplus,
 			Handle: HandleID(in.Fh),
 			Offset: int64(in.Offset),
 			Size:   int(in.Size),
@@ -981,11 +985,38 @@ loop:
 		}
 
 	case opGetlk:
-		panic("opGetlk")
-	case opSetlk:
-		panic("opSetlk")
-	case opSetlkw:
-		panic("opSetlkw")
+		in := (*lkIn)(m.data())
+		if m.len() < lkInSize(c.proto) {
+			goto corrupt
+		}
+		req = &QueryLockRequest{
+			Header:    m.Header(),
+			Handle:    HandleID(in.Fh),
+			LockOwner: in.Owner,
+			Lock:      in.Lk.lock(),
+			LockFlags: LockFlags(in.LkFlags),
+		}
+
+	case opSetlk, opSetlkw:
+		in := (*lkIn)(m.data())
+		if m.len() < lkInSize(c.proto) {
+			goto corrupt
+		}
+		lock := LockRequest{
+			Header:    m.Header(),
+			Handle:    HandleID(in.Fh),
+			LockOwner: in.Owner,
+			Lock:      in.Lk.lock(),
+			LockFlags: LockFlags(in.LkFlags),
+		}
+		switch {
+		case lock.Lock.Type == LockUnlock:
+			req = (*UnlockRequest)(&lock)
+		case m.hdr.Opcode == opSetlkw:
+			req = (*LockWaitRequest)(&lock)
+		default:
+			req = &lock
+		}
 
 	case opAccess:
 		in := (*accessIn)(m.data())
@@ -1037,6 +1068,54 @@ loop:
 			Header: m.Header(),
 		}
 
+	case opFallocate:
+		in := (*fallocateIn)(m.data())
+		if m.len() < unsafe.Sizeof(*in) {
+			goto corrupt
+		}
+		req = &FallocateRequest{
+			Header: m.Header(),
+			Handle: HandleID(in.Fh),
+			Offset: in.Offset,
+			Length: in.Length,
+			Mode:   FallocateFlags(in.Mode),
+		}
+
+	case opCopyFileRange:
+		in := (*copyFileRangeIn)(m.data())
+		if m.len() < unsafe.Sizeof(*in) {
+			goto corrupt
+		}
+		req = &CopyFileRangeRequest{
+			Header:    m.Header(),
+			Handle:    HandleID(in.FhIn),
+			Offset:    in.OffIn,
+			NodeOut:   NodeID(in.NodeOut),
+			HandleOut: HandleID(in.FhOut),
+			OffsetOut: in.OffOut,
+			Len:       in.Len,
+			Flags:     in.Flags,
+		}
+
+	case opIoctl:
+		in := (*ioctlIn)(m.data())
+		if m.len() < unsafe.Sizeof(*in) {
+			goto corrupt
+		}
+		buf := m.bytes()[unsafe.Sizeof(*in):]
+		if uint32(len(buf)) < in.InSize {
+			goto corrupt
+		}
+		req = &IoctlRequest{
+			Header:  m.Header(),
+			Handle:  HandleID(in.Fh),
+			Flags:   in.Flags,
+			Cmd:     in.Cmd,
+			Arg:     in.Arg,
+			Data:    buf[:in.InSize],
+			OutSize: in.OutSize,
+		}
+
 	// OS X
 	case opSetvolname:
 		panic("opSetvolname")
@@ -1772,6 +1851,7 @@ func (r *MkdirResponse) String() string {
 type ReadRequest struct {
 	Header    `json:"-"`
 	Dir       bool // is this Readdir?
+	Plus      bool // is this Readdirplus?
 	Handle    HandleID
 	Offset    int64
 	Size      int
@@ -1783,7 +1863,7 @@ type ReadRequest struct {
 var _ = Request(&ReadRequest{})
 
 func (r *ReadRequest) String() string {
-	return fmt.Sprintf("Read [%s] %v %d @%#x dir=%v fl=%v lock=%d ffl=%v", &r.Header, r.Handle, r.Size, r.Offset, r.Dir, r.Flags, r.LockOwner, r.FileFlags)
+	return fmt.Sprintf("Read [%s] %v %d @%#x dir=%v plus=%v fl=%v lock=%d ffl=%v", &r.Header, r.Handle, r.Size, r.Offset, r.Dir, r.Plus, r.Flags, r.LockOwner, r.FileFlags)
 }
 
 // Respond replies to the request with the given response.
@@ -1825,7 +1905,7 @@ type ReleaseRequest struct {
 	Handle       HandleID
 	Flags        OpenFlags // flags from OpenRequest
 	ReleaseFlags ReleaseFlags
-	LockOwner    uint32
+	LockOwner    uint64
 }
 
 var _ = Request(&ReleaseRequest{})
@@ -1959,6 +2039,54 @@ func AppendDirent(data []byte, dir Dirent) []byte {
 	return data
 }
 
+// A DirentPlus is a directory entry along with the result of looking it up,
+// which is returned to the Readdirplus requests.
+type DirentPlus struct {
+	Dirent
+
+	// Entry is the lookup result of the entry. The kernel does not
+	// look up the entry if Entry.Node is zero, e.g. "." and "..".
+	Entry LookupResponse
+}
+
+// DirentPlusSize returns the size of the encoded form of a directory
+// entry with the given name for the Readdirplus requests.
+func DirentPlusSize(name string) int {
+	return (direntPlusSize + len(name) + 7) &^ 7
+}
+
+// AppendDirentPlus appends the encoded form of a directory entry with
+// the lookup result to data and returns the resulting slice. The off is
+// the offset of the next entry, which the kernel passes back to read
+// the entries after this one.
+func AppendDirentPlus(data []byte, dir DirentPlus, off uint64, proto Protocol) []byte {
+	out := entryOut{
+		Nodeid:         uint64(dir.Entry.Node),
+		Generation:     dir.Entry.Generation,
+		EntryValid:     uint64(dir.Entry.EntryValid / time.Second),
+		EntryValidNsec: uint32(dir.Entry.EntryValid % time.Second / time.Nanosecond),
+		AttrValid:      uint64(dir.Entry.Attr.Valid / time.Second),
+		AttrValidNsec:  uint32(dir.Entry.Attr.Valid % time.Second / time.Nanosecond),
+	}
+	if out.Nodeid != 0 {
+		dir.Entry.Attr.attr(&out.Attr, proto)
+	}
+	de := dirent{
+		Ino:     dir.Inode,
+		Off:     off,
+		Namelen: uint32(len(dir.Name)),
+		Type:    uint32(dir.Type),
+	}
+	data = append(data, (*[unsafe.Sizeof(entryOut{})]byte)(unsafe.Pointer(&out))[:]...)
+	data = append(data, (*[direntSize]byte)(unsafe.Pointer(&de))[:]...)
+	data = append(data, dir.Name...)
+	if n := direntPlusSize + len(dir.Name); n%8 != 0 {
+		var pad [8]byte
+		data = append(data, pad[:8-n%8]...)
+	}
+	return data
+}
+
 // A WriteRequest asks to write to an open file.
 type WriteRequest struct {
 	Header
@@ -2125,6 +2253,218 @@ func (r *FlushRequest) Respond() {
 	r.respond(buf)
 }
 
+// A FileLock describes a byte range lock of a file.
+type FileLock struct {
+	Start uint64
+	// End is the inclusive end of the range, which is OFFSET_MAX if the lock extends to the end of file.
+	End  uint64
+	Type LockType
+	// PID is the process holding the lock, which is only meaningful in QueryLockResponse.
+	PID uint32
+}
+
+func (l FileLock) String() string {
+	return fmt.Sprintf("%v %d-%d pid=%d", l.Type, l.Start, l.End, l.PID)
+}
+
+func (l fileLock) lock() FileLock {
+	return FileLock{
+		Start: l.Start,
+		End:   l.End,
+		Type:  LockType(l.Type),
+		PID:   l.Pid,
+	}
+}
+
+// A LockRequest asks to acquire a lock on a byte range of the file, or on the whole file for flock(2),
+// failing with EAGAIN if a conflicting lock is held.
+type LockRequest struct {
+	Header    `json:"-"`
+	Handle    HandleID
+	LockOwner uint64
+	Lock      FileLock
+	LockFlags LockFlags
+}
+
+var _ = Request(&LockRequest{})
+
+func (r *LockRequest) String() string {
+	return fmt.Sprintf("Lock [%s] %v owner=%#x lock=%v fl=%v", &r.Header, r.Handle, r.LockOwner, r.Lock, r.LockFlags)
+}
+
+// Respond replies to the request, indicating that the lock has been acquired.
+func (r *LockRequest) Respond() {
+	buf := newBuffer(0)
+	r.respond(buf)
+}
+
+// A LockWaitRequest asks to acquire a lock like LockRequest, but waits until the conflicting locks
+// are released. The wait is canceled by an interrupt of the request.
+type LockWaitRequest LockRequest
+
+var _ = Request(&LockWaitRequest{})
+
+func (r *LockWaitRequest) String() string {
+	return fmt.Sprintf("LockWait [%s] %v owner=%#x lock=%v fl=%v", &r.Header, r.Handle, r.LockOwner, r.Lock, r.LockFlags)
+}
+
+// Respond replies to the request, indicating that the lock has been acquired.
+func (r *LockWaitRequest) Respond() {
+	buf := newBuffer(0)
+	r.respond(buf)
+}
+
+// An UnlockRequest asks to release the locks of the owner on a byte range of the file.
+type UnlockRequest LockRequest
+
+var _ = Request(&UnlockRequest{})
+
+func (r *UnlockRequest) String() string {
+	return fmt.Sprintf("Unlock [%s] %v owner=%#x lock=%v fl=%v", &r.Header, r.Handle, r.LockOwner, r.Lock, r.LockFlags)
+}
+
+// Respond replies to the request, indicating that the lock has been released.
+func (r *UnlockRequest) Respond() {
+	buf := newBuffer(0)
+	r.respond(buf)
+}
+
+// A QueryLockRequest asks for a lock which conflicts with the given lock.
+type QueryLockRequest struct {
+	Header    `json:"-"`
+	Handle    HandleID
+	LockOwner uint64
+	Lock      FileLock
+	LockFlags LockFlags
+}
+
+var _ = Request(&QueryLockRequest{})
+
+func (r *QueryLockRequest) String() string {
+	return fmt.Sprintf("QueryLock [%s] %v owner=%#x lock=%v fl=%v", &r.Header, r.Handle, r.LockOwner, r.Lock, r.LockFlags)
+}
+
+// Respond replies to the request with the conflicting lock.
+func (r *QueryLockRequest) Respond(resp *QueryLockResponse) {
+	buf := newBuffer(unsafe.Sizeof(lkOut{}))
+	out := (*lkOut)(buf.alloc(unsafe.Sizeof(lkOut{})))
+	out.Lk = fileLock{
+		Start: resp.Lock.Start,
+		End:   resp.Lock.End,
+		Type:  uint32(resp.Lock.Type),
+		Pid:   resp.Lock.PID,
+	}
+	r.respond(buf)
+}
+
+// A QueryLockResponse is the response to a QueryLockRequest.
+// The type of the lock is LockUnlock if there is no conflicting lock.
+type QueryLockResponse struct {
+	Lock FileLock
+}
+
+func (r *QueryLockResponse) String() string {
+	return fmt.Sprintf("QueryLock lock=%v", r.Lock)
+}
+
+// A FallocateRequest asks to manipulate the allocated space of the file.
+type FallocateRequest struct {
+	Header `json:"-"`
+	Handle HandleID
+	Offset uint64
+	Length uint64
+	Mode   FallocateFlags
+}
+
+var _ = Request(&FallocateRequest{})
+
+func (r *FallocateRequest) String() string {
+	return fmt.Sprintf("Fallocate [%s] %v %d @%d mode=%v", &r.Header, r.Handle, r.Length, r.Offset, r.Mode)
+}
+
+// Respond replies to the request, indicating that the space has been manipulated.
+func (r *FallocateRequest) Respond() {
+	buf := newBuffer(0)
+	r.respond(buf)
+}
+
+// A CopyFileRangeRequest asks to copy the range of the file into
+// another file of the file system as copy_file_range(2) does.
+type CopyFileRangeRequest struct {
+	Header    `json:"-"`
+	Handle    HandleID
+	Offset    uint64
+	NodeOut   NodeID
+	HandleOut HandleID
+	OffsetOut uint64
+	Len       uint64
+	Flags     uint64
+}
+
+var _ = Request(&CopyFileRangeRequest{})
+
+func (r *CopyFileRangeRequest) String() string {
+	return fmt.Sprintf("CopyFileRange [%s] %v %d @%d -> %v %v @%d flags=%#x", &r.Header, r.Handle, r.Len, r.Offset,
+		r.NodeOut, r.HandleOut, r.OffsetOut, r.Flags)
+}
+
+// Respond replies to the request with the number of bytes copied.
+func (r *CopyFileRangeRequest) Respond(resp *CopyFileRangeResponse) {
+	buf := newBuffer(unsafe.Sizeof(writeOut{}))
+	out := (*writeOut)(buf.alloc(unsafe.Sizeof(writeOut{})))
+	out.Size = uint32(resp.Size)
+	r.respond(buf)
+}
+
+// A CopyFileRangeResponse replies to a copy indicating how many bytes were copied.
+type CopyFileRangeResponse struct {
+	Size int
+}
+
+func (r *CopyFileRangeResponse) String() string {
+	return fmt.Sprintf("CopyFileRange %d", r.Size)
+}
+
+// An IoctlRequest asks to run the ioctl(2) command on the file. The
+// kernel sends the commands unknown to the VFS only, and copies in
+// the data of the size encoded in the command from the argument.
+type IoctlRequest struct {
+	Header  `json:"-"`
+	Handle  HandleID
+	Flags   uint32
+	Cmd     uint32
+	Arg     uint64
+	Data    []byte
+	OutSize uint32
+}
+
+var _ = Request(&IoctlRequest{})
+
+func (r *IoctlRequest) String() string {
+	return fmt.Sprintf("Ioctl [%s] %v cmd=%#x arg=%#x in=%d out=%d flags=%#x", &r.Header, r.Handle, r.Cmd, r.Arg,
+		len(r.Data), r.OutSize, r.Flags)
+}
+
+// Respond replies to the request with the result of the command and
+// the data copied out to the argument, at most OutSize bytes.
+func (r *IoctlRequest) Respond(resp *IoctlResponse) {
+	buf := newBuffer(unsafe.Sizeof(ioctlOut{}) + uintptr(len(resp.Data)))
+	out := (*ioctlOut)(buf.alloc(unsafe.Sizeof(ioctlOut{})))
+	out.Result = resp.Result
+	buf = append(buf, resp.Data...)
+	r.respond(buf)
+}
+
+// An IoctlResponse is the response to an IoctlRequest.
+type IoctlResponse struct {
+	Result int32
+	Data   []byte
+}
+
+func (r *IoctlResponse) String() string {
+	return fmt.Sprintf("Ioctl result=%d out=%d", r.Result, len(r.Data))
+}
+
 // A RemoveRequest asks to remove a file or directory from the
 // directory r.Node.
 type RemoveRequest struct {
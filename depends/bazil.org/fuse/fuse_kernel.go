@@ -339,7 +339,8 @@ func flagString(f uint32, names []flagName) string {
 type ReleaseFlags uint32
 
 const (
-	ReleaseFlush ReleaseFlags = 1 << 0
+	ReleaseFlush       ReleaseFlags = 1 << 0
+	ReleaseFlockUnlock ReleaseFlags = 1 << 1
 )
 
 func (fl ReleaseFlags) String() string {
@@ -348,6 +349,44 @@ func (fl ReleaseFlags) String() string {
 
 var releaseFlagNames = []flagName{
 	{uint32(ReleaseFlush), "ReleaseFlush"},
+	{uint32(ReleaseFlockUnlock), "ReleaseFlockUnlock"},
+}
+
+// The LockFlags are passed in LockRequest or QueryLockRequest.
+type LockFlags uint32
+
+const (
+	// LockFlock indicates the lock is set by flock(2) rather than fcntl(2).
+	LockFlock LockFlags = 1 << 0
+)
+
+func (fl LockFlags) String() string {
+	return flagString(uint32(fl), lockFlagNames)
+}
+
+var lockFlagNames = []flagName{
+	{uint32(LockFlock), "LockFlock"},
+}
+
+// LockType is the type of a file lock.
+type LockType uint32
+
+const (
+	LockRead   LockType = syscall.F_RDLCK
+	LockWrite  LockType = syscall.F_WRLCK
+	LockUnlock LockType = syscall.F_UNLCK
+)
+
+func (t LockType) String() string {
+	switch t {
+	case LockRead:
+		return "LockRead"
+	case LockWrite:
+		return "LockWrite"
+	case LockUnlock:
+		return "LockUnlock"
+	}
+	return fmt.Sprintf("LockType(%d)", uint32(t))
 }
 
 // Opcodes
@@ -390,6 +429,13 @@ const (
 	opDestroy     = 38
 	opIoctl       = 39 // Linux?
 	opPoll        = 40 // Linux?
+	opFallocate   = 43 // Linux
+
+	// Linux 3.9
+	opReaddirplus = 44
+
+	// Linux 4.5
+	opCopyFileRange = 47
 
 	// OS X
 	opSetvolname = 61
@@ -549,7 +595,7 @@ type releaseIn struct {
 	Fh           uint64
 	Flags        uint32
 	ReleaseFlags uint32
-	LockOwner    uint32
+	LockOwner    uint64
 }
 
 type flushIn struct {
@@ -648,6 +694,59 @@ type fsyncIn struct {
 	_          uint32
 }
 
+type copyFileRangeIn struct {
+	FhIn    uint64
+	OffIn   uint64
+	NodeOut uint64
+	FhOut   uint64
+	OffOut  uint64
+	Len     uint64
+	Flags   uint64
+}
+
+type ioctlIn struct {
+	Fh      uint64
+	Flags   uint32
+	Cmd     uint32
+	Arg     uint64
+	InSize  uint32
+	OutSize uint32
+}
+
+type ioctlOut struct {
+	Result  int32
+	Flags   uint32
+	InIovs  uint32
+	OutIovs uint32
+}
+
+type fallocateIn struct {
+	Fh     uint64
+	Offset uint64
+	Length uint64
+	Mode   uint32
+	_      uint32
+}
+
+// The FallocateFlags are the mode of fallocate(2).
+type FallocateFlags uint32
+
+const (
+	FallocKeepSize  FallocateFlags = 0x01
+	FallocPunchHole FallocateFlags = 0x02
+	FallocZeroRange FallocateFlags = 0x10
+)
+
+var fallocateFlagNames = []flagName{
+	{uint32(FallocKeepSize), "FallocKeepSize"},
+	{uint32(FallocPunchHole), "FallocPunchHole"},
+	{uint32(FallocZeroRange), "FallocZeroRange"},
+}
+
+func (fl FallocateFlags) String() string {
+	return flagString(uint32(fl), fallocateFlagNames)
+}
+
 type setxattrInCommon struct {
 	Size  uint32
 	Flags uint32
@@ -760,6 +859,10 @@ type dirent struct {
 
 const direntSize = 8 + 8 + 4 + 4
 
+// direntPlusSize is the size of the entry before the name, the protocol supporting
+// readdirplus always carries the full entryOut.
+const direntPlusSize = int(unsafe.Sizeof(entryOut{})) + direntSize
+
 const (
 	notifyCodePoll       int32 = 1
 	notifyCodeInvalInode int32 = 2
@@ -322,6 +322,34 @@ func AllowNonEmptyMount() MountOption {
 	}
 }
 
+// ReaddirPlus enables the kernel to read the directories along with the
+// attributes of the entries, which are passed to the handles implementing
+// fs.HandleReadDirPlusser. The kernel decides when to use it adaptively.
+func ReaddirPlus() MountOption {
+	return func(conf *mountConfig) error {
+		conf.initFlags |= InitDoReaddirplus | InitReaddirplusAuto
+		return nil
+	}
+}
+
+// LockingFlock enables flock(2) locks, which are passed to the handles implementing fs.HandleLocker.
+// Without this, the locks are local to the kernel.
+func LockingFlock() MountOption {
+	return func(conf *mountConfig) error {
+		conf.initFlags |= InitFlockLocks
+		return nil
+	}
+}
+
+// LockingPOSIX enables fcntl(2) byte range locks, which are passed to the handles implementing
+// fs.HandleLocker. Without this, the locks are local to the kernel.
+func LockingPOSIX() MountOption {
+	return func(conf *mountConfig) error {
+		conf.initFlags |= InitPosixLocks
+		return nil
+	}
+}
+
 // PosixACL enable posix ACL supported.
 func PosixACL() MountOption {
 	return func(conf *mountConfig) error {
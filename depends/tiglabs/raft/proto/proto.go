@@ -68,6 +68,12 @@ type SnapIterator interface {
 	Next() ([]byte, error)
 }
 
+// The SnapshotTarget interface is implemented by the snapshot which needs to know the node it is sent to,
+// e.g. to resume an interrupted transfer.
+type SnapshotTarget interface {
+	SetTarget(nodeID uint64)
+}
+
 type SnapshotMeta struct {
 	Index uint64
 	Term  uint64
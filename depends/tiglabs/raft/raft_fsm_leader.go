@@ -420,6 +420,9 @@ func (r *raftFsm) sendAppend(to uint64) {
 		if err != nil || snapshot.ApplyIndex() < fi-1 {
 			panic(AppPanicError(fmt.Sprintf("[raft->sendAppend][%v]failed to send snapshot[%d] to %v because snapshot is unavailable, error is: \r\n%v", r.id, snapshot.ApplyIndex(), to, err)))
 		}
+		if target, ok := snapshot.(proto.SnapshotTarget); ok {
+			target.SetTarget(to)
+		}
 
 		m = proto.GetMessage()
 		m.Type = proto.ReqMsgSnapShot
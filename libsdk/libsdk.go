@@ -981,7 +981,7 @@ func cfs_rmdir(id C.int64_t, path *C.char) C.int {
 		return errorToStatus(err)
 	}
 
-	info, err = c.mw.Delete_ll(dirInfo.Inode, name, true)
+	info, err = c.mw.Delete_ll(dirInfo.Inode, name, true, absPath)
 	c.ic.Delete(dirInfo.Inode)
 	c.dc.Delete(absPath)
 	return errorToStatus(err)
@@ -1021,7 +1021,7 @@ func cfs_unlink(id C.int64_t, path *C.char) C.int {
 		return statusEISDIR
 	}
 
-	info, err = c.mw.Delete_ll(dirInfo.Inode, name, false)
+	info, err = c.mw.Delete_ll(dirInfo.Inode, name, false, absPath)
 	if err != nil {
 		return errorToStatus(err)
 	}
@@ -1214,7 +1214,7 @@ func (c *client) start() (err error) {
 		Masters:           masters,
 		FollowerRead:      c.followerRead,
 		OnAppendExtentKey: mw.AppendExtentKey,
-		OnGetExtents:      mw.GetExtents,
+		OnGetExtents:      mw.GetExtentsWithShared,
 		OnTruncate:        mw.Truncate,
 		BcacheEnable:      c.enableBcache,
 		OnLoadBcache:      c.bc.Get,
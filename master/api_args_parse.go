@@ -344,6 +344,8 @@ type updateVolReq struct {
 	authKey                 string
 	capacity                uint64
 	deleteLockTime          int64
+	trashInterval           int64
+	dirShards               int
 	followerRead            bool
 	authenticate            bool
 	enablePosixAcl          bool
@@ -444,6 +446,22 @@ func parseVolUpdateReq(r *http.Request, vol *Vol, req *updateVolReq) (err error)
 		return
 	}
 
+	if req.trashInterval, err = extractInt64WithDefault(r, volTrashIntervalKey, vol.TrashInterval); err != nil {
+		return
+	}
+	if req.trashInterval < 0 {
+		err = fmt.Errorf("trashInterval can't be negative")
+		return
+	}
+
+	if req.dirShards, err = extractUintWithDefault(r, volDirShardsKey, vol.DirShards); err != nil {
+		return
+	}
+	if req.dirShards != 0 && (req.dirShards < proto.MinDirShards || req.dirShards > proto.MaxDirShards) {
+		err = fmt.Errorf("dirShards must be 0 or between [%d] to [%d]", proto.MinDirShards, proto.MaxDirShards)
+		return
+	}
+
 	if req.enablePosixAcl, err = extractBoolWithDefault(r, enablePosixAclKey, vol.enablePosixAcl); err != nil {
 		return
 	}
@@ -624,6 +642,7 @@ type createVolReq struct {
 	DpReadOnlyWhenVolFull                bool
 	enableTransaction                    proto.TxOpMask
 	enableQuota                          bool
+	metaStoreMode                        proto.MetaStoreMode
 	txTimeout                            int64
 	txConflictRetryNum                   int64
 	txConflictRetryInterval              int64
@@ -797,6 +816,10 @@ func parseRequestToCreateVol(r *http.Request, req *createVolReq) (err error) {
 		return
 	}
 
+	if req.metaStoreMode, err = proto.ParseMetaStoreMode(extractStr(r, metaStoreModeKey)); err != nil {
+		return
+	}
+
 	return
 }
 
@@ -1188,6 +1211,14 @@ func validateRequestToCreateMetaPartition(r *http.Request) (volName string, star
 	return
 }
 
+func validateRequestToSplitMetaPartition(r *http.Request) (volName string, partitionID, start uint64, err error) {
+	if volName, start, err = validateRequestToCreateMetaPartition(r); err != nil {
+		return
+	}
+	partitionID, err = extractMetaPartitionID(r)
+	return
+}
+
 func parseAndExtractPartitionInfo(r *http.Request) (partitionID uint64, err error) {
 	if err = r.ParseForm(); err != nil {
 		return
@@ -1653,3 +1684,47 @@ func extractInodeId(r *http.Request) (inode uint64, err error) {
 	}
 	return strconv.ParseUint(value, 10, 64)
 }
+
+func parseSnapshotParam(r *http.Request) (volName, snapshot string, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	if volName, err = extractName(r); err != nil {
+		return
+	}
+	if snapshot = r.FormValue(snapshotKey); snapshot == "" {
+		err = keyNotFound(snapshotKey)
+	}
+	return
+}
+
+func parseCreateSnapshotParam(r *http.Request) (volName, snapshot, snapPath string, err error) {
+	if volName, snapshot, err = parseSnapshotParam(r); err != nil {
+		return
+	}
+	if snapPath = r.FormValue(fullPathKey); snapPath == "" {
+		err = keyNotFound(fullPathKey)
+	}
+	return
+}
+
+func parseUpdateSnapshotParam(r *http.Request) (volName, snapshot string, rootIno uint64, status uint8, err error) {
+	if volName, snapshot, err = parseSnapshotParam(r); err != nil {
+		return
+	}
+	if rootIno, err = extractUint64WithDefault(r, rootInodeKey, 0); err != nil {
+		return
+	}
+	var value = r.FormValue(statusKey)
+	if value == "" {
+		err = keyNotFound(statusKey)
+		return
+	}
+	var tmp uint64
+	if tmp, err = strconv.ParseUint(value, 10, 8); err != nil {
+		err = fmt.Errorf("args [%s] is not legal, val %s", statusKey, value)
+		return
+	}
+	status = uint8(tmp)
+	return
+}
@@ -659,6 +659,44 @@ func (m *Server) createMetaPartition(w http.ResponseWriter, r *http.Request) {
 	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprint("create meta partition successfully")))
 }
 
+// splitMetaPartition splits the inodes from start to the end of a meta partition into a new partition.
+// The items are migrated by the meta node in the background.
+func (m *Server) splitMetaPartition(w http.ResponseWriter, r *http.Request) {
+	var (
+		volName     string
+		partitionID uint64
+		start       uint64
+		vol         *Vol
+		mp          *MetaPartition
+		nextMp      *MetaPartition
+		err         error
+	)
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.AdminSplitMetaPartition))
+	defer func() {
+		doStatAndMetric(proto.AdminSplitMetaPartition, metric, err, map[string]string{exporter.Vol: volName})
+	}()
+
+	if volName, partitionID, start, err = validateRequestToSplitMetaPartition(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.getVol(volName); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+		return
+	}
+	if mp, err = vol.metaPartition(partitionID); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrMetaPartitionNotExists))
+		return
+	}
+	if nextMp, err = vol.splitMetaPartitionAt(m.cluster, mp, start); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+
+	sendOkReply(w, r, newSuccessHTTPReply(fmt.Sprintf("split meta partition[%v] at [%v] into partition[%v]",
+		partitionID, start, nextMp.PartitionID)))
+}
+
 func parsePreloadDpReq(r *http.Request, preload *DataPartitionPreLoad) (err error) {
 	if err = r.ParseForm(); err != nil {
 		return
@@ -1817,6 +1855,8 @@ func (m *Server) updateVol(w http.ResponseWriter, r *http.Request) {
 	newArgs.description = req.description
 	newArgs.capacity = req.capacity
 	newArgs.deleteLockTime = req.deleteLockTime
+	newArgs.trashInterval = req.trashInterval
+	newArgs.dirShards = req.dirShards
 	newArgs.followerRead = req.followerRead
 	newArgs.authenticate = req.authenticate
 	newArgs.dpSelectorName = req.dpSelectorName
@@ -2174,6 +2214,7 @@ func newSimpleView(vol *Vol) (view *proto.SimpleVolView) {
 		FollowerRead:            vol.FollowerRead,
 		EnablePosixAcl:          vol.enablePosixAcl,
 		EnableQuota:             vol.enableQuota,
+		MetaStoreMode:           vol.metaStoreMode.String(),
 		EnableTransaction:       proto.GetMaskString(vol.enableTransaction),
 		TxTimeout:               vol.txTimeout,
 		TxConflictRetryNum:      vol.txConflictRetryNum,
@@ -2189,6 +2230,8 @@ func newSimpleView(vol *Vol) (view *proto.SimpleVolView) {
 		DpCnt:                   len(vol.dataPartitions.partitionMap),
 		CreateTime:              time.Unix(vol.createTime, 0).Format(proto.TimeFormat),
 		DeleteLockTime:          vol.DeleteLockTime,
+		TrashInterval:           vol.TrashInterval,
+		DirShards:               vol.DirShards,
 		Description:             vol.description,
 		DpSelectorName:          vol.dpSelectorName,
 		DpSelectorParm:          vol.dpSelectorParm,
@@ -5184,3 +5227,112 @@ func (m *Server) queryBadDisks(w http.ResponseWriter, r *http.Request) {
 
 	sendOkReply(w, r, newSuccessHTTPReply(infos))
 }
+
+func (m *Server) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		vol      *Vol
+		name     string
+		snapshot string
+		snapPath string
+		info     *proto.SnapshotInfo
+	)
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.SnapshotCreate))
+	defer func() {
+		doStatAndMetric(proto.SnapshotCreate, metric, err, map[string]string{exporter.Vol: name})
+	}()
+
+	if name, snapshot, snapPath, err = parseCreateSnapshotParam(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.getVol(name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+		return
+	}
+	if info, err = vol.snapshotManager.createSnapshot(snapshot, snapPath); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(info))
+}
+
+func (m *Server) UpdateSnapshot(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		vol      *Vol
+		name     string
+		snapshot string
+		rootIno  uint64
+		status   uint8
+	)
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.SnapshotUpdate))
+	defer func() {
+		doStatAndMetric(proto.SnapshotUpdate, metric, err, map[string]string{exporter.Vol: name})
+	}()
+
+	if name, snapshot, rootIno, status, err = parseUpdateSnapshotParam(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.getVol(name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+		return
+	}
+	if err = vol.snapshotManager.updateSnapshot(snapshot, rootIno, status); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	msg := fmt.Sprintf("update snapshot [%v] of vol [%v] successfully", snapshot, name)
+	sendOkReply(w, r, newSuccessHTTPReply(msg))
+}
+
+func (m *Server) DeleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	var (
+		err      error
+		vol      *Vol
+		name     string
+		snapshot string
+	)
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.SnapshotDelete))
+	defer func() {
+		doStatAndMetric(proto.SnapshotDelete, metric, err, map[string]string{exporter.Vol: name})
+	}()
+
+	if name, snapshot, err = parseSnapshotParam(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.getVol(name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+		return
+	}
+	if err = vol.snapshotManager.deleteSnapshot(snapshot); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(err))
+		return
+	}
+	msg := fmt.Sprintf("delete snapshot [%v] of vol [%v] successfully", snapshot, name)
+	sendOkReply(w, r, newSuccessHTTPReply(msg))
+}
+
+func (m *Server) ListSnapshot(w http.ResponseWriter, r *http.Request) {
+	var (
+		err  error
+		vol  *Vol
+		name string
+	)
+	metric := exporter.NewTPCnt(apiToMetricsName(proto.SnapshotList))
+	defer func() {
+		doStatAndMetric(proto.SnapshotList, metric, err, map[string]string{exporter.Vol: name})
+	}()
+
+	if name, err = parseAndExtractName(r); err != nil {
+		sendErrReply(w, r, &proto.HTTPReply{Code: proto.ErrCodeParamError, Msg: err.Error()})
+		return
+	}
+	if vol, err = m.cluster.getVol(name); err != nil {
+		sendErrReply(w, r, newErrHTTPReply(proto.ErrVolNotExists))
+		return
+	}
+	sendOkReply(w, r, newSuccessHTTPReply(vol.snapshotManager.listSnapshot()))
+}
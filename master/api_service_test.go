@@ -830,7 +830,8 @@ func TestGetUser(t *testing.T) {
 
 func TestUpdateUser(t *testing.T) {
 	reqURL := fmt.Sprintf("%v%v", hostAddr, proto.UserUpdate)
-	param := &proto.UserUpdateParam{UserID: testUserID, AccessKey: ak, SecretKey: sk, Type: proto.UserTypeAdmin, Description: description}
+	param := &proto.UserUpdateParam{UserID: testUserID, AccessKey: ak, SecretKey: sk, Type: proto.UserTypeAdmin, Description: description,
+		PublicAccessBlock: &proto.PublicAccessBlock{BlockPublicAcls: true, RestrictPublicBuckets: true}}
 	data, err := json.Marshal(param)
 	if err != nil {
 		t.Error(err)
@@ -859,6 +860,10 @@ func TestUpdateUser(t *testing.T) {
 		t.Errorf("expect description[%v], real description[%v]\n", description, userInfo.Description)
 		return
 	}
+	if block := userInfo.PublicAccessBlock; block == nil || !block.BlockPublicAcls || block.IgnorePublicAcls || !block.RestrictPublicBuckets {
+		t.Errorf("expect public access block[%+v], real public access block[%+v]\n", param.PublicAccessBlock, block)
+		return
+	}
 }
 
 func TestGetAKInfo(t *testing.T) {
@@ -2892,6 +2892,7 @@ func (c *Cluster) createVol(req *createVolReq) (vol *Vol, err error) {
 	vol.aclMgr.init(c, vol)
 	vol.initUidSpaceManager(c)
 	vol.initQuotaManager(c)
+	vol.snapshotManager = newMasterSnapshotManager(c, vol)
 
 	if err = vol.initMetaPartitions(c, req.mpCount); err != nil {
 
@@ -2965,6 +2966,7 @@ func (c *Cluster) doCreateVol(req *createVolReq) (vol *Vol, err error) {
 		Description:             req.description,
 		EnablePosixAcl:          req.enablePosixAcl,
 		EnableQuota:             req.enableQuota,
+		MetaStoreMode:           req.metaStoreMode,
 		EnableTransaction:       req.enableTransaction,
 		TxTimeout:               req.txTimeout,
 		TxConflictRetryNum:      req.txConflictRetryNum,
@@ -698,6 +698,9 @@ func (c *Cluster) handleMetaNodeTaskResponse(nodeAddr string, task *proto.AdminT
 	case proto.OpUpdateMetaPartition:
 		response := task.Response.(*proto.UpdateMetaPartitionResponse)
 		err = c.dealUpdateMetaPartitionResp(task.OperatorAddr, response)
+	case proto.OpMigrateMetaPartition:
+		response := task.Response.(*proto.MigrateMetaPartitionResponse)
+		err = c.dealMigrateMetaPartitionResp(task.OperatorAddr, response)
 	default:
 		err := fmt.Errorf("unknown operate code %v", task.OpCode)
 		log.LogError(err)
@@ -725,6 +728,64 @@ func (c *Cluster) dealUpdateMetaPartitionResp(nodeAddr string, resp *proto.Updat
 	return
 }
 
+// dealMigrateMetaPartitionResp shrinks the range of the partition split and shows the partition split off
+// to the clients. The meta node has already cut the end of the partition when it committed the split,
+// so no update task is sent to it.
+func (c *Cluster) dealMigrateMetaPartitionResp(nodeAddr string, resp *proto.MigrateMetaPartitionResponse) (err error) {
+	if resp.Status == proto.TaskFailed {
+		msg := fmt.Sprintf("action[dealMigrateMetaPartitionResp],clusterID[%v] nodeAddr %v "+
+			"split meta partition[%v] at [%v] failed,err %v", c.Name, nodeAddr, resp.PartitionID, resp.Start, resp.Result)
+		log.LogError(msg)
+		Warn(c.Name, msg)
+		return
+	}
+	var (
+		mp, nextMp *MetaPartition
+		cmd        *RaftCmd
+		oldEnd     uint64
+	)
+	cmdMap := make(map[string]*RaftCmd, 0)
+	if mp, err = c.getMetaPartitionByID(resp.PartitionID); err != nil {
+		goto errHandler
+	}
+	if nextMp, err = c.getMetaPartitionByID(resp.TargetID); err != nil {
+		goto errHandler
+	}
+	mp.Lock()
+	defer mp.Unlock()
+	nextMp.Lock()
+	defer nextMp.Unlock()
+	if nextMp.SplitFrom != mp.PartitionID || nextMp.Start != resp.Start {
+		return
+	}
+
+	oldEnd = mp.End
+	mp.End = resp.Start - 1
+	nextMp.SplitFrom = 0
+	for _, p := range []*MetaPartition{mp, nextMp} {
+		if cmd, err = c.buildMetaPartitionRaftCmd(opSyncUpdateMetaPartition, p); err != nil {
+			break
+		}
+		cmdMap[cmd.K] = cmd
+	}
+	if err == nil {
+		err = c.syncBatchCommitCmd(cmdMap)
+	}
+	if err != nil {
+		mp.End = oldEnd
+		nextMp.SplitFrom = mp.PartitionID
+		goto errHandler
+	}
+	mp.updateInodeIDRangeForAllReplicas()
+	log.LogWarnf("action[dealMigrateMetaPartitionResp] partition[%v] end[%v] split off partition[%v]",
+		mp.PartitionID, mp.End, nextMp.PartitionID)
+	return
+
+errHandler:
+	log.LogError(fmt.Sprintf("dealMigrateMetaPartitionResp %v", err))
+	return
+}
+
 func (c *Cluster) dealDeleteMetaPartitionResp(nodeAddr string, resp *proto.DeleteMetaPartitionResponse) (err error) {
 	if resp.Status == proto.TaskFailed {
 		msg := fmt.Sprintf("action[dealDeleteMetaPartitionResp],clusterID[%v] nodeAddr %v "+
@@ -1099,7 +1160,7 @@ func (c *Cluster) updateInodeIDUpperBound(mp *MetaPartition, mr *proto.MetaParti
 	}
 
 	maxPartitionID := vol.maxPartitionID()
-	if mr.PartitionID < maxPartitionID {
+	if mr.PartitionID != maxPartitionID {
 		return
 	}
 	var end uint64
@@ -37,6 +37,8 @@ const (
 	metaPartitionCountKey = "mpCount"
 	volCapacityKey        = "capacity"
 	volDeleteLockTimeKey  = "deleteLockTime"
+	volTrashIntervalKey   = "trashInterval"
+	volDirShardsKey       = "dirShards"
 	volTypeKey            = "volType"
 	cacheRuleKey          = "cacheRuleKey"
 	emptyCacheRuleKey     = "emptyCacheRule"
@@ -115,7 +117,11 @@ const (
 	fullPathKey                = "fullPath"
 	inodeKey                   = "inode"
 	quotaKey                   = "quotaId"
+	snapshotKey                = "snapshot"
+	rootInodeKey               = "rootIno"
+	statusKey                  = "status"
 	enableQuota                = "enableQuota"
+	metaStoreModeKey           = "metaStoreMode"
 	dpDiscardKey               = "dpDiscard"
 	ignoreDiscardKey           = "ignoreDiscard"
 )
@@ -181,6 +187,7 @@ const (
 	defaultClientTriggerHitCnt                   = 1
 	defaultClientReqPeriodSeconds                = 1
 	defaultMaxQuotaNumPerVol                     = 100
+	maxSnapshotNumPerVol                         = 256
 )
 
 const (
@@ -254,6 +261,9 @@ const (
 	opSyncAllocQuotaID uint32 = 0x40
 	opSyncSetQuota     uint32 = 0x41
 	opSyncDeleteQuota  uint32 = 0x42
+
+	opSyncSetSnapshot    uint32 = 0x43
+	opSyncDeleteSnapshot uint32 = 0x44
 )
 
 const (
@@ -299,4 +309,5 @@ const (
 	volWarnUsedRatio      = 0.9
 	volCachePrefix        = keySeparator + volNameAcronym + keySeparator
 	quotaPrefix           = keySeparator + "quota" + keySeparator
+	snapshotPrefix        = keySeparator + "snapshot" + keySeparator
 )
@@ -31,6 +31,7 @@ import (
 	"github.com/cubefs/cubefs/util/config"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/trace"
 )
 
 func (m *Server) startHTTPService(modulename string, cfg *config.Config) {
@@ -84,7 +85,52 @@ func (m *Server) isFollowerRead(r *http.Request) (followerRead bool) {
 	return
 }
 
+// statusRecorder records the status code written to the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
 func (m *Server) registerAPIMiddleware(route *mux.Router) {
+	// tracer joins the trace of the caller carried by the traceparent header, or starts a new one.
+	var tracer mux.MiddlewareFunc = func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if !trace.Enabled() {
+					next.ServeHTTP(w, r)
+					return
+				}
+				var span *trace.Span
+				ctx := r.Context()
+				if parent, ok := trace.ParseTraceparent(r.Header.Get("traceparent")); ok {
+					span = trace.StartRemoteSpan(parent, "master"+r.URL.Path)
+					ctx = trace.ContextWithSpan(ctx, span)
+				} else {
+					span, ctx = trace.StartSpan(ctx, "master"+r.URL.Path)
+					span.SetKind(trace.SpanKindServer)
+				}
+				if span == nil {
+					next.ServeHTTP(w, r)
+					return
+				}
+				span.SetTag("method", r.Method)
+				span.SetTag("remote", r.RemoteAddr)
+				rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+				next.ServeHTTP(rec, r.WithContext(ctx))
+				span.SetTag("status", rec.status)
+				if rec.status >= http.StatusBadRequest {
+					span.SetError(fmt.Errorf("%v", http.StatusText(rec.status)))
+				}
+				span.Finish()
+			})
+	}
+	route.Use(tracer)
+
 	var interceptor mux.MiddlewareFunc = func(next http.Handler) http.Handler {
 		return http.HandlerFunc(
 			func(w http.ResponseWriter, r *http.Request) {
@@ -334,6 +380,9 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AdminCreateMetaPartition).
 		HandlerFunc(m.createMetaPartition)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.AdminSplitMetaPartition).
+		HandlerFunc(m.splitMetaPartition)
 	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
 		Path(proto.AdminAddMetaReplica).
 		HandlerFunc(m.addMetaReplica)
@@ -560,6 +609,20 @@ func (m *Server) registerAPIRoutes(router *mux.Router) {
 		Path(proto.QuotaListAll).
 		HandlerFunc(m.ListQuotaAll)
 
+	// Snapshot
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.SnapshotCreate).
+		HandlerFunc(m.CreateSnapshot)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.SnapshotUpdate).
+		HandlerFunc(m.UpdateSnapshot)
+	router.NewRoute().Methods(http.MethodGet, http.MethodPost).
+		Path(proto.SnapshotDelete).
+		HandlerFunc(m.DeleteSnapshot)
+	router.NewRoute().Methods(http.MethodGet).
+		Path(proto.SnapshotList).
+		HandlerFunc(m.ListSnapshot)
+
 }
 
 func (m *Server) registerHandler(router *mux.Router, model string, schema *graphql.Schema) {
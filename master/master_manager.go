@@ -208,6 +208,12 @@ func (m *Server) loadMetadata() {
 		panic(err)
 	}
 	log.LogInfo("action[loadQuota] end")
+
+	log.LogInfo("action[loadSnapshot] begin")
+	if err = m.cluster.loadSnapshot(); err != nil {
+		panic(err)
+	}
+	log.LogInfo("action[loadSnapshot] end")
 }
 
 func (m *Server) clearMetadata() {
@@ -0,0 +1,205 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// MasterSnapshotManager keeps the records of the snapshots of the volume. The trees of the snapshots are
+// copied by the clients, the master only tracks the root inodes and the status of the snapshots.
+type MasterSnapshotManager struct {
+	snapshots map[string]*proto.SnapshotInfo
+	vol       *Vol
+	c         *Cluster
+
+	sync.RWMutex
+}
+
+func newMasterSnapshotManager(c *Cluster, vol *Vol) *MasterSnapshotManager {
+	return &MasterSnapshotManager{
+		snapshots: make(map[string]*proto.SnapshotInfo),
+		vol:       vol,
+		c:         c,
+	}
+}
+
+func (smMgr *MasterSnapshotManager) snapshotKey(name string) string {
+	return snapshotPrefix + strconv.FormatUint(smMgr.vol.ID, 10) + keySeparator + name
+}
+
+func (smMgr *MasterSnapshotManager) syncSnapshot(op uint32, info *proto.SnapshotInfo) (err error) {
+	var value []byte
+	if value, err = json.Marshal(info); err != nil {
+		return
+	}
+	metadata := new(RaftCmd)
+	metadata.Op = op
+	metadata.K = smMgr.snapshotKey(info.Name)
+	metadata.V = value
+	return smMgr.c.submit(metadata)
+}
+
+func (smMgr *MasterSnapshotManager) createSnapshot(name, snapPath string) (info *proto.SnapshotInfo, err error) {
+	if !volNameRegexp.MatchString(name) {
+		err = errors.NewErrorf("invalid snapshot name [%v]", name)
+		return
+	}
+	// the data extents of the snapshot are frozen on the data nodes
+	if proto.IsCold(smMgr.vol.VolType) {
+		err = errors.NewErrorf("snapshot is not supported by the cold volume [%v]", smMgr.vol.Name)
+		return
+	}
+	if !path.IsAbs(snapPath) {
+		err = errors.NewErrorf("the path [%v] of the snapshot is not absolute", snapPath)
+		return
+	}
+
+	smMgr.Lock()
+	defer smMgr.Unlock()
+	if _, ok := smMgr.snapshots[name]; ok {
+		err = errors.NewErrorf("snapshot [%v] already exists", name)
+		return
+	}
+	if len(smMgr.snapshots) >= maxSnapshotNumPerVol {
+		err = errors.NewErrorf("the number of snapshots has reached the upper limit %v", maxSnapshotNumPerVol)
+		return
+	}
+
+	info = &proto.SnapshotInfo{
+		VolName:    smMgr.vol.Name,
+		Name:       name,
+		Path:       path.Clean(snapPath),
+		CreateTime: time.Now().Unix(),
+		Status:     proto.SnapshotStatusCreating,
+	}
+	if info.Id, err = smMgr.c.idAlloc.allocateCommonID(); err != nil {
+		return
+	}
+	if err = smMgr.syncSnapshot(opSyncSetSnapshot, info); err != nil {
+		log.LogErrorf("create snapshot [%v] submit fail [%v].", info, err)
+		return
+	}
+	smMgr.snapshots[name] = info
+	log.LogInfof("create snapshot [%v] success.", info)
+	return
+}
+
+// updateSnapshot sets the root inode of the snapshot being created, and moves the snapshot to the status given.
+// The snapshot being created becomes normal once the versions are taken, and any snapshot can be marked deleting.
+func (smMgr *MasterSnapshotManager) updateSnapshot(name string, rootIno uint64, status uint8) (err error) {
+	smMgr.Lock()
+	defer smMgr.Unlock()
+	old, ok := smMgr.snapshots[name]
+	if !ok {
+		err = errors.NewErrorf("snapshot [%v] is not exist", name)
+		return
+	}
+	info := *old
+	if rootIno != 0 && rootIno != info.RootInode {
+		if info.Status != proto.SnapshotStatusCreating {
+			err = errors.NewErrorf("the root inode of snapshot [%v] can not be changed in status %v",
+				name, proto.SnapshotStatusToString(info.Status))
+			return
+		}
+		info.RootInode = rootIno
+	}
+	if status != info.Status {
+		switch {
+		case status == proto.SnapshotStatusNormal && info.Status == proto.SnapshotStatusCreating:
+			if info.RootInode == 0 {
+				err = errors.NewErrorf("snapshot [%v] has no root inode", name)
+				return
+			}
+		case status == proto.SnapshotStatusDeleting:
+		default:
+			err = errors.NewErrorf("snapshot [%v] can not be moved from %v to %v", name,
+				proto.SnapshotStatusToString(info.Status), proto.SnapshotStatusToString(status))
+			return
+		}
+		info.Status = status
+	}
+	if err = smMgr.syncSnapshot(opSyncSetSnapshot, &info); err != nil {
+		log.LogErrorf("update snapshot [%v] submit fail [%v].", info, err)
+		return
+	}
+	smMgr.snapshots[name] = &info
+	log.LogInfof("update snapshot [%v] success.", info)
+	return
+}
+
+// deleteSnapshot removes the record of the snapshot, whose versions must have been deleted by the client.
+func (smMgr *MasterSnapshotManager) deleteSnapshot(name string) (err error) {
+	smMgr.Lock()
+	defer smMgr.Unlock()
+	info, ok := smMgr.snapshots[name]
+	if !ok {
+		err = errors.NewErrorf("snapshot [%v] is not exist", name)
+		return
+	}
+	if info.Status != proto.SnapshotStatusDeleting {
+		err = errors.NewErrorf("snapshot [%v] must be marked deleting before deleted", name)
+		return
+	}
+	if err = smMgr.syncSnapshot(opSyncDeleteSnapshot, info); err != nil {
+		log.LogErrorf("delete snapshot [%v] submit fail [%v].", info, err)
+		return
+	}
+	delete(smMgr.snapshots, name)
+	log.LogInfof("delete snapshot [%v] success.", info)
+	return
+}
+
+// listSnapshot returns the snapshots ordered by the creation.
+func (smMgr *MasterSnapshotManager) listSnapshot() (snapshots []*proto.SnapshotInfo) {
+	smMgr.RLock()
+	snapshots = make([]*proto.SnapshotInfo, 0, len(smMgr.snapshots))
+	for _, info := range smMgr.snapshots {
+		s := *info
+		snapshots = append(snapshots, &s)
+	}
+	smMgr.RUnlock()
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Id < snapshots[j].Id })
+	return
+}
+
+func (vol *Vol) loadSnapshotManager(c *Cluster) (err error) {
+	vol.snapshotManager = newMasterSnapshotManager(c, vol)
+	result, err := c.fsm.store.SeekForPrefix([]byte(snapshotPrefix + strconv.FormatUint(vol.ID, 10) + keySeparator))
+	if err != nil {
+		err = fmt.Errorf("loadSnapshotManager get snapshot failed, err [%v]", err)
+		return
+	}
+	for _, value := range result {
+		var info = &proto.SnapshotInfo{}
+		if err = json.Unmarshal(value, info); err != nil {
+			log.LogErrorf("loadSnapshotManager Unmarshal fail err [%v]", err)
+			return
+		}
+		log.LogDebugf("loadSnapshotManager info [%v]", info)
+		vol.snapshotManager.snapshots[info.Name] = info
+	}
+	return
+}
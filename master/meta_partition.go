@@ -73,7 +73,9 @@ type MetaPartition struct {
 	LoadResponse     []*proto.MetaPartitionLoadResponse
 	offlineMutex     sync.RWMutex
 	uidInfo          []*proto.UidReportSpaceInfo
+	storeMode        proto.MetaStoreMode
 	EqualCheckPass   bool
+	SplitFrom        uint64 // the partition the range is split off, hidden from the clients until the items are migrated
 	sync.RWMutex
 }
 
@@ -207,7 +209,7 @@ func (mp *MetaPartition) dataSize() uint64 {
 
 func (mp *MetaPartition) checkEnd(c *Cluster, maxPartitionID uint64) {
 
-	if mp.PartitionID < maxPartitionID {
+	if mp.PartitionID != maxPartitionID {
 		return
 	}
 	vol, err := c.getVol(mp.volName)
@@ -332,7 +334,7 @@ func (mp *MetaPartition) checkStatus(clusterID string, writeLog bool, replicaNum
 		}
 	}
 
-	if mp.PartitionID >= maxPartitionID && mp.Status == proto.ReadOnly {
+	if mp.PartitionID == maxPartitionID && mp.Status == proto.ReadOnly {
 		mp.Status = proto.ReadWrite
 	}
 
@@ -598,6 +600,7 @@ func (mp *MetaPartition) buildNewMetaPartitionTasks(specifyAddrs []string, peers
 		PartitionID: mp.PartitionID,
 		Members:     peers,
 		VolName:     volName,
+		StoreMode:   mp.storeMode,
 	}
 	if specifyAddrs == nil {
 		hosts = mp.Hosts
@@ -658,6 +661,7 @@ func (mp *MetaPartition) createTaskToCreateReplica(host string) (t *proto.AdminT
 		PartitionID: mp.PartitionID,
 		Members:     mp.Peers,
 		VolName:     mp.volName,
+		StoreMode:   mp.storeMode,
 	}
 	t = proto.NewAdminTask(proto.OpCreateMetaPartition, host, req)
 	resetMetaPartitionTaskID(t, mp.PartitionID)
@@ -693,6 +697,23 @@ func (mp *MetaPartition) createTaskToDecommissionReplica(volName string, removeP
 	return
 }
 
+func (mp *MetaPartition) createTaskToMigrate(target *MetaPartition) (t *proto.AdminTask, err error) {
+	mr, err := mp.getMetaReplicaLeader()
+	if err != nil {
+		return nil, errors.NewError(err)
+	}
+	req := &proto.MigrateMetaPartitionRequest{
+		PartitionID: mp.PartitionID,
+		VolName:     mp.volName,
+		Start:       target.Start,
+		TargetID:    target.PartitionID,
+		TargetHosts: target.Hosts,
+	}
+	t = proto.NewAdminTask(proto.OpMigrateMetaPartition, mr.Addr, req)
+	resetMetaPartitionTaskID(t, mp.PartitionID)
+	return
+}
+
 func resetMetaPartitionTaskID(t *proto.AdminTask, partitionID uint64) {
 	t.ID = fmt.Sprintf("%v_pid[%v]", t.ID, partitionID)
 	t.PartitionID = partitionID
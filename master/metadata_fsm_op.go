@@ -97,6 +97,7 @@ type metaPartitionValue struct {
 	OfflinePeerID uint64
 	Peers         []bsProto.Peer
 	IsRecover     bool
+	SplitFrom     uint64
 }
 
 func newMetaPartitionValue(mp *MetaPartition) (mpv *metaPartitionValue) {
@@ -112,6 +113,7 @@ func newMetaPartitionValue(mp *MetaPartition) (mpv *metaPartitionValue) {
 		Peers:         mp.Peers,
 		OfflinePeerID: mp.OfflinePeerID,
 		IsRecover:     mp.IsRecover,
+		SplitFrom:     mp.SplitFrom,
 	}
 	return
 }
@@ -233,6 +235,8 @@ type volValue struct {
 	OSSSecretKey    string
 	CreateTime      int64
 	DeleteLockTime  int64
+	TrashInterval   int64
+	DirShards       int
 	Description     string
 	DpSelectorName  string
 	DpSelectorParm  string
@@ -252,6 +256,7 @@ type volValue struct {
 
 	EnablePosixAcl bool
 	EnableQuota    bool
+	MetaStoreMode  bsProto.MetaStoreMode
 
 	EnableTransaction       bsProto.TxOpMask
 	TxTimeout               int64
@@ -290,12 +295,15 @@ func newVolValue(vol *Vol) (vv *volValue) {
 		OSSSecretKey:            vol.OSSSecretKey,
 		CreateTime:              vol.createTime,
 		DeleteLockTime:          vol.DeleteLockTime,
+		TrashInterval:           vol.TrashInterval,
+		DirShards:               vol.DirShards,
 		Description:             vol.description,
 		DpSelectorName:          vol.dpSelectorName,
 		DpSelectorParm:          vol.dpSelectorParm,
 		DefaultPriority:         vol.defaultPriority,
 		EnablePosixAcl:          vol.enablePosixAcl,
 		EnableQuota:             vol.enableQuota,
+		MetaStoreMode:           vol.metaStoreMode,
 		EnableTransaction:       vol.enableTransaction,
 		TxTimeout:               vol.txTimeout,
 		TxConflictRetryNum:      vol.txConflictRetryNum,
@@ -1323,10 +1331,12 @@ func (c *Cluster) loadMetaPartitions() (err error) {
 			}
 		}
 		mp := newMetaPartition(mpv.PartitionID, mpv.Start, mpv.End, vol.mpReplicaNum, vol.Name, mpv.VolID)
+		mp.storeMode = vol.metaStoreMode
 		mp.setHosts(strings.Split(mpv.Hosts, underlineSeparator))
 		mp.setPeers(mpv.Peers)
 		mp.OfflinePeerID = mpv.OfflinePeerID
 		mp.IsRecover = mpv.IsRecover
+		mp.SplitFrom = mpv.SplitFrom
 		vol.addMetaPartition(mp)
 		c.addBadMetaParitionIdMap(mp)
 		log.LogInfof("action[loadMetaPartitions],vol[%v],mp[%v]", vol.Name, mp.PartitionID)
@@ -1388,6 +1398,18 @@ func (c *Cluster) loadQuota() (err error) {
 	return
 }
 
+func (c *Cluster) loadSnapshot() (err error) {
+	c.volMutex.RLock()
+	defer c.volMutex.RUnlock()
+	for name, vol := range c.vols {
+		if err = vol.loadSnapshotManager(c); err != nil {
+			log.LogErrorf("loadSnapshot loadSnapshotManager vol [%v] fail err [%v]", name, err.Error())
+			return err
+		}
+	}
+	return
+}
+
 func (c *Cluster) addBadDataParitionIdMap(dp *DataPartition) {
 	if !dp.isRecover {
 		return
@@ -98,6 +98,8 @@ func unmarshalTaskResponse(task *proto.AdminTask) (err error) {
 		response = &proto.DeleteMetaPartitionResponse{}
 	case proto.OpUpdateMetaPartition:
 		response = &proto.UpdateMetaPartitionResponse{}
+	case proto.OpMigrateMetaPartition:
+		response = &proto.MigrateMetaPartitionResponse{}
 	case proto.OpDecommissionMetaPartition:
 		response = &proto.MetaPartitionDecommissionResponse{}
 	default:
@@ -34,6 +34,7 @@ import (
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/trace"
 )
 
 // configuration keys
@@ -161,6 +162,9 @@ func (m *Server) Start(cfg *config.Config) (err error) {
 		return fmt.Errorf("action[Start] failed %v, err: master service Key invalid = %s", proto.ErrInvalidCfg, MasterSecretKey)
 	}
 	m.cluster.scheduleTask()
+	if err = trace.Init(ModuleName, cfg); err != nil {
+		return
+	}
 	m.startHTTPService(ModuleName, cfg)
 	exporter.RegistConsul(m.clusterName, ModuleName, cfg)
 	WarnMetrics = newWarningMetrics(m.cluster)
@@ -183,6 +187,7 @@ func (m *Server) Shutdown() {
 		}
 	}
 	stat.CloseStat()
+	trace.Close()
 	m.wg.Done()
 }
 
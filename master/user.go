@@ -231,6 +231,14 @@ func (u *User) updateKey(param *proto.UserUpdateParam) (userInfo *proto.UserInfo
 	if describeMark == 1 {
 		userInfo.Description = param.Description
 	}
+	// An empty public access block removes the user level settings.
+	if param.PublicAccessBlock != nil {
+		if param.PublicAccessBlock.IsEmpty() {
+			userInfo.PublicAccessBlock = nil
+		} else {
+			userInfo.PublicAccessBlock = param.PublicAccessBlock
+		}
+	}
 
 	if len(strings.TrimSpace(param.Password)) != 0 {
 		akUserBef.Password = encodingPassword(param.Password)
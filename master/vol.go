@@ -32,6 +32,8 @@ type VolVarargs struct {
 	description             string
 	capacity                uint64 //GB
 	deleteLockTime          int64  //h
+	trashInterval           int64  //min
+	dirShards               int
 	followerRead            bool
 	authenticate            bool
 	dpSelectorName          string
@@ -97,6 +99,8 @@ type Vol struct {
 	createMpMutex           sync.RWMutex
 	createTime              int64
 	DeleteLockTime          int64
+	TrashInterval           int64
+	DirShards               int
 	description             string
 	dpSelectorName          string
 	dpSelectorParm          string
@@ -107,7 +111,9 @@ type Vol struct {
 	uidSpaceManager         *UidSpaceManager
 	volLock                 sync.RWMutex
 	quotaManager            *MasterQuotaManager
+	snapshotManager         *MasterSnapshotManager
 	enableQuota             bool
+	metaStoreMode           proto.MetaStoreMode
 }
 
 func newVol(vv volValue) (vol *Vol) {
@@ -133,11 +139,14 @@ func newVol(vv volValue) (vol *Vol) {
 	vol.mpsCache = make([]byte, 0)
 	vol.createTime = vv.CreateTime
 	vol.DeleteLockTime = vv.DeleteLockTime
+	vol.TrashInterval = vv.TrashInterval
+	vol.DirShards = vv.DirShards
 	vol.description = vv.Description
 	vol.defaultPriority = vv.DefaultPriority
 	vol.domainId = vv.DomainId
 	vol.enablePosixAcl = vv.EnablePosixAcl
 	vol.enableQuota = vv.EnableQuota
+	vol.metaStoreMode = vv.MetaStoreMode
 	vol.enableTransaction = vv.EnableTransaction
 	vol.txTimeout = vv.TxTimeout
 	vol.txConflictRetryNum = vv.TxConflictRetryNum
@@ -280,12 +289,15 @@ func (vol *Vol) metaPartition(partitionID uint64) (mp *MetaPartition, err error)
 	return
 }
 
+// maxPartitionID returns the ID of the tail partition, the one with the largest start. The partitions
+// split off the middle of the range have larger IDs than the tail.
 func (vol *Vol) maxPartitionID() (maxPartitionID uint64) {
 	vol.mpsLock.RLock()
 	defer vol.mpsLock.RUnlock()
-	for id := range vol.MetaPartitions {
-		if id > maxPartitionID {
-			maxPartitionID = id
+	var maxStart uint64
+	for id, mp := range vol.MetaPartitions {
+		if maxPartitionID == 0 || mp.Start > maxStart || (mp.Start == maxStart && id > maxPartitionID) {
+			maxPartitionID, maxStart = id, mp.Start
 		}
 	}
 	return
@@ -823,6 +835,8 @@ func (vol *Vol) updateViewCache(c *Cluster) {
 	view := proto.NewVolView(vol.Name, vol.Status, vol.FollowerRead, vol.createTime, vol.CacheTTL, vol.VolType, vol.DeleteLockTime)
 	view.SetOwner(vol.Owner)
 	view.SetOSSSecure(vol.OSSAccessKey, vol.OSSSecretKey)
+	view.TrashInterval = vol.TrashInterval
+	view.DirShards = vol.DirShards
 	mpViews := vol.getMetaPartitionsView()
 	view.MetaPartitions = mpViews
 	mpViewsReply := newSuccessHTTPReply(mpViews)
@@ -854,6 +868,10 @@ func (vol *Vol) getMetaPartitionsView() (mpViews []*proto.MetaPartitionView) {
 
 	mpViews = make([]*proto.MetaPartitionView, 0)
 	for _, mp := range mps {
+		// the clients see the partition split off once its items are migrated
+		if mp.SplitFrom != 0 {
+			continue
+		}
 		mpViews = append(mpViews, getMetaPartitionView(mp))
 	}
 	return
@@ -1151,6 +1169,61 @@ func (vol *Vol) splitMetaPartition(c *Cluster, mp *MetaPartition, end uint64, me
 	return
 }
 
+// splitMetaPartitionAt splits the inodes from start to the end of a partition which is not the last one
+// into a new partition. The new partition is hidden from the clients until the meta node has migrated
+// the items to it, see dealMigrateMetaPartitionResp.
+func (vol *Vol) splitMetaPartitionAt(c *Cluster, mp *MetaPartition, start uint64) (nextMp *MetaPartition, err error) {
+	vol.createMpMutex.Lock()
+	defer vol.createMpMutex.Unlock()
+
+	if maxPartitionID := vol.maxPartitionID(); maxPartitionID == mp.PartitionID {
+		err = fmt.Errorf("mp[%v] is the last meta partition, it's split by its end", mp.PartitionID)
+		return
+	}
+	if mp.SplitFrom != 0 {
+		err = fmt.Errorf("mp[%v] is being split off mp[%v]", mp.PartitionID, mp.SplitFrom)
+		return
+	}
+	mp.RLock()
+	mpStart, mpEnd := mp.Start, mp.End
+	mp.RUnlock()
+	if start <= mpStart || start > mpEnd {
+		err = fmt.Errorf("start[%v] is out of the range (%v,%v] of mp[%v]", start, mpStart, mpEnd, mp.PartitionID)
+		return
+	}
+
+	// the split interrupted is resumed by the same start
+	for _, p := range vol.cloneMetaPartitionMap() {
+		if p.SplitFrom != mp.PartitionID {
+			continue
+		}
+		if p.Start != start {
+			err = fmt.Errorf("mp[%v] is being split at [%v] into mp[%v]", mp.PartitionID, p.Start, p.PartitionID)
+			return
+		}
+		nextMp = p
+	}
+	if nextMp == nil {
+		if nextMp, err = vol.doCreateMetaPartition(c, start, mpEnd); err != nil {
+			return
+		}
+		nextMp.SplitFrom = mp.PartitionID
+		if err = c.syncAddMetaPartition(nextMp); err != nil {
+			return nil, errors.NewError(err)
+		}
+		vol.addMetaPartition(nextMp)
+	}
+
+	task, err := mp.createTaskToMigrate(nextMp)
+	if err != nil {
+		return
+	}
+	c.addMetaNodeTasks([]*proto.AdminTask{task})
+	log.LogWarnf("action[splitMetaPartitionAt] partition[%v] start[%v] end[%v] migrates to partition[%v]",
+		mp.PartitionID, start, mpEnd, nextMp.PartitionID)
+	return
+}
+
 func (vol *Vol) createMetaPartition(c *Cluster, start, end uint64) (err error) {
 	var mp *MetaPartition
 	if mp, err = vol.doCreateMetaPartition(c, start, end); err != nil {
@@ -1196,6 +1269,7 @@ func (vol *Vol) doCreateMetaPartition(c *Cluster, start, end uint64) (mp *MetaPa
 	}
 
 	mp = newMetaPartition(partitionID, start, end, vol.mpReplicaNum, vol.Name, vol.ID)
+	mp.storeMode = vol.metaStoreMode
 	mp.setHosts(hosts)
 	mp.setPeers(peers)
 
@@ -1250,6 +1324,8 @@ func setVolFromArgs(args *VolVarargs, vol *Vol) {
 	vol.zoneName = args.zoneName
 	vol.Capacity = args.capacity
 	vol.DeleteLockTime = args.deleteLockTime
+	vol.TrashInterval = args.trashInterval
+	vol.DirShards = args.dirShards
 	vol.FollowerRead = args.followerRead
 	vol.authenticate = args.authenticate
 	vol.enablePosixAcl = args.enablePosixAcl
@@ -1300,6 +1376,8 @@ func getVolVarargs(vol *Vol) *VolVarargs {
 		description:             vol.description,
 		capacity:                vol.Capacity,
 		deleteLockTime:          vol.DeleteLockTime,
+		trashInterval:           vol.TrashInterval,
+		dirShards:               vol.DirShards,
 		followerRead:            vol.FollowerRead,
 		authenticate:            vol.authenticate,
 		dpSelectorName:          vol.dpSelectorName,
@@ -77,6 +77,10 @@ func createMetaPartition(rootDir string, t *testing.T) (mp *metaPartition) {
 		txRbInodeTree:  mp.txProcessor.txResource.txRbInodeTree,
 		txRbDentryTree: mp.txProcessor.txResource.txRbDentryTree,
 		uniqChecker:    newUniqChecker(),
+		fileLocks:      newFileLockTable(),
+		extentRefs:     newExtentRefTable(),
+		trash:          newTrashTable(),
+		orphans:        newOrphanTable(),
 	}
 	mp.uidManager = NewUidMgr(mpC.VolName, mpC.PartitionId)
 	mp.mqMgr = NewQuotaManager(mpC.VolName, mpC.PartitionId)
@@ -18,6 +18,7 @@ import (
 	"sync"
 
 	"github.com/cubefs/cubefs/util/btree"
+	"github.com/cubefs/cubefs/util/log"
 )
 
 const defaultBTreeDegree = 32
@@ -28,9 +29,14 @@ type (
 )
 
 // BTree is the wrapper of Google's btree.
+// The items of a BTree with kv set are kept in a KVStore instead, see kvTree.
 type BTree struct {
 	sync.RWMutex
-	tree *btree.BTree
+	tree    *btree.BTree
+	kv      *kvTree
+	changes *changeSet // keys changed since the last snapshot, see trackChanges
+	// keys of the range migrated to another partition changed since the migration started, see trackMigration
+	migration *changeSet
 }
 
 // NewBtree creates a new btree.
@@ -40,8 +46,19 @@ func NewBtree() *BTree {
 	}
 }
 
+func newKVBtree(t *kvTree) *BTree {
+	return &BTree{kv: t}
+}
+
 // Get returns the object of the given key in the btree.
 func (b *BTree) Get(key BtreeItem) (item BtreeItem) {
+	if b.kv != nil {
+		return b.kv.lookup(key, b.kv.isTracking())
+	}
+	if b.isTracking() {
+		// the item may be modified in place while applying the raft log
+		return b.CopyGet(key)
+	}
 	b.RLock()
 	item = b.tree.Get(key)
 	b.RUnlock()
@@ -49,17 +66,29 @@ func (b *BTree) Get(key BtreeItem) (item BtreeItem) {
 }
 
 func (b *BTree) CopyGet(key BtreeItem) (item BtreeItem) {
+	if b.kv != nil {
+		return b.kv.lookup(key, true)
+	}
 	b.Lock()
-	item = b.tree.CopyGet(key)
+	if item = b.tree.CopyGet(key); item != nil {
+		b.recordChange(item)
+	}
 	b.Unlock()
 	return
 }
 
 // Find searches for the given key in the btree.
 func (b *BTree) Find(key BtreeItem, fn func(i BtreeItem)) {
-	b.RLock()
-	item := b.tree.Get(key)
-	b.RUnlock()
+	var item BtreeItem
+	if b.kv != nil {
+		item = b.kv.lookup(key, b.kv.isTracking())
+	} else if b.isTracking() {
+		item = b.CopyGet(key)
+	} else {
+		b.RLock()
+		item = b.tree.Get(key)
+		b.RUnlock()
+	}
 	if item == nil {
 		return
 	}
@@ -67,14 +96,24 @@ func (b *BTree) Find(key BtreeItem, fn func(i BtreeItem)) {
 }
 
 func (b *BTree) CopyFind(key BtreeItem, fn func(i BtreeItem)) {
+	if b.kv != nil {
+		fn(b.kv.lookup(key, true))
+		return
+	}
 	b.Lock()
 	item := b.tree.CopyGet(key)
+	if item != nil {
+		b.recordChange(item)
+	}
 	fn(item)
 	b.Unlock()
 }
 
 // Has checks if the key exists in the btree.
 func (b *BTree) Has(key BtreeItem) (ok bool) {
+	if b.kv != nil {
+		return b.kv.lookup(key, false) != nil
+	}
 	b.RLock()
 	ok = b.tree.Has(key)
 	b.RUnlock()
@@ -83,23 +122,40 @@ func (b *BTree) Has(key BtreeItem) (ok bool) {
 
 // Delete deletes the object by the given key.
 func (b *BTree) Delete(key BtreeItem) (item BtreeItem) {
+	if b.kv != nil {
+		return b.kv.delete(key)
+	}
 	b.Lock()
-	item = b.tree.Delete(key)
+	if item = b.tree.Delete(key); item != nil {
+		b.recordChange(item)
+	}
 	b.Unlock()
 	return
 }
 
-func (b *BTree) Execute(fn func(tree *btree.BTree) interface{}) interface{} {
+// DeleteIf deletes the object by the given key if fn returns true for it.
+func (b *BTree) DeleteIf(key BtreeItem, fn func(i BtreeItem) bool) (item BtreeItem) {
+	if b.kv != nil {
+		return b.kv.deleteIf(key, fn)
+	}
 	b.Lock()
 	defer b.Unlock()
-	return fn(b.tree)
+	if item = b.tree.CopyGet(key); item == nil || !fn(item) {
+		return nil
+	}
+	b.recordChange(item)
+	return b.tree.Delete(key)
 }
 
 // ReplaceOrInsert is the wrapper of google's btree ReplaceOrInsert.
 func (b *BTree) ReplaceOrInsert(key BtreeItem, replace bool) (item BtreeItem, ok bool) {
+	if b.kv != nil {
+		return b.kv.replaceOrInsert(key, replace)
+	}
 	b.Lock()
 	if replace {
 		item = b.tree.ReplaceOrInsert(key)
+		b.recordChange(key)
 		b.Unlock()
 		ok = true
 		return
@@ -108,6 +164,7 @@ func (b *BTree) ReplaceOrInsert(key BtreeItem, replace bool) (item BtreeItem, ok
 	item = b.tree.Get(key)
 	if item == nil {
 		item = b.tree.ReplaceOrInsert(key)
+		b.recordChange(key)
 		b.Unlock()
 		ok = true
 		return
@@ -121,6 +178,10 @@ func (b *BTree) ReplaceOrInsert(key BtreeItem, replace bool) (item BtreeItem, ok
 // This function scans the entire btree. When the data is huge, it is not recommended to use this function online.
 // Instead, it is recommended to call GetTree to obtain the snapshot of the current btree, and then do the scan on the snapshot.
 func (b *BTree) Ascend(fn func(i BtreeItem) bool) {
+	if b.kv != nil {
+		b.kv.ascendRange(nil, nil, fn)
+		return
+	}
 	b.RLock()
 	b.tree.Ascend(fn)
 	b.RUnlock()
@@ -128,6 +189,10 @@ func (b *BTree) Ascend(fn func(i BtreeItem) bool) {
 
 // AscendRange is the wrapper of the google's btree AscendRange.
 func (b *BTree) AscendRange(greaterOrEqual, lessThan BtreeItem, iterator func(i BtreeItem) bool) {
+	if b.kv != nil {
+		b.kv.ascendRange(greaterOrEqual, lessThan, iterator)
+		return
+	}
 	b.RLock()
 	b.tree.AscendRange(greaterOrEqual, lessThan, iterator)
 	b.RUnlock()
@@ -135,6 +200,10 @@ func (b *BTree) AscendRange(greaterOrEqual, lessThan BtreeItem, iterator func(i
 
 // AscendGreaterOrEqual is the wrapper of the google's btree AscendGreaterOrEqual
 func (b *BTree) AscendGreaterOrEqual(pivot BtreeItem, iterator func(i BtreeItem) bool) {
+	if b.kv != nil {
+		b.kv.ascendRange(pivot, nil, iterator)
+		return
+	}
 	b.RLock()
 	b.tree.AscendGreaterOrEqual(pivot, iterator)
 	b.RUnlock()
@@ -142,6 +211,9 @@ func (b *BTree) AscendGreaterOrEqual(pivot BtreeItem, iterator func(i BtreeItem)
 
 // GetTree returns the snapshot of a btree.
 func (b *BTree) GetTree() *BTree {
+	if b.kv != nil {
+		return newKVBtree(b.kv.newView())
+	}
 	b.Lock()
 	t := b.tree.Clone()
 	b.Unlock()
@@ -152,13 +224,30 @@ func (b *BTree) GetTree() *BTree {
 
 // Reset resets the current btree.
 func (b *BTree) Reset() {
+	if b.kv != nil {
+		if err := b.kv.reset(); err != nil {
+			log.LogErrorf("[BTree] Reset: tree(%v) err(%v)", b.kv.codec.tree, err)
+		}
+		return
+	}
 	b.Lock()
 	b.tree.Clear(true)
+	b.changes.lose()
 	b.Unlock()
 }
 
+// Release releases the resources held by a snapshot returned by GetTree, it can't be read anymore.
+func (b *BTree) Release() {
+	if b.kv != nil {
+		b.kv.release()
+	}
+}
+
 // Len returns the total number of items in the btree.
 func (b *BTree) Len() (size int) {
+	if b.kv != nil {
+		return b.kv.len()
+	}
 	b.RLock()
 	size = b.tree.Len()
 	b.RUnlock()
@@ -166,7 +255,11 @@ func (b *BTree) Len() (size int) {
 }
 
 // MaxItem returns the largest item in the btree.
+// For a tree kept in a KVStore it scans the whole tree.
 func (b *BTree) MaxItem() BtreeItem {
+	if b.kv != nil {
+		return b.kv.maxItem()
+	}
 	b.RLock()
 	item := b.tree.Max()
 	b.RUnlock()
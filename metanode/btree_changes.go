@@ -0,0 +1,182 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"sync/atomic"
+
+	"github.com/cubefs/cubefs/util/btree"
+)
+
+// changeEntry records that the item of the key was changed by the raft log at the index.
+type changeEntry struct {
+	key   BtreeItem
+	index uint64
+}
+
+func (e *changeEntry) Less(than btree.Item) bool {
+	return e.key.Less(than.(*changeEntry).key)
+}
+
+func (e *changeEntry) Copy() btree.Item {
+	return e
+}
+
+// changeSet records the keys of a BTree changed since the last snapshot, so that
+// only they are written to the delta of the next snapshot. The entries are guarded
+// by the lock of the BTree, a nil changeSet records nothing.
+type changeSet struct {
+	entries *btree.BTree
+	// the snapshots before baseIndex can't be extended by the changes
+	baseIndex uint64
+	index     uint64
+	tracking  int32
+	// the keys less than from are not recorded
+	from BtreeItem
+}
+
+func (c *changeSet) isTracking() bool {
+	return c != nil && atomic.LoadInt32(&c.tracking) == 1
+}
+
+func (c *changeSet) record(key BtreeItem) {
+	if c == nil || (c.from != nil && key.Less(c.from)) {
+		return
+	}
+	c.entries.ReplaceOrInsert(&changeEntry{key: key, index: atomic.LoadUint64(&c.index)})
+}
+
+func (c *changeSet) setTracking(index uint64, on bool) {
+	if c == nil {
+		return
+	}
+	if on {
+		atomic.StoreUint64(&c.index, index)
+		atomic.StoreInt32(&c.tracking, 1)
+		return
+	}
+	atomic.StoreInt32(&c.tracking, 0)
+}
+
+// trim drops the entries of the changes cloned from it which are not changed again, up to the index.
+func (c *changeSet) trim(changes *btree.BTree, index uint64) {
+	changes.Ascend(func(i btree.Item) bool {
+		e := i.(*changeEntry)
+		if e.index > index {
+			return true
+		}
+		if cur := c.entries.Get(e); cur != nil && cur.(*changeEntry) == e {
+			c.entries.Delete(e)
+		}
+		return true
+	})
+}
+
+// lose forgets the changes, the next snapshot is written in full.
+func (c *changeSet) lose() {
+	if c == nil {
+		return
+	}
+	c.entries.Clear(false)
+	c.baseIndex = atomic.LoadUint64(&c.index) + 1
+}
+
+// trackChanges starts recording the changed keys, the snapshots before baseIndex
+// are not extended by the changes.
+func (b *BTree) trackChanges(baseIndex uint64) {
+	if b.kv != nil {
+		return
+	}
+	b.Lock()
+	b.changes = &changeSet{entries: btree.New(defaultBTreeDegree), baseIndex: baseIndex, index: baseIndex}
+	b.Unlock()
+}
+
+// setTracking is set while applying the raft log at the index, the items read are
+// recorded as changed since they may be modified in place.
+func (b *BTree) setTracking(index uint64, on bool) {
+	if b.kv != nil {
+		b.kv.setTracking(on)
+		return
+	}
+	b.changes.setTracking(index, on)
+	b.migration.setTracking(index, on)
+}
+
+func (b *BTree) isTracking() bool {
+	return b.changes.isTracking() || b.migration.isTracking()
+}
+
+func (b *BTree) recordChange(key BtreeItem) {
+	b.changes.record(key)
+	b.migration.record(key)
+}
+
+// cloneChanges returns the changes recorded and the baseIndex, ok is false if the changes are not tracked.
+func (b *BTree) cloneChanges() (changes *btree.BTree, baseIndex uint64, ok bool) {
+	b.Lock()
+	defer b.Unlock()
+	if b.changes == nil {
+		return
+	}
+	return b.changes.entries.Clone(), b.changes.baseIndex, true
+}
+
+// trimChanges drops the changes written to the snapshot of the index.
+func (b *BTree) trimChanges(changes *btree.BTree, index uint64) {
+	b.Lock()
+	defer b.Unlock()
+	if b.changes == nil {
+		return
+	}
+	b.changes.trim(changes, index)
+}
+
+// trackMigration starts recording the changed keys not less than from, they're sent
+// to the partition the range is migrated to.
+func (b *BTree) trackMigration(from BtreeItem) {
+	if b.kv != nil {
+		return
+	}
+	b.Lock()
+	b.migration = &changeSet{entries: btree.New(defaultBTreeDegree), from: from}
+	b.Unlock()
+}
+
+func (b *BTree) stopMigration() {
+	b.Lock()
+	b.migration = nil
+	b.Unlock()
+}
+
+// cloneMigration returns the changes recorded since the migration started, nil if they're not tracked.
+func (b *BTree) cloneMigration() *btree.BTree {
+	b.Lock()
+	defer b.Unlock()
+	if b.migration == nil {
+		return nil
+	}
+	return b.migration.entries.Clone()
+}
+
+// trimMigration drops the changes sent to the target partition, up to the index.
+func (b *BTree) trimMigration(changes *btree.BTree, index uint64) {
+	b.Lock()
+	defer b.Unlock()
+	if b.migration == nil {
+		return
+	}
+	b.migration.trim(changes, index)
+}
@@ -0,0 +1,691 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cubefs/cubefs/util/btree"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Layout of the kv store of a partition:
+//
+//	'i' | gen(4) | tree(1) | item key  ->  marshaled item
+//	'm' | 'a'                          ->  applyID of the flushed items
+//	'm' | 'g'                          ->  generation of the flushed items
+//	'm' | 'c' | tree(1)                ->  number of items of the tree
+//
+// A snapshot from the raft leader is loaded into the next generation, so that the
+// current one keeps serving until the snapshot is complete.
+const (
+	kvItemPrefix byte = 'i'
+	kvMetaPrefix byte = 'm'
+
+	kvTreeInode     byte = 1
+	kvTreeDentry    byte = 2
+	kvTreeExtend    byte = 3
+	kvTreeMultipart byte = 4
+)
+
+const (
+	defaultKVCacheItems = 1 << 16
+	kvLoadBatchSize     = 4096
+)
+
+var (
+	kvApplyIDKey = []byte{kvMetaPrefix, 'a'}
+	kvGenKey     = []byte{kvMetaPrefix, 'g'}
+
+	// max number of clean items cached by each tree of a partition
+	kvCacheItems = defaultKVCacheItems
+
+	errKVTreeReleased = errors.New("kv tree is released")
+)
+
+func kvCountKey(tree byte) []byte {
+	return []byte{kvMetaPrefix, 'c', tree}
+}
+
+func kvGenPrefix(gen uint32) []byte {
+	prefix := make([]byte, 5)
+	prefix[0] = kvItemPrefix
+	binary.BigEndian.PutUint32(prefix[1:], gen)
+	return prefix
+}
+
+func kvTreePrefix(gen uint32, tree byte) []byte {
+	return append(kvGenPrefix(gen), tree)
+}
+
+// kvPrefixEnd returns the smallest key greater than all the keys with the prefix.
+func kvPrefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+func kvUint64Key(v uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, v)
+	return key
+}
+
+// kvCodec encodes the items of a tree, the keys sort in the same order as the items.
+type kvCodec struct {
+	tree      byte
+	key       func(item BtreeItem) []byte
+	marshal   func(item BtreeItem) ([]byte, error)
+	unmarshal func(raw []byte) (BtreeItem, error)
+}
+
+var inodeKVCodec = &kvCodec{
+	tree: kvTreeInode,
+	key: func(item BtreeItem) []byte {
+		return kvUint64Key(item.(*Inode).Inode)
+	},
+	marshal: func(item BtreeItem) ([]byte, error) {
+		return item.(*Inode).Marshal()
+	},
+	unmarshal: func(raw []byte) (BtreeItem, error) {
+		ino := NewInode(0, 0)
+		if err := ino.Unmarshal(raw); err != nil {
+			return nil, err
+		}
+		return ino, nil
+	},
+}
+
+var dentryKVCodec = &kvCodec{
+	tree: kvTreeDentry,
+	key: func(item BtreeItem) []byte {
+		d := item.(*Dentry)
+		return append(kvUint64Key(d.ParentId), d.Name...)
+	},
+	marshal: func(item BtreeItem) ([]byte, error) {
+		return item.(*Dentry).Marshal()
+	},
+	unmarshal: func(raw []byte) (BtreeItem, error) {
+		d := &Dentry{}
+		if err := d.Unmarshal(raw); err != nil {
+			return nil, err
+		}
+		return d, nil
+	},
+}
+
+var extendKVCodec = &kvCodec{
+	tree: kvTreeExtend,
+	key: func(item BtreeItem) []byte {
+		return kvUint64Key(item.(*Extend).inode)
+	},
+	marshal: func(item BtreeItem) ([]byte, error) {
+		return item.(*Extend).Bytes()
+	},
+	unmarshal: func(raw []byte) (BtreeItem, error) {
+		e, err := NewExtendFromBytes(raw)
+		if err != nil {
+			return nil, err
+		}
+		return e, nil
+	},
+}
+
+var multipartKVCodec = &kvCodec{
+	tree: kvTreeMultipart,
+	key: func(item BtreeItem) []byte {
+		// the key is escaped and terminated so that (key, id) pairs sort as the multiparts
+		m := item.(*Multipart)
+		buf := make([]byte, 0, len(m.key)+len(m.id)+2)
+		for i := 0; i < len(m.key); i++ {
+			if m.key[i] == 0 {
+				buf = append(buf, 0, 0xff)
+				continue
+			}
+			buf = append(buf, m.key[i])
+		}
+		buf = append(buf, 0, 1)
+		return append(buf, m.id...)
+	},
+	marshal: func(item BtreeItem) ([]byte, error) {
+		return item.(*Multipart).Bytes()
+	},
+	unmarshal: func(raw []byte) (BtreeItem, error) {
+		return MultipartFromBytes(raw), nil
+	},
+}
+
+// kvEntry is an item of the dirty tree, a deleted entry is the tombstone of the item.
+type kvEntry struct {
+	item    BtreeItem
+	deleted bool
+	seq     uint64
+}
+
+func (e *kvEntry) Less(than btree.Item) bool {
+	return e.item.Less(than.(*kvEntry).item)
+}
+
+// Copy shares the entry between the cloned dirty trees, kvTree copies the item on write instead.
+func (e *kvEntry) Copy() btree.Item {
+	return e
+}
+
+type kvCacheEntry struct {
+	key  string
+	item BtreeItem
+}
+
+// kvCache is a LRU cache of the clean items.
+type kvCache struct {
+	capacity int
+	lru      *list.List
+	items    map[string]*list.Element
+}
+
+func newKVCache(capacity int) *kvCache {
+	return &kvCache{
+		capacity: capacity,
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *kvCache) get(key string) BtreeItem {
+	if elem, ok := c.items[key]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*kvCacheEntry).item
+	}
+	return nil
+}
+
+func (c *kvCache) add(key string, item BtreeItem) {
+	if c.capacity <= 0 {
+		return
+	}
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*kvCacheEntry).item = item
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.lru.PushFront(&kvCacheEntry{key: key, item: item})
+	for c.lru.Len() > c.capacity {
+		elem := c.lru.Back()
+		c.lru.Remove(elem)
+		delete(c.items, elem.Value.(*kvCacheEntry).key)
+	}
+}
+
+func (c *kvCache) remove(key string) {
+	if elem, ok := c.items[key]; ok {
+		c.lru.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+func (c *kvCache) clear() {
+	c.lru.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// kvSnapshotRef shares a KVSnapshot between the views.
+type kvSnapshotRef struct {
+	KVSnapshot
+	refs int32
+}
+
+func (r *kvSnapshotRef) acquire() *kvSnapshotRef {
+	atomic.AddInt32(&r.refs, 1)
+	return r
+}
+
+func (r *kvSnapshotRef) release() {
+	if atomic.AddInt32(&r.refs, -1) == 0 {
+		r.KVSnapshot.Release()
+	}
+}
+
+// kvTree keeps the items of a BTree in a KVStore.
+//
+// The items modified since the last flush are kept in the dirty tree, the clean
+// items are read from the kv store and a bounded number of them is cached. A view
+// returned by GetTree is a clone of the dirty tree on top of a snapshot of the kv
+// store, it is written to the kv store by flush and then trimmed from the dirty tree.
+//
+// The entries of the dirty tree are shared with the views, an item is copied before
+// it's handed out for modification if a view was taken after the item was added.
+type kvTree struct {
+	sync.Mutex
+	kv       KVStore
+	reader   KVReader
+	snap     *kvSnapshotRef // views only
+	codec    *kvCodec
+	gen      uint32
+	prefix   []byte
+	dirty    *btree.BTree
+	cache    *kvCache // live tree only
+	count    int
+	seq      uint64 // increased by each view
+	trims    uint64
+	view     bool
+	tracking int32
+	loading  *KVBatch
+	loadErr  error
+	released bool
+}
+
+func newKVTree(kv KVStore, gen uint32, codec *kvCodec, count int) *kvTree {
+	return &kvTree{
+		kv:     kv,
+		reader: kv,
+		codec:  codec,
+		gen:    gen,
+		prefix: kvTreePrefix(gen, codec.tree),
+		dirty:  btree.New(defaultBTreeDegree),
+		cache:  newKVCache(kvCacheItems),
+		count:  count,
+	}
+}
+
+// newKVLoadTree returns a tree that writes the inserted items to the kv store directly,
+// it's used to load a snapshot and becomes a regular tree after finishLoad.
+func newKVLoadTree(kv KVStore, gen uint32, codec *kvCodec) *kvTree {
+	t := newKVTree(kv, gen, codec, 0)
+	t.loading = NewKVBatch()
+	return t
+}
+
+func (t *kvTree) itemKey(item BtreeItem) []byte {
+	key := t.codec.key(item)
+	buf := make([]byte, 0, len(t.prefix)+len(key))
+	buf = append(buf, t.prefix...)
+	return append(buf, key...)
+}
+
+func (t *kvTree) isTracking() bool {
+	return atomic.LoadInt32(&t.tracking) == 1
+}
+
+func (t *kvTree) setTracking(on bool) {
+	if on {
+		atomic.StoreInt32(&t.tracking, 1)
+		return
+	}
+	atomic.StoreInt32(&t.tracking, 0)
+}
+
+func (t *kvTree) read(key []byte) (item BtreeItem, err error) {
+	t.Lock()
+	reader, released := t.reader, t.released
+	t.Unlock()
+	if released {
+		return nil, errKVTreeReleased
+	}
+	var raw []byte
+	if raw, err = reader.Get(key); err != nil || raw == nil {
+		return
+	}
+	return t.codec.unmarshal(raw)
+}
+
+// lookupMemLocked looks up the dirty tree and the cache, ok reports whether the key is resolved in memory.
+func (t *kvTree) lookupMemLocked(probe *kvEntry, key []byte, track bool) (item BtreeItem, ok bool) {
+	if i := t.dirty.Get(probe); i != nil {
+		e := i.(*kvEntry)
+		if e.deleted {
+			return nil, true
+		}
+		if track && e.seq != t.seq {
+			e = &kvEntry{item: e.item.Copy(), seq: t.seq}
+			t.dirty.ReplaceOrInsert(e)
+		}
+		return e.item, true
+	}
+	if t.cache == nil {
+		return nil, false
+	}
+	if item = t.cache.get(string(key)); item != nil {
+		if track {
+			t.cache.remove(string(key))
+			t.dirty.ReplaceOrInsert(&kvEntry{item: item, seq: t.seq})
+		}
+		return item, true
+	}
+	return nil, false
+}
+
+// lookup returns the item of the key. With track the item is moved into the dirty
+// tree, so that the caller is allowed to modify it in place.
+func (t *kvTree) lookup(key BtreeItem, track bool) BtreeItem {
+	probe := &kvEntry{item: key}
+	k := t.itemKey(key)
+	track = track && !t.view
+	for {
+		t.Lock()
+		if item, ok := t.lookupMemLocked(probe, k, track); ok {
+			t.Unlock()
+			return item
+		}
+		trims := t.trims
+		t.Unlock()
+
+		item, err := t.read(k)
+		if err != nil {
+			log.LogErrorf("[kvTree] lookup: tree(%v) gen(%v) key(%v) err(%v)", t.codec.tree, t.gen, key, err)
+			return nil
+		}
+
+		t.Lock()
+		if cur, ok := t.lookupMemLocked(probe, k, track); ok {
+			t.Unlock()
+			return cur
+		}
+		if t.trims != trims {
+			// the item was flushed while reading, it may be stale
+			t.Unlock()
+			continue
+		}
+		if item != nil {
+			if track {
+				t.dirty.ReplaceOrInsert(&kvEntry{item: item, seq: t.seq})
+			} else if t.cache != nil {
+				t.cache.add(string(k), item)
+			}
+		}
+		t.Unlock()
+		return item
+	}
+}
+
+func (t *kvTree) replaceOrInsert(item BtreeItem, replace bool) (BtreeItem, bool) {
+	if t.loading != nil {
+		t.load(item)
+		return nil, true
+	}
+	existing := t.lookup(item, !replace)
+	if !replace && existing != nil {
+		return existing, false
+	}
+	t.Lock()
+	t.dirty.ReplaceOrInsert(&kvEntry{item: item, seq: t.seq})
+	if t.cache != nil {
+		t.cache.remove(string(t.itemKey(item)))
+	}
+	if existing == nil {
+		t.count++
+	}
+	t.Unlock()
+	return existing, true
+}
+
+func (t *kvTree) load(item BtreeItem) {
+	t.Lock()
+	defer t.Unlock()
+	if t.loadErr != nil {
+		return
+	}
+	raw, err := t.codec.marshal(item)
+	if err != nil {
+		t.loadErr = err
+		return
+	}
+	t.loading.Put(t.itemKey(item), raw)
+	t.count++
+	if t.loading.Len() >= kvLoadBatchSize {
+		t.loadErr = t.kv.Write(t.loading, false)
+		t.loading = NewKVBatch()
+	}
+}
+
+// finishLoad writes the rest of the loaded items.
+func (t *kvTree) finishLoad() (err error) {
+	t.Lock()
+	defer t.Unlock()
+	if err = t.loadErr; err == nil && t.loading.Len() > 0 {
+		err = t.kv.Write(t.loading, false)
+	}
+	t.loading = nil
+	return
+}
+
+func (t *kvTree) delete(key BtreeItem) BtreeItem {
+	return t.deleteIf(key, nil)
+}
+
+func (t *kvTree) deleteIf(key BtreeItem, fn func(item BtreeItem) bool) BtreeItem {
+	existing := t.lookup(key, fn != nil)
+	if existing == nil || (fn != nil && !fn(existing)) {
+		return nil
+	}
+	t.Lock()
+	t.dirty.ReplaceOrInsert(&kvEntry{item: existing, deleted: true, seq: t.seq})
+	if t.cache != nil {
+		t.cache.remove(string(t.itemKey(existing)))
+	}
+	t.count--
+	t.Unlock()
+	return existing
+}
+
+// ascendRange calls fn for the items in [start, end), a nil start or end means no bound.
+func (t *kvTree) ascendRange(start, end BtreeItem, fn func(item BtreeItem) bool) {
+	defer runtime.KeepAlive(t)
+
+	t.Lock()
+	if t.released {
+		t.Unlock()
+		log.LogErrorf("[kvTree] ascendRange: tree(%v) gen(%v) err(%v)", t.codec.tree, t.gen, errKVTreeReleased)
+		return
+	}
+	dirty := t.dirty.Clone()
+	reader := t.reader
+	var snap KVSnapshot
+	if !t.view {
+		snap = t.kv.NewSnapshot()
+		reader = snap
+	}
+	t.Unlock()
+	if snap != nil {
+		defer snap.Release()
+	}
+
+	lower, upper := t.prefix, kvPrefixEnd(t.prefix)
+	if start != nil {
+		lower = t.itemKey(start)
+	}
+	if end != nil {
+		upper = t.itemKey(end)
+	}
+	it := reader.NewIterator(lower, upper)
+	defer it.Close()
+
+	var stopped bool
+	// emit calls fn for the items of the kv store before the limit
+	emit := func(limit []byte) bool {
+		for ; it.Valid(); it.Next() {
+			if limit != nil && bytes.Compare(it.Key(), limit) >= 0 {
+				return true
+			}
+			item, err := t.codec.unmarshal(it.Value())
+			if err != nil {
+				log.LogErrorf("[kvTree] ascendRange: tree(%v) gen(%v) key(%v) err(%v)", t.codec.tree, t.gen, it.Key(), err)
+				return false
+			}
+			if !fn(item) {
+				return false
+			}
+		}
+		return true
+	}
+	visit := func(i btree.Item) bool {
+		e := i.(*kvEntry)
+		key := t.itemKey(e.item)
+		if !emit(key) {
+			stopped = true
+			return false
+		}
+		if it.Valid() && bytes.Equal(it.Key(), key) {
+			it.Next()
+		}
+		if !e.deleted && !fn(e.item) {
+			stopped = true
+			return false
+		}
+		return true
+	}
+	switch {
+	case start == nil && end == nil:
+		dirty.Ascend(visit)
+	case end == nil:
+		dirty.AscendGreaterOrEqual(&kvEntry{item: start}, visit)
+	case start == nil:
+		dirty.AscendLessThan(&kvEntry{item: end}, visit)
+	default:
+		dirty.AscendRange(&kvEntry{item: start}, &kvEntry{item: end}, visit)
+	}
+	if !stopped {
+		emit(nil)
+	}
+	if err := it.Err(); err != nil {
+		log.LogErrorf("[kvTree] ascendRange: tree(%v) gen(%v) err(%v)", t.codec.tree, t.gen, err)
+	}
+}
+
+func (t *kvTree) maxItem() (max BtreeItem) {
+	t.ascendRange(nil, nil, func(item BtreeItem) bool {
+		max = item
+		return true
+	})
+	return
+}
+
+func (t *kvTree) len() int {
+	t.Lock()
+	defer t.Unlock()
+	return t.count
+}
+
+// newView returns a consistent read view of the tree.
+func (t *kvTree) newView() *kvTree {
+	t.Lock()
+	t.seq++
+	v := &kvTree{
+		kv:     t.kv,
+		codec:  t.codec,
+		gen:    t.gen,
+		prefix: t.prefix,
+		dirty:  t.dirty.Clone(),
+		count:  t.count,
+		seq:    t.seq,
+		view:   true,
+	}
+	if t.view {
+		v.snap = t.snap.acquire()
+	} else {
+		v.snap = &kvSnapshotRef{KVSnapshot: t.kv.NewSnapshot(), refs: 1}
+	}
+	v.reader = v.snap
+	t.Unlock()
+	runtime.SetFinalizer(v, (*kvTree).release)
+	return v
+}
+
+// release releases the kv snapshot of a view, the view can't be read anymore.
+func (t *kvTree) release() {
+	t.Lock()
+	defer t.Unlock()
+	if !t.view || t.released {
+		return
+	}
+	t.released = true
+	t.reader = nil
+	t.snap.release()
+}
+
+func (t *kvTree) reset() (err error) {
+	t.Lock()
+	defer t.Unlock()
+	t.dirty.Clear(true)
+	if t.cache != nil {
+		t.cache.clear()
+	}
+	t.count = 0
+	if t.view {
+		return
+	}
+	batch := NewKVBatch()
+	batch.DeleteRange(t.prefix, kvPrefixEnd(t.prefix))
+	return t.kv.Write(batch, true)
+}
+
+// flush appends the items of the view to the batch.
+func (t *kvTree) flush(batch *KVBatch) (err error) {
+	t.dirty.Ascend(func(i btree.Item) bool {
+		e := i.(*kvEntry)
+		key := t.itemKey(e.item)
+		if e.deleted {
+			batch.Delete(key)
+			return true
+		}
+		var raw []byte
+		if raw, err = t.codec.marshal(e.item); err != nil {
+			return false
+		}
+		batch.Put(key, raw)
+		return true
+	})
+	if err != nil {
+		return
+	}
+	batch.Put(kvCountKey(t.codec.tree), kvUint64Key(uint64(t.count)))
+	return
+}
+
+// trim drops the entries flushed with the view from the dirty tree.
+func (t *kvTree) trim(v *kvTree) {
+	t.Lock()
+	defer t.Unlock()
+	if t.gen != v.gen {
+		return
+	}
+	// the items are shared with the views taken after v, they can't be handed out from the cache
+	latest := v.seq == t.seq
+	v.dirty.Ascend(func(i btree.Item) bool {
+		e := i.(*kvEntry)
+		if cur := t.dirty.Get(e); cur == nil || cur.(*kvEntry) != e {
+			return true
+		}
+		t.dirty.Delete(e)
+		key := string(t.itemKey(e.item))
+		if !e.deleted && latest {
+			t.cache.add(key, e.item)
+		} else {
+			t.cache.remove(key)
+		}
+		return true
+	})
+	t.trims++
+}
@@ -0,0 +1,269 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memKVStore is a KVStore kept in a map for the tests.
+type memKVStore struct {
+	sync.RWMutex
+	data map[string][]byte
+}
+
+func newMemKVStore() *memKVStore {
+	return &memKVStore{data: make(map[string][]byte)}
+}
+
+type memKVReader struct {
+	data map[string][]byte
+}
+
+func (r *memKVReader) Get(key []byte) ([]byte, error) {
+	return r.data[string(key)], nil
+}
+
+func (r *memKVReader) NewIterator(start, end []byte) KVIterator {
+	it := &memKVIterator{data: r.data}
+	for k := range r.data {
+		if bytes.Compare([]byte(k), start) >= 0 && (end == nil || bytes.Compare([]byte(k), end) < 0) {
+			it.keys = append(it.keys, k)
+		}
+	}
+	sort.Strings(it.keys)
+	return it
+}
+
+func (r *memKVReader) Release() {}
+
+type memKVIterator struct {
+	data map[string][]byte
+	keys []string
+}
+
+func (it *memKVIterator) Valid() bool   { return len(it.keys) > 0 }
+func (it *memKVIterator) Key() []byte   { return []byte(it.keys[0]) }
+func (it *memKVIterator) Value() []byte { return it.data[it.keys[0]] }
+func (it *memKVIterator) Next()         { it.keys = it.keys[1:] }
+func (it *memKVIterator) Err() error    { return nil }
+func (it *memKVIterator) Close()        {}
+
+func (s *memKVStore) Get(key []byte) ([]byte, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.data[string(key)], nil
+}
+
+func (s *memKVStore) NewIterator(start, end []byte) KVIterator {
+	return s.NewSnapshot().NewIterator(start, end)
+}
+
+func (s *memKVStore) Write(batch *KVBatch, sync bool) error {
+	s.Lock()
+	defer s.Unlock()
+	for _, op := range batch.Ops {
+		switch op.Type {
+		case KVOpPut:
+			s.data[string(op.Key)] = append([]byte{}, op.Value...)
+		case KVOpDelete:
+			delete(s.data, string(op.Key))
+		case KVOpDeleteRange:
+			for k := range s.data {
+				if bytes.Compare([]byte(k), op.Key) >= 0 && bytes.Compare([]byte(k), op.Value) < 0 {
+					delete(s.data, k)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *memKVStore) NewSnapshot() KVSnapshot {
+	s.RLock()
+	defer s.RUnlock()
+	data := make(map[string][]byte, len(s.data))
+	for k, v := range s.data {
+		data[k] = v
+	}
+	return &memKVReader{data: data}
+}
+
+func (s *memKVStore) Close() error {
+	return nil
+}
+
+func collectInodes(tree *BTree) (inos []uint64) {
+	tree.Ascend(func(i BtreeItem) bool {
+		inos = append(inos, i.(*Inode).Inode)
+		return true
+	})
+	return
+}
+
+func flushKVTree(t *testing.T, kv KVStore, tree *BTree) {
+	view := tree.GetTree()
+	batch := NewKVBatch()
+	require.NoError(t, view.kv.flush(batch))
+	require.NoError(t, kv.Write(batch, true))
+	tree.kv.trim(view.kv)
+	view.Release()
+}
+
+func TestKVBtree(t *testing.T) {
+	kv := newMemKVStore()
+	tree := newKVBtree(newKVTree(kv, 0, inodeKVCodec, 0))
+	for _, ino := range []uint64{3, 1, 2} {
+		_, ok := tree.ReplaceOrInsert(NewInode(ino, 0), false)
+		require.True(t, ok)
+	}
+	_, ok := tree.ReplaceOrInsert(NewInode(1, 0), false)
+	require.False(t, ok)
+	require.Equal(t, 3, tree.Len())
+	require.Equal(t, []uint64{1, 2, 3}, collectInodes(tree))
+
+	flushKVTree(t, kv, tree)
+	require.Equal(t, 0, tree.kv.dirty.Len())
+	require.Equal(t, []uint64{1, 2, 3}, collectInodes(tree))
+
+	// the items are merged with the ones in the kv store
+	tree.ReplaceOrInsert(NewInode(4, 0), true)
+	require.NotNil(t, tree.Delete(NewInode(2, 0)))
+	require.Nil(t, tree.Get(NewInode(2, 0)))
+	require.Equal(t, []uint64{1, 3, 4}, collectInodes(tree))
+	require.Equal(t, 3, tree.Len())
+
+	var inos []uint64
+	tree.AscendRange(NewInode(2, 0), NewInode(4, 0), func(i BtreeItem) bool {
+		inos = append(inos, i.(*Inode).Inode)
+		return true
+	})
+	require.Equal(t, []uint64{3}, inos)
+	require.Equal(t, uint64(4), tree.MaxItem().(*Inode).Inode)
+
+	require.Nil(t, tree.DeleteIf(NewInode(3, 0), func(i BtreeItem) bool { return false }))
+	require.NotNil(t, tree.DeleteIf(NewInode(3, 0), func(i BtreeItem) bool { return true }))
+	require.Equal(t, []uint64{1, 4}, collectInodes(tree))
+
+	flushKVTree(t, kv, tree)
+	reopened := newKVBtree(newKVTree(kv, 0, inodeKVCodec, tree.Len()))
+	require.Equal(t, []uint64{1, 4}, collectInodes(reopened))
+	require.Equal(t, 2, reopened.Len())
+}
+
+func TestKVBtreeView(t *testing.T) {
+	kv := newMemKVStore()
+	tree := newKVBtree(newKVTree(kv, 0, inodeKVCodec, 0))
+	tree.ReplaceOrInsert(NewInode(1, 0), true)
+	flushKVTree(t, kv, tree)
+	tree.ReplaceOrInsert(NewInode(2, 0), true)
+
+	view := tree.GetTree()
+	defer view.Release()
+
+	tree.kv.setTracking(true)
+	tree.Get(NewInode(1, 0)).(*Inode).Size = 100
+	tree.Get(NewInode(2, 0)).(*Inode).Size = 200
+	tree.kv.setTracking(false)
+	tree.ReplaceOrInsert(NewInode(3, 0), true)
+	tree.Delete(NewInode(1, 0))
+
+	// the view is not affected by the later modifications
+	require.Equal(t, []uint64{1, 2}, collectInodes(view))
+	require.Equal(t, uint64(0), view.Get(NewInode(1, 0)).(*Inode).Size)
+	require.Equal(t, uint64(0), view.Get(NewInode(2, 0)).(*Inode).Size)
+	require.Equal(t, 2, view.Len())
+	require.Equal(t, uint64(200), tree.Get(NewInode(2, 0)).(*Inode).Size)
+	require.Equal(t, []uint64{2, 3}, collectInodes(tree))
+
+	// the entries modified after the view are kept in the dirty tree
+	batch := NewKVBatch()
+	require.NoError(t, view.kv.flush(batch))
+	require.NoError(t, kv.Write(batch, true))
+	tree.kv.trim(view.kv)
+	require.Equal(t, 3, tree.kv.dirty.Len())
+	require.Equal(t, []uint64{2, 3}, collectInodes(tree))
+	require.Equal(t, uint64(200), tree.Get(NewInode(2, 0)).(*Inode).Size)
+}
+
+func TestKVBtreeDentryOrder(t *testing.T) {
+	kv := newMemKVStore()
+	tree := newKVBtree(newKVTree(kv, 0, dentryKVCodec, 0))
+	names := []string{"b", "a", "ab", "a\x00"}
+	for i, name := range names {
+		tree.ReplaceOrInsert(&Dentry{ParentId: 1, Name: name, Inode: uint64(i + 10)}, true)
+	}
+	tree.ReplaceOrInsert(&Dentry{ParentId: 2, Name: "a", Inode: 20}, true)
+	flushKVTree(t, kv, tree)
+
+	var got []string
+	tree.AscendRange(&Dentry{ParentId: 1}, &Dentry{ParentId: 2}, func(i BtreeItem) bool {
+		got = append(got, i.(*Dentry).Name)
+		return true
+	})
+	require.Equal(t, []string{"a", "a\x00", "ab", "b"}, got)
+	require.Equal(t, uint64(13), tree.Get(&Dentry{ParentId: 1, Name: "a\x00"}).(*Dentry).Inode)
+}
+
+func TestKVBtreeMultipartOrder(t *testing.T) {
+	kv := newMemKVStore()
+	tree := newKVBtree(newKVTree(kv, 0, multipartKVCodec, 0))
+	parts := []*Multipart{
+		{key: "a", id: "2"},
+		{key: "a\x00b", id: "1"},
+		{key: "a", id: "1"},
+		{key: "ab", id: "0"},
+	}
+	for _, m := range parts {
+		tree.ReplaceOrInsert(m, true)
+	}
+	var want []string
+	tree.Ascend(func(i BtreeItem) bool {
+		want = append(want, i.(*Multipart).key+"/"+i.(*Multipart).id)
+		return true
+	})
+	flushKVTree(t, kv, tree)
+
+	var got []string
+	tree.Ascend(func(i BtreeItem) bool {
+		got = append(got, i.(*Multipart).key+"/"+i.(*Multipart).id)
+		return true
+	})
+	require.Equal(t, want, got)
+}
+
+func TestKVBtreeLoad(t *testing.T) {
+	kv := newMemKVStore()
+	tree := newKVBtree(newKVTree(kv, 0, inodeKVCodec, 0))
+	tree.ReplaceOrInsert(NewInode(1, 0), true)
+	flushKVTree(t, kv, tree)
+
+	loadTree := newKVBtree(newKVLoadTree(kv, 1, inodeKVCodec))
+	for ino := uint64(10); ino < 10+kvLoadBatchSize+10; ino++ {
+		loadTree.ReplaceOrInsert(NewInode(ino, 0), true)
+	}
+	require.NoError(t, finishKVSnapshotTrees(loadTree))
+	require.Equal(t, kvLoadBatchSize+10, loadTree.Len())
+	require.Equal(t, uint64(10), loadTree.kv.lookup(NewInode(10, 0), false).(*Inode).Inode)
+	require.Nil(t, loadTree.Get(NewInode(1, 0)))
+
+	// the generations don't share the items
+	require.Equal(t, []uint64{1}, collectInodes(tree))
+}
@@ -0,0 +1,218 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"sort"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+const (
+	// changeFeedCapacity is the number of the recent changes kept at least, the consumer falling further
+	// behind is told the feed is truncated.
+	changeFeedCapacity = 1 << 16
+	// changeFeedStoreCapacity is the number of the recent changes stored with the snapshot at most, so that
+	// the consumers not far behind keep reading from their apply ids after a restart.
+	changeFeedStoreCapacity = 1 << 14
+)
+
+// changeFeed records the metadata changes made by the raft log entries applied to the partition, numbered by
+// the apply id of the entry. The changes are recorded only while the entry is applied, those made by loading
+// the partition are not. The recent changes are stored with the snapshot, and the changes after the apply id
+// of the snapshot loaded are recorded again as the raft log is replayed.
+type changeFeed struct {
+	sync.Mutex
+	// the changes after floor up to applied are kept
+	floor    uint64
+	applied  uint64
+	applying uint64
+	events   []*proto.ChangeEvent
+	// the changes loaded from the snapshot, which are kept on reset to the apply id of the snapshot
+	loaded *changeFeedTail
+	// closed once a raft log entry is applied, if anyone is waiting
+	wait    chan struct{}
+	waiting bool
+}
+
+// changeFeedTail is the recent changes stored with the snapshot, those after Floor are kept.
+type changeFeedTail struct {
+	Floor  uint64               `json:"floor"`
+	Events []*proto.ChangeEvent `json:"events"`
+}
+
+func (t *changeFeedTail) Marshal() (buf []byte, crc uint32, err error) {
+	if buf, err = json.Marshal(t); err != nil {
+		return
+	}
+	crc = crc32.ChecksumIEEE(buf)
+	return
+}
+
+func (t *changeFeedTail) UnMarshal(data []byte) error {
+	return json.Unmarshal(data, t)
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{
+		wait: make(chan struct{}),
+	}
+}
+
+// begin is called before the raft log entry at the index is applied.
+func (f *changeFeed) begin(index uint64) {
+	f.Lock()
+	f.applying = index
+	f.Unlock()
+}
+
+// end is called after the raft log entry at the index is applied.
+func (f *changeFeed) end(index uint64) {
+	f.Lock()
+	f.applying = 0
+	if index > f.applied {
+		f.applied = index
+	}
+	// the oldest changes are dropped once the entry is applied, so that the changes of an entry are never
+	// split however many the entry made
+	if len(f.events) > 2*changeFeedCapacity {
+		if from := changeFeedKeepFrom(f.events, changeFeedCapacity); from > 0 {
+			f.floor = f.events[from-1].ApplyID
+			f.events = append([]*proto.ChangeEvent(nil), f.events[from:]...)
+		}
+	}
+	f.wakeLocked()
+	f.Unlock()
+}
+
+// changeFeedKeepFrom returns the index of the events to keep from, so that at least keep of them are kept
+// unless fewer are recorded. The changes of a raft log entry are kept or dropped together, so more are kept
+// if the index falls within an entry, and the last entry is always kept.
+func changeFeedKeepFrom(events []*proto.ChangeEvent, keep int) int {
+	if len(events) <= keep {
+		return 0
+	}
+	applyID := events[len(events)-keep].ApplyID
+	return sort.Search(len(events), func(i int) bool {
+		return events[i].ApplyID >= applyID
+	})
+}
+
+// reset forgets the changes up to the apply id, e.g. the partition is loaded from the snapshot, except those
+// loaded from the snapshot of the apply id.
+func (f *changeFeed) reset(applyID uint64) {
+	f.Lock()
+	f.events = nil
+	f.floor = applyID
+	f.applied = applyID
+	if tail := f.loaded; tail != nil && tail.Floor <= applyID &&
+		(len(tail.Events) == 0 || tail.Events[len(tail.Events)-1].ApplyID <= applyID) {
+		f.floor = tail.Floor
+		f.events = tail.Events
+	}
+	f.loaded = nil
+	f.wakeLocked()
+	f.Unlock()
+}
+
+// load keeps the changes loaded from the snapshot until the feed is reset to the apply id of the snapshot.
+func (f *changeFeed) load(tail *changeFeedTail) {
+	f.Lock()
+	f.loaded = tail
+	f.Unlock()
+}
+
+// tail returns the recent changes up to the apply id to store with the snapshot of the apply id.
+func (f *changeFeed) tail(applyID uint64) *changeFeedTail {
+	f.Lock()
+	defer f.Unlock()
+	end := sort.Search(len(f.events), func(i int) bool {
+		return f.events[i].ApplyID > applyID
+	})
+	events := f.events[:end]
+	tail := &changeFeedTail{Floor: f.floor}
+	from := changeFeedKeepFrom(events, changeFeedStoreCapacity)
+	if from > 0 {
+		tail.Floor = events[from-1].ApplyID
+	}
+	// the events are not changed once recorded
+	tail.Events = append([]*proto.ChangeEvent(nil), events[from:]...)
+	return tail
+}
+
+func (f *changeFeed) wakeLocked() {
+	if f.waiting {
+		close(f.wait)
+		f.wait = make(chan struct{})
+		f.waiting = false
+	}
+}
+
+func (f *changeFeed) record(event *proto.ChangeEvent) {
+	f.Lock()
+	defer f.Unlock()
+	if f.applying == 0 {
+		return
+	}
+	event.ApplyID = f.applying
+	f.events = append(f.events, event)
+}
+
+func (f *changeFeed) inode(typ proto.ChangeType, ino uint64) {
+	f.record(&proto.ChangeEvent{Type: typ, Inode: ino})
+}
+
+func (f *changeFeed) dentry(typ proto.ChangeType, d *Dentry) {
+	f.record(&proto.ChangeEvent{Type: typ, Inode: d.Inode, ParentID: d.ParentId, Name: d.Name})
+}
+
+func (f *changeFeed) xattr(typ proto.ChangeType, extend *Extend) {
+	keys := make([]string, 0, len(extend.dataMap))
+	for key := range extend.dataMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	f.record(&proto.ChangeEvent{Type: typ, Inode: extend.inode, Keys: keys})
+}
+
+// since returns the changes after the apply id, at most limit of them unless a raft log entry made more, and
+// the apply id to read from next. If the changes are not kept any more, truncated is set with the apply id
+// of the oldest changes kept. If nothing is applied after the apply id, the channel closed on the next
+// entry applied is returned.
+func (f *changeFeed) since(applyID uint64, limit int) (events []*proto.ChangeEvent, next uint64, truncated bool,
+	wait <-chan struct{}) {
+	f.Lock()
+	defer f.Unlock()
+	if applyID < f.floor {
+		return nil, f.floor, true, nil
+	}
+	if applyID >= f.applied {
+		f.waiting = true
+		return nil, applyID, false, f.wait
+	}
+	i := sort.Search(len(f.events), func(i int) bool {
+		return f.events[i].ApplyID > applyID
+	})
+	for ; i < len(f.events); i++ {
+		if len(events) > 0 && len(events) >= limit && f.events[i].ApplyID != events[len(events)-1].ApplyID {
+			return events, events[len(events)-1].ApplyID, false, nil
+		}
+		events = append(events, f.events[i])
+	}
+	return events, f.applied, false, nil
+}
@@ -0,0 +1,218 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeFeed(t *testing.T) {
+	f := newChangeFeed()
+	f.reset(10)
+	events, next, truncated, wait := f.since(5, 10)
+	require.True(t, truncated)
+	require.Equal(t, uint64(10), next)
+	require.Nil(t, wait)
+
+	events, next, truncated, wait = f.since(10, 10)
+	require.False(t, truncated)
+	require.Empty(t, events)
+	require.NotNil(t, wait)
+
+	// the changes made out of applying are not recorded
+	f.inode(proto.ChangeSetAttr, 100)
+	f.begin(11)
+	f.inode(proto.ChangeCreateInode, 101)
+	f.dentry(proto.ChangeCreateDentry, &Dentry{ParentId: 1, Name: "a", Inode: 101})
+	f.end(11)
+	select {
+	case <-wait:
+	default:
+		t.Fatal("the consumer is not woken up")
+	}
+	f.begin(12)
+	f.end(12)
+	f.begin(13)
+	f.inode(proto.ChangeUnlinkInode, 101)
+	f.end(13)
+
+	// the changes of an entry are returned together
+	events, next, _, _ = f.since(10, 1)
+	require.Len(t, events, 2)
+	require.Equal(t, uint64(11), next)
+	require.Equal(t, uint64(11), events[1].ApplyID)
+	require.Equal(t, "a", events[1].Name)
+	events, next, _, _ = f.since(next, 10)
+	require.Len(t, events, 1)
+	require.Equal(t, proto.ChangeUnlinkInode, events[0].Type)
+	require.Equal(t, uint64(13), events[0].ApplyID)
+	require.Equal(t, uint64(13), next)
+
+	// the oldest changes are dropped
+	for i := uint64(0); i <= 2*changeFeedCapacity; i++ {
+		f.begin(14 + i)
+		f.inode(proto.ChangeSetAttr, i)
+		f.end(14 + i)
+	}
+	_, next, truncated, _ = f.since(13, 10)
+	require.True(t, truncated)
+	events, _, truncated, _ = f.since(next, 1)
+	require.False(t, truncated)
+	require.Equal(t, next+1, events[0].ApplyID)
+}
+
+func TestChangeFeedLargeEntry(t *testing.T) {
+	f := newChangeFeed()
+	f.reset(10)
+	f.begin(11)
+	f.inode(proto.ChangeSetAttr, 1)
+	f.end(11)
+
+	// the changes of an entry making more than the capacity are kept together
+	f.begin(12)
+	for i := uint64(0); i <= 2*changeFeedCapacity; i++ {
+		f.inode(proto.ChangeCreateInode, i)
+	}
+	f.end(12)
+	events, next, truncated, _ := f.since(11, 1)
+	require.False(t, truncated)
+	require.Equal(t, uint64(12), next)
+	require.Len(t, events, 2*changeFeedCapacity+1)
+	require.Equal(t, uint64(12), events[0].ApplyID)
+
+	// the entries before are dropped, the large one is dropped once enough are applied after it
+	_, next, truncated, _ = f.since(10, 1)
+	require.True(t, truncated)
+	require.Equal(t, uint64(11), next)
+	for i := uint64(0); i < changeFeedCapacity; i++ {
+		f.begin(13 + i)
+		f.inode(proto.ChangeSetAttr, i)
+		f.end(13 + i)
+	}
+	_, next, truncated, _ = f.since(11, 1)
+	require.True(t, truncated)
+	require.Equal(t, uint64(12), next)
+	require.Len(t, f.events, changeFeedCapacity)
+}
+
+func TestChangeFeedTail(t *testing.T) {
+	f := newChangeFeed()
+	f.reset(10)
+	for i := uint64(0); i < changeFeedStoreCapacity+10; i++ {
+		f.begin(11 + i)
+		f.inode(proto.ChangeSetAttr, i)
+		f.end(11 + i)
+	}
+	applied := uint64(10 + changeFeedStoreCapacity + 10)
+
+	// the recent changes up to the apply id are stored
+	tail := f.tail(applied - 5)
+	require.Len(t, tail.Events, changeFeedStoreCapacity)
+	require.Equal(t, tail.Floor+1, tail.Events[0].ApplyID)
+	require.Equal(t, applied-5, tail.Events[len(tail.Events)-1].ApplyID)
+	data, crc, err := tail.Marshal()
+	require.NoError(t, err)
+	require.NotZero(t, crc)
+	loaded := &changeFeedTail{}
+	require.NoError(t, loaded.UnMarshal(data))
+	require.Equal(t, tail, loaded)
+
+	// kept on reset to the apply id of the snapshot
+	f = newChangeFeed()
+	f.load(loaded)
+	f.reset(applied - 5)
+	events, next, truncated, _ := f.since(applied-10, 10)
+	require.False(t, truncated)
+	require.Len(t, events, 5)
+	require.Equal(t, applied-5, next)
+	_, _, truncated, _ = f.since(loaded.Floor-1, 10)
+	require.True(t, truncated)
+
+	// forgotten if not of the apply id, and once reset
+	f.load(loaded)
+	f.reset(applied - 6)
+	_, _, truncated, _ = f.since(applied-10, 10)
+	require.True(t, truncated)
+	f.reset(applied - 5)
+	_, _, truncated, _ = f.since(applied-10, 10)
+	require.True(t, truncated)
+}
+
+func TestReadChangeFeed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testReadChangeFeed/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+
+	mp := newMigrateTestPartition(t, ctrl, rootDir, 1, 1, 1000)
+	mp.inodeTree.ReplaceOrInsert(NewInode(1, uint32(os.ModeDir)), true)
+	mp.inodeTree.ReplaceOrInsert(NewInode(10, 0), true)
+
+	read := func(applyID uint64, limit int) *proto.ReadChangeFeedResponse {
+		p := &Packet{}
+		require.NoError(t, mp.ReadChangeFeed(&proto.ReadChangeFeedRequest{ApplyID: applyID, Limit: limit}, p))
+		resp := &proto.ReadChangeFeedResponse{}
+		require.NoError(t, p.UnmarshalData(resp))
+		return resp
+	}
+
+	submitTestDentry(t, mp, opFSMCreateDentry, &Dentry{ParentId: 1, Name: "f", Inode: 10})
+	resp := read(0, 0)
+	require.False(t, resp.Truncated)
+	require.Equal(t, uint64(1), resp.ApplyID)
+	require.Len(t, resp.Events, 1)
+	require.Equal(t, proto.ChangeCreateDentry, resp.Events[0].Type)
+	require.Equal(t, uint64(10), resp.Events[0].Inode)
+
+	p := &Packet{}
+	mp.SetXAttr(&proto.SetXAttrRequest{Inode: 10, Key: "user.k", Value: "v"}, p)
+	require.Equal(t, proto.OpOk, p.ResultCode)
+	submitTestDentry(t, mp, opFSMDeleteDentry, &Dentry{ParentId: 1, Name: "f"})
+	resp = read(resp.ApplyID, 1)
+	require.Equal(t, uint64(2), resp.ApplyID)
+	require.Equal(t, proto.ChangeSetXAttr, resp.Events[0].Type)
+	require.Equal(t, []string{"user.k"}, resp.Events[0].Keys)
+	resp = read(resp.ApplyID, 0)
+	require.Equal(t, uint64(3), resp.ApplyID)
+	require.Equal(t, proto.ChangeDeleteDentry, resp.Events[0].Type)
+	require.Equal(t, uint64(10), resp.Events[0].Inode)
+	require.Equal(t, "f", resp.Events[0].Name)
+
+	// the cursors are kept across the restart
+	mp.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+	mp.mqMgr = NewQuotaManager(mp.config.VolName, mp.config.PartitionId)
+	msg := newTestStoreMsg(mp, mp.applyID)
+	msg.feed = mp.feed.tail(mp.applyID)
+	require.NoError(t, mp.store(msg))
+	loaded := newMigrateTestPartition(t, ctrl, rootDir, 1, 1, 1000)
+	loaded.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+	loaded.mqMgr = NewQuotaManager(mp.config.VolName, mp.config.PartitionId)
+	require.NoError(t, loaded.LoadSnapshot(path.Join(rootDir, snapshotDir)))
+	loaded.feed.reset(loaded.getApplyID())
+	mp = loaded
+	resp = read(1, 0)
+	require.False(t, resp.Truncated)
+	require.Equal(t, uint64(3), resp.ApplyID)
+	require.Len(t, resp.Events, 2)
+	require.Equal(t, proto.ChangeSetXAttr, resp.Events[0].Type)
+	require.Equal(t, proto.ChangeDeleteDentry, resp.Events[1].Type)
+}
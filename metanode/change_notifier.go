@@ -0,0 +1,104 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"sync"
+	"time"
+)
+
+// changeNotifierCapacity is the number of the recent changes kept, the watcher falling further behind is reset.
+const changeNotifierCapacity = 1 << 16
+
+// changeNotifier records the inodes changed in the partition, which are watched by the clients caching
+// the metadata. The changes are recorded by each replica as they are applied, and are not persisted:
+// the epoch is renewed once the replica is restarted or the partition is reloaded from the snapshot,
+// so that the watchers are reset.
+type changeNotifier struct {
+	sync.Mutex
+	epoch uint64
+	seq   uint64
+	// the inode changed at the seq is recorded in ring[seq%changeNotifierCapacity]
+	ring []uint64
+	// closed once there are new changes, if anyone is waiting
+	wait    chan struct{}
+	waiting bool
+}
+
+func newChangeNotifier() *changeNotifier {
+	return &changeNotifier{
+		epoch: uint64(time.Now().UnixNano()),
+		ring:  make([]uint64, changeNotifierCapacity),
+		wait:  make(chan struct{}),
+	}
+}
+
+// notify records the inode as changed.
+func (n *changeNotifier) notify(ino uint64) {
+	n.Lock()
+	n.seq++
+	n.ring[n.seq%changeNotifierCapacity] = ino
+	n.wakeLocked()
+	n.Unlock()
+}
+
+// reset forgets the changes, e.g. the partition is reloaded from the snapshot.
+func (n *changeNotifier) reset() {
+	n.Lock()
+	n.epoch++
+	n.seq = 0
+	n.wakeLocked()
+	n.Unlock()
+}
+
+func (n *changeNotifier) wakeLocked() {
+	if n.waiting {
+		close(n.wait)
+		n.wait = make(chan struct{})
+		n.waiting = false
+	}
+}
+
+// since returns the distinct inodes changed after the seq in the epoch, at most limit of them, and the seq
+// of the last change returned. If the changes are not known any more, reset is set with the current seq.
+// If nothing has changed, the channel closed on the next change is returned.
+func (n *changeNotifier) since(epoch, seq uint64, limit int) (inodes []uint64, curEpoch, curSeq uint64,
+	reset bool, wait <-chan struct{}) {
+	n.Lock()
+	defer n.Unlock()
+	curEpoch, curSeq = n.epoch, n.seq
+	if epoch != n.epoch || seq > n.seq || n.seq-seq > changeNotifierCapacity {
+		reset = true
+		return
+	}
+	if seq == n.seq {
+		n.waiting = true
+		wait = n.wait
+		return
+	}
+	seen := make(map[uint64]struct{})
+	for curSeq = seq; curSeq < n.seq; curSeq++ {
+		ino := n.ring[(curSeq+1)%changeNotifierCapacity]
+		if _, ok := seen[ino]; ok {
+			continue
+		}
+		if len(inodes) >= limit {
+			break
+		}
+		seen[ino] = struct{}{}
+		inodes = append(inodes, ino)
+	}
+	return
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeNotifier(t *testing.T) {
+	n := newChangeNotifier()
+	inodes, epoch, seq, reset, wait := n.since(0, 0, 10)
+	require.True(t, reset)
+	require.Nil(t, wait)
+
+	inodes, epoch, seq, reset, wait = n.since(epoch, seq, 10)
+	require.False(t, reset)
+	require.Empty(t, inodes)
+	require.NotNil(t, wait)
+
+	n.notify(1)
+	n.notify(2)
+	n.notify(1)
+	n.notify(3)
+	select {
+	case <-wait:
+	default:
+		t.Fatal("the watcher is not woken up")
+	}
+	// the changes are returned once, at most limit of them
+	inodes, _, seq, reset, _ = n.since(epoch, 0, 2)
+	require.False(t, reset)
+	require.Equal(t, []uint64{1, 2}, inodes)
+	require.Equal(t, uint64(3), seq)
+	inodes, _, seq, _, _ = n.since(epoch, seq, 2)
+	require.Equal(t, []uint64{3}, inodes)
+	require.Equal(t, uint64(4), seq)
+
+	// the watcher fallen behind is reset
+	for i := 0; i <= changeNotifierCapacity; i++ {
+		n.notify(uint64(i))
+	}
+	_, _, cur, reset, _ := n.since(epoch, seq, 2)
+	require.True(t, reset)
+	require.Equal(t, seq+changeNotifierCapacity+1, cur)
+	_, _, _, reset, _ = n.since(epoch, seq+1, 2)
+	require.False(t, reset)
+
+	n.reset()
+	_, _, _, reset, _ = n.since(epoch, 0, 2)
+	require.True(t, reset)
+}
+
+func TestWatchChanges(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1, VolName: "test_vol"}, nil).(*metaPartition)
+	mp.uidManager = NewUidMgr("test_vol", 1)
+	mp.inodeTree.ReplaceOrInsert(NewInode(1, proto.Mode(os.ModePerm|os.ModeDir)), true)
+	mp.inodeTree.ReplaceOrInsert(NewInode(10, proto.Mode(0644)), true)
+
+	watch := func(epoch, seq uint64) *proto.WatchChangesResponse {
+		p := &Packet{}
+		require.NoError(t, mp.WatchChanges(&proto.WatchChangesRequest{Epoch: epoch, Seq: seq}, p))
+		resp := &proto.WatchChangesResponse{}
+		require.NoError(t, p.UnmarshalData(resp))
+		return resp
+	}
+	resp := watch(0, 0)
+	require.True(t, resp.Reset)
+
+	go func() {
+		mp.fsmCreateDentry(&Dentry{ParentId: 1, Name: "f", Inode: 10, Type: proto.Mode(0644)}, false)
+	}()
+	resp = watch(resp.Epoch, resp.Seq)
+	require.False(t, resp.Reset)
+	require.Equal(t, []uint64{1}, resp.Inodes)
+
+	mp.fsmSetAttr(&SetattrRequest{Inode: 10, Valid: proto.AttrMode, Mode: 0600})
+	mp.fsmDeleteDentry(&Dentry{ParentId: 1, Name: "f", Inode: 10}, true)
+	resp = watch(resp.Epoch, resp.Seq)
+	require.Equal(t, []uint64{10, 1}, resp.Inodes)
+}
@@ -79,7 +79,8 @@ type (
 	// MetaNode -> Master
 	UpdatePartitionResp = proto.UpdateMetaPartitionResponse
 	// Client -> MetaNode
-	ExtentsTruncateReq = proto.TruncateRequest
+	ExtentsTruncateReq  = proto.TruncateRequest
+	ExtentsPunchHoleReq = proto.PunchHoleRequest
 
 	// Client -> MetaNode
 	EvictInodeReq = proto.EvictInodeRequest
@@ -176,6 +177,60 @@ const (
 	opFSMUniqCheckerEvict    = 65
 	opFSMUnlinkInodeOnce     = 66
 	opFSMCreateLinkInodeOnce = 67
+
+	// file lock
+	opFSMSetFileLock      = 68
+	opFSMRenewFileLock    = 69
+	opFSMReleaseFileLocks = 70
+	opFSMEvictFileLock    = 71
+	opFSMFileLockSnap     = 72
+
+	opFSMExtentPunchHole = 73
+
+	// clone
+	opFSMCloneInode    = 74
+	opFSMExtentRefSnap = 75
+
+	// trash
+	opFSMTrashInode   = 76
+	opFSMRestoreTrash = 77
+	opFSMPurgeTrash   = 78
+	opFSMTrashSnap    = 79
+
+	// orphan inodes
+	opFSMAddOrphanInode     = 80
+	opFSMRenewOrphanSession = 81
+	opFSMReapOrphanInodes   = 82
+	opFSMOrphanSnap         = 83
+
+	// chunked snapshot
+	opFSMSnapBegin = 84
+	opFSMSnapChunk = 85
+
+	// partition split
+	opFSMMigrateStart  = 86
+	opFSMMigrateFreeze = 87
+	opFSMMigrateCommit = 88
+	opFSMMigrateAbort  = 89
+	opFSMMigrateItems  = 90
+
+	opFSMShardDir = 91
+
+	opFSMUpdateXAttrRecords = 92
+
+	// snapshot versions
+	opFSMSnapshotPrepare  = 93
+	opFSMSnapshotCommit   = 94
+	opFSMSnapshotAbort    = 95
+	opFSMSnapshotDelete   = 96
+	opFSMSnapVersionState = 97
+	opFSMSnapVersionItems = 98
+
+	opFSMSplitStateSnap = 99
+
+	// dir shards fenced before the sharded directory is deleted
+	opFSMFenceDirShard   = 100
+	opFSMUnfenceDirShard = 101
 )
 
 var (
@@ -214,7 +269,9 @@ const (
 	cfgSmuxStreamPerConn         = "smuxStreamPerConn"         //int
 	cfgSmuxMaxBuffer             = "smuxMaxBuffer"             //int
 	cfgRetainLogs                = "retainLogs"                //string, raft RetainLogs
-	cfgRaftSyncSnapFormatVersion = "raftSyncSnapFormatVersion" //int, format version of snapshot that raft leader sent to follower
+	cfgRaftSyncSnapFormatVersion = "raftSyncSnapFormatVersion" //int, format version of snapshot that raft leader sent to follower, 2 sends it in resumable chunks
+	cfgKVCacheItems              = "kvCacheItems"              //int, max items cached by each tree of the partitions kept in rocksdb
+	cfgSnapshotMaxDeltas         = "snapshotMaxDeltas"         //int, max deltas on top of the base of a snapshot, 0 disables the deltas
 
 	metaNodeDeleteBatchCountKey = "batchCount"
 	configNameResolveInterval   = "nameResolveInterval" // int
@@ -48,6 +48,7 @@ type Vol struct {
 	sync.RWMutex
 	dataPartitionView map[uint64]*DataPartition
 	volDeleteLockTime int64
+	volTrashInterval  int64
 }
 
 // NewVol returns a new volume instance.
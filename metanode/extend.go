@@ -17,6 +17,7 @@ package metanode
 import (
 	"bytes"
 	"encoding/binary"
+	"sort"
 	"sync"
 
 	"github.com/cubefs/cubefs/util/btree"
@@ -157,13 +158,19 @@ func (e *Extend) Bytes() ([]byte, error) {
 		}
 		return nil
 	}
-	for k, v := range e.dataMap {
+	// in the order of the keys, so that the same attributes are encoded alike on every replica
+	var keys = make([]string, 0, len(e.dataMap))
+	for k := range e.dataMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
 		// key
 		if err = writeBytes([]byte(k)); err != nil {
 			return nil, err
 		}
 		// value
-		if err = writeBytes(v); err != nil {
+		if err = writeBytes(e.dataMap[k]); err != nil {
 			return nil, err
 		}
 	}
@@ -0,0 +1,237 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/storage"
+)
+
+// extentRef identifies the unit of the data which is deleted from the data nodes: the normal extent is
+// deleted as a whole, while the tiny extent is shared by the files and is deleted by the pages.
+type extentRef struct {
+	PartitionId uint64 `json:"dp"`
+	ExtentId    uint64 `json:"eid"`
+	Offset      uint64 `json:"off"`
+}
+
+type extentRefItem struct {
+	extentRef
+	Count uint32 `json:"cnt"`
+}
+
+// extentRefsOf returns the units of the data referenced by the key.
+func extentRefsOf(ek *proto.ExtentKey) (refs []extentRef) {
+	if !storage.IsTinyExtent(ek.ExtentId) {
+		return []extentRef{{PartitionId: ek.PartitionId, ExtentId: ek.ExtentId}}
+	}
+	start := ek.ExtentOffset - ek.ExtentOffset%storage.PageSize
+	for off := start; off < ek.ExtentOffset+uint64(ek.Size); off += storage.PageSize {
+		refs = append(refs, extentRef{PartitionId: ek.PartitionId, ExtentId: ek.ExtentId, Offset: off})
+	}
+	return
+}
+
+// extentRefTable counts the inodes of the partition referencing the data extents shared by the clones.
+// Only the shared extents are tracked, an extent is removed from the table once it is referenced by
+// a single inode, which owns the extent again and deletes it from the data nodes as before.
+type extentRefTable struct {
+	sync.RWMutex
+	refs map[extentRef]uint32
+}
+
+func newExtentRefTable() *extentRefTable {
+	return &extentRefTable{
+		refs: make(map[extentRef]uint32),
+	}
+}
+
+func (t *extentRefTable) clone() *extentRefTable {
+	t.RLock()
+	defer t.RUnlock()
+	refs := make(map[extentRef]uint32, len(t.refs))
+	for ref, count := range t.refs {
+		refs[ref] = count
+	}
+	return &extentRefTable{refs: refs}
+}
+
+func (t *extentRefTable) len() int {
+	t.RLock()
+	defer t.RUnlock()
+	return len(t.refs)
+}
+
+func (t *extentRefTable) count(ref extentRef) uint32 {
+	t.RLock()
+	defer t.RUnlock()
+	return t.refs[ref]
+}
+
+// get adds a reference of the extent, the extent not tracked is referenced by the owner only.
+func (t *extentRefTable) get(ref extentRef) {
+	t.Lock()
+	defer t.Unlock()
+	count, ok := t.refs[ref]
+	if !ok {
+		count = 1
+	}
+	t.refs[ref] = count + 1
+}
+
+// put drops a reference of the extent, and returns true if the extent was shared with the others,
+// in which case the data must not be deleted.
+func (t *extentRefTable) put(ref extentRef) (shared bool) {
+	t.Lock()
+	defer t.Unlock()
+	count, ok := t.refs[ref]
+	if !ok {
+		return false
+	}
+	if count--; count <= 1 {
+		delete(t.refs, ref)
+	} else {
+		t.refs[ref] = count
+	}
+	return true
+}
+
+// set sets the references of the extent, the extent referenced by a single inode is not tracked.
+func (t *extentRefTable) set(ref extentRef, count uint32) {
+	t.Lock()
+	defer t.Unlock()
+	if count <= 1 {
+		delete(t.refs, ref)
+		return
+	}
+	t.refs[ref] = count
+}
+
+// replace replaces all the references of the table.
+func (t *extentRefTable) replace(refs map[extentRef]uint32) {
+	table := make(map[extentRef]uint32, len(refs))
+	for ref, count := range refs {
+		if count > 1 {
+			table[ref] = count
+		}
+	}
+	t.Lock()
+	t.refs = table
+	t.Unlock()
+}
+
+func (t *extentRefTable) Marshal() (buf []byte, crc uint32, err error) {
+	t.RLock()
+	items := make([]*extentRefItem, 0, len(t.refs))
+	for ref, count := range t.refs {
+		items = append(items, &extentRefItem{extentRef: ref, Count: count})
+	}
+	t.RUnlock()
+	if buf, err = json.Marshal(items); err != nil {
+		return
+	}
+	crc = crc32.ChecksumIEEE(buf)
+	return
+}
+
+func (t *extentRefTable) UnMarshal(data []byte) (err error) {
+	items := make([]*extentRefItem, 0)
+	if err = json.Unmarshal(data, &items); err != nil {
+		return
+	}
+	refs := make(map[extentRef]uint32, len(items))
+	for _, item := range items {
+		refs[item.extentRef] = item.Count
+	}
+	t.Lock()
+	t.refs = refs
+	t.Unlock()
+	return
+}
+
+// split splits the keys into the parts owned by a single inode, which are deleted from the data nodes as before,
+// and the units shared with the other inodes.
+func (t *extentRefTable) split(eks []proto.ExtentKey) (owned []proto.ExtentKey, shared []extentRef) {
+	if t.len() == 0 {
+		return eks, nil
+	}
+	owned = make([]proto.ExtentKey, 0, len(eks))
+	for _, ek := range eks {
+		refs := extentRefsOf(&ek)
+		if !storage.IsTinyExtent(ek.ExtentId) {
+			if t.count(refs[0]) > 0 {
+				shared = append(shared, refs[0])
+			} else {
+				owned = append(owned, ek)
+			}
+			continue
+		}
+		// the pages not shared are deleted by the ranges
+		start, end := ek.ExtentOffset, ek.ExtentOffset+uint64(ek.Size)
+		runStart := start
+		for _, ref := range refs {
+			if t.count(ref) == 0 {
+				continue
+			}
+			shared = append(shared, ref)
+			if ref.Offset > runStart {
+				owned = append(owned, subExtentKey(ek, runStart, ref.Offset))
+			}
+			runStart = ref.Offset + storage.PageSize
+		}
+		if runStart < end {
+			owned = append(owned, subExtentKey(ek, runStart, end))
+		}
+	}
+	return
+}
+
+// shares returns true if any of the data referenced by the keys is shared with the other inodes.
+func (t *extentRefTable) shares(eks []proto.ExtentKey) bool {
+	if t.len() == 0 {
+		return false
+	}
+	for _, ek := range eks {
+		for _, ref := range extentRefsOf(&ek) {
+			if t.count(ref) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// subExtentKey returns the part of the key in the range [start, end) of the extent.
+func subExtentKey(ek proto.ExtentKey, start, end uint64) proto.ExtentKey {
+	sub := ek
+	sub.FileOffset = ek.FileOffset + (start - ek.ExtentOffset)
+	sub.ExtentOffset = start
+	sub.Size = uint32(end - start)
+	return sub
+}
+
+func extentRefSet(eks []proto.ExtentKey) map[extentRef]struct{} {
+	set := make(map[extentRef]struct{}, len(eks))
+	for _, ek := range eks {
+		for _, ref := range extentRefsOf(&ek) {
+			set[ref] = struct{}{}
+		}
+	}
+	return set
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtentRefTableNormal(t *testing.T) {
+	table := newExtentRefTable()
+	eks := []proto.ExtentKey{
+		{FileOffset: 0, PartitionId: 1, ExtentId: 1025, Size: 1000},
+		{FileOffset: 1000, PartitionId: 1, ExtentId: 1026, Size: 1000},
+	}
+	owned, shared := table.split(eks)
+	require.Len(t, owned, 2)
+	require.Len(t, shared, 0)
+	require.False(t, table.shares(eks))
+
+	// the extent cloned is referenced by the source and the clone
+	ref := extentRefsOf(&eks[0])[0]
+	table.get(ref)
+	require.Equal(t, uint32(2), table.count(ref))
+	require.True(t, table.shares(eks))
+	require.False(t, table.shares(eks[1:]))
+	owned, shared = table.split(eks)
+	require.Equal(t, []proto.ExtentKey{eks[1]}, owned)
+	require.Equal(t, []extentRef{ref}, shared)
+
+	table.get(ref)
+	require.True(t, table.put(ref))
+	require.True(t, table.put(ref))
+	// the last inode owns the extent again
+	require.False(t, table.put(ref))
+	require.Equal(t, 0, table.len())
+}
+
+func TestExtentRefTableTiny(t *testing.T) {
+	table := newExtentRefTable()
+	ek := proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: 1, ExtentOffset: 8192, Size: 10000}
+	refs := extentRefsOf(&ek)
+	require.Len(t, refs, 3)
+
+	// the middle page is shared
+	table.get(refs[1])
+	owned, shared := table.split([]proto.ExtentKey{ek})
+	require.Equal(t, []extentRef{refs[1]}, shared)
+	require.Len(t, owned, 2)
+	require.Equal(t, uint64(8192), owned[0].ExtentOffset)
+	require.Equal(t, uint32(4096), owned[0].Size)
+	require.Equal(t, uint64(16384), owned[1].ExtentOffset)
+	require.Equal(t, uint64(8192), owned[1].FileOffset)
+	require.Equal(t, uint32(1808), owned[1].Size)
+
+	data, crc, err := table.clone().Marshal()
+	require.NoError(t, err)
+	require.NotZero(t, crc)
+	loaded := newExtentRefTable()
+	require.NoError(t, loaded.UnMarshal(data))
+	require.Equal(t, uint32(2), loaded.count(refs[1]))
+}
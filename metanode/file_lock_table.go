@@ -0,0 +1,226 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// fileLockTable holds the advisory locks of the inodes in the partition.
+// All the modifications are applied by the raft, and the current time is given by the leader,
+// so that the expiry of the leases is deterministic across the replicas.
+type fileLockTable struct {
+	sync.RWMutex
+	locks map[uint64][]*proto.FileLock
+}
+
+func newFileLockTable() *fileLockTable {
+	return &fileLockTable{
+		locks: make(map[uint64][]*proto.FileLock),
+	}
+}
+
+func (t *fileLockTable) clone() *fileLockTable {
+	t.RLock()
+	defer t.RUnlock()
+	locks := make(map[uint64][]*proto.FileLock, len(t.locks))
+	for ino, list := range t.locks {
+		cloned := make([]*proto.FileLock, 0, len(list))
+		for _, lock := range list {
+			l := *lock
+			cloned = append(cloned, &l)
+		}
+		locks[ino] = cloned
+	}
+	return &fileLockTable{locks: locks}
+}
+
+func (t *fileLockTable) len() (count int) {
+	t.RLock()
+	defer t.RUnlock()
+	for _, list := range t.locks {
+		count += len(list)
+	}
+	return
+}
+
+func (t *fileLockTable) getConflict(ino uint64, lock *proto.FileLock, now int64) *proto.FileLock {
+	t.RLock()
+	defer t.RUnlock()
+	for _, l := range t.locks[ino] {
+		if !l.Expired(now) && l.Conflicts(lock) {
+			conflict := *l
+			return &conflict
+		}
+	}
+	return nil
+}
+
+// setLock acquires or releases the lock on the range, and returns the conflicting lock if the lock
+// is not acquired. The range held by the same owner is replaced, the locks are split or merged as POSIX does.
+func (t *fileLockTable) setLock(ino uint64, lock *proto.FileLock, now int64) (conflict *proto.FileLock) {
+	t.Lock()
+	defer t.Unlock()
+
+	list := make([]*proto.FileLock, 0, len(t.locks[ino])+2)
+	for _, l := range t.locks[ino] {
+		if l.Expired(now) {
+			continue
+		}
+		if lock.Type != proto.FileLockUnlock && l.Conflicts(lock) {
+			c := *l
+			return &c
+		}
+		list = append(list, l)
+	}
+
+	result := make([]*proto.FileLock, 0, len(list)+2)
+	for _, l := range list {
+		if !l.SameOwner(lock) || !l.Overlaps(lock) {
+			result = append(result, l)
+			continue
+		}
+		// keep the parts out of the range
+		if l.Start < lock.Start {
+			left := *l
+			left.End = lock.Start - 1
+			result = append(result, &left)
+		}
+		if l.End > lock.End {
+			right := *l
+			right.Start = lock.End + 1
+			result = append(result, &right)
+		}
+	}
+
+	if lock.Type != proto.FileLockUnlock {
+		added := *lock
+		added.Expire = now + proto.FileLockLease
+		merged := make([]*proto.FileLock, 0, len(result)+1)
+		for _, l := range result {
+			if l.SameOwner(&added) && l.Type == added.Type && adjacentFileLock(l, &added) {
+				if l.Start < added.Start {
+					added.Start = l.Start
+				}
+				if l.End > added.End {
+					added.End = l.End
+				}
+				continue
+			}
+			merged = append(merged, l)
+		}
+		result = append(merged, &added)
+	}
+
+	if len(result) == 0 {
+		delete(t.locks, ino)
+	} else {
+		t.locks[ino] = result
+	}
+	return nil
+}
+
+// adjacentFileLock returns true if the ranges overlap or are next to each other.
+func adjacentFileLock(a, b *proto.FileLock) bool {
+	return (a.End == proto.FileLockEOF || a.End+1 >= b.Start) && (b.End == proto.FileLockEOF || b.End+1 >= a.Start)
+}
+
+// renew extends the leases of the locks held by the session, and returns the number of them.
+func (t *fileLockTable) renew(session string, now int64) (count int) {
+	t.Lock()
+	defer t.Unlock()
+	for _, list := range t.locks {
+		for _, l := range list {
+			if l.Session == session && !l.Expired(now) {
+				l.Expire = now + proto.FileLockLease
+				count++
+			}
+		}
+	}
+	return
+}
+
+// release removes the locks matched, and returns the number of the locks removed.
+func (t *fileLockTable) release(match func(l *proto.FileLock) bool) (count int) {
+	t.Lock()
+	defer t.Unlock()
+	for ino, list := range t.locks {
+		result := list[:0]
+		for _, l := range list {
+			if match(l) {
+				count++
+				continue
+			}
+			result = append(result, l)
+		}
+		if len(result) == 0 {
+			delete(t.locks, ino)
+		} else {
+			t.locks[ino] = result
+		}
+	}
+	return
+}
+
+func (t *fileLockTable) releaseSession(session string) int {
+	return t.release(func(l *proto.FileLock) bool {
+		return l.Session == session
+	})
+}
+
+func (t *fileLockTable) evict(now int64) int {
+	return t.release(func(l *proto.FileLock) bool {
+		return l.Expired(now)
+	})
+}
+
+func (t *fileLockTable) hasExpired(now int64) bool {
+	t.RLock()
+	defer t.RUnlock()
+	for _, list := range t.locks {
+		for _, l := range list {
+			if l.Expired(now) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (t *fileLockTable) Marshal() (buf []byte, crc uint32, err error) {
+	t.RLock()
+	buf, err = json.Marshal(t.locks)
+	t.RUnlock()
+	if err != nil {
+		return
+	}
+	crc = crc32.ChecksumIEEE(buf)
+	return
+}
+
+func (t *fileLockTable) UnMarshal(data []byte) (err error) {
+	locks := make(map[uint64][]*proto.FileLock)
+	if err = json.Unmarshal(data, &locks); err != nil {
+		return
+	}
+	t.Lock()
+	t.locks = locks
+	t.Unlock()
+	return
+}
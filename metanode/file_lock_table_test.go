@@ -0,0 +1,105 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func posixLock(session string, owner uint64, typ uint8, start, end uint64) *proto.FileLock {
+	return &proto.FileLock{Session: session, Owner: owner, Type: typ, Start: start, End: end}
+}
+
+func TestFileLockTableConflict(t *testing.T) {
+	table := newFileLockTable()
+	now := int64(1000)
+
+	require.Nil(t, table.setLock(1, posixLock("a", 1, proto.FileLockRead, 0, 99), now))
+	// the read locks are shared
+	require.Nil(t, table.setLock(1, posixLock("b", 1, proto.FileLockRead, 50, 149), now))
+	conflict := table.setLock(1, posixLock("b", 2, proto.FileLockWrite, 90, 199), now)
+	require.NotNil(t, conflict)
+	require.Equal(t, "a", conflict.Session)
+	// the same owner of different sessions is different
+	require.NotNil(t, table.getConflict(1, posixLock("a", 2, proto.FileLockWrite, 100, 149), now))
+	require.Nil(t, table.getConflict(1, posixLock("a", 2, proto.FileLockWrite, 150, proto.FileLockEOF), now))
+	// the locks of the other inodes do not matter
+	require.Nil(t, table.getConflict(2, posixLock("c", 1, proto.FileLockWrite, 0, proto.FileLockEOF), now))
+
+	// the flock locks do not interact with the posix locks
+	flock := &proto.FileLock{Session: "c", Owner: 1, Type: proto.FileLockWrite, Flock: true, End: proto.FileLockEOF}
+	require.Nil(t, table.setLock(1, flock, now))
+	require.NotNil(t, table.getConflict(1, &proto.FileLock{Session: "d", Owner: 1, Type: proto.FileLockRead, Flock: true, End: proto.FileLockEOF}, now))
+
+	// the expired locks are ignored
+	expired := now + proto.FileLockLease
+	require.Nil(t, table.setLock(1, posixLock("b", 2, proto.FileLockWrite, 0, proto.FileLockEOF), expired))
+	require.Equal(t, 1, table.len())
+}
+
+func TestFileLockTableSplitMerge(t *testing.T) {
+	table := newFileLockTable()
+	now := int64(1000)
+
+	require.Nil(t, table.setLock(1, posixLock("a", 1, proto.FileLockWrite, 0, 99), now))
+	require.Nil(t, table.setLock(1, posixLock("a", 1, proto.FileLockWrite, 100, 199), now))
+	require.Len(t, table.locks[1], 1)
+	require.Equal(t, uint64(199), table.locks[1][0].End)
+
+	// converting the middle of the range splits the lock
+	require.Nil(t, table.setLock(1, posixLock("a", 1, proto.FileLockRead, 50, 149), now))
+	require.Len(t, table.locks[1], 3)
+	require.Nil(t, table.getConflict(1, posixLock("b", 1, proto.FileLockRead, 50, 149), now))
+	require.NotNil(t, table.getConflict(1, posixLock("b", 1, proto.FileLockRead, 150, 150), now))
+
+	// unlocking the range releases the parts overlapped
+	require.Nil(t, table.setLock(1, posixLock("a", 1, proto.FileLockUnlock, 0, 149), now))
+	require.Len(t, table.locks[1], 1)
+	require.Equal(t, uint64(150), table.locks[1][0].Start)
+	require.Nil(t, table.setLock(1, posixLock("a", 1, proto.FileLockUnlock, 0, proto.FileLockEOF), now))
+	require.NotContains(t, table.locks, uint64(1))
+}
+
+func TestFileLockTableLease(t *testing.T) {
+	table := newFileLockTable()
+	now := int64(1000)
+
+	require.Nil(t, table.setLock(1, posixLock("a", 1, proto.FileLockWrite, 0, 99), now))
+	require.Nil(t, table.setLock(2, posixLock("a", 1, proto.FileLockWrite, 0, 99), now))
+	require.Nil(t, table.setLock(2, posixLock("b", 1, proto.FileLockWrite, 100, 199), now))
+
+	// the renewed locks survive the lease
+	later := now + proto.FileLockLease - 1
+	require.Equal(t, 2, table.renew("a", later))
+	require.False(t, table.hasExpired(now+proto.FileLockLease-1))
+	require.True(t, table.hasExpired(now+proto.FileLockLease))
+	require.Equal(t, 1, table.evict(now+proto.FileLockLease))
+	require.Equal(t, 2, table.len())
+
+	cloned := table.clone()
+	data, crc, err := cloned.Marshal()
+	require.NoError(t, err)
+	require.NotZero(t, crc)
+	loaded := newFileLockTable()
+	require.NoError(t, loaded.UnMarshal(data))
+	require.Equal(t, 2, loaded.len())
+
+	require.Equal(t, 2, table.releaseSession("a"))
+	require.Equal(t, 0, table.len())
+	require.Equal(t, 2, loaded.len())
+}
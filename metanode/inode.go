@@ -28,6 +28,8 @@ import (
 
 const (
 	DeleteMarkFlag = 1 << 0
+	ShardedDirFlag = 1 << 1 // the dentries of the directory are sharded to the shard inodes
+	FencedDirFlag  = 1 << 2 // the shard is fenced, no dentry is created in it any more
 )
 
 var (
@@ -615,6 +617,16 @@ func (i *Inode) ExtentsTruncate(length uint64, ct int64, doOnLastKey func(*proto
 	return
 }
 
+// ExtentsPunchHole removes the range of the file from the extents, the size of the file is not changed.
+func (i *Inode) ExtentsPunchHole(offset, size uint64, ct int64) (removed, delExtents []proto.ExtentKey) {
+	i.Lock()
+	removed, delExtents = i.Extents.PunchHole(offset, size)
+	i.ModifyTime = ct
+	i.Generation++
+	i.Unlock()
+	return
+}
+
 // IncNLink increases the nLink value by one.
 func (i *Inode) IncNLink() {
 	i.Lock()
@@ -670,6 +682,40 @@ func (i *Inode) ShouldDelete() (ok bool) {
 	return
 }
 
+// SetShardedDir marks the directory whose dentries are sharded to the shard inodes.
+func (i *Inode) SetShardedDir() {
+	i.Lock()
+	i.Flag |= ShardedDirFlag
+	i.Unlock()
+}
+
+// IsShardedDir returns if the dentries of the directory are sharded to the shard inodes.
+func (i *Inode) IsShardedDir() (ok bool) {
+	i.RLock()
+	ok = i.Flag&ShardedDirFlag == ShardedDirFlag
+	i.RUnlock()
+	return
+}
+
+// SetFencedDir fences the shard of the directory to delete, or lifts the fence.
+func (i *Inode) SetFencedDir(fenced bool) {
+	i.Lock()
+	if fenced {
+		i.Flag |= FencedDirFlag
+	} else {
+		i.Flag &^= FencedDirFlag
+	}
+	i.Unlock()
+}
+
+// IsFencedDir returns if the shard of the directory is fenced.
+func (i *Inode) IsFencedDir() (ok bool) {
+	i.RLock()
+	ok = i.Flag&FencedDirFlag == FencedDirFlag
+	i.RUnlock()
+	return
+}
+
 // inode should delay remove if as 3 conditions:
 // 1. DeleteMarkFlag is unset
 // 2. NLink == 0
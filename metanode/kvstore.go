@@ -0,0 +1,128 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"sync"
+
+	"github.com/cubefs/cubefs/util/errors"
+)
+
+// ErrKVStoreNotRegistered is returned when a partition in MetaStoreModeRocksDB
+// is loaded by a binary without a registered KVStore.
+var ErrKVStoreNotRegistered = errors.New("kv store is not registered")
+
+// KVReader reads an ordered key-value engine.
+type KVReader interface {
+	// Get returns the value of the key, or nil if the key does not exist.
+	Get(key []byte) ([]byte, error)
+	// NewIterator returns an iterator over the keys in [start, end), a nil end means no upper bound.
+	NewIterator(start, end []byte) KVIterator
+}
+
+// KVIterator iterates the keys in ascending order.
+type KVIterator interface {
+	Valid() bool
+	Key() []byte
+	Value() []byte
+	Next()
+	Err() error
+	Close()
+}
+
+// KVSnapshot is a consistent read-only view of a KVStore.
+type KVSnapshot interface {
+	KVReader
+	Release()
+}
+
+// KVStore is the ordered key-value engine that keeps the trees of the partitions in MetaStoreModeRocksDB.
+type KVStore interface {
+	KVReader
+	Write(batch *KVBatch, sync bool) error
+	NewSnapshot() KVSnapshot
+	Close() error
+}
+
+// KVOpType defines the type of the operations in a KVBatch.
+type KVOpType uint8
+
+const (
+	KVOpPut KVOpType = iota
+	KVOpDelete
+	KVOpDeleteRange
+)
+
+// KVOp is an operation of a KVBatch. For KVOpDeleteRange the Value is the exclusive end of the range.
+type KVOp struct {
+	Type  KVOpType
+	Key   []byte
+	Value []byte
+}
+
+// KVBatch is a list of operations written atomically.
+type KVBatch struct {
+	Ops []KVOp
+}
+
+func NewKVBatch() *KVBatch {
+	return &KVBatch{}
+}
+
+func (b *KVBatch) Put(key, value []byte) {
+	b.Ops = append(b.Ops, KVOp{Type: KVOpPut, Key: key, Value: value})
+}
+
+func (b *KVBatch) Delete(key []byte) {
+	b.Ops = append(b.Ops, KVOp{Type: KVOpDelete, Key: key})
+}
+
+func (b *KVBatch) DeleteRange(start, end []byte) {
+	b.Ops = append(b.Ops, KVOp{Type: KVOpDeleteRange, Key: start, Value: end})
+}
+
+func (b *KVBatch) Len() int {
+	return len(b.Ops)
+}
+
+func (b *KVBatch) Reset() {
+	b.Ops = b.Ops[:0]
+}
+
+// KVStoreOpener opens the KVStore in the given directory, creating it if missing.
+type KVStoreOpener func(dir string) (KVStore, error)
+
+var (
+	kvStoreMutex  sync.RWMutex
+	kvStoreOpener KVStoreOpener
+)
+
+// RegisterKVStore registers the engine used by the partitions in MetaStoreModeRocksDB.
+// The metanode does not link the RocksDB library by itself, the binary registers it before starting.
+func RegisterKVStore(opener KVStoreOpener) {
+	kvStoreMutex.Lock()
+	kvStoreOpener = opener
+	kvStoreMutex.Unlock()
+}
+
+func openKVStore(dir string) (KVStore, error) {
+	kvStoreMutex.RLock()
+	opener := kvStoreOpener
+	kvStoreMutex.RUnlock()
+	if opener == nil {
+		return nil, ErrKVStoreNotRegistered
+	}
+	return opener(dir)
+}
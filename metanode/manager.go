@@ -35,6 +35,7 @@ import (
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/trace"
 )
 
 const partitionPrefix = "partition_"
@@ -108,8 +109,17 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remo
 
 	metric := exporter.NewTPCnt(p.GetOpMsg())
 	labels := m.getPacketLabels(p)
+	span := trace.StartRemoteSpan(p.Trace, "metanode."+p.GetOpMsg())
+	span.SetTag("mp", p.PartitionID)
+	// the raft log submitted by the request is the child of the span
+	p.Trace = span.Context()
 	defer func() {
 		metric.SetWithLabels(err, labels)
+		if p.ResultCode != proto.OpOk {
+			span.SetTag("result", p.GetResultMsg())
+		}
+		span.SetError(err)
+		span.Finish()
 		if err != nil {
 			log.LogWarnf("HandleMetadataOperation output (%s), remote %s, err %s", p.String(), remoteAddr, err.Error())
 			return
@@ -170,6 +180,10 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remo
 		err = m.opMetaExtentsDel(conn, p, remoteAddr)
 	case proto.OpMetaTruncate:
 		err = m.opMetaExtentsTruncate(conn, p, remoteAddr)
+	case proto.OpMetaExtentsPunchHole:
+		err = m.opMetaExtentsPunchHole(conn, p, remoteAddr)
+	case proto.OpMetaInodeClone:
+		err = m.opMetaInodeClone(conn, p, remoteAddr)
 	case proto.OpMetaLookup:
 		err = m.opMetaLookup(conn, p, remoteAddr)
 	case proto.OpDeleteMetaPartition:
@@ -186,6 +200,8 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remo
 		err = m.opRemoveMetaPartitionRaftMember(conn, p, remoteAddr)
 	case proto.OpMetaPartitionTryToLeader:
 		err = m.opMetaPartitionTryToLeader(conn, p, remoteAddr)
+	case proto.OpMigrateMetaPartition:
+		err = m.opMigrateMetaPartition(conn, p, remoteAddr)
 	case proto.OpMetaBatchInodeGet:
 		err = m.opMetaBatchInodeGet(conn, p, remoteAddr)
 	case proto.OpMetaDeleteInode:
@@ -207,6 +223,10 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remo
 		err = m.opMetaGetXAttr(conn, p, remoteAddr)
 	case proto.OpMetaGetAllXAttr:
 		err = m.opMetaGetAllXAttr(conn, p, remoteAddr)
+	case proto.OpMetaUpdateXAttrRecords:
+		err = m.opMetaUpdateXAttrRecords(conn, p, remoteAddr)
+	case proto.OpMetaSnapshotVersion:
+		err = m.opMetaSnapshotVersion(conn, p, remoteAddr)
 	case proto.OpMetaBatchGetXAttr:
 		err = m.opMetaBatchGetXAttr(conn, p, remoteAddr)
 	case proto.OpMetaRemoveXAttr:
@@ -264,6 +284,34 @@ func (m *metadataManager) HandleMetadataOperation(conn net.Conn, p *Packet, remo
 		err = m.opQuotaCreateDentry(conn, p, remoteAddr)
 	case proto.OpMetaGetUniqID:
 		err = m.opMetaGetUniqID(conn, p, remoteAddr)
+	case proto.OpMetaSetFileLock:
+		err = m.opMetaSetFileLock(conn, p, remoteAddr)
+	case proto.OpMetaGetFileLock:
+		err = m.opMetaGetFileLock(conn, p, remoteAddr)
+	case proto.OpMetaRenewFileLock:
+		err = m.opMetaRenewFileLock(conn, p, remoteAddr)
+	case proto.OpMetaReleaseFileLocks:
+		err = m.opMetaReleaseFileLocks(conn, p, remoteAddr)
+	case proto.OpMetaTrashInode:
+		err = m.opMetaTrashInode(conn, p, remoteAddr)
+	case proto.OpMetaListTrash:
+		err = m.opMetaListTrash(conn, p, remoteAddr)
+	case proto.OpMetaRestoreTrash:
+		err = m.opMetaRestoreTrash(conn, p, remoteAddr)
+	case proto.OpMetaPurgeTrash:
+		err = m.opMetaPurgeTrash(conn, p, remoteAddr)
+	case proto.OpMetaAddOrphanInode:
+		err = m.opMetaAddOrphanInode(conn, p, remoteAddr)
+	case proto.OpMetaRenewOrphanSession:
+		err = m.opMetaRenewOrphanSession(conn, p, remoteAddr)
+	case proto.OpMetaWatchChanges:
+		err = m.opMetaWatchChanges(conn, p, remoteAddr)
+	case proto.OpMetaReadChangeFeed:
+		err = m.opMetaReadChangeFeed(conn, p, remoteAddr)
+	case proto.OpMetaSnapshotProgress:
+		err = m.opMetaSnapshotProgress(conn, p, remoteAddr)
+	case proto.OpMetaMigrateItems:
+		err = m.opMetaMigrateItems(conn, p, remoteAddr)
 	default:
 		err = fmt.Errorf("%s unknown Opcode: %d, reqId: %d", remoteAddr,
 			p.Opcode, p.GetReqID())
@@ -486,6 +534,7 @@ func (m *metadataManager) createPartition(request *proto.CreateMetaPartitionRequ
 		Cursor:      request.Start,
 		UniqId:      0,
 		Peers:       request.Members,
+		StoreMode:   request.StoreMode,
 		RaftStore:   m.raftStore,
 		NodeId:      m.nodeId,
 		RootDir:     path.Join(m.rootDir, partitionPrefix+partitionId),
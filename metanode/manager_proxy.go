@@ -38,6 +38,10 @@ func (m *metadataManager) serveProxy(conn net.Conn, mp MetaPartition,
 		reqOp      = p.Opcode
 	)
 
+	// the inode of the request is moved to another partition by a split
+	if mp.ServeMoved(p) {
+		goto end
+	}
 	if leaderAddr, ok = mp.IsLeader(); ok {
 		return
 	}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/trace"
 )
 
 // Reply operation results to the master.
@@ -56,7 +57,8 @@ func (m *metadataManager) respondToClient(conn net.Conn, p *Packet) (err error)
 		}
 	}()
 
-	// process data and send reply though specified tcp connection.
+	// process data and send reply though specified tcp connection, which doesn't carry the trace.
+	p.Trace = trace.SpanContext{}
 	err = p.WriteToConn(conn)
 	if err != nil {
 		log.LogErrorf("response to client[%s], "+
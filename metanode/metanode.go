@@ -36,6 +36,7 @@ import (
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/trace"
 )
 
 var (
@@ -161,6 +162,9 @@ func doStart(s common.Server, cfg *config.Config) (err error) {
 	go m.startUpdateNodeInfo()
 
 	exporter.Init(cfg.GetString("role"), cfg)
+	if err = trace.Init(cfg.GetString("role"), cfg); err != nil {
+		return
+	}
 	m.startStat()
 
 	// check local partition compare with master ,if lack,then not start
@@ -186,6 +190,7 @@ func doShutdown(s common.Server) {
 	m.stopMetaManager()
 	m.stopRaftServer()
 	masterClient.Stop()
+	trace.Close()
 }
 
 // Sync blocks the invoker's goroutine until the meta node shuts down.
@@ -215,6 +220,13 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 		updateDeleteBatchCount(uint64(deleteBatchCount))
 	}
 
+	if cacheItems := cfg.GetInt64(cfgKVCacheItems); cacheItems > 0 {
+		kvCacheItems = int(cacheItems)
+	}
+	if cfg.HasKey(cfgSnapshotMaxDeltas) {
+		snapshotMaxDeltas = int(cfg.GetInt64(cfgSnapshotMaxDeltas))
+	}
+
 	total, _, err := util.GetMemInfo()
 	if err != nil {
 		log.LogErrorf("get total mem failed, err %s", err.Error())
@@ -270,7 +282,7 @@ func (m *MetaNode) parseConfig(cfg *config.Config) (err error) {
 
 	if cfg.HasKey(cfgRaftSyncSnapFormatVersion) {
 		raftSyncSnapFormatVersion := uint32(cfg.GetInt64(cfgRaftSyncSnapFormatVersion))
-		if raftSyncSnapFormatVersion < 0 || raftSyncSnapFormatVersion > SnapFormatVersion_1 {
+		if raftSyncSnapFormatVersion < 0 || raftSyncSnapFormatVersion > SnapFormatVersion_2 {
 			m.raftSyncSnapFormatVersion = SnapFormatVersion_1
 			log.LogInfof("invalid config raftSyncSnapFormatVersion, using default[%v]", m.raftSyncSnapFormatVersion)
 		} else {
@@ -0,0 +1,196 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"sort"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// orphanSession is the lease of a client session, and the orphan inodes of the partition it holds open.
+type orphanSession struct {
+	Expire int64    `json:"expire"` // unix timestamp in seconds
+	Inodes []uint64 `json:"inodes"`
+}
+
+// orphanTable holds the inodes unlinked while they are open by the client sessions. The inodes are not
+// deleted until they are evicted by the sessions, or the leases of the sessions are expired.
+// Like the file locks, the current time is given by the leader, so that the expiry is deterministic
+// across the replicas.
+type orphanTable struct {
+	sync.RWMutex
+	sessions map[string]*orphanSession
+	// the session holding the inode
+	inodes map[uint64]string
+}
+
+func newOrphanTable() *orphanTable {
+	return &orphanTable{
+		sessions: make(map[string]*orphanSession),
+		inodes:   make(map[uint64]string),
+	}
+}
+
+func (t *orphanTable) clone() *orphanTable {
+	t.RLock()
+	defer t.RUnlock()
+	cloned := newOrphanTable()
+	for sid, session := range t.sessions {
+		cloned.sessions[sid] = &orphanSession{
+			Expire: session.Expire,
+			Inodes: append([]uint64(nil), session.Inodes...),
+		}
+	}
+	for ino, sid := range t.inodes {
+		cloned.inodes[ino] = sid
+	}
+	return cloned
+}
+
+// len returns the number of the orphan inodes.
+func (t *orphanTable) len() int {
+	t.RLock()
+	defer t.RUnlock()
+	return len(t.inodes)
+}
+
+func (t *orphanTable) has(ino uint64) bool {
+	t.RLock()
+	defer t.RUnlock()
+	_, ok := t.inodes[ino]
+	return ok
+}
+
+// add records the inode held by the session, and extends the lease of the session.
+func (t *orphanTable) add(ino uint64, sid string, now int64) {
+	t.Lock()
+	defer t.Unlock()
+	if old, ok := t.inodes[ino]; ok {
+		if old == sid {
+			t.sessions[sid].Expire = now + proto.OrphanSessionLease
+			return
+		}
+		t.removeLocked(ino)
+	}
+	session, ok := t.sessions[sid]
+	if !ok {
+		session = &orphanSession{}
+		t.sessions[sid] = session
+	}
+	session.Expire = now + proto.OrphanSessionLease
+	session.Inodes = append(session.Inodes, ino)
+	t.inodes[ino] = sid
+}
+
+// renew extends the lease of the session, and returns the number of the inodes held by it.
+// The expired session is not renewed, its inodes are to be reaped.
+func (t *orphanTable) renew(sid string, now int64) int {
+	t.Lock()
+	defer t.Unlock()
+	session, ok := t.sessions[sid]
+	if !ok || session.Expire <= now {
+		return 0
+	}
+	session.Expire = now + proto.OrphanSessionLease
+	return len(session.Inodes)
+}
+
+// remove forgets the inode, e.g. it is evicted by the session.
+func (t *orphanTable) remove(ino uint64) bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.removeLocked(ino)
+}
+
+func (t *orphanTable) removeLocked(ino uint64) bool {
+	sid, ok := t.inodes[ino]
+	if !ok {
+		return false
+	}
+	delete(t.inodes, ino)
+	session := t.sessions[sid]
+	for i, id := range session.Inodes {
+		if id == ino {
+			session.Inodes = append(session.Inodes[:i], session.Inodes[i+1:]...)
+			break
+		}
+	}
+	if len(session.Inodes) == 0 {
+		delete(t.sessions, sid)
+	}
+	return true
+}
+
+func (t *orphanTable) hasExpired(now int64) bool {
+	t.RLock()
+	defer t.RUnlock()
+	for _, session := range t.sessions {
+		if session.Expire <= now {
+			return true
+		}
+	}
+	return false
+}
+
+// reap removes the expired sessions, and returns the inodes held by them in order.
+func (t *orphanTable) reap(now int64) (inodes []uint64) {
+	t.Lock()
+	defer t.Unlock()
+	for sid, session := range t.sessions {
+		if session.Expire > now {
+			continue
+		}
+		for _, ino := range session.Inodes {
+			delete(t.inodes, ino)
+		}
+		inodes = append(inodes, session.Inodes...)
+		delete(t.sessions, sid)
+	}
+	sort.Slice(inodes, func(i, j int) bool { return inodes[i] < inodes[j] })
+	return
+}
+
+func (t *orphanTable) Marshal() (buf []byte, crc uint32, err error) {
+	t.RLock()
+	buf, err = json.Marshal(t.sessions)
+	t.RUnlock()
+	if err != nil {
+		return
+	}
+	crc = crc32.ChecksumIEEE(buf)
+	return
+}
+
+func (t *orphanTable) UnMarshal(data []byte) (err error) {
+	sessions := make(map[string]*orphanSession)
+	if err = json.Unmarshal(data, &sessions); err != nil {
+		return
+	}
+	inodes := make(map[uint64]string)
+	for sid, session := range sessions {
+		for _, ino := range session.Inodes {
+			inodes[ino] = sid
+		}
+	}
+	t.Lock()
+	t.sessions = sessions
+	t.inodes = inodes
+	t.Unlock()
+	return
+}
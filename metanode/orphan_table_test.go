@@ -0,0 +1,119 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrphanTableLease(t *testing.T) {
+	table := newOrphanTable()
+	now := int64(1000)
+
+	table.add(1, "a", now)
+	table.add(2, "a", now)
+	table.add(3, "b", now)
+	require.Equal(t, 3, table.len())
+	require.True(t, table.has(1))
+	require.False(t, table.hasExpired(now+proto.OrphanSessionLease-1))
+
+	// the session renewed is kept, the other one is reaped
+	now += proto.OrphanSessionLease - 1
+	require.Equal(t, 2, table.renew("a", now))
+	require.Equal(t, 0, table.renew("c", now))
+	now += 1
+	require.True(t, table.hasExpired(now))
+	require.Equal(t, []uint64{3}, table.reap(now))
+	require.False(t, table.has(3))
+	require.False(t, table.hasExpired(now))
+
+	// the expired session is not renewed
+	now += proto.OrphanSessionLease
+	require.Equal(t, 0, table.renew("a", now))
+	require.Equal(t, []uint64{1, 2}, table.reap(now))
+	require.Equal(t, 0, table.len())
+}
+
+func TestOrphanTableRemove(t *testing.T) {
+	table := newOrphanTable()
+	now := int64(1000)
+
+	table.add(1, "a", now)
+	table.add(2, "a", now)
+	// the inode held by the other session is moved
+	table.add(2, "b", now)
+	require.Equal(t, 1, table.renew("a", now))
+	require.Equal(t, 1, table.renew("b", now))
+
+	require.True(t, table.remove(1))
+	require.False(t, table.remove(1))
+	// the session without any inode is removed
+	require.Equal(t, 0, table.renew("a", now))
+
+	data, crc, err := table.Marshal()
+	require.NoError(t, err)
+	require.NotZero(t, crc)
+	loaded := newOrphanTable()
+	require.NoError(t, loaded.UnMarshal(data))
+	require.True(t, loaded.has(2))
+	require.Equal(t, 1, loaded.clone().renew("b", now))
+	require.Equal(t, []uint64{2}, loaded.reap(now+proto.OrphanSessionLease))
+}
+
+func TestOrphanInodeFsm(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1, VolName: "test_vol"}, nil).(*metaPartition)
+	mp.uidManager = NewUidMgr("test_vol", 1)
+
+	orphan := NewInode(10, proto.Mode(0644))
+	orphan.DecNLink()
+	mp.inodeTree.ReplaceOrInsert(orphan, true)
+	linked := NewInode(11, proto.Mode(0644))
+	mp.inodeTree.ReplaceOrInsert(linked, true)
+	evicted := NewInode(12, proto.Mode(0644))
+	evicted.DecNLink()
+	mp.inodeTree.ReplaceOrInsert(evicted, true)
+
+	now := int64(1000)
+	resp := mp.fsmAddOrphanInode(&fsmOrphanRequest{Inode: 10, Session: "a", Now: now})
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.True(t, mp.orphans.has(10))
+	// the inode still linked is not an orphan
+	resp = mp.fsmAddOrphanInode(&fsmOrphanRequest{Inode: 11, Session: "a", Now: now})
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.False(t, mp.orphans.has(11))
+	resp = mp.fsmAddOrphanInode(&fsmOrphanRequest{Inode: 13, Session: "a", Now: now})
+	require.Equal(t, proto.OpNotExistErr, resp.Status)
+
+	// the inode evicted by the client is forgotten
+	mp.fsmAddOrphanInode(&fsmOrphanRequest{Inode: 12, Session: "a", Now: now})
+	mp.fsmEvictInode(NewInode(12, 0))
+	require.False(t, mp.orphans.has(12))
+	require.True(t, evicted.ShouldDelete())
+
+	resp = mp.fsmRenewOrphanSession(&fsmOrphanRequest{Session: "a", Now: now + 1})
+	require.Equal(t, 1, resp.Count)
+	resp = mp.fsmReapOrphanInodes(&fsmOrphanRequest{Now: now + 1})
+	require.Equal(t, 0, resp.Count)
+	require.False(t, orphan.ShouldDelete())
+
+	// the inodes of the expired session are evicted
+	resp = mp.fsmReapOrphanInodes(&fsmOrphanRequest{Now: now + 1 + proto.OrphanSessionLease})
+	require.Equal(t, 1, resp.Count)
+	require.True(t, orphan.ShouldDelete())
+	require.Equal(t, 0, mp.orphans.len())
+}
@@ -83,3 +83,23 @@ func NewPacketToFreeInodeOnRaftFollower(partitionID uint64, freeInodes []byte) *
 
 	return p
 }
+
+// NewPacketToFreezeExtents returns a new packet to freeze the extents in all the replicas of the data partition.
+func NewPacketToFreezeExtents(dp *DataPartition, req *proto.FreezeExtentsRequest) *Packet {
+	p := new(Packet)
+	p.Magic = proto.ProtoMagic
+	p.Opcode = proto.OpFreezeExtents
+	p.ExtentType = proto.NormalExtentType
+	p.PartitionID = uint64(dp.PartitionID)
+	p.Data, _ = json.Marshal(req)
+	p.Size = uint32(len(p.Data))
+	p.ReqID = proto.GenerateRequestID()
+	p.RemainingFollowers = uint8(len(dp.Hosts) - 1)
+	if len(dp.Hosts) == 1 {
+		p.RemainingFollowers = 127
+	}
+	p.Arg = ([]byte)(dp.GetAllAddrs())
+	p.ArgLen = uint32(len(p.Arg))
+
+	return p
+}
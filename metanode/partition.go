@@ -73,8 +73,11 @@ type MetaPartitionConfig struct {
 	Start         uint64              `json:"start"` // Minimal Inode ID of this range. (Required during initialization)
 	End           uint64              `json:"end"`   // Maximal Inode ID of this range. (Required during initialization)
 	PartitionType int                 `json:"partition_type"`
-	Peers         []proto.Peer        `json:"peers"` // Peers information of the raftStore
-	Cursor        uint64              `json:"-"`     // Cursor ID of the inode that have been assigned
+	StoreMode     proto.MetaStoreMode `json:"store_mode"`
+	Peers         []proto.Peer        `json:"peers"`               // Peers information of the raftStore
+	Moves         []movedRange        `json:"moves"`               // The ranges split off the end of this range
+	Migration     *partitionMigration `json:"migration,omitempty"` // The split in progress
+	Cursor        uint64              `json:"-"`                   // Cursor ID of the inode that have been assigned
 	UniqId        uint64              `json:"-"`
 	NodeId        uint64              `json:"-"`
 	RootDir       string              `json:"-"`
@@ -140,6 +143,8 @@ type OpExtend interface {
 	BatchSetXAttr(req *proto.BatchSetXAttrRequest, p *Packet) (err error)
 	GetXAttr(req *proto.GetXAttrRequest, p *Packet) (err error)
 	GetAllXAttr(req *proto.GetAllXAttrRequest, p *Packet) (err error)
+	UpdateXAttrRecords(req *proto.UpdateXAttrRecordsRequest, p *Packet) (err error)
+	SnapshotVersion(req *proto.SnapshotVersionRequest, p *Packet) (err error)
 	BatchGetXAttr(req *proto.BatchGetXAttrRequest, p *Packet) (err error)
 	RemoveXAttr(req *proto.RemoveXAttrRequest, p *Packet) (err error)
 	ListXAttr(req *proto.ListXAttrRequest, p *Packet) (err error)
@@ -183,6 +188,8 @@ type OpExtent interface {
 	ExtentsList(req *proto.GetExtentsRequest, p *Packet) (err error)
 	ObjExtentsList(req *proto.GetExtentsRequest, p *Packet) (err error)
 	ExtentsTruncate(req *ExtentsTruncateReq, p *Packet) (err error)
+	ExtentsPunchHole(req *ExtentsPunchHoleReq, p *Packet) (err error)
+	CloneInode(req *proto.CloneInodeRequest, p *Packet) (err error)
 	BatchExtentAppend(req *proto.AppendExtentKeysRequest, p *Packet) (err error)
 	// ExtentsDelete(req *proto.DelExtentKeyRequest, p *Packet) (err error)
 }
@@ -208,6 +215,39 @@ type OpMeta interface {
 	OpMultipart
 	OpTransaction
 	OpQuota
+	OpFileLock
+	OpTrash
+	OpOrphan
+	OpChange
+}
+
+// OpFileLock defines the interface for the advisory file lock operations.
+type OpFileLock interface {
+	SetFileLock(req *proto.SetFileLockRequest, p *Packet) (err error)
+	GetFileLock(req *proto.GetFileLockRequest, p *Packet) (err error)
+	RenewFileLock(req *proto.RenewFileLockRequest, p *Packet) (err error)
+	ReleaseFileLocks(req *proto.ReleaseFileLocksRequest, p *Packet) (err error)
+}
+
+// OpTrash defines the interface for the trash operations.
+type OpTrash interface {
+	TrashInode(req *proto.TrashInodeRequest, p *Packet) (err error)
+	ListTrash(req *proto.ListTrashRequest, p *Packet) (err error)
+	RestoreTrash(req *proto.RestoreTrashRequest, p *Packet) (err error)
+	PurgeTrash(req *proto.PurgeTrashRequest, p *Packet) (err error)
+}
+
+// OpOrphan defines the interface for the orphan inodes held open by the client sessions.
+type OpOrphan interface {
+	AddOrphanInode(req *proto.AddOrphanInodeRequest, p *Packet) (err error)
+	RenewOrphanSession(req *proto.RenewOrphanSessionRequest, p *Packet) (err error)
+}
+
+// OpChange defines the interface for watching the changed inodes.
+type OpChange interface {
+	WatchChanges(req *proto.WatchChangesRequest, p *Packet) (err error)
+	ReadChangeFeed(req *proto.ReadChangeFeedRequest, p *Packet) (err error)
+	SnapshotProgress(req *proto.MetaSnapshotProgressRequest, p *Packet) (err error)
 }
 
 // OpPartition defines the interface for the partition operations.
@@ -229,6 +269,9 @@ type OpPartition interface {
 	CanRemoveRaftMember(peer proto.Peer) error
 	IsEquareCreateMetaPartitionRequst(request *proto.CreateMetaPartitionRequest) (err error)
 	GetUniqID(p *Packet, num uint32) (err error)
+	MigratePartition(req *proto.MigrateMetaPartitionRequest) (err error)
+	MigrateItems(p *Packet) (err error)
+	ServeMoved(p *Packet) bool
 }
 
 // MetaPartition defines the interface for the meta partition operations.
@@ -474,6 +517,18 @@ type metaPartition struct {
 	mqMgr                  *MetaQuotaManager
 	nonIdempotent          sync.Mutex
 	uniqChecker            *uniqChecker
+	fileLocks              *fileLockTable
+	extentRefs             *extentRefTable
+	trash                  *trashTable
+	orphans                *orphanTable
+	changes                *changeNotifier
+	feed                   *changeFeed
+	kv                     KVStore         // keeps the inode, dentry, extend and multipart trees in MetaStoreModeRocksDB
+	snapshotOverlay        snapshotOverlay // the deltas of the snapshot being loaded
+	snapshotCache          snapshotCache   // the raft snapshot kept by the leader to resume the transfer
+	migration              migrationState  // the split of the partition in progress
+	versions               versionTable    // the snapshot versions of the trees
+	versionGate            sync.RWMutex    // orders the writes submitted with the fence of the snapshot version
 }
 
 func (mp *metaPartition) acucumRebuildStart() bool {
@@ -584,6 +639,7 @@ func (mp *metaPartition) onStart(isCreate bool) (err error) {
 			mp.config.PartitionId, err.Error())
 		return
 	}
+	mp.feed.reset(mp.getApplyID())
 	mp.startScheduleTask()
 	if err = mp.startFreeList(); err != nil {
 		err = errors.NewErrorf("[onStart] start free list id=%d: %s",
@@ -604,6 +660,7 @@ func (mp *metaPartition) onStart(isCreate bool) (err error) {
 	}
 
 	mp.vol.volDeleteLockTime = volumeInfo.DeleteLockTime
+	mp.vol.volTrashInterval = volumeInfo.TrashInterval
 
 	mp.volType = volumeInfo.VolType
 	var ebsClient *blobstore.BlobStoreClient
@@ -661,6 +718,7 @@ func (mp *metaPartition) onStop() {
 		mp.delInodeFp.Sync()
 		mp.delInodeFp.Close()
 	}
+	mp.closeKVStore()
 }
 
 func (mp *metaPartition) startRaft() (err error) {
@@ -746,6 +804,12 @@ func NewMetaPartition(conf *MetaPartitionConfig, manager *metadataManager) MetaP
 		vol:           NewVol(),
 		manager:       manager,
 		uniqChecker:   newUniqChecker(),
+		fileLocks:     newFileLockTable(),
+		extentRefs:    newExtentRefTable(),
+		trash:         newTrashTable(),
+		orphans:       newOrphanTable(),
+		changes:       newChangeNotifier(),
+		feed:          newChangeFeed(),
 	}
 	mp.txProcessor = NewTransactionProcessor(mp)
 	return mp
@@ -844,6 +908,13 @@ const (
 	CRC_COUNT_BASIC      int = 4
 	CRC_COUNT_TX_STUFF   int = 7
 	CRC_COUNT_UINQ_STUFF int = 8
+	CRC_COUNT_FILE_LOCK  int = 9
+	CRC_COUNT_EXTENT_REF int = 10
+	CRC_COUNT_TRASH      int = 11
+	CRC_COUNT_ORPHAN     int = 12
+	CRC_COUNT_VERSION    int = 13
+	CRC_COUNT_FEED       int = 14
+	CRC_COUNT_SPLIT      int = 15
 )
 
 func (mp *metaPartition) LoadSnapshot(snapshotPath string) (err error) {
@@ -858,9 +929,23 @@ func (mp *metaPartition) LoadSnapshot(snapshotPath string) (err error) {
 		nil, //loading quota info from extend requires mp.loadInode() has been completed, so skip mp.loadExtend() here
 		mp.loadMultipart,
 	}
+	if mp.kv != nil {
+		// the trees are kept in the kv store
+		loadFuncs[0], loadFuncs[1], loadFuncs[3] = nil, nil, nil
+	} else {
+		if mp.snapshotOverlay, err = loadSnapshotOverlay(snapshotPath); err != nil {
+			return
+		}
+		defer func() {
+			mp.snapshotOverlay = nil
+		}()
+	}
 
 	crc_count := len(crcs)
-	if crc_count != CRC_COUNT_BASIC && crc_count != CRC_COUNT_TX_STUFF && crc_count != CRC_COUNT_UINQ_STUFF {
+	if crc_count != CRC_COUNT_BASIC && crc_count != CRC_COUNT_TX_STUFF && crc_count != CRC_COUNT_UINQ_STUFF &&
+		crc_count != CRC_COUNT_FILE_LOCK && crc_count != CRC_COUNT_EXTENT_REF && crc_count != CRC_COUNT_TRASH &&
+		crc_count != CRC_COUNT_ORPHAN && crc_count != CRC_COUNT_VERSION && crc_count != CRC_COUNT_FEED &&
+		crc_count != CRC_COUNT_SPLIT {
 		log.LogErrorf("action[LoadSnapshot] crc array length %d not match", len(crcs))
 		return ErrSnapshotCrcMismatch
 	}
@@ -874,10 +959,31 @@ func (mp *metaPartition) LoadSnapshot(snapshotPath string) (err error) {
 		loadFuncs = append(loadFuncs, mp.loadTxRbInode)
 		loadFuncs = append(loadFuncs, mp.loadTxRbDentry)
 	}
-	if crc_count == CRC_COUNT_UINQ_STUFF {
+	if crc_count >= CRC_COUNT_UINQ_STUFF {
 		needLoadUniqStuff = true
 		loadFuncs = append(loadFuncs, mp.loadUniqChecker)
 	}
+	if crc_count >= CRC_COUNT_FILE_LOCK {
+		loadFuncs = append(loadFuncs, mp.loadFileLocks)
+	}
+	if crc_count >= CRC_COUNT_EXTENT_REF {
+		loadFuncs = append(loadFuncs, mp.loadExtentRefs)
+	}
+	if crc_count >= CRC_COUNT_TRASH {
+		loadFuncs = append(loadFuncs, mp.loadTrash)
+	}
+	if crc_count >= CRC_COUNT_ORPHAN {
+		loadFuncs = append(loadFuncs, mp.loadOrphans)
+	}
+	if crc_count >= CRC_COUNT_VERSION {
+		loadFuncs = append(loadFuncs, mp.loadVersions)
+	}
+	if crc_count >= CRC_COUNT_FEED {
+		loadFuncs = append(loadFuncs, mp.loadChangeFeed)
+	}
+	if crc_count == CRC_COUNT_SPLIT {
+		loadFuncs = append(loadFuncs, mp.loadSplitState)
+	}
 
 	errs := make([]error, len(loadFuncs))
 	var wg sync.WaitGroup
@@ -914,7 +1020,12 @@ func (mp *metaPartition) LoadSnapshot(snapshotPath string) (err error) {
 		}
 	}
 
-	if err = mp.loadExtend(snapshotPath, crcs[2]); err != nil {
+	if mp.kv != nil {
+		err = mp.loadKVStore()
+	} else {
+		err = mp.loadExtend(snapshotPath, crcs[2])
+	}
+	if err != nil {
 		return
 	}
 
@@ -937,6 +1048,17 @@ func (mp *metaPartition) load(isCreate bool) (err error) {
 	if err = mp.loadMetadata(); err != nil {
 		return
 	}
+	defer func() {
+		if err == nil {
+			mp.trackSnapshotChanges(mp.applyID)
+		}
+	}()
+
+	if mp.kvMode() {
+		if err = mp.openKVStore(); err != nil {
+			return
+		}
+	}
 
 	// 1. create new metaPartition, no need to load snapshot
 	// 2. store the snapshot files for new mp, because
@@ -949,6 +1071,12 @@ func (mp *metaPartition) load(isCreate bool) (err error) {
 		return
 	}
 
+	if mp.kv != nil {
+		if err = mp.recoverKVSnapshot(); err != nil {
+			return
+		}
+	}
+
 	snapshotPath := path.Join(mp.config.RootDir, snapshotDir)
 	if _, err = os.Stat(snapshotPath); err != nil {
 		log.LogErrorf("load snapshot failed, err: %s", err.Error())
@@ -986,6 +1114,27 @@ func (mp *metaPartition) store(sm *storeMsg) (err error) {
 		mp.storeTxRbInode,
 		mp.storeTxRbDentry,
 		mp.storeUniqChecker,
+		mp.storeFileLocks,
+		mp.storeExtentRefs,
+		mp.storeTrash,
+		mp.storeOrphans,
+		mp.storeVersions,
+		mp.storeChangeFeed,
+		mp.storeSplitState,
+	}
+	if mp.kv != nil {
+		// the trees are flushed to the kv store below, only the statistics are collected
+		storeFuncs[0], storeFuncs[1], storeFuncs[2], storeFuncs[3] = mp.storeKVInode, mp.storeKVDentry, mp.storeKVExtend, mp.storeKVMultipart
+	}
+	snap := mp.newSnapshotStore(sm)
+	if snap != nil && !snap.base {
+		if err = snap.storeDelta(mp, tmpDir, sm); err == nil {
+			copy(storeFuncs, snap.storeFuncs(mp))
+		} else {
+			log.LogWarnf("metaPartition %d store delta failed, store the base instead: %v", mp.config.PartitionId, err)
+			snap.useBase(sm)
+			err = nil
+		}
 	}
 	for _, storeFunc := range storeFuncs {
 		var crc uint32
@@ -1007,11 +1156,22 @@ func (mp *metaPartition) store(sm *storeMsg) (err error) {
 	if err = mp.storeUniqID(tmpDir, sm); err != nil {
 		return
 	}
+	if snap != nil {
+		if err = snap.manifest.store(tmpDir); err != nil {
+			return
+		}
+	}
 
 	// write crc to file
 	if err = ioutil.WriteFile(path.Join(tmpDir, SnapshotSign), crcBuffer.Bytes(), 0775); err != nil {
 		return
 	}
+	// flush the kv store after the snapshot is complete, see recoverKVSnapshot
+	if mp.kv != nil {
+		if err = mp.flushKVStore(sm); err != nil {
+			return
+		}
+	}
 	snapshotDir := path.Join(mp.config.RootDir, snapshotDir)
 	// check snapshot backup
 	backupDir := path.Join(mp.config.RootDir, snapshotBackup)
@@ -1040,6 +1200,13 @@ func (mp *metaPartition) store(sm *storeMsg) (err error) {
 	}
 
 	mp.storedApplyId = sm.applyIndex
+	if err = removeStaleVersionFiles(mp.config.RootDir, sm.versions); err != nil {
+		log.LogWarnf("metaPartition %d remove stale version files: %v", mp.config.PartitionId, err)
+		err = nil
+	}
+	if snap != nil {
+		snap.trim(mp, sm.applyIndex)
+	}
 	return
 }
 
@@ -1330,6 +1497,11 @@ func (mp *metaPartition) storeSnapshotFiles() (err error) {
 		txRbDentryTree: NewBtree(),
 		uniqId:         mp.GetUniqId(),
 		uniqChecker:    newUniqChecker(),
+		fileLocks:      newFileLockTable(),
+		extentRefs:     newExtentRefTable(),
+		trash:          newTrashTable(),
+		orphans:        newOrphanTable(),
+		versions:       newVersionTable(),
 	}
 
 	return mp.store(msg)
@@ -1347,6 +1519,15 @@ func (mp *metaPartition) startCheckerEvict() {
 				} else {
 					log.LogDebugf("[uniqChecker] after doEvict partition-%d, left:%d, evict:%d, err:%v", mp.config.PartitionId, left, evict, err)
 				}
+				if evict, err = mp.evictFileLocks(); err != nil {
+					log.LogWarnf("[fileLocks] evict expired locks partition-%d, evict:%d, err:%v", mp.config.PartitionId, evict, err)
+				}
+				if evict, err = mp.purgeExpiredTrash(); err != nil {
+					log.LogWarnf("[trash] purge expired entries partition-%d, purge:%d, err:%v", mp.config.PartitionId, evict, err)
+				}
+				if evict, err = mp.evictOrphanInodes(); err != nil {
+					log.LogWarnf("[orphans] evict orphan inodes of expired sessions partition-%d, evict:%d, err:%v", mp.config.PartitionId, evict, err)
+				}
 			}
 			timer.Reset(opCheckerInterval)
 		case <-mp.stopC:
@@ -71,6 +71,7 @@ func (mp *metaPartition) updateVolView(convert func(view *proto.DataPartitionsVi
 		return
 	}
 	mp.vol.volDeleteLockTime = volView.DeleteLockTime
+	mp.vol.volTrashInterval = volView.TrashInterval
 	return nil
 }
 
@@ -161,8 +162,10 @@ func (mp *metaPartition) deleteWorker() {
 			//check inode nlink == 0 and deleteMarkFlag unset
 			if inode, ok := mp.inodeTree.Get(&Inode{Inode: ino}).(*Inode); ok {
 				inTx, _ := mp.txProcessor.txResource.isInodeInTransction(inode)
-				if inode.ShouldDelayDelete() || inTx {
-					log.LogDebugf("[metaPartition] deleteWorker delay to remove inode: %v as NLink is 0, inTx %v", inode, inTx)
+				// the orphan inode is kept until it is evicted, or the session holding it open is expired
+				orphan := mp.orphans.has(ino)
+				if inode.ShouldDelayDelete() || inTx || orphan {
+					log.LogDebugf("[metaPartition] deleteWorker delay to remove inode: %v as NLink is 0, inTx %v, orphan %v", inode, inTx, orphan)
 					delayDeleteInos = append(delayDeleteInos, ino)
 					continue
 				}
@@ -257,8 +260,10 @@ func (mp *metaPartition) deleteMarkedInodes(inoSlice []uint64) {
 			continue
 		}
 
-		inode.Extents.Range(func(ek proto.ExtentKey) bool {
-			ext := &ek
+		// the data shared with the other inodes is kept, the references are dropped when the inode is deleted
+		owned, _ := mp.extentRefs.split(inode.Extents.CopyExtents())
+		for i := range owned {
+			ext := &owned[i]
 			exts, ok := deleteExtentsByPartition[ext.PartitionId]
 			if !ok {
 				exts = make([]*proto.ExtentKey, 0)
@@ -266,8 +271,7 @@ func (mp *metaPartition) deleteMarkedInodes(inoSlice []uint64) {
 			exts = append(exts, ext)
 			log.LogWritef("mp(%v) ino(%v) deleteExtent(%v)", mp.config.PartitionId, inode.Inode, ext.String())
 			deleteExtentsByPartition[ext.PartitionId] = exts
-			return true
-		})
+		}
 
 		allInodes = append(allInodes, inode)
 	}
@@ -0,0 +1,125 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/storage"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// cloneFreezeRetry is the times the clone freezes the extents again, if the source is written meanwhile.
+const cloneFreezeRetry = 3
+
+// frozenExtent is an extent frozen in the data nodes, which is not overwritten in place any more, see
+// proto.FreezeExtentsRequest. The tiny extent is frozen below the offset only.
+type frozenExtent struct {
+	PartitionId uint64 `json:"dp"`
+	ExtentId    uint64 `json:"eid"`
+	Offset      uint64 `json:"off,omitempty"`
+}
+
+// extentsFrozen checks that the data of the keys are all frozen.
+func extentsFrozen(eks []proto.ExtentKey, frozen []frozenExtent) bool {
+	offsets := make(map[frozenExtent]uint64, len(frozen))
+	for _, f := range frozen {
+		offsets[frozenExtent{PartitionId: f.PartitionId, ExtentId: f.ExtentId}] = f.Offset
+	}
+	for _, ek := range eks {
+		offset, ok := offsets[frozenExtent{PartitionId: ek.PartitionId, ExtentId: ek.ExtentId}]
+		if !ok {
+			return false
+		}
+		if storage.IsTinyExtent(ek.ExtentId) && ek.ExtentOffset+uint64(ek.Size) > offset {
+			return false
+		}
+	}
+	return true
+}
+
+// freezeExtents freezes the extents of the keys in the data nodes, so that the data shared by the inodes is
+// not overwritten in place, and returns the extents frozen.
+func (mp *metaPartition) freezeExtents(eks []proto.ExtentKey) (frozen []frozenExtent, err error) {
+	extents := make(map[uint64]map[uint64]struct{})
+	for _, ek := range eks {
+		if extents[ek.PartitionId] == nil {
+			extents[ek.PartitionId] = make(map[uint64]struct{})
+		}
+		extents[ek.PartitionId][ek.ExtentId] = struct{}{}
+	}
+	frozen = make([]frozenExtent, 0)
+	for partitionID, ids := range extents {
+		extentIDs := make([]uint64, 0, len(ids))
+		for extentID := range ids {
+			extentIDs = append(extentIDs, extentID)
+		}
+		sort.Slice(extentIDs, func(i, j int) bool { return extentIDs[i] < extentIDs[j] })
+		var state *proto.FrozenExtents
+		if state, err = mp.freezeExtentsByPartition(partitionID, &proto.FreezeExtentsRequest{Extents: extentIDs}); err != nil {
+			return
+		}
+		for _, extentID := range extentIDs {
+			f := frozenExtent{PartitionId: partitionID, ExtentId: extentID}
+			if storage.IsTinyExtent(extentID) {
+				f.Offset = uint64(state.Tiny[extentID])
+			}
+			frozen = append(frozen, f)
+		}
+	}
+	return
+}
+
+func (mp *metaPartition) freezeExtentsByPartition(partitionID uint64, req *proto.FreezeExtentsRequest) (state *proto.FrozenExtents, err error) {
+	dp := mp.vol.GetPartition(partitionID)
+	if dp == nil || len(dp.Hosts) < 1 {
+		err = errors.NewErrorf("unknown dataPartitionID=%d in vol", partitionID)
+		return
+	}
+	addr := util.ShiftAddrPort(dp.Hosts[0], smuxPortShift)
+	conn, err := smuxPool.GetConnect(addr)
+	if err != nil {
+		err = errors.NewErrorf("get conn from pool %s, extents partitionId=%d", err.Error(), partitionID)
+		return
+	}
+	defer func() {
+		smuxPool.PutConnect(conn, err != nil)
+	}()
+
+	p := NewPacketToFreezeExtents(dp, req)
+	if err = p.WriteToConn(conn); err != nil {
+		err = errors.NewErrorf("write to dataNode %s, %s", p.GetUniqueLogId(), err.Error())
+		return
+	}
+	if err = p.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+		err = errors.NewErrorf("read response from dataNode %s, %s", p.GetUniqueLogId(), err.Error())
+		return
+	}
+	if p.ResultCode != proto.OpOk {
+		err = errors.NewErrorf("freeze extents %s response: %s", p.GetUniqueLogId(), p.GetResultMsg())
+		return
+	}
+	state = new(proto.FrozenExtents)
+	if err = json.Unmarshal(p.Data[:p.Size], state); err != nil {
+		return
+	}
+	log.LogDebugf("freezeExtentsByPartition: mp(%v) dp(%v) all(%v) extents(%v)",
+		mp.config.PartitionId, partitionID, req.All, len(req.Extents))
+	return
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtentsFrozen(t *testing.T) {
+	eks := []proto.ExtentKey{
+		{FileOffset: 0, PartitionId: 1, ExtentId: 1025, Size: 4096},
+		{FileOffset: 4096, PartitionId: 1, ExtentId: 1, ExtentOffset: 8192, Size: 100},
+	}
+	require.True(t, extentsFrozen(nil, nil))
+	require.False(t, extentsFrozen(eks, nil))
+	require.False(t, extentsFrozen(eks, []frozenExtent{{PartitionId: 1, ExtentId: 1025}}))
+	// the tiny extent is appended after it is frozen
+	require.False(t, extentsFrozen(eks, []frozenExtent{
+		{PartitionId: 1, ExtentId: 1025},
+		{PartitionId: 1, ExtentId: 1, Offset: 8192},
+	}))
+	require.True(t, extentsFrozen(eks, []frozenExtent{
+		{PartitionId: 1, ExtentId: 1025},
+		{PartitionId: 1, ExtentId: 1, Offset: 8292},
+	}))
+	// the same extent id of another data partition
+	require.False(t, extentsFrozen(eks, []frozenExtent{
+		{PartitionId: 2, ExtentId: 1025},
+		{PartitionId: 1, ExtentId: 1, Offset: 8292},
+	}))
+}
+
+func TestFsmCloneInodeFrozen(t *testing.T) {
+	rootDir := "/tmp/testFsmCloneInodeFrozen/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+	mp := newSnapshotTestPartition(t, rootDir, 0)
+	mp.uidManager = NewUidMgr("test_vol", 1)
+
+	src := NewInode(10, 0)
+	src.Extents.Append(proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: 1025, Size: 4096})
+	src.Size = 4096
+	mp.inodeTree.ReplaceOrInsert(src, true)
+
+	req := &fsmCloneInodeRequest{Inode: 10, DstInode: 11, Create: true, Frozen: []frozenExtent{}}
+	// the source is written since the extents are frozen
+	resp := mp.fsmCloneInode(req)
+	require.Equal(t, proto.OpAgain, resp.Status)
+	require.Nil(t, mp.inodeTree.Get(NewInode(11, 0)))
+
+	req.Frozen = []frozenExtent{{PartitionId: 1, ExtentId: 1025}}
+	resp = mp.fsmCloneInode(req)
+	require.Equal(t, proto.OpOk, resp.Status)
+	dst := mp.inodeTree.Get(NewInode(11, 0)).(*Inode)
+	require.Equal(t, src.Extents.CopyExtents(), dst.Extents.CopyExtents())
+	require.Equal(t, uint32(2), mp.extentRefs.count(extentRef{PartitionId: 1, ExtentId: 1025}))
+
+	// the entries logged before the extents are frozen by the clones are applied as before
+	req = &fsmCloneInodeRequest{Inode: 10, DstInode: 12, Create: true}
+	resp = mp.fsmCloneInode(req)
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.Equal(t, uint32(3), mp.extentRefs.count(extentRef{PartitionId: 1, ExtentId: 1025}))
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/trace"
 )
 
 // Apply applies the given operational commands.
@@ -46,9 +47,22 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 	if err = msg.UnmarshalJson(command); err != nil {
 		return
 	}
+	if parent, ok := trace.ParseTraceparent(msg.Trace); ok {
+		span := trace.StartRemoteSpan(parent, "metanode.RaftApply")
+		span.SetTag("mp", mp.config.PartitionId)
+		span.SetTag("index", index)
+		defer func() {
+			span.SetError(err)
+			span.Finish()
+		}()
+	}
 
 	mp.nonIdempotent.Lock()
 	defer mp.nonIdempotent.Unlock()
+	mp.setTracking(index, true)
+	defer mp.setTracking(index, false)
+	mp.feed.begin(index)
+	defer mp.feed.end(index)
 
 	switch msg.Op {
 	case opFSMCreateInode:
@@ -105,6 +119,18 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			return
 		}
 		resp = mp.fsmExtentsTruncate(ino)
+	case opFSMExtentPunchHole:
+		req := &fsmPunchHoleRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmExtentsPunchHole(req)
+	case opFSMCloneInode:
+		req := &fsmCloneInodeRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmCloneInode(req)
 	case opFSMCreateLinkInode:
 		ino := NewInode(0, 0)
 		if err = ino.Unmarshal(msg.V); err != nil {
@@ -241,6 +267,11 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 		quotaRebuild := mp.mqMgr.statisticRebuildStart()
 		uidRebuild := mp.acucumRebuildStart()
 		uniqChecker := mp.uniqChecker.clone()
+		fileLocks := mp.fileLocks.clone()
+		extentRefs := mp.extentRefs.clone()
+		trash := mp.trash.clone()
+		orphans := mp.orphans.clone()
+		versions := mp.versions.clone()
 		msg := &storeMsg{
 			command:        opFSMStoreTick,
 			applyIndex:     index,
@@ -255,6 +286,13 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			quotaRebuild:   quotaRebuild,
 			uidRebuild:     uidRebuild,
 			uniqChecker:    uniqChecker,
+			fileLocks:      fileLocks,
+			extentRefs:     extentRefs,
+			trash:          trash,
+			orphans:        orphans,
+			versions:       versions,
+			feed:           mp.feed.tail(index),
+			split:          mp.splitState(),
 		}
 		log.LogDebugf("opFSMStoreTick: quotaRebuild [%v] uidRebuild [%v]", quotaRebuild, uidRebuild)
 		mp.storeChan <- msg
@@ -272,6 +310,39 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			return
 		}
 		err = mp.fsmSetXAttr(extend)
+	case opFSMShardDir:
+		var extend *Extend
+		if extend, err = NewExtendFromBytes(msg.V); err != nil {
+			return
+		}
+		resp = mp.fsmShardDir(extend)
+	case opFSMFenceDirShard, opFSMUnfenceDirShard:
+		ino := NewInode(0, 0)
+		if err = ino.Unmarshal(msg.V); err != nil {
+			return
+		}
+		resp = mp.fsmFenceDirShard(ino, msg.Op == opFSMFenceDirShard)
+	case opFSMUpdateXAttrRecords:
+		req := &fsmUpdateXAttrRecordsRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		resp = mp.fsmUpdateXAttrRecords(req)
+	case opFSMSnapshotPrepare, opFSMSnapshotCommit, opFSMSnapshotAbort, opFSMSnapshotDelete:
+		req := &fsmSnapshotVersionRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		switch msg.Op {
+		case opFSMSnapshotPrepare:
+			resp = mp.fsmSnapshotPrepare(req)
+		case opFSMSnapshotCommit:
+			resp = mp.fsmSnapshotCommit(req)
+		case opFSMSnapshotAbort:
+			resp = mp.fsmSnapshotAbort(req)
+		default:
+			resp = mp.fsmSnapshotDelete(req)
+		}
 	case opFSMRemoveXAttr:
 		var extend *Extend
 		if extend, err = NewExtendFromBytes(msg.V); err != nil {
@@ -429,6 +500,64 @@ func (mp *metaPartition) Apply(command []byte, index uint64) (resp interface{},
 			return
 		}
 		err = mp.fsmUniqCheckerEvict(req)
+	case opFSMSetFileLock, opFSMRenewFileLock, opFSMReleaseFileLocks, opFSMEvictFileLock:
+		req := &fsmFileLockRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		switch msg.Op {
+		case opFSMSetFileLock:
+			resp = mp.fsmSetFileLock(req)
+		case opFSMRenewFileLock:
+			resp = mp.fsmRenewFileLock(req)
+		case opFSMReleaseFileLocks:
+			resp = mp.fsmReleaseFileLocks(req)
+		default:
+			resp = mp.fsmEvictFileLock(req)
+		}
+	case opFSMTrashInode, opFSMRestoreTrash, opFSMPurgeTrash:
+		req := &fsmTrashRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		switch msg.Op {
+		case opFSMTrashInode:
+			resp = mp.fsmTrashInode(req)
+		case opFSMRestoreTrash:
+			resp = mp.fsmRestoreTrash(req)
+		default:
+			resp = mp.fsmPurgeTrash(req)
+		}
+	case opFSMAddOrphanInode, opFSMRenewOrphanSession, opFSMReapOrphanInodes:
+		req := &fsmOrphanRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		switch msg.Op {
+		case opFSMAddOrphanInode:
+			resp = mp.fsmAddOrphanInode(req)
+		case opFSMRenewOrphanSession:
+			resp = mp.fsmRenewOrphanSession(req)
+		default:
+			resp = mp.fsmReapOrphanInodes(req)
+		}
+	case opFSMMigrateStart, opFSMMigrateFreeze, opFSMMigrateCommit, opFSMMigrateAbort:
+		req := &fsmMigrateRequest{}
+		if err = json.Unmarshal(msg.V, req); err != nil {
+			return
+		}
+		switch msg.Op {
+		case opFSMMigrateStart:
+			resp = mp.fsmMigrateStart(req)
+		case opFSMMigrateFreeze:
+			resp = mp.fsmMigrateFreeze(req)
+		case opFSMMigrateCommit:
+			resp = mp.fsmMigrateCommit(req)
+		default:
+			resp = mp.fsmMigrateAbort()
+		}
+	case opFSMMigrateItems:
+		resp = mp.fsmMigrateItems(msg.V)
 	}
 
 	return
@@ -497,6 +626,12 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 		txRbInodeTree  = NewBtree()
 		txRbDentryTree = NewBtree()
 		uniqChecker    = newUniqChecker()
+		fileLocks      = newFileLockTable()
+		extentRefs     = newExtentRefTable()
+		trash          = newTrashTable()
+		orphans        = newOrphanTable()
+		versions       = newVersionTable()
+		split          *splitState
 	)
 
 	blockUntilStoreSnapshot := func() {
@@ -540,6 +675,10 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 
 	defer func() {
 		if err == io.EOF {
+			if err = finishKVSnapshotTrees(inodeTree, dentryTree, extendTree, multipartTree); err != nil {
+				log.LogErrorf("ApplySnapshot: finish kv trees: partitionID(%v) err(%v)", mp.config.PartitionId, err)
+				return
+			}
 			mp.applyID = appIndexID
 			mp.config.UniqId = uniqID
 			mp.txProcessor.txManager.txIdAlloc.setTransactionID(txID)
@@ -547,11 +686,22 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 			mp.dentryTree = dentryTree
 			mp.extendTree = extendTree
 			mp.multipartTree = multipartTree
+			mp.trackSnapshotChanges(mp.applyID)
+			mp.snapshotCache.reset()
 			mp.config.Cursor = cursor
 			mp.txProcessor.txManager.txTree = txTree
 			mp.txProcessor.txResource.txRbInodeTree = txRbInodeTree
 			mp.txProcessor.txResource.txRbDentryTree = txRbDentryTree
 			mp.uniqChecker = uniqChecker
+			mp.fileLocks = fileLocks
+			mp.extentRefs = extentRefs
+			mp.trash = trash
+			mp.orphans = orphans
+			mp.versions.replace(versions)
+			mp.restoreSplitState(split)
+			mp.persistMigration("ApplySnapshot")
+			mp.changes.reset()
+			mp.feed.reset(mp.applyID)
 
 			err = nil
 			// store message
@@ -567,6 +717,12 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 				txRbInodeTree:  mp.txProcessor.txResource.txRbInodeTree.GetTree(),
 				txRbDentryTree: mp.txProcessor.txResource.txRbDentryTree.GetTree(),
 				uniqChecker:    uniqChecker.clone(),
+				fileLocks:      fileLocks.clone(),
+				extentRefs:     extentRefs.clone(),
+				trash:          trash.clone(),
+				orphans:        orphans.clone(),
+				versions:       versions.clone(),
+				split:          mp.splitState(),
 			}
 			select {
 			case mp.extReset <- struct{}{}:
@@ -583,6 +739,18 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 		log.LogErrorf("ApplySnapshot: stop with error: partitionID(%v) err(%v)", mp.config.PartitionId, err)
 	}()
 
+	if mp.kv != nil {
+		var trees []*BTree
+		if trees, err = mp.newKVSnapshotTrees(); err != nil {
+			return
+		}
+		inodeTree, dentryTree, extendTree, multipartTree = trees[0], trees[1], trees[2], trees[3]
+	}
+
+	receiver := mp.newSnapshotReceiver(iter)
+	defer receiver.close()
+	iter = receiver
+
 	var leaderSnapFormatVer uint32
 	leaderSnapFormatVer = math.MaxUint32
 
@@ -711,6 +879,60 @@ func (mp *metaPartition) ApplySnapshot(peers []raftproto.Peer, iter raftproto.Sn
 				return
 			}
 			log.LogDebugf("ApplySnapshot: write snap uniqChecker")
+		case opFSMFileLockSnap:
+			if err = fileLocks.UnMarshal(snap.V); err != nil {
+				log.LogErrorf("ApplySnapshot: unmarshal snap file locks fail: partitionID(%v) err(%v)",
+					mp.config.PartitionId, err)
+				return
+			}
+			log.LogDebugf("ApplySnapshot: write snap file locks: partitionID(%v)", mp.config.PartitionId)
+		case opFSMExtentRefSnap:
+			if err = extentRefs.UnMarshal(snap.V); err != nil {
+				log.LogErrorf("ApplySnapshot: unmarshal snap extent refs fail: partitionID(%v) err(%v)",
+					mp.config.PartitionId, err)
+				return
+			}
+			log.LogDebugf("ApplySnapshot: write snap extent refs: partitionID(%v)", mp.config.PartitionId)
+		case opFSMTrashSnap:
+			if err = trash.UnMarshal(snap.V); err != nil {
+				log.LogErrorf("ApplySnapshot: unmarshal snap trash fail: partitionID(%v) err(%v)",
+					mp.config.PartitionId, err)
+				return
+			}
+			log.LogDebugf("ApplySnapshot: write snap trash: partitionID(%v)", mp.config.PartitionId)
+		case opFSMOrphanSnap:
+			if err = orphans.UnMarshal(snap.V); err != nil {
+				log.LogErrorf("ApplySnapshot: unmarshal snap orphans fail: partitionID(%v) err(%v)",
+					mp.config.PartitionId, err)
+				return
+			}
+			log.LogDebugf("ApplySnapshot: write snap orphans: partitionID(%v)", mp.config.PartitionId)
+		case opFSMSnapVersionState:
+			if err = versions.UnMarshal(snap.V); err != nil {
+				log.LogErrorf("ApplySnapshot: unmarshal snap versions fail: partitionID(%v) err(%v)",
+					mp.config.PartitionId, err)
+				return
+			}
+			log.LogDebugf("ApplySnapshot: write snap versions: partitionID(%v)", mp.config.PartitionId)
+		case opFSMSplitStateSnap:
+			split = &splitState{}
+			if err = split.UnMarshal(snap.V); err != nil {
+				log.LogErrorf("ApplySnapshot: unmarshal snap split state fail: partitionID(%v) err(%v)",
+					mp.config.PartitionId, err)
+				return
+			}
+			log.LogDebugf("ApplySnapshot: write snap split state: partitionID(%v)", mp.config.PartitionId)
+		case opFSMSnapVersionItems:
+			v := versions.get(binary.BigEndian.Uint64(snap.K))
+			if v == nil {
+				err = fmt.Errorf("items of unknown version %v", binary.BigEndian.Uint64(snap.K))
+				return
+			}
+			if err = applyVersionItems(v, snap.V); err != nil {
+				log.LogErrorf("ApplySnapshot: apply snap version items fail: partitionID(%v) version(%v) err(%v)",
+					mp.config.PartitionId, v.id, err)
+				return
+			}
 
 		default:
 			if leaderSnapFormatVer != math.MaxUint32 && leaderSnapFormatVer > mp.manager.metaNode.raftSyncSnapFormatVersion {
@@ -780,11 +1002,37 @@ func (mp *metaPartition) HandleLeaderChange(leader uint64) {
 
 // Put puts the given key-value pair (operation key and operation request) into the raft store.
 func (mp *metaPartition) submit(op uint32, data []byte) (resp interface{}, err error) {
+	return mp.submitTraced(nil, op, data)
+}
+
+// submitTraced submits the op of the request, the submit and the apply on each replica are traced
+// as the children of the request if it's traced.
+func (mp *metaPartition) submitTraced(p *Packet, op uint32, data []byte) (resp interface{}, err error) {
+	var span *trace.Span
+	if p != nil {
+		span = trace.StartRemoteSpan(p.Trace, "metanode.RaftSubmit")
+		span.SetTag("op", op)
+		defer func() {
+			span.SetError(err)
+			span.Finish()
+		}()
+	}
+	if versionFenced(op) {
+		// the fence is checked and the op is submitted atomically against the prepare of the snapshot version
+		mp.versionGate.RLock()
+		defer mp.versionGate.RUnlock()
+		if err = mp.checkVersionFence(); err != nil {
+			return
+		}
+	}
 	snap := NewMetaItem(0, nil, nil)
 	snap.Op = op
 	if data != nil {
 		snap.V = data
 	}
+	if sc := span.Context(); sc.IsValid() {
+		snap.Trace = sc.Traceparent()
+	}
 	cmd, err := snap.MarshalJson()
 	if err != nil {
 		return
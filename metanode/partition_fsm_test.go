@@ -0,0 +1,91 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/trace"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaPartition_SubmitTraced(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testMetaPartitionSubmitTraced/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+	mp := newMigrateTestPartition(t, ctrl, rootDir, 1, 1, 1000)
+	mp.inodeTree.ReplaceOrInsert(NewInode(1, uint32(os.ModeDir)), true)
+
+	filename := path.Join(t.TempDir(), "trace.json")
+	cfg := config.LoadConfigString(fmt.Sprintf(`{"%v": "file", "%v": "%v"}`,
+		trace.ConfigKeyTraceExporter, trace.ConfigKeyTraceFile, filename))
+	require.NoError(t, trace.Init("metanode", cfg))
+
+	request := trace.StartRemoteSpan(trace.SpanContext{TraceID: [16]byte{1}, SpanID: [8]byte{2}, Flags: trace.FlagSampled},
+		"metanode.OpMetaCreateDentry")
+	p := &Packet{}
+	p.Trace = request.Context()
+	val, err := (&Dentry{ParentId: 1, Name: "f", Inode: 2}).Marshal()
+	require.NoError(t, err)
+	_, err = mp.submitTraced(p, opFSMCreateDentry, val)
+	require.NoError(t, err)
+	// the ops not traced are applied as before
+	_, err = mp.submit(opFSMDeleteDentry, val)
+	require.NoError(t, err)
+	request.Finish()
+	trace.Close()
+
+	file, err := os.Open(filename)
+	require.NoError(t, err)
+	defer file.Close()
+	type span struct {
+		SpanID       string `json:"spanId"`
+		ParentSpanID string `json:"parentSpanId"`
+		Name         string `json:"name"`
+	}
+	spans := make(map[string][]span)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var traces struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []span `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &traces))
+		for _, resource := range traces.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				for _, s := range scope.Spans {
+					spans[s.Name] = append(spans[s.Name], s)
+				}
+			}
+		}
+	}
+	require.Len(t, spans["metanode.RaftSubmit"], 1)
+	require.Len(t, spans["metanode.RaftApply"], 1)
+	submit := spans["metanode.RaftSubmit"][0]
+	require.Equal(t, spans["metanode.OpMetaCreateDentry"][0].SpanID, submit.ParentSpanID)
+	require.Equal(t, submit.SpanID, spans["metanode.RaftApply"][0].ParentSpanID)
+}
@@ -0,0 +1,185 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// fsmCloneInodeRequest carries the destination inode allocated by the leader if it is created by the clone.
+type fsmCloneInodeRequest struct {
+	Inode      uint64 `json:"ino"`
+	Offset     uint64 `json:"off"`
+	Size       uint64 `json:"sz"`
+	DstInode   uint64 `json:"dino"`
+	DstOffset  uint64 `json:"doff"`
+	Create     bool   `json:"create"`
+	Mode       uint32 `json:"mode"`
+	Uid        uint32 `json:"uid"`
+	Gid        uint32 `json:"gid"`
+	ModifyTime int64  `json:"mt"`
+	Version    uint64 `json:"ver,omitempty"` // the snapshot version the source is read from
+	// the extents of the range frozen by the leader, the entries logged before the extents are frozen
+	// by the clones carry none
+	Frozen []frozenExtent `json:"frozen,omitempty"`
+}
+
+// cloneRange returns the keys of the range of the source inode to clone, and the size of the range.
+func cloneRange(src *Inode, offset, size, dstOffset uint64) ([]proto.ExtentKey, uint64) {
+	src.RLock()
+	fileSize := src.Size
+	src.RUnlock()
+	if offset >= fileSize {
+		fileSize = 0
+	} else if fileSize -= offset; size > 0 && size < fileSize {
+		fileSize = size
+	}
+	return src.Extents.CopyRange(offset, fileSize, dstOffset), fileSize
+}
+
+func (mp *metaPartition) fsmCloneInode(req *fsmCloneInodeRequest) (resp *InodeResponse) {
+	resp = NewInodeResponse()
+
+	resp.Status = proto.OpOk
+	var item BtreeItem
+	if req.Version == 0 {
+		item = mp.inodeTree.CopyGet(NewInode(req.Inode, 0))
+	} else if v := mp.versions.get(req.Version); v != nil {
+		item = v.inodeTree.Get(NewInode(req.Inode, 0))
+	}
+	if item == nil {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	src := item.(*Inode)
+	if src.ShouldDelete() {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	if !proto.IsRegular(src.Type) {
+		resp.Status = proto.OpArgMismatchErr
+		return
+	}
+
+	eks, size := cloneRange(src, req.Offset, req.Size, req.DstOffset)
+	if req.Frozen != nil && !extentsFrozen(eks, req.Frozen) {
+		// the source is written since the extents are frozen, the leader freezes them again
+		resp.Status = proto.OpAgain
+		return
+	}
+
+	var dst *Inode
+	if req.Create {
+		dst = NewInode(req.DstInode, req.Mode)
+		dst.Uid = req.Uid
+		dst.Gid = req.Gid
+		dst.CreateTime, dst.AccessTime, dst.ModifyTime = req.ModifyTime, req.ModifyTime, req.ModifyTime
+		if resp.Status = mp.uidManager.addUidSpace(dst.Uid, dst.Inode, nil); resp.Status != proto.OpOk {
+			return
+		}
+		if _, ok := mp.inodeTree.ReplaceOrInsert(dst, false); !ok {
+			resp.Status = proto.OpExistErr
+			return
+		}
+		mp.feed.inode(proto.ChangeCreateInode, dst.Inode)
+	} else {
+		if item = mp.inodeTree.CopyGet(NewInode(req.DstInode, 0)); item == nil {
+			resp.Status = proto.OpNotExistErr
+			return
+		}
+		dst = item.(*Inode)
+		if dst.ShouldDelete() {
+			resp.Status = proto.OpNotExistErr
+			return
+		}
+		if !proto.IsRegular(dst.Type) {
+			resp.Status = proto.OpArgMismatchErr
+			return
+		}
+	}
+
+	if size > 0 {
+		// the data of the range in the destination is replaced
+		removed, delExtents := dst.Extents.PunchHole(req.DstOffset, size)
+		delExtents = mp.releaseExtents(dst, delExtents)
+		if len(delExtents) > 0 {
+			mp.extDelCh <- delExtents
+		}
+		mp.uidManager.minusUidSpace(dst.Uid, dst.Inode, removed)
+	}
+
+	held := extentRefSet(dst.Extents.CopyExtents())
+	for _, ek := range eks {
+		for _, ref := range extentRefsOf(&ek) {
+			if _, ok := held[ref]; ok {
+				continue
+			}
+			held[ref] = struct{}{}
+			mp.extentRefs.get(ref)
+		}
+	}
+	mp.uidManager.addUidSpace(dst.Uid, dst.Inode, eks)
+
+	dst.Lock()
+	oldSize := dst.Size
+	for _, ek := range eks {
+		dst.Extents.Append(ek)
+	}
+	if end := req.DstOffset + size; end > dst.Size {
+		dst.Size = end
+	}
+	dst.ModifyTime = req.ModifyTime
+	dst.Generation++
+	dst.Unlock()
+	mp.updateUsedInfo(int64(dst.Size)-int64(oldSize), 0, dst.Inode)
+	mp.feed.inode(proto.ChangeExtents, dst.Inode)
+
+	log.LogInfof("fsmCloneInode: partitionID(%v) inode(%v) offset(%v) size(%v) dstInode(%v) dstOffset(%v) eks(%v)",
+		mp.config.PartitionId, req.Inode, req.Offset, size, req.DstInode, req.DstOffset, len(eks))
+	resp.Msg = dst
+	return
+}
+
+// releaseExtents drops the references of the shared data which is no longer referenced by the inode,
+// and returns the extents which can be deleted from the data nodes.
+func (mp *metaPartition) releaseExtents(ino *Inode, eks []proto.ExtentKey) []proto.ExtentKey {
+	owned, shared := mp.extentRefs.split(eks)
+	if len(shared) == 0 {
+		return owned
+	}
+	held := extentRefSet(ino.Extents.CopyExtents())
+	for _, ref := range shared {
+		if _, ok := held[ref]; ok {
+			continue
+		}
+		held[ref] = struct{}{}
+		mp.extentRefs.put(ref)
+	}
+	return owned
+}
+
+// releaseInodeExtents drops the references of the shared data held by the inode deleted.
+func (mp *metaPartition) releaseInodeExtents(ino *Inode) {
+	_, shared := mp.extentRefs.split(ino.Extents.CopyExtents())
+	released := make(map[extentRef]struct{}, len(shared))
+	for _, ref := range shared {
+		if _, ok := released[ref]; ok {
+			continue
+		}
+		released[ref] = struct{}{}
+		mp.extentRefs.put(ref)
+	}
+}
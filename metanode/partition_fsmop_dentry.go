@@ -18,7 +18,6 @@ import (
 	"strings"
 
 	"github.com/cubefs/cubefs/proto"
-	"github.com/cubefs/cubefs/util/btree"
 	"github.com/cubefs/cubefs/util/log"
 )
 
@@ -91,6 +90,15 @@ func (mp *metaPartition) fsmCreateDentry(dentry *Dentry,
 			status = proto.OpArgMismatchErr
 			return
 		}
+		if parIno.IsShardedDir() {
+			status = proto.OpDirShardedErr
+			return
+		}
+		if parIno.IsFencedDir() {
+			log.LogWarnf("action[fsmCreateDentry] ParentId [%v] is a fenced dir shard, dentry name [%v], inode [%v]", dentry.ParentId, dentry.Name, dentry.Inode)
+			status = proto.OpNotExistErr
+			return
+		}
 	}
 
 	if item, ok := mp.dentryTree.ReplaceOrInsert(dentry, false); !ok {
@@ -116,6 +124,8 @@ func (mp *metaPartition) fsmCreateDentry(dentry *Dentry,
 		parIno.IncNLink()
 		parIno.SetMtime()
 	}
+	mp.changes.notify(dentry.ParentId)
+	mp.feed.dentry(proto.ChangeCreateDentry, dentry)
 	return
 }
 
@@ -173,6 +183,8 @@ func (mp *metaPartition) fsmTxDeleteDentry(txDentry *TxDentry) (resp *DentryResp
 	}
 
 	mp.dentryTree.Delete(tmpDen)
+	mp.changes.notify(tmpDen.ParentId)
+	mp.feed.dentry(proto.ChangeDeleteDentry, item.(*Dentry))
 	// parent link count not change
 	resp.Msg = item.(*Dentry)
 	return
@@ -186,15 +198,8 @@ func (mp *metaPartition) fsmDeleteDentry(dentry *Dentry, checkInode bool) (
 
 	var item interface{}
 	if checkInode {
-		item = mp.dentryTree.Execute(func(tree *btree.BTree) interface{} {
-			d := tree.CopyGet(dentry)
-			if d == nil {
-				return nil
-			}
-			if d.(*Dentry).Inode != dentry.Inode {
-				return nil
-			}
-			return mp.dentryTree.tree.Delete(dentry)
+		item = mp.dentryTree.DeleteIf(dentry, func(d BtreeItem) bool {
+			return d.(*Dentry).Inode == dentry.Inode
 		})
 	} else {
 		item = mp.dentryTree.Delete(dentry)
@@ -204,6 +209,8 @@ func (mp *metaPartition) fsmDeleteDentry(dentry *Dentry, checkInode bool) (
 		resp.Status = proto.OpNotExistErr
 		return
 	} else {
+		mp.changes.notify(dentry.ParentId)
+		mp.feed.dentry(proto.ChangeDeleteDentry, item.(*Dentry))
 		mp.inodeTree.CopyFind(NewInode(dentry.ParentId, 0),
 			func(item BtreeItem) {
 				if item != nil {
@@ -273,6 +280,8 @@ func (mp *metaPartition) fsmTxUpdateDentry(txUpDateDentry *TxUpdateDentry) (resp
 
 	d := item.(*Dentry)
 	d.Inode, newDen.Inode = newDen.Inode, d.Inode
+	mp.changes.notify(d.ParentId)
+	mp.feed.dentry(proto.ChangeUpdateDentry, d)
 	resp.Msg = newDen
 	return
 }
@@ -291,6 +300,8 @@ func (mp *metaPartition) fsmUpdateDentry(dentry *Dentry) (
 			return
 		}
 		d.Inode, dentry.Inode = dentry.Inode, d.Inode
+		mp.changes.notify(d.ParentId)
+		mp.feed.dentry(proto.ChangeUpdateDentry, d)
 		resp.Msg = dentry
 	})
 	return
@@ -345,6 +356,11 @@ func (mp *metaPartition) readDir(req *ReadDirReq) (resp *ReadDirResp) {
 // else if req.Marker != "" and req.Limit != 0, return dentries from pid:marker to pid:xxxx with limit count
 //
 func (mp *metaPartition) readDirLimit(req *ReadDirLimitReq) (resp *ReadDirLimitResp) {
+	return readDirLimitFrom(mp.dentryTree, req)
+}
+
+// readDirLimitFrom reads the dentries of the directory from the tree, e.g. the tree of a snapshot version.
+func readDirLimitFrom(tree *BTree, req *ReadDirLimitReq) (resp *ReadDirLimitResp) {
 	resp = &ReadDirLimitResp{}
 	startDentry := &Dentry{
 		ParentId: req.ParentID,
@@ -355,7 +371,7 @@ func (mp *metaPartition) readDirLimit(req *ReadDirLimitReq) (resp *ReadDirLimitR
 	endDentry := &Dentry{
 		ParentId: req.ParentID + 1,
 	}
-	mp.dentryTree.AscendRange(startDentry, endDentry, func(i BtreeItem) bool {
+	tree.AscendRange(startDentry, endDentry, func(i BtreeItem) bool {
 		d := i.(*Dentry)
 		resp.Children = append(resp.Children, proto.Dentry{
 			Inode: d.Inode,
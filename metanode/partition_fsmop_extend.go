@@ -14,6 +14,8 @@
 
 package metanode
 
+import "github.com/cubefs/cubefs/proto"
+
 type ExtendOpResult struct {
 	Status uint8
 	Extend *Extend
@@ -28,7 +30,7 @@ func (mp *metaPartition) fsmSetXAttr(extend *Extend) (err error) {
 		e = treeItem.(*Extend)
 		e.Merge(extend, true)
 	}
-
+	mp.feed.xattr(proto.ChangeSetXAttr, extend)
 	return
 }
 
@@ -42,5 +44,6 @@ func (mp *metaPartition) fsmRemoveXAttr(extend *Extend) (err error) {
 		e.Remove(key)
 		return true
 	})
+	mp.feed.xattr(proto.ChangeRemoveXAttr, extend)
 	return
 }
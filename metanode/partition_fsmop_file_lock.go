@@ -0,0 +1,66 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"github.com/cubefs/cubefs/proto"
+)
+
+// fsmFileLockRequest carries the current time of the leader, which the leases are based on.
+type fsmFileLockRequest struct {
+	Inode   uint64         `json:"ino"`
+	Lock    proto.FileLock `json:"lock"`
+	Session string         `json:"sid"`
+	Now     int64          `json:"now"`
+}
+
+type FileLockResp struct {
+	Status   uint8
+	Conflict *proto.FileLock
+	Count    int
+}
+
+func (mp *metaPartition) fsmSetFileLock(req *fsmFileLockRequest) (resp *FileLockResp) {
+	resp = &FileLockResp{Status: proto.OpOk}
+	if req.Lock.Type != proto.FileLockUnlock && mp.inodeTree.Get(NewInode(req.Inode, 0)) == nil {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	if resp.Conflict = mp.fileLocks.setLock(req.Inode, &req.Lock, req.Now); resp.Conflict != nil {
+		resp.Status = proto.OpExistErr
+	}
+	return
+}
+
+func (mp *metaPartition) fsmRenewFileLock(req *fsmFileLockRequest) (resp *FileLockResp) {
+	return &FileLockResp{
+		Status: proto.OpOk,
+		Count:  mp.fileLocks.renew(req.Session, req.Now),
+	}
+}
+
+func (mp *metaPartition) fsmReleaseFileLocks(req *fsmFileLockRequest) (resp *FileLockResp) {
+	return &FileLockResp{
+		Status: proto.OpOk,
+		Count:  mp.fileLocks.releaseSession(req.Session),
+	}
+}
+
+func (mp *metaPartition) fsmEvictFileLock(req *fsmFileLockRequest) (resp *FileLockResp) {
+	return &FileLockResp{
+		Status: proto.OpOk,
+		Count:  mp.fileLocks.evict(req.Now),
+	}
+}
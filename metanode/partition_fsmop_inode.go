@@ -76,8 +76,9 @@ func (mp *metaPartition) fsmCreateInode(ino *Inode) (status uint8) {
 	status = proto.OpOk
 	if _, ok := mp.inodeTree.ReplaceOrInsert(ino, false); !ok {
 		status = proto.OpExistErr
+		return
 	}
-
+	mp.feed.inode(proto.ChangeCreateInode, ino.Inode)
 	return
 }
 
@@ -138,6 +139,8 @@ func (mp *metaPartition) fsmCreateLinkInode(ino *Inode, uniqID uint64) (resp *In
 		return
 	}
 	i.IncNLink()
+	mp.changes.notify(i.Inode)
+	mp.feed.inode(proto.ChangeLinkInode, i.Inode)
 	return
 }
 
@@ -270,6 +273,8 @@ func (mp *metaPartition) fsmUnlinkInode(ino *Inode, uniqID uint64) (resp *InodeR
 	}
 
 	inode.DecNLink()
+	mp.changes.notify(inode.Inode)
+	mp.feed.inode(proto.ChangeUnlinkInode, inode.Inode)
 
 	//Fix#760: when nlink == 0, push into freeList and delay delete inode after 7 days
 	if inode.IsTempFile() {
@@ -343,6 +348,9 @@ func (mp *metaPartition) internalDeleteBatch(val []byte) error {
 }
 
 func (mp *metaPartition) internalDeleteInode(ino *Inode) {
+	if item := mp.inodeTree.Get(ino); item != nil {
+		mp.releaseInodeExtents(item.(*Inode))
+	}
 	mp.inodeTree.Delete(ino)
 	mp.freeList.Remove(ino.Inode)
 	mp.extendTree.Delete(&Extend{inode: ino.Inode}) // Also delete extend attribute.
@@ -366,11 +374,23 @@ func (mp *metaPartition) fsmAppendExtents(ino *Inode) (status uint8) {
 	if status = mp.uidManager.addUidSpace(ino2.Uid, ino2.Inode, eks); status != proto.OpOk {
 		return
 	}
+	// the keys of the shared data moved from the other inode, e.g. the parts of the multipart upload
+	if _, shared := mp.extentRefs.split(eks); len(shared) > 0 {
+		held := extentRefSet(ino2.Extents.CopyExtents())
+		for _, ref := range shared {
+			if _, ok := held[ref]; !ok {
+				held[ref] = struct{}{}
+				mp.extentRefs.get(ref)
+			}
+		}
+	}
 	delExtents := ino2.AppendExtents(eks, ino.ModifyTime, mp.volType)
+	mp.changes.notify(ino2.Inode)
+	mp.feed.inode(proto.ChangeExtents, ino2.Inode)
 	mp.updateUsedInfo(int64(ino2.Size)-oldSize, 0, ino2.Inode)
 	log.LogInfof("fsmAppendExtents inode(%v) deleteExtents(%v)", ino2.Inode, delExtents)
 	mp.uidManager.minusUidSpace(ino2.Uid, ino2.Inode, delExtents)
-	mp.extDelCh <- delExtents
+	mp.extDelCh <- mp.releaseExtents(ino2, delExtents)
 	return
 }
 
@@ -406,7 +426,9 @@ func (mp *metaPartition) fsmAppendExtentsWithCheck(ino *Inode) (status uint8) {
 
 	delExtents, status := ino2.AppendExtentWithCheck(eks[0], ino.ModifyTime, discardExtentKey, mp.volType)
 	if status == proto.OpOk {
-		mp.extDelCh <- delExtents
+		mp.changes.notify(ino2.Inode)
+		mp.feed.inode(proto.ChangeExtents, ino2.Inode)
+		mp.extDelCh <- mp.releaseExtents(ino2, delExtents)
 		mp.uidManager.minusUidSpace(ino2.Uid, ino2.Inode, delExtents)
 	}
 
@@ -448,7 +470,9 @@ func (mp *metaPartition) fsmAppendObjExtents(ino *Inode) (status uint8) {
 	if err != nil {
 		log.LogErrorf("fsmAppendExtents inode(%v) err(%v)", inode.Inode, err)
 		status = proto.OpConflictExtentsErr
+		return
 	}
+	mp.feed.inode(proto.ChangeExtents, inode.Inode)
 	return
 }
 
@@ -500,18 +524,59 @@ func (mp *metaPartition) fsmExtentsTruncate(ino *Inode) (resp *InodeResponse) {
 
 	oldSize := int64(i.Size)
 	delExtents := i.ExtentsTruncate(ino.Size, ino.ModifyTime, doOnLastKey)
+	mp.changes.notify(i.Inode)
+	mp.feed.inode(proto.ChangeExtents, i.Inode)
 	mp.updateUsedInfo(int64(i.Size)-oldSize, 0, i.Inode)
 	// now we should delete the extent
 	log.LogInfof("fsmExtentsTruncate inode(%v) exts(%v)", i.Inode, delExtents)
-	mp.extDelCh <- delExtents
+	mp.extDelCh <- mp.releaseExtents(i, delExtents)
 	mp.uidManager.minusUidSpace(i.Uid, i.Inode, delExtents)
 	return
 }
 
+type fsmPunchHoleRequest struct {
+	Inode      uint64 `json:"ino"`
+	Offset     uint64 `json:"off"`
+	Size       uint64 `json:"sz"`
+	ModifyTime int64  `json:"mt"`
+}
+
+func (mp *metaPartition) fsmExtentsPunchHole(req *fsmPunchHoleRequest) (resp *InodeResponse) {
+	resp = NewInodeResponse()
+
+	resp.Status = proto.OpOk
+	item := mp.inodeTree.CopyGet(NewInode(req.Inode, 0))
+	if item == nil {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	i := item.(*Inode)
+	if i.ShouldDelete() {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	if proto.IsDir(i.Type) {
+		resp.Status = proto.OpArgMismatchErr
+		return
+	}
+
+	removed, delExtents := i.ExtentsPunchHole(req.Offset, req.Size, req.ModifyTime)
+	mp.changes.notify(i.Inode)
+	mp.feed.inode(proto.ChangeExtents, i.Inode)
+	log.LogInfof("fsmExtentsPunchHole inode(%v) offset(%v) size(%v) removed(%v) exts(%v)",
+		i.Inode, req.Offset, req.Size, removed, delExtents)
+	if delExtents = mp.releaseExtents(i, delExtents); len(delExtents) > 0 {
+		mp.extDelCh <- delExtents
+	}
+	mp.uidManager.minusUidSpace(i.Uid, i.Inode, removed)
+	return
+}
+
 func (mp *metaPartition) fsmEvictInode(ino *Inode) (resp *InodeResponse) {
 	resp = NewInodeResponse()
 
 	resp.Status = proto.OpOk
+	mp.orphans.remove(ino.Inode)
 	item := mp.inodeTree.CopyGet(ino)
 	if item == nil {
 		resp.Status = proto.OpNotExistErr
@@ -521,6 +586,8 @@ func (mp *metaPartition) fsmEvictInode(ino *Inode) (resp *InodeResponse) {
 	if i.ShouldDelete() {
 		return
 	}
+	mp.changes.notify(i.Inode)
+	mp.feed.inode(proto.ChangeEvictInode, i.Inode)
 	if proto.IsDir(i.Type) {
 		if i.IsEmptyDir() {
 			i.SetDeleteMark()
@@ -570,6 +637,8 @@ func (mp *metaPartition) fsmSetAttr(req *SetattrRequest) (err error) {
 		return
 	}
 	ino.SetAttr(req)
+	mp.changes.notify(ino.Inode)
+	mp.feed.inode(proto.ChangeSetAttr, ino.Inode)
 	return
 }
 
@@ -0,0 +1,81 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// fsmOrphanRequest carries the current time of the leader, which the leases are based on.
+type fsmOrphanRequest struct {
+	Inode   uint64 `json:"ino"`
+	Session string `json:"sid"`
+	Now     int64  `json:"now"`
+}
+
+type OrphanResp struct {
+	Status uint8
+	Count  int
+}
+
+func (mp *metaPartition) fsmAddOrphanInode(req *fsmOrphanRequest) (resp *OrphanResp) {
+	resp = &OrphanResp{Status: proto.OpOk}
+	// the session may be expired before, its inodes are reaped rather than renewed
+	mp.reapOrphanInodes(req.Now)
+
+	item := mp.inodeTree.CopyGet(NewInode(req.Inode, 0))
+	if item == nil {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	inode := item.(*Inode)
+	if inode.ShouldDelete() {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	if inode.GetNLink() != 0 {
+		// linked again, nothing to keep
+		return
+	}
+	mp.orphans.add(req.Inode, req.Session, req.Now)
+	return
+}
+
+func (mp *metaPartition) fsmRenewOrphanSession(req *fsmOrphanRequest) (resp *OrphanResp) {
+	return &OrphanResp{
+		Status: proto.OpOk,
+		Count:  mp.orphans.renew(req.Session, req.Now),
+	}
+}
+
+func (mp *metaPartition) fsmReapOrphanInodes(req *fsmOrphanRequest) (resp *OrphanResp) {
+	return &OrphanResp{
+		Status: proto.OpOk,
+		Count:  mp.reapOrphanInodes(req.Now),
+	}
+}
+
+// reapOrphanInodes evicts the orphan inodes of the expired sessions, as the clients holding them
+// are gone without evicting them.
+func (mp *metaPartition) reapOrphanInodes(now int64) int {
+	inodes := mp.orphans.reap(now)
+	for _, ino := range inodes {
+		resp := mp.fsmEvictInode(NewInode(ino, 0))
+		log.LogInfof("reapOrphanInodes: partition(%v) evict orphan inode(%v) status(%v)",
+			mp.config.PartitionId, ino, resp.Status)
+	}
+	return len(inodes)
+}
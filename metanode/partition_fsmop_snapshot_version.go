@@ -0,0 +1,148 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// fsmSnapshotVersionRequest carries the current time and the deadline of the fence given by the leader.
+type fsmSnapshotVersionRequest struct {
+	Version  uint64 `json:"ver"`
+	Time     int64  `json:"time"`               // unix nano
+	Deadline int64  `json:"deadline,omitempty"` // unix nano
+}
+
+// fsmSnapshotPrepare fences the partition for the version, the writes are refused by the leader until the version
+// is committed or aborted, or the fence is expired.
+func (mp *metaPartition) fsmSnapshotPrepare(req *fsmSnapshotVersionRequest) (status uint8) {
+	if mp.versions.get(req.Version) != nil {
+		return proto.OpExistErr
+	}
+	if fence := mp.versions.getFence(); fence != nil {
+		if fence.Version == req.Version {
+			return proto.OpOk
+		}
+		if req.Time < fence.Deadline {
+			// another snapshot is being taken
+			return proto.OpAgain
+		}
+	}
+	mp.versions.setFence(&snapshotFence{Version: req.Version, Deadline: req.Deadline})
+	log.LogInfof("fsmSnapshotPrepare: partitionID(%v) version(%v) deadline(%v)",
+		mp.config.PartitionId, req.Version, req.Deadline)
+	return proto.OpOk
+}
+
+// fsmSnapshotCommit takes the version of the trees fenced, the data extents referenced by the version are counted
+// in the extent refs, so that they are kept until the version is deleted.
+func (mp *metaPartition) fsmSnapshotCommit(req *fsmSnapshotVersionRequest) (status uint8) {
+	if mp.versions.get(req.Version) != nil {
+		return proto.OpOk
+	}
+	fence := mp.versions.getFence()
+	if fence == nil || fence.Version != req.Version {
+		return proto.OpNotExistErr
+	}
+	mp.versions.clearFence(req.Version)
+	if req.Time >= fence.Deadline {
+		// the writes after the deadline may have been accepted
+		log.LogWarnf("fsmSnapshotCommit: partitionID(%v) version(%v) fence expired", mp.config.PartitionId, req.Version)
+		return proto.OpNotExistErr
+	}
+	v := &metaVersion{
+		id:         req.Version,
+		inodeTree:  mp.inodeTree.GetTree(),
+		dentryTree: mp.dentryTree.GetTree(),
+		extendTree: mp.extendTree.GetTree(),
+	}
+	refs := versionExtentRefs(v)
+	for ref := range refs {
+		mp.extentRefs.get(ref)
+	}
+	mp.versions.put(v)
+	log.LogInfof("fsmSnapshotCommit: partitionID(%v) version(%v) inodes(%v) extentRefs(%v)",
+		mp.config.PartitionId, req.Version, v.inodeTree.Len(), len(refs))
+	return proto.OpOk
+}
+
+func (mp *metaPartition) fsmSnapshotAbort(req *fsmSnapshotVersionRequest) (status uint8) {
+	if mp.versions.clearFence(req.Version) {
+		log.LogInfof("fsmSnapshotAbort: partitionID(%v) version(%v)", mp.config.PartitionId, req.Version)
+	}
+	return proto.OpOk
+}
+
+// fsmSnapshotDelete drops the version, the data extents no longer referenced by the live inodes are deleted.
+func (mp *metaPartition) fsmSnapshotDelete(req *fsmSnapshotVersionRequest) (status uint8) {
+	mp.versions.clearFence(req.Version)
+	v := mp.versions.remove(req.Version)
+	if v == nil {
+		return proto.OpOk
+	}
+	eks := versionExtents(v)
+	owned, shared := mp.extentRefs.split(eks)
+	released := make(map[extentRef]struct{}, len(shared))
+	for _, ref := range shared {
+		if _, ok := released[ref]; ok {
+			continue
+		}
+		released[ref] = struct{}{}
+		mp.extentRefs.put(ref)
+	}
+	if len(owned) > 0 {
+		mp.extDelCh <- owned
+	}
+	log.LogInfof("fsmSnapshotDelete: partitionID(%v) version(%v) released(%v) deleted(%v)",
+		mp.config.PartitionId, req.Version, len(released), len(owned))
+	return proto.OpOk
+}
+
+// versionExtents returns the extent keys of the inodes of the version, the data shared by the inodes of the
+// version is returned once. The inodes marked deleted are not read from the version, their data is deleted by
+// the free list regardless.
+func versionExtents(v *metaVersion) (eks []proto.ExtentKey) {
+	seen := make(map[proto.ExtentKey]struct{})
+	v.inodeTree.Ascend(func(item BtreeItem) bool {
+		ino := item.(*Inode)
+		if ino.ShouldDelete() {
+			return true
+		}
+		ino.Extents.Range(func(ek proto.ExtentKey) bool {
+			data := ek
+			data.FileOffset, data.CRC = 0, 0
+			if _, ok := seen[data]; !ok {
+				seen[data] = struct{}{}
+				eks = append(eks, ek)
+			}
+			return true
+		})
+		return true
+	})
+	return
+}
+
+// versionExtentRefs returns the units of the data referenced by the version, each is referenced once by the
+// version however many inodes of it share the unit.
+func versionExtentRefs(v *metaVersion) map[extentRef]struct{} {
+	refs := make(map[extentRef]struct{})
+	for _, ek := range versionExtents(v) {
+		for _, ref := range extentRefsOf(&ek) {
+			refs[ref] = struct{}{}
+		}
+	}
+	return refs
+}
@@ -0,0 +1,117 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// fsmTrashRequest carries the current time of the leader, which the deletion time is based on.
+type fsmTrashRequest struct {
+	Inode    uint64   `json:"ino"`
+	ParentId uint64   `json:"parent"`
+	Name     string   `json:"name"`
+	Path     string   `json:"path"`
+	UniqID   uint64   `json:"uid"`
+	Inodes   []uint64 `json:"inos"`
+	All      bool     `json:"all"`
+	Now      int64    `json:"now"`
+}
+
+type TrashResp struct {
+	Status  uint8
+	Inode   *Inode
+	Trashed bool
+	Entry   *proto.TrashEntry
+	Count   int
+}
+
+// fsmTrashInode keeps the inode of the dentry deleted in the trash. The inode linked by the other dentries
+// is unlinked as before, since the data is still reachable.
+func (mp *metaPartition) fsmTrashInode(req *fsmTrashRequest) (resp *TrashResp) {
+	resp = &TrashResp{Status: proto.OpOk}
+	item := mp.inodeTree.CopyGet(NewInode(req.Inode, 0))
+	if item == nil {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	inode := item.(*Inode)
+	if inode.ShouldDelete() {
+		resp.Status = proto.OpNotExistErr
+		return
+	}
+	// the inode in the trash has no dentry, so the request is retried
+	if mp.trash.get(req.Inode) != nil {
+		resp.Inode, resp.Trashed = inode, true
+		return
+	}
+	if proto.IsDir(inode.Type) || inode.GetNLink() > 1 {
+		r := mp.fsmUnlinkInode(NewInode(req.Inode, 0), req.UniqID)
+		resp.Status, resp.Inode = r.Status, r.Msg
+		return
+	}
+
+	inode.RLock()
+	entry := &proto.TrashEntry{
+		Inode:      req.Inode,
+		ParentId:   req.ParentId,
+		Name:       req.Name,
+		Path:       req.Path,
+		Mode:       inode.Type,
+		Size:       inode.Size,
+		DeleteTime: req.Now,
+	}
+	inode.RUnlock()
+	mp.trash.add(entry)
+	resp.Inode, resp.Trashed = inode, true
+	log.LogDebugf("fsmTrashInode: partitionID(%v) entry(%v)", mp.config.PartitionId, entry)
+	return
+}
+
+// fsmRestoreTrash takes the inode out of the trash, the inode is still linked as it was.
+func (mp *metaPartition) fsmRestoreTrash(req *fsmTrashRequest) (resp *TrashResp) {
+	resp = &TrashResp{Status: proto.OpOk}
+	if resp.Entry = mp.trash.remove(req.Inode); resp.Entry == nil {
+		resp.Status = proto.OpNotExistErr
+	}
+	return
+}
+
+// fsmPurgeTrash unlinks the inodes in the trash, whose data is released by the free list.
+func (mp *metaPartition) fsmPurgeTrash(req *fsmTrashRequest) (resp *TrashResp) {
+	resp = &TrashResp{Status: proto.OpOk}
+	inodes := req.Inodes
+	if req.All {
+		inodes = inodes[:0]
+		for _, entry := range mp.trash.list() {
+			inodes = append(inodes, entry.Inode)
+		}
+	}
+	for _, ino := range inodes {
+		if mp.trash.remove(ino) == nil {
+			continue
+		}
+		resp.Count++
+		if r := mp.fsmUnlinkInode(NewInode(ino, 0), 0); r.Status != proto.OpOk {
+			log.LogWarnf("fsmPurgeTrash: partitionID(%v) unlink inode(%v) status(%v)",
+				mp.config.PartitionId, ino, r.Status)
+			continue
+		}
+		mp.fsmEvictInode(NewInode(ino, 0))
+	}
+	log.LogDebugf("fsmPurgeTrash: partitionID(%v) purged(%v)", mp.config.PartitionId, resp.Count)
+	return
+}
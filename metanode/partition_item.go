@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -36,6 +37,8 @@ type MetaItem struct {
 	Op uint32 `json:"op"`
 	K  []byte `json:"k"`
 	V  []byte `json:"v"`
+	// the traceparent of the span submitting the item, which is ignored by the nodes not knowing it
+	Trace string `json:"trace,omitempty"`
 }
 
 // MarshalJson
@@ -124,12 +127,22 @@ type fileData struct {
 	data     []byte
 }
 
+// versionItems is a batch of the items of a snapshot version, see writeVersionItems.
+type versionItems struct {
+	id   uint64
+	data []byte
+}
+
 const (
 	// initial version
 	SnapFormatVersion_0 uint32 = iota
 
 	// version since transaction feature, added formatVersion, txId and cursor in MetaItemIterator struct
 	SnapFormatVersion_1
+
+	// version since chunked snapshot, the items of version_1 are sent in checksummed chunks which are
+	// staged by the follower, so that an interrupted transfer is resumed
+	SnapFormatVersion_2
 )
 
 // MetaItemIterator defines the iterator of the MetaItem.
@@ -148,9 +161,20 @@ type MetaItemIterator struct {
 	txRbInodeTree     *BTree
 	txRbDentryTree    *BTree
 	uniqChecker       *uniqChecker
+	fileLocks         *fileLockTable
+	extentRefs        *extentRefTable
+	trash             *trashTable
+	orphans           *orphanTable
+	versions          *versionTable
+	split             *splitState
 
 	filenames []string
 
+	// the chunked stream of SnapFormatVersion_2
+	mp     *metaPartition
+	target uint64 // the node the snapshot is sent to
+	chunk  chunkState
+
 	dataCh    chan interface{}
 	errorCh   chan error
 	err       error
@@ -188,20 +212,30 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 	si = new(MetaItemIterator)
 	si.fileRootDir = mp.config.RootDir
 	si.SnapFormatVersion = mp.manager.metaNode.raftSyncSnapFormatVersion
-	mp.nonIdempotent.Lock()
-	si.applyID = mp.getApplyID()
-	si.txId = mp.txProcessor.txManager.txIdAlloc.getTransactionID()
-	si.cursor = mp.GetCursor()
-	si.uniqID = mp.GetUniqId()
-	si.inodeTree = mp.inodeTree.GetTree()
-	si.dentryTree = mp.dentryTree.GetTree()
-	si.extendTree = mp.extendTree.GetTree()
-	si.multipartTree = mp.multipartTree.GetTree()
-	si.txTree = mp.txProcessor.txManager.txTree.GetTree()
-	si.txRbInodeTree = mp.txProcessor.txResource.txRbInodeTree.GetTree()
-	si.txRbDentryTree = mp.txProcessor.txResource.txRbDentryTree.GetTree()
-	si.uniqChecker = mp.uniqChecker.clone()
-	mp.nonIdempotent.Unlock()
+	si.mp = mp
+	if !mp.snapshotCache.restore(si) {
+		mp.nonIdempotent.Lock()
+		si.applyID = mp.getApplyID()
+		si.txId = mp.txProcessor.txManager.txIdAlloc.getTransactionID()
+		si.cursor = mp.GetCursor()
+		si.uniqID = mp.GetUniqId()
+		si.inodeTree = mp.inodeTree.GetTree()
+		si.dentryTree = mp.dentryTree.GetTree()
+		si.extendTree = mp.extendTree.GetTree()
+		si.multipartTree = mp.multipartTree.GetTree()
+		si.txTree = mp.txProcessor.txManager.txTree.GetTree()
+		si.txRbInodeTree = mp.txProcessor.txResource.txRbInodeTree.GetTree()
+		si.txRbDentryTree = mp.txProcessor.txResource.txRbDentryTree.GetTree()
+		si.uniqChecker = mp.uniqChecker.clone()
+		si.fileLocks = mp.fileLocks.clone()
+		si.extentRefs = mp.extentRefs.clone()
+		si.trash = mp.trash.clone()
+		si.orphans = mp.orphans.clone()
+		si.versions = mp.versions.clone()
+		si.split = mp.splitState()
+		mp.nonIdempotent.Unlock()
+		mp.snapshotCache.save(si)
+	}
 
 	si.dataCh = make(chan interface{})
 	si.errorCh = make(chan error, 1)
@@ -258,7 +292,7 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 			produceItem(si.applyID)
 			log.LogDebugf("newMetaItemIterator: SnapFormatVersion_0, partitionId(%v), applyID(%v)",
 				mp.config.PartitionId, si.applyID)
-		} else if si.SnapFormatVersion == SnapFormatVersion_1 {
+		} else if si.SnapFormatVersion >= SnapFormatVersion_1 && si.SnapFormatVersion <= SnapFormatVersion_2 {
 			// process snapshot format version
 			snapFormatVerWrapper := SnapItemWrapper{SiwKeySnapFormatVer, si.SnapFormatVersion}
 			produceItem(snapFormatVerWrapper)
@@ -315,7 +349,7 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 			return
 		}
 
-		if si.SnapFormatVersion == SnapFormatVersion_1 {
+		if si.SnapFormatVersion >= SnapFormatVersion_1 {
 			iter.txTree.Ascend(func(i BtreeItem) bool {
 				return produceItem(i)
 			})
@@ -343,6 +377,58 @@ func newMetaItemIterator(mp *metaPartition) (si *MetaItemIterator, err error) {
 					return
 				}
 			}
+
+			if si.fileLocks.len() != 0 {
+				produceItem(si.fileLocks)
+				if checkClose() {
+					return
+				}
+			}
+
+			if si.extentRefs.len() != 0 {
+				produceItem(si.extentRefs)
+				if checkClose() {
+					return
+				}
+			}
+
+			if si.trash.len() != 0 {
+				produceItem(si.trash)
+				if checkClose() {
+					return
+				}
+			}
+
+			if si.orphans.len() != 0 {
+				produceItem(si.orphans)
+				if checkClose() {
+					return
+				}
+			}
+
+			if si.split.split() {
+				produceItem(si.split)
+				if checkClose() {
+					return
+				}
+			}
+
+			if si.versions.len() != 0 || si.versions.getFence() != nil {
+				produceItem(si.versions)
+				if checkClose() {
+					return
+				}
+				for _, v := range si.versions.list() {
+					if err := writeVersionItems(v, func(data []byte) error {
+						if !produceItem(&versionItems{id: v.id, data: data}) {
+							return errors.New("snapshot iterator closed")
+						}
+						return nil
+					}); err != nil {
+						return
+					}
+				}
+			}
 		}
 
 		// process extent del files
@@ -375,8 +461,20 @@ func (si *MetaItemIterator) Close() {
 	return
 }
 
-// Next returns the next item.
+// SetTarget sets the node the snapshot is sent to.
+func (si *MetaItemIterator) SetTarget(nodeID uint64) {
+	si.target = nodeID
+}
+
+// Next returns the next item, or the next chunk of the items in SnapFormatVersion_2.
 func (si *MetaItemIterator) Next() (data []byte, err error) {
+	if si.SnapFormatVersion == SnapFormatVersion_2 {
+		return si.nextChunk()
+	}
+	return si.nextItem()
+}
+
+func (si *MetaItemIterator) nextItem() (data []byte, err error) {
 	if si.err != nil {
 		err = si.err
 		return
@@ -470,6 +568,58 @@ func (si *MetaItemIterator) Next() (data []byte, err error) {
 			return
 		}
 		snap = NewMetaItem(opFSMUniqCheckerSnap, nil, raw)
+	case *fileLockTable:
+		var raw []byte
+		if raw, _, err = typedItem.Marshal(); err != nil {
+			si.err = err
+			si.Close()
+			return
+		}
+		snap = NewMetaItem(opFSMFileLockSnap, nil, raw)
+	case *extentRefTable:
+		var raw []byte
+		if raw, _, err = typedItem.Marshal(); err != nil {
+			si.err = err
+			si.Close()
+			return
+		}
+		snap = NewMetaItem(opFSMExtentRefSnap, nil, raw)
+	case *trashTable:
+		var raw []byte
+		if raw, _, err = typedItem.Marshal(); err != nil {
+			si.err = err
+			si.Close()
+			return
+		}
+		snap = NewMetaItem(opFSMTrashSnap, nil, raw)
+	case *orphanTable:
+		var raw []byte
+		if raw, _, err = typedItem.Marshal(); err != nil {
+			si.err = err
+			si.Close()
+			return
+		}
+		snap = NewMetaItem(opFSMOrphanSnap, nil, raw)
+	case *versionTable:
+		var raw []byte
+		if raw, _, err = typedItem.Marshal(); err != nil {
+			si.err = err
+			si.Close()
+			return
+		}
+		snap = NewMetaItem(opFSMSnapVersionState, nil, raw)
+	case *splitState:
+		var raw []byte
+		if raw, _, err = typedItem.Marshal(); err != nil {
+			si.err = err
+			si.Close()
+			return
+		}
+		snap = NewMetaItem(opFSMSplitStateSnap, nil, raw)
+	case *versionItems:
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, typedItem.id)
+		snap = NewMetaItem(opFSMSnapVersionItems, key, typedItem.data)
 	default:
 		panic(fmt.Sprintf("unknown item type: %v", reflect.TypeOf(item).Name()))
 	}
@@ -0,0 +1,281 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const kvStoreDir = "rocksdb"
+
+var kvCodecs = []*kvCodec{inodeKVCodec, dentryKVCodec, extendKVCodec, multipartKVCodec}
+
+func (mp *metaPartition) kvMode() bool {
+	return mp.config.StoreMode == proto.MetaStoreModeRocksDB
+}
+
+func (mp *metaPartition) kvTrees() []**BTree {
+	return []**BTree{&mp.inodeTree, &mp.dentryTree, &mp.extendTree, &mp.multipartTree}
+}
+
+func (sm *storeMsg) snapshotTrees() []*BTree {
+	return []*BTree{sm.inodeTree, sm.dentryTree, sm.extendTree, sm.multipartTree}
+}
+
+func (mp *metaPartition) readKVUint(key []byte) (v uint64, err error) {
+	var raw []byte
+	if raw, err = mp.kv.Get(key); err != nil {
+		return
+	}
+	switch len(raw) {
+	case 0:
+	case 4:
+		v = uint64(binary.BigEndian.Uint32(raw))
+	case 8:
+		v = binary.BigEndian.Uint64(raw)
+	default:
+		err = fmt.Errorf("invalid value length %v of key %v", len(raw), key)
+	}
+	return
+}
+
+// openKVStore opens the kv store of the partition and replaces the trees kept in it.
+func (mp *metaPartition) openKVStore() (err error) {
+	if mp.kv, err = openKVStore(path.Join(mp.config.RootDir, kvStoreDir)); err != nil {
+		err = errors.NewErrorf("[openKVStore] partition(%v): %s", mp.config.PartitionId, err.Error())
+		return
+	}
+	var gen uint64
+	if gen, err = mp.readKVUint(kvGenKey); err != nil {
+		return
+	}
+
+	// drop the generations left by an interrupted ApplySnapshot
+	batch := NewKVBatch()
+	if gen > 0 {
+		batch.DeleteRange(kvGenPrefix(0), kvGenPrefix(uint32(gen)))
+	}
+	batch.DeleteRange(kvGenPrefix(uint32(gen)+1), kvPrefixEnd([]byte{kvItemPrefix}))
+	if err = mp.kv.Write(batch, true); err != nil {
+		return
+	}
+
+	trees := mp.kvTrees()
+	for i, codec := range kvCodecs {
+		var count uint64
+		if count, err = mp.readKVUint(kvCountKey(codec.tree)); err != nil {
+			return
+		}
+		*trees[i] = newKVBtree(newKVTree(mp.kv, uint32(gen), codec, int(count)))
+	}
+	log.LogInfof("openKVStore: partitionID(%v) volume(%v) gen(%v) inodes(%v) dentries(%v)",
+		mp.config.PartitionId, mp.config.VolName, gen, mp.inodeTree.Len(), mp.dentryTree.Len())
+	return
+}
+
+func (mp *metaPartition) closeKVStore() {
+	if mp.kv == nil {
+		return
+	}
+	if err := mp.kv.Close(); err != nil {
+		log.LogErrorf("closeKVStore: partitionID(%v) err(%v)", mp.config.PartitionId, err)
+	}
+	mp.kv = nil
+}
+
+func readApplyIDFile(rootDir string) (applyID uint64, err error) {
+	var data []byte
+	if data, err = ioutil.ReadFile(path.Join(rootDir, applyIDFile)); err != nil {
+		return
+	}
+	_, err = fmt.Sscanf(string(data), "%d", &applyID)
+	return
+}
+
+// recoverKVSnapshot makes the snapshot files match the items of the kv store. The kv
+// store is flushed right before the snapshot directory is renamed, so if the metanode
+// stopped in between, the complete temporary snapshot is promoted.
+func (mp *metaPartition) recoverKVSnapshot() (err error) {
+	var kvApplyID uint64
+	if kvApplyID, err = mp.readKVUint(kvApplyIDKey); err != nil {
+		return
+	}
+	snapshotPath := path.Join(mp.config.RootDir, snapshotDir)
+	if applyID, e := readApplyIDFile(snapshotPath); e == nil && applyID == kvApplyID {
+		return
+	}
+	tmpPath := path.Join(mp.config.RootDir, snapshotDirTmp)
+	applyID, e := readApplyIDFile(tmpPath)
+	if _, signErr := os.Stat(path.Join(tmpPath, SnapshotSign)); e != nil || signErr != nil || applyID != kvApplyID {
+		return errors.NewErrorf("[recoverKVSnapshot] partition(%v): no snapshot matches the kv store applyID(%v)",
+			mp.config.PartitionId, kvApplyID)
+	}
+	if err = os.RemoveAll(snapshotPath); err != nil {
+		return
+	}
+	if err = os.Rename(tmpPath, snapshotPath); err != nil {
+		return
+	}
+	log.LogWarnf("recoverKVSnapshot: partitionID(%v) promoted the temporary snapshot of applyID(%v)",
+		mp.config.PartitionId, kvApplyID)
+	return
+}
+
+// loadKVStore rebuilds the state derived from the inodes and the extends, which is
+// built by loadInode and loadExtend for the partitions kept in memory.
+func (mp *metaPartition) loadKVStore() (err error) {
+	var numInodes, numExtends uint64
+	mp.inodeTree.Ascend(func(i BtreeItem) bool {
+		ino := i.(*Inode)
+		mp.acucumUidSizeByLoad(ino)
+		mp.size += ino.Size
+		mp.checkAndInsertFreeList(ino)
+		if mp.config.Cursor < ino.Inode {
+			mp.config.Cursor = ino.Inode
+		}
+		numInodes++
+		return true
+	})
+	mp.extendTree.Ascend(func(i BtreeItem) bool {
+		mp.statisticExtendByLoad(i.(*Extend))
+		numExtends++
+		return true
+	})
+	if numInodes != uint64(mp.inodeTree.Len()) {
+		log.LogWarnf("loadKVStore: partitionID(%v) scanned %v inodes, counted %v",
+			mp.config.PartitionId, numInodes, mp.inodeTree.Len())
+		mp.inodeTree.kv.Lock()
+		mp.inodeTree.kv.count = int(numInodes)
+		mp.inodeTree.kv.Unlock()
+	}
+	log.LogInfof("loadKVStore: load complete: partitionID(%v) volume(%v) numInodes(%v) numExtends(%v)",
+		mp.config.PartitionId, mp.config.VolName, numInodes, numExtends)
+	return
+}
+
+// flushKVStore writes the trees of the store message to the kv store.
+func (mp *metaPartition) flushKVStore(sm *storeMsg) (err error) {
+	var gen uint64
+	if gen, err = mp.readKVUint(kvGenKey); err != nil {
+		return
+	}
+	batch := NewKVBatch()
+	var viewGen uint32
+	var hasView bool
+	for _, tree := range sm.snapshotTrees() {
+		if tree.kv == nil {
+			continue
+		}
+		if hasView && tree.kv.gen != viewGen {
+			return errors.NewErrorf("[flushKVStore] partition(%v): trees of different generations", mp.config.PartitionId)
+		}
+		viewGen, hasView = tree.kv.gen, true
+		if err = tree.kv.flush(batch); err != nil {
+			return
+		}
+	}
+	if hasView {
+		if uint64(viewGen) < gen {
+			return errors.NewErrorf("[flushKVStore] partition(%v): stale generation %v, current %v",
+				mp.config.PartitionId, viewGen, gen)
+		}
+		if uint64(viewGen) != gen {
+			// a snapshot from the leader replaced the trees
+			batch.DeleteRange(kvGenPrefix(uint32(gen)), kvGenPrefix(viewGen))
+		}
+		genRaw := make([]byte, 4)
+		binary.BigEndian.PutUint32(genRaw, viewGen)
+		batch.Put(kvGenKey, genRaw)
+	}
+	batch.Put(kvApplyIDKey, kvUint64Key(sm.applyIndex))
+	if err = mp.kv.Write(batch, true); err != nil {
+		return
+	}
+
+	// the flushed entries are read from the kv store from now on
+	for i, tree := range sm.snapshotTrees() {
+		if live := *mp.kvTrees()[i]; tree.kv != nil && live.kv != nil {
+			live.kv.trim(tree.kv)
+		}
+	}
+	log.LogInfof("flushKVStore: partitionID(%v) volume(%v) applyID(%v) gen(%v) ops(%v)",
+		mp.config.PartitionId, mp.config.VolName, sm.applyIndex, viewGen, batch.Len())
+	return
+}
+
+func (mp *metaPartition) releaseKVTrees(sm *storeMsg) {
+	for _, tree := range sm.snapshotTrees() {
+		tree.Release()
+	}
+}
+
+// newKVSnapshotTrees returns the trees to load a snapshot from the leader into, they're
+// written to the next generation of the kv store.
+func (mp *metaPartition) newKVSnapshotTrees() (trees []*BTree, err error) {
+	gen := mp.inodeTree.kv.gen + 1
+	batch := NewKVBatch()
+	batch.DeleteRange(kvGenPrefix(gen), kvPrefixEnd(kvGenPrefix(gen)))
+	if err = mp.kv.Write(batch, false); err != nil {
+		return
+	}
+	for _, codec := range kvCodecs {
+		trees = append(trees, newKVBtree(newKVLoadTree(mp.kv, gen, codec)))
+	}
+	return
+}
+
+func finishKVSnapshotTrees(trees ...*BTree) (err error) {
+	for _, tree := range trees {
+		if tree.kv == nil {
+			continue
+		}
+		if err = tree.kv.finishLoad(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (mp *metaPartition) storeKVInode(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	mp.storeInodeStats(sm)
+	return storeKVEmptyFile(rootDir, inodeFile)
+}
+
+func (mp *metaPartition) storeKVDentry(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	return storeKVEmptyFile(rootDir, dentryFile)
+}
+
+func (mp *metaPartition) storeKVExtend(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	mp.storeExtendStats(sm)
+	return storeKVEmptyFile(rootDir, extendFile)
+}
+
+func (mp *metaPartition) storeKVMultipart(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	return storeKVEmptyFile(rootDir, multipartFile)
+}
+
+// storeKVEmptyFile keeps the file of a tree kept in the kv store, so that the snapshot has the same layout.
+func storeKVEmptyFile(rootDir, filename string) (crc uint32, err error) {
+	err = ioutil.WriteFile(path.Join(rootDir, filename), nil, 0755)
+	return
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// WatchChanges returns the inodes changed after the seq of the request. The request is held for a while if
+// nothing has changed, so that the changes are pushed to the watcher as soon as they are applied.
+func (mp *metaPartition) WatchChanges(req *proto.WatchChangesRequest, p *Packet) (err error) {
+	resp := &proto.WatchChangesResponse{}
+	timer := time.NewTimer(proto.WatchChangesTimeout)
+	defer timer.Stop()
+	for {
+		var wait <-chan struct{}
+		resp.Inodes, resp.Epoch, resp.Seq, resp.Reset, wait = mp.changes.since(req.Epoch, req.Seq, proto.WatchChangesLimit)
+		if wait == nil {
+			break
+		}
+		select {
+		case <-wait:
+			continue
+		case <-timer.C:
+		case <-mp.stopC:
+		}
+		break
+	}
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// ReadChangeFeed returns the changes applied after the apply id of the request. The request is held for a
+// while if nothing is applied, so that the consumer tails the feed.
+func (mp *metaPartition) ReadChangeFeed(req *proto.ReadChangeFeedRequest, p *Packet) (err error) {
+	limit := req.Limit
+	if limit <= 0 || limit > proto.ChangeFeedLimit {
+		limit = proto.ChangeFeedLimit
+	}
+	resp := &proto.ReadChangeFeedResponse{}
+	timer := time.NewTimer(proto.ChangeFeedTimeout)
+	defer timer.Stop()
+	for {
+		var wait <-chan struct{}
+		resp.Events, resp.ApplyID, resp.Truncated, wait = mp.feed.since(req.ApplyID, limit)
+		if wait == nil {
+			break
+		}
+		select {
+		case <-wait:
+			continue
+		case <-timer.C:
+		case <-mp.stopC:
+		}
+		break
+	}
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
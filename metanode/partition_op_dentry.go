@@ -65,7 +65,7 @@ func (mp *metaPartition) TxCreateDentry(req *proto.TxCreateDentryRequest, p *Pac
 		return
 	}
 
-	status, err := mp.submit(opFSMTxCreateDentry, val)
+	status, err := mp.submitTraced(p, opFSMTxCreateDentry, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -108,7 +108,7 @@ func (mp *metaPartition) CreateDentry(req *CreateDentryReq, p *Packet) (err erro
 	if err != nil {
 		return
 	}
-	resp, err := mp.submit(opFSMCreateDentry, val)
+	resp, err := mp.submitTraced(p, opFSMCreateDentry, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -157,7 +157,7 @@ func (mp *metaPartition) QuotaCreateDentry(req *proto.QuotaCreateDentryRequest,
 	if err != nil {
 		return
 	}
-	resp, err := mp.submit(opFSMCreateDentry, val)
+	resp, err := mp.submitTraced(p, opFSMCreateDentry, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -186,6 +186,10 @@ func (mp *metaPartition) TxDeleteDentry(req *proto.TxDeleteDentryRequest, p *Pac
 
 	dentry, status := mp.getDentry(den)
 	if status != proto.OpOk {
+		if status = mp.dirShardedStatus(req.ParentID, status); status == proto.OpDirShardedErr {
+			p.PacketErrorWithBody(status, nil)
+			return
+		}
 		if mp.txDentryInRb(req.ParentID, req.Name, req.TxInfo.TxID) {
 			p.ResultCode = proto.OpOk
 			log.LogWarnf("TxDeleteDentry: dentry is already been deleted before, req %v", req)
@@ -218,7 +222,7 @@ func (mp *metaPartition) TxDeleteDentry(req *proto.TxDeleteDentryRequest, p *Pac
 		return
 	}
 
-	r, err := mp.submit(opFSMTxDeleteDentry, val)
+	r, err := mp.submitTraced(p, opFSMTxDeleteDentry, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -249,13 +253,13 @@ func (mp *metaPartition) DeleteDentry(req *DeleteDentryReq, p *Packet) (err erro
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	r, err := mp.submit(opFSMDeleteDentry, val)
+	r, err := mp.submitTraced(p, opFSMDeleteDentry, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
 	}
 	retMsg := r.(*DentryResponse)
-	p.ResultCode = retMsg.Status
+	p.ResultCode = mp.dirShardedStatus(req.ParentID, retMsg.Status)
 	dentry = retMsg.Msg
 	if p.ResultCode == proto.OpOk {
 		var reply []byte
@@ -287,7 +291,7 @@ func (mp *metaPartition) DeleteDentryBatch(req *BatchDeleteDentryReq, p *Packet)
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	r, err := mp.submit(opFSMDeleteDentryBatch, val)
+	r, err := mp.submitTraced(p, opFSMDeleteDentryBatch, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return err
@@ -359,6 +363,10 @@ func (mp *metaPartition) TxUpdateDentry(req *proto.TxUpdateDentryRequest, p *Pac
 	}
 	oldDentry, status := mp.getDentry(newDentry)
 	if status != proto.OpOk {
+		if status = mp.dirShardedStatus(req.ParentID, status); status == proto.OpDirShardedErr {
+			p.PacketErrorWithBody(status, nil)
+			return
+		}
 		if mp.txDentryInRb(req.ParentID, req.Name, req.TxInfo.TxID) {
 			p.ResultCode = proto.OpOk
 			log.LogWarnf("TxDeleteDentry: dentry is already been deleted before, req %v", req)
@@ -385,7 +393,7 @@ func (mp *metaPartition) TxUpdateDentry(req *proto.TxUpdateDentryRequest, p *Pac
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	resp, err := mp.submit(opFSMTxUpdateDentry, val)
+	resp, err := mp.submitTraced(p, opFSMTxUpdateDentry, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -414,13 +422,13 @@ func (mp *metaPartition) UpdateDentry(req *UpdateDentryReq, p *Packet) (err erro
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	resp, err := mp.submit(opFSMUpdateDentry, val)
+	resp, err := mp.submitTraced(p, opFSMUpdateDentry, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
 	}
 	msg := resp.(*DentryResponse)
-	p.ResultCode = msg.Status
+	p.ResultCode = mp.dirShardedStatus(req.ParentID, msg.Status)
 	if msg.Status == proto.OpOk {
 		var reply []byte
 		m := &UpdateDentryResp{
@@ -434,6 +442,9 @@ func (mp *metaPartition) UpdateDentry(req *UpdateDentryReq, p *Packet) (err erro
 
 func (mp *metaPartition) ReadDirOnly(req *ReadDirOnlyReq, p *Packet) (err error) {
 	resp := mp.readDirOnly(req)
+	if mp.replyDirSharded(req.ParentID, resp.Children, p) {
+		return
+	}
 	reply, err := json.Marshal(resp)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
@@ -446,6 +457,9 @@ func (mp *metaPartition) ReadDirOnly(req *ReadDirOnlyReq, p *Packet) (err error)
 // ReadDir reads the directory based on the given request.
 func (mp *metaPartition) ReadDir(req *ReadDirReq, p *Packet) (err error) {
 	resp := mp.readDir(req)
+	if mp.replyDirSharded(req.ParentID, resp.Children, p) {
+		return
+	}
 	reply, err := json.Marshal(resp)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
@@ -456,7 +470,13 @@ func (mp *metaPartition) ReadDir(req *ReadDirReq, p *Packet) (err error) {
 }
 
 func (mp *metaPartition) ReadDirLimit(req *ReadDirLimitReq, p *Packet) (err error) {
+	if req.Version != 0 {
+		return mp.readDirLimitVersion(req, p)
+	}
 	resp := mp.readDirLimit(req)
+	if mp.replyDirSharded(req.ParentID, resp.Children, p) {
+		return
+	}
 	reply, err := json.Marshal(resp)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
@@ -468,11 +488,15 @@ func (mp *metaPartition) ReadDirLimit(req *ReadDirLimitReq, p *Packet) (err erro
 
 // Lookup looks up the given dentry from the request.
 func (mp *metaPartition) Lookup(req *LookupReq, p *Packet) (err error) {
+	if req.Version != 0 {
+		return mp.lookupVersion(req, p)
+	}
 	dentry := &Dentry{
 		ParentId: req.ParentID,
 		Name:     req.Name,
 	}
 	dentry, status := mp.getDentry(dentry)
+	status = mp.dirShardedStatus(req.ParentID, status)
 	var reply []byte
 	if status == proto.OpOk {
 		resp := &LookupResp{
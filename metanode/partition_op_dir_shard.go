@@ -0,0 +1,156 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"fmt"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// ShardDir shards the dentries of the empty directory to the shard inodes kept in the xattr. The shard inodes
+// are created by the client in advance, the partition only marks the directory, after which the dentry
+// operations on the directory itself are rejected with OpDirShardedErr.
+func (mp *metaPartition) ShardDir(req *proto.SetXAttrRequest, p *Packet) (err error) {
+	var shards []uint64
+	if shards, err = proto.ParseDirShards(req.Value); err != nil {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	for _, ino := range shards {
+		if ino == req.Inode {
+			err = fmt.Errorf("directory %v is a shard of itself", req.Inode)
+			p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+			return
+		}
+	}
+	var extend = NewExtend(req.Inode)
+	extend.Put([]byte(req.Key), []byte(req.Value))
+	resp, err := mp.putExtend(opFSMShardDir, extend)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	if status := resp.(uint8); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	log.LogInfof("ShardDir: mp(%v) ino(%v) shards(%v)", mp.config.PartitionId, req.Inode, req.Value)
+	p.PacketOkReply()
+	return
+}
+
+func (mp *metaPartition) fsmShardDir(extend *Extend) (status uint8) {
+	item := mp.inodeTree.CopyGet(NewInode(extend.GetInode(), 0))
+	if item == nil {
+		return proto.OpNotExistErr
+	}
+	ino := item.(*Inode)
+	if ino.ShouldDelete() {
+		return proto.OpNotExistErr
+	}
+	if !proto.IsDir(ino.Type) {
+		return proto.OpArgMismatchErr
+	}
+	if ino.IsShardedDir() {
+		return proto.OpExistErr
+	}
+	if mp.hasDentries(ino.Inode) {
+		return proto.OpNotEmpty
+	}
+	ino.SetShardedDir()
+	mp.fsmSetXAttr(extend)
+	mp.changes.notify(ino.Inode)
+	return proto.OpOk
+}
+
+// FenceDirShard fences the empty shard before the sharded directory is deleted, or lifts the fence if the
+// delete fails. The emptiness is checked where the dentries of the shard are created, so that no dentry
+// created concurrently is left in the shard deleted.
+func (mp *metaPartition) FenceDirShard(ino uint64, fenced bool, p *Packet) (err error) {
+	op := uint32(opFSMFenceDirShard)
+	if !fenced {
+		op = opFSMUnfenceDirShard
+	}
+	val, err := NewInode(ino, 0).Marshal()
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(op, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	if status := resp.(uint8); status != proto.OpOk {
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	log.LogInfof("FenceDirShard: mp(%v) ino(%v) fenced(%v)", mp.config.PartitionId, ino, fenced)
+	p.PacketOkReply()
+	return
+}
+
+func (mp *metaPartition) fsmFenceDirShard(ino *Inode, fenced bool) (status uint8) {
+	item := mp.inodeTree.CopyGet(ino)
+	if item == nil {
+		return proto.OpNotExistErr
+	}
+	ino = item.(*Inode)
+	if ino.ShouldDelete() {
+		return proto.OpNotExistErr
+	}
+	if !proto.IsDir(ino.Type) || ino.IsShardedDir() {
+		return proto.OpArgMismatchErr
+	}
+	if fenced && mp.hasDentries(ino.Inode) {
+		return proto.OpNotEmpty
+	}
+	ino.SetFencedDir(fenced)
+	mp.changes.notify(ino.Inode)
+	return proto.OpOk
+}
+
+func (mp *metaPartition) hasDentries(parentID uint64) (found bool) {
+	mp.dentryTree.AscendRange(&Dentry{ParentId: parentID}, &Dentry{ParentId: parentID + 1}, func(i BtreeItem) bool {
+		found = true
+		return false
+	})
+	return
+}
+
+func (mp *metaPartition) isShardedDir(ino uint64) bool {
+	item := mp.inodeTree.Get(NewInode(ino, 0))
+	return item != nil && item.(*Inode).IsShardedDir()
+}
+
+// dirShardedStatus replaces the status of the dentry not found with OpDirShardedErr, if the dentries of
+// the parent are sharded, so that the client looks for the dentry in the shard.
+func (mp *metaPartition) dirShardedStatus(parentID uint64, status uint8) uint8 {
+	if status == proto.OpNotExistErr && mp.isShardedDir(parentID) {
+		return proto.OpDirShardedErr
+	}
+	return status
+}
+
+// replyDirSharded replies OpDirShardedErr for the directory read, if nothing is read from the sharded directory.
+func (mp *metaPartition) replyDirSharded(parentID uint64, children []proto.Dentry, p *Packet) bool {
+	if len(children) > 0 || !mp.isShardedDir(parentID) {
+		return false
+	}
+	p.PacketErrorWithBody(proto.OpDirShardedErr, nil)
+	return true
+}
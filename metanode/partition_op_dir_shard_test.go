@@ -0,0 +1,136 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaPartition_ShardDir(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testMetaPartitionShardDir/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+
+	mp := newMigrateTestPartition(t, ctrl, rootDir, 1, 1, 1000)
+	for _, ino := range []uint64{10, 11, 12} {
+		mp.inodeTree.ReplaceOrInsert(NewInode(ino, uint32(os.ModeDir)), true)
+	}
+	mp.inodeTree.ReplaceOrInsert(NewInode(20, 0), true)
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 10, Name: "a", Inode: 20}, true)
+
+	shard := func(ino uint64, value string) uint8 {
+		p := &Packet{}
+		mp.SetXAttr(&proto.SetXAttrRequest{Inode: ino, Key: proto.DirShardsXAttrKey, Value: value}, p)
+		return p.ResultCode
+	}
+	require.Equal(t, proto.OpArgMismatchErr, shard(10, "11"))
+	require.Equal(t, proto.OpArgMismatchErr, shard(10, "10,11"))
+	require.Equal(t, proto.OpArgMismatchErr, shard(20, "11,12"))
+	require.Equal(t, proto.OpNotEmpty, shard(10, "11,12"))
+
+	mp.dentryTree.Delete(&Dentry{ParentId: 10, Name: "a"})
+	require.Equal(t, proto.OpOk, shard(10, "11,12"))
+	require.Equal(t, proto.OpExistErr, shard(10, "11,12"))
+	require.True(t, mp.inodeTree.Get(NewInode(10, 0)).(*Inode).IsShardedDir())
+	value, ok := mp.extendTree.Get(NewExtend(10)).(*Extend).Get([]byte(proto.DirShardsXAttrKey))
+	require.True(t, ok)
+	require.Equal(t, "11,12", string(value))
+
+	// the dentries are created in the shards instead
+	dentry := &Dentry{ParentId: 10, Name: "a", Inode: 20}
+	val, err := dentry.Marshal()
+	require.NoError(t, err)
+	resp, err := mp.submit(opFSMCreateDentry, val)
+	require.NoError(t, err)
+	require.Equal(t, proto.OpDirShardedErr, resp.(uint8))
+	dentry.ParentId = proto.DirShardOf([]uint64{11, 12}, dentry.Name)
+	submitTestDentry(t, mp, opFSMCreateDentry, dentry)
+
+	p := &Packet{}
+	require.NoError(t, mp.Lookup(&LookupReq{ParentID: 10, Name: "a"}, p))
+	require.Equal(t, proto.OpDirShardedErr, p.ResultCode)
+	p = &Packet{}
+	require.NoError(t, mp.Lookup(&LookupReq{ParentID: dentry.ParentId, Name: "a"}, p))
+	require.Equal(t, proto.OpOk, p.ResultCode)
+	p = &Packet{}
+	require.NoError(t, mp.ReadDir(&ReadDirReq{ParentID: 10}, p))
+	require.Equal(t, proto.OpDirShardedErr, p.ResultCode)
+	p = &Packet{}
+	require.NoError(t, mp.DeleteDentry(&DeleteDentryReq{ParentID: 10, Name: "a"}, p))
+	require.Equal(t, proto.OpDirShardedErr, p.ResultCode)
+
+	// the dentries not found in the directories not sharded are reported as usual
+	p = &Packet{}
+	require.NoError(t, mp.Lookup(&LookupReq{ParentID: 11, Name: "none"}, p))
+	require.Equal(t, proto.OpNotExistErr, p.ResultCode)
+
+	p = &Packet{}
+	require.NoError(t, mp.RemoveXAttr(&proto.RemoveXAttrRequest{Inode: 10, Key: proto.DirShardsXAttrKey}, p))
+	require.Equal(t, proto.OpNotPerm, p.ResultCode)
+}
+
+func TestMetaPartition_FenceDirShard(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testMetaPartitionFenceDirShard/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+
+	mp := newMigrateTestPartition(t, ctrl, rootDir, 1, 1, 1000)
+	mp.inodeTree.ReplaceOrInsert(NewInode(11, uint32(os.ModeDir)), true)
+	mp.inodeTree.ReplaceOrInsert(NewInode(20, 0), true)
+
+	fence := func(ino uint64, fenced bool) uint8 {
+		p := &Packet{}
+		if fenced {
+			require.NoError(t, mp.SetXAttr(&proto.SetXAttrRequest{Inode: ino, Key: proto.DirShardFenceXAttrKey}, p))
+		} else {
+			require.NoError(t, mp.RemoveXAttr(&proto.RemoveXAttrRequest{Inode: ino, Key: proto.DirShardFenceXAttrKey}, p))
+		}
+		return p.ResultCode
+	}
+	require.Equal(t, proto.OpArgMismatchErr, fence(20, true))
+	require.Equal(t, proto.OpNotExistErr, fence(30, true))
+
+	// the shard keeping dentries is not fenced
+	submitTestDentry(t, mp, opFSMCreateDentry, &Dentry{ParentId: 11, Name: "a", Inode: 20})
+	require.Equal(t, proto.OpNotEmpty, fence(11, true))
+	submitTestDentry(t, mp, opFSMDeleteDentry, &Dentry{ParentId: 11, Name: "a"})
+
+	// no dentry is created in the shard fenced
+	require.Equal(t, proto.OpOk, fence(11, true))
+	require.Equal(t, proto.OpOk, fence(11, true))
+	dentry := &Dentry{ParentId: 11, Name: "a", Inode: 20}
+	val, err := dentry.Marshal()
+	require.NoError(t, err)
+	resp, err := mp.submit(opFSMCreateDentry, val)
+	require.NoError(t, err)
+	require.Equal(t, proto.OpNotExistErr, resp.(uint8))
+
+	// the fence is lifted if the directory is not deleted
+	require.Equal(t, proto.OpOk, fence(11, false))
+	submitTestDentry(t, mp, opFSMCreateDentry, dentry)
+
+	p := &Packet{}
+	require.NoError(t, mp.BatchSetXAttr(&proto.BatchSetXAttrRequest{Inode: 11, Attrs: map[string]string{proto.DirShardFenceXAttrKey: ""}}, p))
+	require.Equal(t, proto.OpNotPerm, p.ResultCode)
+}
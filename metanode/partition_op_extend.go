@@ -74,17 +74,52 @@ func (mp *metaPartition) UpdateXAttr(req *proto.UpdateXAttrRequest, p *Packet) (
 }
 
 func (mp *metaPartition) SetXAttr(req *proto.SetXAttrRequest, p *Packet) (err error) {
+	if req.Key == proto.DirShardsXAttrKey {
+		return mp.ShardDir(req, p)
+	}
+	if req.Key == proto.DirShardFenceXAttrKey {
+		return mp.FenceDirShard(req.Inode, true, p)
+	}
+	if err = mp.checkObjectLockXAttr(req.Inode, map[string]string{req.Key: req.Value}, nil); err != nil {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+		return
+	}
+	attrs := map[string]string{req.Key: req.Value}
+	if err = mp.freezeLockedInode(req.Inode, attrs); err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
 	var extend = NewExtend(req.Inode)
 	extend.Put([]byte(req.Key), []byte(req.Value))
 	if _, err = mp.putExtend(opFSMSetXAttr, extend); err != nil {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
+	if err = mp.freezeLockedInode(req.Inode, attrs); err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
 	p.PacketOkReply()
 	return
 }
 
 func (mp *metaPartition) BatchSetXAttr(req *proto.BatchSetXAttrRequest, p *Packet) (err error) {
+	if err = mp.checkObjectLockXAttr(req.Inode, req.Attrs, nil); err != nil {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+		return
+	}
+	if _, ok := req.Attrs[proto.DirShardsXAttrKey]; ok {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte("the shards of the directory can't be set in batch"))
+		return
+	}
+	if _, ok := req.Attrs[proto.DirShardFenceXAttrKey]; ok {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte("the shard of the directory can't be fenced in batch"))
+		return
+	}
+	if err = mp.freezeLockedInode(req.Inode, req.Attrs); err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
 	var extend = NewExtend(req.Inode)
 	for key, val := range req.Attrs {
 		extend.Put([]byte(key), []byte(val))
@@ -94,6 +129,10 @@ func (mp *metaPartition) BatchSetXAttr(req *proto.BatchSetXAttrRequest, p *Packe
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
+	if err = mp.freezeLockedInode(req.Inode, req.Attrs); err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
 	p.PacketOkReply()
 	return
 }
@@ -123,6 +162,9 @@ func (mp *metaPartition) GetXAttr(req *proto.GetXAttrRequest, p *Packet) (err er
 }
 
 func (mp *metaPartition) GetAllXAttr(req *proto.GetAllXAttrRequest, p *Packet) (err error) {
+	if req.Version != 0 {
+		return mp.getAllXAttrVersion(req, p)
+	}
 	var response = &proto.GetAllXAttrResponse{
 		VolName:     req.VolName,
 		PartitionId: req.PartitionId,
@@ -133,7 +175,9 @@ func (mp *metaPartition) GetAllXAttr(req *proto.GetAllXAttrRequest, p *Packet) (
 	if treeItem != nil {
 		extend := treeItem.(*Extend)
 		for key, val := range extend.dataMap {
-			response.Attrs[key] = string(val)
+			if strings.HasPrefix(key, req.Prefix) {
+				response.Attrs[key] = string(val)
+			}
 		}
 	}
 	var encoded []byte
@@ -178,6 +222,17 @@ func (mp *metaPartition) BatchGetXAttr(req *proto.BatchGetXAttrRequest, p *Packe
 }
 
 func (mp *metaPartition) RemoveXAttr(req *proto.RemoveXAttrRequest, p *Packet) (err error) {
+	if err = mp.checkObjectLockXAttr(req.Inode, nil, []string{req.Key}); err != nil {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+		return
+	}
+	if req.Key == proto.DirShardsXAttrKey {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte("the shards of the directory can't be removed"))
+		return
+	}
+	if req.Key == proto.DirShardFenceXAttrKey {
+		return mp.FenceDirShard(req.Inode, false, p)
+	}
 	var extend = NewExtend(req.Inode)
 	extend.Put([]byte(req.Key), nil)
 	if _, err = mp.putExtend(opFSMRemoveXAttr, extend); err != nil {
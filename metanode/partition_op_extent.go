@@ -63,6 +63,10 @@ func (mp *metaPartition) ExtentAppend(req *proto.AppendExtentKeyRequest, p *Pack
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
+	if err = mp.checkInodeLocked(req.Inode); err != nil {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+		return
+	}
 	ino := NewInode(req.Inode, 0)
 	if _, _, err = mp.CheckQuota(req.Inode, p); err != nil {
 		log.LogErrorf("ExtentAppend fail status [%v]", err)
@@ -75,7 +79,7 @@ func (mp *metaPartition) ExtentAppend(req *proto.AppendExtentKeyRequest, p *Pack
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	resp, err := mp.submit(opFSMExtentsAdd, val)
+	resp, err := mp.submitTraced(p, opFSMExtentsAdd, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -87,6 +91,10 @@ func (mp *metaPartition) ExtentAppend(req *proto.AppendExtentKeyRequest, p *Pack
 // ExtentAppendWithCheck appends an extent with discard extents check.
 // Format: one valid extent key followed by non or several discard keys.
 func (mp *metaPartition) ExtentAppendWithCheck(req *proto.AppendExtentKeyWithCheckRequest, p *Packet) (err error) {
+	if err = mp.checkInodeLocked(req.Inode); err != nil {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+		return
+	}
 	status := mp.isOverQuota(req.Inode, true, false)
 	if status != 0 {
 		log.LogErrorf("ExtentAppendWithCheck fail status [%v]", status)
@@ -135,7 +143,7 @@ func (mp *metaPartition) ExtentAppendWithCheck(req *proto.AppendExtentKeyWithChe
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	resp, err := mp.submit(opFSMExtentsAddWithCheck, val)
+	resp, err := mp.submitTraced(p, opFSMExtentsAddWithCheck, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -166,6 +174,9 @@ func (mp *metaPartition) GetUidInfo() (info []*proto.UidReportSpaceInfo) {
 
 // ExtentsList returns the list of extents.
 func (mp *metaPartition) ExtentsList(req *proto.GetExtentsRequest, p *Packet) (err error) {
+	if req.Version != 0 {
+		return mp.extentsListVersion(req, p)
+	}
 	ino := NewInode(req.Inode, 0)
 	retMsg := mp.getInode(ino)
 	ino = retMsg.Msg
@@ -184,6 +195,7 @@ func (mp *metaPartition) ExtentsList(req *proto.GetExtentsRequest, p *Packet) (e
 				return true
 			})
 		})
+		resp.Shared = mp.extentRefs.shares(resp.Extents)
 		reply, err = json.Marshal(resp)
 		if err != nil {
 			status = proto.OpErr
@@ -244,6 +256,10 @@ func (mp *metaPartition) ExtentsTruncate(req *ExtentsTruncateReq, p *Packet) (er
 		return
 	}
 	i := item.(*Inode)
+	if err = mp.checkInodeLocked(req.Inode); err != nil {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+		return
+	}
 	status := mp.isOverQuota(req.Inode, req.Size > i.Size, false)
 	if status != 0 {
 		log.LogErrorf("ExtentsTruncate fail status [%v]", status)
@@ -259,7 +275,7 @@ func (mp *metaPartition) ExtentsTruncate(req *ExtentsTruncateReq, p *Packet) (er
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	resp, err := mp.submit(opFSMExtentTruncate, val)
+	resp, err := mp.submitTraced(p, opFSMExtentTruncate, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -269,6 +285,152 @@ func (mp *metaPartition) ExtentsTruncate(req *ExtentsTruncateReq, p *Packet) (er
 	return
 }
 
+// ExtentsPunchHole removes the range of the file from the extents, and releases the space of the data nodes.
+func (mp *metaPartition) ExtentsPunchHole(req *ExtentsPunchHoleReq, p *Packet) (err error) {
+	if !proto.IsHot(mp.volType) {
+		err = fmt.Errorf("only support hot vol")
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	if req.Size == 0 || req.Offset+req.Size < req.Offset {
+		err = fmt.Errorf("invalid range: offset(%v) size(%v)", req.Offset, req.Size)
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	if mp.inodeTree.Get(NewInode(req.Inode, 0)) == nil {
+		err = fmt.Errorf("inode %v is not exist", req.Inode)
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		return
+	}
+	if err = mp.checkInodeLocked(req.Inode); err != nil {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+		return
+	}
+
+	val, err := json.Marshal(&fsmPunchHoleRequest{
+		Inode:      req.Inode,
+		Offset:     req.Offset,
+		Size:       req.Size,
+		ModifyTime: Now.GetCurrentTime().Unix(),
+	})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submitTraced(p, opFSMExtentPunchHole, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	msg := resp.(*InodeResponse)
+	p.PacketErrorWithBody(msg.Status, nil)
+	return
+}
+
+// CloneInode clones the range of the inode into the destination inode of the partition, the data extents
+// are shared by the inodes and are deleted from the data nodes when the last reference is dropped.
+func (mp *metaPartition) CloneInode(req *proto.CloneInodeRequest, p *Packet) (err error) {
+	if !proto.IsHot(mp.volType) {
+		err = fmt.Errorf("only support hot vol")
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	if req.Inode == req.DstInode || req.Offset+req.Size < req.Offset || req.DstOffset+req.Size < req.DstOffset {
+		err = fmt.Errorf("invalid clone: %v", req)
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	if req.DstInode != 0 && (req.DstInode < mp.config.Start || req.DstInode > mp.config.End) {
+		err = fmt.Errorf("dst inode %v is not in partition %v", req.DstInode, mp.config.PartitionId)
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	// the locked source is only read by the clone
+	if req.DstInode != 0 {
+		if err = mp.checkInodeLocked(req.DstInode); err != nil {
+			p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+			return
+		}
+	}
+
+	fsmReq := &fsmCloneInodeRequest{
+		Inode:      req.Inode,
+		Offset:     req.Offset,
+		Size:       req.Size,
+		DstInode:   req.DstInode,
+		DstOffset:  req.DstOffset,
+		Mode:       req.Mode,
+		Uid:        req.Uid,
+		Gid:        req.Gid,
+		ModifyTime: Now.GetCurrentTime().Unix(),
+		Version:    req.Version,
+	}
+	if req.Version != 0 && mp.versions.get(req.Version) == nil {
+		err = fmt.Errorf("snapshot version %v not found", req.Version)
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(err.Error()))
+		return
+	}
+	if req.DstInode == 0 {
+		if fsmReq.DstInode, err = mp.nextInodeID(); err != nil {
+			p.PacketErrorWithBody(proto.OpInodeFullErr, []byte(err.Error()))
+			return
+		}
+		fsmReq.Create = true
+	}
+	var msg *InodeResponse
+	for i := 0; ; i++ {
+		// the shared data is frozen in the data nodes before the clone, so that it is not overwritten in place
+		// by the writers of either inode, and is written to the new extents instead. The data of the snapshot
+		// version is frozen by the prepare of the version.
+		if req.Version == 0 {
+			if fsmReq.Frozen, err = mp.freezeCloneRange(req); err != nil {
+				p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+				return
+			}
+		}
+		var val []byte
+		if val, err = json.Marshal(fsmReq); err != nil {
+			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+			return
+		}
+		var r interface{}
+		if r, err = mp.submitTraced(p, opFSMCloneInode, val); err != nil {
+			p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+			return
+		}
+		msg = r.(*InodeResponse)
+		if msg.Status != proto.OpAgain || i >= cloneFreezeRetry {
+			break
+		}
+	}
+	if msg.Status != proto.OpOk {
+		p.PacketErrorWithBody(msg.Status, nil)
+		return
+	}
+	resp := &proto.CloneInodeResponse{Info: &proto.InodeInfo{}}
+	if !replyInfo(resp.Info, msg.Msg, make(map[uint32]*proto.MetaQuotaInfo, 0)) {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// freezeCloneRange freezes the extents of the range of the source inode to clone.
+func (mp *metaPartition) freezeCloneRange(req *proto.CloneInodeRequest) ([]frozenExtent, error) {
+	item := mp.inodeTree.Get(NewInode(req.Inode, 0))
+	if item == nil {
+		return make([]frozenExtent, 0), nil
+	}
+	eks, _ := cloneRange(item.(*Inode), req.Offset, req.Size, req.DstOffset)
+	return mp.freezeExtents(eks)
+}
+
 func (mp *metaPartition) BatchExtentAppend(req *proto.AppendExtentKeysRequest, p *Packet) (err error) {
 	if !proto.IsHot(mp.volType) {
 		err = fmt.Errorf("only support hot vol")
@@ -276,6 +438,10 @@ func (mp *metaPartition) BatchExtentAppend(req *proto.AppendExtentKeysRequest, p
 		return
 	}
 
+	if err = mp.checkInodeLocked(req.Inode); err != nil {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+		return
+	}
 	var ino *Inode
 	if ino, _, err = mp.CheckQuota(req.Inode, p); err != nil {
 		log.LogErrorf("BatchExtentAppend fail err [%v]", err)
@@ -291,7 +457,7 @@ func (mp *metaPartition) BatchExtentAppend(req *proto.AppendExtentKeysRequest, p
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	resp, err := mp.submit(opFSMExtentsAdd, val)
+	resp, err := mp.submitTraced(p, opFSMExtentsAdd, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -302,6 +468,10 @@ func (mp *metaPartition) BatchExtentAppend(req *proto.AppendExtentKeysRequest, p
 
 func (mp *metaPartition) BatchObjExtentAppend(req *proto.AppendObjExtentKeysRequest, p *Packet) (err error) {
 
+	if err = mp.checkInodeLocked(req.Inode); err != nil {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+		return
+	}
 	var ino *Inode
 	if ino, _, err = mp.CheckQuota(req.Inode, p); err != nil {
 		log.LogErrorf("BatchObjExtentAppend fail status [%v]", err)
@@ -321,7 +491,7 @@ func (mp *metaPartition) BatchObjExtentAppend(req *proto.AppendObjExtentKeysRequ
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	resp, err := mp.submit(opFSMObjExtentsAdd, val)
+	resp, err := mp.submitTraced(p, opFSMObjExtentsAdd, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -343,7 +513,7 @@ func (mp *metaPartition) BatchObjExtentAppend(req *proto.AppendObjExtentKeysRequ
 // 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 // 		return
 // 	}
-// 	resp, err := mp.submit(opFSMExtentsDel, val)
+// 	resp, err := mp.submitTraced(p, opFSMExtentsDel, val)
 // 	if err != nil {
 // 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 // 		return
@@ -359,7 +529,7 @@ func (mp *metaPartition) ExtentsEmpty(req *proto.EmptyExtentKeyRequest, p *Packe
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	resp, err := mp.submit(opFSMExtentsEmpty, val)
+	resp, err := mp.submitTraced(p, opFSMExtentsEmpty, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -0,0 +1,127 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+func (mp *metaPartition) submitFileLock(op uint32, req *fsmFileLockRequest, p *Packet) (resp *FileLockResp, err error) {
+	req.Now = Now.GetCurrentTime().Unix()
+	val, err := json.Marshal(req)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	r, err := mp.submitTraced(p, op, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	return r.(*FileLockResp), nil
+}
+
+// SetFileLock acquires or releases an advisory lock of the inode,
+// OpExistErr is returned with the conflicting lock if the lock is held by the others.
+func (mp *metaPartition) SetFileLock(req *proto.SetFileLockRequest, p *Packet) (err error) {
+	lock := req.Lock
+	if lock.Flock {
+		lock.Start, lock.End = 0, proto.FileLockEOF
+	}
+	if lock.Session == "" || lock.Start > lock.End || lock.Type > proto.FileLockWrite {
+		err = fmt.Errorf("invalid file lock: %v", &lock)
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+
+	resp, err := mp.submitFileLock(opFSMSetFileLock, &fsmFileLockRequest{Inode: req.Inode, Lock: lock}, p)
+	if err != nil {
+		return
+	}
+	var reply []byte
+	if resp.Conflict != nil {
+		if reply, err = json.Marshal(&proto.SetFileLockResponse{Conflict: resp.Conflict}); err != nil {
+			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+			return
+		}
+	}
+	p.PacketErrorWithBody(resp.Status, reply)
+	return
+}
+
+// GetFileLock returns the lock which conflicts with the requested one.
+func (mp *metaPartition) GetFileLock(req *proto.GetFileLockRequest, p *Packet) (err error) {
+	lock := req.Lock
+	if lock.Flock {
+		lock.Start, lock.End = 0, proto.FileLockEOF
+	}
+	resp := &proto.GetFileLockResponse{
+		Conflict: mp.fileLocks.getConflict(req.Inode, &lock, Now.GetCurrentTime().Unix()),
+	}
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// RenewFileLock extends the leases of the locks held by the session.
+func (mp *metaPartition) RenewFileLock(req *proto.RenewFileLockRequest, p *Packet) (err error) {
+	resp, err := mp.submitFileLock(opFSMRenewFileLock, &fsmFileLockRequest{Session: req.Session}, p)
+	if err != nil {
+		return
+	}
+	reply, err := json.Marshal(&proto.RenewFileLockResponse{Count: resp.Count})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// ReleaseFileLocks releases all the locks held by the session, e.g. the client is unmounted.
+func (mp *metaPartition) ReleaseFileLocks(req *proto.ReleaseFileLocksRequest, p *Packet) (err error) {
+	if _, err = mp.submitFileLock(opFSMReleaseFileLocks, &fsmFileLockRequest{Session: req.Session}, p); err != nil {
+		return
+	}
+	p.PacketOkReply()
+	return
+}
+
+// evictFileLocks removes the locks whose leases are expired, which is only called by the leader.
+func (mp *metaPartition) evictFileLocks() (evict int, err error) {
+	if !mp.fileLocks.hasExpired(Now.GetCurrentTime().Unix()) {
+		return
+	}
+	req := &fsmFileLockRequest{Now: Now.GetCurrentTime().Unix()}
+	val, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	resp, err := mp.submit(opFSMEvictFileLock, val)
+	if err != nil {
+		return
+	}
+	evict = resp.(*FileLockResp).Count
+	log.LogDebugf("evictFileLocks: partition(%v) evict(%v)", mp.config.PartitionId, evict)
+	return
+}
@@ -99,7 +99,7 @@ func (mp *metaPartition) CreateInode(req *CreateInoReq, p *Packet) (err error) {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return err
 	}
-	resp, err = mp.submit(opFSMCreateInode, val)
+	resp, err = mp.submitTraced(p, opFSMCreateInode, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return err
@@ -158,7 +158,7 @@ func (mp *metaPartition) QuotaCreateInode(req *proto.QuotaCreateInodeRequest, p
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return err
 	}
-	resp, err = mp.submit(opFSMCreateInodeQuota, val)
+	resp, err = mp.submitTraced(p, opFSMCreateInodeQuota, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return err
@@ -242,6 +242,10 @@ func (mp *metaPartition) TxUnlinkInode(req *proto.TxUnlinkInodeRequest, p *Packe
 		p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
 		return
 	}
+	if err = mp.checkInodeUnlink(req.Inode); err != nil {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+		return
+	}
 
 	ti := &TxInode{
 		Inode:  inoResp.Msg,
@@ -254,7 +258,7 @@ func (mp *metaPartition) TxUnlinkInode(req *proto.TxUnlinkInodeRequest, p *Packe
 		return
 	}
 
-	r, err := mp.submit(opFSMTxUnlinkInode, val)
+	r, err := mp.submitTraced(p, opFSMTxUnlinkInode, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -271,11 +275,16 @@ func (mp *metaPartition) TxUnlinkInode(req *proto.TxUnlinkInodeRequest, p *Packe
 
 // DeleteInode deletes an inode.
 func (mp *metaPartition) UnlinkInode(req *UnlinkInoReq, p *Packet) (err error) {
+	if err = mp.checkInodeUnlink(req.Inode); err != nil {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+		return
+	}
+
 	var r interface{}
 	var val []byte
 	if req.UniqID > 0 {
 		val = InodeOnceUnlinkMarshal(req)
-		r, err = mp.submit(opFSMUnlinkInodeOnce, val)
+		r, err = mp.submitTraced(p, opFSMUnlinkInodeOnce, val)
 	} else {
 		ino := NewInode(req.Inode, 0)
 		val, err = ino.Marshal()
@@ -283,7 +292,7 @@ func (mp *metaPartition) UnlinkInode(req *UnlinkInoReq, p *Packet) (err error) {
 			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 			return
 		}
-		r, err = mp.submit(opFSMUnlinkInode, val)
+		r, err = mp.submitTraced(p, opFSMUnlinkInode, val)
 	}
 
 	if err != nil {
@@ -317,6 +326,10 @@ func (mp *metaPartition) UnlinkInodeBatch(req *BatchUnlinkInoReq, p *Packet) (er
 	var inodes InodeBatch
 
 	for _, id := range req.Inodes {
+		if err = mp.checkInodeUnlink(id); err != nil {
+			p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+			return
+		}
 		inodes = append(inodes, NewInode(id, 0))
 	}
 
@@ -325,7 +338,7 @@ func (mp *metaPartition) UnlinkInodeBatch(req *BatchUnlinkInoReq, p *Packet) (er
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	r, err := mp.submit(opFSMUnlinkInodeBatch, val)
+	r, err := mp.submitTraced(p, opFSMUnlinkInodeBatch, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -360,6 +373,9 @@ func (mp *metaPartition) UnlinkInodeBatch(req *BatchUnlinkInoReq, p *Packet) (er
 
 // InodeGet executes the inodeGet command from the client.
 func (mp *metaPartition) InodeGet(req *InodeGetReq, p *Packet) (err error) {
+	if req.Version != 0 {
+		return mp.inodeGetVersion(req, p)
+	}
 	var (
 		reply      []byte
 		status     = proto.OpNotExistErr
@@ -446,7 +462,7 @@ func (mp *metaPartition) TxCreateInodeLink(req *proto.TxLinkInodeRequest, p *Pac
 		return
 	}
 
-	resp, err := mp.submit(opFSMTxCreateLinkInode, val)
+	resp, err := mp.submitTraced(p, opFSMTxCreateLinkInode, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -478,7 +494,7 @@ func (mp *metaPartition) CreateInodeLink(req *LinkInodeReq, p *Packet) (err erro
 	var val []byte
 	if req.UniqID > 0 {
 		val = InodeOnceLinkMarshal(req)
-		r, err = mp.submit(opFSMCreateLinkInodeOnce, val)
+		r, err = mp.submitTraced(p, opFSMCreateLinkInodeOnce, val)
 	} else {
 		ino := NewInode(req.Inode, 0)
 		val, err = ino.Marshal()
@@ -486,7 +502,7 @@ func (mp *metaPartition) CreateInodeLink(req *LinkInodeReq, p *Packet) (err erro
 			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 			return
 		}
-		r, err = mp.submit(opFSMCreateLinkInode, val)
+		r, err = mp.submitTraced(p, opFSMCreateLinkInode, val)
 	}
 
 	if err != nil {
@@ -522,7 +538,7 @@ func (mp *metaPartition) EvictInode(req *EvictInodeReq, p *Packet) (err error) {
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	resp, err := mp.submit(opFSMEvictInode, val)
+	resp, err := mp.submitTraced(p, opFSMEvictInode, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -550,7 +566,7 @@ func (mp *metaPartition) EvictInodeBatch(req *BatchEvictInodeReq, p *Packet) (er
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	resp, err := mp.submit(opFSMEvictInodeBatch, val)
+	resp, err := mp.submitTraced(p, opFSMEvictInodeBatch, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -569,7 +585,7 @@ func (mp *metaPartition) EvictInodeBatch(req *BatchEvictInodeReq, p *Packet) (er
 
 // SetAttr set the inode attributes.
 func (mp *metaPartition) SetAttr(reqData []byte, p *Packet) (err error) {
-	_, err = mp.submit(opFSMSetAttr, reqData)
+	_, err = mp.submitTraced(p, opFSMSetAttr, reqData)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -594,7 +610,7 @@ func (mp *metaPartition) GetInodeTreeLen() int {
 func (mp *metaPartition) DeleteInode(req *proto.DeleteInodeRequest, p *Packet) (err error) {
 	var bytes = make([]byte, 8)
 	binary.BigEndian.PutUint64(bytes, req.Inode)
-	_, err = mp.submit(opFSMInternalDeleteInode, bytes)
+	_, err = mp.submitTraced(p, opFSMInternalDeleteInode, bytes)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -619,7 +635,7 @@ func (mp *metaPartition) DeleteInodeBatch(req *proto.DeleteInodeBatchRequest, p
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	_, err = mp.submit(opFSMInternalDeleteInodeBatch, encoded)
+	_, err = mp.submitTraced(p, opFSMInternalDeleteInodeBatch, encoded)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -642,7 +658,7 @@ func (mp *metaPartition) ClearInodeCache(req *proto.ClearInodeCacheRequest, p *P
 		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 		return
 	}
-	resp, err := mp.submit(opFSMClearInodeCache, val)
+	resp, err := mp.submitTraced(p, opFSMClearInodeCache, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
@@ -714,7 +730,7 @@ func (mp *metaPartition) TxCreateInode(req *proto.TxCreateInodeRequest, p *Packe
 			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 			return err
 		}
-		resp, err = mp.submit(opFSMTxCreateInodeQuota, val)
+		resp, err = mp.submitTraced(p, opFSMTxCreateInodeQuota, val)
 		if err != nil {
 			p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 			return err
@@ -725,7 +741,7 @@ func (mp *metaPartition) TxCreateInode(req *proto.TxCreateInodeRequest, p *Packe
 			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
 			return err
 		}
-		resp, err = mp.submit(opFSMTxCreateInode, val)
+		resp, err = mp.submitTraced(p, opFSMTxCreateInode, val)
 		if err != nil {
 			p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 			return err
@@ -0,0 +1,130 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// inodeObjectLock returns the WORM protection of the inode, or nil if the inode is not protected.
+func (mp *metaPartition) inodeObjectLock(ino uint64) *proto.ObjectLock {
+	item := mp.extendTree.Get(NewExtend(ino))
+	if item == nil {
+		return nil
+	}
+	extend := item.(*Extend)
+	mode, _ := extend.Get([]byte(proto.XAttrKeyObjectLockMode))
+	retainUntil, _ := extend.Get([]byte(proto.XAttrKeyObjectLockRetainUntil))
+	legalHold, _ := extend.Get([]byte(proto.XAttrKeyObjectLockLegalHold))
+	if len(mode) == 0 && len(legalHold) == 0 {
+		return nil
+	}
+	return proto.ParseObjectLock(string(mode), string(retainUntil), string(legalHold))
+}
+
+// checkInodeLocked returns an error if the content of the inode is protected by object lock.
+func (mp *metaPartition) checkInodeLocked(ino uint64) (err error) {
+	if lock := mp.inodeObjectLock(ino); lock.Locked(time.Now().Unix()) {
+		err = fmt.Errorf("inode[%v] is locked: %v", ino, proto.ErrObjectLocked)
+		log.LogWarnf("checkInodeLocked: mp(%v) ino(%v) lock(%+v)", mp.config.PartitionId, ino, lock)
+	}
+	return
+}
+
+// checkInodeUnlink returns an error if the unlink removes the last link of a protected file.
+// Unlinking the other hard links, e.g. the links used by the noncurrent object versions, is allowed.
+func (mp *metaPartition) checkInodeUnlink(ino uint64) (err error) {
+	item := mp.inodeTree.Get(NewInode(ino, 0))
+	if item == nil {
+		return
+	}
+	inode := item.(*Inode)
+	if !proto.IsRegular(inode.Type) || inode.GetNLink() > 1 {
+		return
+	}
+	return mp.checkInodeLocked(ino)
+}
+
+// checkObjectLockXAttr returns an error if setting or removing the extended attributes breaks
+// the retention of the inode in compliance mode.
+func (mp *metaPartition) checkObjectLockXAttr(ino uint64, attrs map[string]string, removed []string) (err error) {
+	var changed bool
+	for key := range attrs {
+		changed = changed || proto.IsObjectLockXAttrKey(key)
+	}
+	for _, key := range removed {
+		changed = changed || proto.IsObjectLockXAttrKey(key)
+	}
+	if !changed {
+		return
+	}
+	old := mp.inodeObjectLock(ino)
+	if old == nil {
+		return
+	}
+
+	newAttrs := old.Attrs()
+	for key, val := range attrs {
+		newAttrs[key] = val
+	}
+	for _, key := range removed {
+		delete(newAttrs, key)
+	}
+	lock := proto.ParseObjectLock(newAttrs[proto.XAttrKeyObjectLockMode],
+		newAttrs[proto.XAttrKeyObjectLockRetainUntil], newAttrs[proto.XAttrKeyObjectLockLegalHold])
+	if err = old.CheckUpdate(lock, time.Now().Unix()); err != nil {
+		err = fmt.Errorf("inode[%v] retention can not be changed from (%+v) to (%+v): %v", ino, old, lock, err)
+		log.LogWarnf("checkObjectLockXAttr: mp(%v) err(%v)", mp.config.PartitionId, err)
+	}
+	return
+}
+
+// freezeLockedInode freezes the extents of the inode in the data nodes if the attributes set lock the inode,
+// so that the protected data is not overwritten in place by the clients which have opened the file. It is
+// called both before and after the attributes are set, as the extents appended meanwhile are frozen by the
+// latter. The extents stay frozen once the inode is unlocked, whose data is written to the new extents.
+func (mp *metaPartition) freezeLockedInode(ino uint64, attrs map[string]string) (err error) {
+	var changed bool
+	for key := range attrs {
+		changed = changed || proto.IsObjectLockXAttrKey(key)
+	}
+	if !changed || !proto.IsHot(mp.volType) {
+		return
+	}
+	newAttrs := make(map[string]string)
+	if old := mp.inodeObjectLock(ino); old != nil {
+		newAttrs = old.Attrs()
+	}
+	for key, val := range attrs {
+		newAttrs[key] = val
+	}
+	lock := proto.ParseObjectLock(newAttrs[proto.XAttrKeyObjectLockMode],
+		newAttrs[proto.XAttrKeyObjectLockRetainUntil], newAttrs[proto.XAttrKeyObjectLockLegalHold])
+	if !lock.Locked(time.Now().Unix()) {
+		return
+	}
+	item := mp.inodeTree.Get(NewInode(ino, 0))
+	if item == nil {
+		return
+	}
+	if _, err = mp.freezeExtents(item.(*Inode).Extents.CopyExtents()); err != nil {
+		log.LogWarnf("freezeLockedInode: mp(%v) ino(%v) err(%v)", mp.config.PartitionId, ino, err)
+	}
+	return
+}
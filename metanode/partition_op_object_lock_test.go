@@ -0,0 +1,52 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaPartition_ExtentAppendLocked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testExtentAppendLocked/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+	mp := newMigrateTestPartition(t, ctrl, rootDir, 1, 1, 1000)
+	mp.inodeTree.ReplaceOrInsert(NewInode(10, 0), true)
+
+	// the inode without extents is locked without freezing any
+	p := &Packet{}
+	require.NoError(t, mp.SetXAttr(&proto.SetXAttrRequest{Inode: 10, Key: proto.XAttrKeyObjectLockLegalHold,
+		Value: proto.ObjectLockLegalHoldOn}, p))
+	require.Equal(t, proto.OpOk, p.ResultCode)
+
+	ek := proto.ExtentKey{FileOffset: 0, PartitionId: 1, ExtentId: 1025, Size: 4096}
+	p = &Packet{}
+	require.Error(t, mp.ExtentAppend(&proto.AppendExtentKeyRequest{Inode: 10, Extent: ek}, p))
+	require.Equal(t, proto.OpNotPerm, p.ResultCode)
+	p = &Packet{}
+	require.Error(t, mp.ExtentAppendWithCheck(&proto.AppendExtentKeyWithCheckRequest{Inode: 10, Extent: ek}, p))
+	require.Equal(t, proto.OpNotPerm, p.ResultCode)
+	p = &Packet{}
+	require.Error(t, mp.BatchExtentAppend(&proto.AppendExtentKeysRequest{Inode: 10, Extents: []proto.ExtentKey{ek}}, p))
+	require.Equal(t, proto.OpNotPerm, p.ResultCode)
+	require.Equal(t, 0, mp.inodeTree.Get(NewInode(10, 0)).(*Inode).Extents.Len())
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+func (mp *metaPartition) submitOrphan(op uint32, req *fsmOrphanRequest, p *Packet) (resp *OrphanResp, err error) {
+	req.Now = Now.GetCurrentTime().Unix()
+	val, err := json.Marshal(req)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	r, err := mp.submitTraced(p, op, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	return r.(*OrphanResp), nil
+}
+
+// AddOrphanInode records the inode unlinked while it is open by the client session,
+// so that the inode is evicted once the session is expired.
+func (mp *metaPartition) AddOrphanInode(req *proto.AddOrphanInodeRequest, p *Packet) (err error) {
+	if req.Session == "" {
+		err = fmt.Errorf("invalid orphan inode(%v) session(%v)", req.Inode, req.Session)
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submitOrphan(opFSMAddOrphanInode, &fsmOrphanRequest{Inode: req.Inode, Session: req.Session}, p)
+	if err != nil {
+		return
+	}
+	p.PacketErrorWithBody(resp.Status, nil)
+	return
+}
+
+// RenewOrphanSession extends the lease of the orphan inodes held by the session.
+func (mp *metaPartition) RenewOrphanSession(req *proto.RenewOrphanSessionRequest, p *Packet) (err error) {
+	resp, err := mp.submitOrphan(opFSMRenewOrphanSession, &fsmOrphanRequest{Session: req.Session}, p)
+	if err != nil {
+		return
+	}
+	reply, err := json.Marshal(&proto.RenewOrphanSessionResponse{Count: resp.Count})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// evictOrphanInodes evicts the orphan inodes of the expired sessions, which is only called by the leader.
+func (mp *metaPartition) evictOrphanInodes() (evict int, err error) {
+	if !mp.orphans.hasExpired(Now.GetCurrentTime().Unix()) {
+		return
+	}
+	req := &fsmOrphanRequest{Now: Now.GetCurrentTime().Unix()}
+	val, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	resp, err := mp.submit(opFSMReapOrphanInodes, val)
+	if err != nil {
+		return
+	}
+	evict = resp.(*OrphanResp).Count
+	log.LogDebugf("evictOrphanInodes: partition(%v) evict(%v)", mp.config.PartitionId, evict)
+	return
+}
@@ -0,0 +1,295 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// snapshotFenceTimeout is how long the partition refuses the writes for the snapshot being taken, the fence is
+// dropped by the leader after it, and the version is not committed any more.
+const snapshotFenceTimeout = 30 * time.Second
+
+var ErrSnapshotFenced = errors.New("partition is fenced by the snapshot being taken")
+
+// versionFenced returns true if the op is refused while the partition is fenced. The ops keeping the partition
+// running, which neither add the data to the inodes nor change the trees read by the clients, are not.
+func versionFenced(op uint32) bool {
+	switch op {
+	case opFSMStoreTick, opFSMSyncCursor, opFSMSyncTxID, opFSMInternalDeleteInode, opFSMInternalDeleteInodeBatch,
+		opFSMInternalDelExtentFile, opFSMInternalDelExtentCursor, opFSMSentToChan, opFSMUniqCheckerEvict,
+		opFSMRenewFileLock, opFSMRenewOrphanSession, opFSMSnapshotPrepare, opFSMSnapshotCommit,
+		opFSMSnapshotAbort, opFSMSnapshotDelete:
+		return false
+	}
+	return true
+}
+
+// checkVersionFence returns ErrSnapshotFenced if the partition is fenced, the expired fence is aborted.
+// The caller holds the read lock of the versionGate.
+func (mp *metaPartition) checkVersionFence() (err error) {
+	fence := mp.versions.getFence()
+	if fence == nil {
+		return
+	}
+	now := time.Now().UnixNano()
+	if now < fence.Deadline {
+		return ErrSnapshotFenced
+	}
+	log.LogWarnf("checkVersionFence: partitionID(%v) abort expired version(%v)", mp.config.PartitionId, fence.Version)
+	_, err = mp.submitSnapshotVersion(opFSMSnapshotAbort, &fsmSnapshotVersionRequest{Version: fence.Version, Time: now})
+	return
+}
+
+func (mp *metaPartition) submitSnapshotVersion(op uint32, req *fsmSnapshotVersionRequest) (status uint8, err error) {
+	val, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	resp, err := mp.submit(op, val)
+	if err != nil {
+		return
+	}
+	return resp.(uint8), nil
+}
+
+// SnapshotVersion prepares, commits, aborts or deletes the snapshot version of the partition, see
+// proto.SnapshotVersionRequest.
+func (mp *metaPartition) SnapshotVersion(req *proto.SnapshotVersionRequest, p *Packet) (err error) {
+	if req.Version == 0 {
+		err = fmt.Errorf("invalid snapshot version: %v", req)
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	var status uint8
+	fsmReq := &fsmSnapshotVersionRequest{Version: req.Version, Time: time.Now().UnixNano()}
+	switch req.Op {
+	case proto.SnapshotVersionPrepare:
+		status, err = mp.prepareSnapshotVersion(fsmReq)
+	case proto.SnapshotVersionCommit:
+		status, err = mp.submitSnapshotVersion(opFSMSnapshotCommit, fsmReq)
+	case proto.SnapshotVersionAbort:
+		status, err = mp.submitSnapshotVersion(opFSMSnapshotAbort, fsmReq)
+	case proto.SnapshotVersionDelete:
+		status, err = mp.submitSnapshotVersion(opFSMSnapshotDelete, fsmReq)
+	default:
+		err = fmt.Errorf("unknown snapshot version op: %v", req.Op)
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.PacketErrorWithBody(status, nil)
+	return
+}
+
+// prepareSnapshotVersion fences the partition, and freezes the data extents referenced by it in the data nodes,
+// so that the data of the version is not modified in place by the live inodes once committed.
+func (mp *metaPartition) prepareSnapshotVersion(req *fsmSnapshotVersionRequest) (status uint8, err error) {
+	if mp.kv != nil {
+		log.LogWarnf("prepareSnapshotVersion: partitionID(%v) the trees kept in the kv store are not versioned",
+			mp.config.PartitionId)
+		return proto.OpArgMismatchErr, nil
+	}
+	if mp.getMigration() != nil {
+		return 0, errors.New("the partition is being split")
+	}
+	// the data overwritten after the extents are frozen is written to new extents, whose keys are applied ahead
+	// of the fence, so only the extents created in between are frozen again once fenced
+	if err = mp.freezeVersionExtents(); err != nil {
+		log.LogErrorf("prepareSnapshotVersion: partitionID(%v) version(%v) freeze extents err(%v)",
+			mp.config.PartitionId, req.Version, err)
+		return
+	}
+	req.Time = time.Now().UnixNano()
+	req.Deadline = req.Time + int64(snapshotFenceTimeout)
+	// the writes submitted before are applied ahead of the fence, and the ones after are refused
+	mp.versionGate.Lock()
+	status, err = mp.submitSnapshotVersion(opFSMSnapshotPrepare, req)
+	mp.versionGate.Unlock()
+	if err != nil || status != proto.OpOk {
+		return
+	}
+	if err = mp.freezeVersionExtents(); err != nil {
+		log.LogErrorf("prepareSnapshotVersion: partitionID(%v) version(%v) freeze extents err(%v)",
+			mp.config.PartitionId, req.Version, err)
+		if _, abortErr := mp.submitSnapshotVersion(opFSMSnapshotAbort, req); abortErr != nil {
+			log.LogWarnf("prepareSnapshotVersion: partitionID(%v) version(%v) abort err(%v)",
+				mp.config.PartitionId, req.Version, abortErr)
+		}
+	}
+	return
+}
+
+// freezeVersionExtents freezes all the extents of the data partitions referenced by the inodes, the live inodes
+// write the data frozen to new extents instead.
+func (mp *metaPartition) freezeVersionExtents() (err error) {
+	if !proto.IsHot(mp.volType) {
+		return
+	}
+	partitions := make(map[uint64]struct{})
+	mp.inodeTree.GetTree().Ascend(func(item BtreeItem) bool {
+		item.(*Inode).Extents.Range(func(ek proto.ExtentKey) bool {
+			partitions[ek.PartitionId] = struct{}{}
+			return true
+		})
+		return true
+	})
+	for partitionID := range partitions {
+		if _, err = mp.freezeExtentsByPartition(partitionID, &proto.FreezeExtentsRequest{All: true}); err != nil {
+			return
+		}
+	}
+	log.LogInfof("freezeVersionExtents: partitionID(%v) data partitions(%v)", mp.config.PartitionId, len(partitions))
+	return
+}
+
+// readVersion returns the snapshot version read by the request, the version not found is replied OpNotExistErr.
+func (mp *metaPartition) readVersion(id uint64, p *Packet) *metaVersion {
+	v := mp.versions.get(id)
+	if v == nil {
+		p.PacketErrorWithBody(proto.OpNotExistErr, []byte(fmt.Sprintf("snapshot version %v not found", id)))
+	}
+	return v
+}
+
+func (mp *metaPartition) lookupVersion(req *LookupReq, p *Packet) (err error) {
+	v := mp.readVersion(req.Version, p)
+	if v == nil {
+		return
+	}
+	item := v.dentryTree.Get(&Dentry{ParentId: req.ParentID, Name: req.Name})
+	if item == nil {
+		status := proto.OpNotExistErr
+		if v.isShardedDir(req.ParentID) {
+			status = proto.OpDirShardedErr
+		}
+		p.PacketErrorWithBody(status, nil)
+		return
+	}
+	dentry := item.(*Dentry)
+	reply, err := json.Marshal(&LookupResp{Inode: dentry.Inode, Mode: dentry.Type})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+func (mp *metaPartition) readDirLimitVersion(req *ReadDirLimitReq, p *Packet) (err error) {
+	v := mp.readVersion(req.Version, p)
+	if v == nil {
+		return
+	}
+	resp := readDirLimitFrom(v.dentryTree, req)
+	if len(resp.Children) == 0 && v.isShardedDir(req.ParentID) {
+		p.PacketErrorWithBody(proto.OpDirShardedErr, nil)
+		return
+	}
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+func (mp *metaPartition) inodeGetVersion(req *InodeGetReq, p *Packet) (err error) {
+	v := mp.readVersion(req.Version, p)
+	if v == nil {
+		return
+	}
+	ino := v.getInode(req.Inode)
+	resp := &proto.InodeGetResponse{Info: &proto.InodeInfo{}}
+	if ino == nil || !replyInfo(resp.Info, ino, nil) {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+func (mp *metaPartition) extentsListVersion(req *proto.GetExtentsRequest, p *Packet) (err error) {
+	v := mp.readVersion(req.Version, p)
+	if v == nil {
+		return
+	}
+	ino := v.getInode(req.Inode)
+	if ino == nil || ino.ShouldDelete() {
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
+	// the data of the version is frozen
+	resp := &proto.GetExtentsResponse{Shared: true}
+	ino.DoReadFunc(func() {
+		resp.Generation = ino.Generation
+		resp.Size = ino.Size
+		ino.Extents.Range(func(ek proto.ExtentKey) bool {
+			resp.Extents = append(resp.Extents, ek)
+			return true
+		})
+	})
+	reply, err := json.Marshal(resp)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+func (mp *metaPartition) getAllXAttrVersion(req *proto.GetAllXAttrRequest, p *Packet) (err error) {
+	v := mp.readVersion(req.Version, p)
+	if v == nil {
+		return
+	}
+	response := &proto.GetAllXAttrResponse{
+		VolName:     req.VolName,
+		PartitionId: req.PartitionId,
+		Inode:       req.Inode,
+		Attrs:       make(map[string]string),
+	}
+	if item := v.extendTree.Get(NewExtend(req.Inode)); item != nil {
+		item.(*Extend).Range(func(key, val []byte) bool {
+			if strings.HasPrefix(string(key), req.Prefix) {
+				response.Attrs[string(key)] = string(val)
+			}
+			return true
+		})
+	}
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(encoded)
+	return
+}
@@ -0,0 +1,316 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func newVersionTestPartition(t *testing.T, ctrl *gomock.Controller, rootDir string) *metaPartition {
+	mp := newMigrateTestPartition(t, ctrl, rootDir, 1, 1, 1000)
+	mp.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+	mp.mqMgr = NewQuotaManager(mp.config.VolName, mp.config.PartitionId)
+
+	mp.inodeTree.ReplaceOrInsert(NewInode(1, uint32(os.ModeDir)), true)
+	file := NewInode(10, 0)
+	file.Size = 100
+	file.Extents.Append(proto.ExtentKey{PartitionId: 1, ExtentId: 1025, Size: 100})
+	mp.inodeTree.ReplaceOrInsert(file, true)
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 1, Name: "f", Inode: 10}, true)
+	extend := NewExtend(10)
+	extend.Put([]byte("user.k"), []byte("v1"))
+	mp.extendTree.ReplaceOrInsert(extend, true)
+	return mp
+}
+
+func submitTestVersion(t *testing.T, mp *metaPartition, op uint32, version uint64, deadline time.Time) uint8 {
+	now := time.Now()
+	status, err := mp.submitSnapshotVersion(op, &fsmSnapshotVersionRequest{
+		Version:  version,
+		Time:     now.UnixNano(),
+		Deadline: deadline.UnixNano(),
+	})
+	require.NoError(t, err)
+	return status
+}
+
+func readTestVersionExtents(t *testing.T, mp *metaPartition, version, ino uint64) *proto.GetExtentsResponse {
+	p := &Packet{}
+	require.NoError(t, mp.ExtentsList(&proto.GetExtentsRequest{Inode: ino, Version: version}, p))
+	require.Equal(t, proto.OpOk, p.ResultCode)
+	resp := &proto.GetExtentsResponse{}
+	require.NoError(t, json.Unmarshal(p.Data, resp))
+	return resp
+}
+
+// deletedTestExtents returns the extents sent to be deleted so far.
+func deletedTestExtents(mp *metaPartition) (eks []proto.ExtentKey) {
+	for {
+		select {
+		case deleted := <-mp.extDelCh:
+			eks = append(eks, deleted...)
+		default:
+			return
+		}
+	}
+}
+
+func TestMetaPartition_SnapshotVersion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testSnapshotVersion/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+	mp := newVersionTestPartition(t, ctrl, rootDir)
+	ref := extentRef{PartitionId: 1, ExtentId: 1025}
+
+	deadline := time.Now().Add(time.Minute)
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotPrepare, 7, deadline))
+	// the writes are refused until the version is committed
+	val, err := (&Dentry{ParentId: 1, Name: "g", Inode: 10}).Marshal()
+	require.NoError(t, err)
+	_, err = mp.submit(opFSMCreateDentry, val)
+	require.Equal(t, ErrSnapshotFenced, err)
+	require.Equal(t, proto.OpAgain, submitTestVersion(t, mp, opFSMSnapshotPrepare, 8, deadline))
+
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotCommit, 7, deadline))
+	require.Nil(t, mp.versions.getFence())
+	require.Equal(t, uint32(2), mp.extentRefs.count(ref))
+
+	// the live tree is written after the version is committed
+	_, err = mp.submit(opFSMCreateDentry, val)
+	require.NoError(t, err)
+	submitTestDentry(t, mp, opFSMDeleteDentry, &Dentry{ParentId: 1, Name: "f"})
+	overwrite := NewInode(10, 0)
+	overwrite.Extents.Append(proto.ExtentKey{PartitionId: 1, ExtentId: 1026, Size: 200})
+	val, err = overwrite.Marshal()
+	require.NoError(t, err)
+	resp, err := mp.submit(opFSMExtentsAdd, val)
+	require.NoError(t, err)
+	require.Equal(t, proto.OpOk, resp.(uint8))
+	extend := NewExtend(10)
+	extend.Put([]byte("user.k"), []byte("v2"))
+	val, err = extend.Bytes()
+	require.NoError(t, err)
+	_, err = mp.submit(opFSMSetXAttr, val)
+	require.NoError(t, err)
+	// the data replaced is kept for the version
+	require.Empty(t, deletedTestExtents(mp))
+	require.Equal(t, uint32(0), mp.extentRefs.count(ref))
+
+	// the version is not changed
+	p := &Packet{}
+	require.NoError(t, mp.Lookup(&LookupReq{ParentID: 1, Name: "f", Version: 7}, p))
+	require.Equal(t, proto.OpOk, p.ResultCode)
+	p = &Packet{}
+	require.NoError(t, mp.Lookup(&LookupReq{ParentID: 1, Name: "g", Version: 7}, p))
+	require.Equal(t, proto.OpNotExistErr, p.ResultCode)
+	p = &Packet{}
+	require.NoError(t, mp.ReadDirLimit(&ReadDirLimitReq{ParentID: 1, Version: 7}, p))
+	dir := &ReadDirLimitResp{}
+	require.NoError(t, json.Unmarshal(p.Data, dir))
+	require.Equal(t, []proto.Dentry{{Name: "f", Inode: 10}}, dir.Children)
+	extents := readTestVersionExtents(t, mp, 7, 10)
+	require.Equal(t, uint64(100), extents.Size)
+	require.Equal(t, []proto.ExtentKey{{PartitionId: 1, ExtentId: 1025, Size: 100}}, extents.Extents)
+	p = &Packet{}
+	require.NoError(t, mp.InodeGet(&InodeGetReq{Inode: 10, Version: 7}, p))
+	info := &proto.InodeGetResponse{}
+	require.NoError(t, json.Unmarshal(p.Data, info))
+	require.Equal(t, uint64(100), info.Info.Size)
+	p = &Packet{}
+	require.NoError(t, mp.GetAllXAttr(&proto.GetAllXAttrRequest{Inode: 10, Version: 7}, p))
+	xattrs := &proto.GetAllXAttrResponse{}
+	require.NoError(t, json.Unmarshal(p.Data, xattrs))
+	require.Equal(t, map[string]string{"user.k": "v1"}, xattrs.Attrs)
+	// while the live tree is
+	p = &Packet{}
+	require.NoError(t, mp.ExtentsList(&proto.GetExtentsRequest{Inode: 10}, p))
+	live := &proto.GetExtentsResponse{}
+	require.NoError(t, json.Unmarshal(p.Data, live))
+	require.Equal(t, uint64(200), live.Size)
+	p = &Packet{}
+	require.NoError(t, mp.Lookup(&LookupReq{ParentID: 1, Name: "f", Version: 8}, p))
+	require.Equal(t, proto.OpNotExistErr, p.ResultCode)
+
+	// the file of the version is restored by the clone, which shares the data with the version
+	p = &Packet{}
+	require.NoError(t, mp.CloneInode(&proto.CloneInodeRequest{Inode: 10, Mode: 0644, Version: 7}, p))
+	require.Equal(t, proto.OpOk, p.ResultCode)
+	cloned := &proto.CloneInodeResponse{}
+	require.NoError(t, json.Unmarshal(p.Data, cloned))
+	require.Equal(t, uint64(100), cloned.Info.Size)
+	require.Equal(t, uint32(2), mp.extentRefs.count(ref))
+	p = &Packet{}
+	mp.CloneInode(&proto.CloneInodeRequest{Inode: 10, Mode: 0644, Version: 8}, p)
+	require.Equal(t, proto.OpNotExistErr, p.ResultCode)
+
+	// the data still referenced by the clone is kept once the version is deleted
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotDelete, 7, deadline))
+	require.Nil(t, mp.versions.get(7))
+	require.Equal(t, uint32(0), mp.extentRefs.count(ref))
+	require.Empty(t, deletedTestExtents(mp))
+
+	// the data referenced by the version only is deleted with the version
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotPrepare, 9, deadline))
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotCommit, 9, deadline))
+	require.Equal(t, uint32(2), mp.extentRefs.count(ref))
+	overwrite = NewInode(cloned.Info.Inode, 0)
+	overwrite.Extents.Append(proto.ExtentKey{PartitionId: 1, ExtentId: 1027, Size: 200})
+	val, err = overwrite.Marshal()
+	require.NoError(t, err)
+	_, err = mp.submit(opFSMExtentsAdd, val)
+	require.NoError(t, err)
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotDelete, 9, deadline))
+	require.Equal(t, []proto.ExtentKey{{PartitionId: 1, ExtentId: 1025, Size: 100}}, deletedTestExtents(mp))
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotDelete, 9, deadline))
+}
+
+func TestMetaPartition_SnapshotVersionShared(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testSnapshotVersionShared/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+	mp := newVersionTestPartition(t, ctrl, rootDir)
+	ref := extentRef{PartitionId: 1, ExtentId: 1025}
+
+	deadline := time.Now().Add(time.Minute)
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotPrepare, 7, deadline))
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotCommit, 7, deadline))
+	require.Equal(t, proto.OpExistErr, submitTestVersion(t, mp, opFSMSnapshotPrepare, 7, deadline))
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotPrepare, 8, deadline))
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotCommit, 8, deadline))
+	require.Equal(t, uint32(3), mp.extentRefs.count(ref))
+
+	// the data still referenced by the live inode is kept
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotDelete, 7, deadline))
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotDelete, 8, deadline))
+	require.Equal(t, uint32(0), mp.extentRefs.count(ref))
+	require.Empty(t, deletedTestExtents(mp))
+}
+
+func TestMetaPartition_SnapshotVersionFenceExpired(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testSnapshotVersionFence/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+	mp := newVersionTestPartition(t, ctrl, rootDir)
+
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotPrepare, 7, time.Now().Add(-time.Second)))
+	require.NotNil(t, mp.versions.getFence())
+	// the leader drops the expired fence, and the write is accepted
+	submitTestDentry(t, mp, opFSMCreateDentry, &Dentry{ParentId: 1, Name: "g", Inode: 10})
+	require.Nil(t, mp.versions.getFence())
+	require.Equal(t, proto.OpNotExistErr, submitTestVersion(t, mp, opFSMSnapshotCommit, 7, time.Now()))
+	require.Nil(t, mp.versions.get(7))
+
+	// the version is not committed after the deadline, even if the fence is not dropped yet
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotPrepare, 8, time.Now().Add(time.Millisecond)))
+	time.Sleep(2 * time.Millisecond)
+	require.Equal(t, proto.OpNotExistErr, submitTestVersion(t, mp, opFSMSnapshotCommit, 8, time.Now()))
+	require.Nil(t, mp.versions.get(8))
+	require.Nil(t, mp.versions.getFence())
+}
+
+func TestMetaPartition_SnapshotVersionStore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testSnapshotVersionStore/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+	mp := newVersionTestPartition(t, ctrl, rootDir)
+
+	deadline := time.Now().Add(time.Minute)
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotPrepare, 7, deadline))
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotCommit, 7, deadline))
+	submitTestDentry(t, mp, opFSMDeleteDentry, &Dentry{ParentId: 1, Name: "f"})
+	msg := newTestStoreMsg(mp, 1)
+	require.NoError(t, mp.store(msg))
+	_, err := os.Stat(versionFilePath(rootDir, 7))
+	require.NoError(t, err)
+
+	loaded := newMigrateTestPartition(t, ctrl, rootDir, 1, 1, 1000)
+	loaded.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+	loaded.mqMgr = NewQuotaManager(mp.config.VolName, mp.config.PartitionId)
+	require.NoError(t, loaded.LoadSnapshot(path.Join(rootDir, snapshotDir)))
+	require.Equal(t, []uint64{7}, loaded.versions.ids())
+	p := &Packet{}
+	require.NoError(t, loaded.Lookup(&LookupReq{ParentID: 1, Name: "f", Version: 7}, p))
+	require.Equal(t, proto.OpOk, p.ResultCode)
+	require.Equal(t, uint64(100), readTestVersionExtents(t, loaded, 7, 10).Size)
+
+	// the file of the version is removed once the snapshot without it is stored
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotDelete, 7, deadline))
+	require.NoError(t, mp.store(newTestStoreMsg(mp, 2)))
+	_, err = os.Stat(versionFilePath(rootDir, 7))
+	require.True(t, os.IsNotExist(err))
+
+	// a corrupted file is not loaded
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotPrepare, 9, deadline))
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotCommit, 9, deadline))
+	require.NoError(t, mp.store(newTestStoreMsg(mp, 3)))
+	data, err := os.ReadFile(versionFilePath(rootDir, 9))
+	require.NoError(t, err)
+	data[0]++
+	require.NoError(t, os.WriteFile(versionFilePath(rootDir, 9), data, 0644))
+	require.Error(t, loaded.LoadSnapshot(path.Join(rootDir, snapshotDir)))
+}
+
+func TestMetaPartition_SnapshotVersionRaftSnapshot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testSnapshotVersionRaft/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+	mp := newVersionTestPartition(t, ctrl, rootDir)
+	mp.manager.metaNode.raftSyncSnapFormatVersion = SnapFormatVersion_1
+
+	deadline := time.Now().Add(time.Minute)
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotPrepare, 7, deadline))
+	require.Equal(t, proto.OpOk, submitTestVersion(t, mp, opFSMSnapshotCommit, 7, deadline))
+	submitTestDentry(t, mp, opFSMDeleteDentry, &Dentry{ParentId: 1, Name: "f"})
+
+	iter, err := newMetaItemIterator(mp)
+	require.NoError(t, err)
+	versions := newVersionTable()
+	for _, record := range readSnapshotRecords(t, iter) {
+		item := NewMetaItem(0, nil, nil)
+		require.NoError(t, item.UnmarshalBinary(record))
+		switch item.Op {
+		case opFSMSnapVersionState:
+			require.NoError(t, versions.UnMarshal(item.V))
+		case opFSMSnapVersionItems:
+			v := versions.get(binary.BigEndian.Uint64(item.K))
+			require.NotNil(t, v)
+			require.NoError(t, applyVersionItems(v, item.V))
+		}
+	}
+	v := versions.get(7)
+	require.NotNil(t, v)
+	require.Equal(t, mp.versions.get(7).inodeTree.Len(), v.inodeTree.Len())
+	require.NotNil(t, v.dentryTree.Get(&Dentry{ParentId: 1, Name: "f"}))
+	require.NotNil(t, v.extendTree.Get(NewExtend(10)))
+}
@@ -136,7 +136,7 @@ func (mp *metaPartition) txInit(txInfo *proto.TransactionInfo, p *Packet) (ifo *
 		return nil, err
 	}
 
-	status, err := mp.submit(opFSMTxInit, val)
+	status, err := mp.submitTraced(p, opFSMTxInit, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return nil, err
@@ -180,7 +180,7 @@ func (mp *metaPartition) TxCommitRM(req *proto.TxApplyRMRequest, p *Packet) erro
 		return err
 	}
 
-	status, err := mp.submit(opFSMTxCommitRM, val)
+	status, err := mp.submitTraced(p, opFSMTxCommitRM, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return err
@@ -213,7 +213,7 @@ func (mp *metaPartition) TxRollbackRM(req *proto.TxApplyRMRequest, p *Packet) er
 		return err
 	}
 
-	status, err := mp.submit(opFSMTxRollbackRM, val)
+	status, err := mp.submitTraced(p, opFSMTxRollbackRM, val)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return err
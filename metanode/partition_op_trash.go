@@ -0,0 +1,139 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+func (mp *metaPartition) submitTrash(op uint32, req *fsmTrashRequest, p *Packet) (resp *TrashResp, err error) {
+	req.Now = Now.GetCurrentTime().Unix()
+	val, err := json.Marshal(req)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	r, err := mp.submitTraced(p, op, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	return r.(*TrashResp), nil
+}
+
+// TrashInode moves the inode of the dentry deleted into the trash, the inode which can not be
+// trashed is unlinked as UnlinkInode does.
+func (mp *metaPartition) TrashInode(req *proto.TrashInodeRequest, p *Packet) (err error) {
+	if err = mp.checkInodeUnlink(req.Inode); err != nil {
+		p.PacketErrorWithBody(proto.OpNotPerm, []byte(err.Error()))
+		return
+	}
+
+	resp, err := mp.submitTrash(opFSMTrashInode, &fsmTrashRequest{
+		Inode:    req.Inode,
+		ParentId: req.ParentId,
+		Name:     req.Name,
+		Path:     req.Path,
+		UniqID:   req.UniqID,
+	}, p)
+	if err != nil {
+		return
+	}
+	var reply []byte
+	if resp.Status == proto.OpOk {
+		result := &proto.TrashInodeResponse{
+			Info:    &proto.InodeInfo{},
+			Trashed: resp.Trashed,
+		}
+		replyInfo(result.Info, resp.Inode, make(map[uint32]*proto.MetaQuotaInfo, 0))
+		if reply, err = json.Marshal(result); err != nil {
+			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+			return
+		}
+	}
+	p.PacketErrorWithBody(resp.Status, reply)
+	return
+}
+
+// ListTrash returns the entries in the trash of the partition.
+func (mp *metaPartition) ListTrash(req *proto.ListTrashRequest, p *Packet) (err error) {
+	reply, err := json.Marshal(&proto.ListTrashResponse{Entries: mp.trash.list()})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// RestoreTrash takes the inode out of the trash and returns the entry, from which the dentry is
+// created again by the client.
+func (mp *metaPartition) RestoreTrash(req *proto.RestoreTrashRequest, p *Packet) (err error) {
+	resp, err := mp.submitTrash(opFSMRestoreTrash, &fsmTrashRequest{Inode: req.Inode}, p)
+	if err != nil {
+		return
+	}
+	var reply []byte
+	if resp.Status == proto.OpOk {
+		if reply, err = json.Marshal(&proto.RestoreTrashResponse{Entry: resp.Entry}); err != nil {
+			p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+			return
+		}
+	}
+	p.PacketErrorWithBody(resp.Status, reply)
+	return
+}
+
+// PurgeTrash deletes the inodes in the trash permanently.
+func (mp *metaPartition) PurgeTrash(req *proto.PurgeTrashRequest, p *Packet) (err error) {
+	resp, err := mp.submitTrash(opFSMPurgeTrash, &fsmTrashRequest{Inodes: req.Inodes, All: len(req.Inodes) == 0}, p)
+	if err != nil {
+		return
+	}
+	reply, err := json.Marshal(&proto.PurgeTrashResponse{Count: resp.Count})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// purgeExpiredTrash purges the entries kept longer than the trash interval of the volume, which is only
+// called by the leader. The entries are kept until purged manually if the trash is disabled afterwards.
+func (mp *metaPartition) purgeExpiredTrash() (purge int, err error) {
+	if mp.vol.volTrashInterval <= 0 || mp.trash.len() == 0 {
+		return
+	}
+	inodes := mp.trash.expired(Now.GetCurrentTime().Unix() - mp.vol.volTrashInterval*60)
+	if len(inodes) == 0 {
+		return
+	}
+	req := &fsmTrashRequest{Inodes: inodes, Now: Now.GetCurrentTime().Unix()}
+	val, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	resp, err := mp.submit(opFSMPurgeTrash, val)
+	if err != nil {
+		return
+	}
+	purge = resp.(*TrashResp).Count
+	log.LogInfof("purgeExpiredTrash: partition(%v) purge(%v)", mp.config.PartitionId, purge)
+	return
+}
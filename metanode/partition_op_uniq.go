@@ -26,7 +26,7 @@ func (mp *metaPartition) GetUniqID(p *Packet, num uint32) (err error) {
 
 	idBuf := make([]byte, 4)
 	binary.BigEndian.PutUint32(idBuf, num)
-	resp, err := mp.submit(opFSMUniqID, idBuf)
+	resp, err := mp.submitTraced(p, opFSMUniqID, idBuf)
 	if err != nil {
 		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
 		return
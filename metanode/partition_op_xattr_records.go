@@ -0,0 +1,123 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// fsmUpdateXAttrRecordsRequest is the request with the sequence number stamped by the leader, so that the
+// key of the record appended is the same on all the replicas.
+type fsmUpdateXAttrRecordsRequest struct {
+	proto.UpdateXAttrRecordsRequest
+	Seq uint64 `json:"seq"`
+}
+
+type XAttrRecordsResult struct {
+	Status uint8
+	Key    string
+}
+
+// UpdateXAttrRecords updates the records of the prefix in the extended attributes of the inode, see
+// proto.UpdateXAttrRecordsRequest. The records are kept one per key, so the update is done in the partition
+// without reading and writing all the records of the prefix by the client.
+func (mp *metaPartition) UpdateXAttrRecords(req *proto.UpdateXAttrRecordsRequest, p *Packet) (err error) {
+	if req.Prefix == "" || len(req.Remove) == 0 && req.Append == "" {
+		err = fmt.Errorf("nothing to update of prefix(%v)", req.Prefix)
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	for key := range req.Remove {
+		if !strings.HasPrefix(key, req.Prefix) {
+			err = fmt.Errorf("key(%v) is not of prefix(%v)", key, req.Prefix)
+			p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+			return
+		}
+	}
+	val, err := json.Marshal(&fsmUpdateXAttrRecordsRequest{
+		UpdateXAttrRecordsRequest: *req,
+		Seq:                       uint64(time.Now().UnixNano()),
+	})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submitTraced(p, opFSMUpdateXAttrRecords, val)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	result := resp.(*XAttrRecordsResult)
+	if result.Status != proto.OpOk {
+		p.PacketErrorWithBody(result.Status, nil)
+		return
+	}
+	encoded, err := json.Marshal(&proto.UpdateXAttrRecordsResponse{Key: result.Key})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(encoded)
+	return
+}
+
+func (mp *metaPartition) fsmUpdateXAttrRecords(req *fsmUpdateXAttrRecordsRequest) (resp *XAttrRecordsResult) {
+	resp = &XAttrRecordsResult{Status: proto.OpOk}
+	var extend *Extend
+	if item := mp.extendTree.CopyGet(NewExtend(req.Inode)); item != nil {
+		extend = item.(*Extend)
+	}
+	if len(req.Remove) > 0 {
+		removed := NewExtend(req.Inode)
+		for key, value := range req.Remove {
+			if extend == nil {
+				resp.Status = proto.OpNotExistErr
+				return
+			}
+			if old, ok := extend.Get([]byte(key)); !ok || string(old) != value {
+				resp.Status = proto.OpNotExistErr
+				return
+			}
+			removed.Put([]byte(key), nil)
+		}
+		mp.fsmRemoveXAttr(removed)
+	}
+	if req.Append == "" {
+		return
+	}
+	seq := req.Seq
+	if extend != nil {
+		extend.Range(func(key, value []byte) bool {
+			if !strings.HasPrefix(string(key), req.Prefix) {
+				return true
+			}
+			if s, err := strconv.ParseUint(string(key[len(req.Prefix):]), 16, 64); err == nil && s >= seq {
+				seq = s + 1
+			}
+			return true
+		})
+	}
+	resp.Key = fmt.Sprintf("%s%016x", req.Prefix, seq)
+	appended := NewExtend(req.Inode)
+	appended.Put([]byte(resp.Key), []byte(req.Append))
+	mp.fsmSetXAttr(appended)
+	return
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaPartition_UpdateXAttrRecords(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testUpdateXAttrRecords/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+	mp := newMigrateTestPartition(t, ctrl, rootDir, 1, 1, 1000)
+
+	update := func(req *proto.UpdateXAttrRecordsRequest) (key string, status uint8) {
+		p := &Packet{}
+		req.Inode, req.Prefix = 10, "v/a/"
+		mp.UpdateXAttrRecords(req, p)
+		if p.ResultCode == proto.OpOk {
+			resp := &proto.UpdateXAttrRecordsResponse{}
+			require.NoError(t, json.Unmarshal(p.Data, resp))
+			key = resp.Key
+		}
+		return key, p.ResultCode
+	}
+	records := func(prefix string) map[string]string {
+		p := &Packet{}
+		require.NoError(t, mp.GetAllXAttr(&proto.GetAllXAttrRequest{Inode: 10, Prefix: prefix}, p))
+		resp := &proto.GetAllXAttrResponse{}
+		require.NoError(t, json.Unmarshal(p.Data, resp))
+		return resp.Attrs
+	}
+
+	_, status := update(&proto.UpdateXAttrRecordsRequest{})
+	require.Equal(t, proto.OpArgMismatchErr, status)
+	_, status = update(&proto.UpdateXAttrRecordsRequest{Remove: map[string]string{"v/b/1": "x"}})
+	require.Equal(t, proto.OpArgMismatchErr, status)
+
+	// the keys appended increase
+	first, status := update(&proto.UpdateXAttrRecordsRequest{Append: "1"})
+	require.Equal(t, proto.OpOk, status)
+	second, status := update(&proto.UpdateXAttrRecordsRequest{Append: "2"})
+	require.Equal(t, proto.OpOk, status)
+	require.True(t, second > first)
+	require.Equal(t, map[string]string{first: "1", second: "2"}, records("v/a/"))
+
+	// nothing is changed if any record removed is changed
+	_, status = update(&proto.UpdateXAttrRecordsRequest{
+		Remove: map[string]string{first: "1", second: "1"},
+		Append: "3",
+	})
+	require.Equal(t, proto.OpNotExistErr, status)
+	require.Equal(t, map[string]string{first: "1", second: "2"}, records("v/a/"))
+
+	third, status := update(&proto.UpdateXAttrRecordsRequest{
+		Remove: map[string]string{first: "1"},
+		Append: "3",
+	})
+	require.Equal(t, proto.OpOk, status)
+	require.True(t, third > second)
+	require.Equal(t, map[string]string{second: "2", third: "3"}, records("v/a/"))
+
+	// a record is removed once
+	_, status = update(&proto.UpdateXAttrRecordsRequest{Remove: map[string]string{second: "2"}})
+	require.Equal(t, proto.OpOk, status)
+	_, status = update(&proto.UpdateXAttrRecordsRequest{Remove: map[string]string{second: "2"}})
+	require.Equal(t, proto.OpNotExistErr, status)
+
+	// the other attributes are not returned with the prefix
+	p := &Packet{}
+	require.NoError(t, mp.SetXAttr(&proto.SetXAttrRequest{Inode: 10, Key: "user.k", Value: "v"}, p))
+	require.Equal(t, map[string]string{third: "3"}, records("v/a/"))
+	require.Len(t, records(""), 2)
+}
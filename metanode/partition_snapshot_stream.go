@@ -0,0 +1,443 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	raftproto "github.com/cubefs/cubefs/depends/tiglabs/raft/proto"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The raft snapshot of SnapFormatVersion_2 is sent as:
+//
+//	format version                      the MetaItem of opFSMSnapFormatVersion
+//	begin                               applyID(8) | the first chunk sent(8)
+//	chunk, chunk, ...                   seq(8) | crc(4) as the key, the items of version_1 as the value
+//
+// The items of a chunk are encoded as length(4) | MetaItem. The follower verifies the chunks and appends
+// them to the staging directory, the items are applied only after the last chunk is received. If the
+// transfer is interrupted, the leader sends the same snapshot again starting from the chunks the
+// follower hasn't staged.
+const (
+	snapChunkKeySize      = 12
+	snapChunkProgressFile = "progress"
+	snapChunkDataFile     = "chunks"
+	defaultSnapChunkLen   = 1024 * 1024
+	snapshotCacheTTL      = 30 * time.Minute
+)
+
+// the size of the items sent in a chunk
+var snapChunkSize = defaultSnapChunkLen
+
+// chunkState is the progress of the leader sending the chunks.
+type chunkState struct {
+	versionSent bool
+	begun       bool
+	seq         uint64
+	startSeq    uint64
+}
+
+func (si *MetaItemIterator) nextChunk() (data []byte, err error) {
+	if si.err != nil {
+		return nil, si.err
+	}
+	if !si.chunk.versionSent {
+		si.chunk.versionSent = true
+		return si.nextItem()
+	}
+	if !si.chunk.begun {
+		si.chunk.begun = true
+		si.chunk.startSeq = si.mp.querySnapshotProgress(si.target, si.applyID)
+		value := make([]byte, 16)
+		binary.BigEndian.PutUint64(value[:8], si.applyID)
+		binary.BigEndian.PutUint64(value[8:], si.chunk.startSeq)
+		return NewMetaItem(opFSMSnapBegin, nil, value).MarshalBinary()
+	}
+	for {
+		var payload []byte
+		for len(payload) < snapChunkSize {
+			var item []byte
+			if item, err = si.nextItem(); err == io.EOF {
+				break
+			}
+			if err != nil {
+				return
+			}
+			lenBuf := make([]byte, 4)
+			binary.BigEndian.PutUint32(lenBuf, uint32(len(item)))
+			payload = append(payload, lenBuf...)
+			payload = append(payload, item...)
+		}
+		if len(payload) == 0 {
+			return nil, io.EOF
+		}
+		seq := si.chunk.seq
+		si.chunk.seq++
+		if seq < si.chunk.startSeq {
+			// staged by the follower
+			continue
+		}
+		key := make([]byte, snapChunkKeySize)
+		binary.BigEndian.PutUint64(key[:8], seq)
+		binary.BigEndian.PutUint32(key[8:], crc32.ChecksumIEEE(payload))
+		return NewMetaItem(opFSMSnapChunk, key, payload).MarshalBinary()
+	}
+}
+
+// querySnapshotProgress returns the number of the chunks of the snapshot staged by the node, 0 if unknown.
+func (mp *metaPartition) querySnapshotProgress(nodeID, applyID uint64) (chunks uint64) {
+	var addr string
+	for _, peer := range mp.config.Peers {
+		if peer.ID == nodeID && nodeID != mp.config.NodeId {
+			addr = peer.Addr
+			break
+		}
+	}
+	if addr == "" {
+		return
+	}
+	resp, err := mp.sendSnapshotProgress(addr)
+	if err != nil {
+		log.LogWarnf("querySnapshotProgress: partitionID(%v) node(%v) err(%v)", mp.config.PartitionId, addr, err)
+		return
+	}
+	if resp.ApplyID != applyID {
+		return
+	}
+	log.LogWarnf("querySnapshotProgress: partitionID(%v) resume the snapshot of applyID(%v) to node(%v) from chunk(%v)",
+		mp.config.PartitionId, applyID, addr, resp.Chunks)
+	return resp.Chunks
+}
+
+func (mp *metaPartition) sendSnapshotProgress(addr string) (resp *proto.MetaSnapshotProgressResponse, err error) {
+	p := proto.NewPacketReqID()
+	p.Opcode = proto.OpMetaSnapshotProgress
+	p.PartitionID = mp.config.PartitionId
+	if p.Data, err = json.Marshal(&proto.MetaSnapshotProgressRequest{PartitionID: mp.config.PartitionId}); err != nil {
+		return
+	}
+	p.Size = uint32(len(p.Data))
+	reqID, reqOp := p.ReqID, p.Opcode
+
+	connPool := mp.manager.connPool
+	conn, err := connPool.GetConnect(addr)
+	if err != nil {
+		return
+	}
+	defer func() {
+		connPool.PutConnect(conn, err != nil)
+	}()
+	if err = p.WriteToConn(conn); err != nil {
+		return
+	}
+	if err = p.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+		return
+	}
+	if reqID != p.ReqID || reqOp != p.Opcode {
+		err = fmt.Errorf("send and received packet mismatch: req(%v_%v) resp(%v_%v)", reqID, reqOp, p.ReqID, p.Opcode)
+		return
+	}
+	if p.ResultCode != proto.OpOk {
+		err = fmt.Errorf("result(%v) %s", p.GetResultMsg(), string(p.Data))
+		return
+	}
+	resp = &proto.MetaSnapshotProgressResponse{}
+	err = json.Unmarshal(p.Data, resp)
+	return
+}
+
+// SnapshotProgress returns the chunks of the snapshot staged by the follower.
+func (mp *metaPartition) SnapshotProgress(req *proto.MetaSnapshotProgressRequest, p *Packet) (err error) {
+	progress, err := readSnapChunkProgress(path.Join(mp.config.RootDir, snapshotRecvDir))
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	reply, err := json.Marshal(&proto.MetaSnapshotProgressResponse{ApplyID: progress.ApplyID, Chunks: progress.Chunks})
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpErr, []byte(err.Error()))
+		return
+	}
+	p.PacketOkWithBody(reply)
+	return
+}
+
+// snapChunkProgress records the chunks staged, size is the length of the data file verified.
+type snapChunkProgress struct {
+	ApplyID uint64 `json:"apply_id"`
+	Chunks  uint64 `json:"chunks"`
+	Size    int64  `json:"size"`
+}
+
+// readSnapChunkProgress returns the empty progress if nothing is staged.
+func readSnapChunkProgress(dir string) (progress *snapChunkProgress, err error) {
+	progress = &snapChunkProgress{}
+	data, err := ioutil.ReadFile(path.Join(dir, snapChunkProgressFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	if err = json.Unmarshal(data, progress); err != nil {
+		err = errors.NewErrorf("[readSnapChunkProgress] Unmarshal: %s", err.Error())
+	}
+	return
+}
+
+func (progress *snapChunkProgress) store(dir string) (err error) {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return
+	}
+	tmpFile := path.Join(dir, "."+snapChunkProgressFile)
+	if err = ioutil.WriteFile(tmpFile, data, 0644); err != nil {
+		return
+	}
+	return os.Rename(tmpFile, path.Join(dir, snapChunkProgressFile))
+}
+
+// snapshotReceiver passes the snapshot of the former versions through, and stages the chunks of
+// SnapFormatVersion_2 before returning the items of them.
+type snapshotReceiver struct {
+	mp      *metaPartition
+	iter    raftproto.SnapIterator
+	dir     string
+	started bool
+	chunked bool
+	done    bool
+	fp      *os.File
+	reader  *bufio.Reader
+}
+
+func (mp *metaPartition) newSnapshotReceiver(iter raftproto.SnapIterator) *snapshotReceiver {
+	return &snapshotReceiver{mp: mp, iter: iter, dir: path.Join(mp.config.RootDir, snapshotRecvDir)}
+}
+
+func (r *snapshotReceiver) Next() (data []byte, err error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	if r.reader != nil {
+		return r.nextItem()
+	}
+	if r.started && !r.chunked {
+		return r.iter.Next()
+	}
+	if !r.started {
+		r.started = true
+		if data, err = r.iter.Next(); err != nil {
+			return
+		}
+		item := NewMetaItem(0, nil, nil)
+		if item.UnmarshalBinary(data) == nil && item.Op == opFSMSnapFormatVersion && len(item.V) >= 4 &&
+			binary.BigEndian.Uint32(item.V) == SnapFormatVersion_2 {
+			r.chunked = true
+		}
+		return
+	}
+	if err = r.receive(); err != nil {
+		return
+	}
+	return r.nextItem()
+}
+
+// receive stages the chunks until the last one, then the staged items are read.
+func (r *snapshotReceiver) receive() (err error) {
+	data, err := r.iter.Next()
+	if err != nil {
+		return
+	}
+	begin := NewMetaItem(0, nil, nil)
+	if err = begin.UnmarshalBinary(data); err != nil {
+		return
+	}
+	if begin.Op != opFSMSnapBegin || len(begin.V) != 16 {
+		return fmt.Errorf("snapshot begin mismatch, op(%v)", begin.Op)
+	}
+	applyID, startSeq := binary.BigEndian.Uint64(begin.V[:8]), binary.BigEndian.Uint64(begin.V[8:])
+
+	progress, err := readSnapChunkProgress(r.dir)
+	if err != nil {
+		return
+	}
+	if startSeq == 0 || progress.ApplyID != applyID || progress.Chunks != startSeq {
+		if startSeq != 0 {
+			os.RemoveAll(r.dir)
+			return fmt.Errorf("snapshot of applyID(%v) resumed from chunk(%v), staged applyID(%v) chunks(%v)",
+				applyID, startSeq, progress.ApplyID, progress.Chunks)
+		}
+		if err = os.RemoveAll(r.dir); err != nil {
+			return
+		}
+		progress = &snapChunkProgress{ApplyID: applyID}
+	}
+	if err = os.MkdirAll(r.dir, 0755); err != nil {
+		return
+	}
+	fp, err := os.OpenFile(path.Join(r.dir, snapChunkDataFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			fp.Close()
+		}
+	}()
+	if err = fp.Truncate(progress.Size); err != nil {
+		return
+	}
+	if _, err = fp.Seek(progress.Size, io.SeekStart); err != nil {
+		return
+	}
+	log.LogWarnf("snapshotReceiver: partitionID(%v) receive the snapshot of applyID(%v) from chunk(%v)",
+		r.mp.config.PartitionId, applyID, startSeq)
+
+	for {
+		if data, err = r.iter.Next(); err == io.EOF {
+			break
+		}
+		if err != nil {
+			return
+		}
+		chunk := NewMetaItem(0, nil, nil)
+		if err = chunk.UnmarshalBinary(data); err != nil {
+			return
+		}
+		if chunk.Op != opFSMSnapChunk || len(chunk.K) != snapChunkKeySize {
+			return fmt.Errorf("snapshot chunk mismatch, op(%v)", chunk.Op)
+		}
+		seq, crc := binary.BigEndian.Uint64(chunk.K[:8]), binary.BigEndian.Uint32(chunk.K[8:])
+		if seq != progress.Chunks {
+			return fmt.Errorf("snapshot chunk(%v) out of order, expect(%v)", seq, progress.Chunks)
+		}
+		if res := crc32.ChecksumIEEE(chunk.V); res != crc {
+			log.LogErrorf("snapshotReceiver: partitionID(%v) chunk(%v) crc mismatch, expected[%d], actual[%d]",
+				r.mp.config.PartitionId, seq, crc, res)
+			os.RemoveAll(r.dir)
+			return ErrSnapshotCrcMismatch
+		}
+		if _, err = fp.Write(chunk.V); err != nil {
+			return
+		}
+		if err = fp.Sync(); err != nil {
+			return
+		}
+		progress.Chunks++
+		progress.Size += int64(len(chunk.V))
+		if err = progress.store(r.dir); err != nil {
+			return
+		}
+	}
+	log.LogWarnf("snapshotReceiver: partitionID(%v) received the snapshot of applyID(%v) chunks(%v) size(%v)",
+		r.mp.config.PartitionId, applyID, progress.Chunks, progress.Size)
+
+	if _, err = fp.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	r.fp = fp
+	r.reader = bufio.NewReaderSize(fp, 4*1024*1024)
+	return
+}
+
+func (r *snapshotReceiver) nextItem() (data []byte, err error) {
+	lenBuf := make([]byte, 4)
+	if _, err = io.ReadFull(r.reader, lenBuf); err != nil {
+		r.close()
+		if err == io.EOF {
+			// all read, nothing to resume
+			os.RemoveAll(r.dir)
+		}
+		return
+	}
+	data = make([]byte, binary.BigEndian.Uint32(lenBuf))
+	if _, err = io.ReadFull(r.reader, data); err != nil {
+		r.close()
+	}
+	return
+}
+
+func (r *snapshotReceiver) close() {
+	r.done = true
+	if r.fp != nil {
+		r.fp.Close()
+		r.fp = nil
+	}
+}
+
+// snapshotCache keeps the state of the raft snapshot sent by the leader, so that the same snapshot
+// is sent again to resume an interrupted transfer.
+type snapshotCache struct {
+	sync.Mutex
+	state   *MetaItemIterator
+	created time.Time
+}
+
+func (c *snapshotCache) restore(si *MetaItemIterator) bool {
+	c.Lock()
+	defer c.Unlock()
+	if c.state == nil || si.SnapFormatVersion != SnapFormatVersion_2 {
+		return false
+	}
+	if time.Since(c.created) > snapshotCacheTTL {
+		c.state = nil
+		return false
+	}
+	s := c.state
+	si.applyID, si.txId, si.cursor, si.uniqID = s.applyID, s.txId, s.cursor, s.uniqID
+	si.inodeTree, si.dentryTree, si.extendTree, si.multipartTree = s.inodeTree, s.dentryTree, s.extendTree, s.multipartTree
+	si.txTree, si.txRbInodeTree, si.txRbDentryTree = s.txTree, s.txRbInodeTree, s.txRbDentryTree
+	si.uniqChecker, si.fileLocks, si.extentRefs, si.trash, si.orphans = s.uniqChecker, s.fileLocks, s.extentRefs, s.trash, s.orphans
+	si.versions, si.split = s.versions, s.split
+	return true
+}
+
+func (c *snapshotCache) save(si *MetaItemIterator) {
+	// the views of the trees kept in the kv store are not shared
+	if si.SnapFormatVersion != SnapFormatVersion_2 || si.inodeTree.kv != nil {
+		return
+	}
+	c.Lock()
+	c.state, c.created = si, time.Now()
+	c.Unlock()
+}
+
+// expire drops the snapshot before the index.
+func (c *snapshotCache) expire(index uint64) {
+	c.Lock()
+	if c.state != nil && c.state.applyID < index {
+		c.state = nil
+	}
+	c.Unlock()
+}
+
+func (c *snapshotCache) reset() {
+	c.Lock()
+	c.state = nil
+	c.Unlock()
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordIterator returns the records, then the err.
+type recordIterator struct {
+	records [][]byte
+	err     error
+}
+
+func (it *recordIterator) Next() ([]byte, error) {
+	if len(it.records) == 0 {
+		return nil, it.err
+	}
+	data := it.records[0]
+	it.records = it.records[1:]
+	return data, nil
+}
+
+func newSnapshotTestPartition(t *testing.T, rootDir string, version uint32) *metaPartition {
+	metaM := &metadataManager{
+		nodeId:     1,
+		partitions: make(map[uint64]MetaPartition),
+		metaNode:   &MetaNode{raftSyncSnapFormatVersion: version},
+	}
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1, VolName: "test_vol", End: 1000, RootDir: rootDir}, metaM).(*metaPartition)
+	require.NoError(t, os.MkdirAll(rootDir, 0755))
+	return mp
+}
+
+func readSnapshotRecords(t *testing.T, iter interface{ Next() ([]byte, error) }) (records [][]byte) {
+	for {
+		data, err := iter.Next()
+		if err == io.EOF {
+			return
+		}
+		require.NoError(t, err)
+		records = append(records, data)
+	}
+}
+
+func TestSnapshotChunkStream(t *testing.T) {
+	leaderDir, followerDir := "/tmp/testSnapshotChunkLeader/", "/tmp/testSnapshotChunkFollower/"
+	os.RemoveAll(leaderDir)
+	os.RemoveAll(followerDir)
+	defer os.RemoveAll(leaderDir)
+	defer os.RemoveAll(followerDir)
+	defer func(size int) { snapChunkSize = size }(snapChunkSize)
+	snapChunkSize = 128
+
+	leader := newSnapshotTestPartition(t, leaderDir, SnapFormatVersion_2)
+	for ino := uint64(1); ino <= 50; ino++ {
+		leader.inodeTree.ReplaceOrInsert(NewInode(ino, 0), true)
+	}
+	iter, err := newMetaItemIterator(leader)
+	require.NoError(t, err)
+	records := readSnapshotRecords(t, iter)
+	chunks := records[2:]
+	require.True(t, len(chunks) > 3)
+
+	// the same snapshot is sent again while it's cached
+	iter, err = newMetaItemIterator(leader)
+	require.NoError(t, err)
+	leader.inodeTree.ReplaceOrInsert(NewInode(51, 0), true)
+	require.Equal(t, records, readSnapshotRecords(t, iter))
+
+	// the items of the chunks are the same as version_1
+	leader.manager.metaNode.raftSyncSnapFormatVersion = SnapFormatVersion_1
+	leader.snapshotCache.reset()
+	leader.inodeTree.Delete(NewInode(51, 0))
+	iter, err = newMetaItemIterator(leader)
+	require.NoError(t, err)
+	items := readSnapshotRecords(t, iter)
+
+	follower := newSnapshotTestPartition(t, followerDir, SnapFormatVersion_2)
+	broken := errors.New("connection broken")
+	receiver := follower.newSnapshotReceiver(&recordIterator{records: records[:5], err: broken})
+	_, err = receiver.Next()
+	require.NoError(t, err)
+	_, err = receiver.Next()
+	require.Equal(t, broken, err)
+	progress, err := readSnapChunkProgress(receiver.dir)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), progress.Chunks)
+
+	// resume from the chunks staged
+	begin := NewMetaItem(0, nil, nil)
+	require.NoError(t, begin.UnmarshalBinary(records[1]))
+	binary.BigEndian.PutUint64(begin.V[8:], progress.Chunks)
+	beginRaw, err := begin.MarshalBinary()
+	require.NoError(t, err)
+	resumed := append([][]byte{records[0], beginRaw}, chunks[3:]...)
+	receiver = follower.newSnapshotReceiver(&recordIterator{records: resumed, err: io.EOF})
+	got := readSnapshotRecords(t, receiver)
+	require.Equal(t, items[0][:len(items[0])-8], got[0][:len(got[0])-8]) // the format versions differ
+	require.Equal(t, items[1:], got[1:])
+	_, err = os.Stat(receiver.dir)
+	require.True(t, os.IsNotExist(err))
+
+	// a corrupted chunk is rejected and the staged chunks are dropped
+	corrupted := NewMetaItem(0, nil, nil)
+	require.NoError(t, corrupted.UnmarshalBinary(chunks[1]))
+	corrupted.V[0]++
+	corruptedRaw, err := corrupted.MarshalBinary()
+	require.NoError(t, err)
+	receiver = follower.newSnapshotReceiver(&recordIterator{records: [][]byte{records[0], records[1], chunks[0], corruptedRaw}, err: io.EOF})
+	_, err = receiver.Next()
+	require.NoError(t, err)
+	_, err = receiver.Next()
+	require.Equal(t, ErrSnapshotCrcMismatch, err)
+	progress, err = readSnapChunkProgress(receiver.dir)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), progress.Chunks)
+}
@@ -0,0 +1,939 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/btree"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// A meta partition is split by moving the inodes from a start to its end, with the dentries of
+// which they're the parent and their extended attributes, to the target partition created by
+// master. The leader drives the migration:
+//
+//	start    opFSMMigrateStart records the migration, the leader takes the views of the trees and
+//	         tracks the keys changed since
+//	copy     the items of the views are sent to the target, then the keys changed, in rounds
+//	freeze   opFSMMigrateFreeze rejects the requests of the range, the last changes are sent
+//	commit   opFSMMigrateCommit drops the items of the range and shrinks the end of the partition
+//
+// The range is frozen by the raft log until the migration is committed or aborted. The commit
+// carries the digest of the items sent, which are read from the views of the trees taken at an
+// index, every replica drops the range only if its items match the digest, otherwise the range
+// is changed after the index and the changes are sent again. The commit is rejected if the freeze
+// expired, in which case the leader aborts the migration once it serves a request of the range.
+// The migration record is kept in the snapshot with the end and the moved ranges, see splitState,
+// so that the replicas restarted or installing a snapshot decide the commit alike.
+//
+// The items are sent in the records of the snapshot deltas, the target applies them through its
+// own raft log. The requests of the range received after the commit are answered with
+// OpMetaPartitionMovedErr naming the target, so that the clients with a stale view re-route them.
+// The partitions kept in the kv store don't track the changes, they're frozen before the copy.
+const (
+	migrateBatchSize     = 1024 * 1024
+	migrateMaxRounds     = 8
+	migrateFreezeChanges = 1024 // the range is frozen once a round sends fewer changes
+	migrateCommitRetries = 3
+	migrateSendRetries   = 10
+	migrateFreezeTimeout = time.Minute
+
+	// with deltaOpPut and deltaOpDelete, drops the items sent by an earlier migration
+	migrateOpReset byte = 3
+	// replaces the references of the extents shared by the inodes of the range
+	migrateOpExtentRefs byte = 4
+)
+
+// the codecs of the trees migrated, the multiparts are not keyed by the inode and stay
+var migrateCodecs = kvCodecs[:3]
+
+// movedRange is the range split off the end of the partition to another one.
+type movedRange struct {
+	Start       uint64 `json:"start"`
+	PartitionID uint64 `json:"pid"`
+}
+
+type fsmMigrateRequest struct {
+	TargetID uint64 `json:"target"`
+	Start    uint64 `json:"start"`
+	Time     int64  `json:"time,omitempty"`     // unix nano, set by the leader
+	Deadline int64  `json:"deadline,omitempty"` // unix nano, of the freeze
+	Digest   uint32 `json:"digest,omitempty"`   // of the items sent, of the commit
+}
+
+// partitionMigration is the split in progress. The record is replicated and persisted with the
+// partition, the views and the changes are kept by the leader driving the migration only.
+type partitionMigration struct {
+	Deadline int64  `json:"deadline,omitempty"` // unix nano, set once the range is frozen
+	TargetID uint64 `json:"target"`
+	Start    uint64 `json:"start"`
+	views    []*BTree
+}
+
+func (m *partitionMigration) frozen() bool {
+	return atomic.LoadInt64(&m.Deadline) != 0
+}
+
+func (m *partitionMigration) expired(now int64) bool {
+	deadline := atomic.LoadInt64(&m.Deadline)
+	return deadline != 0 && now >= deadline
+}
+
+// record returns the replicated part of the migration.
+func (m *partitionMigration) record() *partitionMigration {
+	return &partitionMigration{Deadline: atomic.LoadInt64(&m.Deadline), TargetID: m.TargetID, Start: m.Start}
+}
+
+// migrationState guards the migration and the moved ranges of the partition config.
+type migrationState struct {
+	sync.RWMutex
+	driving bool
+}
+
+func (mp *metaPartition) getMigration() *partitionMigration {
+	mp.migration.RLock()
+	defer mp.migration.RUnlock()
+	return mp.config.Migration
+}
+
+func (mp *metaPartition) setMigration(m *partitionMigration) {
+	mp.migration.Lock()
+	mp.config.Migration = m
+	mp.migration.Unlock()
+}
+
+// acquire returns false if the migration is driven by another task.
+func (s *migrationState) acquire() bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.driving {
+		return false
+	}
+	s.driving = true
+	return true
+}
+
+func (s *migrationState) release() {
+	s.Lock()
+	s.driving = false
+	s.Unlock()
+}
+
+// migrateKeys returns the least keys of the trees migrated from the start.
+func migrateKeys(start uint64) []BtreeItem {
+	return []BtreeItem{NewInode(start, 0), &Dentry{ParentId: start}, NewExtend(start)}
+}
+
+func (mp *metaPartition) migrateTrees() []*BTree {
+	return mp.snapshotTrees()[:len(migrateCodecs)]
+}
+
+// migratedInode returns the inode which decides the partition of the item.
+func migratedInode(item BtreeItem) uint64 {
+	switch typedItem := item.(type) {
+	case *Inode:
+		return typedItem.Inode
+	case *Dentry:
+		return typedItem.ParentId
+	case *Extend:
+		return typedItem.inode
+	}
+	return 0
+}
+
+func lookupMovedRange(moves []movedRange, ino uint64) (partitionID uint64) {
+	var start uint64
+	for _, r := range moves {
+		if ino >= r.Start && r.Start >= start {
+			start, partitionID = r.Start, r.PartitionID
+		}
+	}
+	return
+}
+
+// stopMigration stops tracking the changes and releases the views of the migration.
+func (mp *metaPartition) stopMigration() {
+	for _, tree := range mp.migrateTrees() {
+		tree.stopMigration()
+	}
+	if m := mp.getMigration(); m != nil {
+		for _, view := range m.views {
+			view.Release()
+		}
+		m.views = nil
+	}
+}
+
+func (mp *metaPartition) fsmMigrateStart(req *fsmMigrateRequest) (status uint8) {
+	if req.Start <= mp.config.Start || req.Start > mp.config.End {
+		return proto.OpArgMismatchErr
+	}
+	mp.stopMigration()
+	mp.setMigration(&partitionMigration{TargetID: req.TargetID, Start: req.Start})
+	mp.persistMigration("fsmMigrateStart")
+	log.LogWarnf("fsmMigrateStart: partitionID(%v) start(%v) target(%v)", mp.config.PartitionId, req.Start, req.TargetID)
+	return proto.OpOk
+}
+
+func (mp *metaPartition) fsmMigrateFreeze(req *fsmMigrateRequest) (status uint8) {
+	m := mp.getMigration()
+	if m == nil || m.Start != req.Start || m.TargetID != req.TargetID {
+		return proto.OpNotExistErr
+	}
+	if m.frozen() {
+		return proto.OpOk
+	}
+	atomic.StoreInt64(&m.Deadline, req.Deadline)
+	mp.persistMigration("fsmMigrateFreeze")
+	log.LogWarnf("fsmMigrateFreeze: partitionID(%v) start(%v) target(%v) deadline(%v)",
+		mp.config.PartitionId, m.Start, m.TargetID, req.Deadline)
+	return proto.OpOk
+}
+
+// fsmMigrateCommit drops the items of the range, they're kept by the target from now on. The commit is
+// decided by the migration record and the items of the range only, which are alike on every replica.
+func (mp *metaPartition) fsmMigrateCommit(req *fsmMigrateRequest) (status uint8) {
+	m := mp.getMigration()
+	if m == nil || m.Start != req.Start || m.TargetID != req.TargetID || !m.frozen() {
+		return proto.OpNotExistErr
+	}
+	if m.expired(req.Time) {
+		// the requests of the range may have been served after the deadline
+		log.LogWarnf("fsmMigrateCommit: partitionID(%v) start(%v) freeze expired", mp.config.PartitionId, m.Start)
+		return proto.OpNotExistErr
+	}
+	digest, err := migrateDigest(mp.migrateTrees(), m.Start)
+	if err != nil {
+		log.LogErrorf("fsmMigrateCommit: partitionID(%v) start(%v) err(%v)", mp.config.PartitionId, m.Start, err)
+		return proto.OpErr
+	}
+	if digest != req.Digest {
+		// the range is changed after the items sent
+		return proto.OpAgain
+	}
+	mp.stopMigration()
+	kept, moved := mp.splitExtentRefs(m.Start)
+	for ref := range moved {
+		mp.extentRefs.set(ref, kept[ref])
+	}
+	keys := migrateKeys(m.Start)
+	var dropped int
+	for i, tree := range mp.migrateTrees() {
+		var items []BtreeItem
+		tree.AscendGreaterOrEqual(keys[i], func(item BtreeItem) bool {
+			items = append(items, item)
+			return true
+		})
+		for _, item := range items {
+			tree.Delete(item)
+			if ino, ok := item.(*Inode); ok {
+				mp.freeList.Remove(ino.Inode)
+			}
+		}
+		dropped += len(items)
+	}
+
+	mp.migration.Lock()
+	mp.config.End = m.Start - 1
+	if mp.config.Cursor > mp.config.End {
+		mp.config.Cursor = mp.config.End
+	}
+	if lookupMovedRange(mp.config.Moves, m.Start) != m.TargetID {
+		mp.config.Moves = append(mp.config.Moves, movedRange{Start: m.Start, PartitionID: m.TargetID})
+	}
+	mp.config.Migration = nil
+	mp.migration.Unlock()
+	mp.persistMigration("fsmMigrateCommit")
+	log.LogWarnf("fsmMigrateCommit: partitionID(%v) moved start(%v) to target(%v), dropped items(%v) extentRefs(%v)",
+		mp.config.PartitionId, m.Start, m.TargetID, dropped, len(moved))
+	return proto.OpOk
+}
+
+func (mp *metaPartition) fsmMigrateAbort() (status uint8) {
+	mp.stopMigration()
+	mp.setMigration(nil)
+	mp.persistMigration("fsmMigrateAbort")
+	return proto.OpOk
+}
+
+// persistMigration persists the migration record and the moved ranges with the partition config, the
+// snapshot holds the ones consistent with its apply id.
+func (mp *metaPartition) persistMigration(action string) {
+	mp.migration.RLock()
+	err := mp.persistMetadata()
+	mp.migration.RUnlock()
+	if err != nil {
+		log.LogErrorf("%v: partitionID(%v) persist metadata err(%v)", action, mp.config.PartitionId, err)
+	}
+}
+
+// migrateDigest returns the checksum of the items of the range in the trees migrated, see fsmMigrateCommit.
+func migrateDigest(trees []*BTree, start uint64) (digest uint32, err error) {
+	hash := crc32.NewIEEE()
+	keys := migrateKeys(start)
+	for i, tree := range trees {
+		codec := migrateCodecs[i]
+		hash.Write([]byte{codec.tree})
+		tree.AscendGreaterOrEqual(keys[i], func(item BtreeItem) bool {
+			var raw []byte
+			if raw, err = codec.marshal(item); err != nil {
+				return false
+			}
+			hash.Write(raw)
+			return true
+		})
+		if err != nil {
+			return
+		}
+	}
+	return hash.Sum32(), nil
+}
+
+// splitState is the state of the splits of the partition kept in the snapshot, consistent with the
+// trees of its apply id.
+type splitState struct {
+	End       uint64              `json:"end"`
+	Moves     []movedRange        `json:"moves,omitempty"`
+	Migration *partitionMigration `json:"migration,omitempty"`
+}
+
+func (s *splitState) Marshal() (buf []byte, crc uint32, err error) {
+	if buf, err = json.Marshal(s); err != nil {
+		return
+	}
+	crc = crc32.ChecksumIEEE(buf)
+	return
+}
+
+func (s *splitState) UnMarshal(data []byte) error {
+	return json.Unmarshal(data, s)
+}
+
+// split reports whether the partition is split or splitting, only then the state is sent in the raft snapshot.
+func (s *splitState) split() bool {
+	return len(s.Moves) != 0 || s.Migration != nil
+}
+
+func (mp *metaPartition) splitState() *splitState {
+	mp.migration.RLock()
+	defer mp.migration.RUnlock()
+	s := &splitState{End: mp.config.End, Moves: append([]movedRange(nil), mp.config.Moves...)}
+	if m := mp.config.Migration; m != nil {
+		s.Migration = m.record()
+	}
+	return s
+}
+
+// restoreSplitState replaces the state of the splits with the one of the snapshot, the state is nil if the
+// partition is not split as of the snapshot.
+func (mp *metaPartition) restoreSplitState(s *splitState) {
+	mp.stopMigration()
+	mp.migration.Lock()
+	if s == nil {
+		mp.config.Moves, mp.config.Migration = nil, nil
+	} else {
+		mp.config.End, mp.config.Moves, mp.config.Migration = s.End, s.Moves, s.Migration
+	}
+	mp.migration.Unlock()
+}
+
+// splitExtentRefs returns the references of the shared extents kept by the inodes before the start, and
+// the ones moved with the inodes of the range. The extent shared by both sides is pinned by a reference
+// more on each side, so that it's not deleted from the data nodes by either of them.
+func (mp *metaPartition) splitExtentRefs(start uint64) (kept, moved map[extentRef]uint32) {
+	kept, moved = make(map[extentRef]uint32), make(map[extentRef]uint32)
+	if mp.extentRefs.len() == 0 {
+		return
+	}
+	mp.inodeTree.AscendGreaterOrEqual(NewInode(start, 0), func(item BtreeItem) bool {
+		_, shared := mp.extentRefs.split(item.(*Inode).Extents.CopyExtents())
+		held := make(map[extentRef]struct{}, len(shared))
+		for _, ref := range shared {
+			if _, ok := held[ref]; ok {
+				continue
+			}
+			held[ref] = struct{}{}
+			moved[ref]++
+		}
+		return true
+	})
+	for ref, count := range moved {
+		if total := mp.extentRefs.count(ref); total > count {
+			kept[ref] = total - count + 1
+			moved[ref] = count + 1
+		}
+	}
+	return
+}
+
+// migrateRecord is an item sent to the target partition, see snapshotStore.writeDelta.
+type migrateRecord struct {
+	op   byte
+	tree int
+	item BtreeItem
+	refs map[extentRef]uint32
+}
+
+func decodeMigrateRecords(data []byte) (records []migrateRecord, err error) {
+	for len(data) > 0 {
+		if len(data) < deltaRecordHeaderSize {
+			return nil, fmt.Errorf("truncated record header")
+		}
+		op, tree, size := data[0], data[1], int(binary.BigEndian.Uint32(data[2:]))
+		data = data[deltaRecordHeaderSize:]
+		if len(data) < size {
+			return nil, fmt.Errorf("truncated record")
+		}
+		raw := data[:size]
+		data = data[size:]
+		if op == migrateOpReset {
+			records = append(records, migrateRecord{op: op})
+			continue
+		}
+		if op == migrateOpExtentRefs {
+			table := newExtentRefTable()
+			if err = table.UnMarshal(raw); err != nil {
+				return nil, err
+			}
+			records = append(records, migrateRecord{op: op, refs: table.refs})
+			continue
+		}
+		i := int(tree) - 1
+		if i < 0 || i >= len(migrateCodecs) || migrateCodecs[i].tree != tree {
+			return nil, fmt.Errorf("unknown tree %v", tree)
+		}
+		if op != deltaOpPut && op != deltaOpDelete {
+			return nil, fmt.Errorf("unknown op %v", op)
+		}
+		var item BtreeItem
+		if item, err = migrateCodecs[i].unmarshal(raw); err != nil {
+			return nil, err
+		}
+		records = append(records, migrateRecord{op: op, tree: i, item: item})
+	}
+	return
+}
+
+func (mp *metaPartition) checkMigrateRecords(records []migrateRecord) (err error) {
+	for _, r := range records {
+		if r.op == migrateOpReset || r.op == migrateOpExtentRefs {
+			continue
+		}
+		if ino := migratedInode(r.item); ino < mp.config.Start || ino > mp.config.End {
+			return fmt.Errorf("inode %v out of range [%v, %v]", ino, mp.config.Start, mp.config.End)
+		}
+	}
+	return
+}
+
+// fsmMigrateItems applies the items sent by the partition the range is split off.
+func (mp *metaPartition) fsmMigrateItems(data []byte) (status uint8) {
+	records, err := decodeMigrateRecords(data)
+	if err == nil {
+		err = mp.checkMigrateRecords(records)
+	}
+	if err != nil {
+		log.LogErrorf("fsmMigrateItems: partitionID(%v) err(%v)", mp.config.PartitionId, err)
+		return proto.OpArgMismatchErr
+	}
+	trees := mp.migrateTrees()
+	for _, r := range records {
+		if r.op == migrateOpReset {
+			mp.inodeTree.Ascend(func(item BtreeItem) bool {
+				mp.freeList.Remove(item.(*Inode).Inode)
+				return true
+			})
+			for _, tree := range trees {
+				tree.Reset()
+			}
+			mp.extentRefs.replace(nil)
+			continue
+		}
+		if r.op == migrateOpExtentRefs {
+			mp.extentRefs.replace(r.refs)
+			continue
+		}
+		ino, isInode := r.item.(*Inode)
+		if r.op == deltaOpDelete {
+			trees[r.tree].Delete(r.item)
+			if isInode {
+				mp.freeList.Remove(ino.Inode)
+			}
+			continue
+		}
+		trees[r.tree].ReplaceOrInsert(r.item, true)
+		if isInode {
+			if mp.config.Cursor < ino.Inode {
+				mp.config.Cursor = ino.Inode
+			}
+			mp.checkAndInsertFreeList(ino)
+		}
+	}
+	return proto.OpOk
+}
+
+// MigrateItems applies the items sent by the partition the range is split off.
+func (mp *metaPartition) MigrateItems(p *Packet) (err error) {
+	records, err := decodeMigrateRecords(p.Data)
+	if err == nil {
+		err = mp.checkMigrateRecords(records)
+	}
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	resp, err := mp.submit(opFSMMigrateItems, p.Data)
+	if err != nil {
+		p.PacketErrorWithBody(proto.OpAgain, []byte(err.Error()))
+		return
+	}
+	p.PacketErrorWithBody(resp.(uint8), nil)
+	return
+}
+
+// ServeMoved answers the request of an inode of the range frozen or moved by a split, it
+// returns false if the request is served by the partition.
+func (mp *metaPartition) ServeMoved(p *Packet) bool {
+	mp.migration.RLock()
+	m, moves := mp.config.Migration, mp.config.Moves
+	mp.migration.RUnlock()
+	frozen := m != nil && m.frozen()
+	if !frozen && len(moves) == 0 {
+		return false
+	}
+	inodes := requestInodes(p.Data)
+	if frozen {
+		for _, ino := range inodes {
+			if ino < m.Start {
+				continue
+			}
+			if mp.abortExpiredMigration(m) {
+				break
+			}
+			p.PacketErrorWithBody(proto.OpAgain, []byte(fmt.Sprintf("inode(%v) is migrating", ino)))
+			return true
+		}
+	}
+	var target uint64
+	for i, ino := range inodes {
+		partitionID := lookupMovedRange(moves, ino)
+		if partitionID == 0 || (i > 0 && partitionID != target) {
+			return false
+		}
+		target = partitionID
+	}
+	if target == 0 {
+		return false
+	}
+	body, err := json.Marshal(&proto.MetaPartitionMovedResponse{PartitionID: target})
+	if err != nil {
+		return false
+	}
+	p.PacketErrorWithBody(proto.OpMetaPartitionMovedErr, body)
+	return true
+}
+
+// abortExpiredMigration aborts the migration frozen past the deadline by the leader, it returns
+// true if the migration is aborted.
+func (mp *metaPartition) abortExpiredMigration(m *partitionMigration) bool {
+	now := time.Now().UnixNano()
+	if !m.expired(now) {
+		return false
+	}
+	if _, leader := mp.IsLeader(); !leader {
+		return false
+	}
+	log.LogWarnf("abortExpiredMigration: partitionID(%v) start(%v) target(%v)", mp.config.PartitionId, m.Start, m.TargetID)
+	req := &fsmMigrateRequest{TargetID: m.TargetID, Start: m.Start, Time: now}
+	if err := mp.submitMigrate(opFSMMigrateAbort, req); err != nil {
+		log.LogErrorf("abortExpiredMigration: partitionID(%v) err(%v)", mp.config.PartitionId, err)
+		return false
+	}
+	return true
+}
+
+// requestInodes returns the inodes which decide the partition of the request, nil if unknown.
+func requestInodes(data []byte) []uint64 {
+	req := &struct {
+		ParentID *uint64  `json:"pino"`
+		Inode    *uint64  `json:"ino"`
+		Inodes   []uint64 `json:"inos"`
+	}{}
+	if len(data) == 0 || data[0] != '{' || json.Unmarshal(data, req) != nil {
+		return nil
+	}
+	switch {
+	case req.ParentID != nil:
+		return []uint64{*req.ParentID}
+	case req.Inode != nil:
+		return []uint64{*req.Inode}
+	}
+	return req.Inodes
+}
+
+// checkMigrateRange returns an error if the range holds the state kept by the partition
+// outside the trees migrated.
+func (mp *metaPartition) checkMigrateRange(start uint64) (err error) {
+	if mp.versions.len() > 0 {
+		return errors.New("the snapshot versions are not migrated")
+	}
+	for _, entry := range mp.trash.list() {
+		if entry.Inode >= start {
+			return errors.NewErrorf("inode(%v) is in the trash", entry.Inode)
+		}
+	}
+	for ino := range mp.orphans.clone().inodes {
+		if ino >= start {
+			return errors.NewErrorf("inode(%v) is an orphan", ino)
+		}
+	}
+	for ino := range mp.fileLocks.clone().locks {
+		if ino >= start {
+			return errors.NewErrorf("inode(%v) is locked", ino)
+		}
+	}
+	return
+}
+
+func (mp *metaPartition) submitMigrateStatus(op uint32, req *fsmMigrateRequest) (status uint8, err error) {
+	val, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	resp, err := mp.submit(op, val)
+	if err != nil {
+		return
+	}
+	return resp.(uint8), nil
+}
+
+func (mp *metaPartition) submitMigrate(op uint32, req *fsmMigrateRequest) (err error) {
+	status, err := mp.submitMigrateStatus(op, req)
+	if err != nil {
+		return
+	}
+	if status != proto.OpOk {
+		p := &Packet{}
+		p.ResultCode = status
+		err = errors.NewErrorf("[submitMigrate] op(%v): %s", op, p.GetResultMsg())
+	}
+	return
+}
+
+// movedTo returns the partition the range of the start is moved to, 0 if it's not moved.
+func (mp *metaPartition) movedTo(start uint64) uint64 {
+	mp.migration.RLock()
+	defer mp.migration.RUnlock()
+	for _, r := range mp.config.Moves {
+		if r.Start == start {
+			return r.PartitionID
+		}
+	}
+	return 0
+}
+
+// MigratePartition moves the range of the request to the target partition, it returns once
+// the split is committed, or the migration is aborted.
+func (mp *metaPartition) MigratePartition(req *proto.MigrateMetaPartitionRequest) (err error) {
+	return mp.migrate(req, mp.newMigrateSender(req))
+}
+
+func (mp *metaPartition) migrate(req *proto.MigrateMetaPartitionRequest, send func(data []byte) error) (err error) {
+	if mp.movedTo(req.Start) == req.TargetID {
+		return
+	}
+	if !mp.migration.acquire() {
+		return errors.NewErrorf("[migrate] partition(%v) is migrating", mp.config.PartitionId)
+	}
+	defer mp.migration.release()
+
+	fsmReq := &fsmMigrateRequest{TargetID: req.TargetID, Start: req.Start}
+	if err = mp.submitMigrate(opFSMMigrateStart, fsmReq); err != nil {
+		return
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		log.LogErrorf("migrate: partitionID(%v) start(%v) target(%v) err(%v)",
+			mp.config.PartitionId, req.Start, req.TargetID, err)
+		if abortErr := mp.submitMigrate(opFSMMigrateAbort, fsmReq); abortErr != nil {
+			log.LogErrorf("migrate: partitionID(%v) abort err(%v)", mp.config.PartitionId, abortErr)
+		}
+	}()
+	m := mp.getMigration()
+	if m == nil || m.Start != req.Start || m.TargetID != req.TargetID || !mp.trackMigration(m) {
+		return errors.NewErrorf("[migrate] partition(%v) migration is replaced", mp.config.PartitionId)
+	}
+
+	w := &migrateWriter{send: send}
+	w.reset()
+	if mp.kv == nil {
+		if err = w.writeViews(m.views, migrateKeys(req.Start)); err != nil {
+			return
+		}
+		for round := 0; round < migrateMaxRounds; round++ {
+			var changes int
+			if changes, err = mp.writeMigrateChanges(w, mp.getApplyID(), nil); err != nil {
+				return
+			}
+			log.LogInfof("migrate: partitionID(%v) round(%v) changes(%v)", mp.config.PartitionId, round, changes)
+			if changes < migrateFreezeChanges {
+				break
+			}
+		}
+	}
+
+	fsmReq.Time = time.Now().UnixNano()
+	fsmReq.Deadline = fsmReq.Time + int64(migrateFreezeTimeout)
+	if err = mp.submitMigrate(opFSMMigrateFreeze, fsmReq); err != nil {
+		return
+	}
+	if err = mp.checkMigrateRange(req.Start); err != nil {
+		return
+	}
+	for i := 0; ; i++ {
+		if fsmReq.Digest, err = mp.writeMigrateCut(w, req.Start); err != nil {
+			return
+		}
+		fsmReq.Time = time.Now().UnixNano()
+		var status uint8
+		if status, err = mp.submitMigrateStatus(opFSMMigrateCommit, fsmReq); err != nil {
+			return
+		}
+		if status == proto.OpOk {
+			break
+		}
+		if status != proto.OpAgain || i >= migrateCommitRetries {
+			p := &Packet{}
+			p.ResultCode = status
+			return errors.NewErrorf("[migrate] partition(%v) commit: %s", mp.config.PartitionId, p.GetResultMsg())
+		}
+	}
+	log.LogWarnf("migrate: partitionID(%v) start(%v) target(%v) items(%v) complete",
+		mp.config.PartitionId, req.Start, req.TargetID, w.items)
+	return
+}
+
+// trackMigration takes the views of the trees and starts tracking the keys changed since, at the same
+// index of the raft log. It returns false if the migration is replaced in the meantime.
+func (mp *metaPartition) trackMigration(m *partitionMigration) bool {
+	mp.nonIdempotent.Lock()
+	defer mp.nonIdempotent.Unlock()
+	if mp.getMigration() != m {
+		return false
+	}
+	if mp.kv != nil {
+		// the partitions kept in the kv store are copied once frozen
+		return true
+	}
+	keys := migrateKeys(m.Start)
+	for i, tree := range mp.migrateTrees() {
+		m.views = append(m.views, tree.GetTree())
+		tree.trackMigration(keys[i])
+	}
+	return true
+}
+
+// writeMigrateCut sends the items of the range as of the views of the trees taken at an index, and
+// returns their digest. The partitions kept in the kv store are sent in full, the others the changes.
+func (mp *metaPartition) writeMigrateCut(w *migrateWriter, start uint64) (digest uint32, err error) {
+	mp.nonIdempotent.Lock()
+	index := mp.getApplyID()
+	views := make([]*BTree, 0, len(migrateCodecs))
+	for _, tree := range mp.migrateTrees() {
+		views = append(views, tree.GetTree())
+	}
+	mp.nonIdempotent.Unlock()
+	defer func() {
+		for _, view := range views {
+			view.Release()
+		}
+	}()
+
+	if mp.kv != nil {
+		w.reset()
+		err = w.writeViews(views, migrateKeys(start))
+	} else {
+		_, err = mp.writeMigrateChanges(w, index, views)
+	}
+	if err != nil {
+		return
+	}
+	_, moved := mp.splitExtentRefs(start)
+	if err = w.writeExtentRefs(moved); err != nil {
+		return
+	}
+	if err = w.flush(); err != nil {
+		return
+	}
+	return migrateDigest(views, start)
+}
+
+// writeMigrateChanges writes the items changed since the last round, read from the views if given, the
+// changes up to the index are dropped after, so that they're not sent again.
+func (mp *metaPartition) writeMigrateChanges(w *migrateWriter, index uint64, views []*BTree) (count int, err error) {
+	for i, tree := range mp.migrateTrees() {
+		changes := tree.cloneMigration()
+		if changes == nil {
+			continue
+		}
+		source := tree
+		if views != nil {
+			source = views[i]
+		}
+		changes.Ascend(func(e btree.Item) bool {
+			key := e.(*changeEntry).key
+			if item := source.Get(key); item != nil {
+				err = w.write(deltaOpPut, i, item)
+			} else {
+				err = w.write(deltaOpDelete, i, key)
+			}
+			count++
+			return err == nil
+		})
+		if err != nil {
+			return
+		}
+		tree.trimMigration(changes, index)
+	}
+	return
+}
+
+// migrateWriter batches the records sent to the target partition.
+type migrateWriter struct {
+	send  func(data []byte) error
+	buf   []byte
+	items int
+}
+
+// reset drops the items left in the target partition by an earlier migration.
+func (w *migrateWriter) reset() {
+	w.buf = append(w.buf, migrateOpReset, 0, 0, 0, 0, 0)
+}
+
+func (w *migrateWriter) write(op byte, tree int, item BtreeItem) (err error) {
+	codec := migrateCodecs[tree]
+	raw, err := codec.marshal(item)
+	if err != nil {
+		return
+	}
+	header := make([]byte, deltaRecordHeaderSize)
+	header[0], header[1] = op, codec.tree
+	binary.BigEndian.PutUint32(header[2:], uint32(len(raw)))
+	w.buf = append(w.buf, header...)
+	w.buf = append(w.buf, raw...)
+	w.items++
+	if len(w.buf) >= migrateBatchSize {
+		return w.flush()
+	}
+	return
+}
+
+// writeExtentRefs writes the references of the extents shared by the inodes of the range.
+func (w *migrateWriter) writeExtentRefs(refs map[extentRef]uint32) (err error) {
+	table := &extentRefTable{refs: refs}
+	raw, _, err := table.Marshal()
+	if err != nil {
+		return
+	}
+	header := make([]byte, deltaRecordHeaderSize)
+	header[0] = migrateOpExtentRefs
+	binary.BigEndian.PutUint32(header[2:], uint32(len(raw)))
+	w.buf = append(w.buf, header...)
+	w.buf = append(w.buf, raw...)
+	return
+}
+
+func (w *migrateWriter) writeViews(views []*BTree, keys []BtreeItem) (err error) {
+	for i, view := range views {
+		view.AscendGreaterOrEqual(keys[i], func(item BtreeItem) bool {
+			err = w.write(deltaOpPut, i, item)
+			return err == nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (w *migrateWriter) flush() (err error) {
+	if len(w.buf) == 0 {
+		return
+	}
+	if err = w.send(w.buf); err != nil {
+		return
+	}
+	w.buf = nil
+	return
+}
+
+// newMigrateSender returns the func sending the items to the target partition through any of its replicas.
+func (mp *metaPartition) newMigrateSender(req *proto.MigrateMetaPartitionRequest) func(data []byte) error {
+	return func(data []byte) (err error) {
+		for i := 0; i < migrateSendRetries; i++ {
+			for _, addr := range req.TargetHosts {
+				if err = mp.sendMigrateItems(addr, req.TargetID, data); err == nil {
+					return
+				}
+				log.LogWarnf("sendMigrateItems: partitionID(%v) target(%v) addr(%v) err(%v)",
+					mp.config.PartitionId, req.TargetID, addr, err)
+			}
+			time.Sleep(time.Second)
+		}
+		return
+	}
+}
+
+func (mp *metaPartition) sendMigrateItems(addr string, targetID uint64, data []byte) (err error) {
+	p := proto.NewPacketReqID()
+	p.Opcode = proto.OpMetaMigrateItems
+	p.PartitionID = targetID
+	p.Data = data
+	p.Size = uint32(len(p.Data))
+	reqID, reqOp := p.ReqID, p.Opcode
+
+	connPool := mp.manager.connPool
+	conn, err := connPool.GetConnect(addr)
+	if err != nil {
+		return
+	}
+	defer func() {
+		connPool.PutConnect(conn, err != nil)
+	}()
+	if err = p.WriteToConn(conn); err != nil {
+		return
+	}
+	if err = p.ReadFromConn(conn, proto.ReadDeadlineTime); err != nil {
+		return
+	}
+	if reqID != p.ReqID || reqOp != p.Opcode {
+		return fmt.Errorf("send and received packet mismatch: req(%v_%v) resp(%v_%v)", reqID, reqOp, p.ReqID, p.Opcode)
+	}
+	if p.ResultCode != proto.OpOk {
+		return fmt.Errorf("result(%v) %s", p.GetResultMsg(), string(p.Data))
+	}
+	return
+}
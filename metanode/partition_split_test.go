@@ -0,0 +1,312 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	raftstoremock "github.com/cubefs/cubefs/metanode/mocktest/raftstore"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func newMigrateTestPartition(t *testing.T, ctrl *gomock.Controller, rootDir string, id, start, end uint64) *metaPartition {
+	require.NoError(t, os.MkdirAll(rootDir, 0755))
+	metaM := &metadataManager{
+		nodeId:     1,
+		partitions: make(map[uint64]MetaPartition),
+		metaNode:   &MetaNode{},
+	}
+	conf := &MetaPartitionConfig{
+		PartitionId: id,
+		VolName:     "test_vol",
+		Start:       start,
+		End:         end,
+		Cursor:      start,
+		NodeId:      1,
+		RootDir:     rootDir,
+		Peers:       []proto.Peer{{ID: 1, Addr: "127.0.0.1:17210"}},
+	}
+	mp := NewMetaPartition(conf, metaM).(*metaPartition)
+	raft := raftstoremock.NewMockPartition(ctrl)
+	index := uint64(0)
+	raft.EXPECT().Submit(gomock.Any()).DoAndReturn(func(cmd []byte) (resp interface{}, err error) {
+		index++
+		return mp.Apply(cmd, index)
+	}).AnyTimes()
+	raft.EXPECT().LeaderTerm().Return(uint64(1), uint64(1)).AnyTimes()
+	mp.raftPartition = raft
+	return mp
+}
+
+func submitTestDentry(t *testing.T, mp *metaPartition, op uint32, dentry *Dentry) {
+	val, err := dentry.Marshal()
+	require.NoError(t, err)
+	_, err = mp.submit(op, val)
+	require.NoError(t, err)
+}
+
+func TestMetaPartition_Migrate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testMetaPartitionMigrate/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+
+	source := newMigrateTestPartition(t, ctrl, rootDir+"source", 1, 1, 1000)
+	target := newMigrateTestPartition(t, ctrl, rootDir+"target", 2, 500, 1000)
+	for _, ino := range []uint64{1, 500} {
+		source.inodeTree.ReplaceOrInsert(NewInode(ino, uint32(os.ModeDir)), true)
+	}
+	for ino := uint64(501); ino <= 520; ino++ {
+		source.inodeTree.ReplaceOrInsert(NewInode(ino, 0), true)
+		source.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 500, Name: fmt.Sprintf("f%v", ino), Inode: ino}, true)
+	}
+	source.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 1, Name: "dir", Inode: 500, Type: uint32(os.ModeDir)}, true)
+	extend := NewExtend(510)
+	extend.Put([]byte("user.k"), []byte("v"))
+	source.extendTree.ReplaceOrInsert(extend, true)
+	source.config.Cursor = 520
+
+	first := "f501"
+	req := &proto.MigrateMetaPartitionRequest{PartitionID: 1, Start: 500, TargetID: 2}
+	var batches int
+	send := func(data []byte) error {
+		if batches++; batches == 1 {
+			// the range is changed while the items of the views are sent
+			submitTestDentry(t, source, opFSMCreateDentry, &Dentry{ParentId: 500, Name: "new", Inode: 521})
+			submitTestDentry(t, source, opFSMDeleteDentry, &Dentry{ParentId: 500, Name: first})
+		}
+		p := &Packet{}
+		p.Data = data
+		require.NoError(t, target.MigrateItems(p))
+		require.Equal(t, proto.OpOk, p.ResultCode, p.GetResultMsg())
+		return nil
+	}
+	require.NoError(t, source.migrate(req, send))
+
+	require.Equal(t, uint64(499), source.config.End)
+	require.Equal(t, []movedRange{{Start: 500, PartitionID: 2}}, source.config.Moves)
+	require.Equal(t, 1, source.inodeTree.Len())
+	require.Equal(t, 1, source.dentryTree.Len())
+	require.Equal(t, 0, source.extendTree.Len())
+	require.Nil(t, source.getMigration())
+
+	require.Equal(t, 21, target.inodeTree.Len())
+	require.Equal(t, 20, target.dentryTree.Len())
+	require.NotNil(t, target.dentryTree.Get(&Dentry{ParentId: 500, Name: "new"}))
+	require.Nil(t, target.dentryTree.Get(&Dentry{ParentId: 500, Name: first}))
+	value, ok := target.extendTree.Get(NewExtend(510)).(*Extend).Get([]byte("user.k"))
+	require.True(t, ok)
+	require.Equal(t, []byte("v"), value)
+	require.Equal(t, uint64(520), target.config.Cursor)
+
+	// the task sent again is done
+	require.NoError(t, source.migrate(req, func(data []byte) error {
+		t.Fatal("unexpected items sent")
+		return nil
+	}))
+
+	// the requests of the range are re-routed
+	p := &Packet{}
+	p.Data = []byte(`{"vol":"test_vol","pid":1,"pino":500,"name":"new"}`)
+	require.True(t, source.ServeMoved(p))
+	require.Equal(t, proto.OpMetaPartitionMovedErr, p.ResultCode)
+	moved := &proto.MetaPartitionMovedResponse{}
+	require.NoError(t, json.Unmarshal(p.Data, moved))
+	require.Equal(t, uint64(2), moved.PartitionID)
+	p = &Packet{}
+	p.Data = []byte(`{"vol":"test_vol","pid":1,"ino":1}`)
+	require.False(t, source.ServeMoved(p))
+
+	// the requests of the range frozen are retried
+	source.setMigration(&partitionMigration{Start: 300, Deadline: time.Now().Add(time.Minute).UnixNano()})
+	p = &Packet{}
+	p.Data = []byte(`{"vol":"test_vol","pid":1,"inos":[1,300]}`)
+	require.True(t, source.ServeMoved(p))
+	require.Equal(t, proto.OpAgain, p.ResultCode)
+
+	// the freeze expired is aborted by the leader
+	source.setMigration(&partitionMigration{Start: 300, Deadline: time.Now().Add(-time.Second).UnixNano()})
+	p = &Packet{}
+	p.Data = []byte(`{"vol":"test_vol","pid":1,"inos":[1,300]}`)
+	require.False(t, source.ServeMoved(p))
+	require.Nil(t, source.getMigration())
+}
+
+func TestMetaPartition_MigrateCommit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testMetaPartitionMigrateCommit/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+
+	mp := newMigrateTestPartition(t, ctrl, rootDir, 1, 1, 1000)
+	mp.inodeTree.ReplaceOrInsert(NewInode(500, uint32(os.ModeDir)), true)
+	mp.inodeTree.ReplaceOrInsert(NewInode(501, 0), true)
+
+	req := &fsmMigrateRequest{TargetID: 2, Start: 500}
+	// the range is not frozen
+	status, err := mp.submitMigrateStatus(opFSMMigrateStart, req)
+	require.NoError(t, err)
+	require.Equal(t, proto.OpOk, status)
+	req.Time = time.Now().UnixNano()
+	status, err = mp.submitMigrateStatus(opFSMMigrateCommit, req)
+	require.NoError(t, err)
+	require.Equal(t, proto.OpNotExistErr, status)
+
+	req.Deadline = req.Time + int64(time.Minute)
+	status, err = mp.submitMigrateStatus(opFSMMigrateFreeze, req)
+	require.NoError(t, err)
+	require.Equal(t, proto.OpOk, status)
+	req.Digest, err = migrateDigest(mp.migrateTrees(), req.Start)
+	require.NoError(t, err)
+
+	// the range changed after the items sent by an entry logged before the freeze
+	submitTestDentry(t, mp, opFSMCreateDentry, &Dentry{ParentId: 500, Name: "f", Inode: 501})
+	status, err = mp.submitMigrateStatus(opFSMMigrateCommit, req)
+	require.NoError(t, err)
+	require.Equal(t, proto.OpAgain, status)
+	require.NotNil(t, mp.getMigration())
+
+	// the freeze expired
+	req.Digest, err = migrateDigest(mp.migrateTrees(), req.Start)
+	require.NoError(t, err)
+	req.Time = req.Deadline
+	status, err = mp.submitMigrateStatus(opFSMMigrateCommit, req)
+	require.NoError(t, err)
+	require.Equal(t, proto.OpNotExistErr, status)
+	require.Equal(t, uint64(1000), mp.config.End)
+
+	req.Time = time.Now().UnixNano()
+	status, err = mp.submitMigrateStatus(opFSMMigrateCommit, req)
+	require.NoError(t, err)
+	require.Equal(t, proto.OpOk, status)
+	require.Equal(t, uint64(499), mp.config.End)
+	require.Equal(t, 0, mp.inodeTree.Len())
+}
+
+func TestMetaPartition_MigrateRestart(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testMetaPartitionMigrateRestart/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+
+	mp := newMigrateTestPartition(t, ctrl, rootDir, 1, 1, 1000)
+	mp.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+	mp.mqMgr = NewQuotaManager(mp.config.VolName, mp.config.PartitionId)
+	mp.inodeTree.ReplaceOrInsert(NewInode(500, uint32(os.ModeDir)), true)
+	extend := NewExtend(500)
+	for _, key := range []string{"user.a", "user.b", "user.c", "user.d"} {
+		extend.Put([]byte(key), []byte("v"))
+	}
+	mp.extendTree.ReplaceOrInsert(extend, true)
+
+	req := &fsmMigrateRequest{TargetID: 2, Start: 500, Time: time.Now().UnixNano()}
+	req.Deadline = req.Time + int64(time.Minute)
+	for _, op := range []uint32{opFSMMigrateStart, opFSMMigrateFreeze} {
+		status, err := mp.submitMigrateStatus(op, req)
+		require.NoError(t, err)
+		require.Equal(t, proto.OpOk, status)
+	}
+	digest, err := migrateDigest(mp.migrateTrees(), req.Start)
+	require.NoError(t, err)
+	req.Digest = digest
+
+	// the replica restarted between the freeze and the commit decides the commit as the others
+	require.NoError(t, mp.store(newTestStoreMsg(mp, mp.getApplyID())))
+	loaded := newMigrateTestPartition(t, ctrl, rootDir, 1, 1, 1000)
+	loaded.uidManager = NewUidMgr(mp.config.VolName, mp.config.PartitionId)
+	loaded.mqMgr = NewQuotaManager(mp.config.VolName, mp.config.PartitionId)
+	require.NoError(t, loaded.LoadSnapshot(path.Join(rootDir, snapshotDir)))
+	m := loaded.getMigration()
+	require.NotNil(t, m)
+	require.Equal(t, &partitionMigration{Deadline: req.Deadline, TargetID: 2, Start: 500}, m)
+	// the extended attributes are encoded alike, whatever the order of the keys in the map
+	digest, err = migrateDigest(loaded.migrateTrees(), req.Start)
+	require.NoError(t, err)
+	require.Equal(t, req.Digest, digest)
+	p := &Packet{}
+	p.Data = []byte(`{"vol":"test_vol","pid":1,"ino":500}`)
+	require.True(t, loaded.ServeMoved(p))
+	require.Equal(t, proto.OpAgain, p.ResultCode)
+
+	for _, replica := range []*metaPartition{mp, loaded} {
+		status, err := replica.submitMigrateStatus(opFSMMigrateCommit, req)
+		require.NoError(t, err)
+		require.Equal(t, proto.OpOk, status)
+		require.Equal(t, uint64(499), replica.config.End)
+		require.Equal(t, []movedRange{{Start: 500, PartitionID: 2}}, replica.config.Moves)
+		require.Equal(t, 0, replica.inodeTree.Len())
+		require.Nil(t, replica.getMigration())
+	}
+
+	// the split is kept across the restart, whatever the metadata persisted
+	require.NoError(t, mp.store(newTestStoreMsg(mp, mp.getApplyID())))
+	loaded = newMigrateTestPartition(t, ctrl, rootDir, 1, 1, 1000)
+	require.NoError(t, loaded.LoadSnapshot(path.Join(rootDir, snapshotDir)))
+	require.Equal(t, uint64(499), loaded.config.End)
+	require.Equal(t, []movedRange{{Start: 500, PartitionID: 2}}, loaded.config.Moves)
+	require.Nil(t, loaded.getMigration())
+}
+
+func TestMetaPartition_MigrateExtentRefs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	rootDir := "/tmp/testMetaPartitionMigrateExtentRefs/"
+	os.RemoveAll(rootDir)
+	defer os.RemoveAll(rootDir)
+
+	source := newMigrateTestPartition(t, ctrl, rootDir+"source", 1, 1, 1000)
+	target := newMigrateTestPartition(t, ctrl, rootDir+"target", 2, 500, 1000)
+	kept := extentRef{PartitionId: 1, ExtentId: 1025}
+	moved := extentRef{PartitionId: 1, ExtentId: 1026}
+	for _, ino := range []uint64{10, 600, 601} {
+		inode := NewInode(ino, 0)
+		inode.Extents.Append(proto.ExtentKey{PartitionId: 1, ExtentId: 1025, Size: 100})
+		if ino >= 500 {
+			inode.Extents.Append(proto.ExtentKey{FileOffset: 100, PartitionId: 1, ExtentId: 1026, Size: 100})
+		}
+		source.inodeTree.ReplaceOrInsert(inode, true)
+	}
+	source.extentRefs.set(kept, 3)
+	source.extentRefs.set(moved, 2)
+
+	req := &proto.MigrateMetaPartitionRequest{PartitionID: 1, Start: 500, TargetID: 2}
+	require.NoError(t, source.migrate(req, func(data []byte) error {
+		p := &Packet{}
+		p.Data = data
+		require.NoError(t, target.MigrateItems(p))
+		require.Equal(t, proto.OpOk, p.ResultCode, p.GetResultMsg())
+		return nil
+	}))
+
+	// the extent shared by both sides is pinned on each of them
+	require.Equal(t, uint32(2), source.extentRefs.count(kept))
+	require.Equal(t, uint32(0), source.extentRefs.count(moved))
+	require.Equal(t, uint32(3), target.extentRefs.count(kept))
+	require.Equal(t, uint32(2), target.extentRefs.count(moved))
+
+	// the data is not deleted once the last inode of the source drops it
+	require.True(t, source.extentRefs.put(kept))
+	require.Equal(t, uint32(0), source.extentRefs.count(kept))
+}
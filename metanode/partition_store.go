@@ -52,6 +52,16 @@ const (
 	metadataFileTmp = ".meta"
 	uniqIDFile      = "uniqID"
 	uniqCheckerFile = "uniqChecker"
+	fileLocksFile   = "fileLocks"
+	extentRefsFile  = "extentRefs"
+	trashFile       = "trash"
+	orphansFile     = "orphans"
+	versionsFile    = "versions"
+	changeFeedFile  = "changeFeed"
+	splitStateFile  = "splitState"
+	manifestFile    = "manifest"
+	deltaFilePrefix = "delta."
+	snapshotRecvDir = ".snapshot_recv"
 )
 
 func (mp *metaPartition) loadMetadata() (err error) {
@@ -83,6 +93,9 @@ func (mp *metaPartition) loadMetadata() (err error) {
 	mp.config.Start = mConf.Start
 	mp.config.End = mConf.End
 	mp.config.Peers = mConf.Peers
+	mp.config.StoreMode = mConf.StoreMode
+	mp.config.Moves = mConf.Moves
+	mp.config.Migration = mConf.Migration
 	mp.config.Cursor = mp.config.Start
 	mp.config.UniqId = 0
 
@@ -127,6 +140,11 @@ func (mp *metaPartition) loadInode(rootDir string, crc uint32) (err error) {
 					log.LogErrorf("[loadInode]: check crc mismatch, expected[%d], actual[%d]", crc, res)
 					return ErrSnapshotCrcMismatch
 				}
+				err = mp.snapshotOverlay.load(0, func(item BtreeItem) error {
+					mp.loadInodeItem(item.(*Inode))
+					numInodes += 1
+					return nil
+				})
 				return
 			}
 			err = errors.NewErrorf("[loadInode] ReadHeader: %s", err.Error())
@@ -155,24 +173,30 @@ func (mp *metaPartition) loadInode(rootDir string, crc uint32) (err error) {
 			err = errors.NewErrorf("[loadInode] Unmarshal: %s", err.Error())
 			return
 		}
-		mp.acucumUidSizeByLoad(ino)
 		// data crc
 		if _, err = crcCheck.Write(inoBuf); err != nil {
 			return err
 		}
-
-		mp.size += ino.Size
-
-		mp.fsmCreateInode(ino)
-		mp.checkAndInsertFreeList(ino)
-		if mp.config.Cursor < ino.Inode {
-			mp.config.Cursor = ino.Inode
+		if mp.snapshotOverlay.has(0, ino) {
+			// replaced by the deltas
+			continue
 		}
+		mp.loadInodeItem(ino)
 		numInodes += 1
 	}
 
 }
 
+func (mp *metaPartition) loadInodeItem(ino *Inode) {
+	mp.acucumUidSizeByLoad(ino)
+	mp.size += ino.Size
+	mp.fsmCreateInode(ino)
+	mp.checkAndInsertFreeList(ino)
+	if mp.config.Cursor < ino.Inode {
+		mp.config.Cursor = ino.Inode
+	}
+}
+
 // Load dentry from the dentry snapshot.
 func (mp *metaPartition) loadDentry(rootDir string, crc uint32) (err error) {
 	var numDentries uint64
@@ -208,6 +232,13 @@ func (mp *metaPartition) loadDentry(rootDir string, crc uint32) (err error) {
 					log.LogErrorf("[loadDentry]: check crc mismatch, expected[%d], actual[%d]", crc, res)
 					return ErrSnapshotCrcMismatch
 				}
+				err = mp.snapshotOverlay.load(1, func(item BtreeItem) error {
+					if status := mp.fsmCreateDentry(item.(*Dentry), true); status != proto.OpOk {
+						return errors.NewErrorf("[loadDentry] createDentry dentry: %v, resp code: %d", item, status)
+					}
+					numDentries += 1
+					return nil
+				})
 				return
 			}
 			err = errors.NewErrorf("[loadDentry] ReadHeader: %s", err.Error())
@@ -234,13 +265,16 @@ func (mp *metaPartition) loadDentry(rootDir string, crc uint32) (err error) {
 			err = errors.NewErrorf("[loadDentry] Unmarshal: %s", err.Error())
 			return
 		}
+		if _, err = crcCheck.Write(dentryBuf); err != nil {
+			return err
+		}
+		if mp.snapshotOverlay.has(1, dentry) {
+			continue
+		}
 		if status := mp.fsmCreateDentry(dentry, true); status != proto.OpOk {
 			err = errors.NewErrorf("[loadDentry] createDentry dentry: %v, resp code: %d", dentry, status)
 			return
 		}
-		if _, err = crcCheck.Write(dentryBuf); err != nil {
-			return err
-		}
 		numDentries += 1
 	}
 }
@@ -295,14 +329,23 @@ func (mp *metaPartition) loadExtend(rootDir string, crc uint32) (err error) {
 		}
 		log.LogDebugf("loadExtend: new extend from bytes: partitionID（%v) volume(%v) inode(%v)",
 			mp.config.PartitionId, mp.config.VolName, extend.inode)
-		_ = mp.fsmSetXAttr(extend)
-
 		if _, err = crcCheck.Write(mem[offset : offset+int(numBytes)]); err != nil {
 			return
 		}
 		offset += int(numBytes)
+		if mp.snapshotOverlay.has(2, extend) {
+			continue
+		}
+		_ = mp.fsmSetXAttr(extend)
 		mp.statisticExtendByLoad(extend)
 	}
+	if err = mp.snapshotOverlay.load(2, func(item BtreeItem) error {
+		_ = mp.fsmSetXAttr(item.(*Extend))
+		mp.statisticExtendByLoad(item.(*Extend))
+		return nil
+	}); err != nil {
+		return
+	}
 
 	log.LogInfof("loadExtend: load complete: partitionID(%v) volume(%v) numExtends(%v) filename(%v)",
 		mp.config.PartitionId, mp.config.VolName, numExtends, filename)
@@ -357,11 +400,20 @@ func (mp *metaPartition) loadMultipart(rootDir string, crc uint32) (err error) {
 		var multipart *Multipart
 		multipart = MultipartFromBytes(mem[offset : offset+int(numBytes)])
 		log.LogDebugf("loadMultipart: create multipart from bytes: partitionID（%v) multipartID(%v)", mp.config.PartitionId, multipart.id)
-		mp.fsmCreateMultipart(multipart)
 		offset += int(numBytes)
 		if _, err = crcCheck.Write(mem[offset-int(numBytes) : offset]); err != nil {
 			return err
 		}
+		if mp.snapshotOverlay.has(3, multipart) {
+			continue
+		}
+		mp.fsmCreateMultipart(multipart)
+	}
+	if err = mp.snapshotOverlay.load(3, func(item BtreeItem) error {
+		mp.fsmCreateMultipart(item.(*Multipart))
+		return nil
+	}); err != nil {
+		return
 	}
 	log.LogInfof("loadMultipart: load complete: partitionID(%v) numMultiparts(%v) filename(%v)",
 		mp.config.PartitionId, numMultiparts, filename)
@@ -991,6 +1043,22 @@ func (mp *metaPartition) storeInode(rootDir string,
 	return
 }
 
+// storeInodeStats collects the statistics of the inodes without writing them, see storeInode.
+func (mp *metaPartition) storeInodeStats(sm *storeMsg) {
+	size := uint64(0)
+	sm.inodeTree.Ascend(func(i BtreeItem) bool {
+		ino := i.(*Inode)
+		if sm.uidRebuild {
+			mp.acucumUidSizeByStore(ino)
+		}
+		size += ino.Size
+		mp.fileStats(ino)
+		return true
+	})
+	mp.acucumRebuildFin(sm.uidRebuild)
+	mp.size = size
+}
+
 func (mp *metaPartition) storeDentry(rootDir string,
 	sm *storeMsg) (crc uint32, err error) {
 	filename := path.Join(rootDir, dentryFile)
@@ -1109,6 +1177,17 @@ func (mp *metaPartition) storeExtend(rootDir string, sm *storeMsg) (crc uint32,
 	return
 }
 
+// storeExtendStats rebuilds the quota statistics without writing the extends, see storeExtend.
+func (mp *metaPartition) storeExtendStats(sm *storeMsg) {
+	if sm.quotaRebuild {
+		sm.extendTree.Ascend(func(i BtreeItem) bool {
+			mp.statisticExtendByStore(i.(*Extend), sm.inodeTree)
+			return true
+		})
+	}
+	mp.mqMgr.statisticRebuildFin(sm.quotaRebuild)
+}
+
 func (mp *metaPartition) storeMultipart(rootDir string, sm *storeMsg) (crc uint32, err error) {
 	var multipartTree = sm.multipartTree
 	var fp = path.Join(rootDir, multipartFile)
@@ -1193,6 +1272,35 @@ func (mp *metaPartition) storeUniqID(rootDir string, sm *storeMsg) (err error) {
 	return
 }
 
+func (mp *metaPartition) loadFileLocks(rootDir string, crc uint32) (err error) {
+	filename := path.Join(rootDir, fileLocksFile)
+	if _, err = os.Stat(filename); err != nil {
+		log.LogErrorf("loadFileLocks get file %s err(%s)", filename, err)
+		err = nil
+		return
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.LogErrorf("loadFileLocks read file %s err(%s)", filename, err)
+		err = errors.NewErrorf("[loadFileLocks] OpenFile: %v", err.Error())
+		return
+	}
+	if res := crc32.ChecksumIEEE(data); res != crc {
+		log.LogErrorf("[loadFileLocks]: check crc mismatch, expected[%d], actual[%d]", crc, res)
+		return ErrSnapshotCrcMismatch
+	}
+	if err = mp.fileLocks.UnMarshal(data); err != nil {
+		log.LogErrorf("loadFileLocks UnMarshal err(%s)", err)
+		err = errors.NewErrorf("[loadFileLocks] Unmarshal: %v", err.Error())
+		return
+	}
+
+	log.LogInfof("loadFileLocks: load complete: partitionID(%v) volume(%v) locks(%v)",
+		mp.config.PartitionId, mp.config.VolName, mp.fileLocks.len())
+	return
+}
+
 func (mp *metaPartition) storeUniqChecker(rootDir string, sm *storeMsg) (crc uint32, err error) {
 	filename := path.Join(rootDir, uniqCheckerFile)
 	fp, err := os.OpenFile(filename, os.O_RDWR|os.O_TRUNC|os.O_APPEND|os.
@@ -1218,3 +1326,379 @@ func (mp *metaPartition) storeUniqChecker(rootDir string, sm *storeMsg) (crc uin
 		mp.config.UniqId, mp.config.VolName, crc)
 	return
 }
+
+func (mp *metaPartition) storeFileLocks(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	filename := path.Join(rootDir, fileLocksFile)
+	fp, err := os.OpenFile(filename, os.O_RDWR|os.O_TRUNC|os.O_APPEND|os.O_CREATE, 0755)
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = fp.Sync()
+		fp.Close()
+	}()
+
+	var data []byte
+	if data, crc, err = sm.fileLocks.Marshal(); err != nil {
+		return
+	}
+	if _, err = fp.Write(data); err != nil {
+		return
+	}
+
+	log.LogInfof("storeFileLocks: store complete: partitionID(%v) volume(%v) crc(%v)",
+		mp.config.PartitionId, mp.config.VolName, crc)
+	return
+}
+
+func (mp *metaPartition) loadExtentRefs(rootDir string, crc uint32) (err error) {
+	filename := path.Join(rootDir, extentRefsFile)
+	if _, err = os.Stat(filename); err != nil {
+		log.LogErrorf("loadExtentRefs get file %s err(%s)", filename, err)
+		err = nil
+		return
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.LogErrorf("loadExtentRefs read file %s err(%s)", filename, err)
+		err = errors.NewErrorf("[loadExtentRefs] OpenFile: %v", err.Error())
+		return
+	}
+	if res := crc32.ChecksumIEEE(data); res != crc {
+		log.LogErrorf("[loadExtentRefs]: check crc mismatch, expected[%d], actual[%d]", crc, res)
+		return ErrSnapshotCrcMismatch
+	}
+	if err = mp.extentRefs.UnMarshal(data); err != nil {
+		log.LogErrorf("loadExtentRefs UnMarshal err(%s)", err)
+		err = errors.NewErrorf("[loadExtentRefs] Unmarshal: %v", err.Error())
+		return
+	}
+
+	log.LogInfof("loadExtentRefs: load complete: partitionID(%v) volume(%v) refs(%v)",
+		mp.config.PartitionId, mp.config.VolName, mp.extentRefs.len())
+	return
+}
+
+func (mp *metaPartition) storeExtentRefs(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	filename := path.Join(rootDir, extentRefsFile)
+	fp, err := os.OpenFile(filename, os.O_RDWR|os.O_TRUNC|os.O_APPEND|os.O_CREATE, 0755)
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = fp.Sync()
+		fp.Close()
+	}()
+
+	var data []byte
+	if data, crc, err = sm.extentRefs.Marshal(); err != nil {
+		return
+	}
+	if _, err = fp.Write(data); err != nil {
+		return
+	}
+
+	log.LogInfof("storeExtentRefs: store complete: partitionID(%v) volume(%v) crc(%v)",
+		mp.config.PartitionId, mp.config.VolName, crc)
+	return
+}
+
+func (mp *metaPartition) loadTrash(rootDir string, crc uint32) (err error) {
+	filename := path.Join(rootDir, trashFile)
+	if _, err = os.Stat(filename); err != nil {
+		log.LogErrorf("loadTrash get file %s err(%s)", filename, err)
+		err = nil
+		return
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.LogErrorf("loadTrash read file %s err(%s)", filename, err)
+		err = errors.NewErrorf("[loadTrash] OpenFile: %v", err.Error())
+		return
+	}
+	if res := crc32.ChecksumIEEE(data); res != crc {
+		log.LogErrorf("[loadTrash]: check crc mismatch, expected[%d], actual[%d]", crc, res)
+		return ErrSnapshotCrcMismatch
+	}
+	if err = mp.trash.UnMarshal(data); err != nil {
+		log.LogErrorf("loadTrash UnMarshal err(%s)", err)
+		err = errors.NewErrorf("[loadTrash] Unmarshal: %v", err.Error())
+		return
+	}
+
+	log.LogInfof("loadTrash: load complete: partitionID(%v) volume(%v) entries(%v)",
+		mp.config.PartitionId, mp.config.VolName, mp.trash.len())
+	return
+}
+
+func (mp *metaPartition) storeTrash(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	filename := path.Join(rootDir, trashFile)
+	fp, err := os.OpenFile(filename, os.O_RDWR|os.O_TRUNC|os.O_APPEND|os.O_CREATE, 0755)
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = fp.Sync()
+		fp.Close()
+	}()
+
+	var data []byte
+	if data, crc, err = sm.trash.Marshal(); err != nil {
+		return
+	}
+	if _, err = fp.Write(data); err != nil {
+		return
+	}
+
+	log.LogInfof("storeTrash: store complete: partitionID(%v) volume(%v) crc(%v)",
+		mp.config.PartitionId, mp.config.VolName, crc)
+	return
+}
+
+func (mp *metaPartition) loadOrphans(rootDir string, crc uint32) (err error) {
+	filename := path.Join(rootDir, orphansFile)
+	if _, err = os.Stat(filename); err != nil {
+		log.LogErrorf("loadOrphans get file %s err(%s)", filename, err)
+		err = nil
+		return
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.LogErrorf("loadOrphans read file %s err(%s)", filename, err)
+		err = errors.NewErrorf("[loadOrphans] OpenFile: %v", err.Error())
+		return
+	}
+	if res := crc32.ChecksumIEEE(data); res != crc {
+		log.LogErrorf("[loadOrphans]: check crc mismatch, expected[%d], actual[%d]", crc, res)
+		return ErrSnapshotCrcMismatch
+	}
+	if err = mp.orphans.UnMarshal(data); err != nil {
+		log.LogErrorf("loadOrphans UnMarshal err(%s)", err)
+		err = errors.NewErrorf("[loadOrphans] Unmarshal: %v", err.Error())
+		return
+	}
+
+	log.LogInfof("loadOrphans: load complete: partitionID(%v) volume(%v) inodes(%v)",
+		mp.config.PartitionId, mp.config.VolName, mp.orphans.len())
+	return
+}
+
+func (mp *metaPartition) storeOrphans(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	filename := path.Join(rootDir, orphansFile)
+	fp, err := os.OpenFile(filename, os.O_RDWR|os.O_TRUNC|os.O_APPEND|os.O_CREATE, 0755)
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = fp.Sync()
+		fp.Close()
+	}()
+
+	var data []byte
+	if data, crc, err = sm.orphans.Marshal(); err != nil {
+		return
+	}
+	if _, err = fp.Write(data); err != nil {
+		return
+	}
+
+	log.LogInfof("storeOrphans: store complete: partitionID(%v) volume(%v) crc(%v)",
+		mp.config.PartitionId, mp.config.VolName, crc)
+	return
+}
+
+func (mp *metaPartition) loadVersions(rootDir string, crc uint32) (err error) {
+	filename := path.Join(rootDir, versionsFile)
+	if _, err = os.Stat(filename); err != nil {
+		log.LogErrorf("loadVersions get file %s err(%s)", filename, err)
+		err = nil
+		return
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.LogErrorf("loadVersions read file %s err(%s)", filename, err)
+		err = errors.NewErrorf("[loadVersions] OpenFile: %v", err.Error())
+		return
+	}
+	if res := crc32.ChecksumIEEE(data); res != crc {
+		log.LogErrorf("[loadVersions]: check crc mismatch, expected[%d], actual[%d]", crc, res)
+		return ErrSnapshotCrcMismatch
+	}
+	versions := newVersionTable()
+	if err = versions.UnMarshal(data); err != nil {
+		log.LogErrorf("loadVersions UnMarshal err(%s)", err)
+		err = errors.NewErrorf("[loadVersions] Unmarshal: %v", err.Error())
+		return
+	}
+	// the trees of the versions are kept out of the snapshot, see storeVersionFile
+	for _, v := range versions.list() {
+		if err = loadVersionFile(mp.config.RootDir, v); err != nil {
+			log.LogErrorf("loadVersions load version(%v) err(%s)", v.id, err)
+			err = errors.NewErrorf("[loadVersions] version %v: %v", v.id, err.Error())
+			return
+		}
+	}
+	mp.versions.replace(versions)
+
+	log.LogInfof("loadVersions: load complete: partitionID(%v) volume(%v) versions(%v)",
+		mp.config.PartitionId, mp.config.VolName, versions.len())
+	return
+}
+
+func (mp *metaPartition) storeVersions(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	versions := sm.versions
+	if versions == nil {
+		versions = newVersionTable()
+	}
+	for _, v := range versions.list() {
+		if err = storeVersionFile(mp.config.RootDir, v); err != nil {
+			return
+		}
+	}
+
+	filename := path.Join(rootDir, versionsFile)
+	fp, err := os.OpenFile(filename, os.O_RDWR|os.O_TRUNC|os.O_APPEND|os.O_CREATE, 0755)
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = fp.Sync()
+		fp.Close()
+	}()
+
+	var data []byte
+	if data, crc, err = versions.Marshal(); err != nil {
+		return
+	}
+	if _, err = fp.Write(data); err != nil {
+		return
+	}
+
+	log.LogInfof("storeVersions: store complete: partitionID(%v) volume(%v) versions(%v) crc(%v)",
+		mp.config.PartitionId, mp.config.VolName, versions.len(), crc)
+	return
+}
+
+func (mp *metaPartition) loadChangeFeed(rootDir string, crc uint32) (err error) {
+	filename := path.Join(rootDir, changeFeedFile)
+	if _, err = os.Stat(filename); err != nil {
+		log.LogErrorf("loadChangeFeed get file %s err(%s)", filename, err)
+		err = nil
+		return
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.LogErrorf("loadChangeFeed read file %s err(%s)", filename, err)
+		err = errors.NewErrorf("[loadChangeFeed] OpenFile: %v", err.Error())
+		return
+	}
+	if res := crc32.ChecksumIEEE(data); res != crc {
+		log.LogErrorf("[loadChangeFeed]: check crc mismatch, expected[%d], actual[%d]", crc, res)
+		return ErrSnapshotCrcMismatch
+	}
+	tail := &changeFeedTail{}
+	if err = tail.UnMarshal(data); err != nil {
+		log.LogErrorf("loadChangeFeed UnMarshal err(%s)", err)
+		err = errors.NewErrorf("[loadChangeFeed] Unmarshal: %v", err.Error())
+		return
+	}
+	// kept once the partition is reset to the apply id of the snapshot
+	mp.feed.load(tail)
+
+	log.LogInfof("loadChangeFeed: load complete: partitionID(%v) volume(%v) floor(%v) events(%v)",
+		mp.config.PartitionId, mp.config.VolName, tail.Floor, len(tail.Events))
+	return
+}
+
+func (mp *metaPartition) storeChangeFeed(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	tail := sm.feed
+	if tail == nil {
+		tail = &changeFeedTail{Floor: sm.applyIndex}
+	}
+	filename := path.Join(rootDir, changeFeedFile)
+	fp, err := os.OpenFile(filename, os.O_RDWR|os.O_TRUNC|os.O_APPEND|os.O_CREATE, 0755)
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = fp.Sync()
+		fp.Close()
+	}()
+
+	var data []byte
+	if data, crc, err = tail.Marshal(); err != nil {
+		return
+	}
+	if _, err = fp.Write(data); err != nil {
+		return
+	}
+
+	log.LogInfof("storeChangeFeed: store complete: partitionID(%v) volume(%v) floor(%v) events(%v) crc(%v)",
+		mp.config.PartitionId, mp.config.VolName, tail.Floor, len(tail.Events), crc)
+	return
+}
+
+func (mp *metaPartition) loadSplitState(rootDir string, crc uint32) (err error) {
+	filename := path.Join(rootDir, splitStateFile)
+	if _, err = os.Stat(filename); err != nil {
+		log.LogErrorf("loadSplitState get file %s err(%s)", filename, err)
+		err = nil
+		return
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.LogErrorf("loadSplitState read file %s err(%s)", filename, err)
+		err = errors.NewErrorf("[loadSplitState] OpenFile: %v", err.Error())
+		return
+	}
+	if res := crc32.ChecksumIEEE(data); res != crc {
+		log.LogErrorf("[loadSplitState]: check crc mismatch, expected[%d], actual[%d]", crc, res)
+		return ErrSnapshotCrcMismatch
+	}
+	state := &splitState{}
+	if err = state.UnMarshal(data); err != nil {
+		log.LogErrorf("loadSplitState UnMarshal err(%s)", err)
+		err = errors.NewErrorf("[loadSplitState] Unmarshal: %v", err.Error())
+		return
+	}
+	// the metadata may be persisted after the snapshot, the state is replayed from the one of the snapshot
+	mp.restoreSplitState(state)
+
+	log.LogInfof("loadSplitState: load complete: partitionID(%v) volume(%v) end(%v) moves(%v) migration(%v)",
+		mp.config.PartitionId, mp.config.VolName, state.End, len(state.Moves), state.Migration != nil)
+	return
+}
+
+func (mp *metaPartition) storeSplitState(rootDir string, sm *storeMsg) (crc uint32, err error) {
+	state := sm.split
+	if state == nil {
+		state = mp.splitState()
+	}
+	filename := path.Join(rootDir, splitStateFile)
+	fp, err := os.OpenFile(filename, os.O_RDWR|os.O_TRUNC|os.O_APPEND|os.O_CREATE, 0755)
+	if err != nil {
+		return
+	}
+	defer func() {
+		err = fp.Sync()
+		fp.Close()
+	}()
+
+	var data []byte
+	if data, crc, err = state.Marshal(); err != nil {
+		return
+	}
+	if _, err = fp.Write(data); err != nil {
+		return
+	}
+
+	log.LogInfof("storeSplitState: store complete: partitionID(%v) volume(%v) end(%v) moves(%v) crc(%v)",
+		mp.config.PartitionId, mp.config.VolName, state.End, len(state.Moves), crc)
+	return
+}
@@ -0,0 +1,405 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/cubefs/cubefs/util/btree"
+	"github.com/cubefs/cubefs/util/errors"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// A snapshot of the partition kept in memory is made of the base files of the trees and
+// the deltas written since, which are listed in the manifest:
+//
+//	inode, dentry, extend, multipart       the base files, linked from the previous snapshot
+//	delta.<applyID>                        the items changed until the applyID
+//	manifest                               the base and the deltas
+//
+// A delta is a list of records: op(1) | tree(1) | length(4) | item or key. Once there are
+// too many deltas or they are too large compared to the base, the base is written again.
+const (
+	defaultSnapshotMaxDeltas = 8
+
+	deltaOpPut    byte = 1
+	deltaOpDelete byte = 2
+
+	deltaRecordHeaderSize = 6
+)
+
+// max number of deltas on top of the base of a snapshot, 0 writes the snapshot in full every time
+var snapshotMaxDeltas = defaultSnapshotMaxDeltas
+
+var deltaBaseFiles = []string{inodeFile, dentryFile, extendFile, multipartFile}
+
+type snapshotManifest struct {
+	BaseApplyID uint64           `json:"base_apply_id"`
+	BaseItems   uint64           `json:"base_items"`
+	Deltas      []*snapshotDelta `json:"deltas"`
+}
+
+type snapshotDelta struct {
+	Name    string `json:"name"`
+	ApplyID uint64 `json:"apply_id"`
+	Items   uint64 `json:"items"`
+	Crc     uint32 `json:"crc"`
+}
+
+func (m *snapshotManifest) applyID() uint64 {
+	if len(m.Deltas) == 0 {
+		return m.BaseApplyID
+	}
+	return m.Deltas[len(m.Deltas)-1].ApplyID
+}
+
+func (m *snapshotManifest) deltaItems() (items uint64) {
+	for _, d := range m.Deltas {
+		items += d.Items
+	}
+	return
+}
+
+// readSnapshotManifest returns nil if the snapshot has no manifest.
+func readSnapshotManifest(rootDir string) (m *snapshotManifest, err error) {
+	var data []byte
+	if data, err = ioutil.ReadFile(path.Join(rootDir, manifestFile)); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	m = &snapshotManifest{}
+	if err = json.Unmarshal(data, m); err != nil {
+		err = errors.NewErrorf("[readSnapshotManifest] Unmarshal: %s", err.Error())
+	}
+	return
+}
+
+func (m *snapshotManifest) store(rootDir string) (err error) {
+	var data []byte
+	if data, err = json.Marshal(m); err != nil {
+		return
+	}
+	return ioutil.WriteFile(path.Join(rootDir, manifestFile), data, 0755)
+}
+
+func (mp *metaPartition) snapshotTrees() []*BTree {
+	return []*BTree{mp.inodeTree, mp.dentryTree, mp.extendTree, mp.multipartTree}
+}
+
+// trackSnapshotChanges records the keys changed since baseIndex, so that the
+// snapshots are written as deltas.
+func (mp *metaPartition) trackSnapshotChanges(baseIndex uint64) {
+	if mp.kv != nil || snapshotMaxDeltas <= 0 {
+		return
+	}
+	for _, tree := range mp.snapshotTrees() {
+		tree.trackChanges(baseIndex)
+	}
+}
+
+// setTracking is set while applying the raft log at the index.
+func (mp *metaPartition) setTracking(index uint64, on bool) {
+	for _, tree := range mp.snapshotTrees() {
+		tree.setTracking(index, on)
+	}
+}
+
+// snapshotStore decides how the trees of a store message are written.
+type snapshotStore struct {
+	// the changes of the live trees cloned when the store started
+	changes  []*btree.BTree
+	manifest *snapshotManifest
+	prev     *snapshotManifest
+	crcs     []uint32
+	base     bool
+}
+
+// newSnapshotStore returns nil if the changes are not tracked, then the snapshot is written in full without a manifest.
+func (mp *metaPartition) newSnapshotStore(sm *storeMsg) (s *snapshotStore) {
+	if mp.kv != nil || snapshotMaxDeltas <= 0 {
+		return nil
+	}
+	s = &snapshotStore{}
+	s.useBase(sm)
+	var baseIndex, changed uint64
+	for _, tree := range mp.snapshotTrees() {
+		changes, index, ok := tree.cloneChanges()
+		if !ok {
+			return nil
+		}
+		if index > baseIndex {
+			baseIndex = index
+		}
+		changed += uint64(changes.Len())
+		s.changes = append(s.changes, changes)
+	}
+
+	rootDir := path.Join(mp.config.RootDir, snapshotDir)
+	prev, err := readSnapshotManifest(rootDir)
+	if err != nil || prev == nil {
+		return
+	}
+	applyID, err := readApplyIDFile(rootDir)
+	if err != nil || applyID != prev.applyID() || applyID < baseIndex || applyID >= sm.applyIndex {
+		return
+	}
+	if len(prev.Deltas) >= snapshotMaxDeltas || prev.deltaItems()+changed > prev.BaseItems/2 {
+		return
+	}
+	crcs, err := mp.parseCrcFromFile()
+	if err != nil || len(crcs) < CRC_COUNT_BASIC {
+		return
+	}
+	s.base = false
+	s.prev = prev
+	s.crcs = crcs[:CRC_COUNT_BASIC]
+	s.manifest = &snapshotManifest{
+		BaseApplyID: prev.BaseApplyID,
+		BaseItems:   prev.BaseItems,
+		Deltas:      append([]*snapshotDelta{}, prev.Deltas...),
+	}
+	return
+}
+
+// useBase writes the trees of the snapshot in full.
+func (s *snapshotStore) useBase(sm *storeMsg) {
+	s.base = true
+	s.manifest = &snapshotManifest{BaseApplyID: sm.applyIndex}
+	for _, tree := range sm.snapshotTrees() {
+		s.manifest.BaseItems += uint64(tree.Len())
+	}
+}
+
+// storeFuncs returns the functions storing the trees, the base files of a delta are
+// linked from the previous snapshot and only the statistics are collected.
+func (s *snapshotStore) storeFuncs(mp *metaPartition) []func(dir string, sm *storeMsg) (uint32, error) {
+	linked := func(i int, stats func(sm *storeMsg)) func(dir string, sm *storeMsg) (uint32, error) {
+		return func(dir string, sm *storeMsg) (uint32, error) {
+			if stats != nil {
+				stats(sm)
+			}
+			return s.crcs[i], nil
+		}
+	}
+	return []func(dir string, sm *storeMsg) (uint32, error){
+		linked(0, mp.storeInodeStats),
+		linked(1, nil),
+		linked(2, mp.storeExtendStats),
+		linked(3, nil),
+	}
+}
+
+// storeDelta links the base and the previous deltas into the directory and writes the changes as a new delta.
+// If it fails the linked files are removed, so that the base can be written instead.
+func (s *snapshotStore) storeDelta(mp *metaPartition, rootDir string, sm *storeMsg) (err error) {
+	var linked []string
+	defer func() {
+		if err != nil {
+			for _, name := range linked {
+				os.Remove(path.Join(rootDir, name))
+			}
+		}
+	}()
+	names := append([]string{}, deltaBaseFiles...)
+	for _, d := range s.prev.Deltas {
+		names = append(names, d.Name)
+	}
+	srcDir := path.Join(mp.config.RootDir, snapshotDir)
+	for _, name := range names {
+		if err = os.Link(path.Join(srcDir, name), path.Join(rootDir, name)); err != nil {
+			return
+		}
+		linked = append(linked, name)
+	}
+
+	delta := &snapshotDelta{Name: fmt.Sprintf("%s%020d", deltaFilePrefix, sm.applyIndex), ApplyID: sm.applyIndex}
+	linked = append(linked, delta.Name)
+	if delta.Items, delta.Crc, err = s.writeDelta(path.Join(rootDir, delta.Name), sm); err != nil {
+		return
+	}
+	s.manifest.Deltas = append(s.manifest.Deltas, delta)
+	log.LogInfof("storeDelta: store complete: partitionID(%v) volume(%v) delta(%v) items(%v) deltas(%v)",
+		mp.config.PartitionId, mp.config.VolName, delta.Name, delta.Items, len(s.manifest.Deltas))
+	return
+}
+
+func (s *snapshotStore) writeDelta(filename string, sm *storeMsg) (items uint64, crc uint32, err error) {
+	fp, err := os.OpenFile(filename, os.O_RDWR|os.O_TRUNC|os.O_CREATE, 0755)
+	if err != nil {
+		return
+	}
+	defer func() {
+		closeErr := fp.Close()
+		if err == nil && closeErr != nil {
+			err = closeErr
+		}
+	}()
+	writer := bufio.NewWriterSize(fp, 4*1024*1024)
+	sign := crc32.NewIEEE()
+	header := make([]byte, deltaRecordHeaderSize)
+	views := sm.snapshotTrees()
+	for i, changes := range s.changes {
+		codec := kvCodecs[i]
+		changes.Ascend(func(e btree.Item) bool {
+			key := e.(*changeEntry).key
+			var raw []byte
+			op := deltaOpDelete
+			if item := views[i].Get(key); item != nil {
+				op = deltaOpPut
+				if raw, err = codec.marshal(item); err != nil {
+					return false
+				}
+			} else {
+				raw = codec.key(key)
+			}
+			header[0], header[1] = op, codec.tree
+			binary.BigEndian.PutUint32(header[2:], uint32(len(raw)))
+			if _, err = writer.Write(header); err != nil {
+				return false
+			}
+			if _, err = writer.Write(raw); err != nil {
+				return false
+			}
+			sign.Write(header)
+			sign.Write(raw)
+			items++
+			return true
+		})
+		if err != nil {
+			return
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		return
+	}
+	if err = fp.Sync(); err != nil {
+		return
+	}
+	crc = sign.Sum32()
+	return
+}
+
+// trim drops the changes written to the snapshot from the live trees.
+func (s *snapshotStore) trim(mp *metaPartition, applyIndex uint64) {
+	for i, tree := range mp.snapshotTrees() {
+		tree.trimChanges(s.changes[i], applyIndex)
+	}
+}
+
+// snapshotOverlay keeps the items of the deltas of a snapshot by tree and key, a
+// nil item is deleted. The items of the base files replaced by it are skipped.
+type snapshotOverlay []map[string]BtreeItem
+
+func loadSnapshotOverlay(rootDir string) (o snapshotOverlay, err error) {
+	var m *snapshotManifest
+	if m, err = readSnapshotManifest(rootDir); err != nil || m == nil || len(m.Deltas) == 0 {
+		return
+	}
+	o = make(snapshotOverlay, len(kvCodecs))
+	for i := range o {
+		o[i] = make(map[string]BtreeItem)
+	}
+	for _, d := range m.Deltas {
+		if err = o.loadDelta(path.Join(rootDir, d.Name), d.Crc); err != nil {
+			return nil, err
+		}
+	}
+	log.LogInfof("loadSnapshotOverlay: load complete: dir(%v) base(%v) deltas(%v) items(%v)",
+		rootDir, m.BaseApplyID, len(m.Deltas), m.deltaItems())
+	return
+}
+
+func (o snapshotOverlay) loadDelta(filename string, crc uint32) (err error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return errors.NewErrorf("[loadDelta] OpenFile: %s", err.Error())
+	}
+	defer fp.Close()
+	reader := bufio.NewReaderSize(fp, 4*1024*1024)
+	sign := crc32.NewIEEE()
+	header := make([]byte, deltaRecordHeaderSize)
+	for {
+		if _, err = io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				err = nil
+				break
+			}
+			return errors.NewErrorf("[loadDelta] ReadHeader: %s", err.Error())
+		}
+		raw := make([]byte, binary.BigEndian.Uint32(header[2:]))
+		if _, err = io.ReadFull(reader, raw); err != nil {
+			return errors.NewErrorf("[loadDelta] ReadBody: %s", err.Error())
+		}
+		sign.Write(header)
+		sign.Write(raw)
+
+		i := int(header[1]) - 1
+		if i < 0 || i >= len(kvCodecs) || kvCodecs[i].tree != header[1] {
+			return errors.NewErrorf("[loadDelta] unknown tree %v", header[1])
+		}
+		switch header[0] {
+		case deltaOpPut:
+			var item BtreeItem
+			if item, err = kvCodecs[i].unmarshal(raw); err != nil {
+				return errors.NewErrorf("[loadDelta] Unmarshal: %s", err.Error())
+			}
+			o[i][string(kvCodecs[i].key(item))] = item
+		case deltaOpDelete:
+			o[i][string(raw)] = nil
+		default:
+			return errors.NewErrorf("[loadDelta] unknown op %v", header[0])
+		}
+	}
+	if res := sign.Sum32(); res != crc {
+		log.LogErrorf("[loadDelta]: check crc mismatch, file[%v] expected[%d], actual[%d]", filename, crc, res)
+		return ErrSnapshotCrcMismatch
+	}
+	return
+}
+
+// has reports whether the item of the base file is replaced by the deltas.
+func (o snapshotOverlay) has(tree int, item BtreeItem) (ok bool) {
+	if o == nil {
+		return
+	}
+	_, ok = o[tree][string(kvCodecs[tree].key(item))]
+	return
+}
+
+// load calls fn for the items of the deltas, after the base file of the tree is loaded.
+func (o snapshotOverlay) load(tree int, fn func(item BtreeItem) error) (err error) {
+	if o == nil {
+		return
+	}
+	for _, item := range o[tree] {
+		if item == nil {
+			continue
+		}
+		if err = fn(item); err != nil {
+			return
+		}
+	}
+	return
+}
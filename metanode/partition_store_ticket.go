@@ -40,6 +40,13 @@ type storeMsg struct {
 	uidRebuild     bool
 	uniqId         uint64
 	uniqChecker    *uniqChecker
+	fileLocks      *fileLockTable
+	extentRefs     *extentRefTable
+	trash          *trashTable
+	orphans        *orphanTable
+	versions       *versionTable
+	feed           *changeFeedTail
+	split          *splitState
 }
 
 func (mp *metaPartition) startSchedule(curIndex uint64) {
@@ -58,6 +65,8 @@ func (mp *metaPartition) startSchedule(curIndex uint64) {
 				log.LogWarnf("[startSchedule] start trunc, partitionId=%d: nowAppID"+
 					"=%d, applyID=%d", mp.config.PartitionId, curIndex,
 					msg.applyIndex)
+				// the cached snapshot can't be sent once the raft log after it is truncated
+				mp.snapshotCache.expire(curIndex)
 				mp.raftPartition.Truncate(curIndex)
 			} else {
 				// maybe happen when start load dentry
@@ -65,6 +74,7 @@ func (mp *metaPartition) startSchedule(curIndex uint64) {
 					" truncate raft log")
 			}
 			curIndex = msg.applyIndex
+			mp.releaseKVTrees(msg)
 		} else {
 			// retry again
 			mp.storeChan <- msg
@@ -64,6 +64,10 @@ func TestMetaPartition_LoadSnapshot(t *testing.T) {
 		txRbDentryTree: mp.txProcessor.txResource.txRbDentryTree,
 		uniqId:         mp.GetUniqId(),
 		uniqChecker:    mp.uniqChecker,
+		fileLocks:      mp.fileLocks,
+		extentRefs:     mp.extentRefs,
+		trash:          mp.trash,
+		orphans:        mp.orphans,
 	}
 	mp.uidManager = NewUidMgr(mpC.VolName, mpC.PartitionId)
 	mp.mqMgr = NewQuotaManager(mpC.VolName, mpC.PartitionId)
@@ -104,6 +108,10 @@ func TestMetaPartition_LoadSnapshot(t *testing.T) {
 		txRbDentryTree: mp.txProcessor.txResource.txRbDentryTree,
 		uniqId:         mp.GetUniqId(),
 		uniqChecker:    mp.uniqChecker,
+		fileLocks:      mp.fileLocks,
+		extentRefs:     mp.extentRefs,
+		trash:          mp.trash,
+		orphans:        mp.orphans,
 	}
 	err = mp.store(msg)
 	require.Nil(t, err)
@@ -135,3 +143,95 @@ func TestMetaPartition_LoadSnapshot(t *testing.T) {
 	require.Equal(t, ErrSnapshotCrcMismatch, err)
 
 }
+
+func newTestStoreMsg(mp *metaPartition, applyIndex uint64) *storeMsg {
+	return &storeMsg{
+		command:        opFSMStoreTick,
+		applyIndex:     applyIndex,
+		txId:           mp.txProcessor.txManager.txIdAlloc.getTransactionID(),
+		inodeTree:      mp.inodeTree.GetTree(),
+		dentryTree:     mp.dentryTree.GetTree(),
+		extendTree:     mp.extendTree.GetTree(),
+		multipartTree:  mp.multipartTree.GetTree(),
+		txTree:         mp.txProcessor.txManager.txTree.GetTree(),
+		txRbInodeTree:  mp.txProcessor.txResource.txRbInodeTree.GetTree(),
+		txRbDentryTree: mp.txProcessor.txResource.txRbDentryTree.GetTree(),
+		uniqId:         mp.GetUniqId(),
+		uniqChecker:    mp.uniqChecker.clone(),
+		fileLocks:      mp.fileLocks.clone(),
+		extentRefs:     mp.extentRefs.clone(),
+		trash:          mp.trash.clone(),
+		orphans:        mp.orphans.clone(),
+		versions:       mp.versions.clone(),
+		split:          mp.splitState(),
+	}
+}
+
+func TestMetaPartition_SnapshotDelta(t *testing.T) {
+	testPath := "/tmp/testMetaPartitionDelta/"
+	os.RemoveAll(testPath)
+	defer os.RemoveAll(testPath)
+	mpC := &MetaPartitionConfig{
+		PartitionId:   1,
+		VolName:       "test_vol",
+		Start:         0,
+		End:           100,
+		PartitionType: 1,
+		RootDir:       testPath,
+	}
+	metaM := &metadataManager{
+		nodeId:     1,
+		partitions: make(map[uint64]MetaPartition),
+		metaNode:   &MetaNode{},
+	}
+	newPartition := func() *metaPartition {
+		mp := NewMetaPartition(mpC, metaM).(*metaPartition)
+		mp.uidManager = NewUidMgr(mpC.VolName, mpC.PartitionId)
+		mp.mqMgr = NewQuotaManager(mpC.VolName, mpC.PartitionId)
+		return mp
+	}
+
+	mp := newPartition()
+	mp.trackSnapshotChanges(0)
+	for ino := uint64(1); ino <= 20; ino++ {
+		mp.inodeTree.ReplaceOrInsert(NewInode(ino, 0), true)
+	}
+	mp.dentryTree.ReplaceOrInsert(&Dentry{ParentId: 1, Name: "a", Inode: 2}, true)
+	require.NoError(t, mp.store(newTestStoreMsg(mp, 1)))
+	snapshotPath := path.Join(mp.config.RootDir, snapshotDir)
+	m, err := readSnapshotManifest(snapshotPath)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), m.BaseApplyID)
+	require.Empty(t, m.Deltas)
+
+	// the items changed in place are written to the delta
+	mp.setTracking(2, true)
+	mp.inodeTree.Get(NewInode(3, 0)).(*Inode).Size = 100
+	mp.setTracking(2, false)
+	mp.inodeTree.Delete(NewInode(5, 0))
+	mp.inodeTree.ReplaceOrInsert(NewInode(30, 0), true)
+	mp.dentryTree.Delete(&Dentry{ParentId: 1, Name: "a"})
+	require.NoError(t, mp.store(newTestStoreMsg(mp, 2)))
+	m, err = readSnapshotManifest(snapshotPath)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), m.BaseApplyID)
+	require.Len(t, m.Deltas, 1)
+	require.Equal(t, uint64(4), m.Deltas[0].Items)
+
+	loaded := newPartition()
+	require.NoError(t, loaded.LoadSnapshot(snapshotPath))
+	require.Equal(t, uint64(2), loaded.applyID)
+	require.Equal(t, 20, loaded.inodeTree.Len())
+	require.Equal(t, uint64(100), loaded.inodeTree.Get(NewInode(3, 0)).(*Inode).Size)
+	require.Nil(t, loaded.inodeTree.Get(NewInode(5, 0)))
+	require.NotNil(t, loaded.inodeTree.Get(NewInode(30, 0)))
+	require.Equal(t, 0, loaded.dentryTree.Len())
+
+	// a corrupted delta fails the load
+	deltaPath := path.Join(snapshotPath, m.Deltas[0].Name)
+	data, err := ioutil.ReadFile(deltaPath)
+	require.NoError(t, err)
+	data[len(data)-1]++
+	require.NoError(t, ioutil.WriteFile(deltaPath, data, 0644))
+	require.Equal(t, ErrSnapshotCrcMismatch, newPartition().LoadSnapshot(snapshotPath))
+}
@@ -0,0 +1,188 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package rocksdbstore implements the metanode.KVStore with RocksDB.
+package rocksdbstore
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cubefs/cubefs/metanode"
+	"github.com/tecbot/gorocksdb"
+)
+
+const (
+	DefaultLRUCacheSize    = 256 * 1024 * 1024
+	DefaultWriteBufferSize = 64 * 1024 * 1024
+)
+
+// Store is a metanode.KVStore of a RocksDB instance.
+type Store struct {
+	dir string
+	db  *gorocksdb.DB
+	ro  *gorocksdb.ReadOptions
+}
+
+// Open opens the RocksDB instance in the directory with the default options.
+func Open(dir string) (metanode.KVStore, error) {
+	return NewStore(dir, DefaultLRUCacheSize, DefaultWriteBufferSize)
+}
+
+// NewStore returns a new Store, the directory is created if missing.
+func NewStore(dir string, lruCacheSize, writeBufferSize int) (store *Store, err error) {
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return
+	}
+	basedTableOptions := gorocksdb.NewDefaultBlockBasedTableOptions()
+	basedTableOptions.SetBlockCache(gorocksdb.NewLRUCache(uint64(lruCacheSize)))
+	opts := gorocksdb.NewDefaultOptions()
+	opts.SetBlockBasedTableFactory(basedTableOptions)
+	opts.SetCreateIfMissing(true)
+	opts.SetWriteBufferSize(writeBufferSize)
+	opts.SetMaxWriteBufferNumber(2)
+	db, err := gorocksdb.OpenDb(opts, dir)
+	if err != nil {
+		err = fmt.Errorf("action[openRocksDB] dir(%v) err(%v)", dir, err)
+		return
+	}
+	store = &Store{dir: dir, db: db, ro: gorocksdb.NewDefaultReadOptions()}
+	return
+}
+
+func (s *Store) Get(key []byte) ([]byte, error) {
+	return get(s.db, s.ro, key)
+}
+
+func (s *Store) NewIterator(start, end []byte) metanode.KVIterator {
+	return newIterator(s.db, nil, start, end)
+}
+
+func (s *Store) Write(batch *metanode.KVBatch, sync bool) (err error) {
+	wb := gorocksdb.NewWriteBatch()
+	wo := gorocksdb.NewDefaultWriteOptions()
+	wo.SetSync(sync)
+	defer func() {
+		wb.Destroy()
+		wo.Destroy()
+	}()
+	for _, op := range batch.Ops {
+		switch op.Type {
+		case metanode.KVOpPut:
+			wb.Put(op.Key, op.Value)
+		case metanode.KVOpDelete:
+			wb.Delete(op.Key)
+		case metanode.KVOpDeleteRange:
+			wb.DeleteRange(op.Key, op.Value)
+		default:
+			return fmt.Errorf("action[rocksdbWrite] unknown op type %v", op.Type)
+		}
+	}
+	return s.db.Write(wo, wb)
+}
+
+func (s *Store) NewSnapshot() metanode.KVSnapshot {
+	snap := s.db.NewSnapshot()
+	ro := gorocksdb.NewDefaultReadOptions()
+	ro.SetSnapshot(snap)
+	return &snapshot{db: s.db, snap: snap, ro: ro}
+}
+
+func (s *Store) Close() error {
+	s.ro.Destroy()
+	s.db.Close()
+	return nil
+}
+
+type snapshot struct {
+	db   *gorocksdb.DB
+	snap *gorocksdb.Snapshot
+	ro   *gorocksdb.ReadOptions
+}
+
+func (s *snapshot) Get(key []byte) ([]byte, error) {
+	return get(s.db, s.ro, key)
+}
+
+func (s *snapshot) NewIterator(start, end []byte) metanode.KVIterator {
+	return newIterator(s.db, s.snap, start, end)
+}
+
+func (s *snapshot) Release() {
+	s.ro.Destroy()
+	s.db.ReleaseSnapshot(s.snap)
+}
+
+func get(db *gorocksdb.DB, ro *gorocksdb.ReadOptions, key []byte) (value []byte, err error) {
+	slice, err := db.Get(ro, key)
+	if err != nil {
+		return
+	}
+	defer slice.Free()
+	if !slice.Exists() {
+		return
+	}
+	value = append([]byte{}, slice.Data()...)
+	return
+}
+
+type iterator struct {
+	ro  *gorocksdb.ReadOptions
+	it  *gorocksdb.Iterator
+	end []byte // referenced by the upper bound of ro
+}
+
+func newIterator(db *gorocksdb.DB, snap *gorocksdb.Snapshot, start, end []byte) *iterator {
+	ro := gorocksdb.NewDefaultReadOptions()
+	ro.SetFillCache(false)
+	if snap != nil {
+		ro.SetSnapshot(snap)
+	}
+	if end != nil {
+		ro.SetIterateUpperBound(end)
+	}
+	it := db.NewIterator(ro)
+	it.Seek(start)
+	return &iterator{ro: ro, it: it, end: end}
+}
+
+func (i *iterator) Valid() bool {
+	return i.it.Valid()
+}
+
+func (i *iterator) Key() []byte {
+	return copySlice(i.it.Key())
+}
+
+func (i *iterator) Value() []byte {
+	return copySlice(i.it.Value())
+}
+
+func (i *iterator) Next() {
+	i.it.Next()
+}
+
+func (i *iterator) Err() error {
+	return i.it.Err()
+}
+
+func (i *iterator) Close() {
+	i.it.Close()
+	i.ro.Destroy()
+}
+
+func copySlice(slice *gorocksdb.Slice) []byte {
+	defer slice.Free()
+	return append([]byte{}, slice.Data()...)
+}
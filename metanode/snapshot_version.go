@@ -0,0 +1,341 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// The versions of the partition are kept in the files of the directory under the root of the partition, one per
+// version. The versions are never modified, so the files are written once and are not rewritten by the snapshots,
+// which record the ids of the versions only.
+const (
+	snapshotVersionDir = "snapshot_versions"
+	versionFileCrcSize = 4
+)
+
+// snapshotFence refuses the writes of the partition while the snapshot version is prepared, so that the version
+// committed is the metadata of the data extents frozen by the prepare. The deadline is given by the leader, so that
+// the expiry is deterministic across the replicas.
+type snapshotFence struct {
+	Version  uint64 `json:"ver"`
+	Deadline int64  `json:"deadline"` // unix nano
+}
+
+// metaVersion is the read-only version of the trees of the partition taken by a snapshot, the trees are the clones
+// of the partition at the point the version is committed, and share the items not modified since.
+type metaVersion struct {
+	id         uint64
+	inodeTree  *BTree
+	dentryTree *BTree
+	extendTree *BTree
+}
+
+func newMetaVersion(id uint64) *metaVersion {
+	return &metaVersion{
+		id:         id,
+		inodeTree:  NewBtree(),
+		dentryTree: NewBtree(),
+		extendTree: NewBtree(),
+	}
+}
+
+// trees returns the trees of the version in the order of migrateCodecs.
+func (v *metaVersion) trees() []*BTree {
+	return []*BTree{v.inodeTree, v.dentryTree, v.extendTree}
+}
+
+func (v *metaVersion) getInode(ino uint64) *Inode {
+	item := v.inodeTree.Get(NewInode(ino, 0))
+	if item == nil {
+		return nil
+	}
+	return item.(*Inode)
+}
+
+func (v *metaVersion) isShardedDir(ino uint64) bool {
+	inode := v.getInode(ino)
+	return inode != nil && inode.IsShardedDir()
+}
+
+// versionState is the state of the versions recorded by the snapshot of the partition.
+type versionState struct {
+	Fence    *snapshotFence `json:"fence,omitempty"`
+	Versions []uint64       `json:"vers"`
+}
+
+// versionTable holds the snapshot versions of the partition, and the fence of the version being prepared.
+// The zero value is an empty table.
+type versionTable struct {
+	sync.RWMutex
+	fence    *snapshotFence
+	versions map[uint64]*metaVersion
+}
+
+func newVersionTable() *versionTable {
+	return &versionTable{versions: make(map[uint64]*metaVersion)}
+}
+
+// clone returns a copy of the table, the versions are shared as they are never modified.
+func (t *versionTable) clone() *versionTable {
+	t.RLock()
+	defer t.RUnlock()
+	cloned := newVersionTable()
+	if t.fence != nil {
+		fence := *t.fence
+		cloned.fence = &fence
+	}
+	for id, v := range t.versions {
+		cloned.versions[id] = v
+	}
+	return cloned
+}
+
+// replace replaces the content of the table with the other, e.g. a raft snapshot is applied.
+func (t *versionTable) replace(other *versionTable) {
+	other.RLock()
+	fence, versions := other.fence, other.versions
+	other.RUnlock()
+	t.Lock()
+	t.fence, t.versions = fence, versions
+	t.Unlock()
+}
+
+func (t *versionTable) len() int {
+	t.RLock()
+	defer t.RUnlock()
+	return len(t.versions)
+}
+
+func (t *versionTable) get(id uint64) *metaVersion {
+	t.RLock()
+	defer t.RUnlock()
+	return t.versions[id]
+}
+
+func (t *versionTable) put(v *metaVersion) {
+	t.Lock()
+	defer t.Unlock()
+	if t.versions == nil {
+		t.versions = make(map[uint64]*metaVersion)
+	}
+	t.versions[v.id] = v
+}
+
+func (t *versionTable) remove(id uint64) *metaVersion {
+	t.Lock()
+	defer t.Unlock()
+	v := t.versions[id]
+	delete(t.versions, id)
+	return v
+}
+
+func (t *versionTable) getFence() *snapshotFence {
+	t.RLock()
+	defer t.RUnlock()
+	return t.fence
+}
+
+func (t *versionTable) setFence(fence *snapshotFence) {
+	t.Lock()
+	t.fence = fence
+	t.Unlock()
+}
+
+// clearFence drops the fence of the version, and returns false if the partition is not fenced by the version.
+func (t *versionTable) clearFence(version uint64) bool {
+	t.Lock()
+	defer t.Unlock()
+	if t.fence == nil || t.fence.Version != version {
+		return false
+	}
+	t.fence = nil
+	return true
+}
+
+// ids returns the ids of the versions in order.
+func (t *versionTable) ids() []uint64 {
+	t.RLock()
+	ids := make([]uint64, 0, len(t.versions))
+	for id := range t.versions {
+		ids = append(ids, id)
+	}
+	t.RUnlock()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// list returns the versions in the order of the ids.
+func (t *versionTable) list() []*metaVersion {
+	ids := t.ids()
+	versions := make([]*metaVersion, 0, len(ids))
+	t.RLock()
+	for _, id := range ids {
+		versions = append(versions, t.versions[id])
+	}
+	t.RUnlock()
+	return versions
+}
+
+// Marshal marshals the state of the table, the trees of the versions are stored by storeVersionFile.
+func (t *versionTable) Marshal() (buf []byte, crc uint32, err error) {
+	state := &versionState{
+		Fence:    t.getFence(),
+		Versions: t.ids(),
+	}
+	if buf, err = json.Marshal(state); err != nil {
+		return
+	}
+	crc = crc32.ChecksumIEEE(buf)
+	return
+}
+
+// UnMarshal restores the state of the table, the versions are created empty.
+func (t *versionTable) UnMarshal(data []byte) (err error) {
+	state := &versionState{}
+	if err = json.Unmarshal(data, state); err != nil {
+		return
+	}
+	versions := make(map[uint64]*metaVersion, len(state.Versions))
+	for _, id := range state.Versions {
+		versions[id] = newMetaVersion(id)
+	}
+	t.Lock()
+	t.fence = state.Fence
+	t.versions = versions
+	t.Unlock()
+	return
+}
+
+// writeVersionItems writes the items of the version in the records of the migration, see migrateWriter.
+func writeVersionItems(v *metaVersion, send func(data []byte) error) (err error) {
+	w := &migrateWriter{send: send}
+	if err = w.writeViews(v.trees(), migrateKeys(0)); err != nil {
+		return
+	}
+	return w.flush()
+}
+
+// applyVersionItems inserts the items in the records of the migration into the version.
+func applyVersionItems(v *metaVersion, data []byte) (err error) {
+	records, err := decodeMigrateRecords(data)
+	if err != nil {
+		return
+	}
+	trees := v.trees()
+	for _, r := range records {
+		if r.op != deltaOpPut {
+			return fmt.Errorf("unexpected op %v of version %v", r.op, v.id)
+		}
+		trees[r.tree].ReplaceOrInsert(r.item, true)
+	}
+	return
+}
+
+func versionFilePath(rootDir string, id uint64) string {
+	return path.Join(rootDir, snapshotVersionDir, strconv.FormatUint(id, 10))
+}
+
+// storeVersionFile writes the items of the version to its file if not written yet, the crc of the items is
+// appended to the end.
+func storeVersionFile(rootDir string, v *metaVersion) (err error) {
+	filename := versionFilePath(rootDir, v.id)
+	if _, err = os.Stat(filename); err == nil {
+		return
+	}
+	if err = os.MkdirAll(path.Dir(filename), 0755); err != nil {
+		return
+	}
+	tmpFile := filename + ".tmp"
+	fp, err := os.OpenFile(tmpFile, os.O_RDWR|os.O_TRUNC|os.O_CREATE, 0755)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			fp.Close()
+			os.Remove(tmpFile)
+		}
+	}()
+	crc := crc32.NewIEEE()
+	if err = writeVersionItems(v, func(data []byte) (err error) {
+		crc.Write(data)
+		_, err = fp.Write(data)
+		return
+	}); err != nil {
+		return
+	}
+	crcBuf := make([]byte, versionFileCrcSize)
+	binary.BigEndian.PutUint32(crcBuf, crc.Sum32())
+	if _, err = fp.Write(crcBuf); err != nil {
+		return
+	}
+	if err = fp.Sync(); err != nil {
+		return
+	}
+	if err = fp.Close(); err != nil {
+		return
+	}
+	return os.Rename(tmpFile, filename)
+}
+
+// loadVersionFile reads the items of the version from its file.
+func loadVersionFile(rootDir string, v *metaVersion) (err error) {
+	data, err := ioutil.ReadFile(versionFilePath(rootDir, v.id))
+	if err != nil {
+		return
+	}
+	if len(data) < versionFileCrcSize {
+		return fmt.Errorf("truncated file of version %v", v.id)
+	}
+	items, crc := data[:len(data)-versionFileCrcSize], binary.BigEndian.Uint32(data[len(data)-versionFileCrcSize:])
+	if res := crc32.ChecksumIEEE(items); res != crc {
+		return fmt.Errorf("crc mismatch of version %v, expected[%d], actual[%d]", v.id, crc, res)
+	}
+	return applyVersionItems(v, items)
+}
+
+// removeStaleVersionFiles removes the files of the versions deleted, once the snapshot without them is stored.
+func removeStaleVersionFiles(rootDir string, versions *versionTable) (err error) {
+	if versions == nil {
+		versions = newVersionTable()
+	}
+	dir := path.Join(rootDir, snapshotVersionDir)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		id, parseErr := strconv.ParseUint(entry.Name(), 10, 64)
+		if parseErr == nil && versions.get(id) != nil {
+			continue
+		}
+		if err = os.Remove(path.Join(dir, entry.Name())); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -240,6 +240,128 @@ func (se *SortedExtents) Truncate(offset uint64, doOnLastKey func(*proto.ExtentK
 	return
 }
 
+// PunchHole removes the range [offset, offset+size) of the file from the extents, the keys overlapped
+// partially are split. It returns the ranges removed, and the extents to be deleted from the data nodes:
+// the tiny extents are deleted by the pages removed, while the normal extents are deleted only if they
+// are no longer referenced, since the data node deletes the normal extent as a whole.
+func (se *SortedExtents) PunchHole(offset, size uint64) (removed, deleteExtents []proto.ExtentKey) {
+	end := offset + size
+
+	se.Lock()
+	defer se.Unlock()
+
+	eks := make([]proto.ExtentKey, 0, len(se.eks)+1)
+	normals := make([]proto.ExtentKey, 0)
+	for _, key := range se.eks {
+		keyEnd := key.FileOffset + uint64(key.Size)
+		if keyEnd <= offset || key.FileOffset >= end {
+			eks = append(eks, key)
+			continue
+		}
+		if key.FileOffset < offset {
+			left := key
+			left.Size = uint32(offset - key.FileOffset)
+			eks = append(eks, left)
+		}
+		if keyEnd > end {
+			right := key
+			right.FileOffset = end
+			right.ExtentOffset = key.ExtentOffset + (end - key.FileOffset)
+			right.Size = uint32(keyEnd - end)
+			eks = append(eks, right)
+		}
+
+		holeStart, holeEnd := key.FileOffset, keyEnd
+		if holeStart < offset {
+			holeStart = offset
+		}
+		if holeEnd > end {
+			holeEnd = end
+		}
+		hole := key
+		hole.FileOffset = holeStart
+		hole.ExtentOffset = key.ExtentOffset + (holeStart - key.FileOffset)
+		hole.Size = uint32(holeEnd - holeStart)
+		removed = append(removed, hole)
+
+		if !storage.IsTinyExtent(key.ExtentId) {
+			normals = append(normals, key)
+			continue
+		}
+		// The pages of the tiny extent are shared with the neighbor keys, only the whole pages are deleted,
+		// except for the tail page which is padded for the key.
+		delStart := hole.ExtentOffset
+		delEnd := hole.ExtentOffset + uint64(hole.Size)
+		if delStart%storage.PageSize != 0 {
+			delStart += storage.PageSize - delStart%storage.PageSize
+		}
+		if holeEnd < keyEnd {
+			delEnd -= delEnd % storage.PageSize
+		} else if delEnd%storage.PageSize != 0 {
+			delEnd += storage.PageSize - delEnd%storage.PageSize
+		}
+		if delEnd > delStart {
+			del := hole
+			del.FileOffset += delStart - hole.ExtentOffset
+			del.ExtentOffset = delStart
+			del.Size = uint32(delEnd - delStart)
+			deleteExtents = append(deleteExtents, del)
+		}
+	}
+	se.eks = eks
+
+	type extentID struct {
+		partitionID uint64
+		extentID    uint64
+	}
+	referenced := make(map[extentID]struct{}, len(eks))
+	for _, ek := range eks {
+		referenced[extentID{ek.PartitionId, ek.ExtentId}] = struct{}{}
+	}
+	for _, key := range normals {
+		id := extentID{key.PartitionId, key.ExtentId}
+		if _, ok := referenced[id]; ok {
+			continue
+		}
+		deleteExtents = append(deleteExtents, key)
+		// the extent is deleted once
+		referenced[id] = struct{}{}
+	}
+	return
+}
+
+// CopyRange returns the keys of the range [offset, offset+size) of the file, the keys overlapped partially
+// are split, and the file offsets of the keys are moved to start at dstOffset.
+func (se *SortedExtents) CopyRange(offset, size, dstOffset uint64) (eks []proto.ExtentKey) {
+	end := offset + size
+
+	se.RLock()
+	defer se.RUnlock()
+
+	eks = make([]proto.ExtentKey, 0)
+	for _, key := range se.eks {
+		keyEnd := key.FileOffset + uint64(key.Size)
+		if keyEnd <= offset {
+			continue
+		}
+		if key.FileOffset >= end {
+			break
+		}
+		ek := key
+		if ek.FileOffset < offset {
+			ek.ExtentOffset += offset - ek.FileOffset
+			ek.FileOffset = offset
+		}
+		if keyEnd > end {
+			keyEnd = end
+		}
+		ek.Size = uint32(keyEnd - ek.FileOffset)
+		ek.FileOffset = ek.FileOffset - offset + dstOffset
+		eks = append(eks, ek)
+	}
+	return
+}
+
 func (se *SortedExtents) insert(ek proto.ExtentKey, startIdx int) {
 	se.eks = append(se.eks, ek)
 	size := len(se.eks)
@@ -137,3 +137,58 @@ func TestSortedMarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestPunchHole01(t *testing.T) {
+	se := NewSortedExtents()
+	se.AppendWithCheck(proto.ExtentKey{FileOffset: 0, Size: 1000, ExtentId: 1025}, nil)
+	se.AppendWithCheck(proto.ExtentKey{FileOffset: 1000, Size: 1000, ExtentId: 1026}, nil)
+	se.AppendWithCheck(proto.ExtentKey{FileOffset: 2000, Size: 1000, ExtentId: 1027}, nil)
+	// the middle of the extent is punched, which is still referenced by the both sides
+	removed, delExtents := se.PunchHole(200, 300)
+	t.Logf("\nremoved: %v\ndel: %v\neks: %v", removed, delExtents, se.eks)
+	if len(removed) != 1 || removed[0].Size != 300 || removed[0].ExtentOffset != 200 ||
+		len(delExtents) != 0 || len(se.eks) != 4 || se.eks[1].FileOffset != 500 ||
+		se.eks[1].ExtentOffset != 500 || se.eks[1].Size != 500 || se.Size() != 3000 {
+		t.Fail()
+	}
+	// the extents no longer referenced are deleted
+	removed, delExtents = se.PunchHole(0, 2500)
+	t.Logf("\nremoved: %v\ndel: %v\neks: %v", removed, delExtents, se.eks)
+	if len(removed) != 4 || len(delExtents) != 2 ||
+		delExtents[0].ExtentId != 1025 || delExtents[1].ExtentId != 1026 ||
+		len(se.eks) != 1 || se.eks[0].ExtentId != 1027 || se.eks[0].FileOffset != 2500 ||
+		se.eks[0].ExtentOffset != 500 {
+		t.Fail()
+	}
+}
+
+func TestPunchHole02(t *testing.T) {
+	se := NewSortedExtents()
+	se.AppendWithCheck(proto.ExtentKey{FileOffset: 0, Size: 10000, ExtentId: 1, ExtentOffset: 8192}, nil)
+	// only the whole pages of the tiny extent are deleted
+	removed, delExtents := se.PunchHole(1000, 8000)
+	t.Logf("\nremoved: %v\ndel: %v\neks: %v", removed, delExtents, se.eks)
+	if len(removed) != 1 || removed[0].Size != 8000 || len(delExtents) != 1 ||
+		delExtents[0].ExtentOffset != 12288 || delExtents[0].Size != 4096 || len(se.eks) != 2 {
+		t.Fail()
+	}
+	// the tail page padded for the key is deleted
+	removed, delExtents = se.PunchHole(0, 1000)
+	t.Logf("\nremoved: %v\ndel: %v\neks: %v", removed, delExtents, se.eks)
+	if len(removed) != 1 || len(delExtents) != 1 || delExtents[0].ExtentOffset != 8192 ||
+		delExtents[0].Size != 4096 || len(se.eks) != 1 || se.eks[0].FileOffset != 9000 {
+		t.Fail()
+	}
+}
+
+func TestCopyRange(t *testing.T) {
+	se := NewSortedExtents()
+	se.Append(proto.ExtentKey{FileOffset: 0, Size: 1000, ExtentId: 1025})
+	se.Append(proto.ExtentKey{FileOffset: 2000, Size: 1000, ExtentId: 1026, ExtentOffset: 100})
+	eks := se.CopyRange(500, 2000, 4096)
+	t.Logf("\neks: %v", eks)
+	if len(eks) != 2 || eks[0].FileOffset != 4096 || eks[0].ExtentOffset != 500 || eks[0].Size != 500 ||
+		eks[1].FileOffset != 5596 || eks[1].ExtentOffset != 100 || eks[1].Size != 500 {
+		t.Fail()
+	}
+}
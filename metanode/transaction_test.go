@@ -67,6 +67,9 @@ func newMetaPartition(PartitionId uint64, manager *metadataManager) (mp *metaPar
 		extReset:      make(chan struct{}),
 		vol:           NewVol(),
 		manager:       manager,
+		orphans:       newOrphanTable(),
+		changes:       newChangeNotifier(),
+		feed:          newChangeFeed(),
 	}
 	mp.config.Cursor = 1000
 	mp.config.End = 100000
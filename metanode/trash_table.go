@@ -0,0 +1,137 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"sort"
+	"sync"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// trashTable holds the entries of the files deleted into the trash of the volume, keyed by the inodes
+// of the partition. The inodes in the trash are kept linked, so that the data is not released until
+// the entries are purged.
+type trashTable struct {
+	sync.RWMutex
+	entries map[uint64]*proto.TrashEntry
+}
+
+func newTrashTable() *trashTable {
+	return &trashTable{
+		entries: make(map[uint64]*proto.TrashEntry),
+	}
+}
+
+func (t *trashTable) clone() *trashTable {
+	t.RLock()
+	defer t.RUnlock()
+	entries := make(map[uint64]*proto.TrashEntry, len(t.entries))
+	for ino, entry := range t.entries {
+		e := *entry
+		entries[ino] = &e
+	}
+	return &trashTable{entries: entries}
+}
+
+func (t *trashTable) len() int {
+	t.RLock()
+	defer t.RUnlock()
+	return len(t.entries)
+}
+
+func (t *trashTable) get(ino uint64) *proto.TrashEntry {
+	t.RLock()
+	defer t.RUnlock()
+	if entry, ok := t.entries[ino]; ok {
+		e := *entry
+		return &e
+	}
+	return nil
+}
+
+func (t *trashTable) add(entry *proto.TrashEntry) {
+	t.Lock()
+	defer t.Unlock()
+	t.entries[entry.Inode] = entry
+}
+
+func (t *trashTable) remove(ino uint64) *proto.TrashEntry {
+	t.Lock()
+	defer t.Unlock()
+	entry, ok := t.entries[ino]
+	if !ok {
+		return nil
+	}
+	delete(t.entries, ino)
+	return entry
+}
+
+// list returns the entries ordered by the deletion time.
+func (t *trashTable) list() []*proto.TrashEntry {
+	t.RLock()
+	entries := make([]*proto.TrashEntry, 0, len(t.entries))
+	for _, entry := range t.entries {
+		e := *entry
+		entries = append(entries, &e)
+	}
+	t.RUnlock()
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].DeleteTime != entries[j].DeleteTime {
+			return entries[i].DeleteTime < entries[j].DeleteTime
+		}
+		return entries[i].Inode < entries[j].Inode
+	})
+	return entries
+}
+
+// expired returns the inodes deleted before the deadline.
+func (t *trashTable) expired(deadline int64) (inodes []uint64) {
+	t.RLock()
+	defer t.RUnlock()
+	for ino, entry := range t.entries {
+		if entry.DeleteTime < deadline {
+			inodes = append(inodes, ino)
+		}
+	}
+	sort.Slice(inodes, func(i, j int) bool { return inodes[i] < inodes[j] })
+	return
+}
+
+func (t *trashTable) Marshal() (buf []byte, crc uint32, err error) {
+	entries := t.list()
+	if buf, err = json.Marshal(entries); err != nil {
+		return
+	}
+	crc = crc32.ChecksumIEEE(buf)
+	return
+}
+
+func (t *trashTable) UnMarshal(data []byte) (err error) {
+	list := make([]*proto.TrashEntry, 0)
+	if err = json.Unmarshal(data, &list); err != nil {
+		return
+	}
+	entries := make(map[uint64]*proto.TrashEntry, len(list))
+	for _, entry := range list {
+		entries[entry.Inode] = entry
+	}
+	t.Lock()
+	t.entries = entries
+	t.Unlock()
+	return
+}
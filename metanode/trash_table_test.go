@@ -0,0 +1,88 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metanode
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrashTable(t *testing.T) {
+	table := newTrashTable()
+	table.add(&proto.TrashEntry{Inode: 3, Name: "c", DeleteTime: 300})
+	table.add(&proto.TrashEntry{Inode: 1, Name: "a", DeleteTime: 100})
+	table.add(&proto.TrashEntry{Inode: 2, Name: "b", DeleteTime: 200})
+	require.Equal(t, 3, table.len())
+
+	list := table.list()
+	require.Equal(t, []uint64{1, 2, 3}, []uint64{list[0].Inode, list[1].Inode, list[2].Inode})
+	require.Equal(t, []uint64{1, 2}, table.expired(300))
+	require.Empty(t, table.expired(100))
+
+	data, _, err := table.Marshal()
+	require.NoError(t, err)
+	loaded := newTrashTable()
+	require.NoError(t, loaded.UnMarshal(data))
+	require.Equal(t, list, loaded.list())
+
+	cloned := table.clone()
+	require.Equal(t, "b", table.remove(2).Name)
+	require.Nil(t, table.remove(2))
+	require.Nil(t, table.get(2))
+	require.NotNil(t, cloned.get(2))
+}
+
+func TestTrashInodeFsm(t *testing.T) {
+	mp := NewMetaPartition(&MetaPartitionConfig{PartitionId: 1, VolName: "test_vol"}, nil).(*metaPartition)
+	mp.uidManager = NewUidMgr("test_vol", 1)
+
+	file := NewInode(10, proto.Mode(0644))
+	file.Size = 100
+	mp.inodeTree.ReplaceOrInsert(file, true)
+	linked := NewInode(11, proto.Mode(0644))
+	linked.IncNLink()
+	mp.inodeTree.ReplaceOrInsert(linked, true)
+
+	resp := mp.fsmTrashInode(&fsmTrashRequest{Inode: 10, ParentId: 1, Name: "f", Path: "/d/f", Now: 1000})
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.True(t, resp.Trashed)
+	require.Equal(t, uint32(1), file.GetNLink())
+	entry := mp.trash.get(10)
+	require.NotNil(t, entry)
+	require.Equal(t, "/d/f", entry.Path)
+	require.Equal(t, uint64(100), entry.Size)
+
+	// the inode linked by the other dentries is unlinked
+	resp = mp.fsmTrashInode(&fsmTrashRequest{Inode: 11, ParentId: 1, Name: "g", Now: 1000})
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.False(t, resp.Trashed)
+	require.Equal(t, uint32(1), linked.GetNLink())
+	require.Nil(t, mp.trash.get(11))
+
+	resp = mp.fsmRestoreTrash(&fsmTrashRequest{Inode: 10})
+	require.Equal(t, proto.OpOk, resp.Status)
+	require.Equal(t, "f", resp.Entry.Name)
+	resp = mp.fsmRestoreTrash(&fsmTrashRequest{Inode: 10})
+	require.Equal(t, proto.OpNotExistErr, resp.Status)
+
+	mp.fsmTrashInode(&fsmTrashRequest{Inode: 10, ParentId: 1, Name: "f", Now: 1000})
+	resp = mp.fsmPurgeTrash(&fsmTrashRequest{All: true})
+	require.Equal(t, 1, resp.Count)
+	require.Equal(t, 0, mp.trash.len())
+	require.Equal(t, uint32(0), file.GetNLink())
+	require.True(t, file.ShouldDelete())
+}
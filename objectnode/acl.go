@@ -131,6 +131,10 @@ func (g *Grant) isAllowed(reqId string, apiName proto.Action) bool {
 	}
 }
 
+func (g *Grant) isPublic() bool {
+	return g.Grantee.Type == TypeGroup && (g.Grantee.URI == GroupAllUser || g.Grantee.URI == GroupAuthenticated)
+}
+
 func (acp *AccessControlPolicy) IsValid() error {
 	if len(acp.Acl.Grants) == 0 {
 		return ErrMissingGrants
@@ -161,6 +165,27 @@ func (acp *AccessControlPolicy) IsAllowed(reqUid string, apiName proto.Action) b
 	return false
 }
 
+// IsPublic returns true if the acl grants any permission to the AllUsers or AuthenticatedUsers group.
+func (acp *AccessControlPolicy) IsPublic() bool {
+	for _, g := range acp.Acl.Grants {
+		if g.isPublic() {
+			return true
+		}
+	}
+	return false
+}
+
+// WithoutPublicGrants returns a copy of the acl which ignores the public grants.
+func (acp *AccessControlPolicy) WithoutPublicGrants() *AccessControlPolicy {
+	var ignored = &AccessControlPolicy{Xmlns: acp.Xmlns, Owner: acp.Owner}
+	for _, g := range acp.Acl.Grants {
+		if !g.isPublic() {
+			ignored.Acl.Grants = append(ignored.Acl.Grants, g)
+		}
+	}
+	return ignored
+}
+
 func (acp *AccessControlPolicy) IsEmpty() bool {
 	return len(acp.Acl.Grants) == 0
 }
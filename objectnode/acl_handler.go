@@ -101,6 +101,11 @@ func (o *ObjectNode) putBucketACLHandler(w http.ResponseWriter, r *http.Request)
 			GetRequestID(r), param.bucket, err)
 		return
 	}
+	if err = checkPublicACL(vol, acl); err != nil {
+		log.LogErrorf("putBucketACLHandler: public acl blocked: requestID(%v) volume(%v) acl(%+v) err(%v)",
+			GetRequestID(r), param.bucket, acl, err)
+		return
+	}
 	if err = putBucketACL(vol, acl); err != nil {
 		log.LogErrorf("putBucketACLHandler: put acl fail: requestID(%v) volume(%v) acl(%+v) err(%v)",
 			GetRequestID(r), param.bucket, acl, err)
@@ -209,6 +214,11 @@ func (o *ObjectNode) putObjectACLHandler(w http.ResponseWriter, r *http.Request)
 			GetRequestID(r), param.bucket, param.object, err)
 		return
 	}
+	if err = checkPublicACL(vol, acl); err != nil {
+		log.LogErrorf("putObjectACLHandler: public acl blocked: requestID(%v) volume(%v) path(%v) acl(%+v) err(%v)",
+			GetRequestID(r), param.bucket, param.object, acl, err)
+		return
+	}
 	if oldAcl != nil {
 		originalOwner := oldAcl.GetOwner()
 		if oldAcl.IsEmpty() {
@@ -117,6 +117,9 @@ func (o *ObjectNode) errorResponse(w http.ResponseWriter, r *http.Request, err e
 		if err == syscall.EPERM {
 			ec = FileDeleteLock
 		}
+		if err == proto.ErrObjectLocked {
+			ec = ObjectLocked
+		}
 		if ec1, ok := err.(*ErrorCode); ok && ec == nil {
 			ec = ec1
 		}
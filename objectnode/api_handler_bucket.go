@@ -111,6 +111,12 @@ func (o *ObjectNode) createBucketHandler(w http.ResponseWriter, r *http.Request)
 		log.LogErrorf("createBucketHandler: parse acl fail: requestID(%v) err(%v)", GetRequestID(r), err)
 		return
 	}
+	// Only the user level public access block applies to the bucket to be created.
+	if acl != nil && acl.IsPublic() && userInfo.PublicAccessBlock != nil && userInfo.PublicAccessBlock.BlockPublicAcls {
+		log.LogErrorf("createBucketHandler: public acl blocked: requestID(%v) volume(%v) acl(%+v)", GetRequestID(r), bucket, acl)
+		errorCode = AccessDenied
+		return
+	}
 
 	if err = o.mc.AdminAPI().CreateDefaultVolume(bucket, userInfo.UserID); err != nil {
 		log.LogErrorf("createBucketHandler: create bucket fail: requestID(%v) volume(%v) accessKey(%v) err(%v)",
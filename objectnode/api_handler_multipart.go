@@ -114,6 +114,32 @@ func (o *ObjectNode) createMultipleUploadHandler(w http.ResponseWriter, r *http.
 			GetRequestID(r), acl, err)
 		return
 	}
+	if err = checkPublicACL(vol, acl); err != nil {
+		log.LogErrorf("createMultipleUploadHandler: public acl blocked: requestID(%v) volume(%v) acl(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), acl, err)
+		return
+	}
+	// Check object lock
+	var objectLock *proto.ObjectLock
+	if objectLock, err = newObjectLock(vol, r.Header); err != nil {
+		log.LogErrorf("createMultipleUploadHandler: parse object lock fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	// Check server-side encryption
+	var encryption *SSEOption
+	if encryption, err = newSSEOption(vol, r.Header); err != nil {
+		log.LogErrorf("createMultipleUploadHandler: parse server-side encryption fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	// Check storage class
+	var storageClass string
+	if storageClass, err = parseStorageClass(vol, r.Header); err != nil {
+		log.LogErrorf("createMultipleUploadHandler: parse storage class fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
 	var opt = &PutFileOption{
 		MIMEType:     contentType,
 		Disposition:  contentDisposition,
@@ -122,6 +148,9 @@ func (o *ObjectNode) createMultipleUploadHandler(w http.ResponseWriter, r *http.
 		CacheControl: cacheControl,
 		Expires:      expires,
 		ACL:          acl,
+		ObjectLock:   objectLock,
+		Encryption:   encryption,
+		StorageClass: storageClass,
 	}
 
 	var uploadID string
@@ -147,6 +176,9 @@ func (o *ObjectNode) createMultipleUploadHandler(w http.ResponseWriter, r *http.
 	// set response header
 	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
 	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(bytes))}
+	if encryption != nil {
+		setSSEHeaders(w.Header(), &ObjectEncryption{Type: encryption.Type, KeyMD5: encryption.CustomerKeyMD5})
+	}
 	if _, err = w.Write(bytes); err != nil {
 		log.LogErrorf("createMultipleUploadHandler: write response body fail, requestID(%v) err(%v)",
 			GetRequestID(r), err)
@@ -212,8 +244,14 @@ func (o *ObjectNode) uploadPartHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The customer key is required to upload the parts of the objects encrypted with SSE-C.
+	var sse *SSEOption
+	if sse, errorCode = parseSSECustomerHeaders(r.Header, false); errorCode != nil {
+		return
+	}
+
 	var fsFileInfo *FSFileInfo
-	if fsFileInfo, err = vol.WritePart(param.Object(), uploadId, uint16(partNumberInt), r.Body); err != nil {
+	if fsFileInfo, err = vol.WritePart(param.Object(), uploadId, uint16(partNumberInt), r.Body, sse); err != nil {
 		err = handleWritePartErr(err)
 		log.LogErrorf("uploadPartHandler: write part fail: requestID(%v) volume(%v) path(%v) uploadId(%v) part(%v) err(%v)",
 			GetRequestID(r), vol.Name(), param.Object(), uploadId, partNumberInt, err)
@@ -231,6 +269,7 @@ func (o *ObjectNode) uploadPartHandler(w http.ResponseWriter, r *http.Request) {
 	// write header to response
 	w.Header()[HeaderNameContentLength] = []string{"0"}
 	w.Header()[HeaderNameETag] = []string{"\"" + fsFileInfo.ETag + "\""}
+	setSSEHeaders(w.Header(), fsFileInfo.Encryption)
 	return
 }
 
@@ -298,10 +337,23 @@ func (o *ObjectNode) uploadPartCopyHandler(w http.ResponseWriter, r *http.Reques
 		log.LogErrorf("partCopyHandler: get fileMeta fail: requestId(%v) srcVol(%v) path(%v) err(%v)", GetRequestID(r), srcBucket, srcObject, err)
 		return
 	}
+	if errorCode = checkObjectRestored(srcFileInfo); errorCode != nil {
+		return
+	}
 	errorCode = CheckConditionInHeader(r, srcFileInfo)
 	if errorCode != nil {
 		return
 	}
+	var srcDataKey []byte
+	if srcDataKey, err = objectDataKey(srcFileInfo, r.Header, true); err != nil {
+		log.LogErrorf("partCopyHandler: open source data key fail: requestId(%v) srcVol(%v) path(%v) err(%v)",
+			GetRequestID(r), srcBucket, srcObject, err)
+		return
+	}
+	var sse *SSEOption
+	if sse, errorCode = parseSSECustomerHeaders(r.Header, false); errorCode != nil {
+		return
+	}
 
 	//step4: extract range params
 	copyRange := r.Header.Get(HeaderNameXAmzCopyRange)
@@ -309,10 +361,6 @@ func (o *ObjectNode) uploadPartCopyHandler(w http.ResponseWriter, r *http.Reques
 	if errorCode != nil {
 		return
 	}
-	size, err := safeConvertInt64ToUint64(srcFileInfo.Size)
-	if err != nil {
-		return
-	}
 	fb, err := safeConvertInt64ToUint64(firstByte)
 	if err != nil {
 		return
@@ -321,19 +369,22 @@ func (o *ObjectNode) uploadPartCopyHandler(w http.ResponseWriter, r *http.Reques
 	if err != nil {
 		return
 	}
-	reader, writer := io.Pipe()
-	go func() {
-		err = srcVol.readFile(srcFileInfo.Inode, size, srcObject, writer, fb, cl)
-		if err != nil {
-			log.LogErrorf("partCopyHandler: read srcObj err(%v): requestId(%v) srcVol(%v) path(%v)",
-				err, GetRequestID(r), srcBucket, srcObject)
-		}
-		writer.CloseWithError(err)
-	}()
 
-	// step5: upload part by copy
+	// step5: upload part by clone, or by copy if the part can not be cloned
 	var fsFileInfo *FSFileInfo
-	fsFileInfo, err = vol.WritePart(param.Object(), uploadId, uint16(partNumberInt), reader)
+	fsFileInfo, err = vol.ClonePart(param.Object(), uploadId, partNumberInt, srcVol, srcFileInfo.Inode, fb, cl, sse)
+	if err == syscall.EXDEV {
+		reader, writer := io.Pipe()
+		go func() {
+			readErr := srcVol.readObject(srcFileInfo, srcDataKey, srcObject, writer, fb, cl)
+			if readErr != nil {
+				log.LogErrorf("partCopyHandler: read srcObj err(%v): requestId(%v) srcVol(%v) path(%v)",
+					readErr, GetRequestID(r), srcBucket, srcObject)
+			}
+			writer.CloseWithError(readErr)
+		}()
+		fsFileInfo, err = vol.WritePart(param.Object(), uploadId, uint16(partNumberInt), reader, sse)
+	}
 	if err != nil {
 		err = handleWritePartErr(err)
 		log.LogErrorf("partCopyHandler: write part fail: requestID(%v) volume(%v) path(%v) uploadId(%v) part(%v) err(%v)",
@@ -344,6 +395,7 @@ func (o *ObjectNode) uploadPartCopyHandler(w http.ResponseWriter, r *http.Reques
 		GetRequestID(r), vol.Name(), param.Object(), uploadId, partNumberInt, fsFileInfo)
 	Etag := "\"" + fsFileInfo.ETag + "\""
 	w.Header()[HeaderNameETag] = []string{Etag}
+	setSSEHeaders(w.Header(), fsFileInfo.Encryption)
 	cpr := NewS3CopyPartResult(Etag, fsFileInfo.CreateTime.UTC().Format(time.RFC3339))
 	w.Write([]byte(cpr.String()))
 	return
@@ -659,6 +711,8 @@ func (o *ObjectNode) completeMultipartUploadHandler(w http.ResponseWriter, r *ht
 	}
 	log.LogDebugf("completeMultipartUploadHandler: complete multipart: requestID(%v) volume(%v) key(%v) uploadID(%v) fileInfo(%v)",
 		GetRequestID(r), param.Bucket(), param.Object(), uploadId, fsFileInfo)
+	o.replicateObject(r, vol, param.Object(), fsFileInfo)
+	o.notifyObjectCreated(r, vol, EventObjectCreatedCompleteMultipartUpload, param.Object(), fsFileInfo)
 
 	// write response
 	completeResult := CompleteMultipartResult{
@@ -677,6 +731,10 @@ func (o *ObjectNode) completeMultipartUploadHandler(w http.ResponseWriter, r *ht
 	// set response header
 	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
 	w.Header()[HeaderNameContentLength] = []string{strconv.Itoa(len(bytes))}
+	if len(fsFileInfo.VersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{fsFileInfo.VersionId}
+	}
+	setSSEHeaders(w.Header(), fsFileInfo.Encryption)
 	if _, err = w.Write(bytes); err != nil {
 		log.LogErrorf("completeMultipartUploadHandler: write response body fail, requestID(%v) err(%v)", GetRequestID(r), err)
 		return
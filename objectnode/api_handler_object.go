@@ -147,15 +147,26 @@ func (o *ObjectNode) getObjectHandler(w http.ResponseWriter, r *http.Request) {
 	var fileInfo *FSFileInfo
 	var xattr *proto.XAttrInfo
 	var start = time.Now()
-	fileInfo, xattr, err = vol.ObjectMeta(param.Object())
+	var versionId = r.URL.Query().Get(ParamVersionId)
+	fileInfo, xattr, err = vol.ObjectVersionMeta(param.Object(), versionId)
 	log.LogDebugf("getObjectHandler: get object meta cost: %v", time.Since(start))
 	if err == syscall.ENOENT {
 		errorCode = NoSuchKey
+		if versionId != "" {
+			errorCode = NoSuchVersion
+		}
 		return
 	}
 	if err != nil {
-		log.LogErrorf("getObjectHandler: get file meta fail: requestId(%v) volume(%v) path(%v) err(%v)",
-			GetRequestID(r), vol.Name(), param.Object(), err)
+		log.LogErrorf("getObjectHandler: get file meta fail: requestId(%v) volume(%v) path(%v) versionId(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), versionId, err)
+		return
+	}
+	if errorCode = checkDeleteMarker(w, fileInfo, versionId); errorCode != nil {
+		return
+	}
+	// The archived object must be restored before being read.
+	if errorCode = checkObjectRestored(fileInfo); errorCode != nil {
 		return
 	}
 
@@ -164,6 +175,13 @@ func (o *ObjectNode) getObjectHandler(w http.ResponseWriter, r *http.Request) {
 	if errorCode != nil {
 		return
 	}
+	// The customer key is required to read the objects encrypted with SSE-C.
+	var dataKey []byte
+	if dataKey, err = objectDataKey(fileInfo, r.Header, false); err != nil {
+		log.LogErrorf("getObjectHandler: open data key fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), err)
+		return
+	}
 
 	// validate and fix range
 	if isRangeRead && rangeUpper > uint64(fileInfo.Size)-1 {
@@ -189,6 +207,15 @@ func (o *ObjectNode) getObjectHandler(w http.ResponseWriter, r *http.Request) {
 	// set response header for GetObject
 	w.Header()[HeaderNameAcceptRange] = []string{HeaderValueAcceptRange}
 	w.Header()[HeaderNameLastModified] = []string{formatTimeRFC1123(fileInfo.ModifyTime)}
+	if len(fileInfo.VersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{fileInfo.VersionId}
+	}
+	setObjectLockHeaders(w.Header(), fileInfo.ObjectLock)
+	setSSEHeaders(w.Header(), fileInfo.Encryption)
+	if len(fileInfo.ReplicationStatus) > 0 {
+		w.Header()[HeaderNameXAmzReplicationStatus] = []string{fileInfo.ReplicationStatus}
+	}
+	setStorageClassHeaders(w.Header(), fileInfo)
 	if len(responseContentType) > 0 {
 		w.Header()[HeaderNameContentType] = []string{responseContentType}
 	} else if len(fileInfo.MIMEType) > 0 {
@@ -260,7 +287,6 @@ func (o *ObjectNode) getObjectHandler(w http.ResponseWriter, r *http.Request) {
 	// get object content
 	var offset = rangeLower
 	size, err := safeConvertInt64ToUint64(fileInfo.Size)
-	fileSize := size
 	if err != nil {
 		return
 	}
@@ -270,7 +296,7 @@ func (o *ObjectNode) getObjectHandler(w http.ResponseWriter, r *http.Request) {
 	if isRangeRead {
 		w.WriteHeader(http.StatusPartialContent)
 	}
-	err = vol.readFile(fileInfo.Inode, fileSize, param.Object(), w, offset, size)
+	err = vol.readObject(fileInfo, dataKey, param.Object(), w, offset, size)
 	if err == syscall.ENOENT {
 		errorCode = NoSuchKey
 		return
@@ -373,16 +399,23 @@ func (o *ObjectNode) headObjectHandler(w http.ResponseWriter, r *http.Request) {
 
 	// get object meta
 	var fileInfo *FSFileInfo
-	fileInfo, _, err = vol.ObjectMeta(param.Object())
+	var versionId = r.URL.Query().Get(ParamVersionId)
+	fileInfo, _, err = vol.ObjectVersionMeta(param.Object(), versionId)
 	if err != nil {
-		log.LogErrorf("headObjectHandler: get file meta fail: requestId(%v) volume(%v) path(%v) err(%v)",
-			GetRequestID(r), vol.Name(), param.Object(), err)
+		log.LogErrorf("headObjectHandler: get file meta fail: requestId(%v) volume(%v) path(%v) versionId(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), versionId, err)
 		if err == syscall.ENOENT {
 			errorCode = NoSuchKey
+			if versionId != "" {
+				errorCode = NoSuchVersion
+			}
 			return
 		}
 		return
 	}
+	if errorCode = checkDeleteMarker(w, fileInfo, versionId); errorCode != nil {
+		return
+	}
 
 	// parse request header
 	match := r.Header.Get(HeaderNameIfMatch)
@@ -443,10 +476,26 @@ func (o *ObjectNode) headObjectHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// The customer key is required to read the metadata of the objects encrypted with SSE-C.
+	if _, err = objectDataKey(fileInfo, r.Header, false); err != nil {
+		log.LogErrorf("headObjectHandler: open data key fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), err)
+		return
+	}
+
 	// set response header
 	w.Header()[HeaderNameAcceptRange] = []string{HeaderValueAcceptRange}
 	w.Header()[HeaderNameLastModified] = []string{formatTimeRFC1123(fileInfo.ModifyTime)}
 	w.Header()[HeaderNameContentMD5] = []string{EmptyContentMD5String}
+	if len(fileInfo.VersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{fileInfo.VersionId}
+	}
+	setObjectLockHeaders(w.Header(), fileInfo.ObjectLock)
+	setSSEHeaders(w.Header(), fileInfo.Encryption)
+	if len(fileInfo.ReplicationStatus) > 0 {
+		w.Header()[HeaderNameXAmzReplicationStatus] = []string{fileInfo.ReplicationStatus}
+	}
+	setStorageClassHeaders(w.Header(), fileInfo)
 	if len(fileInfo.MIMEType) > 0 {
 		w.Header()[HeaderNameContentType] = []string{fileInfo.MIMEType}
 	} else {
@@ -568,7 +617,7 @@ func (o *ObjectNode) deleteObjectsHandler(w http.ResponseWriter, r *http.Request
 		return deleteReq.Objects[i].Key > deleteReq.Objects[j].Key
 	})
 
-	vol, acl, policy, err := o.loadBucketMeta(param.Bucket())
+	vol, acl, policy, block, err := o.loadBucketMeta(param.Bucket())
 	if err != nil {
 		log.LogErrorf("deleteObjectsHandler: load bucket metadata fail: requestID(%v) volume(%v) err(%v)",
 			GetRequestID(r), param.Bucket(), err)
@@ -582,6 +631,9 @@ func (o *ObjectNode) deleteObjectsHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if acl != nil && block.IgnorePublicAcls {
+		acl = acl.WithoutPublicGrants()
+	}
 	allowByAcl := false
 	if acl == nil && userInfo.UserID == vol.owner {
 		allowByAcl = true
@@ -590,6 +642,7 @@ func (o *ObjectNode) deleteObjectsHandler(w http.ResponseWriter, r *http.Request
 		allowByAcl = true
 	}
 
+	bypassGovernance := isBypassGovernanceRetention(r.Header) && userInfo.UserID == vol.owner
 	deletedObjects := make([]Deleted, 0, len(deleteReq.Objects))
 	deletedErrors := make([]Error, 0)
 	objectKeys := make([]string, 0, len(deleteReq.Objects))
@@ -605,6 +658,9 @@ func (o *ObjectNode) deleteObjectsHandler(w http.ResponseWriter, r *http.Request
 				HOST:     param.r.Host,
 			}
 			result = policy.IsAllowed(param, userInfo.UserID, vol.owner, conditionCheck)
+			if result == POLICY_ALLOW && userInfo.UserID != vol.owner && block.RestrictPublicBuckets && policy.IsPublic() {
+				result = POLICY_UNKNOW
+			}
 		}
 		if result == POLICY_DENY || (result == POLICY_UNKNOW && !allowByAcl) {
 			deletedErrors = append(deletedErrors, Error{
@@ -617,14 +673,28 @@ func (o *ObjectNode) deleteObjectsHandler(w http.ResponseWriter, r *http.Request
 		objectKeys = append(objectKeys, object.Key)
 		log.LogWarnf("deleteObjectsHandler: delete path: requestID(%v) remote(%v) volume(%v) path(%v)",
 			GetRequestID(r), getRequestIP(r), vol.Name(), object.Key)
-		if err = vol.DeletePath(object.Key); err != nil {
-			log.LogErrorf("deleteObjectsHandler: delete object failed: requestID(%v) volume(%v) path(%v) err(%v)",
-				GetRequestID(r), vol.Name(), object.Key, err)
-			deletedErrors = append(deletedErrors, Error{Key: object.Key, Code: "InternalError", Message: err.Error()})
+		var objResult *DeleteObjectResult
+		if objResult, err = vol.DeleteObject(object.Key, object.VersionId, bypassGovernance); err != nil {
+			log.LogErrorf("deleteObjectsHandler: delete object failed: requestID(%v) volume(%v) path(%v) versionId(%v) err(%v)",
+				GetRequestID(r), vol.Name(), object.Key, object.VersionId, err)
+			if err == proto.ErrObjectLocked {
+				deletedErrors = append(deletedErrors, Error{Key: object.Key, Code: ObjectLocked.ErrorCode, Message: ObjectLocked.ErrorMessage})
+			} else {
+				deletedErrors = append(deletedErrors, Error{Key: object.Key, Code: "InternalError", Message: err.Error()})
+			}
 		} else {
-			log.LogDebugf("deleteObjectsHandler: delete object success: requestID(%v) volume(%v) path(%v)",
-				GetRequestID(r), vol.Name(), object.Key)
-			deletedObjects = append(deletedObjects, Deleted{Key: object.Key})
+			log.LogDebugf("deleteObjectsHandler: delete object success: requestID(%v) volume(%v) path(%v) versionId(%v)",
+				GetRequestID(r), vol.Name(), object.Key, object.VersionId)
+			deleted := Deleted{Key: object.Key, VersionId: object.VersionId}
+			if objResult.DeleteMarker {
+				deleted.DeleteMarker = "true"
+				deleted.DeleteMarkerVersionId = objResult.VersionId
+			}
+			if objResult.DeleteMarker && object.VersionId == "" {
+				o.replicateDeleteMarker(r, vol, object.Key, objResult.VersionId)
+			}
+			o.notifyObjectRemoved(r, vol, object.Key, objResult.VersionId, objResult.DeleteMarker && object.VersionId == "")
+			deletedObjects = append(deletedObjects, deleted)
 		}
 	}
 
@@ -750,6 +820,11 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 			GetRequestID(r), param.Bucket(), acl, err)
 		return
 	}
+	if err = checkPublicACL(vol, acl); err != nil {
+		log.LogErrorf("copyObjectHandler: public acl blocked: requestID(%v) volume(%v) acl(%+v) err(%v)",
+			GetRequestID(r), param.Bucket(), acl, err)
+		return
+	}
 
 	// get src object meta
 	var sourceVol *Volume
@@ -769,6 +844,9 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if errorCode = checkObjectRestored(fileInfo); errorCode != nil {
+		return
+	}
 
 	// get header
 	copyMatch := r.Header.Get(HeaderNameXAmzCopyMatch)
@@ -818,14 +896,42 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 
 	// parse user-defined metadata
 	metadata := ParseUserDefinedMetadata(r.Header)
+	// parse object lock
+	var objectLock *proto.ObjectLock
+	if objectLock, err = newObjectLock(vol, r.Header); err != nil {
+		log.LogErrorf("copyObjectHandler: parse object lock fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	// parse server-side encryption of the target and the customer key of the source
+	var encryption, sourceEncryption *SSEOption
+	if encryption, err = newSSEOption(vol, r.Header); err != nil {
+		log.LogErrorf("copyObjectHandler: parse server-side encryption fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if sourceEncryption, errorCode = parseSSECustomerHeaders(r.Header, true); errorCode != nil {
+		return
+	}
+	// parse storage class, which is not copied from the source
+	var storageClass string
+	if storageClass, err = parseStorageClass(vol, r.Header); err != nil {
+		log.LogErrorf("copyObjectHandler: parse storage class fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
 	// copy file
 	opt := &PutFileOption{
-		MIMEType:     contentType,
-		Disposition:  contentDisposition,
-		Metadata:     metadata,
-		CacheControl: cacheControl,
-		Expires:      expires,
-		ACL:          acl,
+		MIMEType:         contentType,
+		Disposition:      contentDisposition,
+		Metadata:         metadata,
+		CacheControl:     cacheControl,
+		Expires:          expires,
+		ACL:              acl,
+		ObjectLock:       objectLock,
+		Encryption:       encryption,
+		SourceEncryption: sourceEncryption,
+		StorageClass:     storageClass,
 	}
 	fsFileInfo, err := vol.CopyFile(sourceVol, sourceObject, param.Object(), metadataDirective, opt)
 	if err != nil && err != syscall.EINVAL && err != syscall.EFBIG {
@@ -845,11 +951,17 @@ func (o *ObjectNode) copyObjectHandler(w http.ResponseWriter, r *http.Request) {
 		errorCode = CopySourceSizeTooLarge
 		return
 	}
+	o.replicateObject(r, vol, param.Object(), fsFileInfo)
+	o.notifyObjectCreated(r, vol, EventObjectCreatedCopy, param.Object(), fsFileInfo)
 
 	copyResult := CopyResult{
 		ETag:         "\"" + fsFileInfo.ETag + "\"",
 		LastModified: formatTimeISO(fsFileInfo.ModifyTime),
 	}
+	if len(fsFileInfo.VersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{fsFileInfo.VersionId}
+	}
+	setSSEHeaders(w.Header(), fsFileInfo.Encryption)
 
 	var bytes []byte
 	if bytes, err = MarshalXMLEntity(copyResult); err != nil {
@@ -959,7 +1071,7 @@ func (o *ObjectNode) getBucketV1Handler(w http.ResponseWriter, r *http.Request)
 			LastModified: formatTimeISO(file.ModifyTime),
 			ETag:         wrapUnescapedQuot(file.ETag),
 			Size:         int(file.Size),
-			StorageClass: StorageClassStandard,
+			StorageClass: file.StorageClass,
 			Owner:        bucketOwner,
 		}
 		contents = append(contents, content)
@@ -1122,7 +1234,7 @@ func (o *ObjectNode) getBucketV2Handler(w http.ResponseWriter, r *http.Request)
 				LastModified: formatTimeISO(file.ModifyTime),
 				ETag:         wrapUnescapedQuot(file.ETag),
 				Size:         int(file.Size),
-				StorageClass: StorageClassStandard,
+				StorageClass: file.StorageClass,
 				Owner:        bucketOwner,
 			}
 			contents = append(contents, content)
@@ -1226,6 +1338,11 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 			GetRequestID(r), vol.Name(), param.Object(), acl, err)
 		return
 	}
+	if err = checkPublicACL(vol, acl); err != nil {
+		log.LogErrorf("putObjectHandler: public acl blocked: requestID(%v) volume(%v) path(%v) acl(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), acl, err)
+		return
+	}
 
 	// Get request MD5, if request MD5 is not empty, compute and verify it.
 	requestMD5 := r.Header.Get(HeaderNameContentMD5)
@@ -1258,6 +1375,27 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	// Checking user-defined metadata
 	metadata := ParseUserDefinedMetadata(r.Header)
+	// Checking object lock
+	var objectLock *proto.ObjectLock
+	if objectLock, err = newObjectLock(vol, r.Header); err != nil {
+		log.LogErrorf("putObjectHandler: parse object lock fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	// Checking server-side encryption
+	var encryption *SSEOption
+	if encryption, err = newSSEOption(vol, r.Header); err != nil {
+		log.LogErrorf("putObjectHandler: parse server-side encryption fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	// Checking storage class
+	var storageClass string
+	if storageClass, err = parseStorageClass(vol, r.Header); err != nil {
+		log.LogErrorf("putObjectHandler: parse storage class fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
 	// Audit file write
 	log.LogInfof("Audit: put object: requestID(%v) remote(%v) volume(%v) path(%v) type(%v)",
 		GetRequestID(r), getRequestIP(r), vol.Name(), param.Object(), contentType)
@@ -1271,6 +1409,9 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 		CacheControl: cacheControl,
 		Expires:      expires,
 		ACL:          acl,
+		ObjectLock:   objectLock,
+		Encryption:   encryption,
+		StorageClass: storageClass,
 	}
 	var startPut = time.Now()
 	if fsFileInfo, err = vol.PutObject(param.Object(), r.Body, opt); err != nil {
@@ -1295,13 +1436,233 @@ func (o *ObjectNode) putObjectHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.LogDebugf("PutObject succeed, requestID(%v) volume(%v) key(%v) costTime: %v", GetRequestID(r),
 		vol.Name(), param.Object(), time.Since(startPut))
+	o.replicateObject(r, vol, param.Object(), fsFileInfo)
+	o.notifyObjectCreated(r, vol, EventObjectCreatedPut, param.Object(), fsFileInfo)
 
 	// set response header
 	w.Header()[HeaderNameETag] = []string{wrapUnescapedQuot(fsFileInfo.ETag)}
+	if len(fsFileInfo.VersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{fsFileInfo.VersionId}
+	}
+	setSSEHeaders(w.Header(), fsFileInfo.Encryption)
 	w.Header()[HeaderNameContentLength] = []string{"0"}
 	return
 }
 
+// Post object
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectPOST.html
+func (o *ObjectNode) postObjectHandler(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var errorCode *ErrorCode
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var form *postForm
+	if form, err = parsePostForm(r); err != nil {
+		log.LogErrorf("postObjectHandler: parse post form fail: requestID(%v) err(%v)", GetRequestID(r), err)
+		return
+	}
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	if param.Object() == "" {
+		errorCode = MissingPostKey
+		return
+	}
+	if len(param.Object()) > MaxKeyLength {
+		errorCode = KeyTooLong
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("postObjectHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	// Check the policy, which is required if the form is signed
+	if encoded := form.fields.Get(PostFormFieldPolicy); encoded != "" {
+		var policy *PostPolicy
+		if policy, err = parsePostPolicy(encoded); err != nil {
+			log.LogErrorf("postObjectHandler: parse policy fail: requestID(%v) volume(%v) policy(%v) err(%v)",
+				GetRequestID(r), vol.Name(), encoded, err)
+			return
+		}
+		if errorCode = policy.match(vol.Name(), form.fields); errorCode != nil {
+			log.LogErrorf("postObjectHandler: policy not matched: requestID(%v) volume(%v) path(%v) fields(%v) err(%v)",
+				GetRequestID(r), vol.Name(), param.Object(), form.fields, errorCode)
+			return
+		}
+		form.minSize, form.maxSize = policy.MinSize, policy.MaxSize
+	} else if !isAnonymous(param.AccessKey()) {
+		errorCode = MissingPostPolicy
+		return
+	}
+
+	var userInfo *proto.UserInfo
+	if userInfo, err = o.getUserInfoByAccessKeyV2(param.AccessKey()); err != nil {
+		log.LogErrorf("postObjectHandler: get user info fail: requestID(%v) volume(%v) accessKey(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), param.AccessKey(), err)
+		return
+	}
+
+	// The form fields describing the object are used in the same way as the headers of PutObject.
+	form.setHeaders(r.Header)
+
+	// Check 'tagging' field, which is the tag set in XML
+	var tagging *Tagging
+	if taggingXML := form.fields.Get(PostFormFieldTagging); taggingXML != "" {
+		tagging = NewTagging()
+		if err = xml.Unmarshal([]byte(taggingXML), tagging); err != nil {
+			log.LogErrorf("postObjectHandler: decode tagging fail: requestID(%v) volume(%v) tagging(%v) err(%v)",
+				GetRequestID(r), vol.Name(), taggingXML, err)
+			errorCode = MalformedXML
+			return
+		}
+		var validateRes bool
+		if validateRes, errorCode = tagging.Validate(); !validateRes {
+			log.LogErrorf("postObjectHandler: tagging validate fail: requestID(%v) volume(%v) path(%v) tagging(%v) err(%v)",
+				GetRequestID(r), vol.Name(), param.Object(), tagging, errorCode)
+			return
+		}
+	}
+
+	// Check ACL
+	acl, err := ParseACL(r, userInfo.UserID, false, vol.GetOwner() != userInfo.UserID)
+	if err != nil {
+		log.LogErrorf("postObjectHandler: parse acl fail: requestID(%v) volume(%v) path(%v) acl(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), acl, err)
+		return
+	}
+	if err = checkPublicACL(vol, acl); err != nil {
+		log.LogErrorf("postObjectHandler: public acl blocked: requestID(%v) volume(%v) path(%v) acl(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), acl, err)
+		return
+	}
+
+	contentType := r.Header.Get(HeaderNameContentType)
+	cacheControl := r.Header.Get(HeaderNameCacheControl)
+	if len(cacheControl) > 0 && !ValidateCacheControl(cacheControl) {
+		errorCode = InvalidCacheArgument
+		return
+	}
+	expires := r.Header.Get(HeaderNameExpires)
+	if len(expires) > 0 && !ValidateCacheExpires(expires) {
+		errorCode = InvalidCacheArgument
+		return
+	}
+	var objectLock *proto.ObjectLock
+	if objectLock, err = newObjectLock(vol, r.Header); err != nil {
+		log.LogErrorf("postObjectHandler: parse object lock fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	var encryption *SSEOption
+	if encryption, err = newSSEOption(vol, r.Header); err != nil {
+		log.LogErrorf("postObjectHandler: parse server-side encryption fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	var storageClass string
+	if storageClass, err = parseStorageClass(vol, r.Header); err != nil {
+		log.LogErrorf("postObjectHandler: parse storage class fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	// Audit file write
+	log.LogInfof("Audit: post object: requestID(%v) remote(%v) volume(%v) path(%v) type(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), param.Object(), contentType)
+
+	var fsFileInfo *FSFileInfo
+	var opt = &PutFileOption{
+		MIMEType:     contentType,
+		Disposition:  r.Header.Get(HeaderNameContentDisposition),
+		Tagging:      tagging,
+		Metadata:     ParseUserDefinedMetadata(r.Header),
+		CacheControl: cacheControl,
+		Expires:      expires,
+		ACL:          acl,
+		ObjectLock:   objectLock,
+		Encryption:   encryption,
+		StorageClass: storageClass,
+	}
+	if fsFileInfo, err = vol.PutObject(param.Object(), form, opt); err != nil {
+		log.LogErrorf("postObjectHandler: put object fail: requestId(%v) volume(%v) path(%v) remote(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), getRequestIP(r), err)
+		if err == syscall.EINVAL {
+			errorCode = ObjectModeConflict
+			return
+		}
+		if err == io.ErrUnexpectedEOF {
+			errorCode = EntityTooSmall
+			return
+		}
+		return
+	}
+	o.replicateObject(r, vol, param.Object(), fsFileInfo)
+	o.notifyObjectCreated(r, vol, EventObjectCreatedPost, param.Object(), fsFileInfo)
+
+	// set response header
+	var etag = wrapUnescapedQuot(fsFileInfo.ETag)
+	var scheme = "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	var location = (&url.URL{
+		Scheme: scheme,
+		Host:   r.Host,
+		Path:   strings.TrimSuffix(r.URL.Path, "/") + "/" + param.Object(),
+	}).String()
+	w.Header()[HeaderNameETag] = []string{etag}
+	w.Header()[HeaderNameLocation] = []string{location}
+	if len(fsFileInfo.VersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{fsFileInfo.VersionId}
+	}
+	setSSEHeaders(w.Header(), fsFileInfo.Encryption)
+
+	// The client is redirected to the specified URL with the bucket, key and etag in the query.
+	var redirect = form.fields.Get(PostFormFieldSuccessActionRedirect)
+	if redirect == "" {
+		redirect = form.fields.Get(PostFormFieldRedirect)
+	}
+	if redirectURL, parseErr := url.Parse(redirect); redirect != "" && parseErr == nil && redirectURL.IsAbs() {
+		var query = redirectURL.Query()
+		query.Set(PostFormFieldBucket, param.Bucket())
+		query.Set(PostFormFieldKey, param.Object())
+		query.Set("etag", etag)
+		redirectURL.RawQuery = query.Encode()
+		w.Header()[HeaderNameLocation] = []string{redirectURL.String()}
+		w.WriteHeader(http.StatusSeeOther)
+		return
+	}
+	switch form.fields.Get(PostFormFieldSuccessActionStatus) {
+	case strconv.Itoa(http.StatusOK):
+		w.WriteHeader(http.StatusOK)
+	case strconv.Itoa(http.StatusCreated):
+		var data []byte
+		if data, err = MarshalXMLEntity(&PostResponse{
+			Location: location,
+			Bucket:   param.Bucket(),
+			Key:      param.Object(),
+			ETag:     etag,
+		}); err != nil {
+			log.LogErrorf("postObjectHandler: xml marshal result fail: requestID(%v) err(%v)", GetRequestID(r), err)
+			return
+		}
+		w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+		w.WriteHeader(http.StatusCreated)
+		if _, err = w.Write(data); err != nil {
+			log.LogErrorf("postObjectHandler: write response body fail: requestID(%v) err(%v)", GetRequestID(r), err)
+		}
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return
+}
+
 // Delete object
 // API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteObject.html .
 func (o *ObjectNode) deleteObjectHandler(w http.ResponseWriter, r *http.Request) {
@@ -1330,17 +1691,30 @@ func (o *ObjectNode) deleteObjectHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	var versionId = r.URL.Query().Get(ParamVersionId)
+
 	// Audit deletion
-	log.LogInfof("Audit: delete object: requestID(%v) remote(%v) volume(%v) path(%v)",
-		GetRequestID(r), getRequestIP(r), vol.Name(), param.Object())
+	log.LogInfof("Audit: delete object: requestID(%v) remote(%v) volume(%v) path(%v) versionId(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), param.Object(), versionId)
 
-	err = vol.DeletePath(param.Object())
+	var result *DeleteObjectResult
+	result, err = vol.DeleteObject(param.Object(), versionId, o.isBypassGovernanceAllowed(r, param, vol))
 	if err != nil {
 		log.LogErrorf("deleteObjectHandler: Volume delete file fail: "+
-			"requestID(%v) volume(%v) path(%v) err(%v)", GetRequestID(r), vol.Name(), param.Object(), err)
+			"requestID(%v) volume(%v) path(%v) versionId(%v) err(%v)", GetRequestID(r), vol.Name(), param.Object(), versionId, err)
 		return
 	}
 
+	if len(result.VersionId) > 0 {
+		w.Header()[HeaderNameXAmzVersionId] = []string{result.VersionId}
+	}
+	if result.DeleteMarker {
+		w.Header()[HeaderNameXAmzDeleteMarker] = []string{"true"}
+	}
+	if result.DeleteMarker && versionId == "" {
+		o.replicateDeleteMarker(r, vol, param.Object(), result.VersionId)
+	}
+	o.notifyObjectRemoved(r, vol, param.Object(), result.VersionId, result.DeleteMarker && versionId == "")
 	w.WriteHeader(http.StatusNoContent)
 	return
 }
@@ -1532,6 +1906,11 @@ func (o *ObjectNode) putObjectXAttrHandler(w http.ResponseWriter, r *http.Reques
 	if len(key) == 0 {
 		return
 	}
+	// The object lock can only be changed by the object retention and legal hold APIs.
+	if proto.IsObjectLockXAttrKey(key) {
+		errorCode = AccessDenied
+		return
+	}
 
 	if err = vol.SetXAttr(param.object, key, []byte(value), true); err != nil {
 		if err == syscall.ENOENT {
@@ -1628,6 +2007,10 @@ func (o *ObjectNode) deleteObjectXAttrHandler(w http.ResponseWriter, r *http.Req
 		errorCode = InvalidArgument
 		return
 	}
+	if proto.IsObjectLockXAttrKey(xattrKey) {
+		errorCode = AccessDenied
+		return
+	}
 
 	if err = vol.DeleteXAttr(param.object, xattrKey); err != nil {
 		if err == syscall.ENOENT {
@@ -156,6 +156,19 @@ func (o *ObjectNode) authMiddleware(next http.Handler) http.Handler {
 				pass bool
 				err  error
 			)
+			// browser POST upload carries the authentication information in the form fields.
+			if currentAction == proto.OSSPostObjectAction {
+				if _, err = parsePostForm(r); err != nil {
+					log.LogErrorf("authMiddleware: parse post form fail: requestID(%v) err(%v)", GetRequestID(r), err)
+					if ec, ok := err.(*ErrorCode); ok {
+						_ = ec.ServeResponse(w, r)
+					} else {
+						_ = InternalErrorCode(err).ServeResponse(w, r)
+					}
+					return
+				}
+			}
+
 			// anonymous request will be authed in policy and acl check step.
 			authInfo := parseRequestAuthInfo(r)
 			if isAnonymous(authInfo.accessKey) {
@@ -164,7 +177,10 @@ func (o *ObjectNode) authMiddleware(next http.Handler) http.Handler {
 			}
 
 			//  check auth type
-			if isHeaderUsingSignatureAlgorithmV4(r) {
+			if isPostPolicyRequest(r) {
+				// using policy signed with signature algorithm version 2 or 4 in post form
+				pass, err = o.validatePostPolicySignature(r)
+			} else if isHeaderUsingSignatureAlgorithmV4(r) {
 				// using signature algorithm version 4 in header
 				pass, err = o.validateHeaderBySignatureAlgorithmV4(r)
 			} else if isHeaderUsingSignatureAlgorithmV2(r) {
@@ -216,8 +232,9 @@ func (o *ObjectNode) policyCheckMiddleware(next http.Handler) http.Handler {
 }
 
 // ContentMiddleware returns a middleware handler to process reader for content.
-// If the request contains the "X-amz-Decoded-Content-Length" header, it means that the data
-// in the request body is chunked. Use ChunkedReader to parse the data.
+// If the request declares a streaming payload or contains the "X-amz-Decoded-Content-Length" header,
+// it means that the data in the request body is aws-chunked. Use awsChunkedReader to parse the data
+// unless the body has been replaced by the one verifying the chunk signatures in authentication.
 // Workflow:
 //   request → [pre-handle] → [next handler] → response
 func (o *ObjectNode) contentMiddleware(next http.Handler) http.Handler {
@@ -231,8 +248,18 @@ func (o *ObjectNode) contentMiddleware(next http.Handler) http.Handler {
 				w.WriteHeader(StatusServerPanic)
 			}
 		}()
-		if len(r.Header) > 0 && len(r.Header.Get(http.CanonicalHeaderKey(HeaderNameXAmzDecodeContentLength))) > 0 {
-			r.Body = NewClosableChunkedReader(r.Body)
+		if _, verified := r.Body.(*awsChunkedReader); !verified && isAWSChunkedPayload(r) {
+			reader, err := newAWSChunkedReader(r, nil)
+			if err != nil {
+				log.LogErrorf("contentMiddleware: init chunk reader fail: requestID(%v) err(%v)", GetRequestID(r), err)
+				if ec, ok := err.(*ErrorCode); ok {
+					_ = ec.ServeResponse(w, r)
+				} else {
+					_ = InternalErrorCode(err).ServeResponse(w, r)
+				}
+				return
+			}
+			r.Body = reader
 			log.LogDebugf("contentMiddleware: chunk reader inited: requestID(%v)", GetRequestID(r))
 		}
 		next.ServeHTTP(w, r)
@@ -25,6 +25,7 @@ const (
 	SignatrueV4          = "signature_v4"
 	PresignedV2          = "presigned_v2"
 	PresignedV4          = "presigned_v4"
+	PostForm             = "post_form"
 )
 
 type RequestAuthInfo struct {
@@ -34,7 +35,10 @@ type RequestAuthInfo struct {
 
 func parseRequestAuthInfo(r *http.Request) *RequestAuthInfo {
 	auth := new(RequestAuthInfo)
-	if isHeaderUsingSignatureAlgorithmV2(r) {
+	if form, is := r.Body.(*postForm); is {
+		auth.authType = PostForm
+		auth.accessKey = form.accessKey()
+	} else if isHeaderUsingSignatureAlgorithmV2(r) {
 		auth.authType = SignatrueV2
 		ai, _ := parseRequestAuthInfoV2(r)
 		if ai != nil {
@@ -147,6 +147,16 @@ func (o *ObjectNode) validateHeaderBySignatureAlgorithmV4(r *http.Request) (bool
 		return false, SignatureDoesNotMatch
 	}
 
+	// The chunks of the streaming payload are signed with the signature of the request as the seed.
+	if isSignedStreamingPayload(getContentHash(r.Header)) {
+		signer := newChunkSigner(secretKey, req.Credential, getStartTime(r.Header), req.Signature)
+		var reader *awsChunkedReader
+		if reader, err = newAWSChunkedReader(r, signer); err != nil {
+			return false, err
+		}
+		r.Body = reader
+	}
+
 	return true, nil
 }
 
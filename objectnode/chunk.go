@@ -14,31 +14,309 @@
 
 package objectnode
 
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming-trailers.html
+
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
 	"io"
-	"net/http/httputil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	StreamingContentSHA256          = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	StreamingContentSHA256Trailer   = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	StreamingUnsignedPayloadTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	SignatureV4ChunkAlgorithm   = "AWS4-HMAC-SHA256-PAYLOAD"
+	SignatureV4TrailerAlgorithm = "AWS4-HMAC-SHA256-TRAILER"
+
+	chunkSignatureFlag   = "chunk-signature="
+	trailerSignatureName = "x-amz-trailer-signature"
+	emptySHA256          = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	MaxChunkSize     = 16 << 20 // 16MB
+	maxChunkLineSize = 4096
+	maxTrailerLines  = 16
 )
 
-// ClosableChunkReader wraps the chunked reader from the "httputil" package provided by Go
-// and provides a close method.
-type closableChunkedReader struct {
-	io.Reader
-	src io.ReadCloser
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// newChecksumHash returns the hash which computes the checksum named by the trailing header.
+func newChecksumHash(name string) hash.Hash {
+	switch name {
+	case HeaderNameXAmzChecksumCRC32:
+		return crc32.NewIEEE()
+	case HeaderNameXAmzChecksumCRC32C:
+		return crc32.New(crc32cTable)
+	case HeaderNameXAmzChecksumSHA256:
+		return sha256.New()
+	}
+	return nil
 }
 
-func (r *closableChunkedReader) Read(p []byte) (n int, err error) {
-	return r.Reader.Read(p)
+// isSignedStreamingPayload checks whether the content hash of the request declares an aws-chunked
+// payload with chunk signatures.
+func isSignedStreamingPayload(contentHash string) bool {
+	return contentHash == StreamingContentSHA256 || contentHash == StreamingContentSHA256Trailer
+}
+
+// isAWSChunkedPayload checks whether the body of the request is aws-chunked encoded.
+func isAWSChunkedPayload(r *http.Request) bool {
+	return strings.HasPrefix(getContentHash(r.Header), "STREAMING-") ||
+		r.Header.Get(HeaderNameXAmzDecodeContentLength) != ""
+}
+
+// chunkSigner computes the signatures of the chunks and the trailer of an aws-chunked payload.
+// Each signature chains the previous one, starting from the seed signature of the request.
+type chunkSigner struct {
+	signingKey []byte
+	timestamp  string
+	scope      string
+	prev       string
+}
+
+func newChunkSigner(secretKey string, cred credential, timestamp, seedSignature string) *chunkSigner {
+	return &chunkSigner{
+		signingKey: buildSigningKey(SCHEME, secretKey, cred.Date, cred.Region, SERVICE, TERMINATOR),
+		timestamp:  timestamp,
+		scope:      buildScope(cred.Date, cred.Region, SERVICE, TERMINATOR),
+		prev:       seedSignature,
+	}
 }
 
-func (r *closableChunkedReader) Close() error {
+func (s *chunkSigner) verify(stringToSign, signature string) bool {
+	expected := hex.EncodeToString(sign(stringToSign, s.signingKey))
+	if expected != signature {
+		return false
+	}
+	s.prev = signature
+	return true
+}
+
+func (s *chunkSigner) verifyChunk(chunkHash, signature string) bool {
+	return s.verify(strings.Join([]string{
+		SignatureV4ChunkAlgorithm,
+		s.timestamp,
+		s.scope,
+		s.prev,
+		emptySHA256,
+		chunkHash,
+	}, "\n"), signature)
+}
+
+func (s *chunkSigner) verifyTrailer(trailer, signature string) bool {
+	return s.verify(strings.Join([]string{
+		SignatureV4TrailerAlgorithm,
+		s.timestamp,
+		s.scope,
+		s.prev,
+		calcHash(trailer),
+	}, "\n"), signature)
+}
+
+// awsChunkedReader decodes the aws-chunked request body. If the signer is specified, the signature of
+// each chunk and of the trailer is verified before the chunk data is returned. The decoded content
+// length and the trailing checksums are verified when the final chunk is read.
+type awsChunkedReader struct {
+	src           io.ReadCloser
+	br            *bufio.Reader
+	signer        *chunkSigner
+	trailers      []string
+	checksums     map[string]hash.Hash
+	decodedLength int64 // -1 if not specified
+	decoded       int64
+	chunk         []byte
+	data          []byte
+	err           error
+}
+
+// newAWSChunkedReader returns a reader which decodes the aws-chunked body of the request.
+func newAWSChunkedReader(r *http.Request, signer *chunkSigner) (reader *awsChunkedReader, err error) {
+	reader = &awsChunkedReader{
+		src:           r.Body,
+		br:            bufio.NewReaderSize(r.Body, maxChunkLineSize),
+		signer:        signer,
+		checksums:     make(map[string]hash.Hash),
+		decodedLength: -1,
+	}
+	if raw := r.Header.Get(HeaderNameXAmzDecodeContentLength); raw != "" {
+		if reader.decodedLength, err = strconv.ParseInt(raw, 10, 64); err != nil || reader.decodedLength < 0 {
+			return nil, InvalidArgument
+		}
+	} else if signer != nil {
+		return nil, MissingDecodedContentLength
+	}
+	if raw := r.Header.Get(HeaderNameXAmzTrailer); raw != "" {
+		for _, name := range strings.Split(raw, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			var h = newChecksumHash(name)
+			if h == nil {
+				return nil, InvalidTrailer
+			}
+			reader.trailers = append(reader.trailers, name)
+			reader.checksums[name] = h
+		}
+	}
+	if getContentHash(r.Header) == StreamingContentSHA256Trailer && len(reader.trailers) == 0 {
+		return nil, InvalidTrailer
+	}
+	return
+}
+
+func (r *awsChunkedReader) Read(p []byte) (n int, err error) {
+	for len(r.data) == 0 && r.err == nil {
+		r.err = r.nextChunk()
+	}
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n = copy(p, r.data)
+	r.data = r.data[n:]
+	return
+}
+
+func (r *awsChunkedReader) Close() error {
 	return r.src.Close()
 }
 
-// NewClosableChunkedReader returns an instance of the io.ReadCloser interface
-// used to parse chunk data.
-func NewClosableChunkedReader(source io.ReadCloser) io.ReadCloser {
-	return &closableChunkedReader{
-		src:    source,
-		Reader: httputil.NewChunkedReader(source),
+// readLine reads a line terminated by CRLF and returns it without the terminator.
+func (r *awsChunkedReader) readLine() (line string, err error) {
+	var raw []byte
+	if raw, err = r.br.ReadSlice('\n'); err != nil {
+		if err == io.EOF {
+			return "", IncompleteBody
+		}
+		if err == bufio.ErrBufferFull {
+			return "", MalformedChunkedEncoding
+		}
+		return
+	}
+	if !bytes.HasSuffix(raw, []byte("\r\n")) {
+		return "", MalformedChunkedEncoding
+	}
+	return string(raw[:len(raw)-2]), nil
+}
+
+func (r *awsChunkedReader) nextChunk() (err error) {
+	var line string
+	if line, err = r.readLine(); err != nil {
+		return
+	}
+	var sizeStr, signature = line, ""
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		sizeStr = line[:i]
+		if ext := line[i+1:]; strings.HasPrefix(ext, chunkSignatureFlag) {
+			signature = ext[len(chunkSignatureFlag):]
+		}
+	}
+	var size int64
+	if size, err = strconv.ParseInt(strings.TrimSpace(sizeStr), 16, 64); err != nil || size < 0 {
+		return MalformedChunkedEncoding
+	}
+	if size > MaxChunkSize {
+		return MalformedChunkedEncoding
+	}
+	if r.decodedLength >= 0 && r.decoded+size > r.decodedLength {
+		return IncompleteBody
+	}
+	if r.signer != nil && signature == "" {
+		return SignatureDoesNotMatch
+	}
+
+	if size == 0 {
+		if r.signer != nil && !r.signer.verifyChunk(emptySHA256, signature) {
+			return SignatureDoesNotMatch
+		}
+		if err = r.readTrailer(); err != nil {
+			return
+		}
+		if r.decodedLength >= 0 && r.decoded != r.decodedLength {
+			return IncompleteBody
+		}
+		return io.EOF
+	}
+
+	if int64(cap(r.chunk)) < size {
+		r.chunk = make([]byte, size)
+	}
+	r.chunk = r.chunk[:size]
+	if _, err = io.ReadFull(r.br, r.chunk); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return IncompleteBody
+		}
+		return
+	}
+	if line, err = r.readLine(); err != nil {
+		return
+	}
+	if line != "" {
+		return MalformedChunkedEncoding
+	}
+	if r.signer != nil {
+		sum := sha256.Sum256(r.chunk)
+		if !r.signer.verifyChunk(hex.EncodeToString(sum[:]), signature) {
+			return SignatureDoesNotMatch
+		}
+	}
+	for _, h := range r.checksums {
+		h.Write(r.chunk)
+	}
+	r.decoded += size
+	r.data = r.chunk
+	return nil
+}
+
+// readTrailer reads the trailing headers after the final chunk, which ends with an empty line,
+// and verifies the trailer signature and the checksums.
+func (r *awsChunkedReader) readTrailer() (err error) {
+	var (
+		values    = make(map[string]string)
+		canonical strings.Builder
+		signature string
+	)
+	for i := 0; ; i++ {
+		if i > maxTrailerLines {
+			return MalformedChunkedEncoding
+		}
+		var line string
+		if line, err = r.readLine(); err != nil {
+			return
+		}
+		if line == "" {
+			break
+		}
+		var kv = strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			return MalformedChunkedEncoding
+		}
+		var name, value = strings.ToLower(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+		if name == trailerSignatureName {
+			signature = value
+			continue
+		}
+		values[name] = value
+		canonical.WriteString(name + ":" + value + "\n")
+	}
+	if r.signer != nil && len(r.trailers) > 0 && !r.signer.verifyTrailer(canonical.String(), signature) {
+		return SignatureDoesNotMatch
+	}
+	for _, name := range r.trailers {
+		value, has := values[name]
+		if !has {
+			return InvalidTrailer
+		}
+		if base64.StdEncoding.EncodeToString(r.checksums[name].Sum(nil)) != value {
+			return BadChecksum
+		}
 	}
+	return nil
 }
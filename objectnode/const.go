@@ -38,6 +38,7 @@ const (
 	HeaderNameRange              = "Range"
 	HeaderNameExpect             = "Expect"
 	HeaderNameXForwardedExpect   = "X-Forwarded-Expect"
+	HeaderNameXForwardedProto    = "X-Forwarded-Proto"
 	HeaderNameLocation           = "Location"
 	HeaderNameCacheControl       = "Cache-Control"
 	HeaderNameExpires            = "Expires"
@@ -70,6 +71,28 @@ const (
 	HeaderNameXAmzMetadataDirective   = "x-amz-metadata-directive"
 	HeaderNameXAmzBucketRegion        = "x-amz-bucket-region"
 	HeaderNameXAmzTaggingCount        = "x-amz-tagging-count"
+	HeaderNameXAmzVersionId           = "x-amz-version-id"
+	HeaderNameXAmzDeleteMarker        = "x-amz-delete-marker"
+	HeaderNameXAmzReplicationStatus   = "x-amz-replication-status"
+	HeaderNameXAmzStorageClass        = "x-amz-storage-class"
+	HeaderNameXAmzRestore             = "x-amz-restore"
+	HeaderNameXAmzTrailer             = "x-amz-trailer"
+	HeaderNameXAmzChecksumCRC32       = "x-amz-checksum-crc32"
+	HeaderNameXAmzChecksumCRC32C      = "x-amz-checksum-crc32c"
+	HeaderNameXAmzChecksumSHA256      = "x-amz-checksum-sha256"
+
+	HeaderNameXAmzObjectLockMode            = "x-amz-object-lock-mode"
+	HeaderNameXAmzObjectLockRetainUntilDate = "x-amz-object-lock-retain-until-date"
+	HeaderNameXAmzObjectLockLegalHold       = "x-amz-object-lock-legal-hold"
+	HeaderNameXAmzBypassGovernanceRetention = "x-amz-bypass-governance-retention"
+
+	HeaderNameXAmzServerSideEncryption           = "x-amz-server-side-encryption"
+	HeaderNameXAmzSSECustomerAlgorithm           = "x-amz-server-side-encryption-customer-algorithm"
+	HeaderNameXAmzSSECustomerKey                 = "x-amz-server-side-encryption-customer-key"
+	HeaderNameXAmzSSECustomerKeyMD5              = "x-amz-server-side-encryption-customer-key-md5"
+	HeaderNameXAmzCopySourceSSECustomerAlgorithm = "x-amz-copy-source-server-side-encryption-customer-algorithm"
+	HeaderNameXAmzCopySourceSSECustomerKey       = "x-amz-copy-source-server-side-encryption-customer-key"
+	HeaderNameXAmzCopySourceSSECustomerKeyMD5    = "x-amz-copy-source-server-side-encryption-customer-key-md5"
 
 	HeaderNameIfMatch           = "If-Match"
 	HeaderNameIfNoneMatch       = "If-None-Match"
@@ -83,6 +106,9 @@ const (
 	HeaderValueTypeStream           = "application/octet-stream"
 	HeaderValueContentTypeXML       = "application/xml"
 	HeaderValueContentTypeDirectory = "application/directory"
+	HeaderValueContentTypeFormData  = "multipart/form-data"
+	HeaderValueContentTypeJSON      = "application/json"
+	HeaderValueContentTypeHTML      = "text/html; charset=utf-8"
 )
 
 const (
@@ -101,13 +127,15 @@ const (
 	ParamMaxKeys    = "max-keys"
 	ParamStartAfter = "start-after"
 	ParamKey        = "key"
+	ParamVersionId  = "versionId"
 
-	ParamMaxParts       = "max-parts"
-	ParamUploadIdMarker = "upload-id-marker"
-	ParamPartNoMarker   = "part-number-marker"
-	ParamPartMaxUploads = "max-uploads"
-	ParamPartDelimiter  = "delimiter"
-	ParamEncodingType   = "encoding-type"
+	ParamMaxParts        = "max-parts"
+	ParamUploadIdMarker  = "upload-id-marker"
+	ParamVersionIdMarker = "version-id-marker"
+	ParamPartNoMarker    = "part-number-marker"
+	ParamPartMaxUploads  = "max-uploads"
+	ParamPartDelimiter   = "delimiter"
+	ParamEncodingType    = "encoding-type"
 
 	ParamResponseCacheControl       = "response-cache-control"
 	ParamResponseContentType        = "response-content-type"
@@ -136,6 +164,29 @@ const (
 	XAttrKeyOSSCORS         = "oss:cors"
 	XAttrKeyOSSCacheControl = "oss:cache"
 	XAttrKeyOSSExpires      = "oss:expires"
+	XAttrKeyOSSLifecycle    = "oss:lifecycle"
+	XAttrKeyOSSVersioning   = "oss:versioning"
+	XAttrKeyOSSVersionId    = "oss:version-id"
+	XAttrKeyOSSObjectLock   = "oss:object-lock"
+	XAttrKeyOSSEncryption   = "oss:encryption"
+	XAttrKeyOSSBucketSSE    = "oss:bucket-encryption"
+	XAttrKeyOSSReplication  = "oss:replication"
+	XAttrKeyOSSNotification = "oss:notification"
+	XAttrKeyOSSWebsite      = "oss:website"
+	XAttrKeyOSSPublicBlock  = "oss:public-access-block"
+	XAttrKeyOSSStorageClass = "oss:storage-class"
+	XAttrKeyOSSRestore      = "oss:restore"
+
+	// The replication status of the object version.
+	XAttrKeyOSSReplicationStatus = "oss:replication-status"
+
+	// Marks the part inode sharing the extents with the source object of UploadPartCopy.
+	XAttrKeyOSSClonedPart = "oss:cloned-part"
+
+	// Prefix of the keys of the parent directory extend attributes which record the
+	// noncurrent versions and delete markers of the objects in the directory, followed
+	// by the object name, a slash and the sequence number of the record.
+	XAttrKeyOSSVersionsPrefix = "oss:versions:"
 
 	// Deprecated
 	XAttrKeyOSSETagDeprecated = "oss:tag"
@@ -18,6 +18,8 @@ import (
 	"os"
 	"sort"
 	"time"
+
+	"github.com/cubefs/cubefs/proto"
 )
 
 type FSFileInfo struct {
@@ -33,6 +35,15 @@ type FSFileInfo struct {
 	CacheControl string
 	Expires      string
 	Metadata     map[string]string `graphql:"-"` // User-defined metadata
+	VersionId    string
+	IsLatest     bool
+	DeleteMarker bool
+	ObjectLock   *proto.ObjectLock `graphql:"-"`
+	Encryption   *ObjectEncryption `graphql:"-"`
+
+	ReplicationStatus string
+	StorageClass      string
+	Restore           *ObjectRestore `graphql:"-"`
 }
 
 type Prefixes []string
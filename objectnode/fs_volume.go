@@ -90,6 +90,11 @@ type PutFileOption struct {
 	Metadata     map[string]string
 	CacheControl string
 	Expires      string
+	ObjectLock   *proto.ObjectLock
+	Encryption   *SSEOption
+	// The customer key of the copy source encrypted with SSE-C.
+	SourceEncryption *SSEOption
+	StorageClass     string
 }
 
 type ListFilesV1Option struct {
@@ -130,6 +135,7 @@ type ListFilesV2Result struct {
 type Volume struct {
 	mw         *meta.MetaWrapper
 	ec         *stream.ExtentClient
+	mc         *master.MasterClient
 	store      Store // Storage for ACP management
 	name       string
 	owner      string
@@ -190,6 +196,60 @@ func (v *Volume) loadOSSMeta() {
 		return
 	}
 	v.metaLoader.storeCORS(cors)
+
+	var lifecycle *LifecycleConfiguration
+	if lifecycle, err = v.loadBucketLifecycle(); err != nil {
+		return
+	}
+	v.metaLoader.storeLifecycle(lifecycle)
+
+	var versioning *VersioningConfiguration
+	if versioning, err = v.loadBucketVersioning(); err != nil {
+		return
+	}
+	v.metaLoader.storeVersioning(versioning)
+
+	var objectLock *ObjectLockConfiguration
+	if objectLock, err = v.loadBucketObjectLock(); err != nil {
+		return
+	}
+	v.metaLoader.storeObjectLock(objectLock)
+
+	var encryption *ServerSideEncryptionConfiguration
+	if encryption, err = v.loadBucketEncryption(); err != nil {
+		return
+	}
+	v.metaLoader.storeEncryption(encryption)
+
+	var replication *ReplicationConfiguration
+	if replication, err = v.loadBucketReplication(); err != nil {
+		return
+	}
+	v.metaLoader.storeReplication(replication)
+
+	var notification *NotificationConfiguration
+	if notification, err = v.loadBucketNotification(); err != nil {
+		return
+	}
+	v.metaLoader.storeNotification(notification)
+
+	var website *WebsiteConfiguration
+	if website, err = v.loadBucketWebsite(); err != nil {
+		return
+	}
+	v.metaLoader.storeWebsite(website)
+
+	var publicBlock *PublicAccessBlockConfiguration
+	if publicBlock, err = v.loadBucketPublicAccessBlock(); err != nil {
+		return
+	}
+	v.metaLoader.storePublicAccessBlock(publicBlock)
+
+	var ownerBlock *proto.PublicAccessBlock
+	if ownerBlock, err = v.loadOwnerPublicAccessBlock(); err != nil {
+		return
+	}
+	v.metaLoader.storeOwnerPublicAccessBlock(ownerBlock)
 	v.metaLoader.setSynced()
 }
 
@@ -254,6 +314,144 @@ func (v *Volume) loadBucketCors() (configuration *CORSConfiguration, err error)
 	return configuration, nil
 }
 
+func (v *Volume) loadBucketLifecycle() (configuration *LifecycleConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSLifecycle); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = NewLifecycleConfiguration()
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketVersioning() (configuration *VersioningConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSVersioning); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = NewVersioningConfiguration()
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketObjectLock() (configuration *ObjectLockConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSObjectLock); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = NewObjectLockConfiguration()
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketEncryption() (configuration *ServerSideEncryptionConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSBucketSSE); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = NewServerSideEncryptionConfiguration()
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketReplication() (configuration *ReplicationConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSReplication); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = NewReplicationConfiguration()
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketNotification() (configuration *NotificationConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSNotification); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = NewNotificationConfiguration()
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketWebsite() (configuration *WebsiteConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSWebsite); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = NewWebsiteConfiguration()
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+func (v *Volume) loadBucketPublicAccessBlock() (configuration *PublicAccessBlockConfiguration, err error) {
+	var raw []byte
+	if raw, err = v.store.Get(v.name, bucketRootPath, XAttrKeyOSSPublicBlock); err != nil {
+		return
+	}
+	if len(raw) == 0 {
+		return
+	}
+	configuration = NewPublicAccessBlockConfiguration()
+	if err = xml.Unmarshal(raw, configuration); err != nil {
+		return
+	}
+	return configuration, nil
+}
+
+// load the user level public access block settings of the volume owner from master
+func (v *Volume) loadOwnerPublicAccessBlock() (block *proto.PublicAccessBlock, err error) {
+	if v.mc == nil {
+		return
+	}
+	var userInfo *proto.UserInfo
+	if userInfo, err = v.mc.UserAPI().GetUserInfo(v.owner); err != nil {
+		if err == proto.ErrUserNotExists {
+			err = nil
+		}
+		return
+	}
+	if userInfo.PublicAccessBlock.IsEmpty() {
+		return
+	}
+	return userInfo.PublicAccessBlock, nil
+}
+
 func (v *Volume) getInodeFromPath(path string) (inode uint64, err error) {
 	if path == "/" {
 		return volumeRootInode, nil
@@ -687,9 +885,27 @@ func (v *Volume) PutObject(path string, reader io.Reader, opt *PutFileOption) (f
 	}()
 
 	var (
-		md5Hash  = md5.New()
-		md5Value string
+		md5Hash   = md5.New()
+		md5Value  string
+		writeHash hash.Hash = md5Hash
+		enc       *ObjectEncryption
+		encReader *sseEncryptReader
 	)
+	// The encrypted data is written, and the ETag is computed from the plaintext.
+	if opt != nil && opt.Encryption != nil {
+		var dataKey []byte
+		if enc, dataKey, err = newObjectEncryption(opt.Encryption); err != nil {
+			log.LogErrorf("PutObject: new object encryption fail: volume(%v) path(%v) err(%v)", v.name, path, err)
+			return
+		}
+		var stream *SSEStream
+		if encReader, stream, err = newSSEStreamReader(reader, dataKey, md5Hash); err != nil {
+			log.LogErrorf("PutObject: new encrypt reader fail: volume(%v) path(%v) err(%v)", v.name, path, err)
+			return
+		}
+		enc.Streams = []*SSEStream{stream}
+		reader, writeHash = encReader, nil
+	}
 
 	if err = v.ec.OpenStream(invisibleTempDataInode.Inode); err != nil {
 		log.LogErrorf("PutObject: open stream fail: volume(%v) path(%v) inode(%v) err(%v)",
@@ -703,7 +919,7 @@ func (v *Volume) PutObject(path string, reader io.Reader, opt *PutFileOption) (f
 		}
 	}()
 	if proto.IsCold(v.volType) {
-		if _, err = v.ebsWrite(invisibleTempDataInode.Inode, reader, md5Hash); err != nil {
+		if _, err = v.ebsWrite(invisibleTempDataInode.Inode, reader, writeHash); err != nil {
 			log.LogErrorf("PutObject: ebs write fail: volume(%v) path(%v) inode(%v) err(%v)",
 				v.name, path, invisibleTempDataInode.Inode, err)
 			return
@@ -711,7 +927,7 @@ func (v *Volume) PutObject(path string, reader io.Reader, opt *PutFileOption) (f
 
 	} else {
 
-		if _, err = v.streamWrite(invisibleTempDataInode.Inode, reader, md5Hash); err != nil {
+		if _, err = v.streamWrite(invisibleTempDataInode.Inode, reader, writeHash); err != nil {
 			log.LogErrorf("PutObject: stream write fail: volume(%v) path(%v) inode(%v) err(%v)",
 				v.name, path, invisibleTempDataInode.Inode, err)
 			return
@@ -766,6 +982,19 @@ func (v *Volume) PutObject(path string, reader io.Reader, opt *PutFileOption) (f
 	if opt != nil && opt.ACL != nil {
 		attr.XAttrs[XAttrKeyOSSACL] = opt.ACL.Encode()
 	}
+	if opt != nil && opt.ObjectLock != nil {
+		for key, value := range opt.ObjectLock.Attrs() {
+			attr.XAttrs[key] = value
+		}
+	}
+	if opt != nil {
+		setStorageClassAttr(attr.XAttrs, opt.StorageClass)
+	}
+	if enc != nil {
+		enc.Size = encReader.Size()
+		enc.Streams[0].Size = enc.Size
+		attr.XAttrs[XAttrKeyOSSEncryption] = enc.Encode()
+	}
 
 	// If user-defined metadata have been specified, use extend attributes for storage.
 	if opt != nil && len(opt.Metadata) > 0 {
@@ -794,14 +1023,20 @@ func (v *Volume) PutObject(path string, reader io.Reader, opt *PutFileOption) (f
 		ETag:       etagValue.ETag(),
 		Inode:      finalInode.Inode,
 	}
+	if enc != nil {
+		fsInfo.Size, fsInfo.Encryption = enc.Size, enc
+	}
 
 	// apply new inode to dentry
-	err = v.applyInodeToDEntry(parentId, lastPathItem.Name, invisibleTempDataInode.Inode)
+	fsInfo.VersionId, err = v.applyInodeToDEntry(parentId, lastPathItem.Name, invisibleTempDataInode.Inode)
 	if err != nil {
 		log.LogErrorf("PutObject: apply new inode to dentry fail: parentID(%v) name(%v) inode(%v) err(%v)",
 			parentId, lastPathItem.Name, invisibleTempDataInode.Inode, err)
 		return
 	}
+	if fsInfo.VersionId != "" {
+		attr.XAttrs[XAttrKeyOSSVersionId] = fsInfo.VersionId
+	}
 
 	//force updating dentry and attrs in cache
 	if objMetaCache != nil {
@@ -820,7 +1055,16 @@ func (v *Volume) PutObject(path string, reader io.Reader, opt *PutFileOption) (f
 	return fsInfo, nil
 }
 
-func (v *Volume) applyInodeToDEntry(parentId uint64, name string, inode uint64) (err error) {
+func (v *Volume) applyInodeToDEntry(parentId uint64, name string, inode uint64) (versionId string, err error) {
+	var status string
+	if status, err = v.versioningStatus(); err != nil {
+		log.LogErrorf("applyInodeToDEntry: load versioning fail: volume(%v) err(%v)", v.name, err)
+		return
+	}
+	if status != "" {
+		return v.applyInodeToVersionedDEntry(parentId, name, inode, status)
+	}
+
 	var existMode uint32
 	_, existMode, err = v.mw.Lookup_ll(parentId, name)
 	if err != nil && err != syscall.ENOENT {
@@ -843,7 +1087,7 @@ func (v *Volume) applyInodeToDEntry(parentId uint64, name string, inode uint64)
 			err = syscall.EINVAL
 			return
 		}
-		//current implementation dosen't support object versioning, so uploading a object with a key already existed in bucket
+		// Versioning is not enabled on the bucket, so uploading a object with a key already existed in bucket
 		// is implemented with replacing the old one instead.
 		// refer: https://docs.aws.amazon.com/AmazonS3/latest/userguide/upload-objects.html
 		if err = v.applyInodeToExistDentry(parentId, name, inode); err != nil {
@@ -892,9 +1136,14 @@ func (v *Volume) DeletePath(path string) (err error) {
 		if err != nil || len(dentries) > 0 {
 			return
 		}
+		// The noncurrent versions of the objects in the directory are recorded in the directory.
+		var hasVersions bool
+		if hasVersions, err = v.hasObjectVersions(ino); err != nil || hasVersions {
+			return
+		}
 	}
 	log.LogWarnf("DeletePath: delete: volume(%v) path(%v) inode(%v)", v.name, path, ino)
-	if _, err = v.mw.Delete_ll(parent, name, mode.IsDir()); err != nil {
+	if _, err = v.mw.Delete_ll(parent, name, mode.IsDir(), "/"+strings.TrimPrefix(path, "/")); err != nil {
 		return
 	}
 
@@ -959,6 +1208,24 @@ func (v *Volume) InitMultipart(path string, opt *PutFileOption) (multipartID str
 	if opt != nil && opt.ACL != nil {
 		extend[XAttrKeyOSSACL] = opt.ACL.Encode()
 	}
+	// The retention and legal hold are applied to the object when the upload is completed.
+	if opt != nil && opt.ObjectLock != nil {
+		for key, value := range opt.ObjectLock.Attrs() {
+			extend[key] = value
+		}
+	}
+	if opt != nil {
+		setStorageClassAttr(extend, opt.StorageClass)
+	}
+	// The data key is shared by all the parts, and the streams are recorded when the upload is completed.
+	if opt != nil && opt.Encryption != nil {
+		var enc *ObjectEncryption
+		if enc, _, err = newObjectEncryption(opt.Encryption); err != nil {
+			log.LogErrorf("InitMultipart: new object encryption fail: volume(%v) path(%v) err(%v)", v.name, path, err)
+			return
+		}
+		extend[XAttrKeyOSSEncryption] = enc.Encode()
+	}
 
 	if v.mw.EnableQuota {
 		var parentId uint64
@@ -982,7 +1249,7 @@ func (v *Volume) InitMultipart(path string, opt *PutFileOption) (multipartID str
 	return multipartID, nil
 }
 
-func (v *Volume) WritePart(path string, multipartId string, partId uint16, reader io.Reader) (*FSFileInfo, error) {
+func (v *Volume) WritePart(path string, multipartId string, partId uint16, reader io.Reader, sse *SSEOption) (*FSFileInfo, error) {
 	var exist bool
 	var err error
 	defer func() {
@@ -994,6 +1261,14 @@ func (v *Volume) WritePart(path string, multipartId string, partId uint16, reade
 	var fInfo *FSFileInfo
 	_, fileName := splitPath(path)
 
+	var enc *ObjectEncryption
+	var dataKey []byte
+	if enc, dataKey, err = v.multipartEncryption(path, multipartId, sse); err != nil {
+		log.LogErrorf("WritePart: load multipart encryption fail: volume(%v) path(%v) multipartID(%v) partID(%v) err(%v)",
+			v.name, path, multipartId, partId, err)
+		return nil, err
+	}
+
 	// create temp file (inode only, invisible for user)
 	var tempInodeInfo *proto.InodeInfo
 	if tempInodeInfo, err = v.mw.InodeCreate_ll(0, DefaultFileMode, 0, 0, nil, make([]uint64, 0)); err != nil {
@@ -1027,10 +1302,21 @@ func (v *Volume) WritePart(path string, multipartId string, partId uint16, reade
 	}()
 
 	var (
-		size    uint64
-		etag    string
-		md5Hash = md5.New()
+		size      uint64
+		etag      string
+		md5Hash             = md5.New()
+		writeHash hash.Hash = md5Hash
+		encReader *sseEncryptReader
+		stream    *SSEStream
 	)
+	if enc != nil {
+		if encReader, stream, err = newSSEStreamReader(reader, dataKey, md5Hash); err != nil {
+			log.LogErrorf("WritePart: new encrypt reader fail: volume(%v) path(%v) multipartID(%v) partID(%v) err(%v)",
+				v.name, path, multipartId, partId, err)
+			return nil, err
+		}
+		reader, writeHash = encReader, nil
+	}
 	if err = v.ec.OpenStream(tempInodeInfo.Inode); err != nil {
 		log.LogErrorf("WritePart: data open stream fail: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
 			v.name, path, multipartId, partId, tempInodeInfo.Inode, err)
@@ -1043,14 +1329,14 @@ func (v *Volume) WritePart(path string, multipartId string, partId uint16, reade
 		}
 	}()
 	if proto.IsCold(v.volType) {
-		if size, err = v.ebsWrite(tempInodeInfo.Inode, reader, md5Hash); err != nil {
+		if size, err = v.ebsWrite(tempInodeInfo.Inode, reader, writeHash); err != nil {
 			log.LogErrorf("WritePart: ebs write fail: volume(%v) inode(%v) multipartID(%v) partID(%v) err(%v)",
 				v.name, tempInodeInfo.Inode, multipartId, partId, err)
 			return nil, err
 		}
 	} else {
 		// Write data to data node
-		if size, err = v.streamWrite(tempInodeInfo.Inode, reader, md5Hash); err != nil {
+		if size, err = v.streamWrite(tempInodeInfo.Inode, reader, writeHash); err != nil {
 			log.LogErrorf("WritePart: stream write fail: volume(%v) inode(%v) multipartID(%v) partID(%v) err(%v)",
 				v.name, tempInodeInfo.Inode, multipartId, partId, err)
 			return nil, err
@@ -1065,6 +1351,21 @@ func (v *Volume) WritePart(path string, multipartId string, partId uint16, reade
 	// compute file md5
 	etag = hex.EncodeToString(md5Hash.Sum(nil))
 
+	// The nonce of the stream is kept by the part inode, and the size of the plaintext is recorded as the part size.
+	if enc != nil {
+		stream.Size = encReader.Size()
+		size = uint64(stream.Size)
+		var raw []byte
+		if raw, err = json.Marshal(stream); err != nil {
+			return nil, err
+		}
+		if err = v.mw.XAttrSet_ll(tempInodeInfo.Inode, []byte(XAttrKeyOSSEncryption), raw); err != nil {
+			log.LogErrorf("WritePart: meta set part encryption fail: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
+				v.name, path, multipartId, partId, tempInodeInfo.Inode, err)
+			return nil, err
+		}
+	}
+
 	// update temp file inode to meta with session, overwrite existing part can result in exist == true
 	oldInode, exist, err = v.mw.AddMultipartPart_ll(path, multipartId, partId, size, etag, tempInodeInfo)
 	if err != nil {
@@ -1083,6 +1384,7 @@ func (v *Volume) WritePart(path string, multipartId string, partId uint16, reade
 		CreateTime: tempInodeInfo.CreateTime,
 		ETag:       etag,
 		Inode:      tempInodeInfo.Inode,
+		Encryption: enc,
 	}
 	return fInfo, nil
 }
@@ -1139,9 +1441,30 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 		return
 	}
 
+	// The parts cloned from the source objects share the extents counted by their meta partition, and the complete
+	// inode is created in the same partition.
+	var cloned map[uint64]struct{}
+	if cloned, err = v.clonedParts(parts); err != nil {
+		log.LogErrorf("CompleteMultipart: meta get cloned parts fail: volume(%v) path(%v) multipartID(%v) err(%v)",
+			v.name, path, multipartID, err)
+		return
+	}
+	var clonedInode uint64
+	for _, part := range parts {
+		if _, ok := cloned[part.Inode]; ok {
+			clonedInode = part.Inode
+			break
+		}
+	}
+
 	// create inode for complete data
 	var completeInodeInfo *proto.InodeInfo
-	if completeInodeInfo, err = v.mw.InodeCreate_ll(parentId, DefaultFileMode, 0, 0, nil, make([]uint64, 0)); err != nil {
+	if clonedInode != 0 {
+		completeInodeInfo, err = v.mw.InodeCreateNear_ll(clonedInode, DefaultFileMode, 0, 0)
+	} else {
+		completeInodeInfo, err = v.mw.InodeCreate_ll(parentId, DefaultFileMode, 0, 0, nil, make([]uint64, 0))
+	}
+	if err != nil {
 		log.LogErrorf("CompleteMultipart: meta inode create fail: volume(%v) path(%v) multipartID(%v) err(%v)",
 			v.name, path, multipartID, err)
 		return
@@ -1162,6 +1485,15 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 	// merge complete extent keys
 	var size uint64
 	var fileOffset uint64
+	var copiedParts = make(map[uint64]uint64)
+	defer func() {
+		if err != nil {
+			for _, inode := range copiedParts {
+				_, _ = v.mw.InodeUnlink_ll(inode)
+				_ = v.mw.Evict(inode)
+			}
+		}
+	}()
 	if proto.IsCold(v.volType) {
 		var completeObjExtentKeys = make([]proto.ObjExtentKey, 0)
 		for _, part := range parts {
@@ -1186,8 +1518,18 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 	} else {
 		var completeExtentKeys = make([]proto.ExtentKey, 0)
 		for _, part := range parts {
+			dataInode := part.Inode
+			// the part cloned in another partition is copied, which is rare for the concurrent UploadPartCopy
+			if _, ok := cloned[part.Inode]; ok && !v.mw.IsSamePartition(part.Inode, completeInodeInfo.Inode) {
+				if dataInode, err = v.copyClonedPart(part, completeInodeInfo.Inode); err != nil {
+					log.LogErrorf("CompleteMultipart: copy cloned part fail: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
+						v.name, path, multipartID, part.ID, part.Inode, err)
+					return
+				}
+				copiedParts[part.Inode] = dataInode
+			}
 			var eks []proto.ExtentKey
-			if _, _, eks, err = v.mw.GetExtents(part.Inode); err != nil {
+			if _, _, eks, err = v.mw.GetExtents(dataInode); err != nil {
 				log.LogErrorf("CompleteMultipart: meta get extents fail: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
 					v.name, path, multipartID, part.ID, part.Inode, err)
 				return
@@ -1248,6 +1590,15 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 			attrs[key] = value
 		}
 	}
+	var enc *ObjectEncryption
+	if enc = parseObjectEncryption(extend[XAttrKeyOSSEncryption]); enc != nil {
+		if err = v.loadPartStreams(enc, parts); err != nil {
+			log.LogErrorf("CompleteMultipart: load part streams fail: volume(%v) multipartID(%v) err(%v)",
+				v.name, multipartID, err)
+			return
+		}
+		attrs[XAttrKeyOSSEncryption] = enc.Encode()
+	}
 	if err = v.mw.BatchSetXAttr_ll(finalInode.Inode, attrs); err != nil {
 		log.LogErrorf("CompleteMultipart: store multipart extend fail: volume(%v) multipartID(%v) inode(%v) "+
 			"attrs(%v) err(%v)", v.name, multipartID, finalInode.Inode, attrs, err)
@@ -1255,7 +1606,8 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 	}
 
 	// apply new inode to dentry
-	if err = v.applyInodeToDEntry(parentId, filename, completeInodeInfo.Inode); err != nil {
+	var versionId string
+	if versionId, err = v.applyInodeToDEntry(parentId, filename, completeInodeInfo.Inode); err != nil {
 		log.LogErrorf("CompleteMultipart: apply inode to dentry fail: volume(%v) multipartID(%v) parentId(%v) "+
 			"fileName(%v) inode(%v) err(%v)", v.name, multipartID, parentId, filename, completeInodeInfo.Inode, err)
 		return
@@ -1274,6 +1626,17 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 		for _, part := range parts {
 			log.LogWarnf("CompleteMultipart: destroy part inode: volume(%v) multipartID(%v) partID(%v) inode(%v)",
 				v.name, multipartID, part.ID, part.Inode)
+			// the data of the part copied is released, and the inode of the copy is deleted instead
+			if copied, ok := copiedParts[part.Inode]; ok {
+				if _, err2 = v.mw.InodeUnlink_ll(part.Inode); err2 == nil {
+					err2 = v.mw.Evict(part.Inode)
+				}
+				if err2 != nil {
+					log.LogWarnf("CompleteMultipart: release copied part fail: volume(%v) multipartID(%v) part(%v) err(%v)",
+						v.name, multipartID, part, err2)
+				}
+				part = &proto.MultipartPartInfo{ID: part.ID, Inode: copied}
+			}
 			if err2 = v.mw.InodeDelete_ll(part.Inode); err2 != nil {
 				log.LogWarnf("CompleteMultipart: delete part inode fail: volume(%v) multipartID(%v) part(%v) err(%v)",
 					v.name, multipartID, part, err2)
@@ -1301,6 +1664,8 @@ func (v *Volume) CompleteMultipart(path, multipartID string, multipartInfo *prot
 		ModifyTime: time.Now(),
 		ETag:       etagValue.ETag(),
 		Inode:      finalInode.Inode,
+		VersionId:  versionId,
+		Encryption: enc,
 	}
 
 	return fInfo, nil
@@ -1437,6 +1802,14 @@ func (v *Volume) applyInodeToExistDentry(parentID uint64, name string, inode uin
 	if _, err = v.mw.InodeUnlink_ll(oldInode); err != nil {
 		log.LogWarnf("applyInodeToExistDentry: unlink inode fail: volume(%v) inode(%v) err(%v)",
 			v.name, oldInode, err)
+		if err == syscall.EPERM {
+			// The old inode is protected against deletion, e.g. by object lock, so it can not be replaced.
+			if _, rbErr := v.mw.DentryUpdate_ll(parentID, name, oldInode); rbErr != nil {
+				log.LogErrorf("applyInodeToExistDentry: rollback dentry fail: parentID(%v) name(%v) inode(%v) err(%v)",
+					parentID, name, oldInode, rbErr)
+			}
+			return
+		}
 	}
 
 	log.LogWarnf("applyInodeToExistDentry: evict inode: volume(%v) inode(%v)", v.name, oldInode)
@@ -1503,7 +1876,7 @@ func (v *Volume) readEbs(inode, inodeSize uint64, path string, writer io.Writer,
 
 	ctx := context.Background()
 	_ = context.WithValue(ctx, "objectnode", 1)
-	reader := v.getEbsReader(inode)
+	reader := v.getEbsReader(inode, false)
 	var n int
 	var rest uint64
 	var tmp = buf.ReadBufPool.Get().([]byte)
@@ -1632,7 +2005,10 @@ func (v *Volume) ObjectMeta(path string) (info *FSFileInfo, xattr *proto.XAttrIn
 		}
 		break
 	}
+	return v.objectMeta(path, inode, mode, inoInfo)
+}
 
+func (v *Volume) objectMeta(path string, inode uint64, mode os.FileMode, inoInfo *proto.InodeInfo) (info *FSFileInfo, xattr *proto.XAttrInfo, err error) {
 	var (
 		etagValue    ETagValue
 		mimeType     string
@@ -1721,6 +2097,15 @@ func (v *Volume) ObjectMeta(path string) (info *FSFileInfo, xattr *proto.XAttrIn
 		CacheControl: cacheControl,
 		Expires:      expires,
 		Metadata:     metadata,
+		VersionId:    string(xattr.Get(XAttrKeyOSSVersionId)),
+		ObjectLock:   objectLockFromXAttr(xattr),
+
+		ReplicationStatus: string(xattr.Get(XAttrKeyOSSReplicationStatus)),
+		StorageClass:      storageClassFromXAttr(xattr),
+		Restore:           parseObjectRestore(string(xattr.Get(XAttrKeyOSSRestore))),
+	}
+	if enc := parseObjectEncryption(string(xattr.Get(XAttrKeyOSSEncryption))); enc != nil && !mode.IsDir() {
+		info.Size, info.Encryption = enc.Size, enc
 	}
 	return
 }
@@ -2339,7 +2724,7 @@ func (v *Volume) supplyListFileInfo(fileInfos []*FSFileInfo) (err error) {
 	}
 
 	// Get MD5 information in batches, then update to fileInfos
-	keys := []string{XAttrKeyOSSETag, XAttrKeyOSSETagDeprecated}
+	keys := []string{XAttrKeyOSSETag, XAttrKeyOSSETagDeprecated, XAttrKeyOSSEncryption, XAttrKeyOSSStorageClass}
 	xattrs, err := v.mw.BatchGetXAttr(inodes, keys)
 	if err != nil {
 		log.LogErrorf("supplyListFileInfo: batch get xattr fail, inodes(%v), err(%v)", inodes, err)
@@ -2349,6 +2734,7 @@ func (v *Volume) supplyListFileInfo(fileInfos []*FSFileInfo) (err error) {
 		return xattrs[i].Inode < xattrs[j].Inode
 	})
 	for _, fileInfo := range fileInfos {
+		fileInfo.StorageClass = StorageClassStandard
 		if fileInfo.Mode.IsDir() {
 			fileInfo.ETag = DirectoryETagValue().ETag()
 			continue
@@ -2357,8 +2743,11 @@ func (v *Volume) supplyListFileInfo(fileInfos []*FSFileInfo) (err error) {
 			return xattrs[i].Inode >= fileInfo.Inode
 		})
 		var etagValue ETagValue
+		var enc *ObjectEncryption
 		if i >= 0 && i < len(xattrs) && xattrs[i].Inode == fileInfo.Inode {
 			var xattr = xattrs[i]
+			enc = parseObjectEncryption(string(xattr.Get(XAttrKeyOSSEncryption)))
+			fileInfo.StorageClass = storageClassFromXAttr(xattr)
 			var rawETag = string(xattr.Get(XAttrKeyOSSETag))
 			if len(rawETag) == 0 {
 				rawETag = string(xattr.Get(XAttrKeyOSSETagDeprecated))
@@ -2377,6 +2766,10 @@ func (v *Volume) supplyListFileInfo(fileInfos []*FSFileInfo) (err error) {
 				v.name, fileInfo.Path, fileInfo.Inode, etagValue)
 		}
 		fileInfo.ETag = etagValue.ETag()
+		// The size of an encrypted object is the size of the plaintext.
+		if enc != nil {
+			fileInfo.Size = enc.Size
+		}
 	}
 	return
 }
@@ -2450,7 +2843,7 @@ func (v *Volume) ListMultipartUploads(prefix, delimiter, keyMarker string, multi
 				Key:          session.Path,
 				UploadId:     session.ID,
 				Initiated:    formatTimeISO(session.InitTime),
-				StorageClass: StorageClassStandard,
+				StorageClass: multipartStorageClass(session),
 			}
 			uploads = append(uploads, fsUpload)
 		}
@@ -2661,10 +3054,30 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 	}
 	tLastName = pathItems[len(pathItems)-1].Name
 
-	// create target file inode and set target inode to be source file inode
-	if tInodeInfo, err = v.mw.InodeCreate_ll(tParentId, uint32(sMode), 0, 0, nil, make([]uint64, 0)); err != nil {
+	// The data of the encrypted objects is decrypted from the source and encrypted with the data key of the target.
+	var sEnc, tEnc *ObjectEncryption
+	if sEnc, err = sv.inodeEncryption(sInode); err != nil {
 		return
 	}
+	// The data of the plain objects in the same hot volume is cloned by the meta node, the extents are shared
+	// by the source and the target instead of being copied.
+	// The quota of the target directory is not applied to the clone.
+	clone := v.name == sv.name && proto.IsHot(v.volType) && !v.mw.EnableQuota && sEnc == nil &&
+		(opt == nil || opt.Encryption == nil)
+
+	// create target file inode and set target inode to be source file inode
+	if clone {
+		if tInodeInfo, err = v.mw.CloneInode_ll(sInode, 0, 0, 0, 0, uint32(sMode), 0, 0); err != nil {
+			log.LogWarnf("CopyFile: clone source inode fail, copy the data instead: volume(%v) source path(%v) inode(%v) err(%v)",
+				v.name, sourcePath, sInode, err)
+			clone = false
+		}
+	}
+	if !clone {
+		if tInodeInfo, err = v.mw.InodeCreate_ll(tParentId, uint32(sMode), 0, 0, nil, make([]uint64, 0)); err != nil {
+			return
+		}
+	}
 	defer func() {
 		// An error has caused the entire process to fail. Delete the inode and release the written data.
 		if err != nil {
@@ -2701,72 +3114,100 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		hashBuf     = make([]byte, 2*util.BlockSize)
 	)
 
-	var sctx context.Context
-	var ebsReader *blobstore.Reader
-	var tctx context.Context
-	var ebsWriter *blobstore.Writer
-	if proto.IsCold(sv.volType) {
-		sctx = context.Background()
-		ebsReader = v.getEbsReader(sInode)
-	}
-	if proto.IsCold(v.volType) {
-		tctx = context.Background()
-		ebsWriter = v.getEbsWriter(tInodeInfo.Inode)
-	}
-
-	for {
-		if rest = int(fileSize) - readOffset; rest <= 0 {
-			break
+	if clone {
+		fileSize = tInodeInfo.Size
+		if md5Value, err = v.cloneContentMD5(sInode, sInodeInfo, tInodeInfo); err != nil {
+			log.LogErrorf("CopyFile: get content md5 of clone fail, volume(%v) path(%v) inode(%v) err(%v)",
+				v.name, targetPath, tInodeInfo.Inode, err)
+			return
 		}
-		readSize = len(buf)
-		if rest < len(buf) {
-			readSize = rest
+	} else if sEnc != nil || (opt != nil && opt.Encryption != nil) {
+		var sDataKey []byte
+		var tOpt *SSEOption
+		if opt != nil {
+			tOpt = opt.Encryption
 		}
-		buf = buf[:readSize]
-		if proto.IsCold(sv.volType) {
-			readN, err = ebsReader.Read(sctx, buf, readOffset, readSize)
-		} else {
-			readN, err = sv.ec.Read(sInode, buf, readOffset, readSize)
+		if sEnc != nil {
+			var sOpt *SSEOption
+			if opt != nil {
+				sOpt = opt.SourceEncryption
+			}
+			if sDataKey, err = sEnc.openDataKey(sOpt); err != nil {
+				return
+			}
 		}
-		if err != nil && err != io.EOF {
+		fileSize, md5Value, tEnc, err = v.copyEncryptedData(sv, sourcePath, sInodeInfo, sEnc, sDataKey, tInodeInfo.Inode, tOpt)
+		if err != nil {
 			return
 		}
+	} else {
+		var sctx context.Context
+		var ebsReader *blobstore.Reader
+		var tctx context.Context
+		var ebsWriter *blobstore.Writer
+		if proto.IsCold(sv.volType) {
+			sctx = context.Background()
+			ebsReader = v.getEbsReader(sInode, false)
+		}
+		if proto.IsCold(v.volType) {
+			tctx = context.Background()
+			ebsWriter = v.getEbsWriter(tInodeInfo.Inode)
+		}
 
-		if readN > 0 {
-			if proto.IsCold(v.volType) {
-				writeN, err = ebsWriter.WriteWithoutPool(tctx, writeOffset, buf[:readN])
+		for {
+			if rest = int(fileSize) - readOffset; rest <= 0 {
+				break
+			}
+			readSize = len(buf)
+			if rest < len(buf) {
+				readSize = rest
+			}
+			buf = buf[:readSize]
+			if proto.IsCold(sv.volType) {
+				readN, err = ebsReader.Read(sctx, buf, readOffset, readSize)
 			} else {
-				writeN, err = v.ec.Write(tInodeInfo.Inode, writeOffset, buf[:readN], 0, nil)
+				readN, err = sv.ec.Read(sInode, buf, readOffset, readSize)
 			}
-			if err != nil {
-				log.LogErrorf("CopyFile: write target path from source fail, volume(%v) path(%v) inode(%v) target offset(%v) err(%v)",
-					v.name, targetPath, tInodeInfo.Inode, writeOffset, err)
+			if err != nil && err != io.EOF {
 				return
 			}
 
-			readOffset += readN
-			writeOffset += writeN
-			// copy to md5 buffer, and then write to md5
-			copy(hashBuf, buf[:readN])
-			md5Hash.Write(hashBuf[:readN])
+			if readN > 0 {
+				if proto.IsCold(v.volType) {
+					writeN, err = ebsWriter.WriteWithoutPool(tctx, writeOffset, buf[:readN])
+				} else {
+					writeN, err = v.ec.Write(tInodeInfo.Inode, writeOffset, buf[:readN], 0, nil)
+				}
+				if err != nil {
+					log.LogErrorf("CopyFile: write target path from source fail, volume(%v) path(%v) inode(%v) target offset(%v) err(%v)",
+						v.name, targetPath, tInodeInfo.Inode, writeOffset, err)
+					return
+				}
+
+				readOffset += readN
+				writeOffset += writeN
+				// copy to md5 buffer, and then write to md5
+				copy(hashBuf, buf[:readN])
+				md5Hash.Write(hashBuf[:readN])
+			}
+			if err == io.EOF {
+				err = nil
+				break
+			}
 		}
-		if err == io.EOF {
-			err = nil
-			break
+		// flush
+		if proto.IsCold(v.volType) {
+			err = ebsWriter.FlushWithoutPool(tInodeInfo.Inode, tctx)
+		} else {
+			v.ec.Flush(tInodeInfo.Inode)
+		}
+		if err != nil {
+			log.LogErrorf("CopyFile: data flush inode fail, volume(%v) inode(%v), path (%v) err(%v)", v.name, tInodeInfo.Inode, targetPath, err)
+			return
 		}
-	}
-	// flush
-	if proto.IsCold(v.volType) {
-		err = ebsWriter.FlushWithoutPool(tInodeInfo.Inode, tctx)
-	} else {
-		v.ec.Flush(tInodeInfo.Inode)
-	}
-	if err != nil {
-		log.LogErrorf("CopyFile: data flush inode fail, volume(%v) inode(%v), path (%v) err(%v)", v.name, tInodeInfo.Inode, targetPath, err)
-		return
-	}
 
-	md5Value = hex.EncodeToString(md5Hash.Sum(nil))
+		md5Value = hex.EncodeToString(md5Hash.Sum(nil))
+	}
 	log.LogDebugf("Audit: copy file: write file finished, volume(%v), path(%v), etag(%v)", v.name, targetPath, md5Value)
 
 	var finalInode *proto.InodeInfo
@@ -2788,6 +3229,9 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		},
 	}
 	targetAttr.XAttrs[XAttrKeyOSSETag] = etagValue.Encode()
+	if tEnc != nil {
+		targetAttr.XAttrs[XAttrKeyOSSEncryption] = tEnc.Encode()
+	}
 
 	// copy source file metadata to write target file metadata
 	if metaDirective != MetadataDirectiveReplace {
@@ -2798,7 +3242,11 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		}
 
 		for key, val := range xattr.XAttrs {
-			if key == XAttrKeyOSSETag {
+			// The version ID, the object lock, the encryption, the replication status, the storage class
+			// and the restore status of the source object are not copied.
+			if key == XAttrKeyOSSETag || key == XAttrKeyOSSVersionId || key == XAttrKeyOSSEncryption ||
+				key == XAttrKeyOSSReplicationStatus || key == XAttrKeyOSSStorageClass || key == XAttrKeyOSSRestore ||
+				proto.IsObjectLockXAttrKey(key) {
 				continue
 			}
 			targetAttr.XAttrs[key] = val
@@ -2806,6 +3254,14 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		if opt != nil && opt.ACL != nil {
 			targetAttr.XAttrs[XAttrKeyOSSACL] = opt.ACL.Encode()
 		}
+		if opt != nil && opt.ObjectLock != nil {
+			for key, value := range opt.ObjectLock.Attrs() {
+				targetAttr.XAttrs[key] = value
+			}
+		}
+		if opt != nil {
+			setStorageClassAttr(targetAttr.XAttrs, opt.StorageClass)
+		}
 		if err = v.mw.BatchSetXAttr_ll(tInodeInfo.Inode, targetAttr.XAttrs); err != nil {
 			log.LogErrorf("CopyFile: set target xattr fail: volume(%v) target path(%v) inode(%v) xattr (%v)err(%v)",
 				v.name, targetPath, tInodeInfo.Inode, xattr, err)
@@ -2832,6 +3288,14 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		if opt != nil && opt.ACL != nil {
 			targetAttr.XAttrs[XAttrKeyOSSACL] = opt.ACL.Encode()
 		}
+		if opt != nil && opt.ObjectLock != nil {
+			for key, value := range opt.ObjectLock.Attrs() {
+				targetAttr.XAttrs[key] = value
+			}
+		}
+		if opt != nil {
+			setStorageClassAttr(targetAttr.XAttrs, opt.StorageClass)
+		}
 
 		// If user-defined metadata have been specified, use extend attributes for storage.
 		if opt != nil && len(opt.Metadata) > 0 {
@@ -2863,14 +3327,19 @@ func (v *Volume) CopyFile(sv *Volume, sourcePath, targetPath, metaDirective stri
 		CreateTime: tInodeInfo.CreateTime,
 		ETag:       md5Value,
 		Inode:      tInodeInfo.Inode,
+		Encryption: tEnc,
 	}
 
 	// apply new inode to dentry
-	err = v.applyInodeToDEntry(tParentId, tLastName, tInodeInfo.Inode)
+	info.VersionId, err = v.applyInodeToDEntry(tParentId, tLastName, tInodeInfo.Inode)
 	if err != nil {
 		log.LogErrorf("CopyFile: apply inode to new dentry fail: path(%v) parentID(%v) name(%v) inode(%v) err(%v)",
 			targetPath, tParentId, tLastName, tInodeInfo.Inode, err)
 	}
+	if info.VersionId != "" && objMetaCache != nil {
+		// The version ID is not in the attrs cached above.
+		objMetaCache.DeleteAttr(v.name, tInodeInfo.Inode)
+	}
 
 	// force updating dentry and attrs in cache
 	if objMetaCache != nil {
@@ -2940,7 +3409,7 @@ func NewVolume(config *VolumeConfig) (*Volume, error) {
 		Masters:           config.Masters,
 		FollowerRead:      true,
 		OnAppendExtentKey: metaWrapper.AppendExtentKey,
-		OnGetExtents:      metaWrapper.GetExtents,
+		OnGetExtents:      metaWrapper.GetExtentsWithShared,
 		OnTruncate:        metaWrapper.Truncate,
 	}
 	if proto.IsCold(volumeInfo.VolType) {
@@ -2961,6 +3430,7 @@ func NewVolume(config *VolumeConfig) (*Volume, error) {
 	v := &Volume{
 		mw:             metaWrapper,
 		ec:             extentClient,
+		mc:             mc,
 		name:           config.Volume,
 		owner:          volumeInfo.Owner,
 		store:          config.Store,
@@ -3014,7 +3484,9 @@ func (v *Volume) getEbsWriter(ino uint64) (writer *blobstore.Writer) {
 	return
 }
 
-func (v *Volume) getEbsReader(ino uint64) (reader *blobstore.Reader) {
+// getEbsReader returns the reader of the blobstore, the data read is cached in the replicas
+// regardless of the cache threshold of the volume if fileCache is set.
+func (v *Volume) getEbsReader(ino uint64, fileCache bool) (reader *blobstore.Reader) {
 	clientConf := blobstore.ClientConfig{
 		VolName:         v.name,
 		VolType:         v.volType,
@@ -3028,7 +3500,7 @@ func (v *Volume) getEbsReader(ino uint64) (reader *blobstore.Reader) {
 		WConcurrency:    writeThreads,
 		ReadConcurrency: readThreads,
 		CacheAction:     v.cacheAction,
-		FileCache:       false,
+		FileCache:       fileCache,
 		FileSize:        0,
 		CacheThreshold:  v.cacheThreshold,
 	}
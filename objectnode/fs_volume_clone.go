@@ -0,0 +1,262 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// The extents shared by the clones are counted by the meta partition of the inodes, so the data can only be
+// cloned into the inodes of the same partition. The part inodes of UploadPartCopy are cloned in the partition
+// of the source object, and the complete inode of the upload is created in the partition of the cloned parts.
+
+// cloneContentMD5 returns the MD5 of the content of the clone, the ETag of the source is reused if it is still
+// valid for the content, otherwise the data of the clone is read.
+func (v *Volume) cloneContentMD5(sInode uint64, sInodeInfo, tInodeInfo *proto.InodeInfo) (md5Value string, err error) {
+	var xattr *proto.XAttrInfo
+	if xattr, err = v.mw.XAttrGet_ll(sInode, XAttrKeyOSSETag); err != nil {
+		return
+	}
+	etagValue := ParseETagValue(string(xattr.Get(XAttrKeyOSSETag)))
+	if etagValue.Valid() && etagValue.PartNum == 0 && !etagValue.TS.Before(sInodeInfo.ModifyTime) &&
+		tInodeInfo.Size == sInodeInfo.Size {
+		return etagValue.Value, nil
+	}
+
+	var (
+		md5Hash = md5.New()
+		buf     = make([]byte, 2*util.BlockSize)
+		offset  int
+		readN   int
+	)
+	for offset < int(tInodeInfo.Size) {
+		size := len(buf)
+		if rest := int(tInodeInfo.Size) - offset; rest < size {
+			size = rest
+		}
+		if readN, err = v.ec.Read(tInodeInfo.Inode, buf, offset, size); err != nil && err != io.EOF {
+			return
+		}
+		md5Hash.Write(buf[:readN])
+		offset += readN
+		if err == io.EOF || readN == 0 {
+			err = nil
+			break
+		}
+	}
+	return hex.EncodeToString(md5Hash.Sum(nil)), nil
+}
+
+// clonedParts returns the inodes of the parts cloned from the source objects.
+func (v *Volume) clonedParts(parts []*proto.MultipartPartInfo) (cloned map[uint64]struct{}, err error) {
+	cloned = make(map[uint64]struct{})
+	if len(parts) == 0 {
+		return
+	}
+	inodes := make([]uint64, 0, len(parts))
+	for _, part := range parts {
+		inodes = append(inodes, part.Inode)
+	}
+	var xattrs []*proto.XAttrInfo
+	if xattrs, err = v.mw.BatchGetXAttr(inodes, []string{XAttrKeyOSSClonedPart}); err != nil {
+		return
+	}
+	for _, xattr := range xattrs {
+		if len(xattr.Get(XAttrKeyOSSClonedPart)) > 0 {
+			cloned[xattr.Inode] = struct{}{}
+		}
+	}
+	return
+}
+
+// ClonePart uploads the part by cloning the range of the source object, EXDEV is returned if the part can not be
+// cloned, in which case the data is copied by WritePart instead.
+func (v *Volume) ClonePart(path string, multipartId string, partId uint16, sv *Volume, sInode, offset, size uint64,
+	sse *SSEOption) (*FSFileInfo, error) {
+	var exist bool
+	var err error
+	defer func() {
+		log.LogInfof("Audit: ClonePart: volume(%v) path(%v) multipartID(%v) partID(%v) source inode(%v) exist(%v) err(%v)",
+			v.name, path, multipartId, partId, sInode, exist, err)
+	}()
+
+	if sv.name != v.name || !proto.IsHot(v.volType) || v.mw.EnableQuota || size == 0 {
+		return nil, syscall.EXDEV
+	}
+	var enc *ObjectEncryption
+	if enc, _, err = v.multipartEncryption(path, multipartId, sse); err != nil {
+		return nil, err
+	}
+	if enc != nil {
+		return nil, syscall.EXDEV
+	}
+	if enc, err = v.inodeEncryption(sInode); err != nil {
+		return nil, err
+	}
+	if enc != nil {
+		return nil, syscall.EXDEV
+	}
+
+	// all the cloned parts of the upload must be in the same partition
+	var multipartInfo *proto.MultipartInfo
+	if multipartInfo, err = v.mw.GetMultipart_ll(path, multipartId); err != nil {
+		return nil, err
+	}
+	var cloned map[uint64]struct{}
+	if cloned, err = v.clonedParts(multipartInfo.Parts); err != nil {
+		return nil, err
+	}
+	for inode := range cloned {
+		if !v.mw.IsSamePartition(sInode, inode) {
+			return nil, syscall.EXDEV
+		}
+	}
+
+	var sInodeInfo *proto.InodeInfo
+	if sInodeInfo, err = v.mw.InodeGet_ll(sInode); err != nil {
+		return nil, err
+	}
+	var partInodeInfo *proto.InodeInfo
+	if partInodeInfo, err = v.mw.CloneInode_ll(sInode, offset, size, 0, 0, DefaultFileMode, 0, 0); err != nil {
+		log.LogWarnf("ClonePart: meta clone inode fail, copy the data instead: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
+			v.name, path, multipartId, partId, sInode, err)
+		return nil, syscall.EXDEV
+	}
+
+	var oldInode uint64
+	defer func() {
+		// An error has caused the entire process to fail. Delete the inode and release the references of the data.
+		if err != nil {
+			log.LogWarnf("ClonePart: unlink part inode: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v)",
+				v.name, path, multipartId, partId, partInodeInfo.Inode)
+			_, _ = v.mw.InodeUnlink_ll(partInodeInfo.Inode)
+			_ = v.mw.Evict(partInodeInfo.Inode)
+		}
+		// Delete the old inode and release the written data.
+		if exist {
+			log.LogWarnf("ClonePart: unlink old part inode: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v)",
+				v.name, path, multipartId, partId, oldInode)
+			_, _ = v.mw.InodeUnlink_ll(oldInode)
+			_ = v.mw.Evict(oldInode)
+		}
+	}()
+	if partInodeInfo.Size != size {
+		log.LogErrorf("ClonePart: source changed: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) size(%v) cloned(%v)",
+			v.name, path, multipartId, partId, sInode, size, partInodeInfo.Size)
+		err = io.ErrUnexpectedEOF
+		return nil, err
+	}
+	if err = v.mw.XAttrSet_ll(partInodeInfo.Inode, []byte(XAttrKeyOSSClonedPart), []byte("true")); err != nil {
+		log.LogErrorf("ClonePart: meta set cloned part fail: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
+			v.name, path, multipartId, partId, partInodeInfo.Inode, err)
+		return nil, err
+	}
+
+	if err = v.ec.OpenStream(partInodeInfo.Inode); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := v.ec.CloseStream(partInodeInfo.Inode); closeErr != nil {
+			log.LogErrorf("ClonePart: data close stream fail: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
+				v.name, path, multipartId, partId, partInodeInfo.Inode, closeErr)
+		}
+	}()
+	var etag string
+	if etag, err = v.cloneContentMD5(sInode, sInodeInfo, partInodeInfo); err != nil {
+		log.LogErrorf("ClonePart: get content md5 fail: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) err(%v)",
+			v.name, path, multipartId, partId, partInodeInfo.Inode, err)
+		return nil, err
+	}
+
+	oldInode, exist, err = v.mw.AddMultipartPart_ll(path, multipartId, partId, size, etag, partInodeInfo)
+	if err != nil {
+		log.LogErrorf("ClonePart: meta add multipart part fail: volume(%v) path(%v) multipartID(%v) partID(%v) inode(%v) size(%v) MD5(%v) err(%v)",
+			v.name, path, multipartId, partId, partInodeInfo.Inode, size, etag, err)
+		return nil, err
+	}
+	_, fileName := splitPath(path)
+	return &FSFileInfo{
+		Path:       fileName,
+		Size:       int64(size),
+		Mode:       os.FileMode(DefaultFileMode),
+		ModifyTime: time.Now(),
+		CreateTime: partInodeInfo.CreateTime,
+		ETag:       etag,
+		Inode:      partInodeInfo.Inode,
+	}, nil
+}
+
+// copyClonedPart copies the data of the part cloned in another partition into a new inode in the partition of
+// the complete inode, the extents of which can be moved to the complete inode.
+func (v *Volume) copyClonedPart(part *proto.MultipartPartInfo, completeInode uint64) (inode uint64, err error) {
+	var info *proto.InodeInfo
+	if info, err = v.mw.InodeCreateNear_ll(completeInode, DefaultFileMode, 0, 0); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_, _ = v.mw.InodeUnlink_ll(info.Inode)
+			_ = v.mw.Evict(info.Inode)
+		}
+	}()
+	for _, ino := range []uint64{part.Inode, info.Inode} {
+		if err = v.ec.OpenStream(ino); err != nil {
+			return
+		}
+		defer func(ino uint64) {
+			if closeErr := v.ec.CloseStream(ino); closeErr != nil {
+				log.LogWarnf("copyClonedPart: data close stream fail: volume(%v) inode(%v) err(%v)", v.name, ino, closeErr)
+			}
+		}(ino)
+	}
+
+	var (
+		buf    = make([]byte, 2*util.BlockSize)
+		offset int
+		readN  int
+	)
+	for offset < int(part.Size) {
+		size := len(buf)
+		if rest := int(part.Size) - offset; rest < size {
+			size = rest
+		}
+		if readN, err = v.ec.Read(part.Inode, buf, offset, size); err != nil && err != io.EOF {
+			return
+		}
+		if readN == 0 {
+			err = io.ErrUnexpectedEOF
+			return
+		}
+		if _, err = v.ec.Write(info.Inode, offset, buf[:readN], 0, nil); err != nil {
+			return
+		}
+		offset += readN
+	}
+	if err = v.ec.Flush(info.Inode); err != nil {
+		return
+	}
+	log.LogDebugf("copyClonedPart: volume(%v) partID(%v) inode(%v) copied to inode(%v) size(%v)",
+		v.name, part.ID, part.Inode, info.Inode, part.Size)
+	return info.Inode, nil
+}
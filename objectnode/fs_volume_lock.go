@@ -0,0 +1,93 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// inodeObjectLock returns the retention and legal hold of the inode read from the meta node.
+func (v *Volume) inodeObjectLock(inode uint64) (lock *proto.ObjectLock, err error) {
+	var xattr *proto.XAttrInfo
+	if xattr, err = v.mw.XAttrGetAll_ll(inode); err != nil {
+		log.LogErrorf("inodeObjectLock: get xattr fail: volume(%v) inode(%v) err(%v)", v.name, inode, err)
+		return
+	}
+	return objectLockFromXAttr(xattr), nil
+}
+
+// checkVersionDeletable returns proto.ErrObjectLocked if the object version can not be deleted permanently.
+// The retention in governance mode is removed if the request bypasses the governance retention.
+func (v *Volume) checkVersionDeletable(inode uint64, bypassGovernance bool) (err error) {
+	var lock *proto.ObjectLock
+	if lock, err = v.inodeObjectLock(inode); err != nil {
+		return
+	}
+	if !lock.Locked(time.Now().Unix()) {
+		return
+	}
+	if !bypassGovernance || lock.LegalHold || lock.Mode != proto.ObjectLockModeGovernance {
+		return proto.ErrObjectLocked
+	}
+	return v.SetObjectRetention(inode, &proto.ObjectLock{})
+}
+
+// SetObjectRetention sets the retention of the object version, and the retention is removed if the mode is empty.
+// The retention in compliance mode is checked by the meta node, so an syscall.EPERM error is returned if
+// it is shortened or removed.
+func (v *Volume) SetObjectRetention(inode uint64, lock *proto.ObjectLock) (err error) {
+	defer func() {
+		if objMetaCache != nil {
+			objMetaCache.DeleteAttr(v.name, inode)
+		}
+	}()
+	if lock.Mode == "" {
+		for _, key := range []string{proto.XAttrKeyObjectLockMode, proto.XAttrKeyObjectLockRetainUntil} {
+			if err = v.mw.XAttrDel_ll(inode, key); err != nil {
+				log.LogErrorf("SetObjectRetention: delete xattr fail: volume(%v) inode(%v) key(%v) err(%v)",
+					v.name, inode, key, err)
+				return
+			}
+		}
+		return
+	}
+	var attrs = (&proto.ObjectLock{Mode: lock.Mode, RetainUntil: lock.RetainUntil}).Attrs()
+	if err = v.mw.BatchSetXAttr_ll(inode, attrs); err != nil {
+		log.LogErrorf("SetObjectRetention: set xattr fail: volume(%v) inode(%v) attrs(%v) err(%v)",
+			v.name, inode, attrs, err)
+	}
+	return
+}
+
+// SetObjectLegalHold turns the legal hold of the object version on or off.
+func (v *Volume) SetObjectLegalHold(inode uint64, on bool) (err error) {
+	defer func() {
+		if objMetaCache != nil {
+			objMetaCache.DeleteAttr(v.name, inode)
+		}
+	}()
+	var status = proto.ObjectLockLegalHoldOff
+	if on {
+		status = proto.ObjectLockLegalHoldOn
+	}
+	if err = v.mw.XAttrSet_ll(inode, []byte(proto.XAttrKeyObjectLockLegalHold), []byte(status)); err != nil {
+		log.LogErrorf("SetObjectLegalHold: set xattr fail: volume(%v) inode(%v) status(%v) err(%v)",
+			v.name, inode, status, err)
+	}
+	return
+}
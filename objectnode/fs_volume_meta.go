@@ -18,6 +18,8 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/cubefs/cubefs/proto"
+
 	"golang.org/x/sync/singleflight"
 )
 
@@ -25,12 +27,33 @@ type ossMetaLoader interface {
 	loadPolicy() (p *Policy, err error)
 	loadACL() (p *AccessControlPolicy, err error)
 	loadCORS() (cors *CORSConfiguration, err error)
+	loadLifecycle() (lifecycle *LifecycleConfiguration, err error)
+	loadVersioning() (versioning *VersioningConfiguration, err error)
+	loadObjectLock() (objectLock *ObjectLockConfiguration, err error)
+	loadEncryption() (encryption *ServerSideEncryptionConfiguration, err error)
+	loadReplication() (replication *ReplicationConfiguration, err error)
+	loadNotification() (notification *NotificationConfiguration, err error)
+	loadWebsite() (website *WebsiteConfiguration, err error)
+	loadPublicAccessBlock() (block *PublicAccessBlockConfiguration, err error)
+	loadOwnerPublicAccessBlock() (block *proto.PublicAccessBlock, err error)
 	storePolicy(p *Policy)
 	storeACL(p *AccessControlPolicy)
 	storeCORS(cors *CORSConfiguration)
+	storeLifecycle(lifecycle *LifecycleConfiguration)
+	storeVersioning(versioning *VersioningConfiguration)
+	storeObjectLock(objectLock *ObjectLockConfiguration)
+	storeEncryption(encryption *ServerSideEncryptionConfiguration)
+	storeReplication(replication *ReplicationConfiguration)
+	storeNotification(notification *NotificationConfiguration)
+	storeWebsite(website *WebsiteConfiguration)
+	storePublicAccessBlock(block *PublicAccessBlockConfiguration)
+	storeOwnerPublicAccessBlock(block *proto.PublicAccessBlock)
 	setSynced()
 }
 
+// ownerPublicAccessBlockKey is the singleflight key to load the public access block settings of the bucket owner.
+const ownerPublicAccessBlockKey = "owner:public-access-block"
+
 type strictMetaLoader struct {
 	v *Volume
 }
@@ -44,12 +67,29 @@ type cacheMetaLoader struct {
 
 // OSSMeta is bucket policy and ACL metadata.
 type OSSMeta struct {
-	policy     *Policy
-	acl        *AccessControlPolicy
-	corsConfig *CORSConfiguration
-	policyLock sync.RWMutex
-	aclLock    sync.RWMutex
-	corsLock   sync.RWMutex
+	policy       *Policy
+	acl          *AccessControlPolicy
+	corsConfig   *CORSConfiguration
+	lifecycle    *LifecycleConfiguration
+	versioning   *VersioningConfiguration
+	objectLock   *ObjectLockConfiguration
+	encryption   *ServerSideEncryptionConfiguration
+	replication  *ReplicationConfiguration
+	notification *NotificationConfiguration
+	website      *WebsiteConfiguration
+	publicBlock  *PublicAccessBlockConfiguration
+	ownerBlock   *proto.PublicAccessBlock
+	policyLock   sync.RWMutex
+	aclLock      sync.RWMutex
+	corsLock     sync.RWMutex
+	lcLock       sync.RWMutex
+	verLock      sync.RWMutex
+	olLock       sync.RWMutex
+	sseLock      sync.RWMutex
+	replLock     sync.RWMutex
+	notifyLock   sync.RWMutex
+	websiteLock  sync.RWMutex
+	blockLock    sync.RWMutex
 }
 
 func (c *cacheMetaLoader) loadPolicy() (p *Policy, err error) {
@@ -127,6 +167,231 @@ func (c *cacheMetaLoader) storeCORS(cors *CORSConfiguration) {
 	return
 }
 
+func (c *cacheMetaLoader) loadLifecycle() (lifecycle *LifecycleConfiguration, err error) {
+	c.om.lcLock.RLock()
+	lifecycle = c.om.lifecycle
+	c.om.lcLock.RUnlock()
+	if lifecycle == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(XAttrKeyOSSLifecycle, func() (interface{}, error) {
+			lc, err := c.sml.loadLifecycle()
+			return lc, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		lifecycle = ret.(*LifecycleConfiguration)
+		c.storeLifecycle(lifecycle)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storeLifecycle(lifecycle *LifecycleConfiguration) {
+	c.om.lcLock.Lock()
+	c.om.lifecycle = lifecycle
+	c.om.lcLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadVersioning() (versioning *VersioningConfiguration, err error) {
+	c.om.verLock.RLock()
+	versioning = c.om.versioning
+	c.om.verLock.RUnlock()
+	if versioning == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(XAttrKeyOSSVersioning, func() (interface{}, error) {
+			v, err := c.sml.loadVersioning()
+			return v, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		versioning = ret.(*VersioningConfiguration)
+		c.storeVersioning(versioning)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storeVersioning(versioning *VersioningConfiguration) {
+	c.om.verLock.Lock()
+	c.om.versioning = versioning
+	c.om.verLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadObjectLock() (objectLock *ObjectLockConfiguration, err error) {
+	c.om.olLock.RLock()
+	objectLock = c.om.objectLock
+	c.om.olLock.RUnlock()
+	if objectLock == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(XAttrKeyOSSObjectLock, func() (interface{}, error) {
+			ol, err := c.sml.loadObjectLock()
+			return ol, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		objectLock = ret.(*ObjectLockConfiguration)
+		c.storeObjectLock(objectLock)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storeObjectLock(objectLock *ObjectLockConfiguration) {
+	c.om.olLock.Lock()
+	c.om.objectLock = objectLock
+	c.om.olLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadEncryption() (encryption *ServerSideEncryptionConfiguration, err error) {
+	c.om.sseLock.RLock()
+	encryption = c.om.encryption
+	c.om.sseLock.RUnlock()
+	if encryption == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(XAttrKeyOSSBucketSSE, func() (interface{}, error) {
+			sse, err := c.sml.loadEncryption()
+			return sse, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		encryption = ret.(*ServerSideEncryptionConfiguration)
+		c.storeEncryption(encryption)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storeEncryption(encryption *ServerSideEncryptionConfiguration) {
+	c.om.sseLock.Lock()
+	c.om.encryption = encryption
+	c.om.sseLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadReplication() (replication *ReplicationConfiguration, err error) {
+	c.om.replLock.RLock()
+	replication = c.om.replication
+	c.om.replLock.RUnlock()
+	if replication == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(XAttrKeyOSSReplication, func() (interface{}, error) {
+			repl, err := c.sml.loadReplication()
+			return repl, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		replication = ret.(*ReplicationConfiguration)
+		c.storeReplication(replication)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storeReplication(replication *ReplicationConfiguration) {
+	c.om.replLock.Lock()
+	c.om.replication = replication
+	c.om.replLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadNotification() (notification *NotificationConfiguration, err error) {
+	c.om.notifyLock.RLock()
+	notification = c.om.notification
+	c.om.notifyLock.RUnlock()
+	if notification == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(XAttrKeyOSSNotification, func() (interface{}, error) {
+			n, err := c.sml.loadNotification()
+			return n, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		notification = ret.(*NotificationConfiguration)
+		c.storeNotification(notification)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storeNotification(notification *NotificationConfiguration) {
+	c.om.notifyLock.Lock()
+	c.om.notification = notification
+	c.om.notifyLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadWebsite() (website *WebsiteConfiguration, err error) {
+	c.om.websiteLock.RLock()
+	website = c.om.website
+	c.om.websiteLock.RUnlock()
+	if website == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(XAttrKeyOSSWebsite, func() (interface{}, error) {
+			w, err := c.sml.loadWebsite()
+			return w, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		website = ret.(*WebsiteConfiguration)
+		c.storeWebsite(website)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storeWebsite(website *WebsiteConfiguration) {
+	c.om.websiteLock.Lock()
+	c.om.website = website
+	c.om.websiteLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadPublicAccessBlock() (block *PublicAccessBlockConfiguration, err error) {
+	c.om.blockLock.RLock()
+	block = c.om.publicBlock
+	c.om.blockLock.RUnlock()
+	if block == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(XAttrKeyOSSPublicBlock, func() (interface{}, error) {
+			b, err := c.sml.loadPublicAccessBlock()
+			return b, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		block = ret.(*PublicAccessBlockConfiguration)
+		c.storePublicAccessBlock(block)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storePublicAccessBlock(block *PublicAccessBlockConfiguration) {
+	c.om.blockLock.Lock()
+	c.om.publicBlock = block
+	c.om.blockLock.Unlock()
+	return
+}
+
+func (c *cacheMetaLoader) loadOwnerPublicAccessBlock() (block *proto.PublicAccessBlock, err error) {
+	c.om.blockLock.RLock()
+	block = c.om.ownerBlock
+	c.om.blockLock.RUnlock()
+	if block == nil && atomic.LoadInt32(c.synced) == 0 {
+		ret, err, _ := c.sf.Do(ownerPublicAccessBlockKey, func() (interface{}, error) {
+			b, err := c.sml.loadOwnerPublicAccessBlock()
+			return b, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		block = ret.(*proto.PublicAccessBlock)
+		c.storeOwnerPublicAccessBlock(block)
+	}
+	return
+}
+
+func (c *cacheMetaLoader) storeOwnerPublicAccessBlock(block *proto.PublicAccessBlock) {
+	c.om.blockLock.Lock()
+	c.om.ownerBlock = block
+	c.om.blockLock.Unlock()
+	return
+}
+
 func (c *cacheMetaLoader) setSynced() {
 	atomic.StoreInt32(c.synced, 1)
 }
@@ -149,4 +414,58 @@ func (s *strictMetaLoader) loadCORS() (cors *CORSConfiguration, err error) {
 
 func (s *strictMetaLoader) storeCORS(cors *CORSConfiguration) {}
 
+func (s *strictMetaLoader) loadLifecycle() (lifecycle *LifecycleConfiguration, err error) {
+	return s.v.loadBucketLifecycle()
+}
+
+func (s *strictMetaLoader) storeLifecycle(lifecycle *LifecycleConfiguration) {}
+
+func (s *strictMetaLoader) loadVersioning() (versioning *VersioningConfiguration, err error) {
+	return s.v.loadBucketVersioning()
+}
+
+func (s *strictMetaLoader) storeVersioning(versioning *VersioningConfiguration) {}
+
+func (s *strictMetaLoader) loadObjectLock() (objectLock *ObjectLockConfiguration, err error) {
+	return s.v.loadBucketObjectLock()
+}
+
+func (s *strictMetaLoader) storeObjectLock(objectLock *ObjectLockConfiguration) {}
+
+func (s *strictMetaLoader) loadEncryption() (encryption *ServerSideEncryptionConfiguration, err error) {
+	return s.v.loadBucketEncryption()
+}
+
+func (s *strictMetaLoader) storeEncryption(encryption *ServerSideEncryptionConfiguration) {}
+
+func (s *strictMetaLoader) loadReplication() (replication *ReplicationConfiguration, err error) {
+	return s.v.loadBucketReplication()
+}
+
+func (s *strictMetaLoader) storeReplication(replication *ReplicationConfiguration) {}
+
+func (s *strictMetaLoader) loadNotification() (notification *NotificationConfiguration, err error) {
+	return s.v.loadBucketNotification()
+}
+
+func (s *strictMetaLoader) storeNotification(notification *NotificationConfiguration) {}
+
+func (s *strictMetaLoader) loadWebsite() (website *WebsiteConfiguration, err error) {
+	return s.v.loadBucketWebsite()
+}
+
+func (s *strictMetaLoader) storeWebsite(website *WebsiteConfiguration) {}
+
+func (s *strictMetaLoader) loadPublicAccessBlock() (block *PublicAccessBlockConfiguration, err error) {
+	return s.v.loadBucketPublicAccessBlock()
+}
+
+func (s *strictMetaLoader) storePublicAccessBlock(block *PublicAccessBlockConfiguration) {}
+
+func (s *strictMetaLoader) loadOwnerPublicAccessBlock() (block *proto.PublicAccessBlock, err error) {
+	return s.v.loadOwnerPublicAccessBlock()
+}
+
+func (s *strictMetaLoader) storeOwnerPublicAccessBlock(block *proto.PublicAccessBlock) {}
+
 func (s *strictMetaLoader) setSynced() {}
@@ -0,0 +1,138 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/buf"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// An ongoing restore which is not finished in time is regarded as failed, e.g. the objectnode restarts,
+// and the object can be restored again.
+const restoreOngoingTimeout = 24 * time.Hour
+
+// RestoreObject stages a temporary hot copy of the archived object for the specified days.
+// The data is read from the blobstore and cached in the replicas in the background, and the restore
+// status is recorded in the extended attributes of the inode, so that each version is restored separately.
+// It returns true if the object has been restored, in which case only the expiry date is updated.
+func (v *Volume) RestoreObject(info *FSFileInfo, days int) (restored bool, err error) {
+	var now = time.Now()
+	if restore := info.Restore; restore != nil && restore.Ongoing &&
+		now.Sub(time.Unix(restore.RequestDate, 0)) < restoreOngoingTimeout {
+		return false, RestoreAlreadyInProgress
+	}
+	if info.Restore.restored(now) {
+		var restore = &ObjectRestore{Days: days, RequestDate: now.Unix(), ExpiryDate: restoreExpiryDate(now, days).Unix()}
+		return true, v.storeObjectRestore(info.Inode, restore)
+	}
+	if err = v.storeObjectRestore(info.Inode, &ObjectRestore{Ongoing: true, Days: days, RequestDate: now.Unix()}); err != nil {
+		return
+	}
+	go v.stageRestoredCopy(info.Path, info.Inode, days)
+	return false, nil
+}
+
+func (v *Volume) stageRestoredCopy(path string, inode uint64, days int) {
+	var err error
+	defer func() {
+		if err != nil {
+			log.LogErrorf("stageRestoredCopy: restore object fail: volume(%v) path(%v) inode(%v) err(%v)",
+				v.name, path, inode, err)
+			// Clear the ongoing status so that the object can be restored again.
+			if err = v.deleteObjectRestore(inode); err != nil {
+				log.LogErrorf("stageRestoredCopy: clear restore status fail: volume(%v) path(%v) inode(%v) err(%v)",
+					v.name, path, inode, err)
+			}
+			return
+		}
+		var now = time.Now()
+		var restore = &ObjectRestore{Days: days, RequestDate: now.Unix(), ExpiryDate: restoreExpiryDate(now, days).Unix()}
+		if err = v.storeObjectRestore(inode, restore); err != nil {
+			log.LogErrorf("stageRestoredCopy: store restore status fail: volume(%v) path(%v) inode(%v) err(%v)",
+				v.name, path, inode, err)
+			return
+		}
+		log.LogInfof("Audit: restore object: volume(%v) path(%v) inode(%v) days(%v)", v.name, path, inode, days)
+	}()
+
+	var inoInfo *proto.InodeInfo
+	if inoInfo, err = v.mw.InodeGet_ll(inode); err != nil {
+		return
+	}
+	if err = v.ec.OpenStream(inode); err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := v.ec.CloseStream(inode); closeErr != nil {
+			log.LogErrorf("stageRestoredCopy: data close stream fail: volume(%v) inode(%v) err(%v)", v.name, inode, closeErr)
+		}
+	}()
+
+	// The blocks read are cached in the replicas regardless of the cache threshold of the volume,
+	// and the cached data is evicted according to the cache TTL of the volume.
+	var (
+		reader = v.getEbsReader(inode, true)
+		tmp    = buf.ReadBufPool.Get().([]byte)
+		offset int
+		n      int
+	)
+	defer buf.ReadBufPool.Put(tmp)
+	for uint64(offset) < inoInfo.Size {
+		var readSize = len(tmp)
+		if rest := int(inoInfo.Size - uint64(offset)); rest < readSize {
+			readSize = rest
+		}
+		if n, err = reader.Read(context.Background(), tmp[:readSize], offset, readSize); err != nil && err != io.EOF {
+			return
+		}
+		err = nil
+		if n == 0 {
+			break
+		}
+		offset += n
+	}
+}
+
+func (v *Volume) storeObjectRestore(inode uint64, restore *ObjectRestore) (err error) {
+	var raw = restore.Encode()
+	if err = v.mw.XAttrSet_ll(inode, []byte(XAttrKeyOSSRestore), []byte(raw)); err != nil {
+		return
+	}
+	if objMetaCache != nil {
+		attrItem := &AttrItem{
+			XAttrInfo: proto.XAttrInfo{
+				Inode:  inode,
+				XAttrs: map[string]string{XAttrKeyOSSRestore: raw},
+			},
+		}
+		objMetaCache.MergeAttr(v.name, attrItem)
+	}
+	return
+}
+
+func (v *Volume) deleteObjectRestore(inode uint64) (err error) {
+	if err = v.mw.XAttrDel_ll(inode, XAttrKeyOSSRestore); err != nil {
+		return
+	}
+	if objMetaCache != nil {
+		objMetaCache.DeleteAttrWithKey(v.name, inode, XAttrKeyOSSRestore)
+	}
+	return
+}
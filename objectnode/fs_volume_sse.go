@@ -0,0 +1,157 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"crypto/cipher"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// multipartEncryption returns the encryption of the multipart upload and its data key,
+// or nil if the upload is not encrypted.
+func (v *Volume) multipartEncryption(path, multipartID string, opt *SSEOption) (enc *ObjectEncryption, dataKey []byte, err error) {
+	var multipartInfo *proto.MultipartInfo
+	if multipartInfo, err = v.mw.GetMultipart_ll(path, multipartID); err != nil {
+		return
+	}
+	if enc = parseObjectEncryption(multipartInfo.Extend[XAttrKeyOSSEncryption]); enc == nil {
+		return
+	}
+	if dataKey, err = enc.openDataKey(opt); err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+// inodeEncryption returns the encryption of the object, or nil if the object is not encrypted.
+func (v *Volume) inodeEncryption(inode uint64) (enc *ObjectEncryption, err error) {
+	var xattr *proto.XAttrInfo
+	if xattr, err = v.mw.XAttrGet_ll(inode, XAttrKeyOSSEncryption); err != nil {
+		log.LogErrorf("inodeEncryption: get xattr fail: volume(%v) inode(%v) err(%v)", v.name, inode, err)
+		return
+	}
+	return parseObjectEncryption(string(xattr.Get(XAttrKeyOSSEncryption))), nil
+}
+
+// loadPartStreams collects the streams of the parts kept by the part inodes in the order of the parts.
+func (v *Volume) loadPartStreams(enc *ObjectEncryption, parts []*proto.MultipartPartInfo) (err error) {
+	enc.Size, enc.Streams = 0, make([]*SSEStream, 0, len(parts))
+	for _, part := range parts {
+		var xattr *proto.XAttrInfo
+		if xattr, err = v.mw.XAttrGet_ll(part.Inode, XAttrKeyOSSEncryption); err != nil {
+			log.LogErrorf("loadPartStreams: get part xattr fail: volume(%v) partID(%v) inode(%v) err(%v)",
+				v.name, part.ID, part.Inode, err)
+			return
+		}
+		var stream = new(SSEStream)
+		if err = json.Unmarshal(xattr.Get(XAttrKeyOSSEncryption), stream); err != nil {
+			log.LogErrorf("loadPartStreams: unmarshal part stream fail: volume(%v) partID(%v) inode(%v) err(%v)",
+				v.name, part.ID, part.Inode, err)
+			return
+		}
+		enc.Size += stream.Size
+		enc.Streams = append(enc.Streams, stream)
+	}
+	return
+}
+
+// readObject writes the plaintext in the range of the object to the writer. The data key is
+// required if the object is encrypted.
+func (v *Volume) readObject(info *FSFileInfo, dataKey []byte, path string, writer io.Writer, offset, size uint64) (err error) {
+	if info.Encryption == nil {
+		return v.readFile(info.Inode, uint64(info.Size), path, writer, offset, size)
+	}
+	return v.readEncryptedFile(info.Inode, uint64(info.Encryption.cipherSize()), info.Encryption, dataKey, path, writer, offset, size)
+}
+
+func (v *Volume) readEncryptedFile(inode, inodeSize uint64, enc *ObjectEncryption, dataKey []byte, path string,
+	writer io.Writer, offset, size uint64) (err error) {
+	var aead cipher.AEAD
+	if aead, err = newSSEAEAD(dataKey); err != nil {
+		return
+	}
+	for _, rr := range enc.readRanges(int64(offset), int64(size)) {
+		var dw = newSSEDecryptWriter(writer, aead, rr.stream.Nonce, rr.index, rr.skip, rr.length)
+		if err = v.readFile(inode, inodeSize, path, dw, uint64(rr.offset), uint64(rr.size)); err != nil {
+			return
+		}
+		if err = dw.Flush(); err != nil {
+			log.LogErrorf("readEncryptedFile: decrypt fail: volume(%v) path(%v) inode(%v) offset(%v) size(%v) err(%v)",
+				v.name, path, inode, rr.offset, rr.size, err)
+			return
+		}
+	}
+	return
+}
+
+// copyEncryptedData copies the plaintext of the source object to the target inode, which is encrypted
+// if the encryption of the target object is specified.
+func (v *Volume) copyEncryptedData(sv *Volume, sourcePath string, sInodeInfo *proto.InodeInfo, sEnc *ObjectEncryption,
+	sDataKey []byte, tInode uint64, opt *SSEOption) (size uint64, md5Value string, tEnc *ObjectEncryption, err error) {
+	var reader, writer = io.Pipe()
+	defer reader.Close()
+	go func() {
+		var readErr error
+		if sEnc != nil {
+			readErr = sv.readEncryptedFile(sInodeInfo.Inode, sInodeInfo.Size, sEnc, sDataKey, sourcePath, writer, 0, uint64(sEnc.Size))
+		} else {
+			readErr = sv.readFile(sInodeInfo.Inode, sInodeInfo.Size, sourcePath, writer, 0, sInodeInfo.Size)
+		}
+		writer.CloseWithError(readErr)
+	}()
+
+	var (
+		md5Hash             = md5.New()
+		source    io.Reader = reader
+		writeHash hash.Hash = md5Hash
+		encReader *sseEncryptReader
+	)
+	if opt != nil {
+		var dataKey []byte
+		if tEnc, dataKey, err = newObjectEncryption(opt); err != nil {
+			return
+		}
+		var stream *SSEStream
+		if encReader, stream, err = newSSEStreamReader(reader, dataKey, md5Hash); err != nil {
+			return
+		}
+		tEnc.Streams = []*SSEStream{stream}
+		source, writeHash = encReader, nil
+	}
+	if proto.IsCold(v.volType) {
+		size, err = v.ebsWrite(tInode, source, writeHash)
+	} else if size, err = v.streamWrite(tInode, source, writeHash); err == nil {
+		err = v.ec.Flush(tInode)
+	}
+	if err != nil {
+		log.LogErrorf("copyEncryptedData: write target fail: volume(%v) source volume(%v) source path(%v) inode(%v) err(%v)",
+			v.name, sv.name, sourcePath, tInode, err)
+		return
+	}
+	if tEnc != nil {
+		tEnc.Size = encReader.Size()
+		tEnc.Streams[0].Size = tEnc.Size
+		size = uint64(tEnc.Size)
+	}
+	md5Value = hex.EncodeToString(md5Hash.Sum(nil))
+	return
+}
@@ -0,0 +1,976 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/metanode"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/meta"
+	"github.com/cubefs/cubefs/util/log"
+
+	"github.com/google/uuid"
+)
+
+const (
+	versionListBatch   = 1000
+	versionUpdateRetry = 3
+)
+
+// ObjectVersion is the record of a noncurrent version or a delete marker of an object.
+//
+// The current version of an object is the inode which the dentry points to, and its version ID is
+// stored in the extend attributes of the inode. The records of the noncurrent versions and delete
+// markers are stored in the extend attributes of the parent directory inode, one key per record under
+// the prefix of the object name, and the key of the newer record is greater. The records are appended
+// and removed atomically by the meta node, so the objectnodes change the versions of the same object
+// concurrently without locks. The inodes of the noncurrent versions have no dentry, just like the parts
+// of multipart uploads.
+type ObjectVersion struct {
+	VersionId    string `json:"vid"`
+	Inode        uint64 `json:"ino,omitempty"`
+	DeleteMarker bool   `json:"dm,omitempty"`
+	ModifyTime   int64  `json:"mt,omitempty"` // Creation time of delete marker
+
+	// The key and the value of the record loaded, which is removed only if not changed.
+	key string
+	raw string
+}
+
+type ObjectVersions []*ObjectVersion
+
+func (vs ObjectVersions) find(versionId string) int {
+	for i, version := range vs {
+		if version.VersionId == versionId {
+			return i
+		}
+	}
+	return -1
+}
+
+func (vs ObjectVersions) remove(i int) ObjectVersions {
+	return append(vs[:i:i], vs[i+1:]...)
+}
+
+func (vs ObjectVersions) prepend(version *ObjectVersion) ObjectVersions {
+	return append(ObjectVersions{version}, vs...)
+}
+
+type DeleteObjectResult struct {
+	VersionId    string
+	DeleteMarker bool
+}
+
+type ListObjectVersionsOption struct {
+	Prefix          string
+	Delimiter       string
+	KeyMarker       string
+	VersionIdMarker string
+	MaxKeys         uint64
+}
+
+type ListObjectVersionsResult struct {
+	Versions            []*FSFileInfo
+	CommonPrefixes      []string
+	NextKeyMarker       string
+	NextVersionIdMarker string
+	Truncated           bool
+}
+
+func newVersionId() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// objectVersionsPrefix returns the prefix of the keys of the version records of the object.
+func objectVersionsPrefix(name string) string {
+	return XAttrKeyOSSVersionsPrefix + name + pathSep
+}
+
+// objectVersionsName returns the object name of the version record key.
+func objectVersionsName(key string) (name string, ok bool) {
+	if !strings.HasPrefix(key, XAttrKeyOSSVersionsPrefix) {
+		return
+	}
+	var rest = key[len(XAttrKeyOSSVersionsPrefix):]
+	var idx = strings.LastIndex(rest, pathSep)
+	if idx < 0 {
+		return
+	}
+	return rest[:idx], true
+}
+
+func (v *Volume) versioningStatus() (status string, err error) {
+	var versioning *VersioningConfiguration
+	if versioning, err = v.metaLoader.loadVersioning(); err != nil || versioning == nil {
+		return
+	}
+	return versioning.Status, nil
+}
+
+// loadObjectVersions returns the version records of the object from the newest to the oldest.
+func (v *Volume) loadObjectVersions(parentID uint64, name string) (versions ObjectVersions, err error) {
+	var records map[string]string
+	if records, err = v.mw.XAttrRecords_ll(parentID, objectVersionsPrefix(name)); err != nil {
+		log.LogErrorf("loadObjectVersions: meta get records fail: volume(%v) parentID(%v) name(%v) err(%v)",
+			v.name, parentID, name, err)
+		return
+	}
+	var keys = make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	versions = make(ObjectVersions, 0, len(keys))
+	for _, key := range keys {
+		var version = &ObjectVersion{key: key, raw: records[key]}
+		if err = json.Unmarshal([]byte(version.raw), version); err != nil {
+			log.LogErrorf("loadObjectVersions: unmarshal fail: volume(%v) parentID(%v) key(%v) raw(%v) err(%v)",
+				v.name, parentID, key, version.raw, err)
+			return
+		}
+		versions = append(versions, version)
+	}
+	return
+}
+
+// updateObjectVersions removes the record loaded and appends the new one as the newest, either of them may
+// be nil. syscall.ENOENT is returned if the record removed is changed by others, in which case nothing is done.
+func (v *Volume) updateObjectVersions(parentID uint64, name string, removed, appended *ObjectVersion) (err error) {
+	var remove map[string]string
+	if removed != nil {
+		remove = map[string]string{removed.key: removed.raw}
+	}
+	var raw []byte
+	if appended != nil {
+		if raw, err = json.Marshal(appended); err != nil {
+			return
+		}
+	}
+	_, err = v.mw.UpdateXAttrRecords_ll(parentID, objectVersionsPrefix(name), remove, string(raw))
+	return
+}
+
+// appendObjectVersion records the version as the newest one of the object. If replaceNull is set, the record
+// of the null version is removed at the same time and its inode is released, which is looked up again if it
+// is changed by others meanwhile. The version is nil if the null version is removed only.
+func (v *Volume) appendObjectVersion(parentID uint64, name string, version *ObjectVersion, replaceNull bool) (err error) {
+	var removed *ObjectVersion
+	for i := 0; ; i++ {
+		removed = nil
+		if replaceNull {
+			var versions ObjectVersions
+			if versions, err = v.loadObjectVersions(parentID, name); err != nil {
+				return
+			}
+			if j := versions.find(NullVersionId); j >= 0 {
+				removed = versions[j]
+			}
+		}
+		if removed == nil && version == nil {
+			return
+		}
+		if err = v.updateObjectVersions(parentID, name, removed, version); err != syscall.ENOENT || i >= versionUpdateRetry {
+			break
+		}
+	}
+	if err != nil {
+		log.LogErrorf("appendObjectVersion: update versions fail: volume(%v) parentID(%v) name(%v) version(%v) removed(%v) err(%v)",
+			v.name, parentID, name, version, removed, err)
+		return
+	}
+	if removed != nil && removed.Inode != 0 {
+		v.releaseVersionInode(removed.Inode)
+	}
+	return
+}
+
+// hasObjectVersions checks whether there are any noncurrent versions or delete markers recorded in the directory.
+func (v *Volume) hasObjectVersions(dirIno uint64) (has bool, err error) {
+	var keys []string
+	if keys, err = v.mw.XAttrsList_ll(dirIno); err != nil {
+		return
+	}
+	for _, key := range keys {
+		if strings.HasPrefix(key, XAttrKeyOSSVersionsPrefix) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (v *Volume) inodeVersionId(inode uint64) (versionId string, err error) {
+	var info *proto.XAttrInfo
+	if info, err = v.mw.XAttrGet_ll(inode, XAttrKeyOSSVersionId); err != nil {
+		return
+	}
+	if versionId = string(info.Get(XAttrKeyOSSVersionId)); versionId == "" {
+		versionId = NullVersionId
+	}
+	return
+}
+
+// releaseVersionInode unlinks and evicts the inode of a noncurrent version which has no dentry.
+func (v *Volume) releaseVersionInode(inode uint64) {
+	log.LogWarnf("releaseVersionInode: unlink inode: volume(%v) inode(%v)", v.name, inode)
+	if _, err := v.mw.InodeUnlink_ll(inode); err != nil {
+		log.LogWarnf("releaseVersionInode: unlink inode fail: volume(%v) inode(%v) err(%v)", v.name, inode, err)
+	}
+	v.evictVersionInode(inode)
+}
+
+func (v *Volume) evictVersionInode(inode uint64) {
+	if err := v.ec.EvictStream(inode); err != nil {
+		log.LogWarnf("evictVersionInode: evict stream fail: volume(%v) inode(%v) err(%v)", v.name, inode, err)
+	}
+	if objMetaCache != nil {
+		objMetaCache.DeleteAttr(v.name, inode)
+	}
+	log.LogWarnf("evictVersionInode: evict inode: volume(%v) inode(%v)", v.name, inode)
+	if err := v.mw.Evict(inode); err != nil {
+		log.LogWarnf("evictVersionInode: evict inode fail: volume(%v) inode(%v) err(%v)", v.name, inode, err)
+	}
+}
+
+func (v *Volume) deleteDentryCache(parentID uint64, name string) {
+	if objMetaCache != nil {
+		var dentry = &DentryItem{
+			Dentry: metanode.Dentry{
+				ParentId: parentID,
+				Name:     name,
+			},
+		}
+		objMetaCache.DeleteDentry(v.name, dentry.Key())
+	}
+}
+
+// detachCurrentVersion removes the dentry of the current version and keeps the inode as a noncurrent version.
+func (v *Volume) detachCurrentVersion(parentID uint64, name string, inode uint64) (err error) {
+	// Delete_ll unlinks the inode, so link it first to prevent it from being freed.
+	if _, err = v.mw.InodeLink_ll(inode); err != nil {
+		log.LogErrorf("detachCurrentVersion: link inode fail: volume(%v) inode(%v) err(%v)", v.name, inode, err)
+		return
+	}
+	if _, err = v.mw.Delete_ll(parentID, name, false, ""); err != nil {
+		log.LogErrorf("detachCurrentVersion: delete dentry fail: volume(%v) parentID(%v) name(%v) err(%v)",
+			v.name, parentID, name, err)
+		_, _ = v.mw.InodeUnlink_ll(inode)
+		return
+	}
+	v.deleteDentryCache(parentID, name)
+	return
+}
+
+// lookupObjectParent returns the parent directory inode and the name of the object.
+func (v *Volume) lookupObjectParent(path string) (parentID uint64, name string, err error) {
+	var pathItems = NewPathIterator(path).ToSlice()
+	if len(pathItems) == 0 || pathItems[len(pathItems)-1].IsDirectory {
+		err = syscall.ENOENT
+		return
+	}
+	parentID = rootIno
+	for _, item := range pathItems[:len(pathItems)-1] {
+		var mode uint32
+		if parentID, mode, err = v.mw.Lookup_ll(parentID, item.Name); err != nil {
+			return
+		}
+		if !os.FileMode(mode).IsDir() {
+			err = syscall.ENOENT
+			return
+		}
+	}
+	name = pathItems[len(pathItems)-1].Name
+	return
+}
+
+// applyInodeToVersionedDEntry applies the new inode to the dentry of a bucket with versioning enabled or suspended.
+// Instead of being deleted, the version replaced is kept as a noncurrent version unless both of it and the new
+// one are the null version.
+func (v *Volume) applyInodeToVersionedDEntry(parentID uint64, name string, inode uint64, status string) (versionId string, err error) {
+	var newVersion = NullVersionId
+	if status == VersioningStatusEnabled {
+		newVersion = newVersionId()
+		if err = v.mw.XAttrSet_ll(inode, []byte(XAttrKeyOSSVersionId), []byte(newVersion)); err != nil {
+			log.LogErrorf("applyInodeToVersionedDEntry: set version id fail: volume(%v) inode(%v) err(%v)",
+				v.name, inode, err)
+			return
+		}
+		versionId = newVersion
+	}
+
+	var (
+		existIno  uint64
+		existMode uint32
+		detached  *ObjectVersion
+	)
+	existIno, existMode, err = v.mw.Lookup_ll(parentID, name)
+	if err != nil && err != syscall.ENOENT {
+		log.LogErrorf("applyInodeToVersionedDEntry: meta lookup fail: parentID(%v) name(%v) err(%v)", parentID, name, err)
+		return
+	}
+	if err == syscall.ENOENT {
+		if err = v.applyInodeToNewDentry(parentID, name, inode); err != nil {
+			return
+		}
+	} else {
+		if os.FileMode(existMode).IsDir() {
+			log.LogErrorf("applyInodeToVersionedDEntry: target mode conflict: parentID(%v) name(%v) mode(%v)",
+				parentID, name, os.FileMode(existMode).String())
+			err = syscall.EINVAL
+			return
+		}
+		var existVersion string
+		if existVersion, err = v.inodeVersionId(existIno); err != nil {
+			log.LogErrorf("applyInodeToVersionedDEntry: get version id fail: volume(%v) inode(%v) err(%v)",
+				v.name, existIno, err)
+			return
+		}
+		var oldInode uint64
+		if oldInode, err = v.mw.DentryUpdate_ll(parentID, name, inode); err != nil {
+			log.LogErrorf("applyInodeToVersionedDEntry: meta update dentry fail: parentID(%v) name(%v) inode(%v) err(%v)",
+				parentID, name, inode, err)
+			return
+		}
+		if oldInode != 0 && oldInode != existIno {
+			// The dentry has been changed by others.
+			if existVersion, err = v.inodeVersionId(oldInode); err != nil {
+				existVersion = newVersionId()
+			}
+		}
+		if oldInode != 0 {
+			if existVersion == NullVersionId && newVersion == NullVersionId {
+				v.releaseVersionInode(oldInode)
+			} else {
+				detached = &ObjectVersion{VersionId: existVersion, Inode: oldInode}
+			}
+		}
+	}
+	err = nil
+
+	// The null version can only be overwritten.
+	var replaceNull = newVersion == NullVersionId
+	if detached == nil && !replaceNull {
+		return
+	}
+	if appendErr := v.appendObjectVersion(parentID, name, detached, replaceNull); appendErr != nil {
+		// The new version has been applied, so the failure is not returned and only the
+		// replaced version is leaked.
+		log.LogErrorf("applyInodeToVersionedDEntry: store versions fail: volume(%v) parentID(%v) name(%v) detached(%v) err(%v)",
+			v.name, parentID, name, detached, appendErr)
+	}
+	return
+}
+
+// DeleteObject deletes the object as the DeleteObject API of a versioned bucket does.
+//
+// Without version ID, a delete marker is inserted as the latest version if versioning of the bucket is enabled
+// or suspended. With version ID, the specified version is deleted permanently, and if it is the latest version,
+// the newest noncurrent version becomes the current version.
+// For the buckets versioning is never enabled, it is same as DeletePath.
+//
+// The versions protected by object lock can not be deleted permanently, and proto.ErrObjectLocked is returned.
+// If bypassGovernance is true, the retention in governance mode is removed before deleting.
+func (v *Volume) DeleteObject(path, versionId string, bypassGovernance bool) (result *DeleteObjectResult, err error) {
+	defer func() {
+		// Audit behavior
+		log.LogInfof("Audit: DeleteObject: volume(%v) path(%v) versionId(%v) bypassGovernance(%v) result(%+v) err(%v)",
+			v.name, path, versionId, bypassGovernance, result, err)
+	}()
+	result = new(DeleteObjectResult)
+
+	var status string
+	if status, err = v.versioningStatus(); err != nil {
+		return
+	}
+	if versionId == "" && status == "" || strings.HasSuffix(path, pathSep) {
+		err = v.DeletePath(path)
+		return
+	}
+
+	var (
+		parentID uint64
+		name     string
+	)
+	if parentID, name, err = v.lookupObjectParent(path); err != nil {
+		if err == syscall.ENOENT {
+			err = nil
+		}
+		return
+	}
+
+	var versions ObjectVersions
+	if versions, err = v.loadObjectVersions(parentID, name); err != nil {
+		return
+	}
+	var (
+		currentIno     uint64
+		currentMode    uint32
+		currentVersion string
+		hasCurrent     bool
+	)
+	currentIno, currentMode, err = v.mw.Lookup_ll(parentID, name)
+	if err != nil && err != syscall.ENOENT {
+		return
+	}
+	if err == nil {
+		if os.FileMode(currentMode).IsDir() {
+			err = nil
+			return
+		}
+		if currentVersion, err = v.inodeVersionId(currentIno); err != nil {
+			return
+		}
+		hasCurrent = true
+	}
+	err = nil
+
+	if versionId == "" {
+		// Insert a delete marker.
+		var marker = &ObjectVersion{
+			VersionId:    NullVersionId,
+			DeleteMarker: true,
+			ModifyTime:   time.Now().Unix(),
+		}
+		if status == VersioningStatusEnabled {
+			marker.VersionId = newVersionId()
+		}
+		var replaceNull = marker.VersionId == NullVersionId
+		if replaceNull {
+			if i := versions.find(NullVersionId); i >= 0 && versions[i].Inode != 0 {
+				if err = v.checkVersionDeletable(versions[i].Inode, bypassGovernance); err != nil {
+					return
+				}
+			}
+		}
+		if hasCurrent {
+			if currentVersion == NullVersionId && replaceNull {
+				if err = v.checkVersionDeletable(currentIno, bypassGovernance); err != nil {
+					return
+				}
+				if _, err = v.mw.Delete_ll(parentID, name, false, "/"+strings.TrimPrefix(path, "/")); err != nil {
+					return
+				}
+				v.deleteDentryCache(parentID, name)
+				v.evictVersionInode(currentIno)
+			} else {
+				if err = v.detachCurrentVersion(parentID, name, currentIno); err != nil {
+					return
+				}
+				var detached = &ObjectVersion{VersionId: currentVersion, Inode: currentIno}
+				if err = v.appendObjectVersion(parentID, name, detached, replaceNull); err != nil {
+					return
+				}
+				replaceNull = false
+			}
+		}
+		// The delete marker is newer than the current version detached.
+		if err = v.appendObjectVersion(parentID, name, marker, replaceNull); err != nil {
+			return
+		}
+		result.VersionId, result.DeleteMarker = marker.VersionId, true
+		return
+	}
+
+	result.VersionId = versionId
+	var promote bool
+	if hasCurrent && currentVersion == versionId {
+		if err = v.checkVersionDeletable(currentIno, bypassGovernance); err != nil {
+			return
+		}
+		if _, err = v.mw.Delete_ll(parentID, name, false, "/"+strings.TrimPrefix(path, "/")); err != nil {
+			return
+		}
+		v.deleteDentryCache(parentID, name)
+		v.evictVersionInode(currentIno)
+		promote = true
+	} else if i := versions.find(versionId); i >= 0 {
+		var version = versions[i]
+		result.DeleteMarker = version.DeleteMarker
+		if version.Inode != 0 {
+			if err = v.checkVersionDeletable(version.Inode, bypassGovernance); err != nil {
+				return
+			}
+		}
+		if err = v.updateObjectVersions(parentID, name, version, nil); err == syscall.ENOENT {
+			// The version has been deleted by others.
+			err = nil
+			return
+		}
+		if err != nil {
+			log.LogErrorf("DeleteObject: remove version fail: volume(%v) path(%v) version(%v) err(%v)",
+				v.name, path, version, err)
+			return
+		}
+		if version.Inode != 0 {
+			v.releaseVersionInode(version.Inode)
+		}
+		promote = i == 0 && !hasCurrent
+	} else {
+		return
+	}
+	if promote {
+		err = v.promoteObjectVersion(parentID, name)
+	}
+	return
+}
+
+// promoteObjectVersion makes the newest noncurrent version the current version, after the latest version is
+// deleted. The record is removed before the dentry is created, so that the version is promoted only once.
+func (v *Volume) promoteObjectVersion(parentID uint64, name string) (err error) {
+	var versions ObjectVersions
+	if versions, err = v.loadObjectVersions(parentID, name); err != nil {
+		return
+	}
+	if len(versions) == 0 || versions[0].DeleteMarker {
+		return
+	}
+	var version = versions[0]
+	if err = v.updateObjectVersions(parentID, name, version, nil); err != nil {
+		if err == syscall.ENOENT {
+			// The version has been promoted or deleted by others.
+			err = nil
+		}
+		return
+	}
+	if err = v.mw.DentryCreate_ll(parentID, name, version.Inode, DefaultFileMode); err != nil {
+		log.LogErrorf("promoteObjectVersion: restore noncurrent version fail: volume(%v) parentID(%v) name(%v) version(%v) err(%v)",
+			v.name, parentID, name, version, err)
+		if appendErr := v.appendObjectVersion(parentID, name, version, false); appendErr != nil {
+			log.LogErrorf("promoteObjectVersion: keep noncurrent version fail: volume(%v) parentID(%v) name(%v) version(%v) err(%v)",
+				v.name, parentID, name, version, appendErr)
+		}
+		if err == syscall.EEXIST {
+			// The object has been put by others.
+			err = nil
+		}
+	}
+	return
+}
+
+// ObjectVersionMeta returns the meta of the specified version of the object, or the latest version if the
+// version ID is empty. If the version is a delete marker, the DeleteMarker of the returned info is true.
+// An syscall.ENOENT error is returned if the version does not exist.
+func (v *Volume) ObjectVersionMeta(path, versionId string) (info *FSFileInfo, xattr *proto.XAttrInfo, err error) {
+	if versionId == "" {
+		if info, xattr, err = v.ObjectMeta(path); err != syscall.ENOENT {
+			return
+		}
+		// The latest version of the object may be a delete marker.
+		if status, _ := v.versioningStatus(); status == "" {
+			return
+		}
+	}
+
+	var (
+		parentID uint64
+		name     string
+	)
+	if parentID, name, err = v.lookupObjectParent(path); err != nil {
+		return
+	}
+	var (
+		currentIno  uint64
+		currentMode uint32
+		hasCurrent  bool
+	)
+	currentIno, currentMode, err = v.mw.Lookup_ll(parentID, name)
+	if err != nil && err != syscall.ENOENT {
+		return
+	}
+	hasCurrent = err == nil && !os.FileMode(currentMode).IsDir()
+	if hasCurrent {
+		if versionId == "" {
+			return v.ObjectMeta(path)
+		}
+		var currentVersion string
+		if currentVersion, err = v.inodeVersionId(currentIno); err != nil {
+			return
+		}
+		if currentVersion == versionId {
+			if info, xattr, err = v.inodeObjectMeta(path, currentIno); err != nil {
+				return
+			}
+			info.VersionId, info.IsLatest = versionId, true
+			return
+		}
+	}
+
+	var versions ObjectVersions
+	if versions, err = v.loadObjectVersions(parentID, name); err != nil {
+		return
+	}
+	var i = 0
+	if versionId != "" {
+		i = versions.find(versionId)
+	}
+	if i < 0 || i >= len(versions) || versionId == "" && !versions[i].DeleteMarker {
+		err = syscall.ENOENT
+		return
+	}
+	var version = versions[i]
+	if version.DeleteMarker {
+		info = &FSFileInfo{
+			Path:         path,
+			ModifyTime:   time.Unix(version.ModifyTime, 0),
+			VersionId:    version.VersionId,
+			DeleteMarker: true,
+			IsLatest:     i == 0 && !hasCurrent,
+		}
+		return
+	}
+	if info, xattr, err = v.inodeObjectMeta(path, version.Inode); err != nil {
+		return
+	}
+	info.VersionId = version.VersionId
+	return
+}
+
+func (v *Volume) inodeObjectMeta(path string, inode uint64) (info *FSFileInfo, xattr *proto.XAttrInfo, err error) {
+	var inoInfo *proto.InodeInfo
+	if inoInfo, err = v.mw.InodeGet_ll(inode); err != nil {
+		log.LogErrorf("inodeObjectMeta: get inode fail: volume(%v) path(%v) inode(%v) err(%v)",
+			v.name, path, inode, err)
+		return
+	}
+	return v.objectMeta(path, inode, os.FileMode(inoInfo.Mode), inoInfo)
+}
+
+// ListObjectVersions lists all versions and delete markers of the objects in the order of the key,
+// and the versions of the same object from the newest to the oldest.
+func (v *Volume) ListObjectVersions(opt *ListObjectVersionsOption) (result *ListObjectVersionsResult, err error) {
+	result = new(ListObjectVersionsResult)
+
+	var (
+		parentID uint64
+		dirs     []string
+	)
+	parentID, dirs, err = v.findParentId(opt.Prefix)
+	if err == syscall.ENOENT {
+		return result, nil
+	}
+	if err != nil {
+		log.LogErrorf("ListObjectVersions: find parent ID fail: volume(%v) prefix(%v) err(%v)", v.name, opt.Prefix, err)
+		return
+	}
+
+	var walker = &versionWalker{v: v, opt: opt, result: result}
+	if err = walker.walk(parentID, dirs); err != nil {
+		log.LogErrorf("ListObjectVersions: walk fail: volume(%v) option(%+v) err(%v)", v.name, opt, err)
+		return
+	}
+
+	// Supplement the version IDs of the current versions and the file information.
+	var (
+		infos   = make([]*FSFileInfo, 0, len(result.Versions))
+		current = make([]uint64, 0)
+	)
+	for _, info := range result.Versions {
+		if info.DeleteMarker {
+			continue
+		}
+		infos = append(infos, info)
+		if info.VersionId == "" {
+			current = append(current, info.Inode)
+		}
+	}
+	if len(current) > 0 {
+		var xattrs []*proto.XAttrInfo
+		if xattrs, err = v.mw.BatchGetXAttr(current, []string{XAttrKeyOSSVersionId}); err != nil {
+			log.LogErrorf("ListObjectVersions: batch get xattr fail: volume(%v) err(%v)", v.name, err)
+			return
+		}
+		var versionIds = make(map[uint64]string, len(xattrs))
+		for _, xattr := range xattrs {
+			versionIds[xattr.Inode] = string(xattr.Get(XAttrKeyOSSVersionId))
+		}
+		for _, info := range infos {
+			if info.VersionId == "" {
+				if info.VersionId = versionIds[info.Inode]; info.VersionId == "" {
+					info.VersionId = NullVersionId
+				}
+			}
+		}
+	}
+	if len(infos) > 0 {
+		if err = v.supplyListFileInfo(infos); err != nil {
+			return
+		}
+	}
+
+	if result.Truncated && walker.last != nil {
+		result.NextKeyMarker, result.NextVersionIdMarker = walker.last.Path, walker.last.VersionId
+	} else if result.Truncated {
+		result.NextKeyMarker = walker.lastPrefix
+	}
+	return
+}
+
+// versionWalker walks the directory tree in depth-first order, merging the object names of the dentries
+// and the version records of each directory.
+type versionWalker struct {
+	v          *Volume
+	opt        *ListObjectVersionsOption
+	result     *ListObjectVersionsResult
+	count      uint64
+	last       *FSFileInfo
+	lastPrefix string
+	done       bool
+}
+
+func (w *versionWalker) walk(dirIno uint64, dirs []string) (err error) {
+	var currentPath string
+	if len(dirs) > 0 {
+		currentPath = strings.Join(dirs, pathSep) + pathSep
+	}
+
+	// The names in this directory which are lower than the names of the prefix and key marker at this
+	// level need not to be scanned.
+	var prefixName, markerName string
+	var markerDeeper bool
+	if len(w.opt.Prefix) > len(currentPath) && strings.HasPrefix(w.opt.Prefix, currentPath) {
+		prefixName = w.opt.Prefix[len(currentPath):]
+	}
+	if w.opt.KeyMarker != "" && strings.HasPrefix(w.opt.KeyMarker, currentPath) {
+		markerName = w.opt.KeyMarker[len(currentPath):]
+		if idx := strings.Index(markerName, pathSep); idx >= 0 {
+			markerName, markerDeeper = markerName[:idx], true
+		}
+	}
+	var fromName = prefixName
+	if markerName > fromName {
+		fromName = markerName
+	}
+
+	var keys []string
+	if keys, err = w.v.mw.XAttrsList_ll(dirIno); err != nil {
+		if err == syscall.ENOENT {
+			err = nil
+		}
+		return
+	}
+	var (
+		versionNames = make([]string, 0)
+		seen         = make(map[string]struct{})
+	)
+	for _, key := range keys {
+		if name, ok := objectVersionsName(key); ok && name >= fromName {
+			if _, ok = seen[name]; !ok {
+				seen[name] = struct{}{}
+				versionNames = append(versionNames, name)
+			}
+		}
+	}
+	sort.Strings(versionNames)
+
+	var dentries = &dentryIterator{mw: w.v.mw, parentID: dirIno, from: fromName}
+	var vi int
+	for !w.done {
+		var dentry *proto.Dentry
+		if dentry, err = dentries.peek(); err != nil {
+			return
+		}
+		var (
+			name       string
+			hasVersion bool
+		)
+		switch {
+		case dentry == nil && vi >= len(versionNames):
+			return
+		case dentry == nil || vi < len(versionNames) && versionNames[vi] < dentry.Name:
+			name, hasVersion, dentry = versionNames[vi], true, nil
+			vi++
+		case vi < len(versionNames) && versionNames[vi] == dentry.Name:
+			name, hasVersion = dentry.Name, true
+			dentries.next()
+			vi++
+		default:
+			name = dentry.Name
+			dentries.next()
+		}
+		if prefixName != "" && !strings.HasPrefix(name, prefixName) {
+			if name > prefixName {
+				return
+			}
+			continue
+		}
+		if markerName != "" && name < markerName {
+			continue
+		}
+
+		var key = currentPath + name
+		var isDir = dentry != nil && os.FileMode(dentry.Type).IsDir()
+		if hasVersion || dentry != nil && !isDir {
+			var current *proto.Dentry
+			if !isDir {
+				current = dentry
+			}
+			// The object is lower than the key marker if the key marker is deeper.
+			if !(name == markerName && markerDeeper) {
+				if err = w.walkObject(dirIno, name, key, current, hasVersion, name == markerName); err != nil {
+					return
+				}
+			}
+		}
+		if isDir && !w.done {
+			if prefix, ok := w.commonPrefix(key + pathSep); ok {
+				w.addPrefix(prefix)
+				continue
+			}
+			if err = w.walk(dentry.Inode, append(dirs, name)); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (w *versionWalker) walkObject(dirIno uint64, name, key string, current *proto.Dentry, hasVersion, atMarker bool) (err error) {
+	if atMarker && w.opt.VersionIdMarker == "" {
+		// All versions of the key marker have been listed.
+		return
+	}
+	if prefix, ok := w.commonPrefix(key); ok {
+		w.addPrefix(prefix)
+		return
+	}
+
+	var infos = make([]*FSFileInfo, 0)
+	if current != nil {
+		var info = &FSFileInfo{Path: key, Inode: current.Inode}
+		if atMarker {
+			// The version ID is required to locate the version ID marker, the others are supplied in batches.
+			if info.VersionId, err = w.v.inodeVersionId(current.Inode); err != nil {
+				return
+			}
+		}
+		infos = append(infos, info)
+	}
+	if hasVersion {
+		var versions ObjectVersions
+		if versions, err = w.v.loadObjectVersions(dirIno, name); err != nil {
+			return
+		}
+		for _, version := range versions {
+			var info = &FSFileInfo{
+				Path:         key,
+				Inode:        version.Inode,
+				VersionId:    version.VersionId,
+				DeleteMarker: version.DeleteMarker,
+			}
+			if version.DeleteMarker {
+				info.ModifyTime = time.Unix(version.ModifyTime, 0)
+			}
+			infos = append(infos, info)
+		}
+	}
+	if len(infos) > 0 {
+		infos[0].IsLatest = true
+	}
+
+	var skip = atMarker
+	for _, info := range infos {
+		if skip {
+			skip = info.VersionId != w.opt.VersionIdMarker
+			continue
+		}
+		if !w.add(info) {
+			return
+		}
+	}
+	return
+}
+
+func (w *versionWalker) commonPrefix(key string) (prefix string, ok bool) {
+	if w.opt.Delimiter == "" || !strings.HasPrefix(key, w.opt.Prefix) {
+		return
+	}
+	var rest = key[len(w.opt.Prefix):]
+	var idx = strings.Index(rest, w.opt.Delimiter)
+	if idx < 0 {
+		return
+	}
+	return w.opt.Prefix + rest[:idx+len(w.opt.Delimiter)], true
+}
+
+func (w *versionWalker) addPrefix(prefix string) {
+	if prefix == w.lastPrefix || w.opt.KeyMarker != "" && strings.HasPrefix(w.opt.KeyMarker, prefix) {
+		return
+	}
+	if w.count >= w.opt.MaxKeys {
+		w.result.Truncated, w.done = true, true
+		return
+	}
+	w.result.CommonPrefixes = append(w.result.CommonPrefixes, prefix)
+	w.lastPrefix, w.last = prefix, nil
+	w.count++
+}
+
+func (w *versionWalker) add(info *FSFileInfo) bool {
+	if w.count >= w.opt.MaxKeys {
+		w.result.Truncated, w.done = true, true
+		return false
+	}
+	w.result.Versions = append(w.result.Versions, info)
+	w.last = info
+	w.count++
+	return true
+}
+
+// dentryIterator reads the dentries of a directory in batches.
+type dentryIterator struct {
+	mw       *meta.MetaWrapper
+	parentID uint64
+	from     string
+	dentries []proto.Dentry
+	index    int
+	eof      bool
+	started  bool
+}
+
+func (it *dentryIterator) peek() (dentry *proto.Dentry, err error) {
+	for it.index >= len(it.dentries) && !it.eof {
+		var dentries []proto.Dentry
+		if dentries, err = it.mw.ReadDirLimit_ll(it.parentID, it.from, versionListBatch); err != nil {
+			if err == syscall.ENOENT {
+				it.eof, err = true, nil
+				break
+			}
+			return
+		}
+		it.eof = len(dentries) < versionListBatch
+		// The dentry named from has been returned in the last batch.
+		if it.started && len(dentries) > 0 && dentries[0].Name == it.from {
+			dentries = dentries[1:]
+		}
+		it.started = true
+		if len(dentries) > 0 {
+			it.from = dentries[len(dentries)-1].Name
+		}
+		it.dentries, it.index = dentries, 0
+	}
+	if it.index < len(it.dentries) {
+		dentry = &it.dentries[it.index]
+	}
+	return
+}
+
+func (it *dentryIterator) next() {
+	it.index++
+}
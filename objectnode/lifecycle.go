@@ -0,0 +1,274 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/object-lifecycle-mgmt.html
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+const (
+	LifecycleStatusEnabled  = "Enabled"
+	LifecycleStatusDisabled = "Disabled"
+
+	MaxLifecycleRules     = 1000
+	MaxLifecycleRuleIDLen = 255
+)
+
+type LifecycleConfiguration struct {
+	XMLName xml.Name         `xml:"LifecycleConfiguration" json:"-"`
+	Rules   []*LifecycleRule `xml:"Rule" json:"rules"`
+}
+
+type LifecycleRule struct {
+	ID                             string                          `xml:"ID,omitempty" json:"id,omitempty"`
+	Status                         string                          `xml:"Status" json:"status"`
+	Prefix                         string                          `xml:"Prefix,omitempty" json:"prefix,omitempty"` // Deprecated, replaced by Filter
+	Filter                         *LifecycleFilter                `xml:"Filter,omitempty" json:"filter,omitempty"`
+	Expiration                     *LifecycleExpiration            `xml:"Expiration,omitempty" json:"expiration,omitempty"`
+	AbortIncompleteMultipartUpload *AbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload,omitempty" json:"abort_mpu,omitempty"`
+}
+
+type LifecycleFilter struct {
+	Prefix string                `xml:"Prefix,omitempty" json:"prefix,omitempty"`
+	Tag    *Tag                  `xml:"Tag,omitempty" json:"tag,omitempty"`
+	And    *LifecycleAndOperator `xml:"And,omitempty" json:"and,omitempty"`
+}
+
+type LifecycleAndOperator struct {
+	Prefix string `xml:"Prefix,omitempty" json:"prefix,omitempty"`
+	Tags   []Tag  `xml:"Tag,omitempty" json:"tags,omitempty"`
+}
+
+type LifecycleExpiration struct {
+	Days int    `xml:"Days,omitempty" json:"days,omitempty"`
+	Date string `xml:"Date,omitempty" json:"date,omitempty"`
+}
+
+type AbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation,omitempty" json:"days_after_initiation,omitempty"`
+}
+
+func NewLifecycleConfiguration() *LifecycleConfiguration {
+	return &LifecycleConfiguration{
+		XMLName: xml.Name{Local: "LifecycleConfiguration"},
+	}
+}
+
+func parseLifecycleConfig(bytes []byte) (config *LifecycleConfiguration, errCode *ErrorCode) {
+	config = NewLifecycleConfiguration()
+	if err := xml.Unmarshal(bytes, config); err != nil {
+		return nil, MalformedXML
+	}
+	if errCode = config.validate(); errCode != nil {
+		return nil, errCode
+	}
+	return config, nil
+}
+
+func (config *LifecycleConfiguration) validate() *ErrorCode {
+	if len(config.Rules) == 0 {
+		return MalformedXML
+	}
+	if len(config.Rules) > MaxLifecycleRules {
+		return NewError("InvalidRequest", "The number of lifecycle rules should not exceed allowed limit of 1000 rules.", 400)
+	}
+	var ids = make(map[string]struct{})
+	for _, rule := range config.Rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+		if rule.ID == "" {
+			continue
+		}
+		if _, has := ids[rule.ID]; has {
+			return NewError("InvalidArgument", "Rule ID must be unique. Found same ID for more than one rule.", 400)
+		}
+		ids[rule.ID] = struct{}{}
+	}
+	return nil
+}
+
+func (rule *LifecycleRule) validate() *ErrorCode {
+	if len(rule.ID) > MaxLifecycleRuleIDLen {
+		return NewError("InvalidArgument", "ID length should not exceed allowed limit of 255.", 400)
+	}
+	if rule.Status != LifecycleStatusEnabled && rule.Status != LifecycleStatusDisabled {
+		return MalformedXML
+	}
+	if rule.Prefix != "" && rule.Filter != nil {
+		return MalformedXML
+	}
+	if rule.Filter != nil {
+		if err := rule.Filter.validate(); err != nil {
+			return err
+		}
+	}
+	if rule.Expiration == nil && rule.AbortIncompleteMultipartUpload == nil {
+		return NewError("InvalidRequest", "At least one action needs to be specified in a rule.", 400)
+	}
+	if rule.Expiration != nil {
+		if err := rule.Expiration.validate(); err != nil {
+			return err
+		}
+	}
+	if rule.AbortIncompleteMultipartUpload != nil {
+		if rule.AbortIncompleteMultipartUpload.DaysAfterInitiation <= 0 {
+			return NewError("InvalidArgument", "'DaysAfterInitiation' for AbortIncompleteMultipartUpload action must be a positive integer.", 400)
+		}
+		if len(rule.filterTags()) > 0 {
+			return NewError("InvalidRequest", "AbortIncompleteMultipartUpload cannot be specified with Tags.", 400)
+		}
+	}
+	return nil
+}
+
+func (f *LifecycleFilter) validate() *ErrorCode {
+	var count int
+	if f.Prefix != "" {
+		count++
+	}
+	if f.Tag != nil {
+		count++
+	}
+	if f.And != nil {
+		count++
+	}
+	// Filter can have only one of Prefix, Tag or And.
+	if count > 1 {
+		return MalformedXML
+	}
+	if f.Tag != nil && !isValidLifecycleTag(*f.Tag) {
+		return InvalidTagKey
+	}
+	if f.And != nil {
+		for _, tag := range f.And.Tags {
+			if !isValidLifecycleTag(tag) {
+				return InvalidTagKey
+			}
+		}
+	}
+	return nil
+}
+
+func isValidLifecycleTag(tag Tag) bool {
+	return tag.Key != "" && len(tag.Key) <= TaggingKeyMaxLength && len(tag.Value) <= TaggingValueMaxLength
+}
+
+func (e *LifecycleExpiration) validate() *ErrorCode {
+	if e.Days == 0 && e.Date == "" {
+		return MalformedXML
+	}
+	if e.Days != 0 && e.Date != "" {
+		return MalformedXML
+	}
+	if e.Days < 0 {
+		return NewError("InvalidArgument", "'Days' for Expiration action must be a positive integer.", 400)
+	}
+	if e.Date != "" {
+		date, err := time.Parse(time.RFC3339, e.Date)
+		if err != nil {
+			return NewError("InvalidArgument", "'Date' must be in ISO 8601 format.", 400)
+		}
+		if !date.Equal(date.UTC().Truncate(24 * time.Hour)) {
+			return NewError("InvalidArgument", "'Date' must be at midnight GMT.", 400)
+		}
+	}
+	return nil
+}
+
+func (rule *LifecycleRule) enabled() bool {
+	return rule.Status == LifecycleStatusEnabled
+}
+
+func (rule *LifecycleRule) filterPrefix() string {
+	if rule.Filter == nil {
+		return rule.Prefix
+	}
+	return rule.Filter.prefix()
+}
+
+func (rule *LifecycleRule) filterTags() []Tag {
+	if rule.Filter == nil {
+		return nil
+	}
+	return rule.Filter.tags()
+}
+
+// matchObject checks whether the object with the given key and tags is selected by the rule filter.
+func (rule *LifecycleRule) matchObject(key string, tags map[string]string) bool {
+	return matchFilter(rule.filterPrefix(), rule.filterTags(), key, tags)
+}
+
+func (f *LifecycleFilter) prefix() string {
+	if f.And != nil {
+		return f.And.Prefix
+	}
+	return f.Prefix
+}
+
+func (f *LifecycleFilter) tags() []Tag {
+	if f.Tag != nil {
+		return []Tag{*f.Tag}
+	}
+	if f.And != nil {
+		return f.And.Tags
+	}
+	return nil
+}
+
+// matchFilter checks whether the object with the given key and tags has the prefix and all the filter tags.
+func matchFilter(prefix string, filterTags []Tag, key string, tags map[string]string) bool {
+	if !strings.HasPrefix(key, prefix) {
+		return false
+	}
+	for _, tag := range filterTags {
+		if value, has := tags[tag.Key]; !has || value != tag.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// expired checks whether the object last modified at the specified time has expired at now.
+func (e *LifecycleExpiration) expired(modifyTime, now time.Time) bool {
+	if e.Date != "" {
+		date, err := time.Parse(time.RFC3339, e.Date)
+		return err == nil && !now.Before(date)
+	}
+	return e.Days > 0 && !now.Before(lifecycleDueTime(modifyTime, e.Days))
+}
+
+// lifecycleDueTime computes the time a lifecycle action becomes due. As Amazon S3 does, the
+// due time is the specified number of days after the base time, rounded up to the next midnight UTC.
+func lifecycleDueTime(base time.Time, days int) time.Time {
+	due := base.UTC().Add(time.Duration(days) * 24 * time.Hour)
+	midnight := due.Truncate(24 * time.Hour)
+	if midnight.Before(due) {
+		midnight = midnight.Add(24 * time.Hour)
+	}
+	return midnight
+}
+
+func storeBucketLifecycle(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSLifecycle, bytes)
+}
+
+func deleteBucketLifecycle(vol *Volume) (err error) {
+	return vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSLifecycle)
+}
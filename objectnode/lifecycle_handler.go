@@ -0,0 +1,167 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	MaxLifecycleConfigSize = 1 << 20 // 1MB
+)
+
+// Get bucket lifecycle configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketLifecycleConfiguration.html
+func (o *ObjectNode) getBucketLifecycleHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getBucketLifecycleHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var lifecycle *LifecycleConfiguration
+	if lifecycle, err = vol.metaLoader.loadLifecycle(); err != nil {
+		log.LogErrorf("getBucketLifecycleHandler: load lifecycle fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if lifecycle == nil || len(lifecycle.Rules) == 0 {
+		errorCode = NoSuchLifecycleConfiguration
+		return
+	}
+
+	var data []byte
+	if data, err = MarshalXMLEntity(lifecycle); err != nil {
+		log.LogErrorf("getBucketLifecycleHandler: xml marshal fail: requestID(%v) volume(%v) lifecycle(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), lifecycle, err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	if _, err = w.Write(data); err != nil {
+		log.LogErrorf("getBucketLifecycleHandler: write response body fail: requestID(%v) volume(%v) body(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(data), err)
+	}
+	return
+}
+
+// Put bucket lifecycle configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLifecycleConfiguration.html
+func (o *ObjectNode) putBucketLifecycleHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("putBucketLifecycleHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	md5 := r.Header.Get(HeaderNameContentMD5)
+	if md5 == "" {
+		errorCode = MissingContentMD5
+		return
+	}
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, MaxLifecycleConfigSize+1)); err != nil {
+		log.LogErrorf("putBucketLifecycleHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxLifecycleConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	if md5 != GetMD5(body) {
+		errorCode = InvalidDigest
+		return
+	}
+
+	var lifecycle *LifecycleConfiguration
+	if lifecycle, errorCode = parseLifecycleConfig(body); errorCode != nil {
+		log.LogErrorf("putBucketLifecycleHandler: parse lifecycle config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), errorCode)
+		return
+	}
+	if err = storeBucketLifecycle(body, vol); err != nil {
+		log.LogErrorf("putBucketLifecycleHandler: store lifecycle config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	vol.metaLoader.storeLifecycle(lifecycle)
+
+	return
+}
+
+// Delete bucket lifecycle configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketLifecycle.html
+func (o *ObjectNode) deleteBucketLifecycleHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("deleteBucketLifecycleHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+	if err = deleteBucketLifecycle(vol); err != nil {
+		log.LogErrorf("deleteBucketLifecycleHandler: delete lifecycle config fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	vol.metaLoader.storeLifecycle(nil)
+	w.WriteHeader(http.StatusNoContent)
+
+	return
+}
@@ -0,0 +1,239 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/sdk/master"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	lifecycleScanBatch = 1000
+)
+
+// LifecycleScanner periodically walks the volumes which have bucket lifecycle configuration,
+// expires the objects and aborts the incomplete multipart uploads selected by enabled rules.
+type LifecycleScanner struct {
+	vm       *VolumeManager
+	mc       *master.MasterClient
+	interval time.Duration
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+func NewLifecycleScanner(vm *VolumeManager, mc *master.MasterClient, interval time.Duration) *LifecycleScanner {
+	return &LifecycleScanner{
+		vm:       vm,
+		mc:       mc,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func (s *LifecycleScanner) Start() {
+	go s.run()
+}
+
+func (s *LifecycleScanner) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+}
+
+func (s *LifecycleScanner) stopped() bool {
+	select {
+	case <-s.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *LifecycleScanner) run() {
+	t := time.NewTimer(s.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-s.stopCh:
+			return
+		}
+		s.scan()
+		t.Reset(s.interval)
+	}
+}
+
+func (s *LifecycleScanner) scan() {
+	var (
+		err  error
+		vols []*proto.VolInfo
+	)
+	if vols, err = s.mc.AdminAPI().ListVols(""); err != nil {
+		log.LogErrorf("LifecycleScanner: list volumes fail: err(%v)", err)
+		return
+	}
+	var now = time.Now()
+	for _, vi := range vols {
+		if s.stopped() {
+			return
+		}
+		if vi.Status == 1 {
+			// volume has been marked for deletion
+			continue
+		}
+		if err = s.scanVolume(vi.Name, now); err != nil {
+			log.LogErrorf("LifecycleScanner: scan volume fail: volume(%v) err(%v)", vi.Name, err)
+		}
+	}
+	log.LogInfof("LifecycleScanner: scan finished: volumes(%v) cost(%v)", len(vols), time.Since(now))
+}
+
+func (s *LifecycleScanner) scanVolume(volName string, now time.Time) (err error) {
+	var vol *Volume
+	if vol, err = s.vm.Volume(volName); err != nil {
+		return
+	}
+	var lifecycle *LifecycleConfiguration
+	if lifecycle, err = vol.loadBucketLifecycle(); err != nil || lifecycle == nil {
+		return
+	}
+	for _, rule := range lifecycle.Rules {
+		if !rule.enabled() {
+			continue
+		}
+		if rule.Expiration != nil {
+			if err = s.expireObjects(vol, rule, now); err != nil {
+				log.LogErrorf("LifecycleScanner: expire objects fail: volume(%v) rule(%v) err(%v)",
+					vol.Name(), rule.ID, err)
+			}
+		}
+		if rule.AbortIncompleteMultipartUpload != nil {
+			if err = s.abortMultipartUploads(vol, rule, now); err != nil {
+				log.LogErrorf("LifecycleScanner: abort multipart uploads fail: volume(%v) rule(%v) err(%v)",
+					vol.Name(), rule.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *LifecycleScanner) expireObjects(vol *Volume, rule *LifecycleRule, now time.Time) (err error) {
+	var opt = &ListFilesV1Option{
+		Prefix:     rule.filterPrefix(),
+		MaxKeys:    lifecycleScanBatch,
+		OnlyObject: true,
+	}
+	var needTags = len(rule.filterTags()) > 0
+	for !s.stopped() {
+		var result *ListFilesV1Result
+		if result, err = vol.ListFilesV1(opt); err != nil {
+			return
+		}
+		var expired = make([]*FSFileInfo, 0)
+		for _, info := range result.Files {
+			if !info.Mode.IsDir() && rule.Expiration.expired(info.ModifyTime, now) {
+				expired = append(expired, info)
+			}
+		}
+		var tags map[uint64]map[string]string
+		if needTags && len(expired) > 0 {
+			if tags, err = loadObjectsTagging(vol, expired); err != nil {
+				return
+			}
+		}
+		for _, info := range expired {
+			if !rule.matchObject(info.Path, tags[info.Inode]) {
+				continue
+			}
+			log.LogInfof("Audit: LifecycleScanner: expire object: volume(%v) rule(%v) path(%v) modifyTime(%v)",
+				vol.Name(), rule.ID, info.Path, info.ModifyTime)
+			// Expiring the current version of an object in a versioned bucket inserts a delete marker.
+			if _, err = vol.DeleteObject(info.Path, "", false); err != nil {
+				log.LogErrorf("LifecycleScanner: delete object fail: volume(%v) path(%v) err(%v)",
+					vol.Name(), info.Path, err)
+			}
+		}
+		if !result.Truncated {
+			break
+		}
+		opt.Marker = result.NextMarker
+	}
+	return nil
+}
+
+func (s *LifecycleScanner) abortMultipartUploads(vol *Volume, rule *LifecycleRule, now time.Time) (err error) {
+	var (
+		prefix    = rule.filterPrefix()
+		days      = rule.AbortIncompleteMultipartUpload.DaysAfterInitiation
+		keyMarker string
+		idMarker  string
+	)
+	for !s.stopped() {
+		var sessions []*proto.MultipartInfo
+		if sessions, err = vol.mw.ListMultipart_ll(prefix, "", keyMarker, idMarker, lifecycleScanBatch); err != nil {
+			return
+		}
+		var scanned int
+		for _, session := range sessions {
+			if session.Path < keyMarker || session.Path == keyMarker && session.ID <= idMarker {
+				continue
+			}
+			scanned++
+			keyMarker, idMarker = session.Path, session.ID
+			if !rule.matchObject(session.Path, nil) || now.Before(lifecycleDueTime(session.InitTime, days)) {
+				continue
+			}
+			log.LogInfof("Audit: LifecycleScanner: abort multipart upload: volume(%v) rule(%v) path(%v) multipartID(%v) initTime(%v)",
+				vol.Name(), rule.ID, session.Path, session.ID, session.InitTime)
+			if err = vol.AbortMultipart(session.Path, session.ID); err != nil {
+				log.LogErrorf("LifecycleScanner: abort multipart upload fail: volume(%v) path(%v) multipartID(%v) err(%v)",
+					vol.Name(), session.Path, session.ID, err)
+			}
+		}
+		if scanned == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// loadObjectsTagging returns the tag set of each of the specified objects, keyed by inode.
+func loadObjectsTagging(vol *Volume, infos []*FSFileInfo) (tags map[uint64]map[string]string, err error) {
+	var inodes = make([]uint64, 0, len(infos))
+	for _, info := range infos {
+		inodes = append(inodes, info.Inode)
+	}
+	var xattrs []*proto.XAttrInfo
+	if xattrs, err = vol.mw.BatchGetXAttr(inodes, []string{XAttrKeyOSSTagging}); err != nil {
+		return
+	}
+	tags = make(map[uint64]map[string]string, len(xattrs))
+	for _, xattr := range xattrs {
+		var tagging *Tagging
+		if tagging, err = ParseTagging(string(xattr.Get(XAttrKeyOSSTagging))); err != nil {
+			return
+		}
+		var kv = make(map[string]string, len(tagging.TagSet))
+		for _, tag := range tagging.TagSet {
+			kv[tag.Key] = tag.Value
+		}
+		tags[xattr.Inode] = kv
+	}
+	return
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLifecycleConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		valid bool
+	}{
+		{
+			name: "expiration days with prefix filter",
+			input: `<LifecycleConfiguration><Rule><ID>r1</ID><Status>Enabled</Status>
+<Filter><Prefix>logs/</Prefix></Filter><Expiration><Days>30</Days></Expiration></Rule></LifecycleConfiguration>`,
+			valid: true,
+		},
+		{
+			name: "expiration date with and filter",
+			input: `<LifecycleConfiguration><Rule><Status>Enabled</Status><Filter><And><Prefix>a/</Prefix>
+<Tag><Key>k</Key><Value>v</Value></Tag></And></Filter><Expiration><Date>2023-01-01T00:00:00Z</Date></Expiration>
+</Rule></LifecycleConfiguration>`,
+			valid: true,
+		},
+		{
+			name: "abort incomplete multipart upload",
+			input: `<LifecycleConfiguration><Rule><Status>Disabled</Status><Prefix>tmp/</Prefix>
+<AbortIncompleteMultipartUpload><DaysAfterInitiation>7</DaysAfterInitiation></AbortIncompleteMultipartUpload>
+</Rule></LifecycleConfiguration>`,
+			valid: true,
+		},
+		{
+			name:  "no rules",
+			input: `<LifecycleConfiguration></LifecycleConfiguration>`,
+		},
+		{
+			name: "invalid status",
+			input: `<LifecycleConfiguration><Rule><Status>On</Status>
+<Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`,
+		},
+		{
+			name:  "no action",
+			input: `<LifecycleConfiguration><Rule><Status>Enabled</Status></Rule></LifecycleConfiguration>`,
+		},
+		{
+			name: "date not at midnight",
+			input: `<LifecycleConfiguration><Rule><Status>Enabled</Status>
+<Expiration><Date>2023-01-01T08:00:00Z</Date></Expiration></Rule></LifecycleConfiguration>`,
+		},
+		{
+			name: "both prefix and tag in filter",
+			input: `<LifecycleConfiguration><Rule><Status>Enabled</Status><Filter><Prefix>a/</Prefix>
+<Tag><Key>k</Key><Value>v</Value></Tag></Filter><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`,
+		},
+		{
+			name: "abort multipart upload with tags",
+			input: `<LifecycleConfiguration><Rule><Status>Enabled</Status><Filter><Tag><Key>k</Key><Value>v</Value></Tag>
+</Filter><AbortIncompleteMultipartUpload><DaysAfterInitiation>1</DaysAfterInitiation></AbortIncompleteMultipartUpload>
+</Rule></LifecycleConfiguration>`,
+		},
+		{
+			name: "duplicated rule id",
+			input: `<LifecycleConfiguration><Rule><ID>r</ID><Status>Enabled</Status><Expiration><Days>1</Days></Expiration>
+</Rule><Rule><ID>r</ID><Status>Enabled</Status><Expiration><Days>2</Days></Expiration></Rule></LifecycleConfiguration>`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, errCode := parseLifecycleConfig([]byte(tc.input))
+			if tc.valid {
+				require.Nil(t, errCode)
+			} else {
+				require.NotNil(t, errCode)
+			}
+		})
+	}
+}
+
+func TestLifecycleRuleMatchObject(t *testing.T) {
+	rule := &LifecycleRule{
+		Status: LifecycleStatusEnabled,
+		Filter: &LifecycleFilter{
+			And: &LifecycleAndOperator{
+				Prefix: "logs/",
+				Tags:   []Tag{{Key: "type", Value: "tmp"}},
+			},
+		},
+	}
+	require.True(t, rule.matchObject("logs/a", map[string]string{"type": "tmp", "x": "y"}))
+	require.False(t, rule.matchObject("logs/a", map[string]string{"type": "keep"}))
+	require.False(t, rule.matchObject("logs/a", nil))
+	require.False(t, rule.matchObject("data/a", map[string]string{"type": "tmp"}))
+
+	rule = &LifecycleRule{Status: LifecycleStatusEnabled, Prefix: "tmp/"}
+	require.True(t, rule.matchObject("tmp/a", nil))
+	require.False(t, rule.matchObject("a/tmp/", nil))
+}
+
+func TestLifecycleExpiration(t *testing.T) {
+	modifyTime := time.Date(2023, 3, 1, 10, 30, 0, 0, time.UTC)
+	require.Equal(t, time.Date(2023, 3, 3, 0, 0, 0, 0, time.UTC), lifecycleDueTime(modifyTime, 1))
+	require.Equal(t, time.Date(2023, 3, 2, 0, 0, 0, 0, time.UTC),
+		lifecycleDueTime(time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), 1))
+
+	e := &LifecycleExpiration{Days: 1}
+	require.False(t, e.expired(modifyTime, time.Date(2023, 3, 2, 23, 59, 59, 0, time.UTC)))
+	require.True(t, e.expired(modifyTime, time.Date(2023, 3, 3, 0, 0, 0, 0, time.UTC)))
+
+	e = &LifecycleExpiration{Date: "2023-04-01T00:00:00Z"}
+	require.False(t, e.expired(modifyTime, time.Date(2023, 3, 31, 0, 0, 0, 0, time.UTC)))
+	require.True(t, e.expired(modifyTime, time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)))
+}
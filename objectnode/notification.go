@@ -0,0 +1,409 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/EventNotifications.html
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/notification-content-structure.html
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	EventObjectCreatedAll                     = "s3:ObjectCreated:*"
+	EventObjectCreatedPut                     = "s3:ObjectCreated:Put"
+	EventObjectCreatedPost                    = "s3:ObjectCreated:Post"
+	EventObjectCreatedCopy                    = "s3:ObjectCreated:Copy"
+	EventObjectCreatedCompleteMultipartUpload = "s3:ObjectCreated:CompleteMultipartUpload"
+	EventObjectRemovedAll                     = "s3:ObjectRemoved:*"
+	EventObjectRemovedDelete                  = "s3:ObjectRemoved:Delete"
+	EventObjectRemovedDeleteMarkerCreated     = "s3:ObjectRemoved:DeleteMarkerCreated"
+
+	NotificationFilterRulePrefix = "prefix"
+	NotificationFilterRuleSuffix = "suffix"
+
+	MaxNotificationConfigurations = 100
+	MaxNotificationIDLen          = 255
+
+	eventVersion       = "2.1"
+	eventSource        = "aws:s3"
+	eventSchemaVersion = "1.0"
+	eventTimeFormat    = "2006-01-02T15:04:05.000Z"
+)
+
+var supportedNotificationEvents = map[string]struct{}{
+	EventObjectCreatedAll:                     {},
+	EventObjectCreatedPut:                     {},
+	EventObjectCreatedPost:                    {},
+	EventObjectCreatedCopy:                    {},
+	EventObjectCreatedCompleteMultipartUpload: {},
+	EventObjectRemovedAll:                     {},
+	EventObjectRemovedDelete:                  {},
+	EventObjectRemovedDeleteMarkerCreated:     {},
+}
+
+// NotificationConfiguration is the bucket notification configuration.
+// Since there is no SQS or SNS in ObjectNode, the Queue and the Topic name the notification targets
+// configured by the notificationTargets configuration item, either by the name itself or by an ARN
+// whose last segment is the name.
+type NotificationConfiguration struct {
+	XMLName                     xml.Name                      `xml:"NotificationConfiguration" json:"-"`
+	QueueConfigurations         []*NotificationRule           `xml:"QueueConfiguration,omitempty" json:"queue_configurations,omitempty"`
+	TopicConfigurations         []*NotificationRule           `xml:"TopicConfiguration,omitempty" json:"topic_configurations,omitempty"`
+	CloudFunctionConfigurations []*CloudFunctionConfiguration `xml:"CloudFunctionConfiguration,omitempty" json:"-"`
+}
+
+// CloudFunctionConfiguration is only parsed to reject the unsupported function configurations.
+type CloudFunctionConfiguration struct {
+	CloudFunction string `xml:"CloudFunction"`
+}
+
+// NotificationRule is a queue or topic configuration, which publishes the selected events to the target.
+// Exactly one of Queue and Topic is specified.
+type NotificationRule struct {
+	ID     string              `xml:"Id,omitempty" json:"id,omitempty"`
+	Queue  string              `xml:"Queue,omitempty" json:"queue,omitempty"`
+	Topic  string              `xml:"Topic,omitempty" json:"topic,omitempty"`
+	Events []string            `xml:"Event" json:"events"`
+	Filter *NotificationFilter `xml:"Filter,omitempty" json:"filter,omitempty"`
+}
+
+type NotificationFilter struct {
+	S3Key *NotificationS3KeyFilter `xml:"S3Key,omitempty" json:"s3_key,omitempty"`
+}
+
+type NotificationS3KeyFilter struct {
+	FilterRules []*NotificationFilterRule `xml:"FilterRule" json:"filter_rules"`
+}
+
+type NotificationFilterRule struct {
+	Name  string `xml:"Name" json:"name"`
+	Value string `xml:"Value" json:"value"`
+}
+
+func NewNotificationConfiguration() *NotificationConfiguration {
+	return &NotificationConfiguration{
+		XMLName: xml.Name{Local: "NotificationConfiguration"},
+	}
+}
+
+func parseNotificationConfig(bytes []byte) (config *NotificationConfiguration, errCode *ErrorCode) {
+	config = NewNotificationConfiguration()
+	if err := xml.Unmarshal(bytes, config); err != nil {
+		return nil, MalformedXML
+	}
+	if errCode = config.validate(); errCode != nil {
+		return nil, errCode
+	}
+	return config, nil
+}
+
+// rules returns the queue and topic configurations.
+func (config *NotificationConfiguration) rules() []*NotificationRule {
+	var rules = make([]*NotificationRule, 0, len(config.QueueConfigurations)+len(config.TopicConfigurations))
+	rules = append(rules, config.QueueConfigurations...)
+	return append(rules, config.TopicConfigurations...)
+}
+
+func (config *NotificationConfiguration) isEmpty() bool {
+	return len(config.QueueConfigurations) == 0 && len(config.TopicConfigurations) == 0
+}
+
+func (config *NotificationConfiguration) validate() *ErrorCode {
+	if len(config.CloudFunctionConfigurations) > 0 {
+		return InvalidNotificationDestination
+	}
+	var rules = config.rules()
+	if len(rules) > MaxNotificationConfigurations {
+		return NewError("InvalidArgument", "The number of notification configurations should not exceed allowed limit of 100.", 400)
+	}
+	var ids = make(map[string]struct{})
+	for i, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+		for _, other := range rules[:i] {
+			if rule.overlap(other) {
+				return NotificationConfigurationOverlap
+			}
+		}
+		if rule.ID == "" {
+			continue
+		}
+		if _, has := ids[rule.ID]; has {
+			return NewError("InvalidArgument", "Configuration ID must be unique.", 400)
+		}
+		ids[rule.ID] = struct{}{}
+	}
+	return nil
+}
+
+func (rule *NotificationRule) validate() *ErrorCode {
+	if len(rule.ID) > MaxNotificationIDLen {
+		return NewError("InvalidArgument", "ID length should not exceed allowed limit of 255.", 400)
+	}
+	if (rule.Queue == "") == (rule.Topic == "") || rule.target() == "" {
+		return InvalidNotificationDestination
+	}
+	if len(rule.Events) == 0 {
+		return MalformedXML
+	}
+	for _, event := range rule.Events {
+		if _, has := supportedNotificationEvents[event]; !has {
+			return InvalidNotificationEvent
+		}
+	}
+	if rule.Filter == nil || rule.Filter.S3Key == nil {
+		return nil
+	}
+	var names = make(map[string]struct{})
+	for _, fr := range rule.Filter.S3Key.FilterRules {
+		var name = strings.ToLower(fr.Name)
+		if name != NotificationFilterRulePrefix && name != NotificationFilterRuleSuffix {
+			return InvalidNotificationFilter
+		}
+		if _, has := names[name]; has {
+			return InvalidNotificationFilter
+		}
+		names[name] = struct{}{}
+	}
+	return nil
+}
+
+// target returns the name of the notification target specified by the queue or the topic.
+func (rule *NotificationRule) target() string {
+	var arn = rule.Queue
+	if arn == "" {
+		arn = rule.Topic
+	}
+	return arn[strings.LastIndex(arn, ":")+1:]
+}
+
+func (rule *NotificationRule) filterValue(name string) string {
+	if rule.Filter == nil || rule.Filter.S3Key == nil {
+		return ""
+	}
+	for _, fr := range rule.Filter.S3Key.FilterRules {
+		if strings.ToLower(fr.Name) == name {
+			return fr.Value
+		}
+	}
+	return ""
+}
+
+func (rule *NotificationRule) filterPrefix() string {
+	return rule.filterValue(NotificationFilterRulePrefix)
+}
+
+func (rule *NotificationRule) filterSuffix() string {
+	return rule.filterValue(NotificationFilterRuleSuffix)
+}
+
+// overlap checks whether both rules may select the same event of the same object.
+func (rule *NotificationRule) overlap(other *NotificationRule) bool {
+	var shared bool
+	for _, a := range rule.Events {
+		for _, b := range other.Events {
+			if matchEventName(a, b) || matchEventName(b, a) {
+				shared = true
+			}
+		}
+	}
+	if !shared {
+		return false
+	}
+	var p1, p2, s1, s2 = rule.filterPrefix(), other.filterPrefix(), rule.filterSuffix(), other.filterSuffix()
+	return (strings.HasPrefix(p1, p2) || strings.HasPrefix(p2, p1)) &&
+		(strings.HasSuffix(s1, s2) || strings.HasSuffix(s2, s1))
+}
+
+// matchEventName checks whether the event pattern, which may end with the wildcard, selects the event.
+func matchEventName(pattern, event string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(event, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == event
+}
+
+func (rule *NotificationRule) match(event, key string) bool {
+	if !strings.HasPrefix(key, rule.filterPrefix()) || !strings.HasSuffix(key, rule.filterSuffix()) {
+		return false
+	}
+	for _, pattern := range rule.Events {
+		if matchEventName(pattern, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRules returns the rules which select the event of the object with the given key.
+func (config *NotificationConfiguration) matchRules(event, key string) (matched []*NotificationRule) {
+	for _, rule := range config.rules() {
+		if rule.match(event, key) {
+			matched = append(matched, rule)
+		}
+	}
+	return
+}
+
+func storeBucketNotification(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSNotification, bytes)
+}
+
+func deleteBucketNotification(vol *Volume) (err error) {
+	return vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSNotification)
+}
+
+// EventRecords is the message published to the notification targets, which is compatible with
+// the event message structure of S3.
+type EventRecords struct {
+	Records []*EventRecord `json:"Records"`
+}
+
+type EventRecord struct {
+	EventVersion      string            `json:"eventVersion"`
+	EventSource       string            `json:"eventSource"`
+	AwsRegion         string            `json:"awsRegion"`
+	EventTime         string            `json:"eventTime"`
+	EventName         string            `json:"eventName"`
+	UserIdentity      EventIdentity     `json:"userIdentity"`
+	RequestParameters map[string]string `json:"requestParameters"`
+	ResponseElements  map[string]string `json:"responseElements"`
+	S3                EventS3Entity     `json:"s3"`
+}
+
+type EventIdentity struct {
+	PrincipalID string `json:"principalId"`
+}
+
+type EventS3Entity struct {
+	SchemaVersion   string            `json:"s3SchemaVersion"`
+	ConfigurationID string            `json:"configurationId"`
+	Bucket          EventBucketEntity `json:"bucket"`
+	Object          EventObjectEntity `json:"object"`
+}
+
+type EventBucketEntity struct {
+	Name          string        `json:"name"`
+	OwnerIdentity EventIdentity `json:"ownerIdentity"`
+	Arn           string        `json:"arn"`
+}
+
+type EventObjectEntity struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	VersionID string `json:"versionId,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+// newEventRecord returns the event record of the request on the object, which is completed by the
+// configuration ID of the selecting rule before it is published.
+func (o *ObjectNode) newEventRecord(r *http.Request, vol *Volume, event string, object EventObjectEntity) *EventRecord {
+	var principal string
+	if accessKey := ParseRequestParam(r).AccessKey(); accessKey != "" {
+		principal = accessKey
+		if userInfo, err := o.getUserInfoByAccessKey(accessKey); err == nil {
+			principal = userInfo.UserID
+		}
+	}
+	var now = time.Now()
+	object.Key = url.QueryEscape(object.Key)
+	object.Sequencer = fmt.Sprintf("%016X", now.UnixNano())
+	return &EventRecord{
+		EventVersion: eventVersion,
+		EventSource:  eventSource,
+		AwsRegion:    o.region,
+		EventTime:    now.UTC().Format(eventTimeFormat),
+		EventName:    strings.TrimPrefix(event, "s3:"),
+		UserIdentity: EventIdentity{PrincipalID: principal},
+		RequestParameters: map[string]string{
+			"sourceIPAddress": getRequestIP(r),
+		},
+		ResponseElements: map[string]string{
+			"x-amz-request-id": GetRequestID(r),
+		},
+		S3: EventS3Entity{
+			SchemaVersion: eventSchemaVersion,
+			Bucket: EventBucketEntity{
+				Name:          vol.Name(),
+				OwnerIdentity: EventIdentity{PrincipalID: vol.owner},
+				Arn:           ReplicationBucketARNPrefix + vol.Name(),
+			},
+			Object: object,
+		},
+	}
+}
+
+// notifyEvent publishes the event of the object to the targets of the rules which select it.
+// The event is published asynchronously, so the failures are only logged and never fail the request.
+func (o *ObjectNode) notifyEvent(r *http.Request, vol *Volume, event string, object EventObjectEntity) {
+	if o.notifier == nil {
+		return
+	}
+	config, err := vol.metaLoader.loadNotification()
+	if err != nil || config == nil {
+		return
+	}
+	var rules = config.matchRules(event, object.Key)
+	if len(rules) == 0 {
+		return
+	}
+	var record = o.newEventRecord(r, vol, event, object)
+	for _, rule := range rules {
+		record.S3.ConfigurationID = rule.ID
+		var data []byte
+		if data, err = json.Marshal(&EventRecords{Records: []*EventRecord{record}}); err != nil {
+			log.LogErrorf("notifyEvent: marshal event fail: requestID(%v) volume(%v) key(%v) event(%v) err(%v)",
+				GetRequestID(r), vol.Name(), object.Key, event, err)
+			return
+		}
+		if err = o.notifier.publish(rule.target(), data); err != nil {
+			log.LogErrorf("notifyEvent: publish event fail: requestID(%v) volume(%v) key(%v) event(%v) target(%v) err(%v)",
+				GetRequestID(r), vol.Name(), object.Key, event, rule.target(), err)
+		}
+	}
+}
+
+// notifyObjectCreated publishes the event of the newly written object version.
+func (o *ObjectNode) notifyObjectCreated(r *http.Request, vol *Volume, event, key string, info *FSFileInfo) {
+	o.notifyEvent(r, vol, event, EventObjectEntity{
+		Key:       key,
+		Size:      info.Size,
+		ETag:      info.ETag,
+		VersionID: info.VersionId,
+	})
+}
+
+// notifyObjectRemoved publishes the event of the deleted object version, or of the inserted delete marker.
+func (o *ObjectNode) notifyObjectRemoved(r *http.Request, vol *Volume, key, versionId string, deleteMarker bool) {
+	var event = EventObjectRemovedDelete
+	if deleteMarker {
+		event = EventObjectRemovedDeleteMarkerCreated
+	}
+	o.notifyEvent(r, vol, event, EventObjectEntity{
+		Key:       key,
+		VersionID: versionId,
+	})
+}
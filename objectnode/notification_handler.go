@@ -0,0 +1,145 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	MaxNotificationConfigSize = 1 << 20 // 1MB
+)
+
+// Get bucket notification
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketNotificationConfiguration.html
+func (o *ObjectNode) getBucketNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getBucketNotificationHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var notification *NotificationConfiguration
+	if notification, err = vol.metaLoader.loadNotification(); err != nil {
+		log.LogErrorf("getBucketNotificationHandler: load notification fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	// An empty configuration is returned if the notification is not configured.
+	if notification == nil {
+		notification = NewNotificationConfiguration()
+	}
+
+	var data []byte
+	if data, err = MarshalXMLEntity(notification); err != nil {
+		log.LogErrorf("getBucketNotificationHandler: xml marshal fail: requestID(%v) volume(%v) notification(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), notification, err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	if _, err = w.Write(data); err != nil {
+		log.LogErrorf("getBucketNotificationHandler: write response body fail: requestID(%v) volume(%v) body(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(data), err)
+	}
+	return
+}
+
+// Put bucket notification
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketNotificationConfiguration.html
+func (o *ObjectNode) putBucketNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("putBucketNotificationHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, MaxNotificationConfigSize+1)); err != nil {
+		log.LogErrorf("putBucketNotificationHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxNotificationConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	if md5 := r.Header.Get(HeaderNameContentMD5); md5 != "" && md5 != GetMD5(body) {
+		errorCode = InvalidDigest
+		return
+	}
+
+	var notification *NotificationConfiguration
+	if notification, errorCode = parseNotificationConfig(body); errorCode != nil {
+		log.LogErrorf("putBucketNotificationHandler: parse notification config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), errorCode)
+		return
+	}
+	// An empty configuration disables the notifications of the bucket.
+	if notification.isEmpty() {
+		if err = deleteBucketNotification(vol); err != nil {
+			log.LogErrorf("putBucketNotificationHandler: delete notification config fail: requestID(%v) volume(%v) err(%v)",
+				GetRequestID(r), vol.Name(), err)
+			return
+		}
+		vol.metaLoader.storeNotification(nil)
+		return
+	}
+	for _, rule := range notification.rules() {
+		if o.notifier == nil || !o.notifier.hasTarget(rule.target()) {
+			errorCode = InvalidNotificationDestination
+			return
+		}
+	}
+	if err = storeBucketNotification(body, vol); err != nil {
+		log.LogErrorf("putBucketNotificationHandler: store notification config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	vol.metaLoader.storeNotification(notification)
+
+	return
+}
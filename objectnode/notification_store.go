@@ -0,0 +1,114 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	eventFileSuffix     = ".event"
+	eventTempFileSuffix = ".tmp"
+)
+
+// EventStore is a durable queue of the event messages of a notification target. Each message is
+// persisted as a file named by its hexadecimal sequence number in the store directory, so the files
+// sorted by name are in the order in which the events were published.
+type EventStore struct {
+	dir    string
+	mu     sync.Mutex
+	seq    uint64
+	notify chan struct{}
+}
+
+// OpenEventStore opens the store in the directory and removes the messages which failed to be persisted.
+func OpenEventStore(dir string) (s *EventStore, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	s = &EventStore{
+		dir:    dir,
+		notify: make(chan struct{}, 1),
+		// The sequence keeps increasing across restarts even if all the messages have been consumed.
+		seq: uint64(time.Now().UnixNano()),
+	}
+	var infos []os.FileInfo
+	if infos, err = ioutil.ReadDir(dir); err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		var name = info.Name()
+		if strings.HasSuffix(name, eventTempFileSuffix) {
+			_ = os.Remove(path.Join(dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, eventFileSuffix) {
+			continue
+		}
+		if seq, parseErr := strconv.ParseUint(strings.TrimSuffix(name, eventFileSuffix), 16, 64); parseErr == nil && seq > s.seq {
+			s.seq = seq
+		}
+	}
+	return
+}
+
+// Put persists the message at the tail of the store.
+func (s *EventStore) Put(data []byte) (err error) {
+	s.mu.Lock()
+	s.seq++
+	var seq = s.seq
+	s.mu.Unlock()
+	var name = fmt.Sprintf("%016x", seq)
+	if err = writeFileAtomic(path.Join(s.dir, name+eventTempFileSuffix), path.Join(s.dir, name+eventFileSuffix), data); err != nil {
+		return
+	}
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return
+}
+
+// List returns the names of the persisted messages in order.
+func (s *EventStore) List() (names []string, err error) {
+	var infos []os.FileInfo
+	if infos, err = ioutil.ReadDir(s.dir); err != nil {
+		return
+	}
+	for _, info := range infos {
+		if strings.HasSuffix(info.Name(), eventFileSuffix) {
+			names = append(names, info.Name())
+		}
+	}
+	return
+}
+
+func (s *EventStore) Get(name string) ([]byte, error) {
+	return ioutil.ReadFile(path.Join(s.dir, name))
+}
+
+func (s *EventStore) Del(name string) (err error) {
+	if err = os.Remove(path.Join(s.dir, name)); os.IsNotExist(err) {
+		err = nil
+	}
+	return
+}
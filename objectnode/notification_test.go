@@ -0,0 +1,209 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNotificationConfig(t *testing.T) {
+	config, errCode := parseNotificationConfig([]byte(`
+<NotificationConfiguration>
+	<QueueConfiguration>
+		<Id>images</Id>
+		<Queue>arn:aws:sqs:us-east-1:123456789012:local</Queue>
+		<Event>s3:ObjectCreated:*</Event>
+		<Filter>
+			<S3Key>
+				<FilterRule><Name>prefix</Name><Value>images/</Value></FilterRule>
+				<FilterRule><Name>Suffix</Name><Value>.jpg</Value></FilterRule>
+			</S3Key>
+		</Filter>
+	</QueueConfiguration>
+	<TopicConfiguration>
+		<Topic>pipeline</Topic>
+		<Event>s3:ObjectRemoved:Delete</Event>
+		<Event>s3:ObjectCreated:Put</Event>
+		<Filter><S3Key><FilterRule><Name>prefix</Name><Value>logs/</Value></FilterRule></S3Key></Filter>
+	</TopicConfiguration>
+</NotificationConfiguration>`))
+	require.Nil(t, errCode)
+	require.Len(t, config.rules(), 2)
+	require.Equal(t, "local", config.QueueConfigurations[0].target())
+	require.Equal(t, "pipeline", config.TopicConfigurations[0].target())
+
+	for name, raw := range map[string]string{
+		"unknown event":        `<QueueConfiguration><Queue>q</Queue><Event>s3:ObjectRestore:*</Event></QueueConfiguration>`,
+		"unknown filter":       `<QueueConfiguration><Queue>q</Queue><Event>s3:ObjectCreated:*</Event><Filter><S3Key><FilterRule><Name>infix</Name><Value>a</Value></FilterRule></S3Key></Filter></QueueConfiguration>`,
+		"repeated filter":      `<QueueConfiguration><Queue>q</Queue><Event>s3:ObjectCreated:*</Event><Filter><S3Key><FilterRule><Name>prefix</Name><Value>a</Value></FilterRule><FilterRule><Name>prefix</Name><Value>b</Value></FilterRule></S3Key></Filter></QueueConfiguration>`,
+		"no destination":       `<QueueConfiguration><Event>s3:ObjectCreated:*</Event></QueueConfiguration>`,
+		"both queue and topic": `<QueueConfiguration><Queue>q</Queue><Topic>t</Topic><Event>s3:ObjectCreated:*</Event></QueueConfiguration>`,
+		"cloud function":       `<CloudFunctionConfiguration><CloudFunction>f</CloudFunction><Event>s3:ObjectCreated:*</Event></CloudFunctionConfiguration>`,
+		"overlap": `<QueueConfiguration><Queue>q</Queue><Event>s3:ObjectCreated:*</Event><Filter><S3Key><FilterRule><Name>prefix</Name><Value>a/</Value></FilterRule></S3Key></Filter></QueueConfiguration>` +
+			`<TopicConfiguration><Topic>t</Topic><Event>s3:ObjectCreated:Put</Event><Filter><S3Key><FilterRule><Name>prefix</Name><Value>a/b/</Value></FilterRule></S3Key></Filter></TopicConfiguration>`,
+		"duplicate id": `<QueueConfiguration><Id>a</Id><Queue>q</Queue><Event>s3:ObjectCreated:*</Event></QueueConfiguration>` +
+			`<QueueConfiguration><Id>a</Id><Queue>q</Queue><Event>s3:ObjectRemoved:*</Event></QueueConfiguration>`,
+	} {
+		_, errCode = parseNotificationConfig([]byte("<NotificationConfiguration>" + raw + "</NotificationConfiguration>"))
+		require.NotNil(t, errCode, name)
+	}
+
+	// the same event type with the disjoint filters does not overlap
+	_, errCode = parseNotificationConfig([]byte(`<NotificationConfiguration>` +
+		`<QueueConfiguration><Queue>q</Queue><Event>s3:ObjectCreated:*</Event><Filter><S3Key><FilterRule><Name>prefix</Name><Value>a/</Value></FilterRule></S3Key></Filter></QueueConfiguration>` +
+		`<QueueConfiguration><Queue>q</Queue><Event>s3:ObjectCreated:Put</Event><Filter><S3Key><FilterRule><Name>prefix</Name><Value>b/</Value></FilterRule></S3Key></Filter></QueueConfiguration>` +
+		`</NotificationConfiguration>`))
+	require.Nil(t, errCode)
+
+	config, errCode = parseNotificationConfig([]byte(`<NotificationConfiguration/>`))
+	require.Nil(t, errCode)
+	require.True(t, config.isEmpty())
+}
+
+func TestNotificationMatchRules(t *testing.T) {
+	config := &NotificationConfiguration{
+		QueueConfigurations: []*NotificationRule{{
+			ID:     "images",
+			Queue:  "local",
+			Events: []string{EventObjectCreatedAll},
+			Filter: &NotificationFilter{S3Key: &NotificationS3KeyFilter{FilterRules: []*NotificationFilterRule{
+				{Name: "prefix", Value: "images/"},
+				{Name: "suffix", Value: ".jpg"},
+			}}},
+		}},
+		TopicConfigurations: []*NotificationRule{{
+			ID:     "removed",
+			Topic:  "pipeline",
+			Events: []string{EventObjectRemovedDeleteMarkerCreated},
+		}},
+	}
+	require.Len(t, config.matchRules(EventObjectCreatedPut, "images/a.jpg"), 1)
+	require.Len(t, config.matchRules(EventObjectCreatedCompleteMultipartUpload, "images/a.jpg"), 1)
+	require.Len(t, config.matchRules(EventObjectCreatedPut, "images/a.png"), 0)
+	require.Len(t, config.matchRules(EventObjectCreatedPut, "docs/a.jpg"), 0)
+	require.Len(t, config.matchRules(EventObjectRemovedDelete, "images/a.jpg"), 0)
+	matched := config.matchRules(EventObjectRemovedDeleteMarkerCreated, "images/a.jpg")
+	require.Len(t, matched, 1)
+	require.Equal(t, "removed", matched[0].ID)
+}
+
+func TestEventStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "event_store")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := OpenEventStore(dir)
+	require.NoError(t, err)
+	for _, data := range []string{"a", "b", "c"} {
+		require.NoError(t, store.Put([]byte(data)))
+	}
+	names, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, names, 3)
+	data, err := store.Get(names[0])
+	require.NoError(t, err)
+	require.Equal(t, "a", string(data))
+	require.NoError(t, store.Del(names[0]))
+	require.NoError(t, store.Del(names[0]))
+
+	// the broken messages are removed, and the new messages follow the persisted ones after reopen
+	require.NoError(t, ioutil.WriteFile(path.Join(dir, "ffffffffffffffff"+eventTempFileSuffix), []byte("x"), 0644))
+	store, err = OpenEventStore(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.Put([]byte("d")))
+	reopened, err := store.List()
+	require.NoError(t, err)
+	require.Equal(t, names[1:], reopened[:2])
+	data, err = store.Get(reopened[2])
+	require.NoError(t, err)
+	require.Equal(t, "d", string(data))
+	_, err = os.Stat(path.Join(dir, "ffffffffffffffff"+eventTempFileSuffix))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestNotifierDeliver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notifier")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var (
+		requests  int32
+		delivered = make(chan string, 10)
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first delivery fails and is retried
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		require.Equal(t, HeaderValueContentTypeJSON, r.Header.Get(HeaderNameContentType))
+		delivered <- string(body)
+	}))
+	defer server.Close()
+
+	targets, err := parseNotificationTargets([]interface{}{
+		map[string]interface{}{"name": "pipeline", "type": "webhook", "endpoint": server.URL},
+		map[string]interface{}{"name": "local", "type": "queue", "dir": path.Join(dir, "local")},
+	})
+	require.NoError(t, err)
+	notifier, err := NewNotifier(targets, path.Join(dir, "queue"), 3)
+	require.NoError(t, err)
+	require.True(t, notifier.hasTarget("local"))
+	require.False(t, notifier.hasTarget("unknown"))
+	notifier.Start()
+	defer notifier.Stop()
+
+	require.NoError(t, notifier.publish("pipeline", []byte(`{"Records":[1]}`)))
+	require.NoError(t, notifier.publish("pipeline", []byte(`{"Records":[2]}`)))
+	require.NoError(t, notifier.publish("local", []byte(`{"Records":[3]}`)))
+	require.Error(t, notifier.publish("unknown", nil))
+	for _, expected := range []string{`{"Records":[1]}`, `{"Records":[2]}`} {
+		select {
+		case body := <-delivered:
+			require.Equal(t, expected, body)
+		case <-time.After(10 * time.Second):
+			t.Fatal("event is not delivered")
+		}
+	}
+	require.Eventually(t, func() bool {
+		names, _ := notifier.targets["pipeline"].store.List()
+		return len(names) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the events of the queue target are kept for the local consumers
+	names, err := notifier.targets["local"].store.List()
+	require.NoError(t, err)
+	require.Len(t, names, 1)
+
+	for _, raw := range [][]interface{}{
+		{map[string]interface{}{"name": "a", "type": "webhook"}},
+		{map[string]interface{}{"name": "a", "type": "queue"}},
+		{map[string]interface{}{"name": "a", "type": "kafka", "endpoint": "x"}},
+		{map[string]interface{}{"name": "a", "type": "queue", "dir": "x"}, map[string]interface{}{"name": "a", "type": "queue", "dir": "y"}},
+	} {
+		_, err = parseNotificationTargets(raw)
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	NotificationTargetWebhook = "webhook"
+	NotificationTargetQueue   = "queue"
+
+	defaultNotificationMaxRetries = 16
+
+	notificationMinRetryDelay = time.Second
+	notificationMaxRetryDelay = time.Minute
+	notificationPostTimeout   = 10 * time.Second
+)
+
+// NotificationTarget is the destination of the bucket event notifications.
+// The events of a webhook target are POSTed to the endpoint, and the events of a queue target are
+// persisted in the directory for the local consumers, which remove each event file once consumed.
+type NotificationTarget struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Endpoint string `json:"endpoint"`
+	Dir      string `json:"dir"`
+
+	store *EventStore
+}
+
+// parseNotificationTargets parses the notification targets from the raw configuration item.
+func parseNotificationTargets(raw []interface{}) (targets []*NotificationTarget, err error) {
+	var data []byte
+	if data, err = json.Marshal(raw); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &targets); err != nil {
+		return
+	}
+	var names = make(map[string]struct{}, len(targets))
+	for _, target := range targets {
+		if target.Name == "" {
+			return nil, fmt.Errorf("notification target requires name")
+		}
+		switch target.Type {
+		case NotificationTargetWebhook:
+			if target.Endpoint == "" {
+				return nil, fmt.Errorf("webhook notification target requires endpoint: %v", target.Name)
+			}
+		case NotificationTargetQueue:
+			if target.Dir == "" {
+				return nil, fmt.Errorf("queue notification target requires dir: %v", target.Name)
+			}
+		default:
+			return nil, fmt.Errorf("unknown notification target type: %v", target.Type)
+		}
+		if _, has := names[target.Name]; has {
+			return nil, fmt.Errorf("duplicate notification target: %v", target.Name)
+		}
+		names[target.Name] = struct{}{}
+	}
+	return
+}
+
+// Notifier publishes the bucket events to the notification targets. The events of the webhook targets
+// are queued in the queue directory and delivered in order, retrying the failed deliveries with backoff.
+// An event is dropped after the retries are exhausted, and the retries start over after restart.
+type Notifier struct {
+	targets    map[string]*NotificationTarget
+	client     *http.Client
+	maxRetries int
+	stopOnce   sync.Once
+	stopCh     chan struct{}
+	wg         sync.WaitGroup
+}
+
+func NewNotifier(targets []*NotificationTarget, queueDir string, maxRetries int) (*Notifier, error) {
+	var n = &Notifier{
+		targets:    make(map[string]*NotificationTarget, len(targets)),
+		client:     &http.Client{Timeout: notificationPostTimeout},
+		maxRetries: maxRetries,
+		stopCh:     make(chan struct{}),
+	}
+	for _, target := range targets {
+		var dir = target.Dir
+		if target.Type == NotificationTargetWebhook {
+			if queueDir == "" {
+				return nil, fmt.Errorf("webhook notification target requires queue dir: %v", target.Name)
+			}
+			dir = path.Join(queueDir, target.Name)
+		}
+		var err error
+		if target.store, err = OpenEventStore(dir); err != nil {
+			return nil, err
+		}
+		n.targets[target.Name] = target
+	}
+	return n, nil
+}
+
+func (n *Notifier) Start() {
+	for _, target := range n.targets {
+		if target.Type == NotificationTargetWebhook {
+			n.wg.Add(1)
+			go n.run(target)
+		}
+	}
+}
+
+func (n *Notifier) Stop() {
+	n.stopOnce.Do(func() {
+		close(n.stopCh)
+	})
+	n.wg.Wait()
+}
+
+func (n *Notifier) hasTarget(name string) bool {
+	_, has := n.targets[name]
+	return has
+}
+
+// publish persists the event message to the store of the target.
+func (n *Notifier) publish(name string, data []byte) error {
+	var target = n.targets[name]
+	if target == nil {
+		return fmt.Errorf("notification target %v not found", name)
+	}
+	return target.store.Put(data)
+}
+
+func (n *Notifier) run(target *NotificationTarget) {
+	defer n.wg.Done()
+	for {
+		names, err := target.store.List()
+		if err != nil {
+			log.LogErrorf("Notifier: list events fail: target(%v) err(%v)", target.Name, err)
+		}
+		for _, name := range names {
+			if !n.deliver(target, name) {
+				return
+			}
+		}
+		var timer = time.NewTimer(time.Minute)
+		select {
+		case <-target.store.notify:
+		case <-timer.C:
+		case <-n.stopCh:
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+	}
+}
+
+// deliver posts the event to the webhook until it succeeds or the retries are exhausted, and then
+// removes the event from the store. It returns false if the notifier is stopped.
+func (n *Notifier) deliver(target *NotificationTarget, name string) bool {
+	data, err := target.store.Get(name)
+	if err != nil {
+		log.LogErrorf("Notifier: read event fail: target(%v) event(%v) err(%v)", target.Name, name, err)
+		return true
+	}
+	for retries := 0; ; retries++ {
+		if err = n.post(target.Endpoint, data); err == nil {
+			log.LogDebugf("Notifier: deliver event finished: target(%v) event(%v)", target.Name, name)
+			break
+		}
+		if retries >= n.maxRetries {
+			log.LogErrorf("Notifier: deliver event fail and drop: target(%v) event(%v) retries(%v) err(%v)",
+				target.Name, name, retries, err)
+			break
+		}
+		var delay = notificationMinRetryDelay << uint(retries)
+		if delay > notificationMaxRetryDelay || delay <= 0 {
+			delay = notificationMaxRetryDelay
+		}
+		log.LogWarnf("Notifier: deliver event fail and retry: target(%v) event(%v) retries(%v) delay(%v) err(%v)",
+			target.Name, name, retries, delay, err)
+		var timer = time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-n.stopCh:
+			timer.Stop()
+			return false
+		}
+	}
+	if err = target.store.Del(name); err != nil {
+		log.LogErrorf("Notifier: remove event fail: target(%v) event(%v) err(%v)", target.Name, name, err)
+	}
+	return true
+}
+
+func (n *Notifier) post(endpoint string, data []byte) (err error) {
+	var resp *http.Response
+	if resp, err = n.client.Post(endpoint, HeaderValueContentTypeJSON, bytes.NewReader(data)); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status code %v", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/object-lock.html
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+const (
+	ObjectLockEnabled = "Enabled"
+)
+
+type ObjectLockConfiguration struct {
+	XMLName           xml.Name        `xml:"ObjectLockConfiguration"`
+	ObjectLockEnabled string          `xml:"ObjectLockEnabled,omitempty"`
+	Rule              *ObjectLockRule `xml:"Rule,omitempty"`
+}
+
+type ObjectLockRule struct {
+	DefaultRetention *DefaultRetention `xml:"DefaultRetention"`
+}
+
+type DefaultRetention struct {
+	Mode  string `xml:"Mode"`
+	Days  int    `xml:"Days,omitempty"`
+	Years int    `xml:"Years,omitempty"`
+}
+
+type ObjectRetention struct {
+	XMLName         xml.Name `xml:"Retention"`
+	Mode            string   `xml:"Mode,omitempty"`
+	RetainUntilDate string   `xml:"RetainUntilDate,omitempty"`
+}
+
+type ObjectLegalHold struct {
+	XMLName xml.Name `xml:"LegalHold"`
+	Status  string   `xml:"Status"`
+}
+
+func NewObjectLockConfiguration() *ObjectLockConfiguration {
+	return &ObjectLockConfiguration{
+		XMLName: xml.Name{Local: "ObjectLockConfiguration"},
+	}
+}
+
+func (c *ObjectLockConfiguration) enabled() bool {
+	return c != nil && c.ObjectLockEnabled == ObjectLockEnabled
+}
+
+// defaultRetention returns the retention applied to the new objects which are put without retention.
+func (c *ObjectLockConfiguration) defaultRetention(now time.Time) *proto.ObjectLock {
+	if !c.enabled() || c.Rule == nil || c.Rule.DefaultRetention == nil {
+		return nil
+	}
+	dr := c.Rule.DefaultRetention
+	return &proto.ObjectLock{
+		Mode:        dr.Mode,
+		RetainUntil: now.AddDate(dr.Years, 0, dr.Days).Unix(),
+	}
+}
+
+func isValidObjectLockMode(mode string) bool {
+	return mode == proto.ObjectLockModeGovernance || mode == proto.ObjectLockModeCompliance
+}
+
+func parseObjectLockConfig(bytes []byte) (config *ObjectLockConfiguration, errCode *ErrorCode) {
+	config = NewObjectLockConfiguration()
+	if err := xml.Unmarshal(bytes, config); err != nil {
+		return nil, MalformedXML
+	}
+	// Object lock can not be disabled once it is enabled on the bucket.
+	if config.ObjectLockEnabled != ObjectLockEnabled {
+		return nil, MalformedXML
+	}
+	if config.Rule != nil {
+		dr := config.Rule.DefaultRetention
+		if dr == nil || !isValidObjectLockMode(dr.Mode) {
+			return nil, MalformedXML
+		}
+		// Either Days or Years must be specified, but not both.
+		if dr.Days < 0 || dr.Years < 0 || (dr.Days == 0) == (dr.Years == 0) {
+			return nil, MalformedXML
+		}
+	}
+	return config, nil
+}
+
+func storeBucketObjectLock(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSObjectLock, bytes)
+}
+
+// parseObjectRetention parses the retention of an object. The retention is removed if both the mode
+// and the retain until date are empty.
+func parseObjectRetention(bytes []byte, now time.Time) (lock *proto.ObjectLock, errCode *ErrorCode) {
+	var retention = new(ObjectRetention)
+	if err := xml.Unmarshal(bytes, retention); err != nil {
+		return nil, MalformedXML
+	}
+	return newObjectRetention(retention.Mode, retention.RetainUntilDate, now)
+}
+
+func newObjectRetention(mode, retainUntilDate string, now time.Time) (lock *proto.ObjectLock, errCode *ErrorCode) {
+	lock = new(proto.ObjectLock)
+	if mode == "" && retainUntilDate == "" {
+		return
+	}
+	if !isValidObjectLockMode(mode) || retainUntilDate == "" {
+		return nil, MalformedXML
+	}
+	retainUntil, err := time.Parse(time.RFC3339, retainUntilDate)
+	if err != nil {
+		return nil, InvalidArgument
+	}
+	if !retainUntil.After(now) {
+		return nil, InvalidRetainUntilDate
+	}
+	lock.Mode, lock.RetainUntil = mode, retainUntil.Unix()
+	return
+}
+
+func parseObjectLegalHold(bytes []byte) (on bool, errCode *ErrorCode) {
+	var legalHold = new(ObjectLegalHold)
+	if err := xml.Unmarshal(bytes, legalHold); err != nil {
+		return false, MalformedXML
+	}
+	if legalHold.Status != proto.ObjectLockLegalHoldOn && legalHold.Status != proto.ObjectLockLegalHoldOff {
+		return false, MalformedXML
+	}
+	return legalHold.Status == proto.ObjectLockLegalHoldOn, nil
+}
+
+// parseObjectLockHeaders parses the retention and legal hold specified in the headers of the
+// requests which create objects. A nil lock is returned if none of them is specified.
+func parseObjectLockHeaders(header http.Header, now time.Time) (lock *proto.ObjectLock, errCode *ErrorCode) {
+	mode := header.Get(HeaderNameXAmzObjectLockMode)
+	retainUntilDate := header.Get(HeaderNameXAmzObjectLockRetainUntilDate)
+	legalHold := header.Get(HeaderNameXAmzObjectLockLegalHold)
+	if mode == "" && retainUntilDate == "" && legalHold == "" {
+		return
+	}
+	if (mode == "") != (retainUntilDate == "") {
+		return nil, InvalidArgument
+	}
+	if lock, errCode = newObjectRetention(mode, retainUntilDate, now); errCode != nil {
+		return
+	}
+	if legalHold != "" {
+		if legalHold != proto.ObjectLockLegalHoldOn && legalHold != proto.ObjectLockLegalHoldOff {
+			return nil, InvalidArgument
+		}
+		lock.LegalHold = legalHold == proto.ObjectLockLegalHoldOn
+	}
+	return
+}
+
+func objectLockFromXAttr(xattr *proto.XAttrInfo) *proto.ObjectLock {
+	if xattr == nil {
+		return nil
+	}
+	mode := string(xattr.Get(proto.XAttrKeyObjectLockMode))
+	legalHold := string(xattr.Get(proto.XAttrKeyObjectLockLegalHold))
+	if mode == "" && legalHold == "" {
+		return nil
+	}
+	return proto.ParseObjectLock(mode, string(xattr.Get(proto.XAttrKeyObjectLockRetainUntil)), legalHold)
+}
+
+// setObjectLockHeaders sets the object lock headers of GetObject and HeadObject.
+func setObjectLockHeaders(header http.Header, lock *proto.ObjectLock) {
+	if lock == nil {
+		return
+	}
+	if lock.Mode != "" {
+		header[HeaderNameXAmzObjectLockMode] = []string{lock.Mode}
+		header[HeaderNameXAmzObjectLockRetainUntilDate] = []string{formatTimeISO(time.Unix(lock.RetainUntil, 0))}
+	}
+	legalHold := proto.ObjectLockLegalHoldOff
+	if lock.LegalHold {
+		legalHold = proto.ObjectLockLegalHoldOn
+	}
+	header[HeaderNameXAmzObjectLockLegalHold] = []string{legalHold}
+}
+
+func isBypassGovernanceRetention(header http.Header) bool {
+	return strings.ToLower(header.Get(HeaderNameXAmzBypassGovernanceRetention)) == "true"
+}
@@ -0,0 +1,408 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	MaxObjectLockConfigSize = 1 << 10 // 1KB
+)
+
+// Get object lock configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLockConfiguration.html
+func (o *ObjectNode) getObjectLockConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getObjectLockConfigurationHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var objectLock *ObjectLockConfiguration
+	if objectLock, err = vol.metaLoader.loadObjectLock(); err != nil {
+		log.LogErrorf("getObjectLockConfigurationHandler: load object lock fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if !objectLock.enabled() {
+		errorCode = ObjectLockConfigurationNotFound
+		return
+	}
+
+	var data []byte
+	if data, err = MarshalXMLEntity(objectLock); err != nil {
+		log.LogErrorf("getObjectLockConfigurationHandler: xml marshal fail: requestID(%v) volume(%v) config(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), objectLock, err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	if _, err = w.Write(data); err != nil {
+		log.LogErrorf("getObjectLockConfigurationHandler: write response body fail: requestID(%v) volume(%v) body(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(data), err)
+	}
+	return
+}
+
+// Put object lock configuration
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectLockConfiguration.html
+func (o *ObjectNode) putObjectLockConfigurationHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("putObjectLockConfigurationHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, MaxObjectLockConfigSize+1)); err != nil {
+		log.LogErrorf("putObjectLockConfigurationHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxObjectLockConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+
+	var objectLock *ObjectLockConfiguration
+	if objectLock, errorCode = parseObjectLockConfig(body); errorCode != nil {
+		log.LogErrorf("putObjectLockConfigurationHandler: parse object lock config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), errorCode)
+		return
+	}
+	// Object lock works on the object versions, so versioning must be enabled on the bucket.
+	var status string
+	if status, err = vol.versioningStatus(); err != nil {
+		log.LogErrorf("putObjectLockConfigurationHandler: load versioning fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if status != VersioningStatusEnabled {
+		errorCode = InvalidBucketState
+		return
+	}
+	if err = storeBucketObjectLock(body, vol); err != nil {
+		log.LogErrorf("putObjectLockConfigurationHandler: store object lock config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	vol.metaLoader.storeObjectLock(objectLock)
+
+	return
+}
+
+// Get object retention
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectRetention.html
+func (o *ObjectNode) getObjectRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var fileInfo *FSFileInfo
+	if _, fileInfo, errorCode, err = o.loadObjectLockTarget(r, "getObjectRetentionHandler"); errorCode != nil || err != nil {
+		return
+	}
+	if fileInfo.ObjectLock == nil || fileInfo.ObjectLock.Mode == "" {
+		errorCode = NoSuchObjectLockConfiguration
+		return
+	}
+
+	var retention = &ObjectRetention{
+		Mode:            fileInfo.ObjectLock.Mode,
+		RetainUntilDate: formatTimeISO(time.Unix(fileInfo.ObjectLock.RetainUntil, 0)),
+	}
+	var data []byte
+	if data, err = MarshalXMLEntity(retention); err != nil {
+		log.LogErrorf("getObjectRetentionHandler: xml marshal fail: requestID(%v) retention(%+v) err(%v)",
+			GetRequestID(r), retention, err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	if _, err = w.Write(data); err != nil {
+		log.LogErrorf("getObjectRetentionHandler: write response body fail: requestID(%v) body(%v) err(%v)",
+			GetRequestID(r), string(data), err)
+	}
+	return
+}
+
+// Put object retention
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectRetention.html
+func (o *ObjectNode) putObjectRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var (
+		vol      *Volume
+		fileInfo *FSFileInfo
+	)
+	if vol, fileInfo, errorCode, err = o.loadObjectLockTarget(r, "putObjectRetentionHandler"); errorCode != nil || err != nil {
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, MaxObjectLockConfigSize+1)); err != nil {
+		log.LogErrorf("putObjectRetentionHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxObjectLockConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	var now = time.Now()
+	var retention *proto.ObjectLock
+	if retention, errorCode = parseObjectRetention(body, now); errorCode != nil {
+		log.LogErrorf("putObjectRetentionHandler: parse retention fail: requestID(%v) volume(%v) body(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), errorCode)
+		return
+	}
+
+	// Shortening or removing the retention in governance mode requires bypassing the governance retention,
+	// and the retention in compliance mode can not be shortened or removed.
+	var old = fileInfo.ObjectLock
+	if old.Retained(now.Unix()) {
+		if err = old.CheckUpdate(retention, now.Unix()); err != nil {
+			return
+		}
+		var shortened = retention.Mode == "" || retention.RetainUntil < old.RetainUntil
+		if old.Mode == proto.ObjectLockModeGovernance && shortened && !o.isBypassGovernanceAllowed(r, ParseRequestParam(r), vol) {
+			errorCode = ObjectLocked
+			return
+		}
+	}
+
+	if err = vol.SetObjectRetention(fileInfo.Inode, retention); err != nil {
+		log.LogErrorf("putObjectRetentionHandler: set retention fail: requestID(%v) volume(%v) path(%v) inode(%v) retention(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), fileInfo.Path, fileInfo.Inode, retention, err)
+		if err == syscall.EPERM {
+			err, errorCode = nil, ObjectLocked
+		}
+		return
+	}
+	return
+}
+
+// Get object legal hold
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLegalHold.html
+func (o *ObjectNode) getObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var fileInfo *FSFileInfo
+	if _, fileInfo, errorCode, err = o.loadObjectLockTarget(r, "getObjectLegalHoldHandler"); errorCode != nil || err != nil {
+		return
+	}
+	var legalHold = &ObjectLegalHold{Status: proto.ObjectLockLegalHoldOff}
+	if fileInfo.ObjectLock != nil && fileInfo.ObjectLock.LegalHold {
+		legalHold.Status = proto.ObjectLockLegalHoldOn
+	}
+
+	var data []byte
+	if data, err = MarshalXMLEntity(legalHold); err != nil {
+		log.LogErrorf("getObjectLegalHoldHandler: xml marshal fail: requestID(%v) legalHold(%+v) err(%v)",
+			GetRequestID(r), legalHold, err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	if _, err = w.Write(data); err != nil {
+		log.LogErrorf("getObjectLegalHoldHandler: write response body fail: requestID(%v) body(%v) err(%v)",
+			GetRequestID(r), string(data), err)
+	}
+	return
+}
+
+// Put object legal hold
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectLegalHold.html
+func (o *ObjectNode) putObjectLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var (
+		vol      *Volume
+		fileInfo *FSFileInfo
+	)
+	if vol, fileInfo, errorCode, err = o.loadObjectLockTarget(r, "putObjectLegalHoldHandler"); errorCode != nil || err != nil {
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, MaxObjectLockConfigSize+1)); err != nil {
+		log.LogErrorf("putObjectLegalHoldHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxObjectLockConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	var on bool
+	if on, errorCode = parseObjectLegalHold(body); errorCode != nil {
+		log.LogErrorf("putObjectLegalHoldHandler: parse legal hold fail: requestID(%v) volume(%v) body(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), errorCode)
+		return
+	}
+	if err = vol.SetObjectLegalHold(fileInfo.Inode, on); err != nil {
+		log.LogErrorf("putObjectLegalHoldHandler: set legal hold fail: requestID(%v) volume(%v) path(%v) inode(%v) on(%v) err(%v)",
+			GetRequestID(r), vol.Name(), fileInfo.Path, fileInfo.Inode, on, err)
+		return
+	}
+	return
+}
+
+// loadObjectLockTarget returns the object version which the object retention and legal hold APIs operate on.
+// Object lock must be enabled on the bucket.
+func (o *ObjectNode) loadObjectLockTarget(r *http.Request, handler string) (vol *Volume, fileInfo *FSFileInfo, errorCode *ErrorCode, err error) {
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	if param.Object() == "" {
+		errorCode = InvalidKey
+		return
+	}
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("%v: load volume fail: requestID(%v) volume(%v) err(%v)",
+			handler, GetRequestID(r), param.Bucket(), err)
+		return
+	}
+	var objectLock *ObjectLockConfiguration
+	if objectLock, err = vol.metaLoader.loadObjectLock(); err != nil {
+		log.LogErrorf("%v: load object lock fail: requestID(%v) volume(%v) err(%v)",
+			handler, GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if !objectLock.enabled() {
+		errorCode = MissingObjectLockConfiguration
+		return
+	}
+
+	var versionId = r.URL.Query().Get(ParamVersionId)
+	if fileInfo, _, err = vol.ObjectVersionMeta(param.Object(), versionId); err != nil {
+		log.LogErrorf("%v: get object meta fail: requestID(%v) volume(%v) path(%v) versionId(%v) err(%v)",
+			handler, GetRequestID(r), vol.Name(), param.Object(), versionId, err)
+		if err == syscall.ENOENT {
+			err = nil
+			errorCode = NoSuchKey
+			if versionId != "" {
+				errorCode = NoSuchVersion
+			}
+		}
+		return
+	}
+	if fileInfo.DeleteMarker {
+		errorCode = MethodNotAllowed
+		return
+	}
+	return
+}
+
+// isBypassGovernanceAllowed returns true if the request bypasses the governance retention, which is
+// only allowed for the bucket owner.
+func (o *ObjectNode) isBypassGovernanceAllowed(r *http.Request, param *RequestParam, vol *Volume) bool {
+	if !isBypassGovernanceRetention(r.Header) {
+		return false
+	}
+	userInfo, err := o.getUserInfoByAccessKeyV2(param.AccessKey())
+	if err != nil {
+		log.LogErrorf("isBypassGovernanceAllowed: get user info fail: requestID(%v) volume(%v) accessKey(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.AccessKey(), err)
+		return false
+	}
+	return userInfo.UserID == vol.owner
+}
+
+// newObjectLock returns the retention and legal hold of the object to be created. The default retention of
+// the bucket is applied if the retention is not specified in the request headers.
+func newObjectLock(vol *Volume, header http.Header) (lock *proto.ObjectLock, err error) {
+	var objectLock *ObjectLockConfiguration
+	if objectLock, err = vol.metaLoader.loadObjectLock(); err != nil {
+		return
+	}
+	var now = time.Now()
+	var errorCode *ErrorCode
+	if lock, errorCode = parseObjectLockHeaders(header, now); errorCode != nil {
+		return nil, errorCode
+	}
+	if !objectLock.enabled() {
+		if lock != nil {
+			return nil, MissingObjectLockConfiguration
+		}
+		return
+	}
+	if lock == nil || lock.Mode == "" {
+		if dr := objectLock.defaultRetention(now); dr != nil {
+			if lock != nil {
+				dr.LegalHold = lock.LegalHold
+			}
+			lock = dr
+		}
+	}
+	return
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseObjectLockConfig(t *testing.T) {
+	config, errCode := parseObjectLockConfig([]byte(`<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled></ObjectLockConfiguration>`))
+	require.Nil(t, errCode)
+	require.True(t, config.enabled())
+	require.Nil(t, config.defaultRetention(time.Now()))
+
+	config, errCode = parseObjectLockConfig([]byte(`<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled>` +
+		`<Rule><DefaultRetention><Mode>COMPLIANCE</Mode><Days>1</Days></DefaultRetention></Rule></ObjectLockConfiguration>`))
+	require.Nil(t, errCode)
+	now := time.Now()
+	lock := config.defaultRetention(now)
+	require.Equal(t, proto.ObjectLockModeCompliance, lock.Mode)
+	require.Equal(t, now.AddDate(0, 0, 1).Unix(), lock.RetainUntil)
+
+	_, errCode = parseObjectLockConfig([]byte(`<ObjectLockConfiguration></ObjectLockConfiguration>`))
+	require.Equal(t, MalformedXML, errCode)
+	_, errCode = parseObjectLockConfig([]byte(`<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled>` +
+		`<Rule><DefaultRetention><Mode>NONE</Mode><Days>1</Days></DefaultRetention></Rule></ObjectLockConfiguration>`))
+	require.Equal(t, MalformedXML, errCode)
+	_, errCode = parseObjectLockConfig([]byte(`<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled>` +
+		`<Rule><DefaultRetention><Mode>GOVERNANCE</Mode><Days>1</Days><Years>1</Years></DefaultRetention></Rule></ObjectLockConfiguration>`))
+	require.Equal(t, MalformedXML, errCode)
+}
+
+func TestParseObjectRetention(t *testing.T) {
+	now := time.Now()
+	future := now.Add(time.Hour).UTC().Format(time.RFC3339)
+	lock, errCode := parseObjectRetention([]byte(`<Retention><Mode>GOVERNANCE</Mode><RetainUntilDate>`+future+`</RetainUntilDate></Retention>`), now)
+	require.Nil(t, errCode)
+	require.Equal(t, proto.ObjectLockModeGovernance, lock.Mode)
+	require.True(t, lock.Retained(now.Unix()))
+
+	lock, errCode = parseObjectRetention([]byte(`<Retention></Retention>`), now)
+	require.Nil(t, errCode)
+	require.Equal(t, "", lock.Mode)
+
+	past := now.Add(-time.Hour).UTC().Format(time.RFC3339)
+	_, errCode = parseObjectRetention([]byte(`<Retention><Mode>GOVERNANCE</Mode><RetainUntilDate>`+past+`</RetainUntilDate></Retention>`), now)
+	require.Equal(t, InvalidRetainUntilDate, errCode)
+	_, errCode = parseObjectRetention([]byte(`<Retention><Mode>GOVERNANCE</Mode></Retention>`), now)
+	require.Equal(t, MalformedXML, errCode)
+}
+
+func TestParseObjectLockHeaders(t *testing.T) {
+	now := time.Now()
+	header := make(http.Header)
+	lock, errCode := parseObjectLockHeaders(header, now)
+	require.Nil(t, errCode)
+	require.Nil(t, lock)
+
+	header.Set(HeaderNameXAmzObjectLockLegalHold, proto.ObjectLockLegalHoldOn)
+	lock, errCode = parseObjectLockHeaders(header, now)
+	require.Nil(t, errCode)
+	require.True(t, lock.LegalHold)
+	require.Equal(t, "", lock.Mode)
+
+	header.Set(HeaderNameXAmzObjectLockMode, proto.ObjectLockModeCompliance)
+	_, errCode = parseObjectLockHeaders(header, now)
+	require.Equal(t, InvalidArgument, errCode)
+
+	header.Set(HeaderNameXAmzObjectLockRetainUntilDate, now.Add(time.Hour).UTC().Format(time.RFC3339))
+	lock, errCode = parseObjectLockHeaders(header, now)
+	require.Nil(t, errCode)
+	require.Equal(t, proto.ObjectLockModeCompliance, lock.Mode)
+	require.True(t, lock.Locked(now.Unix()))
+
+	resp := make(http.Header)
+	setObjectLockHeaders(resp, lock)
+	require.Equal(t, []string{proto.ObjectLockModeCompliance}, resp[HeaderNameXAmzObjectLockMode])
+	require.Equal(t, []string{proto.ObjectLockLegalHoldOn}, resp[HeaderNameXAmzObjectLockLegalHold])
+}
@@ -65,6 +65,16 @@ func (p *Policy) IsEmpty() bool {
 	return len(p.Statements) == 0
 }
 
+// IsPublic returns true if any statement of the policy grants public access.
+func (p *Policy) IsPublic() bool {
+	for i := range p.Statements {
+		if p.Statements[i].isPublic() {
+			return true
+		}
+	}
+	return false
+}
+
 func ParsePolicy(data []byte) (*Policy, error) {
 	policy := new(Policy)
 	dec := json.NewDecoder(bytes.NewReader(data))
@@ -240,14 +250,14 @@ func (o *ObjectNode) policyCheck(f http.HandlerFunc) http.HandlerFunc {
 
 		// step3. Check bucket policy
 	policycheck:
-		vol, acl, policy, err := o.loadBucketMeta(param.Bucket())
+		vol, acl, policy, block, err := o.loadBucketMeta(param.Bucket())
 		if err != nil {
 			log.LogErrorf("bucket policy check: load bucket metadata fail: requestID(%v) err(%v)", GetRequestID(r), err)
 			allowed = false
 			return
 		}
-		log.LogDebugf("bucket policy check: load bucket metadata, requestID(%v) userPolicy(%v/%+v) vol(%v/%v) acl(%+v) policy(%+v)",
-			GetRequestID(r), userInfo.UserID, userInfo.Policy, vol.Name(), vol.GetOwner(), acl, policy)
+		log.LogDebugf("bucket policy check: load bucket metadata, requestID(%v) userPolicy(%v/%+v) vol(%v/%v) acl(%+v) policy(%+v) publicAccessBlock(%+v)",
+			GetRequestID(r), userInfo.UserID, userInfo.Policy, vol.Name(), vol.GetOwner(), acl, policy, block)
 		if vol != nil && policy != nil && !policy.IsEmpty() {
 			log.LogDebugf("bucket policy check: requestID(%v) policy(%v)", GetRequestID(r), policy)
 			conditionCheck := map[string]string{
@@ -259,6 +269,13 @@ func (o *ObjectNode) policyCheck(f http.HandlerFunc) http.HandlerFunc {
 				conditionCheck[KEYNAME] = param.object
 			}
 			pcr := policy.IsAllowed(param, userInfo.UserID, vol.owner, conditionCheck)
+			// Only the bucket owner and the authorized users can access the bucket with a public policy
+			// if public buckets are restricted.
+			if pcr == POLICY_ALLOW && !isOwner && block.RestrictPublicBuckets && policy.IsPublic() {
+				log.LogWarnf("bucket policy check: public bucket restricted: requestID(%v) reqUid(%v) volume(%v)",
+					GetRequestID(r), userInfo.UserID, param.Bucket())
+				pcr = POLICY_UNKNOW
+			}
 			switch pcr {
 			case POLICY_ALLOW:
 				allowed = true
@@ -290,6 +307,9 @@ func (o *ObjectNode) policyCheck(f http.HandlerFunc) http.HandlerFunc {
 				}
 				err = nil
 			}
+			if acl != nil && block.IgnorePublicAcls {
+				acl = acl.WithoutPublicGrants()
+			}
 			if acl == nil && !isOwner {
 				allowed = false
 				log.LogWarnf("acl check: empty acl disallows: requestID(%v) reqUid(%v) ownerUid(%v) volume(%v) action(%v)",
@@ -315,7 +335,8 @@ func (o *ObjectNode) policyCheck(f http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-func (o *ObjectNode) loadBucketMeta(bucket string) (vol *Volume, acl *AccessControlPolicy, policy *Policy, err error) {
+func (o *ObjectNode) loadBucketMeta(bucket string) (vol *Volume, acl *AccessControlPolicy, policy *Policy,
+	block *PublicAccessBlockConfiguration, err error) {
 	if vol, err = o.getVol(bucket); err != nil {
 		return
 	}
@@ -325,6 +346,9 @@ func (o *ObjectNode) loadBucketMeta(bucket string) (vol *Volume, acl *AccessCont
 	if policy, err = vol.metaLoader.loadPolicy(); err != nil {
 		return
 	}
+	if block, err = loadPublicAccessBlock(vol); err != nil {
+		return
+	}
 	return
 }
 
@@ -335,7 +359,7 @@ func (o *ObjectNode) allowedBySrcBucketPolicy(param *RequestParam, reqUid string
 		log.LogDebugf("copySource(%v) argument invalid: requestID(%v)", paramCopy.r.Header.Get(HeaderNameXAmzCopySource), GetRequestID(paramCopy.r))
 		return
 	}
-	vol, acl, policy, err := o.loadBucketMeta(srcBucketId)
+	vol, acl, policy, block, err := o.loadBucketMeta(srcBucketId)
 	if err != nil {
 		log.LogErrorf("srcBucket policy check: load bucket metadata fail: requestID(%v) err(%v)", GetRequestID(paramCopy.r), err)
 		return
@@ -350,6 +374,11 @@ func (o *ObjectNode) allowedBySrcBucketPolicy(param *RequestParam, reqUid string
 			HOST:     paramCopy.r.Host,
 		}
 		pcr := policy.IsAllowed(&paramCopy, reqUid, vol.owner, conditionCheck)
+		if pcr == POLICY_ALLOW && reqUid != vol.owner && block.RestrictPublicBuckets && policy.IsPublic() {
+			log.LogWarnf("srcBucket policy check: public bucket restricted: requestID(%v) reqUid(%v) volume(%v)",
+				GetRequestID(paramCopy.r), reqUid, srcBucketId)
+			pcr = POLICY_UNKNOW
+		}
 		switch pcr {
 		case POLICY_ALLOW:
 			log.LogDebugf("srcBucket policy check: policy allowed: requestID(%v)", GetRequestID(paramCopy.r))
@@ -370,6 +399,9 @@ func (o *ObjectNode) allowedBySrcBucketPolicy(param *RequestParam, reqUid string
 		return
 	}
 	err = nil
+	if acl != nil && block.IgnorePublicAcls {
+		acl = acl.WithoutPublicGrants()
+	}
 	if acl == nil && !isOwner {
 		log.LogWarnf("srcBucket acl check: empty acl disallows: requestID(%v) reqUid(%v) ownerUid(%v) volume(%v) action(%v)",
 			GetRequestID(paramCopy.r), reqUid, vol.owner, srcBucketId, paramCopy.Action())
@@ -117,6 +117,20 @@ func (o *ObjectNode) putBucketPolicyHandler(w http.ResponseWriter, r *http.Reque
 			GetRequestID(r), policy, vol.name, err)
 		return
 	}
+	if policy.IsPublic() {
+		var block *PublicAccessBlockConfiguration
+		if block, err = loadPublicAccessBlock(vol); err != nil {
+			log.LogErrorf("putBucketPolicyHandler: load public access block fail: requestID(%v) bucket(%v) err(%v)",
+				GetRequestID(r), vol.name, err)
+			return
+		}
+		if block.BlockPublicPolicy {
+			log.LogErrorf("putBucketPolicyHandler: public policy blocked: requestID(%v) policy(%v) bucket(%v)",
+				GetRequestID(r), policy, vol.name)
+			ec = AccessDenied
+			return
+		}
+	}
 	if err = storeBucketPolicy(vol, policyRaw); err != nil {
 		log.LogErrorf("putBucketPolicyHandler: store policy fail: requestID(%v) err(%v)", GetRequestID(r), err)
 		return
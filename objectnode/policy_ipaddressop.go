@@ -93,6 +93,20 @@ func (op ipAddressOp) toMap() map[Key]ValueSet {
 	return resultMap
 }
 
+// isFixed returns true if all the networks of this operation are not broader than /8 for IPv4
+// or /32 for IPv6, so that the operation restricts the source IP to a fixed range.
+func (op ipAddressOp) isFixed() bool {
+	for _, v := range op.m {
+		for _, info := range v {
+			ones, bits := info.Net.Mask.Size()
+			if (bits == net.IPv4len*8 && ones < 8) || (bits == net.IPv6len*8 && ones < 32) {
+				return false
+			}
+		}
+	}
+	return len(op.m) > 0
+}
+
 // Not IP address operation. It checks whether value by Key in given
 // values is NOT in IP network.  Here Key must be AWSSourceIP.
 
@@ -50,6 +50,42 @@ func (s Statement) IsAllowed(p *RequestParam) bool {
 	return s.Effect == Allow
 }
 
+// isPublic returns true if the statement allows any principal, unless the
+// statement restricts the source IP to a fixed range.
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/access-control-block-public-access.html#access-control-block-public-access-policy-status
+func (s *Statement) isPublic() bool {
+	if !strings.EqualFold(s.Effect, Allow) || !s.hasAnyPrincipal() {
+		return false
+	}
+	for _, op := range s.Condition {
+		if ipOp, ok := op.(*ipAddressOp); ok && ipOp.isFixed() {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Statement) hasAnyPrincipal() bool {
+	var principals []interface{}
+	switch p := s.Principal.(type) {
+	case string:
+		principals = append(principals, p)
+	case map[string]interface{}:
+		switch p1 := p[S3_PRINCIPAL_PREFIX].(type) {
+		case string:
+			principals = append(principals, p1)
+		case []interface{}:
+			principals = p1
+		}
+	}
+	for _, p := range principals {
+		if p == string(Principal_Any) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Statement) Validate(bucket string) (bool, error) {
 	return s.isValid(bucket)
 }
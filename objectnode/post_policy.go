@@ -0,0 +1,409 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectPOST.html
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-HTTPPOSTConstructPolicy.html
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/gorilla/mux"
+)
+
+const (
+	PostFormFieldKey                   = "key"
+	PostFormFieldFile                  = "file"
+	PostFormFieldPolicy                = "policy"
+	PostFormFieldAcl                   = "acl"
+	PostFormFieldTagging               = "tagging"
+	PostFormFieldSuccessActionRedirect = "success_action_redirect"
+	PostFormFieldRedirect              = "redirect"
+	PostFormFieldSuccessActionStatus   = "success_action_status"
+	PostFormFieldAWSAccessKeyId        = "AWSAccessKeyId"
+	PostFormFieldSignature             = "signature"
+	PostFormFieldBucket                = "bucket"
+
+	PostPolicyOperatorEq         = "eq"
+	PostPolicyOperatorStartsWith = "starts-with"
+	PostPolicyContentLengthRange = "content-length-range"
+	postPolicyIgnoredFieldPrefix = "x-ignore-"
+	postPolicyFilenameVariable   = "${filename}"
+
+	MaxPostFormSize = 20 << 10 // 20KB, the size of the form fields preceding the file
+)
+
+// The form fields which are not required to be specified in the conditions of the policy.
+var postPolicyExemptFields = []string{
+	http.CanonicalHeaderKey(PostFormFieldPolicy),
+	http.CanonicalHeaderKey(PostFormFieldSignature),
+	http.CanonicalHeaderKey(PostFormFieldAWSAccessKeyId),
+	http.CanonicalHeaderKey(XAmzSignature),
+}
+
+// The form fields which are used as the request headers of the uploaded object.
+var postFormHeaders = []string{
+	HeaderNameContentType,
+	HeaderNameContentDisposition,
+	HeaderNameContentEnc,
+	HeaderNameCacheControl,
+	HeaderNameExpires,
+}
+
+// postForm is the multipart/form-data body of a browser POST upload. The form fields preceding the
+// file are parsed in advance, and the form reads the content of the file within the size range.
+type postForm struct {
+	src      io.ReadCloser
+	fields   http.Header
+	filename string
+	file     *multipart.Part
+	minSize  int64
+	maxSize  int64 // -1 if unlimited
+	read     int64
+}
+
+// parsePostForm parses the form fields of the browser POST upload and replaces the request body with the
+// form, which reads the content of the file. The key of the form is set as the object of the request.
+func parsePostForm(r *http.Request) (form *postForm, err error) {
+	var is bool
+	if form, is = r.Body.(*postForm); is {
+		return
+	}
+	var reader *multipart.Reader
+	if reader, err = r.MultipartReader(); err != nil {
+		return nil, MalformedPOSTRequest
+	}
+	form = &postForm{
+		src:     r.Body,
+		fields:  make(http.Header),
+		maxSize: -1,
+	}
+	var size int
+	for form.file == nil {
+		var part *multipart.Part
+		if part, err = reader.NextPart(); err == io.EOF {
+			return nil, IncorrectNumberOfFilesInPostRequest
+		}
+		if err != nil {
+			return nil, MalformedPOSTRequest
+		}
+		var name = part.FormName()
+		if name == "" {
+			continue
+		}
+		if strings.EqualFold(name, PostFormFieldFile) {
+			form.file = part
+			form.filename = part.FileName()
+			break
+		}
+		var value []byte
+		if value, err = ioutil.ReadAll(io.LimitReader(part, int64(MaxPostFormSize-size+1))); err != nil {
+			return nil, MalformedPOSTRequest
+		}
+		if size += len(value); size > MaxPostFormSize {
+			return nil, MaxPostPreDataLengthExceeded
+		}
+		form.fields.Set(name, string(value))
+	}
+	// the identity is taken from the fields of the signature verified, so either version is signed, not both
+	if form.signedV4() {
+		if form.fields.Get(PostFormFieldAWSAccessKeyId) != "" || form.fields.Get(PostFormFieldSignature) != "" ||
+			form.accessKey() == "" {
+			return nil, InvalidArgument
+		}
+	}
+	if key := form.fields.Get(PostFormFieldKey); key != "" {
+		key = strings.Replace(key, postPolicyFilenameVariable, form.filename, -1)
+		form.fields.Set(PostFormFieldKey, key)
+		mux.Vars(r)["object"] = key
+	}
+	r.Body = form
+	return form, nil
+}
+
+func isPostPolicyRequest(r *http.Request) bool {
+	_, is := r.Body.(*postForm)
+	return is
+}
+
+// signedV4 reports whether the form is signed with signature algorithm version 4, otherwise with version 2.
+func (f *postForm) signedV4() bool {
+	return f.fields.Get(XAmzAlgorithm) != "" || f.fields.Get(XAmzCredential) != "" ||
+		f.fields.Get(XAmzSignature) != ""
+}
+
+// accessKey returns the access key of the signed form, or empty if the form is anonymous.
+// It is the key whose signature is verified by validatePostPolicySignature.
+func (f *postForm) accessKey() string {
+	if f.signedV4() {
+		var req = &signatureRequestV4{}
+		if err := req.parseCredential(f.fields.Get(XAmzCredential)); err != nil {
+			return ""
+		}
+		return req.Credential.AccessKey
+	}
+	return f.fields.Get(PostFormFieldAWSAccessKeyId)
+}
+
+// setHeaders sets the form fields, which describe the object, to the request headers.
+func (f *postForm) setHeaders(header http.Header) {
+	header.Del(HeaderNameContentType)
+	for _, name := range postFormHeaders {
+		if value := f.fields.Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+	for name, values := range f.fields {
+		if strings.HasPrefix(name, "X-Amz-") {
+			header[name] = values
+		}
+	}
+	if acl := f.fields.Get(PostFormFieldAcl); acl != "" {
+		header.Set(XAmzAcl, acl)
+	}
+	// the object uploaded by the browser is never a replica
+	header.Del(HeaderNameXAmzReplicationStatus)
+}
+
+func (f *postForm) Read(p []byte) (n int, err error) {
+	n, err = f.file.Read(p)
+	f.read += int64(n)
+	if f.maxSize >= 0 && f.read > f.maxSize {
+		return n, EntityTooLarge
+	}
+	if err == io.EOF && f.read < f.minSize {
+		return n, EntityTooSmall
+	}
+	return
+}
+
+func (f *postForm) Close() error {
+	return f.src.Close()
+}
+
+// PostPolicy is the policy document of a browser POST upload.
+type PostPolicy struct {
+	Expiration time.Time
+	Conditions []PostPolicyCondition
+	MinSize    int64
+	MaxSize    int64 // -1 if unlimited
+}
+
+type PostPolicyCondition struct {
+	Operator string
+	Name     string // canonical name of the form field
+	Value    string
+}
+
+func parsePostPolicyConditionName(name string) (string, bool) {
+	if !strings.HasPrefix(name, "$") {
+		return "", false
+	}
+	return http.CanonicalHeaderKey(name[1:]), true
+}
+
+func parsePostPolicySize(v interface{}) (size int64, ok bool) {
+	switch value := v.(type) {
+	case float64:
+		return int64(value), value >= 0 && value == float64(int64(value))
+	case string:
+		var err error
+		size, err = strconv.ParseInt(value, 10, 64)
+		return size, err == nil && size >= 0
+	}
+	return 0, false
+}
+
+// parsePostPolicy decodes the base64 encoded policy document.
+func parsePostPolicy(encoded string) (policy *PostPolicy, err error) {
+	var data []byte
+	if data, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+		return nil, InvalidPolicyDocument
+	}
+	var raw = struct {
+		Expiration string            `json:"expiration"`
+		Conditions []json.RawMessage `json:"conditions"`
+	}{}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, InvalidPolicyDocument
+	}
+	policy = &PostPolicy{MaxSize: -1}
+	if policy.Expiration, err = time.Parse(time.RFC3339, raw.Expiration); err != nil {
+		return nil, InvalidPolicyDocument
+	}
+	for _, rawCondition := range raw.Conditions {
+		// {"acl": "public-read"} is the shorthand of ["eq", "$acl", "public-read"]
+		var exact map[string]string
+		if err = json.Unmarshal(rawCondition, &exact); err == nil {
+			for name, value := range exact {
+				policy.Conditions = append(policy.Conditions, PostPolicyCondition{
+					Operator: PostPolicyOperatorEq,
+					Name:     http.CanonicalHeaderKey(strings.TrimPrefix(name, "$")),
+					Value:    value,
+				})
+			}
+			continue
+		}
+		var items []interface{}
+		if err = json.Unmarshal(rawCondition, &items); err != nil || len(items) != 3 {
+			return nil, InvalidPolicyDocument
+		}
+		var operator, _ = items[0].(string)
+		switch operator = strings.ToLower(operator); operator {
+		case PostPolicyOperatorEq, PostPolicyOperatorStartsWith:
+			var name, isName = items[1].(string)
+			var value, isValue = items[2].(string)
+			if !isName || !isValue {
+				return nil, InvalidPolicyDocument
+			}
+			var ok bool
+			if name, ok = parsePostPolicyConditionName(name); !ok {
+				return nil, InvalidPolicyDocument
+			}
+			policy.Conditions = append(policy.Conditions, PostPolicyCondition{Operator: operator, Name: name, Value: value})
+		case PostPolicyContentLengthRange:
+			var minOk, maxOk bool
+			policy.MinSize, minOk = parsePostPolicySize(items[1])
+			policy.MaxSize, maxOk = parsePostPolicySize(items[2])
+			if !minOk || !maxOk || policy.MinSize > policy.MaxSize {
+				return nil, InvalidPolicyDocument
+			}
+		default:
+			return nil, InvalidPolicyDocument
+		}
+	}
+	return policy, nil
+}
+
+// match checks that the policy has not expired, the form satisfies all the conditions, and each form
+// field is specified in the conditions. The content length range is checked while reading the file.
+func (p *PostPolicy) match(bucket string, fields http.Header) *ErrorCode {
+	if time.Now().After(p.Expiration) {
+		return PostPolicyExpired
+	}
+	var specified = make(map[string]bool)
+	for _, condition := range p.Conditions {
+		var value = fields.Get(condition.Name)
+		if condition.Name == http.CanonicalHeaderKey(PostFormFieldBucket) {
+			value = bucket
+		}
+		switch condition.Operator {
+		case PostPolicyOperatorEq:
+			if value != condition.Value {
+				return PostPolicyConditionFailed
+			}
+		case PostPolicyOperatorStartsWith:
+			if !strings.HasPrefix(value, condition.Value) {
+				return PostPolicyConditionFailed
+			}
+		}
+		specified[condition.Name] = true
+	}
+	for name := range fields {
+		if specified[name] || contains(postPolicyExemptFields, name) ||
+			strings.HasPrefix(strings.ToLower(name), postPolicyIgnoredFieldPrefix) {
+			continue
+		}
+		return PostPolicyConditionFailed
+	}
+	return nil
+}
+
+// validatePostPolicySignature checks the signature of the policy in the form of the browser POST upload,
+// which is signed with either signature algorithm version 2 or version 4.
+func (o *ObjectNode) validatePostPolicySignature(r *http.Request) (bool, error) {
+	form, err := parsePostForm(r)
+	if err != nil {
+		return false, err
+	}
+	var policy = form.fields.Get(PostFormFieldPolicy)
+	if policy == "" {
+		return false, MissingPostPolicy
+	}
+
+	var accessKey, signature string
+	var calculate func(secretKey string) string
+	if form.signedV4() {
+		if form.fields.Get(XAmzAlgorithm) != SignatureV4Algorithm {
+			return false, InvalidArgument
+		}
+		var req = &signatureRequestV4{}
+		if err = req.parseCredential(form.fields.Get(XAmzCredential)); err != nil {
+			return false, InvalidArgument
+		}
+		accessKey = req.Credential.AccessKey
+		signature = form.fields.Get(XAmzSignature)
+		calculate = func(secretKey string) string {
+			signingKey := buildSigningKey(SCHEME, secretKey, req.Credential.Date, req.Credential.Region,
+				req.Credential.Service, req.Credential.Request)
+			return hex.EncodeToString(sign(policy, signingKey))
+		}
+	} else {
+		accessKey = form.fields.Get(PostFormFieldAWSAccessKeyId)
+		signature = form.fields.Get(PostFormFieldSignature)
+		calculate = func(secretKey string) string {
+			hm := hmac.New(sha1.New, []byte(secretKey))
+			hm.Write([]byte(policy))
+			return base64.StdEncoding.EncodeToString(hm.Sum(nil))
+		}
+	}
+
+	var secretKey string
+	var bucket = mux.Vars(r)["bucket"]
+	if userInfo, err := o.getUserInfoByAccessKey(accessKey); err == nil {
+		secretKey = userInfo.SecretKey
+	} else if err == proto.ErrUserNotExists || err == proto.ErrAccessKeyNotExists || err == proto.ErrParamError {
+		if len(bucket) > 0 {
+			// In order to be directly compatible with the signature verification of version 1.5
+			// (each volume has its own access key and secret key), if the user does not exist,
+			// try to use the access key and secret key bound in the volume information for verification.
+			var volume *Volume
+			if volume, err = o.getVol(bucket); err != nil {
+				return false, err
+			}
+			if ak, sk := volume.OSSSecure(); ak == accessKey {
+				secretKey = sk
+			} else {
+				return false, InvalidAccessKeyId
+			}
+		} else {
+			return false, InvalidAccessKeyId
+		}
+	} else {
+		log.LogErrorf("validatePostPolicySignature: get secretKey from master fail: accessKey(%v) err(%v)",
+			accessKey, err)
+		return false, err
+	}
+
+	if newSignature := calculate(secretKey); newSignature != signature {
+		log.LogDebugf("validatePostPolicySignature: invalid signature: requestID(%v) client(%v) server(%v)",
+			GetRequestID(r), signature, newSignature)
+		return false, SignatureDoesNotMatch
+	}
+	return true, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/access-control-block-public-access.html
+
+import (
+	"encoding/xml"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+type PublicAccessBlockConfiguration struct {
+	XMLName               xml.Name `xml:"PublicAccessBlockConfiguration" json:"-"`
+	BlockPublicAcls       bool     `xml:"BlockPublicAcls" json:"block_public_acls"`
+	IgnorePublicAcls      bool     `xml:"IgnorePublicAcls" json:"ignore_public_acls"`
+	BlockPublicPolicy     bool     `xml:"BlockPublicPolicy" json:"block_public_policy"`
+	RestrictPublicBuckets bool     `xml:"RestrictPublicBuckets" json:"restrict_public_buckets"`
+}
+
+type PolicyStatus struct {
+	XMLName  xml.Name `xml:"PolicyStatus"`
+	IsPublic bool     `xml:"IsPublic"`
+}
+
+func NewPublicAccessBlockConfiguration() *PublicAccessBlockConfiguration {
+	return &PublicAccessBlockConfiguration{
+		XMLName: xml.Name{Local: "PublicAccessBlockConfiguration"},
+	}
+}
+
+func parsePublicAccessBlockConfig(bytes []byte) (config *PublicAccessBlockConfiguration, errCode *ErrorCode) {
+	config = NewPublicAccessBlockConfiguration()
+	if err := xml.Unmarshal(bytes, config); err != nil {
+		return nil, MalformedXML
+	}
+	return config, nil
+}
+
+// merge returns the most restrictive combination of the bucket level and the user level settings,
+// either of them may be nil.
+func (config *PublicAccessBlockConfiguration) merge(block *proto.PublicAccessBlock) *PublicAccessBlockConfiguration {
+	var merged = NewPublicAccessBlockConfiguration()
+	if config != nil {
+		merged.BlockPublicAcls = config.BlockPublicAcls
+		merged.IgnorePublicAcls = config.IgnorePublicAcls
+		merged.BlockPublicPolicy = config.BlockPublicPolicy
+		merged.RestrictPublicBuckets = config.RestrictPublicBuckets
+	}
+	if block != nil {
+		merged.BlockPublicAcls = merged.BlockPublicAcls || block.BlockPublicAcls
+		merged.IgnorePublicAcls = merged.IgnorePublicAcls || block.IgnorePublicAcls
+		merged.BlockPublicPolicy = merged.BlockPublicPolicy || block.BlockPublicPolicy
+		merged.RestrictPublicBuckets = merged.RestrictPublicBuckets || block.RestrictPublicBuckets
+	}
+	return merged
+}
+
+// loadPublicAccessBlock returns the effective public access block settings of the volume,
+// which combine the settings of the bucket and the bucket owner.
+func loadPublicAccessBlock(vol *Volume) (config *PublicAccessBlockConfiguration, err error) {
+	var bucket *PublicAccessBlockConfiguration
+	if bucket, err = vol.metaLoader.loadPublicAccessBlock(); err != nil {
+		return
+	}
+	var owner *proto.PublicAccessBlock
+	if owner, err = vol.metaLoader.loadOwnerPublicAccessBlock(); err != nil {
+		return
+	}
+	return bucket.merge(owner), nil
+}
+
+// checkPublicACL returns AccessDenied if the acl to be set grants public access
+// while the public acls of the volume are blocked.
+func checkPublicACL(vol *Volume, acl *AccessControlPolicy) (err error) {
+	if acl == nil || !acl.IsPublic() {
+		return nil
+	}
+	var config *PublicAccessBlockConfiguration
+	if config, err = loadPublicAccessBlock(vol); err != nil {
+		return
+	}
+	if config.BlockPublicAcls {
+		return AccessDenied
+	}
+	return nil
+}
+
+func storeBucketPublicAccessBlock(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSPublicBlock, bytes)
+}
+
+func deleteBucketPublicAccessBlock(vol *Volume) (err error) {
+	return vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSPublicBlock)
+}
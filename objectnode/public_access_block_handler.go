@@ -0,0 +1,211 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	MaxPublicAccessBlockConfigSize = 4 << 10 // 4KB
+)
+
+// Get public access block
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetPublicAccessBlock.html
+func (o *ObjectNode) getPublicAccessBlockHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getPublicAccessBlockHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var block *PublicAccessBlockConfiguration
+	if block, err = vol.metaLoader.loadPublicAccessBlock(); err != nil {
+		log.LogErrorf("getPublicAccessBlockHandler: load public access block fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if block == nil {
+		errorCode = NoSuchPublicAccessBlock
+		return
+	}
+
+	var data []byte
+	if data, err = MarshalXMLEntity(block); err != nil {
+		log.LogErrorf("getPublicAccessBlockHandler: xml marshal fail: requestID(%v) volume(%v) block(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), block, err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	if _, err = w.Write(data); err != nil {
+		log.LogErrorf("getPublicAccessBlockHandler: write response body fail: requestID(%v) volume(%v) body(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(data), err)
+	}
+	return
+}
+
+// Put public access block
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutPublicAccessBlock.html
+func (o *ObjectNode) putPublicAccessBlockHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("putPublicAccessBlockHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, MaxPublicAccessBlockConfigSize+1)); err != nil {
+		log.LogErrorf("putPublicAccessBlockHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxPublicAccessBlockConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	if md5 := r.Header.Get(HeaderNameContentMD5); md5 != "" && md5 != GetMD5(body) {
+		errorCode = InvalidDigest
+		return
+	}
+
+	var block *PublicAccessBlockConfiguration
+	if block, errorCode = parsePublicAccessBlockConfig(body); errorCode != nil {
+		log.LogErrorf("putPublicAccessBlockHandler: parse public access block fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), errorCode)
+		return
+	}
+	if err = storeBucketPublicAccessBlock(body, vol); err != nil {
+		log.LogErrorf("putPublicAccessBlockHandler: store public access block fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	vol.metaLoader.storePublicAccessBlock(block)
+
+	return
+}
+
+// Delete public access block
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeletePublicAccessBlock.html
+func (o *ObjectNode) deletePublicAccessBlockHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("deletePublicAccessBlockHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+	if err = deleteBucketPublicAccessBlock(vol); err != nil {
+		log.LogErrorf("deletePublicAccessBlockHandler: delete public access block fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	vol.metaLoader.storePublicAccessBlock(nil)
+	w.WriteHeader(http.StatusNoContent)
+
+	return
+}
+
+// Get bucket policy status
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketPolicyStatus.html
+func (o *ObjectNode) getBucketPolicyStatusHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getBucketPolicyStatusHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var policy *Policy
+	if policy, err = vol.metaLoader.loadPolicy(); err != nil {
+		log.LogErrorf("getBucketPolicyStatusHandler: load policy fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if policy == nil {
+		errorCode = NoSuchBucketPolicy
+		return
+	}
+
+	var status = &PolicyStatus{IsPublic: policy.IsPublic()}
+	var data []byte
+	if data, err = MarshalXMLEntity(status); err != nil {
+		log.LogErrorf("getBucketPolicyStatusHandler: xml marshal fail: requestID(%v) volume(%v) status(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), status, err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	if _, err = w.Write(data); err != nil {
+		log.LogErrorf("getBucketPolicyStatusHandler: write response body fail: requestID(%v) volume(%v) body(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(data), err)
+	}
+	return
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePublicAccessBlockConfig(t *testing.T) {
+	config, errCode := parsePublicAccessBlockConfig([]byte(`
+<PublicAccessBlockConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+	<BlockPublicAcls>true</BlockPublicAcls>
+	<IgnorePublicAcls>false</IgnorePublicAcls>
+	<RestrictPublicBuckets>true</RestrictPublicBuckets>
+</PublicAccessBlockConfiguration>`))
+	require.Nil(t, errCode)
+	require.True(t, config.BlockPublicAcls)
+	require.False(t, config.IgnorePublicAcls)
+	require.False(t, config.BlockPublicPolicy)
+	require.True(t, config.RestrictPublicBuckets)
+
+	_, errCode = parsePublicAccessBlockConfig([]byte(`<PublicAccessBlockConfiguration><BlockPublicAcls>yes</BlockPublicAcls></PublicAccessBlockConfiguration>`))
+	require.Equal(t, MalformedXML, errCode)
+
+	// the most restrictive combination of the bucket and the user level settings applies
+	merged := config.merge(&proto.PublicAccessBlock{IgnorePublicAcls: true})
+	require.True(t, merged.BlockPublicAcls)
+	require.True(t, merged.IgnorePublicAcls)
+	require.False(t, merged.BlockPublicPolicy)
+	require.True(t, merged.RestrictPublicBuckets)
+	merged = (*PublicAccessBlockConfiguration)(nil).merge(&proto.PublicAccessBlock{BlockPublicPolicy: true})
+	require.True(t, merged.BlockPublicPolicy)
+	require.False(t, merged.BlockPublicAcls)
+	merged = (*PublicAccessBlockConfiguration)(nil).merge(nil)
+	require.False(t, merged.BlockPublicAcls || merged.IgnorePublicAcls || merged.BlockPublicPolicy || merged.RestrictPublicBuckets)
+}
+
+func TestPolicyIsPublic(t *testing.T) {
+	for raw, expected := range map[string]bool{
+		`{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"bucket/*"}`:                     true,
+		`{"Effect":"Allow","Principal":{"AWS":"*"},"Action":"s3:GetObject","Resource":"bucket/*"}`:             true,
+		`{"Effect":"Allow","Principal":{"AWS":["1001","*"]},"Action":"s3:GetObject","Resource":"bucket/*"}`:    true,
+		`{"Effect":"Allow","Principal":{"AWS":["1001","1002"]},"Action":"s3:GetObject","Resource":"bucket/*"}`: false,
+		`{"Effect":"Deny","Principal":"*","Action":"s3:GetObject","Resource":"bucket/*"}`:                      false,
+		`{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"bucket/*",
+			"Condition":{"IpAddress":{"aws:SourceIp":"192.168.1.0/24"}}}`: false,
+		`{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"bucket/*",
+			"Condition":{"IpAddress":{"aws:SourceIp":["192.168.1.0/24","0.0.0.0/1"]}}}`: true,
+		`{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"bucket/*",
+			"Condition":{"NotIpAddress":{"aws:SourceIp":"192.168.1.0/24"}}}`: true,
+		`{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"bucket/*",
+			"Condition":{"StringLike":{"aws:Referer":"http://www.example.com/*"}}}`: true,
+	} {
+		policy, err := ParsePolicy([]byte(`{"Version":"2012-10-17","Statement":[` + raw + `]}`))
+		require.NoError(t, err, raw)
+		require.Equal(t, expected, policy.IsPublic(), raw)
+	}
+}
+
+func TestACLPublicGrants(t *testing.T) {
+	acl := &AccessControlPolicy{}
+	acl.SetPrivate("1001")
+	require.False(t, acl.IsPublic())
+
+	acl = &AccessControlPolicy{}
+	acl.SetAuthenticatedRead("1001")
+	require.True(t, acl.IsPublic())
+
+	acl = &AccessControlPolicy{}
+	acl.SetPublicReadWrite("1001")
+	acl.AddGrant("1002", TypeCanonicalUser, PermissionRead)
+	require.True(t, acl.IsPublic())
+	require.True(t, acl.IsAllowed(AnonymousUser, proto.OSSGetObjectAction))
+
+	// the public grants are ignored, while the others still take effect
+	ignored := acl.WithoutPublicGrants()
+	require.False(t, ignored.IsPublic())
+	require.Len(t, ignored.Acl.Grants, 2)
+	require.Len(t, acl.Acl.Grants, 4)
+	require.False(t, ignored.IsAllowed(AnonymousUser, proto.OSSGetObjectAction))
+	require.False(t, ignored.IsAllowed("1003", proto.OSSGetObjectAction))
+	require.True(t, ignored.IsAllowed("1002", proto.OSSGetObjectAction))
+	require.True(t, ignored.IsAllowed("1001", proto.OSSPutObjectAction))
+}
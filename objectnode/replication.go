@@ -0,0 +1,224 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/replication.html
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+const (
+	ReplicationRuleStatusEnabled  = "Enabled"
+	ReplicationRuleStatusDisabled = "Disabled"
+
+	// The replication status of an object version, reported by the x-amz-replication-status header.
+	ReplicationStatusPending   = "PENDING"
+	ReplicationStatusCompleted = "COMPLETED"
+	ReplicationStatusFailed    = "FAILED"
+	ReplicationStatusReplica   = "REPLICA"
+
+	MaxReplicationRules     = 1000
+	MaxReplicationRuleIDLen = 255
+
+	ReplicationBucketARNPrefix = "arn:aws:s3:::"
+)
+
+// ReplicationConfiguration is the bucket replication configuration.
+// Since there is no IAM role in ObjectNode, the Role names the replication target, which is one of
+// the remote clusters configured by the replicationTargets configuration item.
+type ReplicationConfiguration struct {
+	XMLName xml.Name           `xml:"ReplicationConfiguration" json:"-"`
+	Role    string             `xml:"Role" json:"role"`
+	Rules   []*ReplicationRule `xml:"Rule" json:"rules"`
+}
+
+type ReplicationRule struct {
+	ID                      string                   `xml:"ID,omitempty" json:"id,omitempty"`
+	Priority                int                      `xml:"Priority,omitempty" json:"priority,omitempty"`
+	Status                  string                   `xml:"Status" json:"status"`
+	Prefix                  string                   `xml:"Prefix,omitempty" json:"prefix,omitempty"` // Deprecated, replaced by Filter
+	Filter                  *LifecycleFilter         `xml:"Filter,omitempty" json:"filter,omitempty"`
+	Destination             *ReplicationDestination  `xml:"Destination" json:"destination"`
+	DeleteMarkerReplication *DeleteMarkerReplication `xml:"DeleteMarkerReplication,omitempty" json:"delete_marker_replication,omitempty"`
+}
+
+type ReplicationDestination struct {
+	Bucket       string `xml:"Bucket" json:"bucket"`
+	StorageClass string `xml:"StorageClass,omitempty" json:"storage_class,omitempty"`
+}
+
+type DeleteMarkerReplication struct {
+	Status string `xml:"Status" json:"status"`
+}
+
+func NewReplicationConfiguration() *ReplicationConfiguration {
+	return &ReplicationConfiguration{
+		XMLName: xml.Name{Local: "ReplicationConfiguration"},
+	}
+}
+
+func parseReplicationConfig(bytes []byte) (config *ReplicationConfiguration, errCode *ErrorCode) {
+	config = NewReplicationConfiguration()
+	if err := xml.Unmarshal(bytes, config); err != nil {
+		return nil, MalformedXML
+	}
+	if errCode = config.validate(); errCode != nil {
+		return nil, errCode
+	}
+	return config, nil
+}
+
+func (config *ReplicationConfiguration) validate() *ErrorCode {
+	if config.Role == "" || len(config.Rules) == 0 {
+		return MalformedXML
+	}
+	if len(config.Rules) > MaxReplicationRules {
+		return NewError("InvalidRequest", "The number of replication rules should not exceed allowed limit of 1000 rules.", 400)
+	}
+	var (
+		ids        = make(map[string]struct{})
+		priorities = make(map[int]struct{})
+	)
+	for _, rule := range config.Rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+		if _, has := priorities[rule.Priority]; has && rule.Filter != nil {
+			return NewError("InvalidRequest", "Found duplicate priority. Each rule must have a unique priority.", 400)
+		}
+		priorities[rule.Priority] = struct{}{}
+		if rule.ID == "" {
+			continue
+		}
+		if _, has := ids[rule.ID]; has {
+			return NewError("InvalidArgument", "Rule ID must be unique. Found same ID for more than one rule.", 400)
+		}
+		ids[rule.ID] = struct{}{}
+	}
+	return nil
+}
+
+func (rule *ReplicationRule) validate() *ErrorCode {
+	if len(rule.ID) > MaxReplicationRuleIDLen {
+		return NewError("InvalidArgument", "ID length should not exceed allowed limit of 255.", 400)
+	}
+	if rule.Status != ReplicationRuleStatusEnabled && rule.Status != ReplicationRuleStatusDisabled {
+		return MalformedXML
+	}
+	if rule.Prefix != "" && rule.Filter != nil {
+		return MalformedXML
+	}
+	if rule.Filter != nil {
+		if err := rule.Filter.validate(); err != nil {
+			return err
+		}
+	}
+	if rule.Destination == nil || !strings.HasPrefix(rule.Destination.Bucket, ReplicationBucketARNPrefix) ||
+		rule.Destination.bucket() == "" {
+		return NewError("InvalidArgument", "Invalid bucket ARN specified in the destination.", 400)
+	}
+	if rule.Destination.StorageClass != "" && rule.Destination.StorageClass != StorageClassStandard {
+		return InvalidStorageClass
+	}
+	if dmr := rule.DeleteMarkerReplication; dmr != nil {
+		if dmr.Status != ReplicationRuleStatusEnabled && dmr.Status != ReplicationRuleStatusDisabled {
+			return MalformedXML
+		}
+		if dmr.Status == ReplicationRuleStatusEnabled && len(rule.filterTags()) > 0 {
+			return NewError("InvalidRequest", "Delete marker replication is not supported if any Tag filter is specified.", 400)
+		}
+	}
+	return nil
+}
+
+func (rule *ReplicationRule) enabled() bool {
+	return rule.Status == ReplicationRuleStatusEnabled
+}
+
+func (rule *ReplicationRule) deleteMarkerReplicationEnabled() bool {
+	return rule.DeleteMarkerReplication != nil && rule.DeleteMarkerReplication.Status == ReplicationRuleStatusEnabled
+}
+
+func (rule *ReplicationRule) filterPrefix() string {
+	if rule.Filter == nil {
+		return rule.Prefix
+	}
+	return rule.Filter.prefix()
+}
+
+func (rule *ReplicationRule) filterTags() []Tag {
+	if rule.Filter == nil {
+		return nil
+	}
+	return rule.Filter.tags()
+}
+
+// bucket returns the name of the destination bucket specified by the bucket ARN.
+func (d *ReplicationDestination) bucket() string {
+	return strings.TrimPrefix(d.Bucket, ReplicationBucketARNPrefix)
+}
+
+// needTags checks whether the tags of the objects are required to select the replication rule.
+func (config *ReplicationConfiguration) needTags() bool {
+	for _, rule := range config.Rules {
+		if rule.enabled() && len(rule.filterTags()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRule returns the enabled rule which selects the object with the given key and tags.
+// If more than one rule selects the object, the one with the highest priority wins.
+func (config *ReplicationConfiguration) matchRule(key string, tags map[string]string) (matched *ReplicationRule) {
+	for _, rule := range config.Rules {
+		if !rule.enabled() || !matchFilter(rule.filterPrefix(), rule.filterTags(), key, tags) {
+			continue
+		}
+		if matched == nil || rule.Priority > matched.Priority {
+			matched = rule
+		}
+	}
+	return
+}
+
+func storeBucketReplication(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSReplication, bytes)
+}
+
+func deleteBucketReplication(vol *Volume) (err error) {
+	return vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSReplication)
+}
+
+// setReplicationStatus records the replication status of the object version stored in the inode.
+func (v *Volume) setReplicationStatus(inode uint64, status string) (err error) {
+	if err = v.mw.XAttrSet_ll(inode, []byte(XAttrKeyOSSReplicationStatus), []byte(status)); err != nil {
+		return
+	}
+	if objMetaCache != nil {
+		attrItem := &AttrItem{
+			XAttrInfo: proto.XAttrInfo{
+				Inode:  inode,
+				XAttrs: map[string]string{XAttrKeyOSSReplicationStatus: status},
+			},
+		}
+		objMetaCache.MergeAttr(v.name, attrItem)
+	}
+	return
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	MaxReplicationConfigSize = 2 << 20 // 2MB
+)
+
+// Get bucket replication
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketReplication.html
+func (o *ObjectNode) getBucketReplicationHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getBucketReplicationHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var replication *ReplicationConfiguration
+	if replication, err = vol.metaLoader.loadReplication(); err != nil {
+		log.LogErrorf("getBucketReplicationHandler: load replication fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if replication == nil || len(replication.Rules) == 0 {
+		errorCode = NoSuchReplicationConfiguration
+		return
+	}
+
+	var data []byte
+	if data, err = MarshalXMLEntity(replication); err != nil {
+		log.LogErrorf("getBucketReplicationHandler: xml marshal fail: requestID(%v) volume(%v) replication(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), replication, err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	if _, err = w.Write(data); err != nil {
+		log.LogErrorf("getBucketReplicationHandler: write response body fail: requestID(%v) volume(%v) body(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(data), err)
+	}
+	return
+}
+
+// Put bucket replication
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketReplication.html
+func (o *ObjectNode) putBucketReplicationHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("putBucketReplicationHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	md5 := r.Header.Get(HeaderNameContentMD5)
+	if md5 == "" {
+		errorCode = MissingContentMD5
+		return
+	}
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, MaxReplicationConfigSize+1)); err != nil {
+		log.LogErrorf("putBucketReplicationHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxReplicationConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	if md5 != GetMD5(body) {
+		errorCode = InvalidDigest
+		return
+	}
+
+	var replication *ReplicationConfiguration
+	if replication, errorCode = parseReplicationConfig(body); errorCode != nil {
+		log.LogErrorf("putBucketReplicationHandler: parse replication config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), errorCode)
+		return
+	}
+	if o.replicator == nil || !o.replicator.hasTarget(replication.Role) {
+		errorCode = InvalidReplicationRole
+		return
+	}
+	var versioning *VersioningConfiguration
+	if versioning, err = vol.metaLoader.loadVersioning(); err != nil {
+		log.LogErrorf("putBucketReplicationHandler: load versioning fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if versioning == nil || versioning.Status != VersioningStatusEnabled {
+		errorCode = ReplicationVersioningNotEnabled
+		return
+	}
+	if err = storeBucketReplication(body, vol); err != nil {
+		log.LogErrorf("putBucketReplicationHandler: store replication config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	vol.metaLoader.storeReplication(replication)
+
+	return
+}
+
+// Delete bucket replication
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketReplication.html
+func (o *ObjectNode) deleteBucketReplicationHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("deleteBucketReplicationHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+	if err = deleteBucketReplication(vol); err != nil {
+		log.LogErrorf("deleteBucketReplicationHandler: delete replication config fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	vol.metaLoader.storeReplication(nil)
+	w.WriteHeader(http.StatusNoContent)
+
+	return
+}
@@ -0,0 +1,249 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	ReplicationOpPut    = "put"
+	ReplicationOpDelete = "delete"
+
+	replicationTaskFileSuffix = ".task"
+	replicationTempFileSuffix = ".tmp"
+)
+
+// ReplicationTask is a pending replication of an object version, or of a delete marker, to the destination bucket.
+type ReplicationTask struct {
+	ID           uint64 `json:"id"`
+	Volume       string `json:"volume"`
+	Key          string `json:"key"`
+	VersionId    string `json:"version_id,omitempty"`
+	Op           string `json:"op"`
+	Target       string `json:"target"`
+	Bucket       string `json:"bucket"`
+	StorageClass string `json:"storage_class,omitempty"`
+	Retries      int    `json:"retries,omitempty"`
+	NextTime     int64  `json:"next_time,omitempty"` // unix time in seconds before which the task is not retried
+}
+
+type replicationTaskHeap []*ReplicationTask
+
+func (h replicationTaskHeap) Len() int { return len(h) }
+
+func (h replicationTaskHeap) Less(i, j int) bool {
+	if h[i].NextTime != h[j].NextTime {
+		return h[i].NextTime < h[j].NextTime
+	}
+	return h[i].ID < h[j].ID
+}
+
+func (h replicationTaskHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *replicationTaskHeap) Push(x interface{}) { *h = append(*h, x.(*ReplicationTask)) }
+
+func (h *replicationTaskHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	task := old[n-1]
+	*h = old[:n-1]
+	return task
+}
+
+// ReplicationQueue is a durable queue of the replication tasks. Each task is persisted as a file in
+// the queue directory until it is done, so the tasks survive the restart of ObjectNode and are
+// delivered at least once.
+type ReplicationQueue struct {
+	dir     string
+	mu      sync.Mutex
+	seq     uint64
+	ready   []*ReplicationTask
+	delayed replicationTaskHeap
+	notify  chan struct{}
+}
+
+// OpenReplicationQueue opens the queue in the directory and loads the persisted tasks.
+func OpenReplicationQueue(dir string) (q *ReplicationQueue, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	q = &ReplicationQueue{
+		dir:    dir,
+		notify: make(chan struct{}, 1),
+	}
+	if err = q.load(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (q *ReplicationQueue) load() (err error) {
+	var infos []os.FileInfo
+	if infos, err = ioutil.ReadDir(q.dir); err != nil {
+		return
+	}
+	var now = time.Now().Unix()
+	for _, info := range infos {
+		var name = info.Name()
+		if strings.HasSuffix(name, replicationTempFileSuffix) {
+			// a task which failed to be persisted
+			_ = os.Remove(path.Join(q.dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, replicationTaskFileSuffix) {
+			continue
+		}
+		var data []byte
+		if data, err = ioutil.ReadFile(path.Join(q.dir, name)); err != nil {
+			return
+		}
+		var task = &ReplicationTask{}
+		if err = json.Unmarshal(data, task); err != nil {
+			log.LogWarnf("ReplicationQueue: skip broken task: dir(%v) file(%v) err(%v)", q.dir, name, err)
+			err = nil
+			continue
+		}
+		if task.ID > q.seq {
+			q.seq = task.ID
+		}
+		if task.NextTime > now {
+			heap.Push(&q.delayed, task)
+		} else {
+			q.ready = append(q.ready, task)
+		}
+	}
+	sort.Slice(q.ready, func(i, j int) bool {
+		return q.ready[i].ID < q.ready[j].ID
+	})
+	log.LogInfof("ReplicationQueue: load tasks: dir(%v) ready(%v) delayed(%v)", q.dir, len(q.ready), len(q.delayed))
+	return nil
+}
+
+func (q *ReplicationQueue) taskFile(id uint64) string {
+	return path.Join(q.dir, fmt.Sprintf("%016x", id)+replicationTaskFileSuffix)
+}
+
+// persist writes the task to its file atomically.
+func (q *ReplicationQueue) persist(task *ReplicationTask) (err error) {
+	var data []byte
+	if data, err = json.Marshal(task); err != nil {
+		return
+	}
+	var tmp = path.Join(q.dir, strconv.FormatUint(task.ID, 16)+replicationTempFileSuffix)
+	return writeFileAtomic(tmp, q.taskFile(task.ID), data)
+}
+
+func (q *ReplicationQueue) wakeup() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Push persists the new task and makes it ready.
+func (q *ReplicationQueue) Push(task *ReplicationTask) (err error) {
+	q.mu.Lock()
+	q.seq++
+	task.ID = q.seq
+	q.mu.Unlock()
+	if err = q.persist(task); err != nil {
+		return
+	}
+	q.mu.Lock()
+	q.ready = append(q.ready, task)
+	q.mu.Unlock()
+	q.wakeup()
+	return
+}
+
+// Retry delays the task until the next time and persists its retry count. The task is delayed
+// even if the persistence fails, in which case it is retried from the previous state after restart.
+func (q *ReplicationQueue) Retry(task *ReplicationTask, delay time.Duration) (err error) {
+	task.Retries++
+	task.NextTime = time.Now().Add(delay).Unix()
+	err = q.persist(task)
+	q.mu.Lock()
+	heap.Push(&q.delayed, task)
+	q.mu.Unlock()
+	q.wakeup()
+	return
+}
+
+// Done removes the finished task from the queue.
+func (q *ReplicationQueue) Done(task *ReplicationTask) (err error) {
+	if err = os.Remove(q.taskFile(task.ID)); os.IsNotExist(err) {
+		err = nil
+	}
+	return
+}
+
+// Pop returns the next task which is ready, blocking until there is one or the stop channel is closed.
+// The returned task stays in the queue directory until Done or Retry is called.
+func (q *ReplicationQueue) Pop(stopCh <-chan struct{}) *ReplicationTask {
+	for {
+		var wait = time.Minute
+		q.mu.Lock()
+		var now = time.Now().Unix()
+		for len(q.delayed) > 0 && q.delayed[0].NextTime <= now {
+			q.ready = append(q.ready, heap.Pop(&q.delayed).(*ReplicationTask))
+		}
+		if len(q.ready) > 0 {
+			var task = q.ready[0]
+			q.ready[0] = nil
+			q.ready = q.ready[1:]
+			var more = len(q.ready) > 0
+			q.mu.Unlock()
+			if more {
+				// let the other workers know
+				q.wakeup()
+			}
+			return task
+		}
+		if len(q.delayed) > 0 {
+			wait = time.Duration(q.delayed[0].NextTime-now) * time.Second
+		}
+		q.mu.Unlock()
+
+		var timer = time.NewTimer(wait)
+		select {
+		case <-q.notify:
+		case <-timer.C:
+		case <-stopCh:
+			timer.Stop()
+			return nil
+		}
+		timer.Stop()
+	}
+}
+
+// Len returns the number of the tasks in the queue which are not being processed.
+func (q *ReplicationQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.ready) + len(q.delayed)
+}
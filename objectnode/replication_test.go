@@ -0,0 +1,318 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReplicationConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		valid bool
+	}{
+		{
+			name: "prefix filter",
+			input: `<ReplicationConfiguration><Role>backup</Role><Rule><ID>r1</ID><Priority>1</Priority>
+<Status>Enabled</Status><Filter><Prefix>logs/</Prefix></Filter><Destination><Bucket>arn:aws:s3:::dest</Bucket>
+</Destination><DeleteMarkerReplication><Status>Enabled</Status></DeleteMarkerReplication></Rule>
+</ReplicationConfiguration>`,
+			valid: true,
+		},
+		{
+			name: "legacy prefix",
+			input: `<ReplicationConfiguration><Role>backup</Role><Rule><Status>Enabled</Status><Prefix>a/</Prefix>
+<Destination><Bucket>arn:aws:s3:::dest</Bucket><StorageClass>STANDARD</StorageClass></Destination></Rule>
+</ReplicationConfiguration>`,
+			valid: true,
+		},
+		{
+			name: "no role",
+			input: `<ReplicationConfiguration><Rule><Status>Enabled</Status>
+<Destination><Bucket>arn:aws:s3:::dest</Bucket></Destination></Rule></ReplicationConfiguration>`,
+		},
+		{
+			name: "invalid destination",
+			input: `<ReplicationConfiguration><Role>backup</Role><Rule><Status>Enabled</Status>
+<Destination><Bucket>dest</Bucket></Destination></Rule></ReplicationConfiguration>`,
+		},
+		{
+			name: "duplicate priority",
+			input: `<ReplicationConfiguration><Role>backup</Role>
+<Rule><Priority>1</Priority><Status>Enabled</Status><Filter><Prefix>a/</Prefix></Filter>
+<Destination><Bucket>arn:aws:s3:::dest</Bucket></Destination></Rule>
+<Rule><Priority>1</Priority><Status>Enabled</Status><Filter><Prefix>b/</Prefix></Filter>
+<Destination><Bucket>arn:aws:s3:::dest</Bucket></Destination></Rule></ReplicationConfiguration>`,
+		},
+		{
+			name: "delete marker replication with tag filter",
+			input: `<ReplicationConfiguration><Role>backup</Role><Rule><Status>Enabled</Status>
+<Filter><Tag><Key>k</Key><Value>v</Value></Tag></Filter><Destination><Bucket>arn:aws:s3:::dest</Bucket></Destination>
+<DeleteMarkerReplication><Status>Enabled</Status></DeleteMarkerReplication></Rule></ReplicationConfiguration>`,
+		},
+		{
+			name: "invalid storage class",
+			input: `<ReplicationConfiguration><Role>backup</Role><Rule><Status>Enabled</Status>
+<Destination><Bucket>arn:aws:s3:::dest</Bucket><StorageClass>GLACIER</StorageClass></Destination></Rule>
+</ReplicationConfiguration>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, errCode := parseReplicationConfig([]byte(tt.input))
+			if tt.valid {
+				require.Nil(t, errCode)
+				require.NotNil(t, config)
+			} else {
+				require.NotNil(t, errCode)
+			}
+		})
+	}
+}
+
+func TestReplicationMatchRule(t *testing.T) {
+	config, errCode := parseReplicationConfig([]byte(`<ReplicationConfiguration><Role>backup</Role>
+<Rule><ID>all</ID><Priority>1</Priority><Status>Enabled</Status><Filter></Filter>
+<Destination><Bucket>arn:aws:s3:::all</Bucket></Destination></Rule>
+<Rule><ID>logs</ID><Priority>3</Priority><Status>Enabled</Status><Filter><Prefix>logs/</Prefix></Filter>
+<Destination><Bucket>arn:aws:s3:::logs</Bucket></Destination></Rule>
+<Rule><ID>tagged</ID><Priority>2</Priority><Status>Enabled</Status><Filter><And><Prefix>logs/</Prefix>
+<Tag><Key>k</Key><Value>v</Value></Tag></And></Filter><Destination><Bucket>arn:aws:s3:::tagged</Bucket></Destination></Rule>
+<Rule><ID>disabled</ID><Priority>4</Priority><Status>Disabled</Status><Filter><Prefix>logs/</Prefix></Filter>
+<Destination><Bucket>arn:aws:s3:::disabled</Bucket></Destination></Rule>
+</ReplicationConfiguration>`))
+	require.Nil(t, errCode)
+	require.True(t, config.needTags())
+
+	rule := config.matchRule("data/a", nil)
+	require.Equal(t, "all", rule.ID)
+	require.Equal(t, "all", rule.Destination.bucket())
+	rule = config.matchRule("logs/a", map[string]string{"k": "v"})
+	require.Equal(t, "logs", rule.ID)
+
+	config.Rules[1].Status = ReplicationRuleStatusDisabled
+	rule = config.matchRule("logs/a", map[string]string{"k": "v"})
+	require.Equal(t, "tagged", rule.ID)
+	rule = config.matchRule("logs/a", nil)
+	require.Equal(t, "all", rule.ID)
+}
+
+func TestParseReplicationTargets(t *testing.T) {
+	targets, err := parseReplicationTargets([]interface{}{
+		map[string]interface{}{"name": "backup", "endpoint": "http://127.0.0.1:80", "accessKey": "ak", "secretKey": "sk"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(targets))
+	require.Equal(t, "ak", targets[0].AccessKey)
+
+	_, err = parseReplicationTargets([]interface{}{map[string]interface{}{"name": "backup"}})
+	require.Error(t, err)
+	_, err = parseReplicationTargets([]interface{}{
+		map[string]interface{}{"name": "backup", "endpoint": "http://a"},
+		map[string]interface{}{"name": "backup", "endpoint": "http://b"},
+	})
+	require.Error(t, err)
+}
+
+func TestReplicationQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replication-queue")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	q, err := OpenReplicationQueue(dir)
+	require.NoError(t, err)
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, q.Push(&ReplicationTask{Volume: "vol", Key: key, Op: ReplicationOpPut}))
+	}
+	stopCh := make(chan struct{})
+	task := q.Pop(stopCh)
+	require.Equal(t, "a", task.Key)
+	require.NoError(t, q.Done(task))
+	task = q.Pop(stopCh)
+	require.Equal(t, "b", task.Key)
+	require.NoError(t, q.Retry(task, time.Hour))
+
+	// the tasks which are not done survive the reopening
+	q, err = OpenReplicationQueue(dir)
+	require.NoError(t, err)
+	require.Equal(t, 2, q.Len())
+	task = q.Pop(stopCh)
+	require.Equal(t, "c", task.Key)
+	require.Equal(t, uint64(3), task.ID)
+	require.NoError(t, q.Push(&ReplicationTask{Volume: "vol", Key: "d", Op: ReplicationOpPut}))
+	task = q.Pop(stopCh)
+	require.Equal(t, "d", task.Key)
+	require.Equal(t, uint64(4), task.ID)
+
+	// the delayed task is not popped before the next time
+	close(stopCh)
+	require.Nil(t, q.Pop(stopCh))
+	require.Equal(t, 1, q.Len())
+	require.Equal(t, 1, q.delayed[0].Retries)
+}
+
+const (
+	replicaTestAccessKey = "AKIAREPLICAEXAMPLE"
+	replicaTestSecretKey = "replicaSecretKeyEXAMPLE"
+	userTestAccessKey    = "AKIAUSEREXAMPLE"
+	userTestSecretKey    = "userSecretKeyEXAMPLE"
+)
+
+type replicaTestRequest struct {
+	method  string
+	path    string
+	header  http.Header
+	body    string
+	replica bool
+}
+
+// newReplicaTestNode starts the object node of the destination cluster, which authenticates the requests
+// and records them before they reach the handlers of the volumes, answering with the status.
+func newReplicaTestNode(status int) (server *httptest.Server, requests func() []*replicaTestRequest) {
+	var (
+		mu       sync.Mutex
+		received []*replicaTestRequest
+	)
+	o := &ObjectNode{
+		userStore: postTestUserStore{
+			replicaTestAccessKey: {AccessKey: replicaTestAccessKey, SecretKey: replicaTestSecretKey},
+			userTestAccessKey:    {AccessKey: userTestAccessKey, SecretKey: userTestSecretKey},
+		},
+		replicaAccessKeys: map[string]struct{}{replicaTestAccessKey: {}},
+	}
+	router := mux.NewRouter().SkipClean(true)
+	o.registerApiRouters(router)
+	router.Use(o.authMiddleware, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			mu.Lock()
+			received = append(received, &replicaTestRequest{method: r.Method, path: r.URL.Path, header: r.Header,
+				body: string(body), replica: o.isReplicaRequest(r)})
+			mu.Unlock()
+			w.WriteHeader(status)
+		})
+	})
+	server = httptest.NewServer(router)
+	requests = func() []*replicaTestRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*replicaTestRequest(nil), received...)
+	}
+	return
+}
+
+func TestReplicationTarget(t *testing.T) {
+	server, requests := newReplicaTestNode(http.StatusOK)
+	defer server.Close()
+
+	target := &ReplicationTarget{Name: "backup", Endpoint: server.URL, AccessKey: replicaTestAccessKey, SecretKey: replicaTestSecretKey}
+	require.NoError(t, target.init())
+	info := &FSFileInfo{
+		Size:     5,
+		MIMEType: "text/plain",
+		Metadata: map[string]string{"owner": "alice"},
+	}
+	require.NoError(t, target.putObject("dest", "dir/key", "", strings.NewReader("hello"), info, "k=v"))
+	require.NoError(t, target.deleteObject("dest", "dir/key"))
+
+	received := requests()
+	require.Equal(t, 2, len(received))
+	put := received[0]
+	require.Equal(t, http.MethodPut, put.method)
+	require.Equal(t, "/dest/dir/key", put.path)
+	require.Equal(t, "hello", put.body)
+	require.True(t, put.replica)
+	require.Equal(t, "text/plain", put.header.Get(HeaderNameContentType))
+	require.Equal(t, "alice", put.header.Get(HeaderNameXAmzMetaPrefix+"owner"))
+	require.Equal(t, "k=v", put.header.Get(HeaderNameXAmzTagging))
+	require.Equal(t, UnsignedPayload, put.header.Get(HeaderNameXAmzContentHash))
+	require.Equal(t, http.MethodDelete, received[1].method)
+	require.True(t, received[1].replica)
+
+	// the replication status is not trusted from the other users
+	user := &ReplicationTarget{Name: "user", Endpoint: server.URL, AccessKey: userTestAccessKey, SecretKey: userTestSecretKey}
+	require.NoError(t, user.init())
+	require.NoError(t, user.putObject("dest", "dir/key", "", strings.NewReader("hello"), info, ""))
+	received = requests()
+	require.Equal(t, 3, len(received))
+	require.Equal(t, ReplicationStatusReplica, received[2].header.Get(HeaderNameXAmzReplicationStatus))
+	require.False(t, received[2].replica)
+
+	// the request not signed by the replication access key is rejected
+	forged := &ReplicationTarget{Name: "forged", Endpoint: server.URL, AccessKey: replicaTestAccessKey, SecretKey: userTestSecretKey}
+	require.NoError(t, forged.init())
+	require.Error(t, forged.deleteObject("dest", "dir/key"))
+	require.Equal(t, 3, len(requests()))
+}
+
+func TestPostFormReplicationStatus(t *testing.T) {
+	form := &postForm{fields: map[string][]string{
+		"X-Amz-Replication-Status": {ReplicationStatusReplica},
+		"X-Amz-Meta-Owner":         {"alice"},
+	}}
+	header := http.Header{}
+	header.Set(HeaderNameXAmzReplicationStatus, ReplicationStatusReplica)
+	form.setHeaders(header)
+	require.Empty(t, header.Get(HeaderNameXAmzReplicationStatus))
+	require.Equal(t, "alice", header.Get("X-Amz-Meta-Owner"))
+}
+
+func TestReplicatorProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replicator")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	okServer, okRequests := newReplicaTestNode(http.StatusNoContent)
+	defer okServer.Close()
+	failServer, _ := newReplicaTestNode(http.StatusInternalServerError)
+	defer failServer.Close()
+
+	q, err := OpenReplicationQueue(dir)
+	require.NoError(t, err)
+	r, err := NewReplicator(nil, []*ReplicationTarget{
+		{Name: "ok", Endpoint: okServer.URL, AccessKey: replicaTestAccessKey, SecretKey: replicaTestSecretKey},
+		{Name: "fail", Endpoint: failServer.URL, AccessKey: replicaTestAccessKey, SecretKey: replicaTestSecretKey},
+	}, q, 1, defaultReplicationMaxRetries)
+	require.NoError(t, err)
+	r.Start()
+	defer r.Stop()
+
+	require.NoError(t, q.Push(&ReplicationTask{Volume: "vol", Key: "a", Op: ReplicationOpDelete, Target: "ok", Bucket: "dest"}))
+	require.NoError(t, q.Push(&ReplicationTask{Volume: "vol", Key: "b", Op: ReplicationOpDelete, Target: "unknown", Bucket: "dest"}))
+	require.NoError(t, q.Push(&ReplicationTask{Volume: "vol", Key: "c", Op: ReplicationOpDelete, Target: "fail", Bucket: "dest"}))
+
+	// The replicated task and the task which can never succeed are removed from the queue,
+	// while the failed task is retried later.
+	require.Eventually(t, func() bool {
+		files, _ := ioutil.ReadDir(dir)
+		return len(files) == 1 && q.Len() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, len(okRequests()))
+	require.Equal(t, "/dest/a", okRequests()[0].path)
+	q.mu.Lock()
+	require.Equal(t, "c", q.delayed[0].Key)
+	q.mu.Unlock()
+}
@@ -0,0 +1,397 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	defaultReplicationWorkers    = 4
+	defaultReplicationMaxRetries = 16
+
+	replicationMinRetryDelay = time.Second
+	replicationMaxRetryDelay = time.Hour
+)
+
+// ReplicationTarget is the S3 endpoint of a remote cluster which the objects are replicated to.
+type ReplicationTarget struct {
+	Name      string `json:"name"`
+	Endpoint  string `json:"endpoint"`
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+	Region    string `json:"region"`
+
+	client *s3.S3
+}
+
+// parseReplicationTargets parses the replication targets from the raw configuration item.
+func parseReplicationTargets(raw []interface{}) (targets []*ReplicationTarget, err error) {
+	var data []byte
+	if data, err = json.Marshal(raw); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &targets); err != nil {
+		return
+	}
+	var names = make(map[string]struct{}, len(targets))
+	for _, target := range targets {
+		if target.Name == "" || target.Endpoint == "" {
+			return nil, fmt.Errorf("replication target requires name and endpoint")
+		}
+		if _, has := names[target.Name]; has {
+			return nil, fmt.Errorf("duplicate replication target: %v", target.Name)
+		}
+		names[target.Name] = struct{}{}
+	}
+	return
+}
+
+func (t *ReplicationTarget) init() (err error) {
+	var region = t.Region
+	if region == "" {
+		region = "default"
+	}
+	var sess *session.Session
+	if sess, err = session.NewSession(); err != nil {
+		return
+	}
+	var ac = aws.NewConfig()
+	ac.Endpoint = aws.String(t.Endpoint)
+	ac.Region = aws.String(region)
+	ac.Credentials = credentials.NewStaticCredentials(t.AccessKey, t.SecretKey, "")
+	ac.S3ForcePathStyle = aws.Bool(true)
+	// Failed replications are retried by the replication queue.
+	ac.MaxRetries = aws.Int(0)
+	t.client = s3.New(sess, ac)
+	return
+}
+
+// putObject uploads the object version read from the reader to the destination bucket as a replica.
+func (t *ReplicationTarget) putObject(bucket, key, storageClass string, body io.Reader, info *FSFileInfo, tagging string) (err error) {
+	var input = &s3.PutObjectInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(key),
+		Body:          aws.ReadSeekCloser(body),
+		ContentLength: aws.Int64(info.Size),
+	}
+	if len(info.Metadata) > 0 {
+		input.Metadata = aws.StringMap(info.Metadata)
+	}
+	if info.MIMEType != "" {
+		input.ContentType = aws.String(info.MIMEType)
+	}
+	if info.Disposition != "" {
+		input.ContentDisposition = aws.String(info.Disposition)
+	}
+	if info.CacheControl != "" {
+		input.CacheControl = aws.String(info.CacheControl)
+	}
+	if expires, parseErr := time.Parse(RFC1123Format, info.Expires); parseErr == nil {
+		input.Expires = aws.Time(expires)
+	}
+	if tagging != "" {
+		input.Tagging = aws.String(tagging)
+	}
+	if storageClass != "" {
+		input.StorageClass = aws.String(storageClass)
+	}
+	req, _ := t.client.PutObjectRequest(input)
+	// The body is streamed and can not be rewound to compute the payload hash.
+	req.HTTPRequest.Header.Set(HeaderNameXAmzContentHash, UnsignedPayload)
+	req.HTTPRequest.Header.Set(HeaderNameXAmzReplicationStatus, ReplicationStatusReplica)
+	return req.Send()
+}
+
+// deleteObject inserts a delete marker for the object in the destination bucket.
+func (t *ReplicationTarget) deleteObject(bucket, key string) (err error) {
+	req, _ := t.client.DeleteObjectRequest(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	req.HTTPRequest.Header.Set(HeaderNameXAmzReplicationStatus, ReplicationStatusReplica)
+	return req.Send()
+}
+
+// replicationAbortError is returned if the replication can never succeed, so it should not be retried.
+type replicationAbortError struct {
+	reason string
+}
+
+func (e *replicationAbortError) Error() string {
+	return e.reason
+}
+
+// Replicator replicates the object versions and delete markers in the replication queue to the
+// destination buckets of the replication targets, retrying the failed replications with backoff.
+type Replicator struct {
+	vm         *VolumeManager
+	targets    map[string]*ReplicationTarget
+	queue      *ReplicationQueue
+	workers    int
+	maxRetries int
+	stopOnce   sync.Once
+	stopCh     chan struct{}
+	wg         sync.WaitGroup
+}
+
+func NewReplicator(vm *VolumeManager, targets []*ReplicationTarget, queue *ReplicationQueue, workers, maxRetries int) (*Replicator, error) {
+	var r = &Replicator{
+		vm:         vm,
+		targets:    make(map[string]*ReplicationTarget, len(targets)),
+		queue:      queue,
+		workers:    workers,
+		maxRetries: maxRetries,
+		stopCh:     make(chan struct{}),
+	}
+	for _, target := range targets {
+		if err := target.init(); err != nil {
+			return nil, err
+		}
+		r.targets[target.Name] = target
+	}
+	return r, nil
+}
+
+func (r *Replicator) Start() {
+	for i := 0; i < r.workers; i++ {
+		r.wg.Add(1)
+		go r.run()
+	}
+}
+
+func (r *Replicator) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopCh)
+	})
+	r.wg.Wait()
+}
+
+func (r *Replicator) hasTarget(name string) bool {
+	_, has := r.targets[name]
+	return has
+}
+
+func (r *Replicator) run() {
+	defer r.wg.Done()
+	for {
+		var task = r.queue.Pop(r.stopCh)
+		if task == nil {
+			return
+		}
+		r.process(task)
+	}
+}
+
+func (r *Replicator) process(task *ReplicationTask) {
+	var err = r.replicate(task)
+	var abortErr *replicationAbortError
+	if err != nil && !errors.As(err, &abortErr) && task.Retries < r.maxRetries {
+		var delay = replicationMinRetryDelay << uint(task.Retries)
+		if delay > replicationMaxRetryDelay || delay <= 0 {
+			delay = replicationMaxRetryDelay
+		}
+		log.LogWarnf("Replicator: replicate fail and retry: volume(%v) key(%v) versionId(%v) op(%v) retries(%v) delay(%v) err(%v)",
+			task.Volume, task.Key, task.VersionId, task.Op, task.Retries, delay, err)
+		if err = r.queue.Retry(task, delay); err != nil {
+			log.LogErrorf("Replicator: persist retry fail: volume(%v) key(%v) versionId(%v) err(%v)",
+				task.Volume, task.Key, task.VersionId, err)
+		}
+		return
+	}
+	var status = ReplicationStatusCompleted
+	if err != nil {
+		status = ReplicationStatusFailed
+		log.LogErrorf("Replicator: replicate fail: volume(%v) key(%v) versionId(%v) op(%v) retries(%v) err(%v)",
+			task.Volume, task.Key, task.VersionId, task.Op, task.Retries, err)
+	} else {
+		log.LogInfof("Replicator: replicate finished: volume(%v) key(%v) versionId(%v) op(%v) target(%v) bucket(%v)",
+			task.Volume, task.Key, task.VersionId, task.Op, task.Target, task.Bucket)
+	}
+	if task.Op == ReplicationOpPut {
+		r.updateStatus(task, status)
+	}
+	if err = r.queue.Done(task); err != nil {
+		log.LogErrorf("Replicator: remove task fail: volume(%v) key(%v) versionId(%v) err(%v)",
+			task.Volume, task.Key, task.VersionId, err)
+	}
+}
+
+func (r *Replicator) replicate(task *ReplicationTask) (err error) {
+	var target = r.targets[task.Target]
+	if target == nil {
+		return &replicationAbortError{reason: fmt.Sprintf("replication target %v not found", task.Target)}
+	}
+	if task.Op == ReplicationOpDelete {
+		return target.deleteObject(task.Bucket, task.Key)
+	}
+
+	var vol *Volume
+	if vol, err = r.vm.Volume(task.Volume); err != nil {
+		return
+	}
+	var (
+		info  *FSFileInfo
+		xattr *proto.XAttrInfo
+	)
+	if info, xattr, err = vol.ObjectVersionMeta(task.Key, task.VersionId); err == syscall.ENOENT {
+		// The version has been deleted, nothing to replicate.
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	if info.DeleteMarker {
+		return nil
+	}
+	var dataKey []byte
+	if info.Encryption != nil {
+		if info.Encryption.Type == SSETypeC {
+			return &replicationAbortError{reason: "objects encrypted with customer provided keys can not be replicated"}
+		}
+		if dataKey, err = info.Encryption.openDataKey(nil); err != nil {
+			return
+		}
+	}
+
+	var reader, writer = io.Pipe()
+	defer reader.Close()
+	go func() {
+		writer.CloseWithError(vol.readObject(info, dataKey, task.Key, writer, 0, uint64(info.Size)))
+	}()
+	return target.putObject(task.Bucket, task.Key, task.StorageClass, reader, info, string(xattr.Get(XAttrKeyOSSTagging)))
+}
+
+func (r *Replicator) updateStatus(task *ReplicationTask, status string) {
+	var (
+		err  error
+		vol  *Volume
+		info *FSFileInfo
+	)
+	if vol, err = r.vm.Volume(task.Volume); err != nil {
+		return
+	}
+	if info, _, err = vol.ObjectVersionMeta(task.Key, task.VersionId); err != nil || info.DeleteMarker {
+		return
+	}
+	if err = vol.setReplicationStatus(info.Inode, status); err != nil && err != syscall.ENOENT {
+		log.LogErrorf("Replicator: set replication status fail: volume(%v) key(%v) versionId(%v) status(%v) err(%v)",
+			task.Volume, task.Key, task.VersionId, status, err)
+	}
+}
+
+// enqueue queues the replication of the object version, or of the delete marker, selected by the rule.
+func (r *Replicator) enqueue(vol *Volume, key, versionId, op string, rule *ReplicationRule, target string) error {
+	return r.queue.Push(&ReplicationTask{
+		Volume:       vol.Name(),
+		Key:          key,
+		VersionId:    versionId,
+		Op:           op,
+		Target:       target,
+		Bucket:       rule.Destination.bucket(),
+		StorageClass: rule.Destination.StorageClass,
+	})
+}
+
+// isReplicaRequest returns true if the request is written by the replication of another cluster, which is
+// trusted only if the request is signed with one of the replication access keys configured.
+func (o *ObjectNode) isReplicaRequest(r *http.Request) bool {
+	if r.Header.Get(HeaderNameXAmzReplicationStatus) != ReplicationStatusReplica {
+		return false
+	}
+	var param = ParseRequestParam(r)
+	if _, trusted := o.replicaAccessKeys[param.AccessKey()]; !trusted {
+		return false
+	}
+	// the signature of the ignored actions is not verified
+	return param.Action().IsNone() || !o.signatureIgnoredActions.Contains(param.Action())
+}
+
+// replicateObject records the replication status of the newly written object version and queues its
+// replication if the object is selected by the replication configuration of the bucket.
+// The objects written by the replications of the other clusters are marked as replicas and are not
+// replicated again.
+func (o *ObjectNode) replicateObject(r *http.Request, vol *Volume, key string, info *FSFileInfo) {
+	if o.isReplicaRequest(r) {
+		if err := vol.setReplicationStatus(info.Inode, ReplicationStatusReplica); err != nil {
+			log.LogErrorf("replicateObject: set replica status fail: requestID(%v) volume(%v) key(%v) err(%v)",
+				GetRequestID(r), vol.Name(), key, err)
+		}
+		return
+	}
+	if o.replicator == nil {
+		return
+	}
+	config, err := vol.metaLoader.loadReplication()
+	if err != nil || config == nil {
+		return
+	}
+	var tags map[string]string
+	if config.needTags() {
+		var tagging map[uint64]map[string]string
+		if tagging, err = loadObjectsTagging(vol, []*FSFileInfo{info}); err != nil {
+			log.LogErrorf("replicateObject: load object tagging fail: requestID(%v) volume(%v) key(%v) err(%v)",
+				GetRequestID(r), vol.Name(), key, err)
+		}
+		tags = tagging[info.Inode]
+	}
+	var rule = config.matchRule(key, tags)
+	if rule == nil {
+		return
+	}
+	if err = vol.setReplicationStatus(info.Inode, ReplicationStatusPending); err != nil {
+		log.LogErrorf("replicateObject: set pending status fail: requestID(%v) volume(%v) key(%v) err(%v)",
+			GetRequestID(r), vol.Name(), key, err)
+	}
+	if err = o.replicator.enqueue(vol, key, info.VersionId, ReplicationOpPut, rule, config.Role); err != nil {
+		log.LogErrorf("replicateObject: enqueue replication fail: requestID(%v) volume(%v) key(%v) versionId(%v) err(%v)",
+			GetRequestID(r), vol.Name(), key, info.VersionId, err)
+		_ = vol.setReplicationStatus(info.Inode, ReplicationStatusFailed)
+	}
+}
+
+// replicateDeleteMarker queues the replication of the newly inserted delete marker if the rule
+// selecting the object enables delete marker replication.
+func (o *ObjectNode) replicateDeleteMarker(r *http.Request, vol *Volume, key, versionId string) {
+	if o.replicator == nil || o.isReplicaRequest(r) {
+		return
+	}
+	config, err := vol.metaLoader.loadReplication()
+	if err != nil || config == nil {
+		return
+	}
+	var rule = config.matchRule(key, nil)
+	if rule == nil || !rule.deleteMarkerReplicationEnabled() {
+		return
+	}
+	if err = o.replicator.enqueue(vol, key, versionId, ReplicationOpDelete, rule, config.Role); err != nil {
+		log.LogErrorf("replicateDeleteMarker: enqueue replication fail: requestID(%v) volume(%v) key(%v) versionId(%v) err(%v)",
+			GetRequestID(r), vol.Name(), key, versionId, err)
+	}
+}
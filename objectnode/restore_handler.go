@@ -0,0 +1,108 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"syscall"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// Restore object
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_RestoreObject.html
+func (o *ObjectNode) restoreObjectHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	if param.Object() == "" {
+		errorCode = InvalidKey
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("restoreObjectHandler: load volume fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), param.Object(), err)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, MaxRestoreRequestSize+1)); err != nil {
+		log.LogErrorf("restoreObjectHandler: read request body fail: requestID(%v) volume(%v) path(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), err)
+		return
+	}
+	if len(body) > MaxRestoreRequestSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	var request *RestoreRequest
+	if request, errorCode = parseRestoreRequest(body); errorCode != nil {
+		log.LogErrorf("restoreObjectHandler: parse restore request fail: requestID(%v) volume(%v) path(%v) body(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), string(body), errorCode)
+		return
+	}
+
+	var fileInfo *FSFileInfo
+	var versionId = r.URL.Query().Get(ParamVersionId)
+	fileInfo, _, err = vol.ObjectVersionMeta(param.Object(), versionId)
+	if err == syscall.ENOENT {
+		errorCode = NoSuchKey
+		if versionId != "" {
+			errorCode = NoSuchVersion
+		}
+		return
+	}
+	if err != nil {
+		log.LogErrorf("restoreObjectHandler: get object meta fail: requestID(%v) volume(%v) path(%v) versionId(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), versionId, err)
+		return
+	}
+	if errorCode = checkDeleteMarker(w, fileInfo, versionId); errorCode != nil {
+		return
+	}
+	// Only the archived objects can be restored.
+	if fileInfo.Mode.IsDir() || !isArchiveStorageClass(fileInfo.StorageClass) {
+		errorCode = InvalidObjectState
+		return
+	}
+
+	var restored bool
+	if restored, err = vol.RestoreObject(fileInfo, request.Days); err != nil {
+		log.LogErrorf("restoreObjectHandler: restore object fail: requestID(%v) volume(%v) path(%v) versionId(%v) days(%v) err(%v)",
+			GetRequestID(r), vol.Name(), param.Object(), versionId, request.Days, err)
+		return
+	}
+	log.LogInfof("Audit: restore object: requestID(%v) remote(%v) volume(%v) path(%v) versionId(%v) days(%v) restored(%v)",
+		GetRequestID(r), getRequestIP(r), vol.Name(), param.Object(), versionId, request.Days, restored)
+
+	// The restored copy is extended with 200 OK, otherwise the restore is accepted and processed in the background.
+	if !restored {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	return
+}
@@ -89,6 +89,14 @@ type CompleteMultipartResult struct {
 	ETag     string   `xml:"ETag"`
 }
 
+type PostResponse struct {
+	XMLName  xml.Name `xml:"PostResponse"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
 type Initiator struct {
 	XMLName     xml.Name `xml:"Initiator"`
 	ID          string   `xml:"ID"`
@@ -175,6 +183,43 @@ type ListBucketResult struct {
 	CommonPrefixes []*CommonPrefix `xml:"CommonPrefixes"`
 }
 
+type ObjectVersionEntry struct {
+	XMLName      xml.Name     `xml:"Version"`
+	Key          string       `xml:"Key"`
+	VersionId    string       `xml:"VersionId"`
+	IsLatest     bool         `xml:"IsLatest"`
+	LastModified string       `xml:"LastModified"`
+	ETag         string       `xml:"ETag"`
+	Size         int          `xml:"Size"`
+	StorageClass string       `xml:"StorageClass"`
+	Owner        *BucketOwner `xml:"Owner,omitempty"`
+}
+
+type DeleteMarkerEntry struct {
+	XMLName      xml.Name     `xml:"DeleteMarker"`
+	Key          string       `xml:"Key"`
+	VersionId    string       `xml:"VersionId"`
+	IsLatest     bool         `xml:"IsLatest"`
+	LastModified string       `xml:"LastModified"`
+	Owner        *BucketOwner `xml:"Owner,omitempty"`
+}
+
+type ListVersionsResult struct {
+	XMLName             xml.Name              `xml:"ListVersionsResult"`
+	Bucket              string                `xml:"Name"`
+	Prefix              string                `xml:"Prefix"`
+	KeyMarker           string                `xml:"KeyMarker"`
+	VersionIdMarker     string                `xml:"VersionIdMarker"`
+	NextKeyMarker       string                `xml:"NextKeyMarker,omitempty"`
+	NextVersionIdMarker string                `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             int                   `xml:"MaxKeys"`
+	Delimiter           string                `xml:"Delimiter,omitempty"`
+	IsTruncated         bool                  `xml:"IsTruncated"`
+	Versions            []*ObjectVersionEntry `xml:"Version"`
+	DeleteMarkers       []*DeleteMarkerEntry  `xml:"DeleteMarker"`
+	CommonPrefixes      []*CommonPrefix       `xml:"CommonPrefixes"`
+}
+
 func NewParts(fsParts []*FSPart) []*Part {
 	parts := make([]*Part, 0)
 	for _, fsPart := range fsParts {
@@ -199,7 +244,7 @@ func NewUploads(fsUploads []*FSUpload, accessKey string) []*Upload {
 		upload := &Upload{
 			Key:          fsUpload.Key,
 			UploadId:     fsUpload.UploadId,
-			StorageClass: StorageClassStandard,
+			StorageClass: fsUpload.StorageClass,
 			Initiated:    fsUpload.Initiated,
 			Owner:        owner,
 		}
@@ -132,6 +132,46 @@ var (
 	InvalidMinPartNumber                = &ErrorCode{"InvalidRequest", "you must specify at least one part.", http.StatusBadRequest}
 	DiskQuotaExceeded                   = &ErrorCode{"DiskQuotaExceeded", "Disk Quota Exceeded.", http.StatusBadRequest}
 	FileDeleteLock                      = &ErrorCode{"FileDeleteLock", "Operation not permitted.", http.StatusBadRequest}
+	NoSuchLifecycleConfiguration        = &ErrorCode{"NoSuchLifecycleConfiguration", "The lifecycle configuration does not exist.", http.StatusNotFound}
+	NoSuchVersion                       = &ErrorCode{"NoSuchVersion", "The specified version does not exist.", http.StatusNotFound}
+	MethodNotAllowed                    = &ErrorCode{"MethodNotAllowed", "The specified method is not allowed against this resource.", http.StatusMethodNotAllowed}
+	ObjectLockConfigurationNotFound     = &ErrorCode{"ObjectLockConfigurationNotFoundError", "Object Lock configuration does not exist for this bucket.", http.StatusNotFound}
+	NoSuchObjectLockConfiguration       = &ErrorCode{"NoSuchObjectLockConfiguration", "The specified object does not have a ObjectLock configuration.", http.StatusNotFound}
+	MissingObjectLockConfiguration      = &ErrorCode{"InvalidRequest", "Bucket is missing Object Lock Configuration.", http.StatusBadRequest}
+	InvalidBucketState                  = &ErrorCode{"InvalidBucketState", "The request is not valid with the current state of the bucket.", http.StatusConflict}
+	InvalidRetainUntilDate              = &ErrorCode{"InvalidArgument", "The retain until date must be in the future.", http.StatusBadRequest}
+	ObjectLocked                        = &ErrorCode{"AccessDenied", "Access Denied because object protected by object lock.", http.StatusForbidden}
+	NoSuchEncryptionConfiguration       = &ErrorCode{"ServerSideEncryptionConfigurationNotFoundError", "The server side encryption configuration was not found.", http.StatusNotFound}
+	InvalidEncryptionAlgorithm          = &ErrorCode{"InvalidEncryptionAlgorithmError", "The encryption request you specified is not valid. The valid value is AES256.", http.StatusBadRequest}
+	InvalidSSECustomerKey               = &ErrorCode{"InvalidArgument", "The secret key was invalid for the specified algorithm.", http.StatusBadRequest}
+	SSECustomerKeyMD5Mismatch           = &ErrorCode{"InvalidArgument", "The calculated MD5 hash of the key did not match the hash that was provided.", http.StatusBadRequest}
+	SSECustomerKeyRequired              = &ErrorCode{"InvalidRequest", "The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object.", http.StatusBadRequest}
+	SSECustomerKeyMismatch              = &ErrorCode{"AccessDenied", "The provided customer key does not match the key used to encrypt the object.", http.StatusForbidden}
+	SSEMasterKeyNotConfigured           = &ErrorCode{"NotImplemented", "Server side encryption with service managed keys is not configured.", http.StatusNotImplemented}
+	NoSuchReplicationConfiguration      = &ErrorCode{"ReplicationConfigurationNotFoundError", "The replication configuration was not found.", http.StatusNotFound}
+	ReplicationVersioningNotEnabled     = &ErrorCode{"InvalidRequest", "Versioning must be 'Enabled' on the bucket to apply a replication configuration.", http.StatusBadRequest}
+	InvalidReplicationRole              = &ErrorCode{"InvalidArgument", "Invalid Role specified in the replication configuration.", http.StatusBadRequest}
+	IncompleteBody                      = &ErrorCode{"IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.", http.StatusBadRequest}
+	MalformedChunkedEncoding            = &ErrorCode{"InvalidRequest", "The chunked encoding of the request body is malformed.", http.StatusBadRequest}
+	MissingDecodedContentLength         = &ErrorCode{"MissingContentLength", "You must provide the x-amz-decoded-content-length HTTP header.", http.StatusLengthRequired}
+	InvalidTrailer                      = &ErrorCode{"InvalidRequest", "The value specified in the x-amz-trailer header is not supported or the trailer is missing.", http.StatusBadRequest}
+	BadChecksum                         = &ErrorCode{"BadDigest", "The checksum you specified did not match the calculated checksum.", http.StatusBadRequest}
+	MalformedPOSTRequest                = &ErrorCode{"MalformedPOSTRequest", "The body of your POST request is not well-formed multipart/form-data.", http.StatusBadRequest}
+	MaxPostPreDataLengthExceeded        = &ErrorCode{"MaxPostPreDataLengthExceeded", "Your POST request fields preceding the upload file were too large.", http.StatusBadRequest}
+	MissingPostKey                      = &ErrorCode{"InvalidArgument", "Bucket POST must contain a field named 'key'.", http.StatusBadRequest}
+	MissingPostPolicy                   = &ErrorCode{"InvalidArgument", "Bucket POST must contain a field named 'policy' if credentials are specified.", http.StatusBadRequest}
+	InvalidPolicyDocument               = &ErrorCode{"InvalidPolicyDocument", "The content of the form does not meet the conditions specified in the policy document.", http.StatusBadRequest}
+	PostPolicyExpired                   = &ErrorCode{"AccessDenied", "Invalid according to Policy: Policy expired.", http.StatusForbidden}
+	PostPolicyConditionFailed           = &ErrorCode{"AccessDenied", "Invalid according to Policy: Policy Condition failed.", http.StatusForbidden}
+	InvalidNotificationDestination      = &ErrorCode{"InvalidArgument", "Unable to validate the following destination configurations.", http.StatusBadRequest}
+	InvalidNotificationEvent            = &ErrorCode{"InvalidArgument", "The event is not supported for notifications.", http.StatusBadRequest}
+	InvalidNotificationFilter           = &ErrorCode{"InvalidArgument", "The filter rule name must be either prefix or suffix, and can not be specified more than once.", http.StatusBadRequest}
+	NotificationConfigurationOverlap    = &ErrorCode{"InvalidArgument", "Configurations overlap. Configurations on the same bucket cannot share a common event type.", http.StatusBadRequest}
+	NoSuchWebsiteConfiguration          = &ErrorCode{"NoSuchWebsiteConfiguration", "The specified bucket does not have a website configuration.", http.StatusNotFound}
+	NoSuchPublicAccessBlock             = &ErrorCode{"NoSuchPublicAccessBlockConfiguration", "The public access block configuration was not found.", http.StatusNotFound}
+	InvalidStorageClass                 = &ErrorCode{"InvalidStorageClass", "The storage class you specified is not valid.", http.StatusBadRequest}
+	InvalidObjectState                  = &ErrorCode{"InvalidObjectState", "The operation is not valid for the object's storage class.", http.StatusForbidden}
+	RestoreAlreadyInProgress            = &ErrorCode{"RestoreAlreadyInProgress", "Object restore is already in progress.", http.StatusConflict}
 )
 
 func HttpStatusErrorCode(code int) *ErrorCode {
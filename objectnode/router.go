@@ -52,11 +52,10 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Get Object Lock configuration
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLockConfiguration.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetObjectLockConfigurationAction)).
 			Methods(http.MethodGet).
 			Queries("object-lock", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getObjectLockConfigurationHandler)
 
 		// List parts
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListParts.html
@@ -99,21 +98,19 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Get object legal hold
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLegalHold.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetObjectLegalHoldAction)).
 			Methods(http.MethodGet).
 			Path("/{object:.+}").
 			Queries("legal-hold", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getObjectLegalHoldHandler)
 
 		// Get object retention
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectRetention.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetObjectRetentionAction)).
 			Methods(http.MethodGet).
 			Path("/{object:.+}").
 			Queries("retention", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getObjectRetentionHandler)
 
 		// Get object torrent
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectTorrent.html
@@ -161,11 +158,10 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Get bucket policy status
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketPolicyStatus.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetBucketPolicyStatusAction)).
 			Methods(http.MethodGet).
 			Queries("policyStatus", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getBucketPolicyStatusHandler)
 
 		// Get bucket acl
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketAcl.html
@@ -183,11 +179,10 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Get bucket encryption
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketEncryption.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetBucketEncryptionAction)).
 			Methods(http.MethodGet).
 			Queries("encryption", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getBucketEncryptionHandler)
 
 		// Get bucket cors
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketCors.html
@@ -199,19 +194,17 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Get bucket website
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketWebsite.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetBucketWebsiteAction)).
 			Methods(http.MethodGet).
 			Queries("website", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getBucketWebsiteHandler)
 
 		// Get public access block
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetPublicAccessBlock.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetPublicAccessBlockAction)).
 			Methods(http.MethodGet).
 			Queries("publicAccessBlock", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getPublicAccessBlockHandler)
 
 		// Get bucket request payment
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketRequestPayment.html
@@ -223,35 +216,38 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Get bucket replication
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketReplication.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetBucketReplicationAction)).
 			Methods(http.MethodGet).
 			Queries("replication", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getBucketReplicationHandler)
+
+		// Get bucket notification
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketNotificationConfiguration.html
+		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetBucketNotificationAction)).
+			Methods(http.MethodGet).
+			Queries("notification", "").
+			HandlerFunc(o.getBucketNotificationHandler)
 
 		// Get bucket lifecycle
-		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketLifecycle.html
-		// Notes: unsupported operation
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketLifecycleConfiguration.html
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetBucketLifecycleAction)).
 			Methods(http.MethodGet).
 			Queries("lifecycle", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getBucketLifecycleHandler)
 
 		// Get bucket versioning
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketVersioning.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetBucketVersioningAction)).
 			Methods(http.MethodGet).
 			Queries("versioning", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.getBucketVersioningHandler)
 
 		// List object versions
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectVersions.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSListObjectVersionsAction)).
 			Methods(http.MethodGet).
 			Queries("versions", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.listObjectVersionsHandler)
 
 		// List objects version 1
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjects.html
@@ -279,12 +275,11 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Restore object
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_RestoreObject.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSRestoreObjectAction)).
 			Methods(http.MethodPost).
 			Path("/{object:.+}").
 			Queries("restore", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.restoreObjectHandler)
 
 		// Delete objects (multiple objects)
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteObjects.html
@@ -292,16 +287,22 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 			Methods(http.MethodPost).
 			Queries("delete", "").
 			HandlerFunc(o.deleteObjectsHandler)
+
+		// Post object (browser-based upload using HTML forms)
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectPOST.html
+		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPostObjectAction)).
+			Methods(http.MethodPost).
+			HeadersRegexp(HeaderNameContentType, HeaderValueContentTypeFormData).
+			HandlerFunc(o.postObjectHandler)
 	}
 
 	var registerBucketHttpPutRouters = func(r *mux.Router) {
 		// Put Object Lock configuration
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectLockConfiguration.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutObjectLockConfigurationAction)).
 			Methods(http.MethodPut).
 			Queries("object-lock", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.putObjectLockConfigurationHandler)
 
 		// Upload part copy
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_UploadPartCopy.html
@@ -354,21 +355,19 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Put object legal hold
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectLegalHold.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutObjectLegalHoldAction)).
 			Methods(http.MethodPut).
 			Path("/{object:.+}").
 			Queries("legal-hold", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.putObjectLegalHoldHandler)
 
 		// Put object retention
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectRetention.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutObjectRetentionAction)).
 			Methods(http.MethodPut).
 			Path("/{object:.+}").
 			Queries("retention", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.putObjectRetentionHandler)
 
 		// Put object
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObject.html
@@ -400,11 +399,10 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Put bucket encryption
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketEncryption.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutBucketEncryptionAction)).
 			Methods(http.MethodPut).
 			Queries("encryption", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.putBucketEncryptionHandler)
 
 		// Put bucket cors
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketCors.html
@@ -416,19 +414,17 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Put bucket website
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketWebsite.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutBucketWebsiteAction)).
 			Methods(http.MethodPut).
 			Queries("website", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.putBucketWebsiteHandler)
 
 		// Put public access block
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutPublicAccessBlock.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutPublicAccessBlockAction)).
 			Methods(http.MethodPut).
 			Queries("publicAccessBlock", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.putPublicAccessBlockHandler)
 
 		// Put bucket request payment
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketRequestPayment.html
@@ -440,27 +436,31 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Put bucket replication
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketReplication.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutBucketReplicationAction)).
 			Methods(http.MethodPut).
 			Queries("replication", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.putBucketReplicationHandler)
+
+		// Put bucket notification
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketNotificationConfiguration.html
+		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutBucketNotificationAction)).
+			Methods(http.MethodPut).
+			Queries("notification", "").
+			HandlerFunc(o.putBucketNotificationHandler)
 
 		// Put bucket lifecycle
-		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLifecycle.html
-		// Notes: unsupported operation
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLifecycleConfiguration.html
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutBucketLifecycleAction)).
 			Methods(http.MethodPut).
 			Queries("lifecycle", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.putBucketLifecycleHandler)
 
 		// Put bucket versioning
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketVersioning.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSPutBucketVersioningAction)).
 			Methods(http.MethodPut).
 			Queries("versioning", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.putBucketVersioningHandler)
 
 		// Create bucket
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_CreateBucket.html
@@ -517,11 +517,10 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Delete bucket encryption
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketEncryption.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSDeleteBucketEncryptionAction)).
 			Methods(http.MethodDelete).
 			Queries("encryption", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.deleteBucketEncryptionHandler)
 
 		// Delete bucket cors
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketCors.html
@@ -533,35 +532,31 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 
 		// Delete bucket website
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketWebsite.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSDeleteBucketWebsiteAction)).
 			Methods(http.MethodDelete).
 			Queries("website", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.deleteBucketWebsiteHandler)
 
 		// Delete public access block
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeletePublicAccessBlock.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSDeletePublicAccessBlockAction)).
 			Methods(http.MethodDelete).
 			Queries("publicAccessBlock", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.deletePublicAccessBlockHandler)
 
 		// Delete bucket replication
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketReplication.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSDeleteBucketReplicationAction)).
 			Methods(http.MethodDelete).
 			Queries("replication", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.deleteBucketReplicationHandler)
 
 		// Delete bucket lifecycle
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketLifecycle.html
-		// Notes: unsupported operation
 		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSDeleteBucketLifecycleAction)).
 			Methods(http.MethodDelete).
 			Queries("lifecycle", "").
-			HandlerFunc(o.unsupportedOperationHandler)
+			HandlerFunc(o.deleteBucketLifecycleHandler)
 
 		// Delete bucket
 		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucket.html
@@ -597,3 +592,35 @@ func (o *ObjectNode) registerApiRouters(router *mux.Router) {
 	// Unsupported operation
 	router.NotFoundHandler = http.HandlerFunc(o.unsupportedOperationHandler)
 }
+
+// register website routers
+func (o *ObjectNode) registerWebsiteRouters(router *mux.Router) {
+	var websiteRouters []*mux.Router
+	for _, d := range o.websiteDomains {
+		websiteRouters = append(websiteRouters, router.Host("{bucket:.+}."+d).Subrouter())
+		websiteRouters = append(websiteRouters, router.Host("{bucket:.+}."+d+":{port:[0-9]+}").Subrouter())
+	}
+	// The host which is not a subdomain of the website domains is the bucket name itself.
+	websiteRouters = append(websiteRouters, router.PathPrefix("/").Subrouter())
+
+	for _, r := range websiteRouters {
+		// Get object from website endpoint
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/userguide/WebsiteEndpoints.html
+		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSGetObjectAction)).
+			Methods(http.MethodGet).
+			HandlerFunc(o.websiteHandler)
+
+		// Head object from website endpoint
+		// API reference: https://docs.aws.amazon.com/AmazonS3/latest/userguide/WebsiteEndpoints.html
+		r.NewRoute().Name(ActionToUniqueRouteName(proto.OSSHeadObjectAction)).
+			Methods(http.MethodHead).
+			HandlerFunc(o.websiteHandler)
+	}
+
+	// Only GET and HEAD requests are supported by website endpoint.
+	// The unmatched request carries no route variables, which are required to record the response status.
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeWebsiteError(w, mux.SetURLVars(r, map[string]string{}), MethodNotAllowed)
+	})
+	router.MethodNotAllowedHandler = router.NotFoundHandler
+}
@@ -22,6 +22,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/cubefs/cubefs/blobstore/api/access"
 	"github.com/cubefs/cubefs/blockcache/bcache"
@@ -106,14 +107,118 @@ const (
 	//define thread numbers for writing and reading ebs
 	ebsWriteThreads = "bStoreWriteThreads"
 	ebsReadThreads  = "bStoreReadThreads"
+
+	// Bool type configuration item, used to enable the background scanner which applies the bucket
+	// lifecycle configurations, expiring objects and aborting incomplete multipart uploads.
+	// Since every objectnode with this configuration scans all volumes, it is usually turned on
+	// for only one objectnode of the cluster.
+	// Example:
+	//		{
+	//			"enableLifecycleScan": true,
+	//			"lifecycleScanInterval": 3600
+	//		}
+	configLifecycleScan         = "enableLifecycleScan"
+	configLifecycleScanInterval = "lifecycleScanInterval"
+
+	// String type configuration items, used to get the master key of SSE-S3 from the keystore of authnode.
+	// The master key seals the data keys of the objects, and server-side encryption with SSE-S3 is not
+	// available if the master key is not configured.
+	// Example:
+	//		{
+	//			"sseMasterKeyID": "objectnode-sse",
+	//			"authNodes": ["10.0.0.1:8080", "10.0.0.2:8080"],
+	//			"clientID": "objectnode",
+	//			"clientKey": "xxx",
+	//			"enableHTTPS": false,
+	//			"certFile": ""
+	//		}
+	configSSEMasterKeyID = "sseMasterKeyID"
+	configAuthNodes      = "authNodes"
+	configClientID       = "clientID"
+	configClientKey      = "clientKey"
+	configEnableHTTPS    = "enableHTTPS"
+	configCertFile       = "certFile"
+
+	// Array type configuration item, used to specify the S3 endpoints of the remote clusters which the
+	// objects are replicated to. The Role of the bucket replication configuration names the target.
+	// The replication tasks are persisted in the replicationQueueDir until they are done, so the
+	// directory is required if any replication target is configured.
+	// Example:
+	//		{
+	//			"replicationTargets": [
+	//				{
+	//					"name": "backup",
+	//					"endpoint": "http://10.0.1.1:80",
+	//					"accessKey": "xxx",
+	//					"secretKey": "xxx",
+	//					"region": "backup-cluster"
+	//				}
+	//			],
+	//			"replicationQueueDir": "/cfs/objectnode/replication",
+	//			"replicationWorkers": 4,
+	//			"replicationMaxRetries": 16
+	//		}
+	configReplicationTargets    = "replicationTargets"
+	configReplicationQueueDir   = "replicationQueueDir"
+	configReplicationWorkers    = "replicationWorkers"
+	configReplicationMaxRetries = "replicationMaxRetries"
+
+	// Array type configuration item, used to specify the access keys which the remote clusters replicate
+	// the objects to this cluster with. The replication status "REPLICA" of a request is honored only if
+	// the request is signed with one of them, otherwise the object is replicated as the others.
+	// Example:
+	//		{
+	//			"replicationAccessKeys": ["xxx"]
+	//		}
+	configReplicationAccessKeys = "replicationAccessKeys"
+
+	// Array type configuration item, used to specify the targets which the bucket events are published
+	// to. The Queue or Topic of the bucket notification configuration names the target. The events of
+	// a webhook target are POSTed to the endpoint, and are persisted in the notificationQueueDir until
+	// they are delivered, so the directory is required if any webhook target is configured. The events
+	// of a queue target are persisted in the dir of the target for the local consumers.
+	// Example:
+	//		{
+	//			"notificationTargets": [
+	//				{
+	//					"name": "pipeline",
+	//					"type": "webhook",
+	//					"endpoint": "http://10.0.1.2:8080/events"
+	//				},
+	//				{
+	//					"name": "local",
+	//					"type": "queue",
+	//					"dir": "/cfs/objectnode/events"
+	//				}
+	//			],
+	//			"notificationQueueDir": "/cfs/objectnode/notification",
+	//			"notificationMaxRetries": 16
+	//		}
+	configNotificationTargets    = "notificationTargets"
+	configNotificationQueueDir   = "notificationQueueDir"
+	configNotificationMaxRetries = "notificationMaxRetries"
+
+	// String type configuration item, used to specify the listening port of the website endpoint, which
+	// serves the static websites of the buckets with the website configuration to the anonymous GET and
+	// HEAD requests. The website endpoint is disabled if the port is not configured.
+	// Array type configuration item, used to specify the domains of the website endpoint. The bucket is
+	// resolved from the host of the request, which is either "<bucket>.<domain>" or the bucket name itself.
+	// Example:
+	//		{
+	//			"websiteListen": "8080",
+	//			"websiteDomains": ["website.cube.io"]
+	//		}
+	configWebsiteListen  = "websiteListen"
+	configWebsiteDomains = "websiteDomains"
 )
 
 // Default of configuration value
 const (
-	defaultListen               = "80"
-	defaultCacheRefreshInterval = 10 * 60
-	defaultMaxDentryCacheNum    = 10000000
-	defaultMaxInodeAttrCacheNum = 10000000
+	defaultListen                = "80"
+	defaultCacheRefreshInterval  = 10 * 60
+	defaultMaxDentryCacheNum     = 10000000
+	defaultMaxInodeAttrCacheNum  = 10000000
+	defaultLifecycleScanInterval = 60 * 60
 	//ebs
 	MaxSizePutOnce = int64(1) << 23
 )
@@ -136,11 +241,21 @@ type ObjectNode struct {
 	listen     string
 	region     string
 	httpServer *http.Server
+
+	websiteListen  string
+	websiteDomains []string
+	websiteServer  *http.Server
+
 	vm         *VolumeManager
 	mc         *master.MasterClient
 	state      uint32
 	wg         sync.WaitGroup
 	userStore  UserInfoStore
+	lcScanner  *LifecycleScanner
+	replicator *Replicator
+	notifier   *Notifier
+
+	replicaAccessKeys map[string]struct{} // the access keys signing the replications of the remote clusters
 
 	signatureIgnoredActions proto.Actions // signature ignored actions
 	disabledActions         proto.Actions // disabled actions
@@ -181,6 +296,17 @@ func (o *ObjectNode) loadConfig(cfg *config.Config) (err error) {
 	}
 	log.LogInfof("loadConfig: setup config: %v(%v)", configDomains, domains)
 
+	// parse website endpoint config
+	if websiteListen := cfg.GetString(configWebsiteListen); websiteListen != "" {
+		if !regexpListen.MatchString(websiteListen) || websiteListen == listen {
+			return config.NewIllegalConfigError(configWebsiteListen)
+		}
+		o.websiteListen = websiteListen
+		o.websiteDomains = cfg.GetStringSlice(configWebsiteDomains)
+		log.LogInfof("loadConfig: setup config: %v(%v) %v(%v)", configWebsiteListen, websiteListen,
+			configWebsiteDomains, o.websiteDomains)
+	}
+
 	// parse master config
 	masters := cfg.GetStringSlice(configMasterAddr)
 	if len(masters) == 0 {
@@ -244,6 +370,83 @@ func (o *ObjectNode) loadConfig(cfg *config.Config) (err error) {
 		blockCache = bcache.NewBcacheClient()
 	}
 
+	// parse lifecycle scanner config
+	if cfg.GetBool(configLifecycleScan) {
+		lifecycleScanInterval := cfg.GetInt64(configLifecycleScanInterval)
+		if lifecycleScanInterval <= 0 {
+			lifecycleScanInterval = defaultLifecycleScanInterval
+		}
+		o.lcScanner = NewLifecycleScanner(o.vm, o.mc, time.Duration(lifecycleScanInterval)*time.Second)
+		log.LogInfof("loadConfig: enableLifecycleScan: true, lifecycleScanInterval: %v", lifecycleScanInterval)
+	}
+
+	// load the master key of server-side encryption
+	if keyID := cfg.GetString(configSSEMasterKeyID); keyID != "" {
+		authNodes := cfg.GetStringSlice(configAuthNodes)
+		if len(authNodes) == 0 {
+			return config.NewIllegalConfigError(configAuthNodes)
+		}
+		if err = loadSSEMasterKey(authNodes, cfg.GetBool(configEnableHTTPS), cfg.GetString(configCertFile),
+			cfg.GetString(configClientID), cfg.GetString(configClientKey), keyID); err != nil {
+			return
+		}
+		log.LogInfof("loadConfig: sseMasterKeyID: %v", keyID)
+	}
+
+	// parse bucket replication config
+	if cfg.HasKey(configReplicationTargets) {
+		var targets []*ReplicationTarget
+		if targets, err = parseReplicationTargets(cfg.GetSlice(configReplicationTargets)); err != nil {
+			return
+		}
+		queueDir := cfg.GetString(configReplicationQueueDir)
+		if queueDir == "" {
+			return config.NewIllegalConfigError(configReplicationQueueDir)
+		}
+		workers := cfg.GetInt(configReplicationWorkers)
+		if workers <= 0 {
+			workers = defaultReplicationWorkers
+		}
+		maxRetries := cfg.GetInt(configReplicationMaxRetries)
+		if maxRetries <= 0 {
+			maxRetries = defaultReplicationMaxRetries
+		}
+		var queue *ReplicationQueue
+		if queue, err = OpenReplicationQueue(queueDir); err != nil {
+			return
+		}
+		if o.replicator, err = NewReplicator(o.vm, targets, queue, workers, maxRetries); err != nil {
+			return
+		}
+		log.LogInfof("loadConfig: replicationTargets: %v, replicationQueueDir: %v, replicationWorkers: %v, "+
+			"replicationMaxRetries: %v", len(targets), queueDir, workers, maxRetries)
+	}
+	if accessKeys := cfg.GetStringSlice(configReplicationAccessKeys); len(accessKeys) > 0 {
+		o.replicaAccessKeys = make(map[string]struct{}, len(accessKeys))
+		for _, accessKey := range accessKeys {
+			o.replicaAccessKeys[accessKey] = struct{}{}
+		}
+		log.LogInfof("loadConfig: replicationAccessKeys: %v", len(accessKeys))
+	}
+
+	// parse bucket notification config
+	if cfg.HasKey(configNotificationTargets) {
+		var targets []*NotificationTarget
+		if targets, err = parseNotificationTargets(cfg.GetSlice(configNotificationTargets)); err != nil {
+			return
+		}
+		queueDir := cfg.GetString(configNotificationQueueDir)
+		maxRetries := cfg.GetInt(configNotificationMaxRetries)
+		if maxRetries <= 0 {
+			maxRetries = defaultNotificationMaxRetries
+		}
+		if o.notifier, err = NewNotifier(targets, queueDir, maxRetries); err != nil {
+			return
+		}
+		log.LogInfof("loadConfig: notificationTargets: %v, notificationQueueDir: %v, notificationMaxRetries: %v",
+			len(targets), queueDir, maxRetries)
+	}
+
 	return
 }
 
@@ -288,6 +491,19 @@ func handleStart(s common.Server, cfg *config.Config) (err error) {
 		log.LogInfof("handleStart: start rest api fail: err(%v)", err)
 		return
 	}
+	if o.websiteListen != "" {
+		o.startWebsiteAPI()
+	}
+
+	if o.lcScanner != nil {
+		o.lcScanner.Start()
+	}
+	if o.replicator != nil {
+		o.replicator.Start()
+	}
+	if o.notifier != nil {
+		o.notifier.Start()
+	}
 
 	exporter.Init(cfg.GetString("role"), cfg)
 	exporter.RegistConsul(ci.Cluster, cfg.GetString("role"), cfg)
@@ -315,7 +531,17 @@ func handleShutdown(s common.Server) {
 	if !ok {
 		return
 	}
+	if o.lcScanner != nil {
+		o.lcScanner.Stop()
+	}
+	if o.replicator != nil {
+		o.replicator.Stop()
+	}
+	if o.notifier != nil {
+		o.notifier.Stop()
+	}
 	o.shutdownRestAPI()
+	o.shutdownWebsiteAPI()
 }
 
 func (o *ObjectNode) startMuxRestAPI() (err error) {
@@ -352,6 +578,37 @@ func (o *ObjectNode) shutdownRestAPI() {
 	}
 }
 
+func (o *ObjectNode) startWebsiteAPI() {
+	router := mux.NewRouter().SkipClean(true)
+	o.registerWebsiteRouters(router)
+	router.Use(
+		o.traceMiddleware,
+		o.websiteMiddleware,
+		o.corsMiddleware,
+		o.contentMiddleware,
+	)
+
+	var server = &http.Server{
+		Addr:    ":" + o.websiteListen,
+		Handler: router,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil {
+			log.LogErrorf("startWebsiteAPI: start http server fail, err(%v)", err)
+			return
+		}
+	}()
+	o.websiteServer = server
+}
+
+func (o *ObjectNode) shutdownWebsiteAPI() {
+	if o.websiteServer != nil {
+		_ = o.websiteServer.Shutdown(context.Background())
+		o.websiteServer = nil
+	}
+}
+
 func NewServer() *ObjectNode {
 	return &ObjectNode{}
 }
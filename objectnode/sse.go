@@ -0,0 +1,263 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/serv-side-encryption.html
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"hash"
+	"io"
+	"net/http"
+
+	"github.com/cubefs/cubefs/sdk/auth"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	SSEAlgorithmAES256 = "AES256"
+	SSEAlgorithmKMS    = "aws:kms"
+
+	SSETypeS3 = "SSE-S3"
+	SSETypeC  = "SSE-C"
+)
+
+// sseMasterKey is the key which seals the data keys of the objects encrypted with SSE-S3.
+var sseMasterKey []byte
+
+type ServerSideEncryptionConfiguration struct {
+	XMLName xml.Name                    `xml:"ServerSideEncryptionConfiguration"`
+	Rules   []*ServerSideEncryptionRule `xml:"Rule"`
+}
+
+type ServerSideEncryptionRule struct {
+	ApplyServerSideEncryptionByDefault *ServerSideEncryptionByDefault `xml:"ApplyServerSideEncryptionByDefault"`
+	BucketKeyEnabled                   bool                           `xml:"BucketKeyEnabled,omitempty"`
+}
+
+type ServerSideEncryptionByDefault struct {
+	SSEAlgorithm   string `xml:"SSEAlgorithm"`
+	KMSMasterKeyID string `xml:"KMSMasterKeyID,omitempty"`
+}
+
+func NewServerSideEncryptionConfiguration() *ServerSideEncryptionConfiguration {
+	return &ServerSideEncryptionConfiguration{
+		XMLName: xml.Name{Local: "ServerSideEncryptionConfiguration"},
+	}
+}
+
+func parseEncryptionConfig(bytes []byte) (config *ServerSideEncryptionConfiguration, errCode *ErrorCode) {
+	config = NewServerSideEncryptionConfiguration()
+	if err := xml.Unmarshal(bytes, config); err != nil {
+		return nil, MalformedXML
+	}
+	if len(config.Rules) != 1 || config.Rules[0].ApplyServerSideEncryptionByDefault == nil {
+		return nil, MalformedXML
+	}
+	// Only the encryption with the keys managed by the object node is supported.
+	var byDefault = config.Rules[0].ApplyServerSideEncryptionByDefault
+	if byDefault.SSEAlgorithm != SSEAlgorithmAES256 || byDefault.KMSMasterKeyID != "" {
+		return nil, InvalidEncryptionAlgorithm
+	}
+	return config, nil
+}
+
+func storeBucketEncryption(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSBucketSSE, bytes)
+}
+
+func deleteBucketEncryption(vol *Volume) (err error) {
+	return vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSBucketSSE)
+}
+
+// SSEOption is the server-side encryption requested for an object or the key provided to access an object.
+type SSEOption struct {
+	Type           string
+	CustomerKey    []byte
+	CustomerKeyMD5 string
+}
+
+// keyEncryptionKey returns the key sealing the data keys.
+func (o *SSEOption) keyEncryptionKey() ([]byte, error) {
+	if o.Type == SSETypeC {
+		return o.CustomerKey, nil
+	}
+	if len(sseMasterKey) == 0 {
+		return nil, SSEMasterKeyNotConfigured
+	}
+	return sseMasterKey, nil
+}
+
+// newObjectEncryption generates the data key of a new object and seals it.
+func newObjectEncryption(opt *SSEOption) (enc *ObjectEncryption, dataKey []byte, err error) {
+	var kek []byte
+	if kek, err = opt.keyEncryptionKey(); err != nil {
+		return
+	}
+	if dataKey, err = sseRandomBytes(sseDataKeySize); err != nil {
+		return
+	}
+	enc = &ObjectEncryption{
+		Type:   opt.Type,
+		KeyMD5: opt.CustomerKeyMD5,
+	}
+	if enc.DataKey, err = sealDataKey(kek, dataKey); err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+// openDataKey returns the data key of the object. The customer key is required if the object is encrypted with SSE-C.
+func (e *ObjectEncryption) openDataKey(opt *SSEOption) (dataKey []byte, err error) {
+	if e.Type == SSETypeC {
+		if opt == nil || opt.Type != SSETypeC {
+			return nil, SSECustomerKeyRequired
+		}
+		if opt.CustomerKeyMD5 != e.KeyMD5 {
+			return nil, SSECustomerKeyMismatch
+		}
+	} else {
+		opt = &SSEOption{Type: SSETypeS3}
+	}
+	var kek []byte
+	if kek, err = opt.keyEncryptionKey(); err != nil {
+		return
+	}
+	return openDataKey(kek, e.DataKey)
+}
+
+// newSSEStreamReader returns a reader which encrypts the data read from the reader as a new stream.
+// The size of the stream is available from the reader after the data is exhausted.
+func newSSEStreamReader(reader io.Reader, dataKey []byte, h hash.Hash) (er *sseEncryptReader, stream *SSEStream, err error) {
+	var prefix []byte
+	if prefix, err = sseRandomBytes(sseNoncePrefixSize); err != nil {
+		return
+	}
+	if er, err = newSSEEncryptReader(reader, dataKey, prefix, h); err != nil {
+		return
+	}
+	return er, &SSEStream{Nonce: prefix}, nil
+}
+
+// parseSSEHeaders parses the server-side encryption of the requests which create objects.
+// A nil option is returned if the encryption is not requested.
+func parseSSEHeaders(header http.Header) (opt *SSEOption, errCode *ErrorCode) {
+	var algorithm = header.Get(HeaderNameXAmzServerSideEncryption)
+	if opt, errCode = parseSSECustomerHeaders(header, false); errCode != nil {
+		return
+	}
+	if algorithm == "" {
+		return
+	}
+	if opt != nil {
+		return nil, InvalidArgument
+	}
+	if algorithm == SSEAlgorithmKMS {
+		return nil, UnsupportedOperation
+	}
+	if algorithm != SSEAlgorithmAES256 {
+		return nil, InvalidEncryptionAlgorithm
+	}
+	return &SSEOption{Type: SSETypeS3}, nil
+}
+
+// parseSSECustomerHeaders parses the customer key of SSE-C, or the customer key of the copy source.
+func parseSSECustomerHeaders(header http.Header, copySource bool) (opt *SSEOption, errCode *ErrorCode) {
+	var algorithm, key, keyMD5 = header.Get(HeaderNameXAmzSSECustomerAlgorithm), header.Get(HeaderNameXAmzSSECustomerKey),
+		header.Get(HeaderNameXAmzSSECustomerKeyMD5)
+	if copySource {
+		algorithm, key, keyMD5 = header.Get(HeaderNameXAmzCopySourceSSECustomerAlgorithm),
+			header.Get(HeaderNameXAmzCopySourceSSECustomerKey), header.Get(HeaderNameXAmzCopySourceSSECustomerKeyMD5)
+	}
+	if algorithm == "" && key == "" && keyMD5 == "" {
+		return
+	}
+	if algorithm != SSEAlgorithmAES256 {
+		return nil, InvalidEncryptionAlgorithm
+	}
+	customerKey, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(customerKey) != sseDataKeySize {
+		return nil, InvalidSSECustomerKey
+	}
+	var sum = md5.Sum(customerKey)
+	if base64.StdEncoding.EncodeToString(sum[:]) != keyMD5 {
+		return nil, SSECustomerKeyMD5Mismatch
+	}
+	return &SSEOption{Type: SSETypeC, CustomerKey: customerKey, CustomerKeyMD5: keyMD5}, nil
+}
+
+// newSSEOption returns the server-side encryption of the object to be created. The default encryption
+// of the bucket is applied if the encryption is not specified in the request headers.
+func newSSEOption(vol *Volume, header http.Header) (opt *SSEOption, err error) {
+	var errorCode *ErrorCode
+	if opt, errorCode = parseSSEHeaders(header); errorCode != nil {
+		return nil, errorCode
+	}
+	if opt == nil {
+		var config *ServerSideEncryptionConfiguration
+		if config, err = vol.metaLoader.loadEncryption(); err != nil {
+			return
+		}
+		if config != nil {
+			opt = &SSEOption{Type: SSETypeS3}
+		}
+	}
+	if opt != nil && opt.Type == SSETypeS3 && len(sseMasterKey) == 0 {
+		return nil, SSEMasterKeyNotConfigured
+	}
+	return
+}
+
+// objectDataKey returns the data key of the encrypted object, the customer key in the headers is
+// verified if the object is encrypted with SSE-C. A nil key is returned if the object is not encrypted.
+func objectDataKey(info *FSFileInfo, header http.Header, copySource bool) (dataKey []byte, err error) {
+	if info.Encryption == nil {
+		return
+	}
+	opt, errorCode := parseSSECustomerHeaders(header, copySource)
+	if errorCode != nil {
+		return nil, errorCode
+	}
+	return info.Encryption.openDataKey(opt)
+}
+
+// setSSEHeaders sets the server-side encryption headers of the responses.
+func setSSEHeaders(header http.Header, enc *ObjectEncryption) {
+	if enc == nil {
+		return
+	}
+	if enc.Type == SSETypeC {
+		header[HeaderNameXAmzSSECustomerAlgorithm] = []string{SSEAlgorithmAES256}
+		header[HeaderNameXAmzSSECustomerKeyMD5] = []string{enc.KeyMD5}
+		return
+	}
+	header[HeaderNameXAmzServerSideEncryption] = []string{SSEAlgorithmAES256}
+}
+
+// loadSSEMasterKey gets the master key of SSE-S3 from the keystore of authnode.
+func loadSSEMasterKey(authNodes []string, enableHTTPS bool, certFile, clientID, clientKey, keyID string) (err error) {
+	var ac = auth.NewAuthClient(authNodes, enableHTTPS, certFile)
+	keyInfo, err := ac.API().AdminGetKey(clientID, clientKey, keyID)
+	if err != nil {
+		log.LogErrorf("loadSSEMasterKey: get key from authnode fail: keyID(%v) err(%v)", keyID, err)
+		return
+	}
+	var sum = sha256.Sum256(keyInfo.AuthKey)
+	sseMasterKey = sum[:]
+	return
+}
@@ -0,0 +1,339 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+)
+
+// The object data is split into streams, one for a simple object and one for each part of a multipart object.
+// Every stream is encrypted with AES-GCM in segments of sseSegmentSize bytes, and the nonce of a segment is
+// the nonce prefix of the stream followed by the big-endian index of the segment in the stream, so that a
+// range of the object can be decrypted by reading the segments covering it only.
+const (
+	sseSegmentSize     = 64 * 1024
+	sseTagSize         = 16
+	sseCipherSegSize   = sseSegmentSize + sseTagSize
+	sseDataKeySize     = 32
+	sseNoncePrefixSize = 8
+)
+
+var (
+	errSSEInvalidDataKey = errors.New("invalid sealed data key")
+	errSSEDecrypt        = errors.New("decrypt object data fail")
+)
+
+// ObjectEncryption is the server-side encryption information of an object, which is persisted in the
+// extended attribute XAttrKeyOSSEncryption of the object.
+type ObjectEncryption struct {
+	Type    string       `json:"type"`
+	DataKey []byte       `json:"data_key"` // sealed by the master key or the customer key
+	KeyMD5  string       `json:"key_md5,omitempty"`
+	Size    int64        `json:"size"`
+	Streams []*SSEStream `json:"streams,omitempty"`
+}
+
+type SSEStream struct {
+	Size  int64  `json:"size"`
+	Nonce []byte `json:"nonce"`
+}
+
+func (e *ObjectEncryption) Encode() string {
+	data, _ := json.Marshal(e)
+	return string(data)
+}
+
+func parseObjectEncryption(raw string) *ObjectEncryption {
+	if raw == "" {
+		return nil
+	}
+	var enc = new(ObjectEncryption)
+	if err := json.Unmarshal([]byte(raw), enc); err != nil {
+		return nil
+	}
+	return enc
+}
+
+// cipherSize returns the size of the encrypted data of the object.
+func (e *ObjectEncryption) cipherSize() (size int64) {
+	for _, stream := range e.Streams {
+		size += sseCipherSize(stream.Size)
+	}
+	return
+}
+
+// sseCipherSize returns the size of a stream after the plaintext of the size is encrypted.
+func sseCipherSize(size int64) int64 {
+	return size + (size+sseSegmentSize-1)/sseSegmentSize*sseTagSize
+}
+
+func newSSEAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sseRandomBytes(n int) (b []byte, err error) {
+	b = make([]byte, n)
+	_, err = io.ReadFull(rand.Reader, b)
+	return
+}
+
+// sealDataKey encrypts the data key with the key encryption key, and the random nonce is prepended.
+func sealDataKey(kek, dataKey []byte) (sealed []byte, err error) {
+	var aead cipher.AEAD
+	if aead, err = newSSEAEAD(kek); err != nil {
+		return
+	}
+	var nonce []byte
+	if nonce, err = sseRandomBytes(aead.NonceSize()); err != nil {
+		return
+	}
+	return aead.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+func openDataKey(kek, sealed []byte) (dataKey []byte, err error) {
+	var aead cipher.AEAD
+	if aead, err = newSSEAEAD(kek); err != nil {
+		return
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errSSEInvalidDataKey
+	}
+	if dataKey, err = aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil); err != nil {
+		return nil, errSSEInvalidDataKey
+	}
+	return
+}
+
+func sseSegmentNonce(nonce, prefix []byte, index uint32) []byte {
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[sseNoncePrefixSize:], index)
+	return nonce
+}
+
+// sseEncryptReader encrypts the data read from the underlying reader as a stream, and the plaintext
+// is written to the hash.
+type sseEncryptReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	nonce  []byte
+	index  uint32
+	h      hash.Hash
+	size   int64
+	plain  []byte
+	sealed []byte
+	out    []byte
+	eof    bool
+}
+
+func newSSEEncryptReader(r io.Reader, dataKey, prefix []byte, h hash.Hash) (er *sseEncryptReader, err error) {
+	var aead cipher.AEAD
+	if aead, err = newSSEAEAD(dataKey); err != nil {
+		return
+	}
+	er = &sseEncryptReader{
+		r:      r,
+		aead:   aead,
+		prefix: prefix,
+		nonce:  make([]byte, aead.NonceSize()),
+		h:      h,
+		plain:  make([]byte, sseSegmentSize),
+		sealed: make([]byte, 0, sseCipherSegSize),
+	}
+	return
+}
+
+// Size returns the size of the plaintext read from the underlying reader.
+func (er *sseEncryptReader) Size() int64 {
+	return er.size
+}
+
+func (er *sseEncryptReader) Read(p []byte) (n int, err error) {
+	for len(er.out) == 0 {
+		if er.eof {
+			return 0, io.EOF
+		}
+		var readN int
+		if readN, err = er.fill(); err != nil {
+			return
+		}
+		if readN == 0 {
+			continue
+		}
+		if er.h != nil {
+			er.h.Write(er.plain[:readN])
+		}
+		er.size += int64(readN)
+		er.out = er.aead.Seal(er.sealed[:0], sseSegmentNonce(er.nonce, er.prefix, er.index), er.plain[:readN], nil)
+		er.index++
+	}
+	n = copy(p, er.out)
+	er.out = er.out[n:]
+	return
+}
+
+// fill reads a segment of plaintext until the buffer is full or the underlying reader is exhausted.
+func (er *sseEncryptReader) fill() (n int, err error) {
+	for n < len(er.plain) {
+		var readN int
+		readN, err = er.r.Read(er.plain[n:])
+		n += readN
+		if err == io.EOF {
+			er.eof = true
+			return n, nil
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// sseDecryptWriter decrypts the segments of a stream written to it, and writes the plaintext in the
+// requested range to the underlying writer.
+type sseDecryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	nonce  []byte
+	index  uint32
+	skip   int64
+	remain int64
+	buf    []byte
+	plain  []byte
+}
+
+func newSSEDecryptWriter(w io.Writer, aead cipher.AEAD, prefix []byte, index uint32, skip, length int64) *sseDecryptWriter {
+	return &sseDecryptWriter{
+		w:      w,
+		aead:   aead,
+		prefix: prefix,
+		nonce:  make([]byte, aead.NonceSize()),
+		index:  index,
+		skip:   skip,
+		remain: length,
+		buf:    make([]byte, 0, sseCipherSegSize),
+		plain:  make([]byte, 0, sseSegmentSize),
+	}
+}
+
+func (dw *sseDecryptWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		var size = sseCipherSegSize - len(dw.buf)
+		if size > len(p) {
+			size = len(p)
+		}
+		dw.buf = append(dw.buf, p[:size]...)
+		p = p[size:]
+		n += size
+		if len(dw.buf) == sseCipherSegSize {
+			if err = dw.Flush(); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Flush decrypts the buffered segment, which may be the last and short segment of the stream.
+func (dw *sseDecryptWriter) Flush() (err error) {
+	if len(dw.buf) == 0 {
+		return
+	}
+	var plain []byte
+	plain, err = dw.aead.Open(dw.plain[:0], sseSegmentNonce(dw.nonce, dw.prefix, dw.index), dw.buf, nil)
+	dw.buf = dw.buf[:0]
+	dw.index++
+	if err != nil {
+		return errSSEDecrypt
+	}
+	if dw.skip > 0 {
+		if dw.skip >= int64(len(plain)) {
+			dw.skip -= int64(len(plain))
+			return
+		}
+		plain = plain[dw.skip:]
+		dw.skip = 0
+	}
+	if int64(len(plain)) > dw.remain {
+		plain = plain[:dw.remain]
+	}
+	if len(plain) == 0 {
+		return
+	}
+	dw.remain -= int64(len(plain))
+	_, err = dw.w.Write(plain)
+	return
+}
+
+// sseReadRange is the encrypted range of a stream which covers a part of the requested plaintext range.
+type sseReadRange struct {
+	stream *SSEStream
+	offset int64 // offset of the encrypted data in the object
+	size   int64 // size of the encrypted data
+	index  uint32
+	skip   int64
+	length int64
+}
+
+// readRanges maps the plaintext range of the object to the encrypted ranges of the streams.
+func (e *ObjectEncryption) readRanges(offset, size int64) (ranges []*sseReadRange) {
+	var end = offset + size
+	if end > e.Size {
+		end = e.Size
+	}
+	var plainOffset, cipherOffset int64
+	for _, stream := range e.Streams {
+		if plainOffset >= end {
+			break
+		}
+		if offset < plainOffset+stream.Size {
+			var start, stop = offset - plainOffset, end - plainOffset
+			if start < 0 {
+				start = 0
+			}
+			if stop > stream.Size {
+				stop = stream.Size
+			}
+			var first, last = start / sseSegmentSize, (stop - 1) / sseSegmentSize
+			var cipherStop = (last + 1) * sseCipherSegSize
+			if streamCipherSize := sseCipherSize(stream.Size); cipherStop > streamCipherSize {
+				cipherStop = streamCipherSize
+			}
+			ranges = append(ranges, &sseReadRange{
+				stream: stream,
+				offset: cipherOffset + first*sseCipherSegSize,
+				size:   cipherStop - first*sseCipherSegSize,
+				index:  uint32(first),
+				skip:   start - first*sseSegmentSize,
+				length: stop - start,
+			})
+		}
+		plainOffset += stream.Size
+		cipherOffset += sseCipherSize(stream.Size)
+	}
+	return
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	MaxEncryptionConfigSize = 1 << 10 // 1KB
+)
+
+// Get bucket encryption
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketEncryption.html
+func (o *ObjectNode) getBucketEncryptionHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getBucketEncryptionHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var encryption *ServerSideEncryptionConfiguration
+	if encryption, err = vol.metaLoader.loadEncryption(); err != nil {
+		log.LogErrorf("getBucketEncryptionHandler: load encryption fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if encryption == nil {
+		errorCode = NoSuchEncryptionConfiguration
+		return
+	}
+
+	var data []byte
+	if data, err = MarshalXMLEntity(encryption); err != nil {
+		log.LogErrorf("getBucketEncryptionHandler: xml marshal fail: requestID(%v) volume(%v) encryption(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), encryption, err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	if _, err = w.Write(data); err != nil {
+		log.LogErrorf("getBucketEncryptionHandler: write response body fail: requestID(%v) volume(%v) body(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(data), err)
+	}
+	return
+}
+
+// Put bucket encryption
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketEncryption.html
+func (o *ObjectNode) putBucketEncryptionHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("putBucketEncryptionHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+	// The default encryption can not be applied without the master key.
+	if len(sseMasterKey) == 0 {
+		errorCode = SSEMasterKeyNotConfigured
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, MaxEncryptionConfigSize+1)); err != nil {
+		log.LogErrorf("putBucketEncryptionHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxEncryptionConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+
+	var encryption *ServerSideEncryptionConfiguration
+	if encryption, errorCode = parseEncryptionConfig(body); errorCode != nil {
+		log.LogErrorf("putBucketEncryptionHandler: parse encryption config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), errorCode)
+		return
+	}
+	if err = storeBucketEncryption(body, vol); err != nil {
+		log.LogErrorf("putBucketEncryptionHandler: store encryption config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	vol.metaLoader.storeEncryption(encryption)
+
+	return
+}
+
+// Delete bucket encryption
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketEncryption.html
+func (o *ObjectNode) deleteBucketEncryptionHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("deleteBucketEncryptionHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+	if err = deleteBucketEncryption(vol); err != nil {
+		log.LogErrorf("deleteBucketEncryptionHandler: delete encryption config fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	vol.metaLoader.storeEncryption(nil)
+	w.WriteHeader(http.StatusNoContent)
+
+	return
+}
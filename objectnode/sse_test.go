@@ -0,0 +1,171 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEncryptionConfig(t *testing.T) {
+	config, errCode := parseEncryptionConfig([]byte(`<ServerSideEncryptionConfiguration><Rule><ApplyServerSideEncryptionByDefault>` +
+		`<SSEAlgorithm>AES256</SSEAlgorithm></ApplyServerSideEncryptionByDefault></Rule></ServerSideEncryptionConfiguration>`))
+	require.Nil(t, errCode)
+	require.Equal(t, SSEAlgorithmAES256, config.Rules[0].ApplyServerSideEncryptionByDefault.SSEAlgorithm)
+
+	_, errCode = parseEncryptionConfig([]byte(`<ServerSideEncryptionConfiguration><Rule><ApplyServerSideEncryptionByDefault>` +
+		`<SSEAlgorithm>aws:kms</SSEAlgorithm></ApplyServerSideEncryptionByDefault></Rule></ServerSideEncryptionConfiguration>`))
+	require.Equal(t, InvalidEncryptionAlgorithm, errCode)
+
+	_, errCode = parseEncryptionConfig([]byte(`<ServerSideEncryptionConfiguration></ServerSideEncryptionConfiguration>`))
+	require.Equal(t, MalformedXML, errCode)
+}
+
+func TestParseSSEHeaders(t *testing.T) {
+	opt, errCode := parseSSEHeaders(http.Header{})
+	require.Nil(t, errCode)
+	require.Nil(t, opt)
+
+	header := http.Header{}
+	header.Set(HeaderNameXAmzServerSideEncryption, SSEAlgorithmAES256)
+	opt, errCode = parseSSEHeaders(header)
+	require.Nil(t, errCode)
+	require.Equal(t, SSETypeS3, opt.Type)
+
+	header.Set(HeaderNameXAmzServerSideEncryption, SSEAlgorithmKMS)
+	_, errCode = parseSSEHeaders(header)
+	require.Equal(t, UnsupportedOperation, errCode)
+
+	key := make([]byte, sseDataKeySize)
+	_, _ = rand.Read(key)
+	sum := md5.Sum(key)
+	header = http.Header{}
+	header.Set(HeaderNameXAmzSSECustomerAlgorithm, SSEAlgorithmAES256)
+	header.Set(HeaderNameXAmzSSECustomerKey, base64.StdEncoding.EncodeToString(key))
+	header.Set(HeaderNameXAmzSSECustomerKeyMD5, base64.StdEncoding.EncodeToString(sum[:]))
+	opt, errCode = parseSSEHeaders(header)
+	require.Nil(t, errCode)
+	require.Equal(t, SSETypeC, opt.Type)
+	require.Equal(t, key, opt.CustomerKey)
+
+	header.Set(HeaderNameXAmzServerSideEncryption, SSEAlgorithmAES256)
+	_, errCode = parseSSEHeaders(header)
+	require.Equal(t, InvalidArgument, errCode)
+
+	header.Del(HeaderNameXAmzServerSideEncryption)
+	header.Set(HeaderNameXAmzSSECustomerKeyMD5, base64.StdEncoding.EncodeToString(key[:16]))
+	_, errCode = parseSSEHeaders(header)
+	require.Equal(t, SSECustomerKeyMD5Mismatch, errCode)
+}
+
+func TestObjectDataKey(t *testing.T) {
+	sseMasterKey = make([]byte, sseDataKeySize)
+	defer func() { sseMasterKey = nil }()
+
+	enc, dataKey, err := newObjectEncryption(&SSEOption{Type: SSETypeS3})
+	require.NoError(t, err)
+	opened, err := enc.openDataKey(nil)
+	require.NoError(t, err)
+	require.Equal(t, dataKey, opened)
+
+	customerKey := bytes.Repeat([]byte{1}, sseDataKeySize)
+	opt := &SSEOption{Type: SSETypeC, CustomerKey: customerKey, CustomerKeyMD5: "md5"}
+	enc, dataKey, err = newObjectEncryption(opt)
+	require.NoError(t, err)
+	_, err = enc.openDataKey(nil)
+	require.Equal(t, SSECustomerKeyRequired, err)
+	_, err = enc.openDataKey(&SSEOption{Type: SSETypeC, CustomerKey: customerKey, CustomerKeyMD5: "other"})
+	require.Equal(t, SSECustomerKeyMismatch, err)
+	opened, err = enc.openDataKey(opt)
+	require.NoError(t, err)
+	require.Equal(t, dataKey, opened)
+
+	_, err = openDataKey(bytes.Repeat([]byte{2}, sseDataKeySize), enc.DataKey)
+	require.Equal(t, errSSEInvalidDataKey, err)
+}
+
+func TestSSEStreamRoundTrip(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{3}, sseDataKeySize)
+	// streams of the parts, including the sizes on the segment boundary
+	sizes := []int{sseSegmentSize*2 + 100, sseSegmentSize, 1, 0, sseSegmentSize*3 - 1}
+	var plain, sealed []byte
+	enc := &ObjectEncryption{Type: SSETypeS3}
+	for _, size := range sizes {
+		data := make([]byte, size)
+		_, _ = rand.Read(data)
+		h := md5.New()
+		er, stream, err := newSSEStreamReader(bytes.NewReader(data), dataKey, h)
+		require.NoError(t, err)
+		out, err := ioutil.ReadAll(er)
+		require.NoError(t, err)
+		stream.Size = er.Size()
+		require.Equal(t, int64(size), stream.Size)
+		require.Equal(t, sseCipherSize(int64(size)), int64(len(out)))
+		sum := md5.Sum(data)
+		require.Equal(t, hex.EncodeToString(sum[:]), hex.EncodeToString(h.Sum(nil)))
+
+		plain = append(plain, data...)
+		sealed = append(sealed, out...)
+		enc.Size += stream.Size
+		enc.Streams = append(enc.Streams, stream)
+	}
+	require.Equal(t, int64(len(sealed)), enc.cipherSize())
+
+	aead, err := newSSEAEAD(dataKey)
+	require.NoError(t, err)
+	read := func(offset, size int64) []byte {
+		var buf bytes.Buffer
+		for _, rr := range enc.readRanges(offset, size) {
+			dw := newSSEDecryptWriter(&buf, aead, rr.stream.Nonce, rr.index, rr.skip, rr.length)
+			_, err := dw.Write(sealed[rr.offset : rr.offset+rr.size])
+			require.NoError(t, err)
+			require.NoError(t, dw.Flush())
+		}
+		return buf.Bytes()
+	}
+	total := int64(len(plain))
+	require.Equal(t, plain, read(0, total))
+	for _, r := range [][2]int64{
+		{0, 1},
+		{sseSegmentSize - 1, 2},
+		{sseSegmentSize*2 + 50, sseSegmentSize},
+		{sseSegmentSize*3 + 100, 1},
+		{total - 10, 100},
+		{12345, total - 12345},
+	} {
+		end := r[0] + r[1]
+		if end > total {
+			end = total
+		}
+		require.Equal(t, plain[r[0]:end], read(r[0], r[1]), "range %v", r)
+	}
+
+	// tampered data can not be decrypted
+	sealed[10] ^= 0xff
+	dw := newSSEDecryptWriter(ioutil.Discard, aead, enc.Streams[0].Nonce, 0, 0, 1)
+	_, err = dw.Write(sealed[:sseCipherSegSize])
+	require.Equal(t, errSSEDecrypt, err)
+
+	parsed := parseObjectEncryption(enc.Encode())
+	require.Equal(t, enc, parsed)
+}
@@ -0,0 +1,173 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/storage-class-intro.html
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/restoring-objects.html
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+)
+
+// The storage classes are mapped onto the volume types:
+// STANDARD objects are served directly, which are backed by the replicas in the hot volume,
+// or by the blobstore and the replica cache in the cold volume.
+// GLACIER objects are archived in the blobstore of the cold volume, which must be restored before being read.
+// COLD is accepted as an alias of GLACIER.
+const (
+	StorageClassGlacier = "GLACIER"
+	StorageClassCold    = "COLD"
+)
+
+const (
+	MaxRestoreRequestSize = 4 << 10 // 4KB
+	MaxRestoreDays        = 30000
+)
+
+type RestoreRequest struct {
+	XMLName xml.Name `xml:"RestoreRequest"`
+	Days    int      `xml:"Days"`
+}
+
+// ObjectRestore is the restore status of an archived object, which is stored in the extended attributes.
+type ObjectRestore struct {
+	Ongoing     bool  `json:"ongoing"`
+	Days        int   `json:"days"`
+	RequestDate int64 `json:"request_date"`
+	ExpiryDate  int64 `json:"expiry_date,omitempty"`
+}
+
+func parseRestoreRequest(bytes []byte) (request *RestoreRequest, errCode *ErrorCode) {
+	request = &RestoreRequest{}
+	if err := xml.Unmarshal(bytes, request); err != nil {
+		return nil, MalformedXML
+	}
+	if request.Days <= 0 || request.Days > MaxRestoreDays {
+		return nil, InvalidArgument
+	}
+	return request, nil
+}
+
+func parseObjectRestore(raw string) *ObjectRestore {
+	if raw == "" {
+		return nil
+	}
+	var restore = &ObjectRestore{}
+	if err := json.Unmarshal([]byte(raw), restore); err != nil {
+		return nil
+	}
+	return restore
+}
+
+func (r *ObjectRestore) Encode() string {
+	data, _ := json.Marshal(r)
+	return string(data)
+}
+
+// restored reports whether the temporary copy of the archived object is available.
+func (r *ObjectRestore) restored(now time.Time) bool {
+	return r != nil && !r.Ongoing && now.Unix() < r.ExpiryDate
+}
+
+// header returns the value of the 'x-amz-restore' header, which is empty if the restored copy expires.
+func (r *ObjectRestore) header(now time.Time) string {
+	if r == nil {
+		return ""
+	}
+	if r.Ongoing {
+		return `ongoing-request="true"`
+	}
+	if !r.restored(now) {
+		return ""
+	}
+	return fmt.Sprintf(`ongoing-request="false", expiry-date="%s"`, formatTimeRFC1123(time.Unix(r.ExpiryDate, 0)))
+}
+
+// restoreExpiryDate returns the expiry date of the restored copy,
+// which is rounded to the midnight UTC of the day after the requested days as AWS S3 does.
+func restoreExpiryDate(now time.Time, days int) time.Time {
+	var year, month, day = now.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).AddDate(0, 0, days+1)
+}
+
+func isArchiveStorageClass(class string) bool {
+	return class == StorageClassGlacier || class == StorageClassCold
+}
+
+// parseStorageClass returns the storage class specified by the 'x-amz-storage-class' header,
+// the archive classes are only available for the cold volume which stores the data in the blobstore.
+func parseStorageClass(vol *Volume, header http.Header) (class string, err error) {
+	switch class = header.Get(HeaderNameXAmzStorageClass); class {
+	case "", StorageClassStandard:
+		return
+	case StorageClassGlacier, StorageClassCold:
+		if !proto.IsCold(vol.volType) {
+			return "", InvalidStorageClass
+		}
+		return StorageClassGlacier, nil
+	default:
+		return "", InvalidStorageClass
+	}
+}
+
+// setStorageClassAttr records the storage class in the extended attributes,
+// the standard class is the default one and is not recorded.
+func setStorageClassAttr(attrs map[string]string, class string) {
+	if isArchiveStorageClass(class) {
+		attrs[XAttrKeyOSSStorageClass] = class
+		return
+	}
+	delete(attrs, XAttrKeyOSSStorageClass)
+}
+
+func storageClassFromXAttr(xattr *proto.XAttrInfo) string {
+	if xattr != nil {
+		if class := string(xattr.Get(XAttrKeyOSSStorageClass)); class != "" {
+			return class
+		}
+	}
+	return StorageClassStandard
+}
+
+// checkObjectRestored returns InvalidObjectState if the archived object is not restored.
+func checkObjectRestored(info *FSFileInfo) *ErrorCode {
+	if isArchiveStorageClass(info.StorageClass) && !info.Restore.restored(time.Now()) {
+		return InvalidObjectState
+	}
+	return nil
+}
+
+func setStorageClassHeaders(header http.Header, info *FSFileInfo) {
+	// The header is not returned for the standard class.
+	if info.StorageClass != "" && info.StorageClass != StorageClassStandard {
+		header[HeaderNameXAmzStorageClass] = []string{info.StorageClass}
+	}
+	if restore := info.Restore.header(time.Now()); restore != "" {
+		header[HeaderNameXAmzRestore] = []string{restore}
+	}
+}
+
+func multipartStorageClass(info *proto.MultipartInfo) string {
+	if class := info.Extend[XAttrKeyOSSStorageClass]; class != "" {
+		return class
+	}
+	return StorageClassStandard
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStorageClass(t *testing.T) {
+	hot := &Volume{volType: proto.VolumeTypeHot}
+	cold := &Volume{volType: proto.VolumeTypeCold}
+	for _, c := range []struct {
+		vol      *Volume
+		value    string
+		expected string
+		err      error
+	}{
+		{hot, "", "", nil},
+		{hot, StorageClassStandard, StorageClassStandard, nil},
+		{hot, StorageClassGlacier, "", InvalidStorageClass},
+		{hot, StorageClassCold, "", InvalidStorageClass},
+		{cold, "", "", nil},
+		{cold, StorageClassStandard, StorageClassStandard, nil},
+		{cold, StorageClassGlacier, StorageClassGlacier, nil},
+		{cold, StorageClassCold, StorageClassGlacier, nil},
+		{cold, "STANDARD_IA", "", InvalidStorageClass},
+	} {
+		header := http.Header{}
+		if c.value != "" {
+			header.Set(HeaderNameXAmzStorageClass, c.value)
+		}
+		class, err := parseStorageClass(c.vol, header)
+		require.Equal(t, c.err, err, c.value)
+		require.Equal(t, c.expected, class, c.value)
+	}
+
+	attrs := map[string]string{XAttrKeyOSSStorageClass: StorageClassGlacier}
+	setStorageClassAttr(attrs, StorageClassStandard)
+	require.NotContains(t, attrs, XAttrKeyOSSStorageClass)
+	setStorageClassAttr(attrs, StorageClassGlacier)
+	require.Equal(t, StorageClassGlacier, storageClassFromXAttr(&proto.XAttrInfo{XAttrs: attrs}))
+	require.Equal(t, StorageClassStandard, storageClassFromXAttr(&proto.XAttrInfo{XAttrs: map[string]string{}}))
+}
+
+func TestParseRestoreRequest(t *testing.T) {
+	request, errCode := parseRestoreRequest([]byte(`<RestoreRequest xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Days>2</Days></RestoreRequest>`))
+	require.Nil(t, errCode)
+	require.Equal(t, 2, request.Days)
+
+	_, errCode = parseRestoreRequest([]byte(`<RestoreRequest><Days>two</Days></RestoreRequest>`))
+	require.Equal(t, MalformedXML, errCode)
+	_, errCode = parseRestoreRequest([]byte(`<RestoreRequest></RestoreRequest>`))
+	require.Equal(t, InvalidArgument, errCode)
+	_, errCode = parseRestoreRequest([]byte(`<RestoreRequest><Days>-1</Days></RestoreRequest>`))
+	require.Equal(t, InvalidArgument, errCode)
+}
+
+func TestObjectRestore(t *testing.T) {
+	now := time.Date(2023, 5, 10, 15, 4, 5, 0, time.UTC)
+	expiry := restoreExpiryDate(now, 2)
+	require.Equal(t, time.Date(2023, 5, 13, 0, 0, 0, 0, time.UTC), expiry)
+
+	// the archived object is not readable until the restore is finished
+	info := &FSFileInfo{StorageClass: StorageClassGlacier}
+	require.Equal(t, InvalidObjectState, checkObjectRestored(info))
+	info.Restore = &ObjectRestore{Ongoing: true, Days: 2, RequestDate: now.Unix()}
+	require.Equal(t, InvalidObjectState, checkObjectRestored(info))
+	require.Equal(t, `ongoing-request="true"`, info.Restore.header(now))
+
+	info.Restore = &ObjectRestore{Days: 2, ExpiryDate: restoreExpiryDate(time.Now(), 2).Unix()}
+	require.Nil(t, checkObjectRestored(info))
+
+	info.Restore = parseObjectRestore((&ObjectRestore{Days: 2, RequestDate: now.Unix(), ExpiryDate: expiry.Unix()}).Encode())
+	require.NotNil(t, info.Restore)
+	require.True(t, info.Restore.restored(now))
+	require.Equal(t, `ongoing-request="false", expiry-date="Sat, 13 May 2023 00:00:00 GMT"`, info.Restore.header(now))
+
+	// the restored copy expires
+	require.False(t, info.Restore.restored(expiry))
+	require.Empty(t, info.Restore.header(expiry))
+
+	// the standard object is always readable
+	require.Nil(t, checkObjectRestored(&FSFileInfo{StorageClass: StorageClassStandard}))
+
+	header := http.Header{}
+	setStorageClassHeaders(header, &FSFileInfo{StorageClass: StorageClassStandard})
+	require.Empty(t, header)
+	setStorageClassHeaders(header, &FSFileInfo{StorageClass: StorageClassGlacier, Restore: &ObjectRestore{Ongoing: true}})
+	require.Equal(t, []string{StorageClassGlacier}, header[HeaderNameXAmzStorageClass])
+	require.Equal(t, []string{`ongoing-request="true"`}, header[HeaderNameXAmzRestore])
+}
@@ -20,6 +20,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"regexp"
 	"strings"
 	"time"
@@ -334,3 +335,23 @@ func GetMD5(b []byte) string {
 	hash.Write(b)
 	return base64.StdEncoding.EncodeToString(hash.Sum(nil))
 }
+
+// writeFileAtomic writes the data to the temporary file and renames it to the file after it is synced,
+// so the file is either complete or absent after a crash.
+func writeFileAtomic(tmp, file string, data []byte) (err error) {
+	var f *os.File
+	if f, err = os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644); err != nil {
+		return
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return
+	}
+	return os.Rename(tmp, file)
+}
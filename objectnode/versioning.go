@@ -0,0 +1,58 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/Versioning.html
+
+import (
+	"encoding/xml"
+)
+
+const (
+	VersioningStatusEnabled   = "Enabled"
+	VersioningStatusSuspended = "Suspended"
+
+	// NullVersionId is the version ID of the objects which are written while versioning
+	// is not enabled on the bucket.
+	NullVersionId = "null"
+)
+
+type VersioningConfiguration struct {
+	XMLName xml.Name `xml:"VersioningConfiguration"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+func NewVersioningConfiguration() *VersioningConfiguration {
+	return &VersioningConfiguration{
+		XMLName: xml.Name{Local: "VersioningConfiguration"},
+	}
+}
+
+func parseVersioningConfig(bytes []byte) (config *VersioningConfiguration, errCode *ErrorCode) {
+	config = NewVersioningConfiguration()
+	if err := xml.Unmarshal(bytes, config); err != nil {
+		return nil, MalformedXML
+	}
+	// Once versioning is enabled, a bucket can never return to the unversioned state,
+	// it can only be suspended.
+	if config.Status != VersioningStatusEnabled && config.Status != VersioningStatusSuspended {
+		return nil, MalformedXML
+	}
+	return config, nil
+}
+
+func storeBucketVersioning(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSVersioning, bytes)
+}
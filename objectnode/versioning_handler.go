@@ -0,0 +1,304 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	MaxVersioningConfigSize = 1 << 10 // 1KB
+)
+
+// Get bucket versioning
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketVersioning.html
+func (o *ObjectNode) getBucketVersioningHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getBucketVersioningHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var versioning *VersioningConfiguration
+	if versioning, err = vol.metaLoader.loadVersioning(); err != nil {
+		log.LogErrorf("getBucketVersioningHandler: load versioning fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	// A bucket which has never been versioning-enabled responds an empty configuration.
+	if versioning == nil {
+		versioning = NewVersioningConfiguration()
+	}
+
+	var data []byte
+	if data, err = MarshalXMLEntity(versioning); err != nil {
+		log.LogErrorf("getBucketVersioningHandler: xml marshal fail: requestID(%v) volume(%v) versioning(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), versioning, err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	if _, err = w.Write(data); err != nil {
+		log.LogErrorf("getBucketVersioningHandler: write response body fail: requestID(%v) volume(%v) body(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(data), err)
+	}
+	return
+}
+
+// Put bucket versioning
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketVersioning.html
+func (o *ObjectNode) putBucketVersioningHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("putBucketVersioningHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, MaxVersioningConfigSize+1)); err != nil {
+		log.LogErrorf("putBucketVersioningHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxVersioningConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+
+	var versioning *VersioningConfiguration
+	if versioning, errorCode = parseVersioningConfig(body); errorCode != nil {
+		log.LogErrorf("putBucketVersioningHandler: parse versioning config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), errorCode)
+		return
+	}
+	// Versioning can not be suspended for the buckets which object lock is enabled or
+	// replication is configured.
+	if versioning.Status == VersioningStatusSuspended {
+		var objectLock *ObjectLockConfiguration
+		if objectLock, err = vol.metaLoader.loadObjectLock(); err != nil {
+			log.LogErrorf("putBucketVersioningHandler: load object lock fail: requestID(%v) volume(%v) err(%v)",
+				GetRequestID(r), vol.Name(), err)
+			return
+		}
+		var replication *ReplicationConfiguration
+		if replication, err = vol.metaLoader.loadReplication(); err != nil {
+			log.LogErrorf("putBucketVersioningHandler: load replication fail: requestID(%v) volume(%v) err(%v)",
+				GetRequestID(r), vol.Name(), err)
+			return
+		}
+		if objectLock.enabled() || replication != nil {
+			errorCode = InvalidBucketState
+			return
+		}
+	}
+	if err = storeBucketVersioning(body, vol); err != nil {
+		log.LogErrorf("putBucketVersioningHandler: store versioning config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	vol.metaLoader.storeVersioning(versioning)
+
+	return
+}
+
+// List object versions
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectVersions.html
+func (o *ObjectNode) listObjectVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("listObjectVersionsHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	// get options
+	prefix := r.URL.Query().Get(ParamPrefix)
+	delimiter := r.URL.Query().Get(ParamPartDelimiter)
+	keyMarker := r.URL.Query().Get(ParamKeyMarker)
+	versionIdMarker := r.URL.Query().Get(ParamVersionIdMarker)
+	maxKeys := r.URL.Query().Get(ParamMaxKeys)
+	encodingType := r.URL.Query().Get(ParamEncodingType)
+
+	var maxKeysInt uint64
+	if maxKeys != "" {
+		maxKeysInt, err = strconv.ParseUint(maxKeys, 10, 16)
+		if err != nil {
+			log.LogErrorf("listObjectVersionsHandler: parse max key fail: requestID(%v) volume(%v) maxKeys(%v) err(%v)",
+				GetRequestID(r), vol.Name(), maxKeys, err)
+			errorCode = InvalidArgument
+			return
+		}
+		if maxKeysInt > MaxKeys {
+			maxKeysInt = MaxKeys
+		}
+	} else {
+		maxKeysInt = uint64(MaxKeys)
+	}
+
+	if encodingType != "" && encodingType != "url" {
+		errorCode = InvalidArgument
+		return
+	}
+	// A version-id-marker is meaningless without a key-marker.
+	if versionIdMarker != "" && keyMarker == "" {
+		errorCode = InvalidArgument
+		return
+	}
+	if keyMarker != "" && prefix != "" && !strings.HasPrefix(keyMarker, prefix) {
+		errorCode = InvalidArgument
+		return
+	}
+
+	var option = &ListObjectVersionsOption{
+		Prefix:          prefix,
+		Delimiter:       delimiter,
+		KeyMarker:       keyMarker,
+		VersionIdMarker: versionIdMarker,
+		MaxKeys:         maxKeysInt,
+	}
+	var result *ListObjectVersionsResult
+	if result, err = vol.ListObjectVersions(option); err != nil {
+		log.LogErrorf("listObjectVersionsHandler: list object versions fail: requestID(%v) volume(%v) option(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), option, err)
+		return
+	}
+
+	var bucketOwner = NewBucketOwner(vol)
+	var versions = make([]*ObjectVersionEntry, 0, len(result.Versions))
+	var deleteMarkers = make([]*DeleteMarkerEntry, 0)
+	for _, info := range result.Versions {
+		if info.DeleteMarker {
+			deleteMarkers = append(deleteMarkers, &DeleteMarkerEntry{
+				Key:          encodeKey(info.Path, encodingType),
+				VersionId:    info.VersionId,
+				IsLatest:     info.IsLatest,
+				LastModified: formatTimeISO(info.ModifyTime),
+				Owner:        bucketOwner,
+			})
+			continue
+		}
+		if info.Mode == 0 {
+			// Invalid file mode, which means that the inode of the version may not exist.
+			log.LogWarnf("listObjectVersionsHandler: invalid version found: requestID(%v) volume(%v) path(%v) version(%v) inode(%v)",
+				GetRequestID(r), vol.Name(), info.Path, info.VersionId, info.Inode)
+			continue
+		}
+		versions = append(versions, &ObjectVersionEntry{
+			Key:          encodeKey(info.Path, encodingType),
+			VersionId:    info.VersionId,
+			IsLatest:     info.IsLatest,
+			LastModified: formatTimeISO(info.ModifyTime),
+			ETag:         wrapUnescapedQuot(info.ETag),
+			Size:         int(info.Size),
+			StorageClass: info.StorageClass,
+			Owner:        bucketOwner,
+		})
+	}
+	var commonPrefixes = make([]*CommonPrefix, 0, len(result.CommonPrefixes))
+	for _, prefix := range result.CommonPrefixes {
+		commonPrefixes = append(commonPrefixes, &CommonPrefix{Prefix: prefix})
+	}
+
+	listVersionsResult := &ListVersionsResult{
+		Bucket:          param.Bucket(),
+		Prefix:          prefix,
+		KeyMarker:       keyMarker,
+		VersionIdMarker: versionIdMarker,
+		MaxKeys:         int(maxKeysInt),
+		Delimiter:       delimiter,
+		IsTruncated:     result.Truncated,
+		Versions:        versions,
+		DeleteMarkers:   deleteMarkers,
+		CommonPrefixes:  commonPrefixes,
+	}
+	if result.Truncated {
+		listVersionsResult.NextKeyMarker = result.NextKeyMarker
+		listVersionsResult.NextVersionIdMarker = result.NextVersionIdMarker
+	}
+
+	var bytes []byte
+	if bytes, err = MarshalXMLEntity(listVersionsResult); err != nil {
+		log.LogErrorf("listObjectVersionsHandler: marshal result fail: requestID(%v) volume(%v) result(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), listVersionsResult, err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	if _, err = w.Write(bytes); err != nil {
+		log.LogErrorf("listObjectVersionsHandler: write response body fail: requestID(%v) volume(%v) body(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(bytes), err)
+	}
+	return
+}
+
+// checkDeleteMarker responds the delete marker headers if the object version is a delete marker.
+// Reading the latest version which is a delete marker behaves as the object does not exist, and
+// reading a delete marker by its version ID is not allowed.
+func checkDeleteMarker(w http.ResponseWriter, fileInfo *FSFileInfo, versionId string) *ErrorCode {
+	if !fileInfo.DeleteMarker {
+		return nil
+	}
+	w.Header()[HeaderNameXAmzDeleteMarker] = []string{"true"}
+	w.Header()[HeaderNameXAmzVersionId] = []string{fileInfo.VersionId}
+	if versionId != "" {
+		return MethodNotAllowed
+	}
+	return NoSuchKey
+}
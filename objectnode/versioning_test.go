@@ -0,0 +1,65 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersioningConfig(t *testing.T) {
+	config, errCode := parseVersioningConfig([]byte(`<VersioningConfiguration><Status>Enabled</Status></VersioningConfiguration>`))
+	require.Nil(t, errCode)
+	require.Equal(t, VersioningStatusEnabled, config.Status)
+
+	config, errCode = parseVersioningConfig([]byte(`<VersioningConfiguration><Status>Suspended</Status></VersioningConfiguration>`))
+	require.Nil(t, errCode)
+	require.Equal(t, VersioningStatusSuspended, config.Status)
+
+	_, errCode = parseVersioningConfig([]byte(`<VersioningConfiguration></VersioningConfiguration>`))
+	require.Equal(t, MalformedXML, errCode)
+	_, errCode = parseVersioningConfig([]byte(`<VersioningConfiguration><Status>Disabled</Status></VersioningConfiguration>`))
+	require.Equal(t, MalformedXML, errCode)
+	_, errCode = parseVersioningConfig([]byte(`<VersioningConfiguration>`))
+	require.Equal(t, MalformedXML, errCode)
+}
+
+func TestObjectVersions(t *testing.T) {
+	var versions ObjectVersions
+	versions = versions.prepend(&ObjectVersion{VersionId: NullVersionId, Inode: 1})
+	versions = versions.prepend(&ObjectVersion{VersionId: "v2", Inode: 2})
+	versions = versions.prepend(&ObjectVersion{VersionId: "v3", DeleteMarker: true})
+	require.Equal(t, 3, len(versions))
+	require.Equal(t, 0, versions.find("v3"))
+	require.Equal(t, 2, versions.find(NullVersionId))
+	require.Equal(t, -1, versions.find("v4"))
+
+	versions = versions.remove(versions.find("v2"))
+	require.Equal(t, 2, len(versions))
+	require.Equal(t, "v3", versions[0].VersionId)
+	require.Equal(t, NullVersionId, versions[1].VersionId)
+}
+
+func TestObjectVersionsName(t *testing.T) {
+	var prefix = objectVersionsPrefix("a.txt")
+	name, ok := objectVersionsName(prefix + "0000000000000001")
+	require.True(t, ok)
+	require.Equal(t, "a.txt", name)
+	_, ok = objectVersionsName(XAttrKeyOSSVersionsPrefix + "a.txt")
+	require.False(t, ok)
+	_, ok = objectVersionsName("user.a/b")
+	require.False(t, ok)
+}
@@ -0,0 +1,248 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/WebsiteHosting.html
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	WebsiteProtocolHTTP  = "http"
+	WebsiteProtocolHTTPS = "https"
+
+	MaxWebsiteRoutingRules = 50
+)
+
+type WebsiteConfiguration struct {
+	XMLName               xml.Name               `xml:"WebsiteConfiguration" json:"-"`
+	ErrorDocument         *ErrorDocument         `xml:"ErrorDocument,omitempty" json:"error_document,omitempty"`
+	IndexDocument         *IndexDocument         `xml:"IndexDocument,omitempty" json:"index_document,omitempty"`
+	RedirectAllRequestsTo *RedirectAllRequestsTo `xml:"RedirectAllRequestsTo,omitempty" json:"redirect_all_requests_to,omitempty"`
+	RoutingRules          *RoutingRules          `xml:"RoutingRules,omitempty" json:"routing_rules,omitempty"`
+}
+
+type ErrorDocument struct {
+	Key string `xml:"Key" json:"key"`
+}
+
+type IndexDocument struct {
+	Suffix string `xml:"Suffix" json:"suffix"`
+}
+
+type RedirectAllRequestsTo struct {
+	HostName string `xml:"HostName" json:"host_name"`
+	Protocol string `xml:"Protocol,omitempty" json:"protocol,omitempty"`
+}
+
+type RoutingRules struct {
+	Rules []*RoutingRule `xml:"RoutingRule" json:"rules"`
+}
+
+type RoutingRule struct {
+	Condition *RoutingCondition `xml:"Condition,omitempty" json:"condition,omitempty"`
+	Redirect  *RoutingRedirect  `xml:"Redirect" json:"redirect"`
+}
+
+type RoutingCondition struct {
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty" json:"http_error_code_returned_equals,omitempty"`
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty" json:"key_prefix_equals,omitempty"`
+}
+
+type RoutingRedirect struct {
+	HostName             string  `xml:"HostName,omitempty" json:"host_name,omitempty"`
+	HttpRedirectCode     string  `xml:"HttpRedirectCode,omitempty" json:"http_redirect_code,omitempty"`
+	Protocol             string  `xml:"Protocol,omitempty" json:"protocol,omitempty"`
+	ReplaceKeyPrefixWith *string `xml:"ReplaceKeyPrefixWith" json:"replace_key_prefix_with,omitempty"`
+	ReplaceKeyWith       *string `xml:"ReplaceKeyWith" json:"replace_key_with,omitempty"`
+}
+
+func NewWebsiteConfiguration() *WebsiteConfiguration {
+	return &WebsiteConfiguration{
+		XMLName: xml.Name{Local: "WebsiteConfiguration"},
+	}
+}
+
+func parseWebsiteConfig(bytes []byte) (config *WebsiteConfiguration, errCode *ErrorCode) {
+	config = NewWebsiteConfiguration()
+	if err := xml.Unmarshal(bytes, config); err != nil {
+		return nil, MalformedXML
+	}
+	if errCode = config.validate(); errCode != nil {
+		return nil, errCode
+	}
+	return config, nil
+}
+
+func isValidWebsiteProtocol(protocol string) bool {
+	return protocol == "" || protocol == WebsiteProtocolHTTP || protocol == WebsiteProtocolHTTPS
+}
+
+func (config *WebsiteConfiguration) validate() *ErrorCode {
+	if redirect := config.RedirectAllRequestsTo; redirect != nil {
+		if config.IndexDocument != nil || config.ErrorDocument != nil || config.RoutingRules != nil {
+			return NewError("InvalidArgument", "RedirectAllRequestsTo cannot be provided in conjunction with other Routing/Redirect configurations.", 400)
+		}
+		if redirect.HostName == "" {
+			return NewError("InvalidArgument", "A host name must be provided in RedirectAllRequestsTo.", 400)
+		}
+		if !isValidWebsiteProtocol(redirect.Protocol) {
+			return NewError("InvalidArgument", "Invalid protocol, protocol can be http or https.", 400)
+		}
+		return nil
+	}
+	if config.IndexDocument == nil {
+		return NewError("InvalidArgument", "A value for IndexDocument Suffix must be provided if RedirectAllRequestsTo is empty.", 400)
+	}
+	if suffix := config.IndexDocument.Suffix; suffix == "" || strings.Contains(suffix, "/") {
+		return NewError("InvalidArgument", "The IndexDocument Suffix is not well formed.", 400)
+	}
+	if config.ErrorDocument != nil && config.ErrorDocument.Key == "" {
+		return NewError("InvalidArgument", "The ErrorDocument Key is not well formed.", 400)
+	}
+	if config.RoutingRules == nil {
+		return nil
+	}
+	if len(config.RoutingRules.Rules) > MaxWebsiteRoutingRules {
+		return NewError("InvalidArgument", "The number of routing rules should not exceed allowed limit of 50.", 400)
+	}
+	for _, rule := range config.RoutingRules.Rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rule *RoutingRule) validate() *ErrorCode {
+	if cond := rule.Condition; cond != nil {
+		if cond.HttpErrorCodeReturnedEquals == "" && cond.KeyPrefixEquals == "" {
+			return NewError("InvalidArgument", "Condition cannot be empty. To redirect all requests without a condition, the condition element shouldn't be present.", 400)
+		}
+		if cond.HttpErrorCodeReturnedEquals != "" {
+			if code, err := strconv.Atoi(cond.HttpErrorCodeReturnedEquals); err != nil || code < 400 || code > 599 {
+				return NewError("InvalidArgument", "The provided HTTP error code is not valid. Valid codes are 4XX or 5XX.", 400)
+			}
+		}
+	}
+	var redirect = rule.Redirect
+	if redirect == nil {
+		return NewError("InvalidArgument", "Redirect must be specified for each routing rule.", 400)
+	}
+	if redirect.ReplaceKeyPrefixWith != nil && redirect.ReplaceKeyWith != nil {
+		return NewError("InvalidArgument", "You can only define ReplaceKeyPrefix or ReplaceKey but not both.", 400)
+	}
+	if !isValidWebsiteProtocol(redirect.Protocol) {
+		return NewError("InvalidArgument", "Invalid protocol, protocol can be http or https.", 400)
+	}
+	if redirect.HttpRedirectCode != "" {
+		if code, err := strconv.Atoi(redirect.HttpRedirectCode); err != nil || code < 300 || code > 399 {
+			return NewError("InvalidArgument", "The provided HTTP redirect code is not valid. It should be a string containing a number in 3XX.", 400)
+		}
+	}
+	return nil
+}
+
+// indexKey returns the key of the index document if the key addresses a directory.
+func (config *WebsiteConfiguration) indexKey(key string) string {
+	if key == "" || strings.HasSuffix(key, "/") {
+		return key + config.IndexDocument.Suffix
+	}
+	return key
+}
+
+// matchRoutingRule returns the first routing rule which applies to the request of the key.
+// The rules with the error code condition only apply after the request fails with the status code,
+// and the others only apply before the object is read.
+func (config *WebsiteConfiguration) matchRoutingRule(key string, status int) *RoutingRule {
+	if config.RoutingRules == nil {
+		return nil
+	}
+	for _, rule := range config.RoutingRules.Rules {
+		var cond = rule.Condition
+		if cond == nil {
+			if status == 0 {
+				return rule
+			}
+			continue
+		}
+		if !strings.HasPrefix(key, cond.KeyPrefixEquals) {
+			continue
+		}
+		if (cond.HttpErrorCodeReturnedEquals == "" && status == 0) ||
+			(cond.HttpErrorCodeReturnedEquals != "" && cond.HttpErrorCodeReturnedEquals == strconv.Itoa(status)) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// requestProtocol returns the protocol which the client uses to send the request.
+func requestProtocol(r *http.Request) string {
+	if forwarded := r.Header.Get(HeaderNameXForwardedProto); forwarded != "" {
+		return forwarded
+	}
+	if r.TLS != nil {
+		return WebsiteProtocolHTTPS
+	}
+	return WebsiteProtocolHTTP
+}
+
+// location returns the redirect location of the request of the key, and the redirect status code.
+func (rule *RoutingRule) location(r *http.Request, key string) (location string, code int) {
+	var redirect = rule.Redirect
+	var protocol, host = redirect.Protocol, redirect.HostName
+	if protocol == "" {
+		protocol = requestProtocol(r)
+	}
+	if host == "" {
+		host = r.Host
+	}
+	if redirect.ReplaceKeyWith != nil {
+		key = *redirect.ReplaceKeyWith
+	} else if redirect.ReplaceKeyPrefixWith != nil {
+		var prefix string
+		if rule.Condition != nil {
+			prefix = rule.Condition.KeyPrefixEquals
+		}
+		key = *redirect.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+	}
+	code = http.StatusMovedPermanently
+	if redirect.HttpRedirectCode != "" {
+		code, _ = strconv.Atoi(redirect.HttpRedirectCode)
+	}
+	return protocol + "://" + host + "/" + key, code
+}
+
+// location returns the location which the request is redirected to.
+func (redirect *RedirectAllRequestsTo) location(r *http.Request) string {
+	var protocol = redirect.Protocol
+	if protocol == "" {
+		protocol = requestProtocol(r)
+	}
+	return protocol + "://" + redirect.HostName + r.URL.RequestURI()
+}
+
+func storeBucketWebsite(bytes []byte, vol *Volume) (err error) {
+	return vol.store.Put(vol.name, bucketRootPath, XAttrKeyOSSWebsite, bytes)
+}
+
+func deleteBucketWebsite(vol *Volume) (err error) {
+	return vol.store.Delete(vol.name, bucketRootPath, XAttrKeyOSSWebsite)
+}
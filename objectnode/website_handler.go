@@ -0,0 +1,355 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package objectnode
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/cubefs/cubefs/util/log"
+	"github.com/gorilla/mux"
+)
+
+const (
+	MaxWebsiteConfigSize = 64 << 10 // 64KB
+
+	maxWebsiteErrorBodySize = 4 << 10
+)
+
+// Get bucket website
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketWebsite.html
+func (o *ObjectNode) getBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("getBucketWebsiteHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var website *WebsiteConfiguration
+	if website, err = vol.metaLoader.loadWebsite(); err != nil {
+		log.LogErrorf("getBucketWebsiteHandler: load website fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if website == nil {
+		errorCode = NoSuchWebsiteConfiguration
+		return
+	}
+
+	var data []byte
+	if data, err = MarshalXMLEntity(website); err != nil {
+		log.LogErrorf("getBucketWebsiteHandler: xml marshal fail: requestID(%v) volume(%v) website(%+v) err(%v)",
+			GetRequestID(r), vol.Name(), website, err)
+		return
+	}
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeXML}
+	if _, err = w.Write(data); err != nil {
+		log.LogErrorf("getBucketWebsiteHandler: write response body fail: requestID(%v) volume(%v) body(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(data), err)
+	}
+	return
+}
+
+// Put bucket website
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketWebsite.html
+func (o *ObjectNode) putBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("putBucketWebsiteHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, MaxWebsiteConfigSize+1)); err != nil {
+		log.LogErrorf("putBucketWebsiteHandler: read request body fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	if len(body) > MaxWebsiteConfigSize {
+		errorCode = EntityTooLarge
+		return
+	}
+	if md5 := r.Header.Get(HeaderNameContentMD5); md5 != "" && md5 != GetMD5(body) {
+		errorCode = InvalidDigest
+		return
+	}
+
+	var website *WebsiteConfiguration
+	if website, errorCode = parseWebsiteConfig(body); errorCode != nil {
+		log.LogErrorf("putBucketWebsiteHandler: parse website config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), errorCode)
+		return
+	}
+	if err = storeBucketWebsite(body, vol); err != nil {
+		log.LogErrorf("putBucketWebsiteHandler: store website config fail: requestID(%v) volume(%v) config(%v) err(%v)",
+			GetRequestID(r), vol.Name(), string(body), err)
+		return
+	}
+	vol.metaLoader.storeWebsite(website)
+
+	return
+}
+
+// Delete bucket website
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketWebsite.html
+func (o *ObjectNode) deleteBucketWebsiteHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err       error
+		errorCode *ErrorCode
+	)
+	defer func() {
+		o.errorResponse(w, r, err, errorCode)
+	}()
+
+	var param = ParseRequestParam(r)
+	if param.Bucket() == "" {
+		errorCode = InvalidBucketName
+		return
+	}
+	var vol *Volume
+	if vol, err = o.getVol(param.Bucket()); err != nil {
+		log.LogErrorf("deleteBucketWebsiteHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), param.Bucket(), err)
+		return
+	}
+	if err = deleteBucketWebsite(vol); err != nil {
+		log.LogErrorf("deleteBucketWebsiteHandler: delete website config fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), vol.Name(), err)
+		return
+	}
+	vol.metaLoader.storeWebsite(nil)
+	w.WriteHeader(http.StatusNoContent)
+
+	return
+}
+
+// websiteResponseWriter intercepts the error response of the object handlers, so that the website
+// endpoint can respond with the routing rules or the error document instead.
+type websiteResponseWriter struct {
+	http.ResponseWriter
+	status      int // the status code which overrides the successful one, used by the error document
+	errStatus   int // the status code of the intercepted error response
+	errBody     bytes.Buffer
+	wroteHeader bool
+}
+
+func (w *websiteResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if code >= http.StatusBadRequest {
+		w.errStatus = code
+		return
+	}
+	if w.status != 0 && code == http.StatusOK {
+		code = w.status
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *websiteResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.errStatus != 0 {
+		if w.errBody.Len() < maxWebsiteErrorBodySize {
+			w.errBody.Write(p)
+		}
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// errorCode returns the error code of the intercepted error response.
+func (w *websiteResponseWriter) errorCode() *ErrorCode {
+	var xmlError = struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}{}
+	if err := xml.Unmarshal(w.errBody.Bytes(), &xmlError); err != nil || xmlError.Code == "" {
+		return HttpStatusErrorCode(w.errStatus)
+	}
+	return &ErrorCode{ErrorCode: xmlError.Code, ErrorMessage: xmlError.Message, StatusCode: w.errStatus}
+}
+
+// serveWebsiteObject reads the object of the key with the object handler of the request method,
+// checking the bucket policy and ACL as the anonymous request does. If the status is not zero, it
+// overrides the status code of the successful response. The error response is intercepted and
+// returned, and the response headers are restored in that case.
+func (o *ObjectNode) serveWebsiteObject(w http.ResponseWriter, r *http.Request, key string, status int) *ErrorCode {
+	var handler = o.getObjectHandler
+	if r.Method == http.MethodHead {
+		handler = o.headObjectHandler
+	}
+	var header = w.Header().Clone()
+	var writer = &websiteResponseWriter{ResponseWriter: w, status: status}
+	mux.Vars(r)["object"] = key
+	o.policyCheck(handler)(writer, r)
+	if writer.errStatus == 0 {
+		return nil
+	}
+	for name := range w.Header() {
+		delete(w.Header(), name)
+	}
+	for name, values := range header {
+		w.Header()[name] = values
+	}
+	return writer.errorCode()
+}
+
+// writeWebsiteError writes the error response of the website endpoint as an HTML page.
+func writeWebsiteError(w http.ResponseWriter, r *http.Request, ec *ErrorCode) {
+	SetResponseStatusCode(r, *ec)
+	SetResponseErrorMessage(r, ec.ErrorMessage)
+	var status = fmt.Sprintf("%d %s", ec.StatusCode, http.StatusText(ec.StatusCode))
+	w.Header()[HeaderNameContentType] = []string{HeaderValueContentTypeHTML}
+	w.WriteHeader(ec.StatusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "<html>\n<head><title>%s</title></head>\n<body>\n<h1>%s</h1>\n<ul>\n"+
+		"<li>Code: %s</li>\n<li>Message: %s</li>\n<li>RequestId: %s</li>\n</ul>\n<hr/>\n</body>\n</html>\n",
+		status, status, html.EscapeString(ec.ErrorCode), html.EscapeString(ec.ErrorMessage), GetRequestID(r))
+}
+
+func websiteRedirect(w http.ResponseWriter, r *http.Request, location string, code int) {
+	w.Header()[HeaderNameLocation] = []string{location}
+	w.WriteHeader(code)
+}
+
+// websiteMiddleware returns a pre-handle middleware handler of the website endpoint, which resolves
+// the bucket from the host if it is not a subdomain of the website domains, and makes the request
+// anonymous since the website endpoint does not authenticate the requests.
+func (o *ObjectNode) websiteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if vars := mux.Vars(r); vars["bucket"] == "" {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			vars["bucket"] = host
+		}
+		r.Header.Del(HeaderNameAuthorization)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Serve the static website of the bucket
+// API reference: https://docs.aws.amazon.com/AmazonS3/latest/userguide/WebsiteEndpoints.html
+// Notes: The website endpoint only serves the anonymous GET and HEAD requests. The bucket is resolved
+// from the host, which is either a subdomain of the website domains or the bucket name itself.
+func (o *ObjectNode) websiteHandler(w http.ResponseWriter, r *http.Request) {
+	var bucket = mux.Vars(r)["bucket"]
+
+	vol, err := o.getVol(bucket)
+	if err != nil {
+		log.LogErrorf("websiteHandler: load volume fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), bucket, err)
+		if ec, ok := err.(*ErrorCode); ok {
+			writeWebsiteError(w, r, ec)
+		} else {
+			writeWebsiteError(w, r, InternalErrorCode(err))
+		}
+		return
+	}
+	var website *WebsiteConfiguration
+	if website, err = vol.metaLoader.loadWebsite(); err != nil {
+		log.LogErrorf("websiteHandler: load website fail: requestID(%v) volume(%v) err(%v)",
+			GetRequestID(r), bucket, err)
+		writeWebsiteError(w, r, InternalErrorCode(err))
+		return
+	}
+	if website == nil {
+		writeWebsiteError(w, r, NoSuchWebsiteConfiguration)
+		return
+	}
+	if website.RedirectAllRequestsTo != nil {
+		websiteRedirect(w, r, website.RedirectAllRequestsTo.location(r), http.StatusMovedPermanently)
+		return
+	}
+
+	// The query parameters of the object APIs are ignored.
+	r.URL.RawQuery = ""
+
+	var key = strings.TrimPrefix(r.URL.Path, "/")
+	if rule := website.matchRoutingRule(key, 0); rule != nil {
+		location, code := rule.location(r, key)
+		websiteRedirect(w, r, location, code)
+		return
+	}
+	var ec = o.serveWebsiteObject(w, r, website.indexKey(key), 0)
+	if ec == nil {
+		return
+	}
+	log.LogDebugf("websiteHandler: serve object fail: requestID(%v) volume(%v) key(%v) status(%v) code(%v)",
+		GetRequestID(r), bucket, key, ec.StatusCode, ec.ErrorCode)
+
+	// A request of the directory without the trailing slash is redirected to the directory, so that
+	// the relative links in the index document work.
+	if ec.StatusCode == http.StatusNotFound && website.indexKey(key) == key {
+		if _, _, err = vol.ObjectMeta(key + "/" + website.IndexDocument.Suffix); err == nil {
+			websiteRedirect(w, r, "/"+key+"/", http.StatusFound)
+			return
+		}
+	}
+	if rule := website.matchRoutingRule(key, ec.StatusCode); rule != nil {
+		location, code := rule.location(r, key)
+		websiteRedirect(w, r, location, code)
+		return
+	}
+	if website.ErrorDocument != nil && (ec.StatusCode == http.StatusNotFound || ec.StatusCode == http.StatusForbidden) {
+		if o.serveWebsiteObject(w, r, website.ErrorDocument.Key, ec.StatusCode) == nil {
+			SetResponseStatusCode(r, *ec)
+			return
+		}
+	}
+	writeWebsiteError(w, r, ec)
+}
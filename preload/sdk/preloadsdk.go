@@ -127,7 +127,7 @@ func NewClient(config PreloadConfig) *PreLoadClient {
 		Masters:           config.Masters,
 		Preload:           true,
 		OnAppendExtentKey: mw.AppendExtentKey,
-		OnGetExtents:      mw.GetExtents,
+		OnGetExtents:      mw.GetExtentsWithShared,
 		OnTruncate:        mw.Truncate,
 		VolumeType:        proto.VolumeTypeCold,
 	}); err != nil {
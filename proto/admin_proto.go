@@ -49,6 +49,7 @@ const (
 	AdminSetCheckDataReplicasEnable           = "/cluster/setCheckDataReplicasEnable"
 	AdminGetIP                                = "/admin/getIp"
 	AdminCreateMetaPartition                  = "/metaPartition/create"
+	AdminSplitMetaPartition                   = "/metaPartition/split"
 	AdminSetMetaNodeThreshold                 = "/threshold/set"
 	AdminListVols                             = "/vol/list"
 	AdminSetNodeInfo                          = "/admin/setNodeInfo"
@@ -189,6 +190,12 @@ const (
 	QuotaGet    = "/quota/get"
 	// QuotaBatchModifyPath = "/quota/batchModifyPath"
 	QuotaListAll = "/quota/listAll"
+
+	// snapshot api
+	SnapshotCreate = "/vol/snapshot/create"
+	SnapshotUpdate = "/vol/snapshot/update"
+	SnapshotDelete = "/vol/snapshot/delete"
+	SnapshotList   = "/vol/snapshot/list"
 )
 
 var GApiInfo map[string]string = map[string]string{
@@ -215,6 +222,7 @@ var GApiInfo map[string]string = map[string]string{
 	"adminclusterstat":                 AdminClusterStat,
 	"admingetip":                       AdminGetIP,
 	"admincreatemetapartition":         AdminCreateMetaPartition,
+	"adminsplitmetapartition":          AdminSplitMetaPartition,
 	"adminsetmetanodethreshold":        AdminSetMetaNodeThreshold,
 	"adminlistvols":                    AdminListVols,
 	"adminsetnodeinfo":                 AdminSetNodeInfo,
@@ -608,6 +616,26 @@ type UpdateMetaPartitionResponse struct {
 	Result      string
 }
 
+// MigrateMetaPartitionRequest defines the request to move the inodes from Start to the end of a meta partition,
+// with their dentries and extended attributes, to the target meta partition created by a split.
+type MigrateMetaPartitionRequest struct {
+	PartitionID uint64
+	VolName     string
+	Start       uint64
+	TargetID    uint64
+	TargetHosts []string
+}
+
+// MigrateMetaPartitionResponse defines the response to the request of migrating a meta partition.
+type MigrateMetaPartitionResponse struct {
+	PartitionID uint64
+	VolName     string
+	Start       uint64
+	TargetID    uint64
+	Status      uint8
+	Result      string
+}
+
 // MetaPartitionDecommissionRequest defines the request of decommissioning a meta partition.
 type MetaPartitionDecommissionRequest struct {
 	PartitionID uint64
@@ -700,6 +728,8 @@ type VolView struct {
 	OSSSecure      *OSSSecure
 	CreateTime     int64
 	DeleteLockTime int64
+	TrashInterval  int64 // minutes the deleted files are kept in the trash, 0 disables the trash
+	DirShards      int   // the number of the shards of the directories created, 0 disables it
 	CacheTTL       int
 	VolType        int
 }
@@ -840,9 +870,12 @@ type SimpleVolView struct {
 	DomainOn                bool
 	CreateTime              string
 	DeleteLockTime          int64
+	TrashInterval           int64
+	DirShards               int
 	EnableToken             bool
 	EnablePosixAcl          bool
 	EnableQuota             bool
+	MetaStoreMode           string
 	EnableTransaction       string
 	TxTimeout               int64
 	TxConflictRetryNum      int64
@@ -0,0 +1,46 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import "time"
+
+// The meta node publishes the inodes changed in a partition to the watching clients, so that the metadata
+// cached by the clients is invalidated. The changes are kept in memory by each replica for a while, and are
+// numbered by a sequence in the epoch of the replica. The watcher falls behind, or talking to another
+// replica, is told to reset everything it cached from the partition.
+const (
+	// WatchChangesTimeout is how long the meta node holds the watch request if nothing has changed,
+	// which must be shorter than the read deadline of the client.
+	WatchChangesTimeout = 3 * time.Second
+	// WatchChangesLimit is the max number of the inodes returned at once.
+	WatchChangesLimit = 4096
+)
+
+// WatchChangesRequest waits for the inodes changed after the sequence in the epoch. The inode is changed
+// if its attributes, or the dentries of the directory are changed.
+type WatchChangesRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Epoch       uint64 `json:"epoch"`
+	Seq         uint64 `json:"seq"`
+}
+
+type WatchChangesResponse struct {
+	Epoch uint64 `json:"epoch"`
+	Seq   uint64 `json:"seq"`
+	// Reset is set if the changes are not known, all the cached metadata of the partition must be dropped.
+	Reset  bool     `json:"reset"`
+	Inodes []uint64 `json:"inodes"`
+}
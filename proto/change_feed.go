@@ -0,0 +1,111 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"fmt"
+	"time"
+)
+
+// The change feed of a meta partition is the ordered metadata changes made by the applied raft log entries,
+// for indexing and incremental backup. The changes are numbered by the apply id of the raft log entry, which is
+// the same on all the replicas, so the consumer resumes from the apply id it has consumed on any replica.
+// The changes are kept in memory for a while, the recent ones are stored with the snapshot of the partition and
+// those replayed from the raft log after a restart are kept again, so the consumer resumes from its apply id
+// after a restart too. The consumer falling further behind is told the feed is truncated and has to rescan
+// the partition.
+//
+// A rename is fed as the dentry created in the new parent and the dentry deleted from the old parent, which
+// may be fed by different partitions.
+const (
+	// ChangeFeedTimeout is how long the meta node holds the read request if nothing has changed,
+	// which must be shorter than the read deadline of the client.
+	ChangeFeedTimeout = 3 * time.Second
+	// ChangeFeedLimit is the default max number of the changes returned at once.
+	ChangeFeedLimit = 4096
+)
+
+type ChangeType uint8
+
+const (
+	ChangeCreateInode ChangeType = iota + 1
+	ChangeLinkInode
+	ChangeUnlinkInode
+	ChangeEvictInode
+	ChangeSetAttr
+	ChangeExtents
+	ChangeSetXAttr
+	ChangeRemoveXAttr
+	ChangeCreateDentry
+	ChangeDeleteDentry
+	ChangeUpdateDentry
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case ChangeCreateInode:
+		return "CreateInode"
+	case ChangeLinkInode:
+		return "LinkInode"
+	case ChangeUnlinkInode:
+		return "UnlinkInode"
+	case ChangeEvictInode:
+		return "EvictInode"
+	case ChangeSetAttr:
+		return "SetAttr"
+	case ChangeExtents:
+		return "Extents"
+	case ChangeSetXAttr:
+		return "SetXAttr"
+	case ChangeRemoveXAttr:
+		return "RemoveXAttr"
+	case ChangeCreateDentry:
+		return "CreateDentry"
+	case ChangeDeleteDentry:
+		return "DeleteDentry"
+	case ChangeUpdateDentry:
+		return "UpdateDentry"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// ChangeEvent is a change made by the raft log entry of the apply id. The dentry changes are of the dentry
+// named Name in ParentID referring to Inode, the xattr changes are of the keys in Keys.
+type ChangeEvent struct {
+	ApplyID  uint64     `json:"apply"`
+	Type     ChangeType `json:"type"`
+	Inode    uint64     `json:"ino"`
+	ParentID uint64     `json:"parent,omitempty"`
+	Name     string     `json:"name,omitempty"`
+	Keys     []string   `json:"keys,omitempty"`
+}
+
+// ReadChangeFeedRequest reads the changes after the apply id, at most limit of them.
+type ReadChangeFeedRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	ApplyID     uint64 `json:"apply"`
+	Limit       int    `json:"limit"`
+}
+
+type ReadChangeFeedResponse struct {
+	// ApplyID is the apply id to read from next, the changes of a raft log entry are always returned together.
+	ApplyID uint64 `json:"apply"`
+	// Truncated is set if the changes after the apply id read are not kept, in which case the feed is read
+	// from ApplyID once the partition is rescanned.
+	Truncated bool           `json:"truncated"`
+	Events    []*ChangeEvent `json:"events"`
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+)
+
+// The dentries of a sharded directory are hashed by name to the shard inodes, which are hidden directories
+// created in different meta partitions, so that a huge directory is served by several raft groups.
+// The shards of a directory are kept in its extended attribute, which is set only while the directory is
+// empty. The meta node rejects the dentry operations on the sharded directory itself with OpDirShardedErr.
+const (
+	// DirShardsXAttrKey is set to the number of the shards by the user, the value kept is the comma
+	// separated shard inodes.
+	DirShardsXAttrKey = "cbfs.dir.shards"
+	MinDirShards      = 2
+	MaxDirShards      = 256
+
+	// DirShardFenceXAttrKey is set on the empty shard to fence it before the sharded directory is deleted,
+	// after which no dentry is created in the shard, and removed to lift the fence if the delete fails.
+	DirShardFenceXAttrKey = "cbfs.dir.shard.fence"
+)
+
+// ParseDirShardsCount returns the number of the shards set by the user.
+func ParseDirShardsCount(value string) (count int, err error) {
+	if count, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+		return 0, fmt.Errorf("invalid dir shards %q", value)
+	}
+	if count < MinDirShards || count > MaxDirShards {
+		return 0, fmt.Errorf("dir shards %v out of [%v, %v]", count, MinDirShards, MaxDirShards)
+	}
+	return
+}
+
+// FormatDirShards returns the xattr value of the shard inodes.
+func FormatDirShards(shards []uint64) string {
+	values := make([]string, 0, len(shards))
+	for _, ino := range shards {
+		values = append(values, strconv.FormatUint(ino, 10))
+	}
+	return strings.Join(values, ",")
+}
+
+// ParseDirShards returns the shard inodes kept in the xattr value.
+func ParseDirShards(value string) (shards []uint64, err error) {
+	values := strings.Split(value, ",")
+	if len(values) < MinDirShards || len(values) > MaxDirShards {
+		return nil, fmt.Errorf("invalid dir shards %q", value)
+	}
+	shards = make([]uint64, 0, len(values))
+	for _, v := range values {
+		var ino uint64
+		if ino, err = strconv.ParseUint(v, 10, 64); err != nil || ino == 0 {
+			return nil, fmt.Errorf("invalid dir shards %q", value)
+		}
+		shards = append(shards, ino)
+	}
+	return
+}
+
+// DirShardOf returns the shard inode keeping the dentry of the name.
+func DirShardOf(shards []uint64, name string) uint64 {
+	return shards[crc32.ChecksumIEEE([]byte(name))%uint32(len(shards))]
+}
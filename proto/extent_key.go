@@ -224,3 +224,21 @@ type TinyExtentDeleteRecord struct {
 	Size         uint32
 	CRC          uint32
 }
+
+// FreezeExtentsRequest freezes the extents of a data partition, which are not modified in place afterwards:
+// overwriting or appending to a frozen normal extent, and overwriting a frozen tiny extent below its size at
+// the time it is frozen are rejected with OpNotPerm, so that the clients write the data to new extents instead.
+// The data shared by the cloned files and the snapshots, and the data protected by the object lock is frozen.
+// The frozen extents of the partition are replied, so the request freezing nothing reads them.
+type FreezeExtentsRequest struct {
+	// All freezes all the extents created so far.
+	All     bool     `json:"all,omitempty"`
+	Extents []uint64 `json:"eids,omitempty"`
+}
+
+// FrozenExtents is the extents frozen in a data partition.
+type FrozenExtents struct {
+	Below   uint64           `json:"below"` // the normal extents below are all frozen
+	Extents []uint64         `json:"eids"`
+	Tiny    map[uint64]int64 `json:"tiny"` // the tiny extents are frozen below the offsets
+}
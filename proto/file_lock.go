@@ -0,0 +1,119 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"fmt"
+	"math"
+)
+
+// The advisory file locks are held in the lock table of the meta partition which the inode belongs to,
+// so that the flock(2) and fcntl(2) locks are respected across the clients.
+// Each lock is leased to the client session holding it, which is expired if the client crashes
+// without renewing the lease.
+const (
+	FileLockUnlock uint8 = iota
+	FileLockRead
+	FileLockWrite
+)
+
+const (
+	FileLockLease         = 30 // seconds
+	FileLockRenewInterval = FileLockLease / 3
+
+	// FileLockEOF is the end of the lock range which extends to the end of file.
+	FileLockEOF = math.MaxUint64
+)
+
+// FileLock is a byte range lock, the flock(2) locks always cover the whole file.
+type FileLock struct {
+	Session string `json:"sid"`   // the client session holding the lock
+	Owner   uint64 `json:"owner"` // the lock owner given by the kernel
+	Pid     uint32 `json:"pid"`
+	Start   uint64 `json:"start"`
+	End     uint64 `json:"end"` // inclusive
+	Type    uint8  `json:"type"`
+	Flock   bool   `json:"flock"`
+	Expire  int64  `json:"expire"` // unix timestamp in seconds
+}
+
+func (l *FileLock) String() string {
+	return fmt.Sprintf("FileLock{sid(%v) owner(%v) pid(%v) range(%v-%v) type(%v) flock(%v) expire(%v)}",
+		l.Session, l.Owner, l.Pid, l.Start, l.End, l.Type, l.Flock, l.Expire)
+}
+
+// SameOwner returns true if the locks are held by the same owner of the same session.
+func (l *FileLock) SameOwner(o *FileLock) bool {
+	return l.Session == o.Session && l.Owner == o.Owner && l.Flock == o.Flock
+}
+
+func (l *FileLock) Overlaps(o *FileLock) bool {
+	return l.Start <= o.End && o.Start <= l.End
+}
+
+// Conflicts returns true if the locks can not be held at the same time.
+// The flock(2) and fcntl(2) locks do not interact with each other as Linux does.
+func (l *FileLock) Conflicts(o *FileLock) bool {
+	if l.Flock != o.Flock || l.SameOwner(o) || !l.Overlaps(o) {
+		return false
+	}
+	return l.Type == FileLockWrite || o.Type == FileLockWrite
+}
+
+func (l *FileLock) Expired(now int64) bool {
+	return l.Expire <= now
+}
+
+type SetFileLockRequest struct {
+	VolName     string   `json:"vol"`
+	PartitionID uint64   `json:"pid"`
+	Inode       uint64   `json:"ino"`
+	Lock        FileLock `json:"lock"`
+}
+
+// SetFileLockResponse returns the conflicting lock if the lock is not acquired.
+type SetFileLockResponse struct {
+	Conflict *FileLock `json:"conflict"`
+}
+
+type GetFileLockRequest struct {
+	VolName     string   `json:"vol"`
+	PartitionID uint64   `json:"pid"`
+	Inode       uint64   `json:"ino"`
+	Lock        FileLock `json:"lock"`
+}
+
+// GetFileLockResponse returns the lock conflicting with the requested one, which is nil if there is none.
+type GetFileLockResponse struct {
+	Conflict *FileLock `json:"conflict"`
+}
+
+// RenewFileLockRequest extends the leases of all the locks held by the session in the partition.
+type RenewFileLockRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Session     string `json:"sid"`
+}
+
+type RenewFileLockResponse struct {
+	Count int `json:"count"` // the number of the locks held by the session
+}
+
+// ReleaseFileLocksRequest releases all the locks held by the session in the partition.
+type ReleaseFileLocksRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Session     string `json:"sid"`
+}
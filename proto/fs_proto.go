@@ -489,6 +489,7 @@ type LookupRequest struct {
 	PartitionID uint64 `json:"pid"`
 	ParentID    uint64 `json:"pino"`
 	Name        string `json:"name"`
+	Version     uint64 `json:"ver,omitempty"` // the snapshot version read, the live tree if 0
 }
 
 // LookupResponse defines the response for the loopup request.
@@ -502,6 +503,7 @@ type InodeGetRequest struct {
 	VolName     string `json:"vol"`
 	PartitionID uint64 `json:"pid"`
 	Inode       uint64 `json:"ino"`
+	Version     uint64 `json:"ver,omitempty"` // the snapshot version read, the live tree if 0
 }
 
 // InodeGetResponse defines the response to the InodeGetRequest.
@@ -549,6 +551,7 @@ type ReadDirLimitRequest struct {
 	ParentID    uint64 `json:"pino"`
 	Marker      string `json:"marker"`
 	Limit       uint64 `json:"limit"`
+	Version     uint64 `json:"ver,omitempty"` // the snapshot version read, the live tree if 0
 }
 
 type ReadDirLimitResponse struct {
@@ -584,6 +587,7 @@ type GetExtentsRequest struct {
 	VolName     string `json:"vol"`
 	PartitionID uint64 `json:"pid"`
 	Inode       uint64 `json:"ino"`
+	Version     uint64 `json:"ver,omitempty"` // the snapshot version read, the live tree if 0
 }
 
 // GetObjExtentsResponse defines the response to the request of getting obj extents.
@@ -599,6 +603,7 @@ type GetExtentsResponse struct {
 	Generation uint64      `json:"gen"`
 	Size       uint64      `json:"sz"`
 	Extents    []ExtentKey `json:"eks"`
+	Shared     bool        `json:"shared"` // some extents are shared with the clones, which must not be overwritten
 }
 
 // TruncateRequest defines the request to truncate.
@@ -609,6 +614,36 @@ type TruncateRequest struct {
 	Size        uint64 `json:"sz"`
 }
 
+// PunchHoleRequest removes the extents in the range of the file, the size of the file is not changed.
+type PunchHoleRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+	Offset      uint64 `json:"off"`
+	Size        uint64 `json:"sz"`
+}
+
+// CloneInodeRequest clones the range [Offset, Offset+Size) of the inode to DstOffset of the destination inode,
+// the data extents are shared by the inodes rather than copied. The destination inode must be in the same
+// partition, a new inode is created with Mode, Uid and Gid if DstInode is zero. Size zero means to the end of file.
+type CloneInodeRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+	Offset      uint64 `json:"off"`
+	Size        uint64 `json:"sz"`
+	DstInode    uint64 `json:"dino"`
+	DstOffset   uint64 `json:"doff"`
+	Mode        uint32 `json:"mode"`
+	Uid         uint32 `json:"uid"`
+	Gid         uint32 `json:"gid"`
+	Version     uint64 `json:"ver,omitempty"` // the snapshot version the source is cloned from
+}
+
+type CloneInodeResponse struct {
+	Info *InodeInfo `json:"info"`
+}
+
 type EmptyExtentKeyRequest struct {
 	VolName     string `json:"vol"`
 	PartitionID uint64 `json:"pid"`
@@ -684,6 +719,9 @@ type GetAllXAttrRequest struct {
 	VolName     string `json:"vol"`
 	PartitionId uint64 `json:"pid"`
 	Inode       uint64 `json:"ino"`
+	// Prefix limits the attributes returned to the keys with the prefix, if not empty.
+	Prefix  string `json:"prefix,omitempty"`
+	Version uint64 `json:"ver,omitempty"`
 }
 
 type GetAllXAttrResponse struct {
@@ -749,6 +787,24 @@ type UpdateXAttrRequest struct {
 	Value       string `json:"val"`
 }
 
+// UpdateXAttrRecordsRequest updates the records kept as the extended attributes of the inode whose keys have
+// the prefix, atomically. The records of Remove must all exist with the values given, otherwise nothing is
+// changed and OpNotExistErr is returned. Append is kept as a new record, whose key is the prefix followed by
+// a sequence number greater than those of the records of the prefix.
+type UpdateXAttrRecordsRequest struct {
+	VolName     string            `json:"vol"`
+	PartitionId uint64            `json:"pid"`
+	Inode       uint64            `json:"ino"`
+	Prefix      string            `json:"prefix"`
+	Remove      map[string]string `json:"remove,omitempty"`
+	Append      string            `json:"append,omitempty"`
+}
+
+type UpdateXAttrRecordsResponse struct {
+	// Key is the key of the record appended.
+	Key string `json:"key"`
+}
+
 type MultipartInfo struct {
 	ID       string               `json:"id"`
 	Path     string               `json:"path"`
@@ -889,3 +945,9 @@ type GetUniqIDRequest struct {
 type GetUniqIDResponse struct {
 	Start uint64 `json:"start"`
 }
+
+// MetaPartitionMovedResponse is the body of OpMetaPartitionMovedErr, the request should be sent
+// to the meta partition the inode is moved to.
+type MetaPartitionMovedResponse struct {
+	PartitionID uint64 `json:"pid"`
+}
@@ -14,7 +14,10 @@
 
 package proto
 
-import "sync"
+import (
+	"fmt"
+	"sync"
+)
 
 // CreateNameSpaceRequest defines the request to create a name space.
 type CreateNameSpaceRequest struct {
@@ -41,6 +44,50 @@ type CreateMetaPartitionRequest struct {
 	End         uint64
 	PartitionID uint64
 	Members     []Peer
+	StoreMode   MetaStoreMode
+}
+
+// MetaStoreMode defines where a meta partition keeps its inode, dentry, extend and multipart trees.
+type MetaStoreMode uint8
+
+const (
+	// MetaStoreModeMem keeps the whole metadata in memory and dumps it to snapshot files.
+	MetaStoreModeMem MetaStoreMode = 0x0
+	// MetaStoreModeRocksDB keeps the metadata in RocksDB with a bounded in-memory cache.
+	MetaStoreModeRocksDB MetaStoreMode = 0x1
+)
+
+func (m MetaStoreMode) Valid() bool {
+	switch m {
+	case MetaStoreModeMem,
+		MetaStoreModeRocksDB:
+		return true
+	default:
+	}
+	return false
+}
+
+func (m MetaStoreMode) String() string {
+	switch m {
+	case MetaStoreModeMem:
+		return "mem"
+	case MetaStoreModeRocksDB:
+		return "rocksdb"
+	default:
+	}
+	return "invalid"
+}
+
+// ParseMetaStoreMode returns the store mode of the given name, an empty name means MetaStoreModeMem.
+func ParseMetaStoreMode(name string) (MetaStoreMode, error) {
+	switch name {
+	case "", "mem":
+		return MetaStoreModeMem, nil
+	case "rocksdb":
+		return MetaStoreModeRocksDB, nil
+	default:
+	}
+	return MetaStoreModeMem, fmt.Errorf("invalid meta store mode %q", name)
 }
 
 // CreateMetaPartitionResponse defines the response to the request of creating a meta partition.
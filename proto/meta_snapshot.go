@@ -0,0 +1,29 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+// The raft snapshot of a meta partition is sent to the follower in checksummed chunks, which are staged
+// by the follower until the snapshot is complete. Before sending, the leader asks the follower for the
+// chunks staged, so that an interrupted transfer of the same snapshot is resumed instead of restarted.
+
+// MetaSnapshotProgressRequest asks the follower for the chunks of the snapshot staged.
+type MetaSnapshotProgressRequest struct {
+	PartitionID uint64 `json:"pid"`
+}
+
+type MetaSnapshotProgressResponse struct {
+	ApplyID uint64 `json:"applyID"` // the apply ID of the snapshot staged
+	Chunks  uint64 `json:"chunks"`  // the number of the chunks verified
+}
@@ -71,6 +71,9 @@ const (
 	LocallyProf
 	MinWriteAbleDataPartitionCnt
 	FileSystemName
+	EnableFileLock
+	EnableReaddirPlus
+	MetaCacheDir
 	MaxMountOption
 )
 
@@ -160,6 +163,9 @@ func InitMountOptions(opts []MountOption) {
 		"Min writeable data partition count retained int dpSelector when update DataPartitionsView from master",
 		"", int64(10)}
 	opts[FileSystemName] = MountOption{"fileSystemName", "The explicit name of the filesystem", "", ""}
+	opts[EnableFileLock] = MountOption{"enableFileLock", "Enable flock/fcntl locks across the clients", "", false}
+	opts[EnableReaddirPlus] = MountOption{"enableReaddirPlus", "Enable readdirplus to return the attributes along with the dentries", "", true}
+	opts[MetaCacheDir] = MountOption{"metaCacheDir", "The local directory to persist the metadata cache of the read-mostly directories, disabled if empty", "", ""}
 
 	for i := 0; i < MaxMountOption; i++ {
 		flag.StringVar(&opts[i].cmdlineValue, opts[i].keyword, "", opts[i].description)
@@ -318,4 +324,7 @@ type MountOptions struct {
 	RequestTimeout               int64
 	MinWriteAbleDataPartitionCnt int
 	FileSystemName               string
+	EnableFileLock               bool
+	EnableReaddirPlus            bool
+	MetaCacheDir                 string
 }
@@ -0,0 +1,97 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"strconv"
+
+	"github.com/cubefs/cubefs/util/errors"
+)
+
+// The WORM (write once read many) protection of an inode is persisted as the extended attributes of the inode,
+// so that the meta node is able to enforce it for both the object storage and the POSIX clients.
+const (
+	XAttrKeyObjectLockMode        = "oss:object-lock-mode"
+	XAttrKeyObjectLockRetainUntil = "oss:object-lock-retain-until" // unix timestamp in seconds
+	XAttrKeyObjectLockLegalHold   = "oss:object-lock-legal-hold"
+
+	ObjectLockModeGovernance = "GOVERNANCE"
+	ObjectLockModeCompliance = "COMPLIANCE"
+
+	ObjectLockLegalHoldOn  = "ON"
+	ObjectLockLegalHoldOff = "OFF"
+)
+
+var ErrObjectLocked = errors.New("object is protected by object lock")
+
+// IsObjectLockXAttrKey returns true if the extended attribute key is one of the object lock attributes.
+func IsObjectLockXAttrKey(key string) bool {
+	return key == XAttrKeyObjectLockMode || key == XAttrKeyObjectLockRetainUntil || key == XAttrKeyObjectLockLegalHold
+}
+
+// ObjectLock is the WORM protection state of an inode.
+type ObjectLock struct {
+	Mode        string
+	RetainUntil int64
+	LegalHold   bool
+}
+
+// ParseObjectLock builds the object lock from the values of the object lock extended attributes.
+func ParseObjectLock(mode, retainUntil, legalHold string) *ObjectLock {
+	lock := &ObjectLock{
+		Mode:      mode,
+		LegalHold: legalHold == ObjectLockLegalHoldOn,
+	}
+	if retainUntil != "" {
+		lock.RetainUntil, _ = strconv.ParseInt(retainUntil, 10, 64)
+	}
+	return lock
+}
+
+// Retained returns true if the retention period has not expired.
+func (l *ObjectLock) Retained(now int64) bool {
+	return l != nil && l.Mode != "" && l.RetainUntil > now
+}
+
+// Locked returns true if the inode can neither be deleted nor be modified.
+func (l *ObjectLock) Locked(now int64) bool {
+	return l != nil && (l.LegalHold || l.Retained(now))
+}
+
+// CheckUpdate checks whether the retention can be changed to the new one.
+// The retention in compliance mode can not be shortened or removed by any user until it expires,
+// while the retention in governance mode is only protected by the permission checking of the object node.
+func (l *ObjectLock) CheckUpdate(n *ObjectLock, now int64) error {
+	if l == nil || l.Mode != ObjectLockModeCompliance || !l.Retained(now) {
+		return nil
+	}
+	if n == nil || n.Mode != ObjectLockModeCompliance || n.RetainUntil < l.RetainUntil {
+		return ErrObjectLocked
+	}
+	return nil
+}
+
+// Attrs returns the extended attributes of the retention and legal hold.
+func (l *ObjectLock) Attrs() map[string]string {
+	attrs := make(map[string]string)
+	if l.Mode != "" {
+		attrs[XAttrKeyObjectLockMode] = l.Mode
+		attrs[XAttrKeyObjectLockRetainUntil] = strconv.FormatInt(l.RetainUntil, 10)
+	}
+	if l.LegalHold {
+		attrs[XAttrKeyObjectLockLegalHold] = ObjectLockLegalHoldOn
+	}
+	return attrs
+}
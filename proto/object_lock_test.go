@@ -0,0 +1,49 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectLock(t *testing.T) {
+	var now int64 = 1000
+	var nilLock *ObjectLock
+	require.False(t, nilLock.Locked(now))
+	require.Nil(t, nilLock.CheckUpdate(nil, now))
+
+	lock := &ObjectLock{Mode: ObjectLockModeCompliance, RetainUntil: now + 10}
+	require.True(t, lock.Locked(now))
+	require.False(t, lock.Locked(now+10))
+	require.Equal(t, lock, ParseObjectLock(lock.Attrs()[XAttrKeyObjectLockMode],
+		lock.Attrs()[XAttrKeyObjectLockRetainUntil], lock.Attrs()[XAttrKeyObjectLockLegalHold]))
+
+	// The compliance retention can only be extended.
+	require.Nil(t, lock.CheckUpdate(&ObjectLock{Mode: ObjectLockModeCompliance, RetainUntil: now + 20}, now))
+	require.Equal(t, ErrObjectLocked, lock.CheckUpdate(&ObjectLock{Mode: ObjectLockModeCompliance, RetainUntil: now + 5}, now))
+	require.Equal(t, ErrObjectLocked, lock.CheckUpdate(&ObjectLock{Mode: ObjectLockModeGovernance, RetainUntil: now + 20}, now))
+	require.Equal(t, ErrObjectLocked, lock.CheckUpdate(&ObjectLock{}, now))
+	require.Nil(t, lock.CheckUpdate(&ObjectLock{}, now+10))
+
+	// The governance retention is not protected by the meta node.
+	lock = &ObjectLock{Mode: ObjectLockModeGovernance, RetainUntil: now + 10}
+	require.Nil(t, lock.CheckUpdate(&ObjectLock{}, now))
+
+	lock = &ObjectLock{LegalHold: true}
+	require.True(t, lock.Locked(now))
+	require.False(t, lock.Retained(now))
+}
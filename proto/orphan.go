@@ -0,0 +1,42 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+// An orphan inode is the file unlinked while it is still open by a client. The orphan inodes are recorded
+// in the meta partition which the inode belongs to together with the client session holding them open,
+// and are evicted by the meta node once the lease of the session is expired, e.g. the client crashes.
+const (
+	OrphanSessionLease         = 600 // seconds
+	OrphanSessionRenewInterval = OrphanSessionLease / 10
+)
+
+// AddOrphanInodeRequest records the inode unlinked while it is open by the session.
+type AddOrphanInodeRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+	Session     string `json:"sid"`
+}
+
+// RenewOrphanSessionRequest extends the lease of the orphan inodes held by the session in the partition.
+type RenewOrphanSessionRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Session     string `json:"sid"`
+}
+
+type RenewOrphanSessionResponse struct {
+	Count int `json:"count"` // the number of the orphan inodes held by the session
+}
@@ -16,6 +16,7 @@ package proto
 
 import (
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,6 +29,7 @@ import (
 
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/buf"
+	"github.com/cubefs/cubefs/util/trace"
 )
 
 var (
@@ -67,6 +69,7 @@ const (
 	OpReadTinyDeleteRecord           uint8 = 0x14
 	OpTinyExtentRepairRead           uint8 = 0x15
 	OpGetMaxExtentIDAndPartitionSize uint8 = 0x16
+	OpFreezeExtents                  uint8 = 0x17
 
 	// Operations: Client -> MetaNode.
 	OpMetaCreateInode   uint8 = 0x20
@@ -111,6 +114,7 @@ const (
 	OpAddMetaPartitionRaftMember    uint8 = 0x46
 	OpRemoveMetaPartitionRaftMember uint8 = 0x47
 	OpMetaPartitionTryToLeader      uint8 = 0x48
+	OpMigrateMetaPartition          uint8 = 0x49
 
 	// Quota
 	OpMetaBatchSetInodeQuota    uint8 = 0x50
@@ -164,6 +168,43 @@ const (
 	//Operations: Client -> MetaNode.
 	OpMetaGetUniqID uint8 = 0xAC
 
+	// Operations: Client -> MetaNode, advisory file locks.
+	OpMetaSetFileLock      uint8 = 0xAD
+	OpMetaGetFileLock      uint8 = 0xAE
+	OpMetaRenewFileLock    uint8 = 0xAF
+	OpMetaReleaseFileLocks uint8 = 0xB0
+
+	OpMetaExtentsPunchHole uint8 = 0xB1
+	OpMetaInodeClone       uint8 = 0xB2
+
+	// Operations: Client -> MetaNode, the trash of the volume.
+	OpMetaTrashInode   uint8 = 0xB3
+	OpMetaListTrash    uint8 = 0xB4
+	OpMetaRestoreTrash uint8 = 0xB5
+	OpMetaPurgeTrash   uint8 = 0xB6
+
+	// Operations: Client -> MetaNode, the orphan inodes held open by the client sessions.
+	OpMetaAddOrphanInode     uint8 = 0xB7
+	OpMetaRenewOrphanSession uint8 = 0xB8
+
+	// Operations: Client -> MetaNode, the notifications of the changed inodes.
+	OpMetaWatchChanges uint8 = 0xB9
+
+	// Operations: MetaNode Leader -> MetaNode Follower, the chunks of the raft snapshot staged by the follower.
+	OpMetaSnapshotProgress uint8 = 0xBA
+
+	// Operations: MetaNode -> MetaNode, the items of the range split off a meta partition.
+	OpMetaMigrateItems uint8 = 0xBB
+
+	// Operations: Client -> MetaNode, the change feed of the partition.
+	OpMetaReadChangeFeed uint8 = 0xBC
+
+	// Operations: Client -> MetaNode, the records kept in the extended attributes.
+	OpMetaUpdateXAttrRecords uint8 = 0xBD
+
+	// Operations: Client -> MetaNode, the read-only versions of the partition kept for the snapshots.
+	OpMetaSnapshotVersion uint8 = 0xBE
+
 	// Commons
 	OpNoSpaceErr         uint8 = 0xEE
 	OpDirQuota           uint8 = 0xF1
@@ -182,6 +223,11 @@ const (
 	OpNotEmpty           uint8 = 0xFE
 	OpOk                 uint8 = 0xF0
 
+	// the inode is moved to another meta partition by a split
+	OpMetaPartitionMovedErr uint8 = 0xEF
+	// the dentries of the directory are sharded to the shard inodes, see DirShardsXAttrKey
+	OpDirShardedErr uint8 = 0xDC
+
 	OpPing                  uint8 = 0xFF
 	OpMetaUpdateXAttr       uint8 = 0x3B
 	OpMetaReadDirOnly       uint8 = 0x3C
@@ -254,6 +300,8 @@ type Packet struct {
 	StartT             int64
 	mesg               string
 	HasPrepare         bool
+	// the context of the span sending the packet, which is carried only if the packet is traced
+	Trace trace.SpanContext
 }
 
 // NewPacket returns a new packet.
@@ -426,6 +474,8 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpTinyExtentRepairRead"
 	case OpGetMaxExtentIDAndPartitionSize:
 		m = "OpGetMaxExtentIDAndPartitionSize"
+	case OpFreezeExtents:
+		m = "OpFreezeExtents"
 	case OpBroadcastMinAppliedID:
 		m = "OpBroadcastMinAppliedID"
 	case OpRemoveDataPartitionRaftMember:
@@ -438,6 +488,8 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpRemoveMetaPartitionRaftMember"
 	case OpMetaPartitionTryToLeader:
 		m = "OpMetaPartitionTryToLeader"
+	case OpMigrateMetaPartition:
+		m = "OpMigrateMetaPartition"
 	case OpDataPartitionTryToLeader:
 		m = "OpDataPartitionTryToLeader"
 	case OpMetaDeleteInode:
@@ -498,6 +550,42 @@ func (p *Packet) GetOpMsg() (m string) {
 		m = "OpMetaTxLinkInode"
 	case OpMetaTxGet:
 		m = "OpMetaTxGet"
+	case OpMetaSetFileLock:
+		m = "OpMetaSetFileLock"
+	case OpMetaGetFileLock:
+		m = "OpMetaGetFileLock"
+	case OpMetaRenewFileLock:
+		m = "OpMetaRenewFileLock"
+	case OpMetaReleaseFileLocks:
+		m = "OpMetaReleaseFileLocks"
+	case OpMetaExtentsPunchHole:
+		m = "OpMetaExtentsPunchHole"
+	case OpMetaInodeClone:
+		m = "OpMetaInodeClone"
+	case OpMetaTrashInode:
+		m = "OpMetaTrashInode"
+	case OpMetaListTrash:
+		m = "OpMetaListTrash"
+	case OpMetaRestoreTrash:
+		m = "OpMetaRestoreTrash"
+	case OpMetaPurgeTrash:
+		m = "OpMetaPurgeTrash"
+	case OpMetaAddOrphanInode:
+		m = "OpMetaAddOrphanInode"
+	case OpMetaRenewOrphanSession:
+		m = "OpMetaRenewOrphanSession"
+	case OpMetaWatchChanges:
+		m = "OpMetaWatchChanges"
+	case OpMetaSnapshotProgress:
+		m = "OpMetaSnapshotProgress"
+	case OpMetaMigrateItems:
+		m = "OpMetaMigrateItems"
+	case OpMetaReadChangeFeed:
+		m = "OpMetaReadChangeFeed"
+	case OpMetaUpdateXAttrRecords:
+		m = "OpMetaUpdateXAttrRecords"
+	case OpMetaSnapshotVersion:
+		m = "OpMetaSnapshotVersion"
 	case OpMetaBatchSetInodeQuota:
 		m = "OpMetaBatchSetInodeQuota"
 	case OpMetaBatchDeleteInodeQuota:
@@ -543,6 +631,10 @@ func (p *Packet) GetResultMsg() (m string) {
 		m = "ArgUnmatchErr"
 	case OpNotExistErr:
 		m = "NotExistErr"
+	case OpMetaPartitionMovedErr:
+		m = "MetaPartitionMovedErr"
+	case OpDirShardedErr:
+		m = "DirShardedErr"
 	case OpTryOtherAddr:
 		m = "TryOtherAddr"
 	case OpNotPerm:
@@ -602,7 +694,11 @@ func (p *Packet) MarshalHeader(out []byte) {
 	out[4] = p.RemainingFollowers
 	binary.BigEndian.PutUint32(out[5:9], p.CRC)
 	binary.BigEndian.PutUint32(out[9:13], p.Size)
-	binary.BigEndian.PutUint32(out[13:17], p.ArgLen)
+	argLen := p.ArgLen
+	if p.Trace.IsValid() {
+		argLen += uint32(traceArgSize)
+	}
+	binary.BigEndian.PutUint32(out[13:17], argLen)
 	binary.BigEndian.PutUint64(out[17:25], p.PartitionID)
 	binary.BigEndian.PutUint64(out[25:33], p.ExtentID)
 	binary.BigEndian.PutUint64(out[33:41], uint64(p.ExtentOffset))
@@ -634,6 +730,46 @@ func (p *Packet) UnmarshalHeader(in []byte) error {
 	return nil
 }
 
+// The trace context is carried at the end of the arg, which is ignored by the nodes not knowing it:
+// the data nodes take the follower addresses before the last AddrSplit of the arg only, while the
+// arg of the other requests is not read.
+const traceArgPrefix = "@trace="
+
+var traceArgSize = len(traceArgPrefix) + hex.EncodedLen(trace.SpanContextSize)
+
+// writeTraceArg writes the trace context following the arg if the packet is traced.
+func (p *Packet) writeTraceArg(c io.Writer) (err error) {
+	if !p.Trace.IsValid() {
+		return
+	}
+	var sc [trace.SpanContextSize]byte
+	p.Trace.Marshal(sc[:])
+	arg := make([]byte, traceArgSize)
+	copy(arg, traceArgPrefix)
+	hex.Encode(arg[len(traceArgPrefix):], sc[:])
+	_, err = c.Write(arg)
+	return
+}
+
+// UnmarshalTraceArg takes the trace context off the end of the arg read, if there is one.
+func (p *Packet) UnmarshalTraceArg() {
+	n := int(p.ArgLen)
+	if n < traceArgSize || len(p.Arg) < n {
+		return
+	}
+	arg := p.Arg[n-traceArgSize : n]
+	if string(arg[:len(traceArgPrefix)]) != traceArgPrefix {
+		return
+	}
+	var sc [trace.SpanContextSize]byte
+	if _, err := hex.Decode(sc[:], arg[len(traceArgPrefix):]); err != nil {
+		return
+	}
+	p.Trace = trace.UnmarshalSpanContext(sc[:])
+	p.ArgLen = uint32(n - traceArgSize)
+	p.Arg = p.Arg[:p.ArgLen]
+}
+
 // MarshalData marshals the packet data.
 func (p *Packet) MarshalData(v interface{}) error {
 	data, err := json.Marshal(v)
@@ -660,6 +796,9 @@ func (p *Packet) WriteToNoDeadLineConn(c net.Conn) (err error) {
 	p.MarshalHeader(header)
 	if _, err = c.Write(header); err == nil {
 		if _, err = c.Write(p.Arg[:int(p.ArgLen)]); err == nil {
+			if err = p.writeTraceArg(c); err != nil {
+				return
+			}
 			if p.Data != nil {
 				_, err = c.Write(p.Data[:p.Size])
 			}
@@ -680,6 +819,9 @@ func (p *Packet) WriteToConn(c net.Conn) (err error) {
 	p.MarshalHeader(header)
 	if _, err = c.Write(header); err == nil {
 		if _, err = c.Write(p.Arg[:int(p.ArgLen)]); err == nil {
+			if err = p.writeTraceArg(c); err != nil {
+				return
+			}
 			if p.Data != nil && p.Size != 0 {
 				_, err = c.Write(p.Data[:p.Size])
 			}
@@ -724,6 +866,7 @@ func (p *Packet) ReadFromConn(c net.Conn, timeoutSec int) (err error) {
 		if _, err = io.ReadFull(c, p.Arg[:int(p.ArgLen)]); err != nil {
 			return err
 		}
+		p.UnmarshalTraceArg()
 	}
 
 	if p.Size < 0 {
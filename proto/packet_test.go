@@ -0,0 +1,92 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/trace"
+)
+
+func TestPacketTrace(t *testing.T) {
+	InitBufferPool(0)
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	send := func(p *Packet) *Packet {
+		errC := make(chan error, 1)
+		go func() {
+			errC <- p.WriteToConn(client)
+		}()
+		reply := NewPacket()
+		require.Nil(t, reply.ReadFromConn(server, ReadDeadlineTime))
+		require.Nil(t, <-errC)
+		return reply
+	}
+
+	// the packet not traced is unchanged on the wire
+	p := NewPacketReqID()
+	p.Opcode = OpMetaInodeGet
+	p.Data = []byte("data")
+	p.Size = uint32(len(p.Data))
+	reply := send(p)
+	require.Equal(t, ProtoMagic, reply.Magic)
+	require.False(t, reply.Trace.IsValid())
+	require.Equal(t, p.Data, reply.Data)
+
+	p.Trace = trace.SpanContext{TraceID: [16]byte{1}, SpanID: [8]byte{2}, Flags: trace.FlagSampled}
+	reply = send(p)
+	require.Equal(t, ProtoMagic, reply.Magic)
+	require.Equal(t, p.Trace, reply.Trace)
+	require.Equal(t, p.ReqID, reply.ReqID)
+	require.Equal(t, p.Data, reply.Data)
+	require.Equal(t, uint32(0), reply.ArgLen)
+
+	// the arg is kept, and the follower addresses are parsed as before by the nodes not knowing the trace
+	p.Arg = []byte("192.168.0.2:17310" + AddrSplit + "192.168.0.3:17310" + AddrSplit)
+	p.ArgLen = uint32(len(p.Arg))
+	reply = send(p)
+	require.Equal(t, p.Trace, reply.Trace)
+	require.Equal(t, p.Arg, reply.Arg)
+	require.Equal(t, p.ArgLen, reply.ArgLen)
+
+	// the nodes not knowing the trace read it as the part of the arg after the last AddrSplit
+	errC := make(chan error, 1)
+	go func() {
+		errC <- p.WriteToConn(client)
+	}()
+	legacy := NewPacket()
+	header := make([]byte, util.PacketHeaderSize)
+	_, err := io.ReadFull(server, header)
+	require.Nil(t, err)
+	require.Nil(t, legacy.UnmarshalHeader(header))
+	legacy.Arg = make([]byte, legacy.ArgLen)
+	_, err = io.ReadFull(server, legacy.Arg)
+	require.Nil(t, err)
+	legacy.Data = make([]byte, legacy.Size)
+	_, err = io.ReadFull(server, legacy.Data)
+	require.Nil(t, err)
+	require.Nil(t, <-errC)
+	addrs := strings.Split(string(legacy.Arg), AddrSplit)
+	require.Equal(t, []string{"192.168.0.2:17310", "192.168.0.3:17310"}, addrs[:len(addrs)-1])
+	require.Equal(t, p.Data, legacy.Data)
+}
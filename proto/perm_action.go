@@ -51,6 +51,7 @@ const (
 	// Object actions
 	OSSGetObjectAction     Action = OSSActionPrefix + "GetObject"
 	OSSPutObjectAction     Action = OSSActionPrefix + "PutObject"
+	OSSPostObjectAction    Action = OSSActionPrefix + "PostObject"
 	OSSCopyObjectAction    Action = OSSActionPrefix + "CopyObject"
 	OSSListObjectsAction   Action = OSSActionPrefix + "ListObjects"
 	OSSDeleteObjectAction  Action = OSSActionPrefix + "DeleteObject"
@@ -67,7 +68,7 @@ const (
 	OSSGetBucketPolicyAction       Action = OSSActionPrefix + "GetBucketPolicy"
 	OSSPutBucketPolicyAction       Action = OSSActionPrefix + "PutBucketPolicy"
 	OSSDeleteBucketPolicyAction    Action = OSSActionPrefix + "DeleteBucketPolicy"
-	OSSGetBucketPolicyStatusAction Action = OSSActionPrefix + "GetBucketPolicyStatus" // unsupported
+	OSSGetBucketPolicyStatusAction Action = OSSActionPrefix + "GetBucketPolicyStatus"
 
 	// Bucket ACL actions
 	OSSGetBucketAclAction Action = OSSActionPrefix + "GetBucketAcl"
@@ -115,57 +116,61 @@ const (
 	OSSDeleteBucketTaggingAction Action = OSSActionPrefix + "DeleteBucketTagging"
 
 	// Bucket lifecycle actions
-	OSSGetBucketLifecycleAction    Action = OSSActionPrefix + "GetBucketLifecycle"    // unsupported
-	OSSPutBucketLifecycleAction    Action = OSSActionPrefix + "PutBucketLifecycle"    // unsupported
-	OSSDeleteBucketLifecycleAction Action = OSSActionPrefix + "DeleteBucketLifecycle" // unsupported
+	OSSGetBucketLifecycleAction    Action = OSSActionPrefix + "GetBucketLifecycle"
+	OSSPutBucketLifecycleAction    Action = OSSActionPrefix + "PutBucketLifecycle"
+	OSSDeleteBucketLifecycleAction Action = OSSActionPrefix + "DeleteBucketLifecycle"
 
 	// Object storage version actions
-	OSSGetBucketVersioningAction Action = OSSActionPrefix + "GetBucketVersioning" // unsupported
-	OSSPutBucketVersioningAction Action = OSSActionPrefix + "PutBucketVersioning" // unsupported
-	OSSListObjectVersionsAction  Action = OSSActionPrefix + "ListObjectVersions"  // unsupported
+	OSSGetBucketVersioningAction Action = OSSActionPrefix + "GetBucketVersioning"
+	OSSPutBucketVersioningAction Action = OSSActionPrefix + "PutBucketVersioning"
+	OSSListObjectVersionsAction  Action = OSSActionPrefix + "ListObjectVersions"
 
 	// Object legal hold actions
-	OSSGetObjectLegalHoldAction Action = OSSActionPrefix + "GetObjectLegalHold" // unsupported
-	OSSPutObjectLegalHoldAction Action = OSSActionPrefix + "PutObjectLegalHold" // unsupported
+	OSSGetObjectLegalHoldAction Action = OSSActionPrefix + "GetObjectLegalHold"
+	OSSPutObjectLegalHoldAction Action = OSSActionPrefix + "PutObjectLegalHold"
 
 	// Object retention actions
-	OSSGetObjectRetentionAction Action = OSSActionPrefix + "GetObjectRetention" // unsupported
-	OSSPutObjectRetentionAction Action = OSSActionPrefix + "PutObjectRetention" // unsupported
+	OSSGetObjectRetentionAction Action = OSSActionPrefix + "GetObjectRetention"
+	OSSPutObjectRetentionAction Action = OSSActionPrefix + "PutObjectRetention"
 
 	// Bucket encryption actions
-	OSSGetBucketEncryptionAction    Action = OSSActionPrefix + "GetBucketEncryption"    // unsupported
-	OSSPutBucketEncryptionAction    Action = OSSActionPrefix + "PutBucketEncryption"    // unsupported
-	OSSDeleteBucketEncryptionAction Action = OSSActionPrefix + "DeleteBucketEncryption" // unsupported
+	OSSGetBucketEncryptionAction    Action = OSSActionPrefix + "GetBucketEncryption"
+	OSSPutBucketEncryptionAction    Action = OSSActionPrefix + "PutBucketEncryption"
+	OSSDeleteBucketEncryptionAction Action = OSSActionPrefix + "DeleteBucketEncryption"
 
 	// Bucket website actions
-	OSSGetBucketWebsiteAction    Action = OSSActionPrefix + "GetBucketWebsite"    // unsupported
-	OSSPutBucketWebsiteAction    Action = OSSActionPrefix + "PutBucketWebsite"    // unsupported
-	OSSDeleteBucketWebsiteAction Action = OSSActionPrefix + "DeleteBucketWebsite" // unsupported
+	OSSGetBucketWebsiteAction    Action = OSSActionPrefix + "GetBucketWebsite"
+	OSSPutBucketWebsiteAction    Action = OSSActionPrefix + "PutBucketWebsite"
+	OSSDeleteBucketWebsiteAction Action = OSSActionPrefix + "DeleteBucketWebsite"
 
 	// Object restore actions
-	OSSRestoreObjectAction Action = OSSActionPrefix + "RestoreObject" // unsupported
+	OSSRestoreObjectAction Action = OSSActionPrefix + "RestoreObject"
 
 	// Public access block actions
-	OSSGetPublicAccessBlockAction    Action = OSSActionPrefix + "GetPublicAccessBlock"   // unsupported
-	OSSPutPublicAccessBlockAction    Action = OSSActionPrefix + "PutPublicAccessBlock"   // unsupported
-	OSSDeletePublicAccessBlockAction Action = OSSActionPrefix + "DeletePulicAccessBlock" // unuspported
+	OSSGetPublicAccessBlockAction    Action = OSSActionPrefix + "GetPublicAccessBlock"
+	OSSPutPublicAccessBlockAction    Action = OSSActionPrefix + "PutPublicAccessBlock"
+	OSSDeletePublicAccessBlockAction Action = OSSActionPrefix + "DeletePublicAccessBlock"
 
 	// Bucket request payment actions
 	OSSGetBucketRequestPaymentAction Action = OSSActionPrefix + "GetBucketRequestPayment" // unsupported
 	OSSPutBucketRequestPaymentAction Action = OSSActionPrefix + "PutBucketRequestPayment" // unsupported
 
 	// Bucket replication actions
-	OSSGetBucketReplicationAction    Action = OSSActionPrefix + "GetBucketReplicationAction"    // unsupported
-	OSSPutBucketReplicationAction    Action = OSSActionPrefix + "PutBucketReplicationAction"    // unsupported
-	OSSDeleteBucketReplicationAction Action = OSSActionPrefix + "DeleteBucketReplicationAction" // unsupported
+	OSSGetBucketReplicationAction    Action = OSSActionPrefix + "GetBucketReplicationAction"
+	OSSPutBucketReplicationAction    Action = OSSActionPrefix + "PutBucketReplicationAction"
+	OSSDeleteBucketReplicationAction Action = OSSActionPrefix + "DeleteBucketReplicationAction"
+
+	// Bucket notification actions
+	OSSGetBucketNotificationAction Action = OSSActionPrefix + "GetBucketNotification"
+	OSSPutBucketNotificationAction Action = OSSActionPrefix + "PutBucketNotification"
 
 	// constants for POSIX file system interface
 	POSIXReadAction  Action = POSIXActionPrefix + "Read"
 	POSIXWriteAction Action = POSIXActionPrefix + "Write"
 
 	// Object lock actions
-	OSSPutObjectLockConfigurationAction Action = OSSActionPrefix + "PutObjectLockConfiguration" // unsupported
-	OSSGetObjectLockConfigurationAction Action = OSSActionPrefix + "GetObjectLockConfiguration" // unsupported
+	OSSPutObjectLockConfigurationAction Action = OSSActionPrefix + "PutObjectLockConfiguration"
+	OSSGetObjectLockConfigurationAction Action = OSSActionPrefix + "GetObjectLockConfiguration"
 
 	NoneAction Action = ""
 )
@@ -175,6 +180,7 @@ var (
 		// Object storage interface actions
 		OSSGetObjectAction,
 		OSSPutObjectAction,
+		OSSPostObjectAction,
 		OSSCopyObjectAction,
 		OSSListObjectsAction,
 		OSSDeleteObjectAction,
@@ -239,6 +245,8 @@ var (
 		OSSGetBucketReplicationAction,
 		OSSPutBucketReplicationAction,
 		OSSDeleteBucketReplicationAction,
+		OSSGetBucketNotificationAction,
+		OSSPutBucketNotificationAction,
 		OSSOptionsObjectAction,
 
 		// POSIX file system interface actions
@@ -414,6 +422,7 @@ var (
 			// Object storage interface actions
 			OSSGetObjectAction,
 			OSSPutObjectAction,
+			OSSPostObjectAction,
 			OSSCopyObjectAction,
 			OSSListObjectsAction,
 			OSSDeleteObjectAction,
@@ -0,0 +1,80 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"fmt"
+	"time"
+)
+
+// SnapshotDirName is the hidden directory in the root of the mount point, under which the snapshots
+// of the volume are browsable.
+const SnapshotDirName = ".snapshot"
+
+const (
+	SnapshotStatusCreating uint8 = iota
+	SnapshotStatusNormal
+	SnapshotStatusDeleting
+)
+
+// SnapshotInfo records a read-only snapshot of a directory tree of the volume. The meta partitions of the
+// volume keep the version of the snapshot, which is the metadata frozen at the point the snapshot is taken,
+// and the data extents referenced by the version are frozen on the data nodes, so that the files of the
+// live tree are written to them copy-on-write.
+type SnapshotInfo struct {
+	VolName    string `json:"vol"`
+	Id         uint64 `json:"id"`
+	Name       string `json:"name"`
+	Path       string `json:"path"`    // the path of the directory taken the snapshot of
+	RootInode  uint64 `json:"rootIno"` // the directory taken the snapshot of, read from the version of the snapshot
+	CreateTime int64  `json:"ct"`
+	Status     uint8  `json:"status"`
+}
+
+func (s *SnapshotInfo) String() string {
+	return fmt.Sprintf("SnapshotInfo{vol(%v) id(%v) name(%v) path(%v) rootIno(%v) createTime(%v) status(%v)}",
+		s.VolName, s.Id, s.Name, s.Path, s.RootInode, time.Unix(s.CreateTime, 0).Format(TimeFormat),
+		SnapshotStatusToString(s.Status))
+}
+
+func SnapshotStatusToString(status uint8) string {
+	switch status {
+	case SnapshotStatusCreating:
+		return "Creating"
+	case SnapshotStatusNormal:
+		return "Normal"
+	case SnapshotStatusDeleting:
+		return "Deleting"
+	default:
+		return "Unknown"
+	}
+}
+
+// The phases of the snapshot version of a meta partition. The partition is fenced by the prepare, the
+// writes are refused until the version is committed or aborted, or the fence is expired. The version is
+// the id of the snapshot.
+const (
+	SnapshotVersionPrepare uint8 = iota + 1
+	SnapshotVersionCommit
+	SnapshotVersionAbort
+	SnapshotVersionDelete
+)
+
+type SnapshotVersionRequest struct {
+	VolName     string `json:"vol"`
+	PartitionId uint64 `json:"pid"`
+	Version     uint64 `json:"ver"`
+	Op          uint8  `json:"op"`
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proto
+
+import (
+	"fmt"
+	"time"
+)
+
+// TrashEntry records the file deleted into the trash of the volume. The entry is kept by the meta partition
+// of the inode, and the inode is kept linked until the entry is purged.
+type TrashEntry struct {
+	Inode      uint64 `json:"ino"`
+	ParentId   uint64 `json:"parent"`
+	Name       string `json:"name"`
+	Path       string `json:"path"` // the original path of the file in the volume
+	Mode       uint32 `json:"mode"`
+	Size       uint64 `json:"size"`
+	DeleteTime int64  `json:"dt"` // unix timestamp in seconds
+}
+
+func (e *TrashEntry) String() string {
+	return fmt.Sprintf("TrashEntry{ino(%v) parent(%v) name(%v) path(%v) size(%v) deleteTime(%v)}",
+		e.Inode, e.ParentId, e.Name, e.Path, e.Size, time.Unix(e.DeleteTime, 0).Format(TimeFormat))
+}
+
+// TrashInodeRequest moves the inode of the dentry deleted into the trash instead of unlinking it. The inode
+// is unlinked as before if it is still linked by other dentries.
+type TrashInodeRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+	ParentId    uint64 `json:"parent"`
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	UniqID      uint64 `json:"uid"` //for request dedup
+}
+
+type TrashInodeResponse struct {
+	Info    *InodeInfo `json:"info"`
+	Trashed bool       `json:"trashed"`
+}
+
+type ListTrashRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+}
+
+type ListTrashResponse struct {
+	Entries []*TrashEntry `json:"entries"`
+}
+
+// RestoreTrashRequest takes the inode out of the trash, the dentry is created again by the caller.
+type RestoreTrashRequest struct {
+	VolName     string `json:"vol"`
+	PartitionID uint64 `json:"pid"`
+	Inode       uint64 `json:"ino"`
+}
+
+type RestoreTrashResponse struct {
+	Entry *TrashEntry `json:"entry"`
+}
+
+// PurgeTrashRequest unlinks the inodes in the trash, all the entries of the partition are purged if
+// Inodes is empty.
+type PurgeTrashRequest struct {
+	VolName     string   `json:"vol"`
+	PartitionID uint64   `json:"pid"`
+	Inodes      []uint64 `json:"inos"`
+}
+
+type PurgeTrashResponse struct {
+	Count int `json:"count"`
+}
@@ -94,15 +94,29 @@ type AKUser struct {
 }
 
 type UserInfo struct {
-	UserID      string       `json:"user_id" graphql:"user_id"`
-	AccessKey   string       `json:"access_key" graphql:"access_key"`
-	SecretKey   string       `json:"secret_key" graphql:"secret_key"`
-	Policy      *UserPolicy  `json:"policy" graphql:"policy"`
-	UserType    UserType     `json:"user_type" graphql:"user_type"`
-	CreateTime  string       `json:"create_time" graphql:"create_time"`
-	Description string       `json:"description" graphql:"description"`
-	Mu          sync.RWMutex `json:"-" graphql:"-"`
-	EMPTY       bool         //graphql need ???
+	UserID            string             `json:"user_id" graphql:"user_id"`
+	AccessKey         string             `json:"access_key" graphql:"access_key"`
+	SecretKey         string             `json:"secret_key" graphql:"secret_key"`
+	Policy            *UserPolicy        `json:"policy" graphql:"policy"`
+	UserType          UserType           `json:"user_type" graphql:"user_type"`
+	CreateTime        string             `json:"create_time" graphql:"create_time"`
+	Description       string             `json:"description" graphql:"description"`
+	PublicAccessBlock *PublicAccessBlock `json:"public_access_block,omitempty" graphql:"-"`
+	Mu                sync.RWMutex       `json:"-" graphql:"-"`
+	EMPTY             bool               //graphql need ???
+}
+
+// PublicAccessBlock is the user level public access block settings,
+// which apply to all the buckets owned by the user.
+type PublicAccessBlock struct {
+	BlockPublicAcls       bool `json:"block_public_acls"`
+	IgnorePublicAcls      bool `json:"ignore_public_acls"`
+	BlockPublicPolicy     bool `json:"block_public_policy"`
+	RestrictPublicBuckets bool `json:"restrict_public_buckets"`
+}
+
+func (b *PublicAccessBlock) IsEmpty() bool {
+	return b == nil || !(b.BlockPublicAcls || b.IgnorePublicAcls || b.BlockPublicPolicy || b.RestrictPublicBuckets)
 }
 
 func (i *UserInfo) String() string {
@@ -357,10 +371,11 @@ type UserTransferVolParam struct {
 }
 
 type UserUpdateParam struct {
-	UserID      string   `json:"user_id"`
-	AccessKey   string   `json:"access_key"`
-	SecretKey   string   `json:"secret_key"`
-	Type        UserType `json:"type"`
-	Password    string   `json:"password"`
-	Description string   `json:"description"`
+	UserID            string             `json:"user_id"`
+	AccessKey         string             `json:"access_key"`
+	SecretKey         string             `json:"secret_key"`
+	Type              UserType           `json:"type"`
+	Password          string             `json:"password"`
+	Description       string             `json:"description"`
+	PublicAccessBlock *PublicAccessBlock `json:"public_access_block,omitempty" graphql:"-"`
 }
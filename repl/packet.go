@@ -17,6 +17,7 @@ package repl
 import (
 	"fmt"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/trace"
 	"io"
 	"net"
 	"strings"
@@ -48,6 +49,9 @@ type Packet struct {
 	// used locally
 	shallDegrade bool
 	AfterPre     bool
+
+	// Span is the span serving the traced packet, nil if the packet is not traced
+	Span *trace.Span
 }
 
 type FollowerPacket struct {
@@ -89,6 +93,8 @@ func (p *FollowerPacket) identificationErrorResultCode(errLog string, errMsg str
 		p.ResultCode = proto.OpDiskNoSpaceErr
 	} else if strings.Contains(errMsg, storage.TryAgainError.Error()) {
 		p.ResultCode = proto.OpAgain
+	} else if strings.Contains(errMsg, storage.ExtentFrozenError.Error()) {
+		p.ResultCode = proto.OpNotPerm
 	} else if strings.Contains(errMsg, raft.ErrNotLeader.Error()) {
 		p.ResultCode = proto.OpTryOtherAddr
 	} else if strings.Contains(errMsg, raft.ErrStopped.Error()) {
@@ -132,6 +138,7 @@ func copyPacket(src *Packet, dst *FollowerPacket) {
 	dst.ExtentID = src.ExtentID
 	dst.ExtentOffset = src.ExtentOffset
 	dst.ReqID = src.ReqID
+	dst.Trace = src.Trace
 	dst.Data = src.OrgBuffer
 
 }
@@ -307,6 +314,8 @@ func (p *Packet) identificationErrorResultCode(errLog string, errMsg string) {
 		p.ResultCode = proto.OpDiskNoSpaceErr
 	} else if strings.Contains(errMsg, storage.TryAgainError.Error()) {
 		p.ResultCode = proto.OpAgain
+	} else if strings.Contains(errMsg, storage.ExtentFrozenError.Error()) {
+		p.ResultCode = proto.OpNotPerm
 	} else if strings.Contains(errMsg, raft.ErrNotLeader.Error()) {
 		p.ResultCode = proto.OpTryOtherAddr
 	} else if strings.Contains(errMsg, raft.ErrStopped.Error()) {
@@ -355,6 +364,7 @@ func (p *Packet) ReadFromConnFromCli(c net.Conn, deadlineTime time.Duration) (er
 		if err = proto.ReadFull(c, &p.Arg, int(p.ArgLen)); err != nil {
 			return
 		}
+		p.UnmarshalTraceArg()
 	}
 
 	if p.Size < 0 {
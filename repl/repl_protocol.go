@@ -26,6 +26,7 @@ import (
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/trace"
 )
 
 var (
@@ -275,6 +276,13 @@ func (rp *ReplProtocol) readPkgAndPrepare() (err error) {
 	}
 	log.LogDebugf("action[readPkgAndPrepare] packet(%v) from remote(%v) ",
 		request.GetUniqueLogId(), rp.sourceConn.RemoteAddr().String())
+	if request.Span = trace.StartRemoteSpan(request.Trace, "datanode."+request.GetOpMsg()); request.Span != nil {
+		request.Span.SetTag("dp", request.PartitionID)
+		request.Span.SetTag("extent", request.ExtentID)
+		request.Span.SetTag("size", request.Size)
+		// the packets forwarded to the followers are the children of the span
+		request.Trace = request.Span.Context()
+	}
 	if err = request.resolveFollowersAddr(); err != nil {
 		err = rp.putResponse(request)
 		return
@@ -384,6 +392,10 @@ func (rp *ReplProtocol) checkLocalResultAndReciveAllFollowerResponse() {
 	if request.IsErrPacket() {
 		return
 	}
+	if len(request.followersAddrs) > 0 {
+		span := request.Span.StartChild("datanode.WaitFollowers")
+		defer span.Finish()
+	}
 	for index := 0; index < len(request.followersAddrs); index++ {
 		followerPacket := request.followerPackets[index]
 		err := <-followerPacket.respCh
@@ -399,11 +411,15 @@ func (rp *ReplProtocol) checkLocalResultAndReciveAllFollowerResponse() {
 func (rp *ReplProtocol) writeResponse(reply *Packet) {
 	var err error
 	defer func() {
+		reply.Span.SetError(err)
+		reply.Span.Finish()
+		reply.Span = nil
 		reply.clean()
 	}()
 	if reply.IsErrPacket() {
 		err = fmt.Errorf(reply.LogMessage(ActionWriteToClient, rp.sourceConn.RemoteAddr().String(),
 			reply.StartT, fmt.Errorf(string(reply.Data[:reply.Size]))))
+		reply.Span.SetError(err)
 		if reply.ResultCode == proto.OpNotExistErr {
 			log.LogInfof(err.Error())
 		} else {
@@ -418,6 +434,8 @@ func (rp *ReplProtocol) writeResponse(reply *Packet) {
 		return
 	}
 
+	// the reply doesn't carry the trace
+	reply.Trace = trace.SpanContext{}
 	if err = reply.WriteToConn(rp.sourceConn); err != nil {
 		err = fmt.Errorf(reply.LogMessage(ActionWriteToClient, fmt.Sprintf("local(%v)->remote(%v)", rp.sourceConn.LocalAddr().String(),
 			rp.sourceConn.RemoteAddr().String()), reply.StartT, err))
@@ -21,6 +21,7 @@ import (
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/btree"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/trace"
 )
 
 // ExtentRequest defines the struct for the request of read or write an extent.
@@ -29,6 +30,7 @@ type ExtentRequest struct {
 	Size       int
 	Data       []byte
 	ExtentKey  *proto.ExtentKey
+	Trace      trace.SpanContext // trace carried by the packets of the request
 }
 
 // String returns the string format of the extent request.
@@ -52,6 +54,7 @@ type ExtentCache struct {
 	inode   uint64
 	gen     uint64 // generation number
 	size    uint64 // size of the cache
+	shared  bool   // some extents are shared with the clones or the snapshots
 	root    *btree.BTree
 	discard *btree.BTree
 }
@@ -66,33 +69,30 @@ func NewExtentCache(inode uint64) *ExtentCache {
 }
 
 func (cache *ExtentCache) RefreshForce(inode uint64, getExtents GetExtentsFunc) error {
-	gen, size, extents, err := getExtents(inode)
+	gen, size, extents, shared, err := getExtents(inode)
 	if err != nil {
 		return err
 	}
 	//log.LogDebugf("Local ExtentCache before update: ino(%v) gen(%v) size(%v) extents(%v)", inode, cache.gen, cache.size, cache.List())
-	cache.update(gen, size, extents)
+	cache.update(gen, size, extents, shared)
 	log.LogDebugf("Local ExtentCache after update: ino(%v) gen(%v) size(%v) extents(%v)", inode, cache.gen, cache.size, cache.List())
 	return nil
 }
 
-// Refresh refreshes the extent cache.
+// Refresh refreshes the extent cache. The cache already loaded is refreshed as well, since the extents may be
+// shared by a snapshot or a clone without changing the file, the extents of the older generation are ignored.
 func (cache *ExtentCache) Refresh(inode uint64, getExtents GetExtentsFunc) error {
-	if cache.root.Len() > 0 {
-		return nil
-	}
-
-	gen, size, extents, err := getExtents(inode)
+	gen, size, extents, shared, err := getExtents(inode)
 	if err != nil {
 		return err
 	}
 	//log.LogDebugf("Local ExtentCache before update: ino(%v) gen(%v) size(%v) extents(%v)", inode, cache.gen, cache.size, cache.List())
-	cache.update(gen, size, extents)
+	cache.update(gen, size, extents, shared)
 	log.LogDebugf("Local ExtentCache after update: ino(%v) gen(%v) size(%v) extents(%v)", inode, cache.gen, cache.size, cache.List())
 	return nil
 }
 
-func (cache *ExtentCache) update(gen, size uint64, eks []proto.ExtentKey) {
+func (cache *ExtentCache) update(gen, size uint64, eks []proto.ExtentKey, shared bool) {
 	cache.Lock()
 	defer cache.Unlock()
 
@@ -108,6 +108,8 @@ func (cache *ExtentCache) update(gen, size uint64, eks []proto.ExtentKey) {
 	//		log.LogDebugf("ExtentCache update: remote ino(%v) ek(%v)", cache.inode, ek)
 	//	}
 
+	// the extents may become shared by a clone without changing the file
+	cache.shared = cache.shared || shared
 	if cache.gen != 0 && cache.gen >= gen {
 		log.LogDebugf("ExtentCache update: no need to update, ino(%v) gen(%v) size(%v)", cache.inode, gen, size)
 		return
@@ -115,6 +117,7 @@ func (cache *ExtentCache) update(gen, size uint64, eks []proto.ExtentKey) {
 
 	cache.gen = gen
 	cache.size = size
+	cache.shared = shared
 	cache.root.Clear(false)
 	for _, ek := range eks {
 		extent := ek
@@ -214,6 +217,21 @@ func (cache *ExtentCache) Size() (size int, gen uint64) {
 	return int(cache.size), cache.gen
 }
 
+// Shared returns true if some extents of the file are shared with the others, in which case the data
+// must not be overwritten in place.
+func (cache *ExtentCache) Shared() bool {
+	cache.RLock()
+	defer cache.RUnlock()
+	return cache.shared
+}
+
+// SetShared marks some extents of the file shared, e.g. the data node refuses to overwrite an extent frozen.
+func (cache *ExtentCache) SetShared() {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.shared = true
+}
+
 // SetSize set the size of the cache.
 func (cache *ExtentCache) SetSize(size uint64, sync bool) {
 	cache.Lock()
@@ -0,0 +1,53 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package stream
+
+import (
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtentCacheRefreshShared(t *testing.T) {
+	eks := []proto.ExtentKey{{PartitionId: 1, ExtentId: 1025, Size: 100}}
+	shared := false
+	getExtents := func(inode uint64) (uint64, uint64, []proto.ExtentKey, bool, error) {
+		return 1, 100, eks, shared, nil
+	}
+
+	// the file is open before the snapshot is taken
+	cache := NewExtentCache(10)
+	require.NoError(t, cache.Refresh(10, getExtents))
+	require.False(t, cache.Shared())
+	require.Len(t, cache.List(), 1)
+
+	// the extents are shared by the snapshot without changing the file, the writes of the file already open
+	// go to the new extents once the cache is refreshed
+	shared = true
+	require.NoError(t, cache.Refresh(10, getExtents))
+	require.True(t, cache.Shared())
+	size, gen := cache.Size()
+	require.Equal(t, 100, size)
+	require.Equal(t, uint64(1), gen)
+
+	// the cache of the newer generation is not replaced by the older extents
+	cache.Append(&proto.ExtentKey{FileOffset: 100, PartitionId: 1, ExtentId: 1026, Size: 100}, true)
+	cache.update(2, 200, append(eks, proto.ExtentKey{FileOffset: 100, PartitionId: 1, ExtentId: 1026, Size: 100}), true)
+	require.NoError(t, cache.Refresh(10, getExtents))
+	size, gen = cache.Size()
+	require.Equal(t, 200, size)
+	require.Equal(t, uint64(2), gen)
+}
@@ -18,6 +18,7 @@ import (
 	"container/list"
 	"context"
 	"fmt"
+	"io"
 	"strings"
 	"sync"
 	"syscall"
@@ -37,8 +38,9 @@ import (
 )
 
 type AppendExtentKeyFunc func(parentInode, inode uint64, key proto.ExtentKey, discard []proto.ExtentKey) error
-type GetExtentsFunc func(inode uint64) (uint64, uint64, []proto.ExtentKey, error)
+type GetExtentsFunc func(inode uint64) (uint64, uint64, []proto.ExtentKey, bool, error)
 type TruncateFunc func(inode, size uint64) error
+type PunchHoleFunc func(inode, offset, size uint64) error
 type EvictIcacheFunc func(inode uint64)
 type LoadBcacheFunc func(key string, buf []byte, offset uint64, size uint32) (int, error)
 type CacheBcacheFunc func(key string, buf []byte) error
@@ -68,6 +70,7 @@ var (
 	flushRequestPool   *sync.Pool
 	releaseRequestPool *sync.Pool
 	truncRequestPool   *sync.Pool
+	punchRequestPool   *sync.Pool
 	evictRequestPool   *sync.Pool
 )
 
@@ -88,6 +91,9 @@ func init() {
 	truncRequestPool = &sync.Pool{New: func() interface{} {
 		return &TruncRequest{}
 	}}
+	punchRequestPool = &sync.Pool{New: func() interface{} {
+		return &PunchHoleRequest{}
+	}}
 	evictRequestPool = &sync.Pool{New: func() interface{} {
 		return &EvictRequest{}
 	}}
@@ -108,6 +114,7 @@ type ExtentConfig struct {
 	OnAppendExtentKey AppendExtentKeyFunc
 	OnGetExtents      GetExtentsFunc
 	OnTruncate        TruncateFunc
+	OnPunchHole       PunchHoleFunc
 	OnEvictIcache     EvictIcacheFunc
 	OnLoadBcache      LoadBcacheFunc
 	OnCacheBcache     CacheBcacheFunc
@@ -140,6 +147,7 @@ type ExtentClient struct {
 	appendExtentKey    AppendExtentKeyFunc
 	getExtents         GetExtentsFunc
 	truncate           TruncateFunc
+	punchHole          PunchHoleFunc   //May be null, must check before using
 	evictIcache        EvictIcacheFunc //May be null, must check before using
 	loadBcache         LoadBcacheFunc
 	cacheBcache        CacheBcacheFunc
@@ -247,6 +255,7 @@ retry:
 	client.appendExtentKey = config.OnAppendExtentKey
 	client.getExtents = config.OnGetExtents
 	client.truncate = config.OnTruncate
+	client.punchHole = config.OnPunchHole
 	client.evictIcache = config.OnEvictIcache
 	client.dataWrapper.InitFollowerRead(config.FollowerRead)
 	client.dataWrapper.SetNearRead(config.NearRead)
@@ -441,6 +450,11 @@ func (client *ExtentClient) SetFileSize(inode uint64, size int) {
 
 // Write writes the data.
 func (client *ExtentClient) Write(inode uint64, offset int, data []byte, flags int, checkFunc func() error) (write int, err error) {
+	return client.WriteWithContext(context.Background(), inode, offset, data, flags, checkFunc)
+}
+
+// WriteWithContext writes the data, the packets sent to the datanodes carry the trace of the context.
+func (client *ExtentClient) WriteWithContext(ctx context.Context, inode uint64, offset int, data []byte, flags int, checkFunc func() error) (write int, err error) {
 	prefix := fmt.Sprintf("Write{ino(%v)offset(%v)size(%v)}", inode, offset, len(data))
 	s := client.GetStreamer(inode)
 	if s == nil {
@@ -453,7 +467,7 @@ func (client *ExtentClient) Write(inode uint64, offset int, data []byte, flags i
 		s.GetExtents()
 	})
 
-	write, err = s.IssueWriteRequest(offset, data, flags, checkFunc)
+	write, err = s.IssueWriteRequest(ctx, offset, data, flags, checkFunc)
 	if err != nil {
 		log.LogError(errors.Stack(err))
 		exporter.Warning(err.Error())
@@ -487,6 +501,25 @@ func (client *ExtentClient) Truncate(mw *meta.MetaWrapper, parentIno uint64, ino
 	return err
 }
 
+// PunchHole removes the range [offset, offset+size) of the file, the size of the file is not changed.
+func (client *ExtentClient) PunchHole(inode uint64, offset, size int) error {
+	prefix := fmt.Sprintf("PunchHole{ino(%v)offset(%v)size(%v)}", inode, offset, size)
+	if client.punchHole == nil {
+		return syscall.EOPNOTSUPP
+	}
+	s := client.GetStreamer(inode)
+	if s == nil {
+		log.LogErrorf("Prefix(%v): stream is not opened yet", prefix)
+		return syscall.EBADF
+	}
+	err := s.IssuePunchHoleRequest(offset, size)
+	if err != nil {
+		err = errors.Trace(err, prefix)
+		log.LogError(errors.Stack(err))
+	}
+	return err
+}
+
 func (client *ExtentClient) Flush(inode uint64) error {
 	s := client.GetStreamer(inode)
 	if s == nil {
@@ -497,6 +530,11 @@ func (client *ExtentClient) Flush(inode uint64) error {
 }
 
 func (client *ExtentClient) Read(inode uint64, data []byte, offset int, size int) (read int, err error) {
+	return client.ReadWithContext(context.Background(), inode, data, offset, size)
+}
+
+// ReadWithContext reads the data, the packets sent to the datanodes carry the trace of the context.
+func (client *ExtentClient) ReadWithContext(ctx context.Context, inode uint64, data []byte, offset int, size int) (read int, err error) {
 	//log.LogErrorf("======> ExtentClient Read Enter, inode(%v), len(data)=(%v), offset(%v), size(%v).", inode, len(data), offset, size)
 	//t1 := time.Now()
 	if size == 0 {
@@ -518,7 +556,7 @@ func (client *ExtentClient) Read(inode uint64, data []byte, offset int, size int
 		return
 	}
 
-	read, err = s.read(data, offset, size)
+	read, err = s.read(ctx, data, offset, size)
 	// log.LogErrorf("======> ExtentClient Read Exit, inode(%v), time[%v us].", inode, time.Since(t1).Microseconds())
 	return
 }
@@ -597,6 +635,51 @@ func (client *ExtentClient) ReadExtent(inode uint64, ek *proto.ExtentKey, data [
 	}
 }
 
+// ReadVersion reads the data of the file in the snapshot version, whose extents are loaded in the cache by the
+// caller. The extents of the version are never changed, so the data is read from the data nodes directly instead
+// of through the streamer of the live file.
+func (client *ExtentClient) ReadVersion(ctx context.Context, cache *ExtentCache, data []byte, offset int, size int) (total int, err error) {
+	if size == 0 {
+		return
+	}
+	client.readLimiter.Wait(ctx)
+	client.LimitManager.ReadAlloc(ctx, size)
+	filesize, _ := cache.Size()
+	requests := cache.PrepareReadRequests(offset, size, data)
+	for _, req := range requests {
+		if req.ExtentKey == nil {
+			for i := range req.Data {
+				req.Data[i] = 0
+			}
+			if req.FileOffset+req.Size > filesize {
+				if req.FileOffset > filesize {
+					return
+				}
+				req.Size = filesize - req.FileOffset
+				total += req.Size
+				err = io.EOF
+				return
+			}
+			total += req.Size
+			continue
+		}
+		var partition *wrapper.DataPartition
+		if partition, err = client.dataWrapper.GetDataPartition(req.ExtentKey.PartitionId); err != nil {
+			return
+		}
+		reader := NewExtentReader(cache.inode, req.ExtentKey, partition, client.dataWrapper.FollowerRead(),
+			!proto.IsCold(client.volumeType))
+		var readBytes int
+		readBytes, err = reader.Read(req)
+		total += readBytes
+		if err != nil || readBytes < req.Size {
+			log.LogErrorf("ReadVersion: ino(%v) req(%v) readBytes(%v) err(%v)", cache.inode, req, readBytes, err)
+			return
+		}
+	}
+	return
+}
+
 // GetStreamer returns the streamer.
 func (client *ExtentClient) GetStreamer(inode uint64) *Streamer {
 	client.streamerLock.Lock()
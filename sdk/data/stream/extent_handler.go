@@ -170,6 +170,7 @@ func (eh *ExtentHandler) write(data []byte, offset, size int, direct bool) (ek *
 			if direct {
 				eh.packet.Opcode = proto.OpSyncWrite
 			}
+			eh.packet.Trace = eh.stream.trace
 			//log.LogDebugf("ExtentHandler Write: NewPacket, eh(%v) packet(%v)", eh, eh.packet)
 		}
 		packsize := int(eh.packet.Size)
@@ -59,6 +59,7 @@ func (reader *ExtentReader) Read(req *ExtentRequest) (readBytes int, err error)
 	size := req.Size
 
 	reqPacket := NewReadPacket(reader.key, offset, size, reader.inode, req.FileOffset, reader.followerRead)
+	reqPacket.Trace = req.Trace
 	sc := NewStreamConn(reader.dp, reader.followerRead)
 
 	log.LogDebugf("ExtentReader Read enter: size(%v) req(%v) reqPacket(%v)", size, req, reqPacket)
@@ -162,6 +162,7 @@ func (p *Packet) readFromConn(c net.Conn, deadlineTime time.Duration) (err error
 		if err = readToBuffer(c, &p.Arg, int(p.ArgLen)); err != nil {
 			return
 		}
+		p.UnmarshalTraceArg()
 	}
 
 	if p.Size < 0 {
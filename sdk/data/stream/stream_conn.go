@@ -29,6 +29,9 @@ import (
 var (
 	TryOtherAddrError = errors.New("TryOtherAddrError")
 	DpDiscardError    = errors.New("DpDiscardError")
+	// ExtentFrozenError is returned if the extent overwritten is frozen by the data node, which is shared
+	// with the clones or the snapshots and is written to the new extents instead.
+	ExtentFrozenError = errors.New("ExtentFrozenError")
 )
 
 const (
@@ -28,6 +28,7 @@ import (
 	"github.com/cubefs/cubefs/util/buf"
 	"github.com/cubefs/cubefs/util/exporter"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/trace"
 )
 
 // One inode corresponds to one streamer. All the requests to the same inode will be queued.
@@ -59,6 +60,8 @@ type Streamer struct {
 	writeLock            sync.Mutex
 	inflightEvictL1cache sync.Map
 	pendingCache         chan bcacheKey
+
+	trace trace.SpanContext // trace of the write being processed, carried by the packets of the write
 }
 
 type bcacheKey struct {
@@ -127,7 +130,7 @@ func (s *Streamer) GetExtentReader(ek *proto.ExtentKey) (*ExtentReader, error) {
 	return reader, nil
 }
 
-func (s *Streamer) read(data []byte, offset int, size int) (total int, err error) {
+func (s *Streamer) read(ctx context.Context, data []byte, offset int, size int) (total int, err error) {
 	//log.LogErrorf("==========> Streamer Read Enter, inode(%v).", s.inode)
 	//t1 := time.Now()
 	var (
@@ -137,7 +140,15 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 		revisedRequests []*ExtentRequest
 	)
 
-	ctx := context.Background()
+	span, ctx := trace.StartSpan(ctx, "stream.Read")
+	span.SetTag("ino", s.inode)
+	span.SetTag("offset", offset)
+	span.SetTag("size", size)
+	defer func() {
+		span.SetError(err)
+		span.Finish()
+	}()
+
 	s.client.readLimiter.Wait(ctx)
 	s.client.LimitManager.ReadAlloc(ctx, size)
 	requests = s.extents.PrepareReadRequests(offset, size, data)
@@ -238,6 +249,7 @@ func (s *Streamer) read(data []byte, offset int, size int) (total int, err error
 
 			}
 
+			req.Trace = span.Context()
 			readBytes, err = reader.Read(req)
 			log.LogDebugf("TRACE Stream read: ino(%v) req(%v) readBytes(%v) err(%v)", s.inode, req, readBytes, err)
 
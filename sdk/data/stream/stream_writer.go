@@ -29,6 +29,7 @@ import (
 	"github.com/cubefs/cubefs/util"
 	"github.com/cubefs/cubefs/util/errors"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/trace"
 )
 
 const (
@@ -63,6 +64,7 @@ type WriteRequest struct {
 	err        error
 	done       chan struct{}
 	checkFunc  func() error
+	ctx        context.Context
 }
 
 // FlushRequest defines a flush request.
@@ -84,6 +86,14 @@ type TruncRequest struct {
 	done chan struct{}
 }
 
+// PunchHoleRequest defines a punch hole request.
+type PunchHoleRequest struct {
+	offset int
+	size   int
+	err    error
+	done   chan struct{}
+}
+
 // EvictRequest defines an evict request.
 type EvictRequest struct {
 	err  error
@@ -101,7 +111,7 @@ func (s *Streamer) IssueOpenRequest() error {
 	return nil
 }
 
-func (s *Streamer) IssueWriteRequest(offset int, data []byte, flags int, checkFunc func() error) (write int, err error) {
+func (s *Streamer) IssueWriteRequest(ctx context.Context, offset int, data []byte, flags int, checkFunc func() error) (write int, err error) {
 	if atomic.LoadInt32(&s.status) >= StreamerError {
 		return 0, errors.New(fmt.Sprintf("IssueWriteRequest: stream writer in error status, ino(%v)", s.inode))
 	}
@@ -114,6 +124,7 @@ func (s *Streamer) IssueWriteRequest(offset int, data []byte, flags int, checkFu
 	request.flags = flags
 	request.done = make(chan struct{}, 1)
 	request.checkFunc = checkFunc
+	request.ctx = ctx
 
 	s.request <- request
 	s.writeLock.Unlock()
@@ -121,6 +132,7 @@ func (s *Streamer) IssueWriteRequest(offset int, data []byte, flags int, checkFu
 	<-request.done
 	err = request.err
 	write = request.writeBytes
+	request.ctx = nil
 	writeRequestPool.Put(request)
 	return
 }
@@ -157,6 +169,18 @@ func (s *Streamer) IssueTruncRequest(size int) error {
 	return err
 }
 
+func (s *Streamer) IssuePunchHoleRequest(offset, size int) error {
+	request := punchRequestPool.Get().(*PunchHoleRequest)
+	request.offset = offset
+	request.size = size
+	request.done = make(chan struct{}, 1)
+	s.request <- request
+	<-request.done
+	err := request.err
+	punchRequestPool.Put(request)
+	return err
+}
+
 func (s *Streamer) IssueEvictRequest() error {
 	request := evictRequestPool.Get().(*EvictRequest)
 	request.done = make(chan struct{}, 1)
@@ -238,6 +262,9 @@ func (s *Streamer) abortRequest(request interface{}) {
 	case *TruncRequest:
 		request.err = syscall.EAGAIN
 		request.done <- struct{}{}
+	case *PunchHoleRequest:
+		request.err = syscall.EAGAIN
+		request.done <- struct{}{}
 	case *FlushRequest:
 		request.err = syscall.EAGAIN
 		request.done <- struct{}{}
@@ -257,11 +284,14 @@ func (s *Streamer) handleRequest(request interface{}) {
 		s.open()
 		request.done <- struct{}{}
 	case *WriteRequest:
-		request.writeBytes, request.err = s.write(request.data, request.fileOffset, request.size, request.flags, request.checkFunc)
+		request.writeBytes, request.err = s.write(request.ctx, request.data, request.fileOffset, request.size, request.flags, request.checkFunc)
 		request.done <- struct{}{}
 	case *TruncRequest:
 		request.err = s.truncate(request.size)
 		request.done <- struct{}{}
+	case *PunchHoleRequest:
+		request.err = s.punchHole(request.offset, request.size)
+		request.done <- struct{}{}
 	case *FlushRequest:
 		request.err = s.flush()
 		request.done <- struct{}{}
@@ -275,9 +305,20 @@ func (s *Streamer) handleRequest(request interface{}) {
 	}
 }
 
-func (s *Streamer) write(data []byte, offset, size, flags int, checkFunc func() error) (total int, err error) {
+func (s *Streamer) write(ctx context.Context, data []byte, offset, size, flags int, checkFunc func() error) (total int, err error) {
 	var direct bool
 
+	span, ctx := trace.StartSpan(ctx, "stream.Write")
+	span.SetTag("ino", s.inode)
+	span.SetTag("offset", offset)
+	span.SetTag("size", size)
+	s.trace = span.Context()
+	defer func() {
+		s.trace = trace.SpanContext{}
+		span.SetError(err)
+		span.Finish()
+	}()
+
 	if flags&proto.FlagsSyncWrite != 0 {
 		direct = true
 	}
@@ -289,7 +330,6 @@ func (s *Streamer) write(data []byte, offset, size, flags int, checkFunc func()
 
 	log.LogDebugf("Streamer write enter: ino(%v) offset(%v) size(%v)", s.inode, offset, size)
 
-	ctx := context.Background()
 	s.client.writeLimiter.Wait(ctx)
 
 	requests := s.extents.PrepareWriteRequests(offset, size, data)
@@ -310,10 +350,20 @@ func (s *Streamer) write(data []byte, offset, size, flags int, checkFunc func()
 		break
 	}
 
+	// the data shared with the clones is not overwritten in place, but written to the new extents instead
+	shared := s.extents.Shared()
 	for _, req := range requests {
 		var writeSize int
-		if req.ExtentKey != nil {
+		if req.ExtentKey != nil && !shared {
 			writeSize, err = s.doOverwrite(req, direct)
+			if err == ExtentFrozenError {
+				// the extent is shared since the cache is loaded, the rest is written to the new extents
+				s.extents.SetShared()
+				shared = true
+				var n int
+				n, err = s.doWrite(req.Data[writeSize:], req.FileOffset+writeSize, req.Size-writeSize, direct)
+				writeSize += n
+			}
 			if s.client.bcacheEnable {
 				cacheKey := util.GenerateRepVolKey(s.client.volumeName, s.inode, req.ExtentKey.PartitionId, req.ExtentKey.ExtentId, uint64(req.FileOffset))
 				if _, ok := s.inflightEvictL1cache.Load(cacheKey); !ok {
@@ -387,6 +437,7 @@ func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool) (total int, err
 		if direct {
 			reqPacket.Opcode = proto.OpSyncRandomWrite
 		}
+		reqPacket.Trace = s.trace
 		packSize := util.Min(size-total, util.BlockSize)
 		copy(reqPacket.Data[:packSize], req.Data[total:total+packSize])
 		reqPacket.Size = uint32(packSize)
@@ -415,6 +466,11 @@ func (s *Streamer) doOverwrite(req *ExtentRequest, direct bool) (total int, err
 		reqPacket.Data = nil
 		log.LogDebugf("doOverwrite: ino(%v) req(%v) reqPacket(%v) err(%v) replyPacket(%v)", s.inode, req, reqPacket, err, replyPacket)
 
+		if err == nil && replyPacket.ResultCode == proto.OpNotPerm {
+			log.LogWarnf("doOverwrite: extent frozen, ino(%v) req(%v) replyPacket(%v)", s.inode, req, replyPacket)
+			err = ExtentFrozenError
+			break
+		}
 		if err != nil || replyPacket.ResultCode != proto.OpOk {
 			err = errors.New(fmt.Sprintf("doOverwrite: failed or reply NOK: err(%v) ino(%v) req(%v) replyPacket(%v)", err, s.inode, req, replyPacket))
 			break
@@ -446,7 +502,8 @@ func (s *Streamer) doWrite(data []byte, offset, size int, direct bool) (total in
 
 	log.LogDebugf("doWrite enter: ino(%v) offset(%v) size(%v) storeMode(%v)", s.inode, offset, size, storeMode)
 	if proto.IsHot(s.client.volumeType) {
-		if storeMode == proto.NormalExtentType && (s.handler == nil || s.handler != nil && s.handler.fileOffset+s.handler.size != offset) {
+		// the extent shared with the clones must not be appended by both of the files
+		if storeMode == proto.NormalExtentType && (s.handler == nil || s.handler != nil && s.handler.fileOffset+s.handler.size != offset) && !s.extents.Shared() {
 			if currentEK := s.extents.GetEnd(uint64(offset)); currentEK != nil && !storage.IsTinyExtent(currentEK.ExtentId) {
 				s.closeOpenHandler()
 
@@ -689,6 +746,22 @@ func (s *Streamer) truncate(size int) error {
 	return s.GetExtentsForce()
 }
 
+// punchHole flushes the dirty data, so that the extents punched by the meta node are the latest,
+// and then reloads the extents without the range.
+func (s *Streamer) punchHole(offset, size int) error {
+	s.closeOpenHandler()
+	err := s.flush()
+	if err != nil {
+		return err
+	}
+
+	err = s.client.punchHole(s.inode, uint64(offset), uint64(size))
+	if err != nil {
+		return err
+	}
+	return s.GetExtentsForce()
+}
+
 func (s *Streamer) tinySizeLimit() int {
 	return util.DefaultTinySizeLimit
 }
@@ -290,6 +290,8 @@ func (api *AdminAPI) UpdateVolume(
 	request.addParam("replicaNum", strconv.FormatUint(uint64(vv.DpReplicaNum), 10))
 	request.addParam("enableQuota", strconv.FormatBool(vv.EnableQuota))
 	request.addParam("deleteLockTime", strconv.FormatInt(vv.DeleteLockTime, 10))
+	request.addParam("trashInterval", strconv.FormatInt(vv.TrashInterval, 10))
+	request.addParam("dirShards", strconv.Itoa(vv.DirShards))
 
 	if txMask != "" {
 		request.addParam("enableTxMask", txMask)
@@ -354,7 +356,7 @@ func (api *AdminAPI) VolExpand(volName string, capacity uint64, authKey string)
 func (api *AdminAPI) CreateVolName(volName, owner string, capacity uint64, deleteLockTime int64, crossZone, normalZonesFirst bool, business string,
 	mpCount, replicaNum, size, volType int, followerRead bool, zoneName, cacheRuleKey string, ebsBlkSize,
 	cacheCapacity, cacheAction, cacheThreshold, cacheTTL, cacheHighWater, cacheLowWater, cacheLRUInterval int,
-	dpReadOnlyWhenVolFull bool, txMask string, txTimeout uint32, txConflictRetryNum int64, txConflictRetryInterval int64, optEnableQuota string) (err error) {
+	dpReadOnlyWhenVolFull bool, txMask string, txTimeout uint32, txConflictRetryNum int64, txConflictRetryInterval int64, optEnableQuota, metaStoreMode string) (err error) {
 	var request = newAPIRequest(http.MethodGet, proto.AdminCreateVol)
 	request.addParam("name", volName)
 	request.addParam("owner", owner)
@@ -380,6 +382,9 @@ func (api *AdminAPI) CreateVolName(volName, owner string, capacity uint64, delet
 	request.addParam("cacheLRUInterval", strconv.Itoa(cacheLRUInterval))
 	request.addParam("dpReadOnlyWhenVolFull", strconv.FormatBool(dpReadOnlyWhenVolFull))
 	request.addParam("enableQuota", optEnableQuota)
+	if metaStoreMode != "" {
+		request.addParam("metaStoreMode", metaStoreMode)
+	}
 	if txMask != "" {
 		request.addParam("enableTxMask", txMask)
 	}
@@ -687,6 +692,66 @@ func (api *AdminAPI) GetQuota(volName string, quotaId string) (quotaInfo *proto.
 	return quotaInfo, err
 }
 
+func (api *AdminAPI) CreateSnapshot(volName, snapshot, snapPath string) (info *proto.SnapshotInfo, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.SnapshotCreate)
+	request.addParam("name", volName)
+	request.addParam("snapshot", snapshot)
+	request.addParam("fullPath", snapPath)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		log.LogErrorf("action[CreateSnapshot] fail. %v", err)
+		return
+	}
+	info = &proto.SnapshotInfo{}
+	if err = json.Unmarshal(data, info); err != nil {
+		log.LogErrorf("action[CreateSnapshot] fail. %v", err)
+		return
+	}
+	log.LogInfof("action[CreateSnapshot] %v success.", info)
+	return
+}
+
+func (api *AdminAPI) UpdateSnapshot(volName, snapshot string, rootIno uint64, status uint8) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.SnapshotUpdate)
+	request.addParam("name", volName)
+	request.addParam("snapshot", snapshot)
+	request.addParam("rootIno", strconv.FormatUint(rootIno, 10))
+	request.addParam("status", strconv.FormatUint(uint64(status), 10))
+	if _, err = api.mc.serveRequest(request); err != nil {
+		log.LogErrorf("action[UpdateSnapshot] fail. %v", err)
+		return
+	}
+	log.LogInfof("action[UpdateSnapshot] vol(%v) snapshot(%v) rootIno(%v) status(%v) success.", volName, snapshot, rootIno, status)
+	return
+}
+
+func (api *AdminAPI) DeleteSnapshot(volName, snapshot string) (err error) {
+	var request = newAPIRequest(http.MethodGet, proto.SnapshotDelete)
+	request.addParam("name", volName)
+	request.addParam("snapshot", snapshot)
+	if _, err = api.mc.serveRequest(request); err != nil {
+		log.LogErrorf("action[DeleteSnapshot] fail. %v", err)
+		return
+	}
+	log.LogInfof("action[DeleteSnapshot] vol(%v) snapshot(%v) success.", volName, snapshot)
+	return
+}
+
+func (api *AdminAPI) ListSnapshot(volName string) (snapshots []*proto.SnapshotInfo, err error) {
+	var request = newAPIRequest(http.MethodGet, proto.SnapshotList)
+	request.addParam("name", volName)
+	var data []byte
+	if data, err = api.mc.serveRequest(request); err != nil {
+		log.LogErrorf("action[ListSnapshot] fail. %v", err)
+		return
+	}
+	if err = json.Unmarshal(data, &snapshots); err != nil {
+		log.LogErrorf("action[ListSnapshot] fail. %v", err)
+		return
+	}
+	return
+}
+
 func (api *AdminAPI) QueryBadDisks() (badDisks *proto.BadDiskInfos, err error) {
 	var buf []byte
 	var request = newAPIRequest(http.MethodGet, proto.QueryBadDisks)
@@ -17,6 +17,7 @@ package meta
 import (
 	"fmt"
 	syslog "log"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -121,11 +122,25 @@ func (mw *MetaWrapper) Create_ll(parentID uint64, name string, mode, uid, gid ui
 		txMask = proto.TxOpMaskMknod
 	}
 	txType := proto.TxMaskToType(txMask)
-	if mw.enableTx(txMask) && txType != proto.TxTypeUndefined {
-		return mw.txCreate_ll(parentID, name, mode, uid, gid, target, txType)
-	} else {
-		return mw.create_ll(parentID, name, mode, uid, gid, target)
+	var info *proto.InodeInfo
+	err := mw.withDentryParent(parentID, name, func(parent uint64) (err error) {
+		if mw.enableTx(txMask) && txType != proto.TxTypeUndefined {
+			info, err = mw.txCreate_ll(parent, name, mode, uid, gid, target, txType)
+		} else {
+			info, err = mw.create_ll(parent, name, mode, uid, gid, target)
+		}
+		return
+	})
+	if err != nil {
+		return nil, err
 	}
+	if proto.IsDir(mode) && mw.volDirShards > 0 {
+		// the directory is still usable if not sharded
+		if err = mw.shardDir(info.Inode, mw.volDirShards); err != nil {
+			log.LogWarnf("Create_ll: shard dir(%v) err(%v)", info.Inode, err)
+		}
+	}
+	return info, nil
 }
 
 func (mw *MetaWrapper) txCreate_ll(parentID uint64, name string, mode, uid, gid uint32, target []byte, txType uint32) (info *proto.InodeInfo, err error) {
@@ -289,7 +304,7 @@ create_dentry:
 		status, err = mw.dcreate(parentMP, parentID, name, info.Inode, mode)
 	}
 	if err != nil {
-		if status == statusOpDirQuota || status == statusNoSpace {
+		if status == statusOpDirQuota || status == statusNoSpace || status == statusDirSharded {
 			mw.iunlink(mp, info.Inode)
 			mw.ievict(mp, info.Inode)
 		}
@@ -314,6 +329,14 @@ create_dentry:
 }
 
 func (mw *MetaWrapper) Lookup_ll(parentID uint64, name string) (inode uint64, mode uint32, err error) {
+	err = mw.withDentryParent(parentID, name, func(parent uint64) (err error) {
+		inode, mode, err = mw.lookup_ll(parent, name)
+		return
+	})
+	return
+}
+
+func (mw *MetaWrapper) lookup_ll(parentID uint64, name string) (inode uint64, mode uint32, err error) {
 	parentMP := mw.getPartitionByInode(parentID)
 	if parentMP == nil {
 		log.LogErrorf("Lookup_ll: No parent partition, parentID(%v) name(%v)", parentID, name)
@@ -486,12 +509,53 @@ func (mw *MetaWrapper) BatchGetXAttr(inodes []uint64, keys []string) ([]*proto.X
 	return xattrs, nil
 }
 
-func (mw *MetaWrapper) Delete_ll(parentID uint64, name string, isDir bool) (*proto.InodeInfo, error) {
-	if mw.enableTx(proto.TxOpMaskRemove) {
-		return mw.txDelete_ll(parentID, name, isDir)
-	} else {
-		return mw.delete_ll(parentID, name, isDir)
+// Delete_ll deletes the dentry, the file is moved into the trash if the trash of the volume is enabled,
+// in which case fullPath is recorded as the original path of the file.
+func (mw *MetaWrapper) Delete_ll(parentID uint64, name string, isDir bool, fullPath string) (info *proto.InodeInfo, err error) {
+	var shards []uint64
+	err = mw.withDentryParent(parentID, name, func(parent uint64) (err error) {
+		if isDir {
+			if shards, err = mw.childDirShards(parent, name); err != nil {
+				return
+			}
+		}
+		// the file moved into the trash is kept linked, which needs no transaction
+		if mw.enableTx(proto.TxOpMaskRemove) && (isDir || !mw.trashEnabled()) {
+			info, err = mw.txDelete_ll(parent, name, isDir)
+		} else {
+			info, err = mw.delete_ll(parent, name, isDir, fullPath)
+		}
+		if err != nil && shards != nil {
+			mw.fenceDirShards(shards, false)
+			shards = nil
+		}
+		return
+	})
+	if err == nil && info != nil && shards != nil {
+		mw.deleteDirShards(info.Inode, shards)
 	}
+	return
+}
+
+// childDirShards returns the shards of the child directory to delete, which are fenced by the meta nodes
+// only if they keep no dentries, so that no dentry is created in them before the directory is deleted.
+func (mw *MetaWrapper) childDirShards(parentID uint64, name string) ([]uint64, error) {
+	inode, mode, err := mw.lookup_ll(parentID, name)
+	if err != nil || !proto.IsDir(mode) {
+		return nil, err
+	}
+	shards, err := mw.loadDirShards(inode)
+	if err != nil || shards == nil {
+		return nil, err
+	}
+	if err = mw.fenceDirShards(shards, true); err != nil {
+		return nil, err
+	}
+	return shards, nil
+}
+
+func (mw *MetaWrapper) trashEnabled() bool {
+	return mw.volTrashInterval > 0
 }
 
 func (mw *MetaWrapper) txDelete_ll(parentID uint64, name string, isDir bool) (info *proto.InodeInfo, err error) {
@@ -614,7 +678,7 @@ func (mw *MetaWrapper) txDelete_ll(parentID uint64, name string, isDir bool) (in
  * Note that the return value of InodeInfo might be nil without error,
  * and the caller should make sure InodeInfo is valid before using it.
  */
-func (mw *MetaWrapper) delete_ll(parentID uint64, name string, isDir bool) (*proto.InodeInfo, error) {
+func (mw *MetaWrapper) delete_ll(parentID uint64, name string, isDir bool, fullPath string) (*proto.InodeInfo, error) {
 	var (
 		status          int
 		inode           uint64
@@ -708,8 +772,17 @@ func (mw *MetaWrapper) delete_ll(parentID uint64, name string, isDir bool) (*pro
 		return nil, nil
 	}
 
-	status, info, err = mw.iunlink(mp, inode)
+	if !isDir && mw.trashEnabled() {
+		status, info, _, err = mw.itrash(mp, inode, parentID, name, fullPath)
+	} else {
+		status, info, err = mw.iunlink(mp, inode)
+	}
 	if err != nil || status != statusOK {
+		if status == statusNotPerm {
+			// The inode is protected against deletion, e.g. by object lock, so put the dentry back.
+			mw.restoreDentry(parentMP, parentID, name, mp, inode)
+			return nil, syscall.EPERM
+		}
 		return nil, nil
 	}
 
@@ -727,11 +800,25 @@ func (mw *MetaWrapper) delete_ll(parentID uint64, name string, isDir bool) (*pro
 }
 
 func (mw *MetaWrapper) Rename_ll(srcParentID uint64, srcName string, dstParentID uint64, dstName string, overwritten bool) (err error) {
-	if mw.enableTx(proto.TxOpMaskRename) {
-		return mw.txRename_ll(srcParentID, srcName, dstParentID, dstName, overwritten)
-	} else {
-		return mw.rename_ll(srcParentID, srcName, dstParentID, dstName, overwritten)
+	rename := func() error {
+		srcParent := mw.dentryParent(srcParentID, srcName)
+		dstParent := mw.dentryParent(dstParentID, dstName)
+		if mw.enableTx(proto.TxOpMaskRename) {
+			return mw.txRename_ll(srcParent, srcName, dstParent, dstName, overwritten)
+		} else {
+			return mw.rename_ll(srcParent, srcName, dstParent, dstName, overwritten)
+		}
+	}
+	if err = rename(); err != syscall.EREMOTE {
+		return
 	}
+	// either parent is found sharded
+	for _, parentID := range []uint64{srcParentID, dstParentID} {
+		if _, err = mw.loadDirShards(parentID); err != nil {
+			return
+		}
+	}
+	return rename()
 }
 
 func (mw *MetaWrapper) txRename_ll(srcParentID uint64, srcName string, dstParentID uint64, dstName string, overwritten bool) (err error) {
@@ -964,6 +1051,10 @@ func (mw *MetaWrapper) rename_ll(srcParentID uint64, srcName string, dstParentID
 		if status == statusOpDirQuota {
 			return statusToErrno(status)
 		}
+		if status == statusDirSharded {
+			mw.iunlink(srcMP, inode)
+			return statusToErrno(status)
+		}
 		return syscall.EAGAIN
 	}
 	var srcInodeInfo *proto.InodeInfo
@@ -1079,6 +1170,10 @@ func (mw *MetaWrapper) rename_ll(srcParentID uint64, srcName string, dstParentID
 
 // Read all dentries with parentID
 func (mw *MetaWrapper) ReadDir_ll(parentID uint64) ([]proto.Dentry, error) {
+	return mw.readDirShards(parentID, 0, mw.readDir_ll)
+}
+
+func (mw *MetaWrapper) readDir_ll(parentID uint64) ([]proto.Dentry, error) {
 	parentMP := mw.getPartitionByInode(parentID)
 	if parentMP == nil {
 		return nil, syscall.ENOENT
@@ -1093,6 +1188,12 @@ func (mw *MetaWrapper) ReadDir_ll(parentID uint64) ([]proto.Dentry, error) {
 
 // Read limit count dentries with parentID, start from string
 func (mw *MetaWrapper) ReadDirLimit_ll(parentID uint64, from string, limit uint64) ([]proto.Dentry, error) {
+	return mw.readDirShards(parentID, limit, func(parent uint64) ([]proto.Dentry, error) {
+		return mw.readDirLimit_ll(parent, from, limit)
+	})
+}
+
+func (mw *MetaWrapper) readDirLimit_ll(parentID uint64, from string, limit uint64) ([]proto.Dentry, error) {
 	parentMP := mw.getPartitionByInode(parentID)
 	if parentMP == nil {
 		return nil, syscall.ENOENT
@@ -1106,6 +1207,12 @@ func (mw *MetaWrapper) ReadDirLimit_ll(parentID uint64, from string, limit uint6
 }
 
 func (mw *MetaWrapper) DentryCreate_ll(parentID uint64, name string, inode uint64, mode uint32) error {
+	return mw.withDentryParent(parentID, name, func(parent uint64) error {
+		return mw.dentryCreate_ll(parent, name, inode, mode)
+	})
+}
+
+func (mw *MetaWrapper) dentryCreate_ll(parentID uint64, name string, inode uint64, mode uint32) error {
 	parentMP := mw.getPartitionByInode(parentID)
 	if parentMP == nil {
 		return syscall.ENOENT
@@ -1119,6 +1226,14 @@ func (mw *MetaWrapper) DentryCreate_ll(parentID uint64, name string, inode uint6
 }
 
 func (mw *MetaWrapper) DentryUpdate_ll(parentID uint64, name string, inode uint64) (oldInode uint64, err error) {
+	err = mw.withDentryParent(parentID, name, func(parent uint64) (err error) {
+		oldInode, err = mw.dentryUpdate_ll(parent, name, inode)
+		return
+	})
+	return
+}
+
+func (mw *MetaWrapper) dentryUpdate_ll(parentID uint64, name string, inode uint64) (oldInode uint64, err error) {
 	parentMP := mw.getPartitionByInode(parentID)
 	if parentMP == nil {
 		err = syscall.ENOENT
@@ -1198,18 +1313,25 @@ func (mw *MetaWrapper) AppendObjExtentKeys(inode uint64, eks []proto.ObjExtentKe
 }
 
 func (mw *MetaWrapper) GetExtents(inode uint64) (gen uint64, size uint64, extents []proto.ExtentKey, err error) {
+	gen, size, extents, _, err = mw.GetExtentsWithShared(inode)
+	return
+}
+
+// GetExtentsWithShared returns the extents of the inode, and whether any of the extents is shared with the clones,
+// e.g. the files of the snapshots, which must not be overwritten in place.
+func (mw *MetaWrapper) GetExtentsWithShared(inode uint64) (gen uint64, size uint64, extents []proto.ExtentKey, shared bool, err error) {
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
-		return 0, 0, nil, syscall.ENOENT
+		return 0, 0, nil, false, syscall.ENOENT
 	}
 
-	status, gen, size, extents, err := mw.getExtents(mp, inode)
+	status, gen, size, extents, shared, err := mw.getExtents(mp, inode)
 	if err != nil || status != statusOK {
 		log.LogErrorf("GetExtents: ino(%v) err(%v) status(%v)", inode, err, status)
-		return 0, 0, nil, statusToErrno(status)
+		return 0, 0, nil, false, statusToErrno(status)
 	}
-	log.LogDebugf("GetExtents: ino(%v) gen(%v) size(%v) extents(%v)", inode, gen, size, extents)
-	return gen, size, extents, nil
+	log.LogDebugf("GetExtents: ino(%v) gen(%v) size(%v) extents(%v) shared(%v)", inode, gen, size, extents, shared)
+	return gen, size, extents, shared, nil
 }
 
 func (mw *MetaWrapper) GetObjExtents(inode uint64) (gen uint64, size uint64, extents []proto.ExtentKey, objExtents []proto.ObjExtentKey, err error) {
@@ -1257,13 +1379,85 @@ func (mw *MetaWrapper) Truncate(inode, size uint64) error {
 
 }
 
-func (mw *MetaWrapper) Link(parentID uint64, name string, ino uint64) (*proto.InodeInfo, error) {
-	//if mw.EnableTransaction {
-	if mw.EnableTransaction&proto.TxOpMaskLink > 0 {
-		return mw.txLink(parentID, name, ino)
-	} else {
-		return mw.link(parentID, name, ino)
+// PunchHole removes the range [offset, offset+size) of the file, the size of the file is not changed.
+func (mw *MetaWrapper) PunchHole(inode, offset, size uint64) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("PunchHole: No inode partition, ino(%v)", inode)
+		return syscall.ENOENT
+	}
+
+	status, err := mw.punchHole(mp, inode, offset, size)
+	if err != nil || status != statusOK {
+		return statusToErrno(status)
+	}
+	return nil
+}
+
+// CloneInode_ll clones the range [offset, offset+size) of the file into the destination at dstOffset, the data
+// extents are shared by the files instead of being copied. The size of 0 clones the data to the end of the file,
+// and the destination of 0 creates a new inode, which is not linked into any directory, in the partition of the
+// source. Since the shared extents are counted by the meta partition, EXDEV is returned if the destination is
+// in a different partition.
+func (mw *MetaWrapper) CloneInode_ll(inode, offset, size, dstInode, dstOffset uint64, mode, uid, gid uint32) (*proto.InodeInfo, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("CloneInode_ll: No inode partition, ino(%v)", inode)
+		return nil, syscall.ENOENT
+	}
+	if dstInode != 0 && (dstInode < mp.Start || dstInode > mp.End) {
+		return nil, syscall.EXDEV
+	}
+
+	req := &proto.CloneInodeRequest{
+		Inode:     inode,
+		Offset:    offset,
+		Size:      size,
+		DstInode:  dstInode,
+		DstOffset: dstOffset,
+		Mode:      mode,
+		Uid:       uid,
+		Gid:       gid,
+	}
+	status, info, err := mw.inodeClone(mp, req)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	return info, nil
+}
+
+// InodeCreateNear_ll creates the inode in the meta partition of the given inode, with which the extents can
+// be shared.
+func (mw *MetaWrapper) InodeCreateNear_ll(inode uint64, mode, uid, gid uint32) (*proto.InodeInfo, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("InodeCreateNear_ll: No inode partition, ino(%v)", inode)
+		return nil, syscall.ENOENT
+	}
+	status, info, err := mw.icreate(mp, mode, uid, gid, nil)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
 	}
+	return info, nil
+}
+
+// IsSamePartition returns true if the inodes are in the same meta partition, the data of which can be cloned.
+func (mw *MetaWrapper) IsSamePartition(ino1, ino2 uint64) bool {
+	mp := mw.getPartitionByInode(ino1)
+	return mp != nil && ino2 >= mp.Start && ino2 <= mp.End
+}
+
+func (mw *MetaWrapper) Link(parentID uint64, name string, ino uint64) (info *proto.InodeInfo, err error) {
+	err = mw.withDentryParent(parentID, name, func(parent uint64) (err error) {
+		//if mw.EnableTransaction {
+		if mw.EnableTransaction&proto.TxOpMaskLink > 0 {
+			info, err = mw.txLink(parent, name, ino)
+		} else {
+			info, err = mw.link(parent, name, ino)
+		}
+		return
+	})
+	return
 }
 
 func (mw *MetaWrapper) txLink(parentID uint64, name string, ino uint64) (info *proto.InodeInfo, err error) {
@@ -1401,6 +1595,9 @@ func (mw *MetaWrapper) link(parentID uint64, name string, ino uint64) (*proto.In
 		status, err = mw.dcreate(parentMP, parentID, name, ino, info.Mode)
 	}
 	if err != nil {
+		if status == statusDirSharded {
+			mw.iunlink(mp, ino)
+		}
 		return nil, statusToErrno(status)
 	} else if status != statusOK {
 		if status != statusExist {
@@ -1726,6 +1923,17 @@ func (mw *MetaWrapper) ListMultipart_ll(prefix, delimiter, keyMarker string, mul
 
 func (mw *MetaWrapper) XAttrSet_ll(inode uint64, name, value []byte) error {
 	var err error
+	if string(name) == proto.DirShardsXAttrKey {
+		var count int
+		if count, err = proto.ParseDirShardsCount(string(value)); err != nil {
+			log.LogErrorf("XAttrSet_ll: inode(%v) err(%v)", inode, err)
+			return syscall.EINVAL
+		}
+		return mw.shardDir(inode, count)
+	}
+	if string(name) == proto.DirShardFenceXAttrKey {
+		return syscall.EPERM
+	}
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
 		log.LogErrorf("XAttrSet_ll: no such partition, inode(%v)", inode)
@@ -1765,7 +1973,7 @@ func (mw *MetaWrapper) XAttrGetAll_ll(inode uint64) (*proto.XAttrInfo, error) {
 		return nil, syscall.ENOENT
 	}
 
-	attrs, status, err := mw.getAllXAttr(mp, inode)
+	attrs, status, err := mw.getAllXAttr(mp, inode, "")
 	if err != nil || status != statusOK {
 		return nil, statusToErrno(status)
 	}
@@ -1780,6 +1988,48 @@ func (mw *MetaWrapper) XAttrGetAll_ll(inode uint64) (*proto.XAttrInfo, error) {
 	return xAttr, nil
 }
 
+// XAttrRecords_ll returns the records of the prefix kept in the extended attributes of the inode, by the keys.
+func (mw *MetaWrapper) XAttrRecords_ll(inode uint64, prefix string) (map[string]string, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("XAttrRecords_ll: no such partition, ino(%v)", inode)
+		return nil, syscall.ENOENT
+	}
+
+	attrs, status, err := mw.getAllXAttr(mp, inode, prefix)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	// the meta node not upgraded returns all the attributes
+	for key := range attrs {
+		if !strings.HasPrefix(key, prefix) {
+			delete(attrs, key)
+		}
+	}
+	log.LogDebugf("XAttrRecords_ll: volume(%v) inode(%v) prefix(%v) records(%v)",
+		mw.volname, inode, prefix, len(attrs))
+	return attrs, nil
+}
+
+// UpdateXAttrRecords_ll removes the records of the prefix and appends the new record atomically, and returns
+// the key of the record appended, see proto.UpdateXAttrRecordsRequest. syscall.ENOENT is returned if any record
+// removed does not exist with the value given any more, in which case nothing is changed.
+func (mw *MetaWrapper) UpdateXAttrRecords_ll(inode uint64, prefix string, remove map[string]string, append string) (string, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("UpdateXAttrRecords_ll: no such partition, ino(%v)", inode)
+		return "", syscall.ENOENT
+	}
+
+	key, status, err := mw.updateXAttrRecords(mp, inode, prefix, remove, append)
+	if err != nil || status != statusOK {
+		return "", statusToErrno(status)
+	}
+	log.LogDebugf("UpdateXAttrRecords_ll: volume(%v) inode(%v) prefix(%v) removed(%v) key(%v)",
+		mw.volname, inode, prefix, len(remove), key)
+	return key, nil
+}
+
 func (mw *MetaWrapper) XAttrGet_ll(inode uint64, name string) (*proto.XAttrInfo, error) {
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
@@ -1808,6 +2058,9 @@ func (mw *MetaWrapper) XAttrGet_ll(inode uint64, name string) (*proto.XAttrInfo,
 // XAttrDel_ll is a low-level meta api that deletes specified xattr.
 func (mw *MetaWrapper) XAttrDel_ll(inode uint64, name string) error {
 	var err error
+	if name == proto.DirShardFenceXAttrKey {
+		return syscall.EPERM
+	}
 	mp := mw.getPartitionByInode(inode)
 	if mp == nil {
 		log.LogErrorf("XAttrDel_ll: no such partition, inode(%v)", inode)
@@ -1841,6 +2094,10 @@ func (mw *MetaWrapper) UpdateSummary_ll(parentIno uint64, filesInc int64, dirsIn
 	if filesInc == 0 && dirsInc == 0 && bytesInc == 0 {
 		return
 	}
+	// the summary of the sharded directory is kept in the directory itself
+	if owner := mw.DirShardOwner(parentIno); owner != 0 {
+		parentIno = owner
+	}
 	mp := mw.getPartitionByInode(parentIno)
 	if mp == nil {
 		log.LogErrorf("UpdateSummary_ll: no such partition, inode(%v)", parentIno)
@@ -1856,6 +2113,10 @@ func (mw *MetaWrapper) UpdateSummary_ll(parentIno uint64, filesInc int64, dirsIn
 }
 
 func (mw *MetaWrapper) ReadDirOnly_ll(parentID uint64) ([]proto.Dentry, error) {
+	return mw.readDirShards(parentID, 0, mw.readDirOnly_ll)
+}
+
+func (mw *MetaWrapper) readDirOnly_ll(parentID uint64) ([]proto.Dentry, error) {
 	parentMP := mw.getPartitionByInode(parentID)
 	if parentMP == nil {
 		return nil, syscall.ENOENT
@@ -2223,3 +2484,377 @@ func (mw *MetaWrapper) RevokeQuota_ll(parentIno uint64, quotaId uint32, maxConcu
 	err = mw.revokeQuota(parentIno, quotaId, &numInodes, &curInodeCount, &inodes, maxConcurrencyInode, true)
 	return
 }
+
+// SetFileLock_ll acquires or releases an advisory lock of the inode on behalf of the client session,
+// syscall.EAGAIN is returned with the conflicting lock if the lock is held by the others.
+func (mw *MetaWrapper) SetFileLock_ll(inode uint64, lock *proto.FileLock) (conflict *proto.FileLock, err error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("SetFileLock_ll: no such partition, inode(%v)", inode)
+		return nil, syscall.ENOENT
+	}
+
+	lock.Session = mw.lockSession
+	if lock.Type != proto.FileLockUnlock {
+		// the partition is registered for the renewal before the request, so that no lock acquired is missed
+		mw.lockMutex.Lock()
+		mw.lockPartitions[mp.PartitionID] = time.Now()
+		mw.lockMutex.Unlock()
+	}
+	status, conflict, err := mw.setFileLock(mp, inode, lock)
+	// only the conflict is reported as EAGAIN, which is retried by the waiters, the failures are not
+	switch {
+	case status == statusExist:
+		return conflict, syscall.EAGAIN
+	case err != nil, status == statusError, status == statusAgain:
+		log.LogErrorf("SetFileLock_ll: inode(%v) lock(%v) status(%v) err(%v)", inode, lock, status, err)
+		return nil, syscall.EIO
+	case status != statusOK:
+		return nil, statusToErrno(status)
+	}
+	log.LogDebugf("SetFileLock_ll: inode(%v) lock(%v)", inode, lock)
+	return nil, nil
+}
+
+// GetFileLock_ll returns the lock of the others which conflicts with the given lock, or nil if there is none.
+func (mw *MetaWrapper) GetFileLock_ll(inode uint64, lock *proto.FileLock) (*proto.FileLock, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("GetFileLock_ll: no such partition, inode(%v)", inode)
+		return nil, syscall.ENOENT
+	}
+
+	lock.Session = mw.lockSession
+	status, conflict, err := mw.getFileLock(mp, inode, lock)
+	if err != nil || status == statusError || status == statusAgain {
+		log.LogErrorf("GetFileLock_ll: inode(%v) lock(%v) status(%v) err(%v)", inode, lock, status, err)
+		return nil, syscall.EIO
+	}
+	if status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	return conflict, nil
+}
+
+// renewFileLocksTick renews the leases of the locks held by the client, the partitions without
+// any lock held are no longer renewed.
+func (mw *MetaWrapper) renewFileLocksTick() {
+	ticker := time.NewTicker(proto.FileLockRenewInterval * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			start := time.Now()
+			mw.lockMutex.Lock()
+			pids := make([]uint64, 0, len(mw.lockPartitions))
+			for pid := range mw.lockPartitions {
+				pids = append(pids, pid)
+			}
+			mw.lockMutex.Unlock()
+
+			for _, pid := range pids {
+				mp := mw.getPartitionByID(pid)
+				if mp == nil {
+					continue
+				}
+				status, count, err := mw.renewFileLock(mp)
+				if err != nil || status != statusOK {
+					log.LogWarnf("renewFileLocksTick: renew fail, mp(%v) status(%v) err(%v)", pid, status, err)
+					continue
+				}
+				if count == 0 {
+					mw.lockMutex.Lock()
+					if mw.lockPartitions[pid].Before(start) {
+						delete(mw.lockPartitions, pid)
+					}
+					mw.lockMutex.Unlock()
+				}
+			}
+		case <-mw.closeCh:
+			return
+		}
+	}
+}
+
+// releaseFileLocks_ll releases all the locks held by the client, e.g. the volume is unmounted.
+func (mw *MetaWrapper) releaseFileLocks_ll() {
+	mw.lockMutex.Lock()
+	pids := make([]uint64, 0, len(mw.lockPartitions))
+	for pid := range mw.lockPartitions {
+		pids = append(pids, pid)
+	}
+	mw.lockPartitions = make(map[uint64]time.Time)
+	mw.lockMutex.Unlock()
+
+	for _, pid := range pids {
+		mp := mw.getPartitionByID(pid)
+		if mp == nil {
+			continue
+		}
+		if status, err := mw.releaseFileLocks(mp); err != nil || status != statusOK {
+			log.LogWarnf("releaseFileLocks_ll: release fail, mp(%v) status(%v) err(%v)", pid, status, err)
+		}
+	}
+}
+
+// AddOrphanInode_ll records the inode unlinked while it is open by the client, so that the meta node
+// evicts the inode if the client is gone without evicting it.
+func (mw *MetaWrapper) AddOrphanInode_ll(inode uint64) error {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("AddOrphanInode_ll: no such partition, inode(%v)", inode)
+		return syscall.ENOENT
+	}
+
+	// the partition is registered for the renewal before the request, so that no inode added is missed
+	mw.orphanMutex.Lock()
+	mw.orphanPartitions[mp.PartitionID] = time.Now()
+	mw.orphanMutex.Unlock()
+	status, err := mw.addOrphanInode(mp, inode)
+	if err != nil || status != statusOK {
+		return statusToErrno(status)
+	}
+	log.LogDebugf("AddOrphanInode_ll: inode(%v) session(%v)", inode, mw.lockSession)
+	return nil
+}
+
+// renewOrphanSessionTick renews the lease of the orphan inodes held by the client, the partitions without
+// any orphan inode held are no longer renewed.
+func (mw *MetaWrapper) renewOrphanSessionTick() {
+	ticker := time.NewTicker(proto.OrphanSessionRenewInterval * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			start := time.Now()
+			mw.orphanMutex.Lock()
+			pids := make([]uint64, 0, len(mw.orphanPartitions))
+			for pid := range mw.orphanPartitions {
+				pids = append(pids, pid)
+			}
+			mw.orphanMutex.Unlock()
+
+			for _, pid := range pids {
+				mp := mw.getPartitionByID(pid)
+				if mp == nil {
+					continue
+				}
+				status, count, err := mw.renewOrphanSession(mp)
+				if err != nil || status != statusOK {
+					log.LogWarnf("renewOrphanSessionTick: renew fail, mp(%v) status(%v) err(%v)", pid, status, err)
+					continue
+				}
+				if count == 0 {
+					mw.orphanMutex.Lock()
+					if mw.orphanPartitions[pid].Before(start) {
+						delete(mw.orphanPartitions, pid)
+					}
+					mw.orphanMutex.Unlock()
+				}
+			}
+		case <-mw.closeCh:
+			return
+		}
+	}
+}
+
+// WatchChanges_ll waits for the inodes of the partition changed after the seq in the epoch, the meta node
+// returns in a few seconds if nothing has changed.
+func (mw *MetaWrapper) WatchChanges_ll(pid, epoch, seq uint64) (*proto.WatchChangesResponse, error) {
+	mp := mw.getPartitionByID(pid)
+	if mp == nil {
+		log.LogErrorf("WatchChanges_ll: no such partition(%v)", pid)
+		return nil, syscall.ENOENT
+	}
+	status, resp, err := mw.watchChanges(mp, epoch, seq)
+	if err != nil {
+		return nil, err
+	}
+	if status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	return resp, nil
+}
+
+// ReadChangeFeed_ll reads the changes of the partition applied after the apply id, at most limit of them,
+// the meta node returns in a few seconds if nothing is applied.
+func (mw *MetaWrapper) ReadChangeFeed_ll(pid, applyID uint64, limit int) (*proto.ReadChangeFeedResponse, error) {
+	mp := mw.getPartitionByID(pid)
+	if mp == nil {
+		log.LogErrorf("ReadChangeFeed_ll: no such partition(%v)", pid)
+		return nil, syscall.ENOENT
+	}
+	status, resp, err := mw.readChangeFeed(mp, applyID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	return resp, nil
+}
+
+// MetaPartitionIDs returns the ids of the meta partitions of the volume.
+func (mw *MetaWrapper) MetaPartitionIDs() []uint64 {
+	partitions := mw.getAllPartitions()
+	ids := make([]uint64, 0, len(partitions))
+	for _, mp := range partitions {
+		ids = append(ids, mp.PartitionID)
+	}
+	return ids
+}
+
+// MetaPartitionIDOf returns the id of the meta partition which the inode belongs to, or 0 if not found.
+func (mw *MetaWrapper) MetaPartitionIDOf(ino uint64) uint64 {
+	mp := mw.getPartitionByInode(ino)
+	if mp == nil {
+		return 0
+	}
+	return mp.PartitionID
+}
+
+// ListTrash_ll returns the files in the trash of the volume ordered by the deletion time.
+func (mw *MetaWrapper) ListTrash_ll() ([]*proto.TrashEntry, error) {
+	entries := make([]*proto.TrashEntry, 0)
+	for _, mp := range mw.getAllPartitions() {
+		status, list, err := mw.listTrash(mp)
+		if err != nil || status != statusOK {
+			log.LogErrorf("ListTrash_ll: list fail, mp(%v) status(%v) err(%v)", mp.PartitionID, status, err)
+			return nil, statusToErrno(status)
+		}
+		entries = append(entries, list...)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].DeleteTime != entries[j].DeleteTime {
+			return entries[i].DeleteTime < entries[j].DeleteTime
+		}
+		return entries[i].Inode < entries[j].Inode
+	})
+	return entries, nil
+}
+
+// RestoreTrash_ll puts the file in the trash back to the directory it was deleted from, the directories
+// of the original path are created again if the directory has been deleted since.
+func (mw *MetaWrapper) RestoreTrash_ll(inode uint64) (*proto.TrashEntry, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		log.LogErrorf("RestoreTrash_ll: no such partition, inode(%v)", inode)
+		return nil, syscall.ENOENT
+	}
+	status, list, err := mw.listTrash(mp)
+	if err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	var entry *proto.TrashEntry
+	for _, e := range list {
+		if e.Inode == inode {
+			entry = e
+			break
+		}
+	}
+	if entry == nil {
+		return nil, syscall.ENOENT
+	}
+
+	parentID, err := mw.trashRestoreParent(entry)
+	if err != nil {
+		log.LogErrorf("RestoreTrash_ll: no parent, entry(%v) err(%v)", entry, err)
+		return nil, err
+	}
+	if _, _, err = mw.Lookup_ll(parentID, entry.Name); err == nil {
+		return nil, syscall.EEXIST
+	} else if err != syscall.ENOENT {
+		return nil, err
+	}
+
+	if status, entry, err = mw.restoreTrash(mp, inode); err != nil || status != statusOK {
+		return nil, statusToErrno(status)
+	}
+	if err = mw.DentryCreate_ll(parentID, entry.Name, inode, entry.Mode); err != nil {
+		// put the file back into the trash
+		if status, _, _, trashErr := mw.itrash(mp, inode, entry.ParentId, entry.Name, entry.Path); trashErr != nil || status != statusOK {
+			log.LogErrorf("RestoreTrash_ll: trash again fail, entry(%v) status(%v) err(%v)", entry, status, trashErr)
+		}
+		log.LogErrorf("RestoreTrash_ll: dentry create fail, parentID(%v) entry(%v) err(%v)", parentID, entry, err)
+		return nil, err
+	}
+	entry.ParentId = parentID
+
+	if mw.EnableSummary {
+		go mw.UpdateSummary_ll(parentID, 1, 0, int64(entry.Size))
+	}
+	log.LogDebugf("RestoreTrash_ll: entry(%v)", entry)
+	return entry, nil
+}
+
+// trashRestoreParent returns the directory which the file in the trash is restored to.
+func (mw *MetaWrapper) trashRestoreParent(entry *proto.TrashEntry) (uint64, error) {
+	info, err := mw.InodeGet_ll(entry.ParentId)
+	if err == nil && proto.IsDir(info.Mode) {
+		return entry.ParentId, nil
+	}
+	if err != nil && err != syscall.ENOENT {
+		return 0, err
+	}
+	if entry.Path == "" {
+		return 0, syscall.ENOENT
+	}
+	return mw.makeDirs(entry.Path[:strings.LastIndex(entry.Path, "/")+1])
+}
+
+// makeDirs creates the directories of the absolute path which do not exist, and returns the inode of the last one.
+func (mw *MetaWrapper) makeDirs(dirPath string) (ino uint64, err error) {
+	ino = proto.RootIno
+	for _, name := range strings.Split(dirPath, "/") {
+		if name == "" {
+			continue
+		}
+		child, mode, err := mw.Lookup_ll(ino, name)
+		if err == syscall.ENOENT {
+			var info *proto.InodeInfo
+			if info, err = mw.Create_ll(ino, name, proto.Mode(os.ModeDir|0755), 0, 0, nil); err == nil {
+				child, mode = info.Inode, info.Mode
+			} else if err == syscall.EEXIST {
+				child, mode, err = mw.Lookup_ll(ino, name)
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !proto.IsDir(mode) {
+			return 0, syscall.ENOTDIR
+		}
+		ino = child
+	}
+	return ino, nil
+}
+
+// PurgeTrash_ll deletes the files in the trash permanently, all the files in the trash are purged
+// if no inode is given.
+func (mw *MetaWrapper) PurgeTrash_ll(inodes []uint64) (count int, err error) {
+	partitions := make(map[*MetaPartition][]uint64)
+	if len(inodes) == 0 {
+		for _, mp := range mw.getAllPartitions() {
+			partitions[mp] = nil
+		}
+	}
+	for _, ino := range inodes {
+		mp := mw.getPartitionByInode(ino)
+		if mp == nil {
+			log.LogErrorf("PurgeTrash_ll: no such partition, inode(%v)", ino)
+			return count, syscall.ENOENT
+		}
+		partitions[mp] = append(partitions[mp], ino)
+	}
+	for mp, list := range partitions {
+		status, n, err := mw.purgeTrash(mp, list)
+		if err != nil || status != statusOK {
+			log.LogErrorf("PurgeTrash_ll: purge fail, mp(%v) status(%v) err(%v)", mp.PartitionID, status, err)
+			return count, statusToErrno(status)
+		}
+		count += n
+	}
+	return count, nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package meta
+
+import (
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	changeFeedRetryInterval   = time.Second
+	changeFeedRefreshInterval = time.Minute
+	changeFeedBatchBuffer     = 64
+)
+
+// ChangeBatch is the changes read from a meta partition, in the order they are applied.
+type ChangeBatch struct {
+	PartitionID uint64
+	// ApplyID is the cursor of the partition once the batch is consumed.
+	ApplyID uint64
+	// Truncated is set if the changes before ApplyID are lost, the partition has to be rescanned before
+	// the batch is committed.
+	Truncated bool
+	Events    []*proto.ChangeEvent
+}
+
+// ChangeFeed is the merged change feed of all the meta partitions of the volume. The changes of a partition
+// are delivered in order, while the changes of different partitions are not ordered with each other.
+// The cursors are advanced as the batches are committed by the consumer, and are persisted by the consumer
+// to resume the feed.
+type ChangeFeed struct {
+	sync.Mutex
+	mw *MetaWrapper
+	// the apply id of each partition, the changes up to which are consumed
+	cursors map[uint64]uint64
+	// the number of the batches of each partition delivered but not committed yet
+	pending map[uint64]int
+	reading map[uint64]bool
+	batches chan *ChangeBatch
+	closeC  chan struct{}
+	limit   int
+}
+
+// NewChangeFeed starts reading the changes of the volume after the cursors, the partitions without a cursor
+// are read from the beginning of their feeds. At most limit changes are read from a partition at once, the
+// default limit of the meta node is used if limit is 0.
+func (mw *MetaWrapper) NewChangeFeed(cursors map[uint64]uint64, limit int) *ChangeFeed {
+	f := &ChangeFeed{
+		mw:      mw,
+		cursors: make(map[uint64]uint64, len(cursors)),
+		pending: make(map[uint64]int),
+		reading: make(map[uint64]bool),
+		batches: make(chan *ChangeBatch, changeFeedBatchBuffer),
+		closeC:  make(chan struct{}),
+		limit:   limit,
+	}
+	for pid, applyID := range cursors {
+		f.cursors[pid] = applyID
+	}
+	f.refreshReaders()
+	go f.run()
+	return f
+}
+
+// Batches returns the channel of the batches read, which is never closed.
+func (f *ChangeFeed) Batches() <-chan *ChangeBatch {
+	return f.batches
+}
+
+// Commit advances the cursor of the partition once the batch is consumed.
+func (f *ChangeFeed) Commit(batch *ChangeBatch) {
+	f.Lock()
+	defer f.Unlock()
+	if f.pending[batch.PartitionID] > 0 {
+		f.pending[batch.PartitionID]--
+	}
+	if batch.ApplyID > f.cursors[batch.PartitionID] || batch.Truncated {
+		f.cursors[batch.PartitionID] = batch.ApplyID
+	}
+}
+
+// Cursors returns the cursors of the partitions to resume the feed with.
+func (f *ChangeFeed) Cursors() map[uint64]uint64 {
+	f.Lock()
+	defer f.Unlock()
+	cursors := make(map[uint64]uint64, len(f.cursors))
+	for pid, applyID := range f.cursors {
+		cursors[pid] = applyID
+	}
+	return cursors
+}
+
+func (f *ChangeFeed) Close() {
+	close(f.closeC)
+}
+
+func (f *ChangeFeed) run() {
+	ticker := time.NewTicker(changeFeedRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.closeC:
+			return
+		case <-ticker.C:
+			f.refreshReaders()
+		}
+	}
+}
+
+// refreshReaders reads the partitions not read yet, e.g. the ones newly created.
+func (f *ChangeFeed) refreshReaders() {
+	for _, pid := range f.mw.MetaPartitionIDs() {
+		f.Lock()
+		reading := f.reading[pid]
+		f.reading[pid] = true
+		applyID := f.cursors[pid]
+		f.Unlock()
+		if !reading {
+			go f.read(pid, applyID)
+		}
+	}
+}
+
+// read delivers the changes of the partition after the apply id.
+func (f *ChangeFeed) read(pid, applyID uint64) {
+	for {
+		select {
+		case <-f.closeC:
+			return
+		default:
+		}
+		resp, err := f.mw.ReadChangeFeed_ll(pid, applyID, f.limit)
+		if err != nil {
+			log.LogWarnf("ChangeFeed: read partition(%v) apply(%v) err(%v)", pid, applyID, err)
+			if err == syscall.ENOENT {
+				f.Lock()
+				delete(f.reading, pid)
+				f.Unlock()
+				return
+			}
+			time.Sleep(changeFeedRetryInterval)
+			continue
+		}
+		if !resp.Truncated && len(resp.Events) == 0 {
+			// nothing to consume, the cursor is advanced if the batches delivered are all consumed
+			f.Lock()
+			if f.pending[pid] == 0 && resp.ApplyID > f.cursors[pid] {
+				f.cursors[pid] = resp.ApplyID
+			}
+			f.Unlock()
+			applyID = resp.ApplyID
+			continue
+		}
+		if resp.Truncated {
+			log.LogWarnf("ChangeFeed: partition(%v) truncated before apply(%v), read from apply(%v)",
+				pid, applyID, resp.ApplyID)
+		}
+		batch := &ChangeBatch{
+			PartitionID: pid,
+			ApplyID:     resp.ApplyID,
+			Truncated:   resp.Truncated,
+			Events:      resp.Events,
+		}
+		f.Lock()
+		f.pending[pid]++
+		f.Unlock()
+		select {
+		case f.batches <- batch:
+		case <-f.closeC:
+			return
+		}
+		applyID = resp.ApplyID
+	}
+}
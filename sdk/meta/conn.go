@@ -15,8 +15,11 @@
 package meta
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -24,6 +27,7 @@ import (
 
 	"github.com/cubefs/cubefs/proto"
 	"github.com/cubefs/cubefs/util/log"
+	"github.com/cubefs/cubefs/util/trace"
 )
 
 const (
@@ -76,6 +80,17 @@ func (mw *MetaWrapper) sendToMetaPartition(mp *MetaPartition, req *proto.Packet)
 	errs := make(map[int]error, len(mp.Members))
 	var j int
 
+	// the packet joins the trace of the caller if the caller has set it, otherwise a new trace is sampled
+	span := trace.StartRemoteSpan(req.Trace, "meta."+req.GetOpMsg())
+	if span == nil {
+		span, _ = trace.StartSpan(context.Background(), "meta."+req.GetOpMsg())
+	}
+	span.SetKind(trace.SpanKindClient)
+	span.SetTag("mp", mp.PartitionID)
+	if span != nil {
+		req.Trace = span.Context()
+	}
+
 	addr = mp.LeaderAddr
 	if addr == "" {
 		err = errors.New(fmt.Sprintf("sendToMetaPartition: failed due to empty leader addr and goto retry, req(%v) mp(%v)", req, mp))
@@ -127,6 +142,17 @@ retry:
 	}
 
 out:
+	if err == nil && resp != nil && resp.ResultCode == proto.OpMetaPartitionMovedErr {
+		if next := mw.movedPartition(mp, req, resp); next != nil {
+			span.Finish()
+			return mw.sendToMetaPartition(next, req)
+		}
+	}
+	if err == nil && resp != nil && resp.ResultCode != proto.OpOk {
+		span.SetTag("result", resp.GetResultMsg())
+	}
+	span.SetError(err)
+	span.Finish()
 	if err != nil || resp == nil {
 		return nil, errors.New(fmt.Sprintf("sendToMetaPartition failed: req(%v) mp(%v) errs(%v) resp(%v)", req, mp, errs, resp))
 	}
@@ -134,6 +160,42 @@ out:
 	return resp, nil
 }
 
+// movedPartition re-routes the request to the partition its inode is moved to by a split,
+// it returns nil if the partition is not found in the view.
+func (mw *MetaWrapper) movedPartition(mp *MetaPartition, req *proto.Packet, resp *proto.Packet) *MetaPartition {
+	moved := &proto.MetaPartitionMovedResponse{}
+	if err := json.Unmarshal(resp.Data, moved); err != nil || moved.PartitionID == mp.PartitionID {
+		log.LogWarnf("movedPartition: invalid response, req(%v) mp(%v) resp(%v) err(%v)", req, mp, resp, err)
+		return nil
+	}
+	next := mw.getPartitionByID(moved.PartitionID)
+	if next == nil {
+		mw.triggerAndWaitForceUpdate()
+		if next = mw.getPartitionByID(moved.PartitionID); next == nil {
+			log.LogWarnf("movedPartition: mp(%v) not found, req(%v)", moved.PartitionID, req)
+			return nil
+		}
+	}
+	body := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(req.Data, &body); err != nil {
+		log.LogWarnf("movedPartition: req(%v) err(%v)", req, err)
+		return nil
+	}
+	if _, ok := body["pid"]; ok {
+		body["pid"] = json.RawMessage(strconv.FormatUint(next.PartitionID, 10))
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		log.LogWarnf("movedPartition: req(%v) err(%v)", req, err)
+		return nil
+	}
+	req.Data = data
+	req.Size = uint32(len(data))
+	req.PartitionID = next.PartitionID
+	log.LogInfof("movedPartition: req(%v) moved from mp(%v) to mp(%v)", req, mp.PartitionID, next.PartitionID)
+	return next
+}
+
 func (mc *MetaConn) send(req *proto.Packet) (resp *proto.Packet, err error) {
 	err = req.WriteToConn(mc.conn)
 	if err != nil {
@@ -0,0 +1,291 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package meta
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// DirShards returns the cached shard inodes of the sharded directory, nil if the directory is not known to be
+// sharded.
+func (mw *MetaWrapper) DirShards(ino uint64) []uint64 {
+	mw.dirShardsMutex.RLock()
+	defer mw.dirShardsMutex.RUnlock()
+	return mw.dirShards[ino]
+}
+
+// DirShardOwner returns the sharded directory of the shard inode, 0 if the inode is not a known shard.
+func (mw *MetaWrapper) DirShardOwner(ino uint64) uint64 {
+	mw.dirShardsMutex.RLock()
+	defer mw.dirShardsMutex.RUnlock()
+	return mw.dirShardOwners[ino]
+}
+
+func (mw *MetaWrapper) setDirShards(ino uint64, shards []uint64) {
+	mw.dirShardsMutex.Lock()
+	defer mw.dirShardsMutex.Unlock()
+	mw.dirShards[ino] = shards
+	for _, shard := range shards {
+		mw.dirShardOwners[shard] = ino
+	}
+}
+
+func (mw *MetaWrapper) dropDirShards(ino uint64) {
+	mw.dirShardsMutex.Lock()
+	defer mw.dirShardsMutex.Unlock()
+	for _, shard := range mw.dirShards[ino] {
+		delete(mw.dirShardOwners, shard)
+	}
+	delete(mw.dirShards, ino)
+}
+
+// loadDirShards loads the shard inodes of the directory from its xattr, nil if the directory is not sharded.
+func (mw *MetaWrapper) loadDirShards(ino uint64) ([]uint64, error) {
+	if shards := mw.DirShards(ino); shards != nil {
+		return shards, nil
+	}
+	mp := mw.getPartitionByInode(ino)
+	if mp == nil {
+		log.LogErrorf("loadDirShards: no such partition, ino(%v)", ino)
+		return nil, syscall.ENOENT
+	}
+	value, status, err := mw.getXAttr(mp, ino, proto.DirShardsXAttrKey)
+	if err != nil || status != statusOK {
+		return nil, statusErrToErrno(status, err)
+	}
+	if value == "" {
+		return nil, nil
+	}
+	shards, err := proto.ParseDirShards(value)
+	if err != nil {
+		log.LogErrorf("loadDirShards: ino(%v) err(%v)", ino, err)
+		return nil, syscall.EIO
+	}
+	mw.setDirShards(ino, shards)
+	return shards, nil
+}
+
+// dentryParent returns the inode keeping the dentry of the name in the directory, which is the shard of the
+// name if the directory is sharded.
+func (mw *MetaWrapper) dentryParent(parentID uint64, name string) uint64 {
+	if shards := mw.DirShards(parentID); shards != nil {
+		return proto.DirShardOf(shards, name)
+	}
+	return parentID
+}
+
+// withDentryParent runs the dentry operation in the inode keeping the dentry of the name. The shards of the
+// directory are loaded and the operation is retried, if the directory is found sharded by the meta node.
+func (mw *MetaWrapper) withDentryParent(parentID uint64, name string, op func(parent uint64) error) error {
+	err := op(mw.dentryParent(parentID, name))
+	if err != syscall.EREMOTE {
+		return err
+	}
+	shards, err := mw.loadDirShards(parentID)
+	if err != nil {
+		return err
+	}
+	if shards == nil {
+		log.LogErrorf("withDentryParent: dir(%v) is sharded without shards", parentID)
+		return syscall.EIO
+	}
+	return op(proto.DirShardOf(shards, name))
+}
+
+// ShardDir_ll shards the dentries of the empty directory to the count of shard inodes, which are created in
+// different meta partitions.
+func (mw *MetaWrapper) ShardDir_ll(ino uint64, count int) error {
+	if count < proto.MinDirShards || count > proto.MaxDirShards {
+		return syscall.EINVAL
+	}
+	return mw.shardDir(ino, count)
+}
+
+func (mw *MetaWrapper) shardDir(ino uint64, count int) error {
+	mp := mw.getPartitionByInode(ino)
+	if mp == nil {
+		log.LogErrorf("shardDir: no such partition, ino(%v)", ino)
+		return syscall.ENOENT
+	}
+	status, info, err := mw.iget(mp, ino)
+	if err != nil || status != statusOK {
+		return statusToErrno(status)
+	}
+	if !proto.IsDir(info.Mode) {
+		return syscall.ENOTDIR
+	}
+	var quotaIds []uint32
+	if mw.EnableQuota {
+		quotaInfos, err := mw.getInodeQuota(mp, ino)
+		if err != nil {
+			log.LogErrorf("shardDir: get dir quota fail, ino(%v) err(%v)", ino, err)
+			return syscall.ENOENT
+		}
+		for quotaId := range quotaInfos {
+			quotaIds = append(quotaIds, quotaId)
+		}
+	}
+
+	shards := make([]uint64, 0, count)
+	shardMPs := make([]*MetaPartition, 0, count)
+	release := func() {
+		for i, shard := range shards {
+			mw.iunlink(shardMPs[i], shard)
+			mw.ievict(shardMPs[i], shard)
+		}
+	}
+	rwPartitions := mw.getRWPartitions()
+	length := len(rwPartitions)
+	if length == 0 {
+		return syscall.ENOMEM
+	}
+	epoch := atomic.AddUint64(&mw.epoch, uint64(count))
+	for i := 0; len(shards) < count; i++ {
+		if i >= count+length {
+			release()
+			return syscall.ENOMEM
+		}
+		// spread the shards over the partitions
+		shardMP := rwPartitions[(int(epoch)+i)%length]
+		var shard *proto.InodeInfo
+		if mw.EnableQuota {
+			status, shard, err = mw.quotaIcreate(shardMP, info.Mode, info.Uid, info.Gid, nil, quotaIds)
+		} else {
+			status, shard, err = mw.icreate(shardMP, info.Mode, info.Uid, info.Gid, nil)
+		}
+		if err == nil && status == statusOK {
+			shards = append(shards, shard.Inode)
+			shardMPs = append(shardMPs, shardMP)
+		} else if status == statusNoSpace {
+			release()
+			return statusToErrno(status)
+		}
+	}
+
+	value := proto.FormatDirShards(shards)
+	status, err = mw.setXAttr(mp, ino, []byte(proto.DirShardsXAttrKey), []byte(value))
+	if err != nil || status != statusOK {
+		release()
+		return statusErrToErrno(status, err)
+	}
+	mw.setDirShards(ino, shards)
+	log.LogInfof("shardDir: volume(%v) ino(%v) shards(%v)", mw.volname, ino, value)
+	return nil
+}
+
+// readDirShards reads the dentries of the directory by read, or of all the shards if the directory is
+// sharded, in which case the dentries are merged in the order of the names and at most limit ones are kept
+// unless limit is 0.
+func (mw *MetaWrapper) readDirShards(parentID uint64, limit uint64, read func(parent uint64) ([]proto.Dentry, error)) ([]proto.Dentry, error) {
+	shards := mw.DirShards(parentID)
+	if shards == nil {
+		children, err := read(parentID)
+		if err != syscall.EREMOTE {
+			return children, err
+		}
+		if shards, err = mw.loadDirShards(parentID); err != nil {
+			return nil, err
+		}
+		if shards == nil {
+			log.LogErrorf("readDirShards: dir(%v) is sharded without shards", parentID)
+			return nil, syscall.EIO
+		}
+	}
+	return mergeDirShards(parentID, shards, limit, read)
+}
+
+// mergeDirShards reads the shards of the directory, and merges the dentries in the order of the names.
+func mergeDirShards(parentID uint64, shards []uint64, limit uint64, read func(parent uint64) ([]proto.Dentry, error)) ([]proto.Dentry, error) {
+	results := make([][]proto.Dentry, len(shards))
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard uint64) {
+			defer wg.Done()
+			results[i], errs[i] = read(shard)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	var children []proto.Dentry
+	for i, err := range errs {
+		if err != nil {
+			log.LogErrorf("mergeDirShards: dir(%v) shard(%v) err(%v)", parentID, shards[i], err)
+			return nil, err
+		}
+		children = append(children, results[i]...)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name < children[j].Name
+	})
+	if limit > 0 && uint64(len(children)) > limit {
+		children = children[:limit]
+	}
+	return children, nil
+}
+
+// deleteDirShards releases the shard inodes of the directory deleted.
+func (mw *MetaWrapper) deleteDirShards(ino uint64, shards []uint64) {
+	for _, shard := range shards {
+		mp := mw.getPartitionByInode(shard)
+		if mp == nil {
+			log.LogWarnf("deleteDirShards: no such partition, dir(%v) shard(%v)", ino, shard)
+			continue
+		}
+		mw.iunlink(mp, shard)
+		mw.ievict(mp, shard)
+	}
+	mw.dropDirShards(ino)
+}
+
+// fenceDirShards fences the shards of the directory to delete, which fails with ENOTEMPTY if any shard keeps
+// dentries, in which case the shards fenced are released. The fence is lifted if fenced is false. A shard
+// left fenced by a client failed is fenced again by the next delete of the directory.
+func (mw *MetaWrapper) fenceDirShards(shards []uint64, fenced bool) error {
+	for i, shard := range shards {
+		mp := mw.getPartitionByInode(shard)
+		if mp == nil {
+			log.LogWarnf("fenceDirShards: no such partition, shard(%v)", shard)
+			if !fenced {
+				continue
+			}
+			mw.fenceDirShards(shards[:i], false)
+			return syscall.EAGAIN
+		}
+		var status int
+		var err error
+		if fenced {
+			status, err = mw.setXAttr(mp, shard, []byte(proto.DirShardFenceXAttrKey), nil)
+		} else {
+			status, err = mw.removeXAttr(mp, shard, proto.DirShardFenceXAttrKey)
+		}
+		if err == nil && status == statusOK {
+			continue
+		}
+		log.LogWarnf("fenceDirShards: shard(%v) fenced(%v) status(%v) err(%v)", shard, fenced, status, err)
+		if fenced {
+			mw.fenceDirShards(shards[:i], false)
+			return statusErrToErrno(status, err)
+		}
+	}
+	return nil
+}
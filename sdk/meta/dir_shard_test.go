@@ -0,0 +1,249 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package meta
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util"
+	"github.com/cubefs/cubefs/util/btree"
+	"github.com/cubefs/cubefs/util/trace"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	dirShardTestDir        = 1 // the sharded directory
+	dirShardTestUnsharded  = 2 // a directory reported sharded without the shards
+	dirShardTestShardStart = 11
+)
+
+var dirShardTestShards = []uint64{11, 12, 13}
+
+// dirShardTestNode serves the dentry operations of the sharded directory as the meta node does: the operations
+// on the directory itself are rejected with OpDirShardedErr, and the dentries are kept in the shards.
+type dirShardTestNode struct {
+	sync.Mutex
+	listener net.Listener
+	dentries map[uint64][]proto.Dentry
+	ops      map[uint8][]uint64 // the inodes requested by the opcodes
+}
+
+func newDirShardTestNode(t *testing.T, names ...string) *dirShardTestNode {
+	proto.InitBufferPool(0)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	node := &dirShardTestNode{
+		listener: listener,
+		dentries: make(map[uint64][]proto.Dentry),
+		ops:      make(map[uint8][]uint64),
+	}
+	for i, name := range names {
+		shard := proto.DirShardOf(dirShardTestShards, name)
+		node.dentries[shard] = append(node.dentries[shard], proto.Dentry{Name: name, Inode: uint64(100 + i)})
+	}
+	for _, dentries := range node.dentries {
+		sort.Slice(dentries, func(i, j int) bool { return dentries[i].Name < dentries[j].Name })
+	}
+	go node.serve()
+	t.Cleanup(func() { listener.Close() })
+	return node
+}
+
+func (n *dirShardTestNode) serve() {
+	for {
+		conn, err := n.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			for {
+				p := proto.NewPacket()
+				if err := p.ReadFromConn(conn, proto.NoReadDeadlineTime); err != nil {
+					return
+				}
+				n.handle(p)
+				p.Trace = trace.SpanContext{}
+				p.ArgLen = 0
+				p.Arg = nil
+				if err := p.WriteToConn(conn); err != nil {
+					return
+				}
+			}
+		}(conn)
+	}
+}
+
+func (n *dirShardTestNode) handle(p *proto.Packet) {
+	n.Lock()
+	defer n.Unlock()
+	var req struct {
+		ParentID uint64 `json:"pino"`
+		Inode    uint64 `json:"ino"`
+		Name     string `json:"name"`
+		Marker   string `json:"marker"`
+	}
+	if err := json.Unmarshal(p.Data, &req); err != nil {
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, []byte(err.Error()))
+		return
+	}
+	reply := func(v interface{}) {
+		data, _ := json.Marshal(v)
+		p.PacketOkWithBody(data)
+	}
+	switch p.Opcode {
+	case proto.OpMetaGetXAttr:
+		n.ops[p.Opcode] = append(n.ops[p.Opcode], req.Inode)
+		resp := &proto.GetXAttrResponse{Inode: req.Inode, Key: proto.DirShardsXAttrKey}
+		if req.Inode == dirShardTestDir {
+			resp.Value = proto.FormatDirShards(dirShardTestShards)
+		}
+		reply(resp)
+		return
+	case proto.OpMetaLookup, proto.OpMetaReadDirLimit:
+		n.ops[p.Opcode] = append(n.ops[p.Opcode], req.ParentID)
+	default:
+		p.PacketErrorWithBody(proto.OpArgMismatchErr, nil)
+		return
+	}
+	if req.ParentID == dirShardTestDir || req.ParentID == dirShardTestUnsharded {
+		p.PacketErrorWithBody(proto.OpDirShardedErr, nil)
+		return
+	}
+	dentries := n.dentries[req.ParentID]
+	if p.Opcode == proto.OpMetaLookup {
+		for _, dentry := range dentries {
+			if dentry.Name == req.Name {
+				reply(&proto.LookupResponse{Inode: dentry.Inode, Mode: dentry.Type})
+				return
+			}
+		}
+		p.PacketErrorWithBody(proto.OpNotExistErr, nil)
+		return
+	}
+	i := sort.Search(len(dentries), func(i int) bool { return dentries[i].Name >= req.Marker })
+	reply(&proto.ReadDirLimitResponse{Children: dentries[i:]})
+}
+
+func (n *dirShardTestNode) requested(op uint8) []uint64 {
+	n.Lock()
+	defer n.Unlock()
+	requested := n.ops[op]
+	n.ops[op] = nil
+	return requested
+}
+
+func newDirShardTestWrapper(addr string) *MetaWrapper {
+	mw := &MetaWrapper{
+		volname:        "vol",
+		conns:          util.NewConnectPool(),
+		partitions:     make(map[uint64]*MetaPartition),
+		ranges:         btree.New(32),
+		dirShards:      make(map[uint64][]uint64),
+		dirShardOwners: make(map[uint64]uint64),
+	}
+	if addr != "" {
+		mw.addPartition(&MetaPartition{PartitionID: 1, Start: 1, End: dirShardTestShardStart - 1,
+			Members: []string{addr}, LeaderAddr: addr})
+		mw.addPartition(&MetaPartition{PartitionID: 2, Start: dirShardTestShardStart, End: 1000,
+			Members: []string{addr}, LeaderAddr: addr})
+	}
+	return mw
+}
+
+func TestWithDentryParent(t *testing.T) {
+	mw := newDirShardTestWrapper("")
+	var parents []uint64
+	op := func(err error) func(parent uint64) error {
+		return func(parent uint64) error {
+			parents = append(parents, parent)
+			return err
+		}
+	}
+
+	// the dentries of the directory not sharded are kept in itself
+	require.NoError(t, mw.withDentryParent(dirShardTestDir, "a", op(nil)))
+	require.Equal(t, []uint64{dirShardTestDir}, parents)
+	parents = nil
+	require.Equal(t, syscall.ENOENT, mw.withDentryParent(dirShardTestDir, "a", op(syscall.ENOENT)))
+	require.Equal(t, []uint64{dirShardTestDir}, parents)
+
+	// the dentries are routed to the shards by the names once the shards are known
+	mw.setDirShards(dirShardTestDir, dirShardTestShards)
+	require.Equal(t, uint64(dirShardTestDir), mw.DirShardOwner(dirShardTestShards[1]))
+	routed := make(map[uint64]bool)
+	for i := 0; i < 64; i++ {
+		name := fmt.Sprintf("f%d", i)
+		parents = nil
+		require.NoError(t, mw.withDentryParent(dirShardTestDir, name, op(nil)))
+		require.Equal(t, []uint64{proto.DirShardOf(dirShardTestShards, name)}, parents)
+		routed[parents[0]] = true
+	}
+	require.Len(t, routed, len(dirShardTestShards))
+
+	mw.dropDirShards(dirShardTestDir)
+	require.Nil(t, mw.DirShards(dirShardTestDir))
+	require.Zero(t, mw.DirShardOwner(dirShardTestShards[1]))
+	parents = nil
+	require.NoError(t, mw.withDentryParent(dirShardTestDir, "a", op(nil)))
+	require.Equal(t, []uint64{dirShardTestDir}, parents)
+}
+
+func TestDirShardRemoteRetry(t *testing.T) {
+	node := newDirShardTestNode(t, "a", "b", "c", "d", "e", "f")
+	mw := newDirShardTestWrapper(node.listener.Addr().String())
+
+	// the client not knowing the directory sharded loads the shards and retries in the shard
+	ino, _, err := mw.Lookup_ll(dirShardTestDir, "c")
+	require.NoError(t, err)
+	require.Equal(t, uint64(102), ino)
+	shard := proto.DirShardOf(dirShardTestShards, "c")
+	require.Equal(t, []uint64{dirShardTestDir, shard}, node.requested(proto.OpMetaLookup))
+	require.Equal(t, []uint64{dirShardTestDir}, node.requested(proto.OpMetaGetXAttr))
+	require.Equal(t, dirShardTestShards, mw.DirShards(dirShardTestDir))
+
+	// and routes to the shard directly afterwards
+	_, _, err = mw.Lookup_ll(dirShardTestDir, "g")
+	require.Equal(t, syscall.ENOENT, err)
+	require.Equal(t, []uint64{proto.DirShardOf(dirShardTestShards, "g")}, node.requested(proto.OpMetaLookup))
+	require.Empty(t, node.requested(proto.OpMetaGetXAttr))
+
+	// the directory read is merged from the shards in the order of the names
+	mw = newDirShardTestWrapper(node.listener.Addr().String())
+	children, err := mw.ReadDirLimit_ll(dirShardTestDir, "b", 3)
+	require.NoError(t, err)
+	names := make([]string, 0, len(children))
+	for _, child := range children {
+		names = append(names, child.Name)
+	}
+	require.Equal(t, []string{"b", "c", "d"}, names)
+	require.Equal(t, []uint64{dirShardTestDir}, node.requested(proto.OpMetaGetXAttr))
+	requested := node.requested(proto.OpMetaReadDirLimit)
+	require.Equal(t, uint64(dirShardTestDir), requested[0])
+	require.ElementsMatch(t, dirShardTestShards, requested[1:])
+
+	// the directory reported sharded without the shards is not retried
+	_, _, err = mw.Lookup_ll(dirShardTestUnsharded, "a")
+	require.Equal(t, syscall.EIO, err)
+	require.Equal(t, []uint64{dirShardTestUnsharded}, node.requested(proto.OpMetaLookup))
+	require.Nil(t, mw.DirShards(dirShardTestUnsharded))
+}
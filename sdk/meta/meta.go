@@ -21,6 +21,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
 	"golang.org/x/time/rate"
 
 	"github.com/cubefs/cubefs/proto"
@@ -56,6 +57,7 @@ const (
 	statusTxTimeout
 	statusUploadPartConflict
 	statusNotEmpty
+	statusDirSharded
 )
 
 const (
@@ -101,6 +103,8 @@ type MetaWrapper struct {
 	ossSecure         *OSSSecure
 	volCreateTime     int64
 	volDeleteLockTime int64
+	volTrashInterval  int64
+	volDirShards      int
 	owner             string
 	ownerValidation   bool
 	mc                *masterSDK.MasterClient
@@ -159,6 +163,22 @@ type MetaWrapper struct {
 	uniqidRangeMutex sync.Mutex
 
 	qc *QuotaCache
+
+	// lockSession identifies the advisory file locks held by the client, and the leases of the locks
+	// are renewed in the partitions which the session holds locks in.
+	lockSession    string
+	lockPartitions map[uint64]time.Time
+	lockMutex      sync.Mutex
+
+	// the orphan inodes held open by the client are recorded with the same session, and the lease
+	// of the session is renewed in the partitions which the session holds orphan inodes in.
+	orphanPartitions map[uint64]time.Time
+	orphanMutex      sync.Mutex
+
+	// the shard inodes of the sharded directories, and the directories of the shard inodes.
+	dirShards      map[uint64][]uint64
+	dirShardOwners map[uint64]uint64
+	dirShardsMutex sync.RWMutex
 }
 
 type uniqidRange struct {
@@ -212,6 +232,11 @@ func NewMetaWrapper(config *MetaConfig) (*MetaWrapper, error) {
 	//mw.EnableTransaction = config.EnableTransaction
 	mw.uniqidRangeMap = make(map[uint64]*uniqidRange, 0)
 	mw.qc = NewQuotaCache(DefaultQuotaExpiration, MaxQuotaCache)
+	mw.lockSession = uuid.New().String()
+	mw.lockPartitions = make(map[uint64]time.Time)
+	mw.orphanPartitions = make(map[uint64]time.Time)
+	mw.dirShards = make(map[uint64][]uint64)
+	mw.dirShardOwners = make(map[uint64]uint64)
 	limit := 0
 
 	for limit < MaxMountRetryLimit {
@@ -242,6 +267,8 @@ func NewMetaWrapper(config *MetaConfig) (*MetaWrapper, error) {
 
 	go mw.updateQuotaInfoTick()
 	go mw.refresh()
+	go mw.renewFileLocksTick()
+	go mw.renewOrphanSessionTick()
 	return mw, nil
 }
 
@@ -284,6 +311,7 @@ func (mw *MetaWrapper) VolCreateTime() int64 {
 func (mw *MetaWrapper) Close() error {
 	mw.closeOnce.Do(func() {
 		close(mw.closeCh)
+		mw.releaseFileLocks_ll()
 		mw.conns.Close()
 	})
 	return nil
@@ -297,6 +325,11 @@ func (mw *MetaWrapper) LocalIP() string {
 	return mw.localIP
 }
 
+// FileLockSession returns the session of the advisory file locks held by the client.
+func (mw *MetaWrapper) FileLockSession() string {
+	return mw.lockSession
+}
+
 func (mw *MetaWrapper) exporterKey(act string) string {
 	return fmt.Sprintf("%s_sdk_meta_%s", mw.cluster, act)
 }
@@ -334,6 +367,8 @@ func parseStatus(result uint8) (status int) {
 		status = statusTxTimeout
 	case proto.OpUploadPartConflictErr:
 		status = statusUploadPartConflict
+	case proto.OpDirShardedErr:
+		status = statusDirSharded
 	default:
 		status = statusError
 	}
@@ -383,6 +418,8 @@ func statusToErrno(status int) error {
 		return syscall.EAGAIN
 	case statusUploadPartConflict:
 		return syscall.EEXIST
+	case statusDirSharded:
+		return syscall.EREMOTE
 	default:
 	}
 	return syscall.EIO
@@ -873,7 +873,28 @@ func (mw *MetaWrapper) canDeleteInode(mp *MetaPartition, info *proto.InodeInfo,
 	return true, nil
 }
 
+// restoreDentry puts back the dentry which has been deleted while the inode refuses to be unlinked.
+func (mw *MetaWrapper) restoreDentry(parentMP *MetaPartition, parentID uint64, name string, mp *MetaPartition, ino uint64) {
+	status, info, err := mw.iget(mp, ino)
+	if err != nil || status != statusOK {
+		log.LogErrorf("restoreDentry: iget fail: parentID(%v) name(%v) ino(%v) status(%v) err(%v)",
+			parentID, name, ino, status, err)
+		return
+	}
+	if status, err = mw.dcreate(parentMP, parentID, name, ino, info.Mode); err != nil || status != statusOK {
+		log.LogErrorf("restoreDentry: dcreate fail: parentID(%v) name(%v) ino(%v) status(%v) err(%v)",
+			parentID, name, ino, status, err)
+		return
+	}
+	log.LogWarnf("restoreDentry: parentID(%v) name(%v) ino(%v)", parentID, name, ino)
+}
+
 func (mw *MetaWrapper) lookup(mp *MetaPartition, parentID uint64, name string) (status int, inode uint64, mode uint32, err error) {
+	return mw.lookupVersion(mp, 0, parentID, name)
+}
+
+// lookupVersion looks up the dentry in the snapshot version of the partition, the live tree if the version is 0.
+func (mw *MetaWrapper) lookupVersion(mp *MetaPartition, version, parentID uint64, name string) (status int, inode uint64, mode uint32, err error) {
 	bgTime := stat.BeginStat()
 	defer func() {
 		stat.EndStat("lookup", err, bgTime, 1)
@@ -884,6 +905,7 @@ func (mw *MetaWrapper) lookup(mp *MetaPartition, parentID uint64, name string) (
 		PartitionID: mp.PartitionID,
 		ParentID:    parentID,
 		Name:        name,
+		Version:     version,
 	}
 	packet := proto.NewPacketReqID()
 	packet.Opcode = proto.OpMetaLookup
@@ -935,6 +957,11 @@ func (mw *MetaWrapper) lookup(mp *MetaPartition, parentID uint64, name string) (
 }
 
 func (mw *MetaWrapper) iget(mp *MetaPartition, inode uint64) (status int, info *proto.InodeInfo, err error) {
+	return mw.igetVersion(mp, 0, inode)
+}
+
+// igetVersion gets the inode in the snapshot version of the partition, the live tree if the version is 0.
+func (mw *MetaWrapper) igetVersion(mp *MetaPartition, version, inode uint64) (status int, info *proto.InodeInfo, err error) {
 	bgTime := stat.BeginStat()
 	defer func() {
 		stat.EndStat("iget", err, bgTime, 1)
@@ -944,6 +971,7 @@ func (mw *MetaWrapper) iget(mp *MetaPartition, inode uint64) (status int, info *
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
 		Inode:       inode,
+		Version:     version,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -1093,12 +1121,19 @@ func (mw *MetaWrapper) readdir(mp *MetaPartition, parentID uint64) (status int,
 
 // read limit dentries start from
 func (mw *MetaWrapper) readdirlimit(mp *MetaPartition, parentID uint64, from string, limit uint64) (status int, children []proto.Dentry, err error) {
+	return mw.readdirlimitVersion(mp, 0, parentID, from, limit)
+}
+
+// readdirlimitVersion reads the directory in the snapshot version of the partition, the live tree if the version
+// is 0.
+func (mw *MetaWrapper) readdirlimitVersion(mp *MetaPartition, version, parentID uint64, from string, limit uint64) (status int, children []proto.Dentry, err error) {
 	req := &proto.ReadDirLimitRequest{
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
 		ParentID:    parentID,
 		Marker:      from,
 		Limit:       limit,
+		Version:     version,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -1180,7 +1215,13 @@ func (mw *MetaWrapper) appendExtentKey(mp *MetaPartition, inode uint64, extent p
 	return status, err
 }
 
-func (mw *MetaWrapper) getExtents(mp *MetaPartition, inode uint64) (status int, gen, size uint64, extents []proto.ExtentKey, err error) {
+func (mw *MetaWrapper) getExtents(mp *MetaPartition, inode uint64) (status int, gen, size uint64, extents []proto.ExtentKey, shared bool, err error) {
+	return mw.getExtentsVersion(mp, 0, inode)
+}
+
+// getExtentsVersion gets the extents of the inode in the snapshot version of the partition, the live tree if the
+// version is 0.
+func (mw *MetaWrapper) getExtentsVersion(mp *MetaPartition, version, inode uint64) (status int, gen, size uint64, extents []proto.ExtentKey, shared bool, err error) {
 	bgTime := stat.BeginStat()
 	defer func() {
 		stat.EndStat("getExtents", err, bgTime, 1)
@@ -1190,6 +1231,7 @@ func (mw *MetaWrapper) getExtents(mp *MetaPartition, inode uint64) (status int,
 		VolName:     mw.volname,
 		PartitionID: mp.PartitionID,
 		Inode:       inode,
+		Version:     version,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -1226,7 +1268,7 @@ func (mw *MetaWrapper) getExtents(mp *MetaPartition, inode uint64) (status int,
 		log.LogErrorf("getExtents: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
 		return
 	}
-	return statusOK, resp.Generation, resp.Size, resp.Extents, nil
+	return statusOK, resp.Generation, resp.Size, resp.Extents, resp.Shared, nil
 }
 
 func (mw *MetaWrapper) getObjExtents(mp *MetaPartition, inode uint64) (status int, gen, size uint64, extents []proto.ExtentKey, objExtents []proto.ObjExtentKey, err error) {
@@ -1359,6 +1401,106 @@ func (mw *MetaWrapper) truncate(mp *MetaPartition, inode, size uint64) (status i
 	return statusOK, nil
 }
 
+func (mw *MetaWrapper) punchHole(mp *MetaPartition, inode, offset, size uint64) (status int, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("punchHole", err, bgTime, 1)
+	}()
+
+	req := &proto.PunchHoleRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Offset:      offset,
+		Size:        size,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaExtentsPunchHole
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("punchHole: ino(%v) offset(%v) size(%v) err(%v)", inode, offset, size, err)
+		return
+	}
+
+	log.LogDebugf("punchHole enter: packet(%v) mp(%v) req(%v)", packet, mp, string(packet.Data))
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("punchHole: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("punchHole: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	log.LogDebugf("punchHole exit: packet(%v) mp(%v) req(%v)", packet, mp, *req)
+	return statusOK, nil
+}
+
+func (mw *MetaWrapper) inodeClone(mp *MetaPartition, req *proto.CloneInodeRequest) (status int, info *proto.InodeInfo, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("inodeClone", err, bgTime, 1)
+	}()
+
+	req.VolName = mw.volname
+	req.PartitionID = mp.PartitionID
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaInodeClone
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("inodeClone: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	log.LogDebugf("inodeClone enter: packet(%v) mp(%v) req(%v)", packet, mp, string(packet.Data))
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("inodeClone: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("inodeClone: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.CloneInodeResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("inodeClone: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	if resp.Info == nil {
+		err = errors.New(fmt.Sprintf("inodeClone: info is nil, packet(%v) mp(%v) req(%v) PacketData(%v)", packet, mp, *req, string(packet.Data)))
+		log.LogWarn(err)
+		return
+	}
+	log.LogDebugf("inodeClone exit: packet(%v) mp(%v) req(%v) info(%v)", packet, mp, *req, resp.Info)
+	return statusOK, resp.Info, nil
+}
+
 func (mw *MetaWrapper) txIlink(tx *Transaction, mp *MetaPartition, inode uint64) (status int, info *proto.InodeInfo, err error) {
 	bgTime := stat.BeginStat()
 	defer func() {
@@ -1937,7 +2079,13 @@ func (mw *MetaWrapper) setXAttr(mp *MetaPartition, inode uint64, name []byte, va
 	return
 }
 
-func (mw *MetaWrapper) getAllXAttr(mp *MetaPartition, inode uint64) (attrs map[string]string, status int, err error) {
+func (mw *MetaWrapper) getAllXAttr(mp *MetaPartition, inode uint64, prefix string) (attrs map[string]string, status int, err error) {
+	return mw.getAllXAttrVersion(mp, 0, inode, prefix)
+}
+
+// getAllXAttrVersion gets the xattrs of the inode in the snapshot version of the partition, the live tree if the
+// version is 0.
+func (mw *MetaWrapper) getAllXAttrVersion(mp *MetaPartition, version, inode uint64, prefix string) (attrs map[string]string, status int, err error) {
 	bgTime := stat.BeginStat()
 	defer func() {
 		stat.EndStat("getAllXAttr", err, bgTime, 1)
@@ -1947,6 +2095,8 @@ func (mw *MetaWrapper) getAllXAttr(mp *MetaPartition, inode uint64) (attrs map[s
 		VolName:     mw.volname,
 		PartitionId: mp.PartitionID,
 		Inode:       inode,
+		Prefix:      prefix,
+		Version:     version,
 	}
 
 	packet := proto.NewPacketReqID()
@@ -1988,6 +2138,105 @@ func (mw *MetaWrapper) getAllXAttr(mp *MetaPartition, inode uint64) (attrs map[s
 	return
 }
 
+func (mw *MetaWrapper) updateXAttrRecords(mp *MetaPartition, inode uint64, prefix string, remove map[string]string,
+	append string) (key string, status int, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("updateXAttrRecords", err, bgTime, 1)
+	}()
+
+	req := &proto.UpdateXAttrRecordsRequest{
+		VolName:     mw.volname,
+		PartitionId: mp.PartitionID,
+		Inode:       inode,
+		Prefix:      prefix,
+		Remove:      remove,
+		Append:      append,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaUpdateXAttrRecords
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("updateXAttrRecords: req(%v) err(%v)", *req, err)
+		return
+	}
+	log.LogDebugf("updateXAttrRecords: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("updateXAttrRecords: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("updateXAttrRecords: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.UpdateXAttrRecordsResponse)
+	if err = packet.UnmarshalData(resp); err != nil {
+		log.LogErrorf("updateXAttrRecords: packet(%v) mp(%v) req(%v) err(%v) PacketData(%v)", packet, mp, *req, err, string(packet.Data))
+		return
+	}
+	key = resp.Key
+
+	log.LogDebugf("updateXAttrRecords: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+	return
+}
+
+// snapshotVersion runs the phase of the snapshot version in the partition, see proto.SnapshotVersionRequest.
+func (mw *MetaWrapper) snapshotVersion(mp *MetaPartition, version uint64, op uint8) (status int, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("snapshotVersion", err, bgTime, 1)
+	}()
+
+	req := &proto.SnapshotVersionRequest{
+		VolName:     mw.volname,
+		PartitionId: mp.PartitionID,
+		Version:     version,
+		Op:          op,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaSnapshotVersion
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("snapshotVersion: req(%v) err(%v)", *req, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("snapshotVersion: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("snapshotVersion: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+	log.LogDebugf("snapshotVersion: packet(%v) mp(%v) req(%v)", packet, mp, *req)
+	return
+}
+
 func (mw *MetaWrapper) getXAttr(mp *MetaPartition, inode uint64, name string) (value string, status int, err error) {
 	bgTime := stat.BeginStat()
 	defer func() {
@@ -2685,3 +2934,455 @@ func (mw *MetaWrapper) getUniqID(mp *MetaPartition, num uint32) (status int, sta
 	start = resp.Start
 	return
 }
+
+func (mw *MetaWrapper) setFileLock(mp *MetaPartition, inode uint64, lock *proto.FileLock) (status int, conflict *proto.FileLock, err error) {
+	req := &proto.SetFileLockRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Lock:        *lock,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaSetFileLock
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		return
+	}
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("setFileLock: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status == statusExist {
+		resp := new(proto.SetFileLockResponse)
+		if err = packet.UnmarshalData(resp); err != nil {
+			log.LogErrorf("setFileLock: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+			return
+		}
+		conflict = resp.Conflict
+		log.LogDebugf("setFileLock: lock conflict, mp(%v) req(%v) conflict(%v)", mp, *req, conflict)
+		return
+	}
+	if status != statusOK {
+		log.LogErrorf("setFileLock: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+	}
+	return
+}
+
+func (mw *MetaWrapper) getFileLock(mp *MetaPartition, inode uint64, lock *proto.FileLock) (status int, conflict *proto.FileLock, err error) {
+	req := &proto.GetFileLockRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Lock:        *lock,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaGetFileLock
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		return
+	}
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("getFileLock: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("getFileLock: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.GetFileLockResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("getFileLock: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	conflict = resp.Conflict
+	return
+}
+
+func (mw *MetaWrapper) renewFileLock(mp *MetaPartition) (status int, count int, err error) {
+	req := &proto.RenewFileLockRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Session:     mw.lockSession,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaRenewFileLock
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		return
+	}
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("renewFileLock: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("renewFileLock: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.RenewFileLockResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("renewFileLock: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	count = resp.Count
+	return
+}
+
+func (mw *MetaWrapper) releaseFileLocks(mp *MetaPartition) (status int, err error) {
+	req := &proto.ReleaseFileLocksRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Session:     mw.lockSession,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaReleaseFileLocks
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		return
+	}
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("releaseFileLocks: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("releaseFileLocks: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+	}
+	return
+}
+
+func (mw *MetaWrapper) addOrphanInode(mp *MetaPartition, inode uint64) (status int, err error) {
+	req := &proto.AddOrphanInodeRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		Session:     mw.lockSession,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaAddOrphanInode
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		return
+	}
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("addOrphanInode: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("addOrphanInode: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+	}
+	return
+}
+
+func (mw *MetaWrapper) renewOrphanSession(mp *MetaPartition) (status int, count int, err error) {
+	req := &proto.RenewOrphanSessionRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Session:     mw.lockSession,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaRenewOrphanSession
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		return
+	}
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("renewOrphanSession: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("renewOrphanSession: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.RenewOrphanSessionResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("renewOrphanSession: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	count = resp.Count
+	return
+}
+
+func (mw *MetaWrapper) watchChanges(mp *MetaPartition, epoch, seq uint64) (status int, resp *proto.WatchChangesResponse, err error) {
+	req := &proto.WatchChangesRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Epoch:       epoch,
+		Seq:         seq,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaWatchChanges
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		return
+	}
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("watchChanges: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("watchChanges: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp = new(proto.WatchChangesResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("watchChanges: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	return
+}
+
+func (mw *MetaWrapper) readChangeFeed(mp *MetaPartition, applyID uint64, limit int) (status int, resp *proto.ReadChangeFeedResponse, err error) {
+	req := &proto.ReadChangeFeedRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		ApplyID:     applyID,
+		Limit:       limit,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaReadChangeFeed
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		return
+	}
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("readChangeFeed: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("readChangeFeed: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp = new(proto.ReadChangeFeedResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("readChangeFeed: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	return
+}
+
+func (mw *MetaWrapper) itrash(mp *MetaPartition, inode, parentID uint64, name, fullPath string) (status int, info *proto.InodeInfo, trashed bool, err error) {
+	bgTime := stat.BeginStat()
+	defer func() {
+		stat.EndStat("itrash", err, bgTime, 1)
+	}()
+
+	//use uniq id to dedup request
+	status, uniqID, err := mw.consumeUniqID(mp)
+	if err != nil || status != statusOK {
+		err = statusToErrno(status)
+		return
+	}
+
+	req := &proto.TrashInodeRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+		ParentId:    parentID,
+		Name:        name,
+		Path:        fullPath,
+		UniqID:      uniqID,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaTrashInode
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		log.LogErrorf("itrash: ino(%v) err(%v)", inode, err)
+		return
+	}
+
+	metric := exporter.NewTPCnt(packet.GetOpMsg())
+	defer func() {
+		metric.SetWithLabels(err, map[string]string{exporter.Vol: mw.volname})
+	}()
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("itrash: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		err = errors.New(packet.GetResultMsg())
+		log.LogErrorf("itrash: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.TrashInodeResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("itrash: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	log.LogDebugf("itrash: packet(%v) mp(%v) req(%v) trashed(%v)", packet, mp, *req, resp.Trashed)
+	return statusOK, resp.Info, resp.Trashed, nil
+}
+
+func (mw *MetaWrapper) listTrash(mp *MetaPartition) (status int, entries []*proto.TrashEntry, err error) {
+	req := &proto.ListTrashRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaListTrash
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		return
+	}
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("listTrash: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("listTrash: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.ListTrashResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("listTrash: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	entries = resp.Entries
+	return
+}
+
+func (mw *MetaWrapper) restoreTrash(mp *MetaPartition, inode uint64) (status int, entry *proto.TrashEntry, err error) {
+	req := &proto.RestoreTrashRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inode:       inode,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaRestoreTrash
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		return
+	}
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("restoreTrash: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("restoreTrash: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.RestoreTrashResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("restoreTrash: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	entry = resp.Entry
+	return
+}
+
+func (mw *MetaWrapper) purgeTrash(mp *MetaPartition, inodes []uint64) (status int, count int, err error) {
+	req := &proto.PurgeTrashRequest{
+		VolName:     mw.volname,
+		PartitionID: mp.PartitionID,
+		Inodes:      inodes,
+	}
+
+	packet := proto.NewPacketReqID()
+	packet.Opcode = proto.OpMetaPurgeTrash
+	packet.PartitionID = mp.PartitionID
+	err = packet.MarshalData(req)
+	if err != nil {
+		return
+	}
+
+	packet, err = mw.sendToMetaPartition(mp, packet)
+	if err != nil {
+		log.LogErrorf("purgeTrash: packet(%v) mp(%v) req(%v) err(%v)", packet, mp, *req, err)
+		return
+	}
+
+	status = parseStatus(packet.ResultCode)
+	if status != statusOK {
+		log.LogErrorf("purgeTrash: packet(%v) mp(%v) req(%v) result(%v)", packet, mp, *req, packet.GetResultMsg())
+		return
+	}
+
+	resp := new(proto.PurgeTrashResponse)
+	err = packet.UnmarshalData(resp)
+	if err != nil {
+		log.LogErrorf("purgeTrash: packet(%v) mp(%v) err(%v) PacketData(%v)", packet, mp, err, string(packet.Data))
+		return
+	}
+	count = resp.Count
+	return
+}
@@ -120,6 +120,16 @@ func (mw *MetaWrapper) getRWPartitions() []*MetaPartition {
 	return rwPartitions
 }
 
+func (mw *MetaWrapper) getAllPartitions() []*MetaPartition {
+	mw.RLock()
+	defer mw.RUnlock()
+	partitions := make([]*MetaPartition, 0, len(mw.partitions))
+	for _, mp := range mw.partitions {
+		partitions = append(partitions, mp)
+	}
+	return partitions
+}
+
 // GetConnect the partition whose Start is Larger than ino.
 // Return nil if no successive partition.
 func (mw *MetaWrapper) getNextPartition(ino uint64) *MetaPartition {
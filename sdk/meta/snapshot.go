@@ -0,0 +1,448 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package meta
+
+import (
+	"path"
+	"sync"
+	"syscall"
+
+	"github.com/cubefs/cubefs/proto"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+// snapshotReadDirLimit is the count of the dentries read from the snapshot version at a time.
+const snapshotReadDirLimit = 1024
+
+// ListSnapshot returns the snapshots of the volume recorded by the master.
+func (mw *MetaWrapper) ListSnapshot() ([]*proto.SnapshotInfo, error) {
+	return mw.mc.AdminAPI().ListSnapshot(mw.volname)
+}
+
+// GetSnapshot returns the snapshot of the given name, or ENOENT if it does not exist.
+func (mw *MetaWrapper) GetSnapshot(name string) (*proto.SnapshotInfo, error) {
+	snapshots, err := mw.ListSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	for _, snap := range snapshots {
+		if snap.Name == name {
+			return snap, nil
+		}
+	}
+	return nil, syscall.ENOENT
+}
+
+// CreateSnapshot_ll takes a read-only snapshot of the directory. The snapshot is a version of the meta partitions
+// of the volume, which are all fenced before any of them takes the version, so that the versions are taken at the
+// same point. The data extents referenced by the versions are frozen on the data nodes, and the files of the live
+// tree, including the ones already open, write the data frozen to new extents.
+func (mw *MetaWrapper) CreateSnapshot_ll(name, snapPath string) (snap *proto.SnapshotInfo, err error) {
+	src, err := mw.LookupPath(snapPath)
+	if err != nil {
+		log.LogErrorf("CreateSnapshot_ll: lookup path(%v) err(%v)", snapPath, err)
+		return nil, err
+	}
+	srcInfo, err := mw.InodeGet_ll(src)
+	if err != nil {
+		return nil, err
+	}
+	if !proto.IsDir(srcInfo.Mode) {
+		return nil, syscall.ENOTDIR
+	}
+
+	if snap, err = mw.mc.AdminAPI().CreateSnapshot(mw.volname, name, snapPath); err != nil {
+		log.LogErrorf("CreateSnapshot_ll: create snapshot(%v) path(%v) err(%v)", name, snapPath, err)
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			if delErr := mw.DeleteSnapshot_ll(name); delErr != nil {
+				log.LogErrorf("CreateSnapshot_ll: clean snapshot(%v) err(%v)", name, delErr)
+			}
+		}
+	}()
+
+	if err = mw.takeSnapshotVersion(snap.Id); err != nil {
+		log.LogErrorf("CreateSnapshot_ll: take version of snapshot(%v) err(%v)", snap, err)
+		return nil, err
+	}
+	if err = mw.mc.AdminAPI().UpdateSnapshot(mw.volname, name, src, proto.SnapshotStatusNormal); err != nil {
+		return nil, err
+	}
+	snap.RootInode = src
+	snap.Status = proto.SnapshotStatusNormal
+	log.LogDebugf("CreateSnapshot_ll: snapshot(%v)", snap)
+	return snap, nil
+}
+
+// takeSnapshotVersion fences all the meta partitions, and commits the version once all are fenced. The fences
+// are aborted if any partition fails to prepare, the versions committed are deleted by the caller otherwise.
+func (mw *MetaWrapper) takeSnapshotVersion(version uint64) error {
+	partitions := mw.getAllPartitions()
+	if err := mw.snapshotVersions(partitions, version, proto.SnapshotVersionPrepare); err != nil {
+		if abortErr := mw.snapshotVersions(partitions, version, proto.SnapshotVersionAbort); abortErr != nil {
+			log.LogWarnf("takeSnapshotVersion: abort version(%v) err(%v)", version, abortErr)
+		}
+		return err
+	}
+	return mw.snapshotVersions(partitions, version, proto.SnapshotVersionCommit)
+}
+
+// snapshotVersions runs the phase of the snapshot version in the partitions concurrently, since the partitions
+// fenced refuse the writes until all are prepared.
+func (mw *MetaWrapper) snapshotVersions(partitions []*MetaPartition, version uint64, op uint8) error {
+	errs := make([]error, len(partitions))
+	var wg sync.WaitGroup
+	for i, mp := range partitions {
+		wg.Add(1)
+		go func(i int, mp *MetaPartition) {
+			defer wg.Done()
+			status, err := mw.snapshotVersion(mp, version, op)
+			if err != nil || status != statusOK {
+				errs[i] = statusErrToErrno(status, err)
+			}
+		}(i, mp)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			log.LogErrorf("snapshotVersions: mp(%v) version(%v) op(%v) err(%v)", partitions[i], version, op, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteSnapshot_ll deletes the version of the snapshot from the meta partitions and its record, the data extents
+// no longer referenced by the live tree or the other snapshots are deleted by the meta partitions.
+func (mw *MetaWrapper) DeleteSnapshot_ll(name string) error {
+	snap, err := mw.GetSnapshot(name)
+	if err != nil {
+		return err
+	}
+	// the snapshot being deleted is hidden from the clients
+	if snap.Status != proto.SnapshotStatusDeleting {
+		if err = mw.mc.AdminAPI().UpdateSnapshot(mw.volname, name, 0, proto.SnapshotStatusDeleting); err != nil {
+			return err
+		}
+	}
+	if err = mw.snapshotVersions(mw.getAllPartitions(), snap.Id, proto.SnapshotVersionDelete); err != nil {
+		log.LogErrorf("DeleteSnapshot_ll: delete version of snapshot(%v) err(%v)", snap, err)
+		return err
+	}
+	return mw.mc.AdminAPI().DeleteSnapshot(mw.volname, name)
+}
+
+// RestoreSnapshot_ll copies the tree of the snapshot back to the path, which is the path taken the snapshot of
+// if dstPath is empty. The path must not exist, the directories above it are created if necessary. The files are
+// cloned from the version, so that the data extents are shared instead of copied.
+func (mw *MetaWrapper) RestoreSnapshot_ll(name, dstPath string) (ino uint64, err error) {
+	snap, err := mw.GetSnapshot(name)
+	if err != nil {
+		return 0, err
+	}
+	if snap.Status != proto.SnapshotStatusNormal {
+		return 0, syscall.EBUSY
+	}
+	if dstPath == "" {
+		dstPath = snap.Path
+	}
+	dstPath = path.Clean("/" + dstPath)
+	if dstPath == "/" {
+		return 0, syscall.EEXIST
+	}
+	rootInfo, err := mw.SnapshotInodeGet_ll(snap.Id, snap.RootInode)
+	if err != nil {
+		return 0, err
+	}
+
+	parentID, err := mw.makeDirs(path.Dir(dstPath))
+	if err != nil {
+		log.LogErrorf("RestoreSnapshot_ll: make dirs of path(%v) err(%v)", dstPath, err)
+		return 0, err
+	}
+	info, err := mw.Create_ll(parentID, path.Base(dstPath), rootInfo.Mode, rootInfo.Uid, rootInfo.Gid, nil)
+	if err != nil {
+		log.LogErrorf("RestoreSnapshot_ll: create path(%v) err(%v)", dstPath, err)
+		return 0, err
+	}
+	if err = newTreeCopier(mw, snap.Id).copyDir(rootInfo, info.Inode); err != nil {
+		log.LogErrorf("RestoreSnapshot_ll: copy snapshot(%v) to path(%v) err(%v)", snap, dstPath, err)
+		return 0, err
+	}
+	log.LogDebugf("RestoreSnapshot_ll: snapshot(%v) path(%v) ino(%v)", snap, dstPath, info.Inode)
+	return info.Inode, nil
+}
+
+// SnapshotLookup_ll looks up the dentry in the snapshot version.
+func (mw *MetaWrapper) SnapshotLookup_ll(version, parentID uint64, name string) (inode uint64, mode uint32, err error) {
+	err = mw.withVersionDentryParent(version, parentID, name, func(parent uint64) error {
+		mp := mw.getPartitionByInode(parent)
+		if mp == nil {
+			return syscall.ENOENT
+		}
+		status, ino, m, err := mw.lookupVersion(mp, version, parent, name)
+		if err != nil || status != statusOK {
+			return statusErrToErrno(status, err)
+		}
+		inode, mode = ino, m
+		return nil
+	})
+	return
+}
+
+// SnapshotInodeGet_ll gets the inode in the snapshot version.
+func (mw *MetaWrapper) SnapshotInodeGet_ll(version, inode uint64) (*proto.InodeInfo, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		return nil, syscall.ENOENT
+	}
+	status, info, err := mw.igetVersion(mp, version, inode)
+	if err != nil || status != statusOK {
+		return nil, statusErrToErrno(status, err)
+	}
+	return info, nil
+}
+
+// SnapshotReadDirLimit_ll reads at most limit dentries of the directory in the snapshot version from the name.
+func (mw *MetaWrapper) SnapshotReadDirLimit_ll(version, parentID uint64, from string, limit uint64) ([]proto.Dentry, error) {
+	read := func(parent uint64) ([]proto.Dentry, error) {
+		mp := mw.getPartitionByInode(parent)
+		if mp == nil {
+			return nil, syscall.ENOENT
+		}
+		status, children, err := mw.readdirlimitVersion(mp, version, parent, from, limit)
+		if err != nil || status != statusOK {
+			return nil, statusErrToErrno(status, err)
+		}
+		return children, nil
+	}
+	children, err := read(parentID)
+	if err != syscall.EREMOTE {
+		return children, err
+	}
+	shards, err := mw.versionDirShards(version, parentID)
+	if err != nil {
+		return nil, err
+	}
+	return mergeDirShards(parentID, shards, limit, read)
+}
+
+// SnapshotReadDir_ll reads all the dentries of the directory in the snapshot version.
+func (mw *MetaWrapper) SnapshotReadDir_ll(version, parentID uint64) (children []proto.Dentry, err error) {
+	var from string
+	for {
+		batch, err := mw.SnapshotReadDirLimit_ll(version, parentID, from, snapshotReadDirLimit)
+		if err != nil {
+			return nil, err
+		}
+		batchNr := uint64(len(batch))
+		if batchNr == 0 || (from != "" && batchNr == 1) {
+			break
+		}
+		if from != "" {
+			batch = batch[1:]
+		}
+		children = append(children, batch...)
+		if batchNr < snapshotReadDirLimit {
+			break
+		}
+		from = batch[len(batch)-1].Name
+	}
+	return children, nil
+}
+
+// SnapshotGetExtents gets the extents of the file in the snapshot version.
+func (mw *MetaWrapper) SnapshotGetExtents(version, inode uint64) (gen uint64, size uint64, extents []proto.ExtentKey, err error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		return 0, 0, nil, syscall.ENOENT
+	}
+	status, gen, size, extents, _, err := mw.getExtentsVersion(mp, version, inode)
+	if err != nil || status != statusOK {
+		return 0, 0, nil, statusErrToErrno(status, err)
+	}
+	return gen, size, extents, nil
+}
+
+// SnapshotXAttrGetAll_ll gets the xattrs of the inode in the snapshot version.
+func (mw *MetaWrapper) SnapshotXAttrGetAll_ll(version, inode uint64) (map[string]string, error) {
+	mp := mw.getPartitionByInode(inode)
+	if mp == nil {
+		return nil, syscall.ENOENT
+	}
+	attrs, status, err := mw.getAllXAttrVersion(mp, version, inode, "")
+	if err != nil || status != statusOK {
+		return nil, statusErrToErrno(status, err)
+	}
+	return attrs, nil
+}
+
+// versionDirShards loads the shard inodes of the directory in the snapshot version, which is not cached since
+// the directory may be sharded after the snapshot is taken.
+func (mw *MetaWrapper) versionDirShards(version, ino uint64) ([]uint64, error) {
+	mp := mw.getPartitionByInode(ino)
+	if mp == nil {
+		return nil, syscall.ENOENT
+	}
+	attrs, status, err := mw.getAllXAttrVersion(mp, version, ino, proto.DirShardsXAttrKey)
+	if err != nil || status != statusOK {
+		return nil, statusErrToErrno(status, err)
+	}
+	value := attrs[proto.DirShardsXAttrKey]
+	if value == "" {
+		log.LogErrorf("versionDirShards: dir(%v) is sharded without shards in version(%v)", ino, version)
+		return nil, syscall.EIO
+	}
+	shards, err := proto.ParseDirShards(value)
+	if err != nil {
+		log.LogErrorf("versionDirShards: ino(%v) version(%v) err(%v)", ino, version, err)
+		return nil, syscall.EIO
+	}
+	return shards, nil
+}
+
+// withVersionDentryParent runs the dentry operation of the snapshot version in the inode keeping the dentry of
+// the name, see withDentryParent.
+func (mw *MetaWrapper) withVersionDentryParent(version, parentID uint64, name string, op func(parent uint64) error) error {
+	err := op(parentID)
+	if err != syscall.EREMOTE {
+		return err
+	}
+	shards, err := mw.versionDirShards(version, parentID)
+	if err != nil {
+		return err
+	}
+	return op(proto.DirShardOf(shards, name))
+}
+
+// treeCopier copies a directory tree of the snapshot version by cloning the files, the hard links within the tree
+// are kept.
+type treeCopier struct {
+	mw      *MetaWrapper
+	version uint64
+	// the copies of the files with multiple links
+	linked map[uint64]uint64
+}
+
+func newTreeCopier(mw *MetaWrapper, version uint64) *treeCopier {
+	return &treeCopier{
+		mw:      mw,
+		version: version,
+		linked:  make(map[uint64]uint64),
+	}
+}
+
+// copyDir copies the children of the directory into the destination directory.
+func (tc *treeCopier) copyDir(src *proto.InodeInfo, dst uint64) (err error) {
+	children, err := tc.mw.SnapshotReadDir_ll(tc.version, src.Inode)
+	if err != nil {
+		return
+	}
+	for _, child := range children {
+		if err = tc.copyEntry(dst, child); err != nil {
+			log.LogErrorf("copyDir: copy dentry(%v) of dir(%v) err(%v)", child, src.Inode, err)
+			return
+		}
+	}
+	return tc.copyAttr(src, dst)
+}
+
+func (tc *treeCopier) copyEntry(parentID uint64, dentry proto.Dentry) (err error) {
+	info, err := tc.mw.SnapshotInodeGet_ll(tc.version, dentry.Inode)
+	if err == syscall.ENOENT {
+		// the inode of the dentry is already deleted
+		return nil
+	} else if err != nil {
+		return
+	}
+	if ino, ok := tc.linked[info.Inode]; ok {
+		if _, err = tc.mw.InodeLink_ll(ino); err != nil {
+			return
+		}
+		if err = tc.mw.DentryCreate_ll(parentID, dentry.Name, ino, info.Mode); err != nil {
+			tc.mw.InodeUnlink_ll(ino)
+		}
+		return
+	}
+
+	var copied *proto.InodeInfo
+	switch {
+	case proto.IsDir(info.Mode):
+		copied, err = tc.mw.InodeCreate_ll(0, info.Mode, info.Uid, info.Gid, nil, nil)
+	case proto.IsRegular(info.Mode):
+		copied, err = tc.cloneFile(info)
+	default:
+		copied, err = tc.mw.InodeCreate_ll(0, info.Mode, info.Uid, info.Gid, info.Target, nil)
+	}
+	if err != nil {
+		return
+	}
+	if err = tc.mw.DentryCreate_ll(parentID, dentry.Name, copied.Inode, info.Mode); err != nil {
+		tc.mw.InodeUnlink_ll(copied.Inode)
+		return
+	}
+	if proto.IsDir(info.Mode) {
+		return tc.copyDir(info, copied.Inode)
+	}
+	if info.Nlink > 1 {
+		tc.linked[info.Inode] = copied.Inode
+	}
+	return tc.copyAttr(info, copied.Inode)
+}
+
+// cloneFile clones the file of the version into a new inode, which is created in the partition of the file,
+// where the extents are counted.
+func (tc *treeCopier) cloneFile(info *proto.InodeInfo) (*proto.InodeInfo, error) {
+	mp := tc.mw.getPartitionByInode(info.Inode)
+	if mp == nil {
+		return nil, syscall.ENOENT
+	}
+	req := &proto.CloneInodeRequest{
+		Inode:   info.Inode,
+		Mode:    info.Mode,
+		Uid:     info.Uid,
+		Gid:     info.Gid,
+		Version: tc.version,
+	}
+	status, copied, err := tc.mw.inodeClone(mp, req)
+	if err != nil || status != statusOK {
+		return nil, statusErrToErrno(status, err)
+	}
+	return copied, nil
+}
+
+// copyAttr copies the times and the extended attributes except the object lock, which would protect the copy,
+// and the shards of the directory, whose dentries are copied into the destination itself.
+func (tc *treeCopier) copyAttr(src *proto.InodeInfo, dst uint64) (err error) {
+	xattrs, err := tc.mw.SnapshotXAttrGetAll_ll(tc.version, src.Inode)
+	if err != nil {
+		return
+	}
+	attrs := make(map[string]string, len(xattrs))
+	for key, value := range xattrs {
+		if !proto.IsObjectLockXAttrKey(key) && key != proto.DirShardsXAttrKey {
+			attrs[key] = value
+		}
+	}
+	if len(attrs) > 0 {
+		if err = tc.mw.BatchSetXAttr_ll(dst, attrs); err != nil {
+			return
+		}
+	}
+	return tc.mw.Setattr(dst, proto.AttrAccessTime|proto.AttrModifyTime, 0, 0, 0,
+		src.AccessTime.Unix(), src.ModifyTime.Unix())
+}
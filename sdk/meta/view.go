@@ -44,6 +44,8 @@ type VolumeView struct {
 	OSSSecure      *OSSSecure
 	CreateTime     int64
 	DeleteLockTime int64
+	TrashInterval  int64
+	DirShards      int
 }
 
 type OSSSecure struct {
@@ -99,6 +101,8 @@ func (mw *MetaWrapper) fetchVolumeView() (view *VolumeView, err error) {
 			OSSSecure:      &OSSSecure{},
 			CreateTime:     volView.CreateTime,
 			DeleteLockTime: volView.DeleteLockTime,
+			TrashInterval:  volView.TrashInterval,
+			DirShards:      volView.DirShards,
 		}
 		if volView.OSSSecure != nil {
 			result.OSSSecure.AccessKey = volView.OSSSecure.AccessKey
@@ -202,6 +206,8 @@ func (mw *MetaWrapper) updateMetaPartitions() error {
 	mw.ossSecure = view.OSSSecure
 	mw.volCreateTime = view.CreateTime
 	mw.volDeleteLockTime = view.DeleteLockTime
+	mw.volTrashInterval = view.TrashInterval
+	mw.volDirShards = view.DirShards
 
 	if len(rwPartitions) == 0 {
 		log.LogInfof("updateMetaPartition: no valid partitions")
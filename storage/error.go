@@ -34,6 +34,7 @@ var (
 	BrokenExtentError         = errors.New("extent has been broken")
 	BrokenDiskError           = errors.New("disk has broken")
 	ForbidWriteError          = errors.New("single replica decommission forbid write")
+	ExtentFrozenError         = errors.New("extent is frozen")
 )
 
 func NewParameterMismatchErr(msg string) (err error) {
@@ -0,0 +1,221 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SpanContextSize is the size of the span context carried by the packet.
+	SpanContextSize = 25
+
+	// FlagSampled is set if the trace is sampled.
+	FlagSampled byte = 0x01
+)
+
+// The kinds of the spans, which are the same as the ones of OpenTelemetry.
+const (
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindClient   = 3
+)
+
+// SpanContext identifies a span across the processes, which is compatible with the W3C trace context.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// IsValid returns if the context identifies a span.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Sampled returns if the trace is sampled.
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// Marshal writes the context into the buffer of SpanContextSize.
+func (sc SpanContext) Marshal(out []byte) {
+	copy(out[0:16], sc.TraceID[:])
+	copy(out[16:24], sc.SpanID[:])
+	out[24] = sc.Flags
+}
+
+// UnmarshalSpanContext reads the context from the buffer of SpanContextSize.
+func UnmarshalSpanContext(in []byte) (sc SpanContext) {
+	copy(sc.TraceID[:], in[0:16])
+	copy(sc.SpanID[:], in[16:24])
+	sc.Flags = in[24]
+	return
+}
+
+// Traceparent returns the W3C traceparent header of the context.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%x-%x-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceparent parses the W3C traceparent header.
+func ParseTraceparent(value string) (sc SpanContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return
+	}
+	// the later versions may append fields, while the version ff is invalid
+	var version, flags [1]byte
+	if _, err := hex.Decode(version[:], []byte(parts[0])); err != nil || version[0] == 0xff ||
+		(version[0] == 0 && len(parts) != 4) {
+		return
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return
+	}
+	sc.Flags = flags[0]
+	return sc, sc.IsValid()
+}
+
+// Span is an operation traced, which is exported once finished. All the methods are safe to call on
+// the nil span, which is returned if the tracing is disabled or the trace is not sampled.
+type Span struct {
+	tracer *tracer
+	sc     SpanContext
+	parent [8]byte
+	name   string
+	kind   int
+	start  time.Time
+	end    time.Time
+
+	sync.Mutex
+	tags map[string]string
+	err  string
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns the context carrying the span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by the context, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// StartSpan starts a span as the child of the span in the context, or a new trace if there is none.
+// The context carrying the new span is returned.
+func StartSpan(ctx context.Context, name string) (*Span, context.Context) {
+	t := getTracer()
+	if t == nil {
+		return nil, ctx
+	}
+	var span *Span
+	if parent := SpanFromContext(ctx); parent != nil {
+		span = t.newSpan(parent.sc, name, SpanKindInternal)
+	} else if t.sample() {
+		span = t.newSpan(SpanContext{TraceID: t.newTraceID(), Flags: FlagSampled}, name, SpanKindInternal)
+	}
+	if span == nil {
+		return nil, ctx
+	}
+	return span, ContextWithSpan(ctx, span)
+}
+
+// StartRemoteSpan starts a span serving the request of the remote parent, nil is returned if the parent
+// is not sampled.
+func StartRemoteSpan(parent SpanContext, name string) *Span {
+	t := getTracer()
+	if t == nil || !parent.IsValid() || !parent.Sampled() {
+		return nil
+	}
+	return t.newSpan(parent, name, SpanKindServer)
+}
+
+// StartChild starts a span as the child of the span, the nil span has no child.
+func (s *Span) StartChild(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	return s.tracer.newSpan(s.sc, name, SpanKindInternal)
+}
+
+// Context returns the context of the span to be propagated, which is invalid for the nil span.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetKind sets the kind of the span, e.g. SpanKindClient for the span calling the remote.
+func (s *Span) SetKind(kind int) {
+	if s == nil {
+		return
+	}
+	s.kind = kind
+}
+
+// SetTag records an attribute of the span.
+func (s *Span) SetTag(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	if s.tags == nil {
+		s.tags = make(map[string]string)
+	}
+	s.tags[key] = fmt.Sprint(value)
+	s.Unlock()
+}
+
+// SetError marks the span failed if the error is not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Lock()
+	s.err = err.Error()
+	s.Unlock()
+}
+
+// Finish ends the span and exports it.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.tracer.export(s)
+}
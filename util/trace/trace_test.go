@@ -0,0 +1,143 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package trace
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/cubefs/cubefs/util/config"
+)
+
+func TestSpanContext(t *testing.T) {
+	sc := SpanContext{Flags: FlagSampled}
+	for i := range sc.TraceID {
+		sc.TraceID[i] = byte(i + 1)
+	}
+	for i := range sc.SpanID {
+		sc.SpanID[i] = byte(i + 100)
+	}
+	if !sc.IsValid() || !sc.Sampled() {
+		t.Fatalf("span context(%v) should be valid and sampled", sc)
+	}
+
+	var buf [SpanContextSize]byte
+	sc.Marshal(buf[:])
+	if got := UnmarshalSpanContext(buf[:]); got != sc {
+		t.Fatalf("unmarshal: expect(%v) got(%v)", sc, got)
+	}
+
+	got, ok := ParseTraceparent(sc.Traceparent())
+	if !ok || got != sc {
+		t.Fatalf("traceparent(%v): expect(%v) got(%v) ok(%v)", sc.Traceparent(), sc, got, ok)
+	}
+	for _, value := range []string{"", "00-01", "00-" + sc.Traceparent()[3:] + "-00", "xx" + sc.Traceparent()[2:]} {
+		if _, ok = ParseTraceparent(value); ok {
+			t.Fatalf("traceparent(%v) should be invalid", value)
+		}
+	}
+	if (SpanContext{}).IsValid() {
+		t.Fatalf("empty span context should be invalid")
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	span, ctx := StartSpan(context.Background(), "disabled")
+	if span != nil || SpanFromContext(ctx) != nil {
+		t.Fatalf("span should not be started if the tracing is disabled")
+	}
+	// the nil span is safe to use
+	span.SetKind(SpanKindClient)
+	span.SetTag("key", "value")
+	span.SetError(errors.New("error"))
+	span.StartChild("child").Finish()
+	span.Finish()
+	if span.Context().IsValid() {
+		t.Fatalf("nil span should have no context")
+	}
+	if StartRemoteSpan(SpanContext{}, "remote") != nil {
+		t.Fatalf("remote span should not be started if the tracing is disabled")
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	filename := path.Join(t.TempDir(), "trace.json")
+	cfg := config.LoadConfigString(fmt.Sprintf(`{"%v": "file", "%v": "%v"}`,
+		ConfigKeyTraceExporter, ConfigKeyTraceFile, filename))
+	if err := Init("test", cfg); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	root, ctx := StartSpan(context.Background(), "root")
+	if root == nil || SpanFromContext(ctx) != root {
+		t.Fatalf("root span should be started")
+	}
+	child, _ := StartSpan(ctx, "child")
+	child.SetTag("ino", 1)
+	child.SetError(errors.New("failed"))
+	child.Finish()
+	remote := StartRemoteSpan(child.Context(), "remote")
+	remote.StartChild("local").Finish()
+	remote.Finish()
+	root.Finish()
+	Close()
+
+	if Enabled() {
+		t.Fatalf("tracing should be disabled after close")
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer file.Close()
+	spans := make(map[string]otlpSpan)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var traces otlpTraces
+		if err = json.Unmarshal(scanner.Bytes(), &traces); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		for _, resource := range traces.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				for _, span := range scope.Spans {
+					spans[span.Name] = span
+				}
+			}
+		}
+	}
+	if len(spans) != 4 {
+		t.Fatalf("expect 4 spans, got(%v)", spans)
+	}
+	traceID := spans["root"].TraceID
+	parents := map[string]string{"child": "root", "remote": "child", "local": "remote"}
+	for name, parent := range parents {
+		if spans[name].TraceID != traceID || spans[name].ParentSpanID != spans[parent].SpanID {
+			t.Fatalf("span(%v) should be the child of (%v): %v", name, parent, spans)
+		}
+	}
+	if spans["root"].ParentSpanID != "" {
+		t.Fatalf("root span should have no parent: %v", spans["root"])
+	}
+	if spans["child"].Status.Code != otlpStatusError || spans["remote"].Kind != SpanKindServer {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright 2023 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package trace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/cubefs/util/config"
+	"github.com/cubefs/cubefs/util/log"
+)
+
+const (
+	ConfigKeyTraceExporter   = "traceExporter"   // "file" or "otlp", the tracing is disabled if empty
+	ConfigKeyTraceFile       = "traceFile"       // the file the spans are appended to by the file exporter
+	ConfigKeyTraceEndpoint   = "traceEndpoint"   // the OTLP/HTTP collector, e.g. http://127.0.0.1:4318
+	ConfigKeyTraceSampleRate = "traceSampleRate" // the ratio of the traces sampled, 1 by default
+
+	ExporterFile = "file"
+	ExporterOTLP = "otlp"
+
+	exportBatchSize = 512
+	exportInterval  = time.Second
+	exportQueueSize = 8192
+	exportTimeout   = 5 * time.Second
+)
+
+var global atomic.Value // *tracer
+
+func getTracer() *tracer {
+	t, _ := global.Load().(*tracer)
+	return t
+}
+
+// Enabled returns if the tracing is enabled.
+func Enabled() bool {
+	return getTracer() != nil
+}
+
+type exporter interface {
+	export(data []byte) error
+	close()
+}
+
+type tracer struct {
+	service    string
+	sampleRate float64
+	exporter   exporter
+
+	randLock sync.Mutex
+	rand     *rand.Rand
+
+	spans chan *Span
+	stopC chan struct{}
+	doneC chan struct{}
+	// the number of the spans dropped since the queue is full
+	dropped uint64
+}
+
+// Init enables the tracing of the service if it is configured, the spans are exported in the OpenTelemetry
+// format, either appended to a local file as JSON lines, or posted to an OTLP/HTTP collector.
+func Init(service string, cfg *config.Config) (err error) {
+	kind := cfg.GetString(ConfigKeyTraceExporter)
+	if kind == "" {
+		return nil
+	}
+	t := &tracer{
+		service:    service,
+		sampleRate: 1,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		spans:      make(chan *Span, exportQueueSize),
+		stopC:      make(chan struct{}),
+		doneC:      make(chan struct{}),
+	}
+	// the rate is either a number or a string
+	if rate := cfg.GetString(ConfigKeyTraceSampleRate); rate != "" {
+		if t.sampleRate, err = strconv.ParseFloat(rate, 64); err != nil {
+			return fmt.Errorf("invalid %v(%v)", ConfigKeyTraceSampleRate, rate)
+		}
+	} else if rate := cfg.GetFloat(ConfigKeyTraceSampleRate); rate >= 0 {
+		t.sampleRate = rate
+	}
+	if t.sampleRate < 0 || t.sampleRate > 1 {
+		return fmt.Errorf("invalid %v(%v)", ConfigKeyTraceSampleRate, t.sampleRate)
+	}
+	switch kind {
+	case ExporterFile:
+		if t.exporter, err = newFileExporter(cfg.GetString(ConfigKeyTraceFile)); err != nil {
+			return
+		}
+	case ExporterOTLP:
+		if t.exporter, err = newOTLPExporter(cfg.GetString(ConfigKeyTraceEndpoint)); err != nil {
+			return
+		}
+	default:
+		return fmt.Errorf("invalid %v(%v)", ConfigKeyTraceExporter, kind)
+	}
+	go t.run()
+	global.Store(t)
+	log.LogInfof("trace: enabled, service(%v) exporter(%v) sampleRate(%v)", service, kind, t.sampleRate)
+	return nil
+}
+
+// Close stops the tracing and exports the spans left.
+func Close() {
+	t := getTracer()
+	if t == nil {
+		return
+	}
+	global.Store((*tracer)(nil))
+	close(t.stopC)
+	<-t.doneC
+	t.exporter.close()
+}
+
+func (t *tracer) sample() bool {
+	if t.sampleRate >= 1 {
+		return true
+	}
+	t.randLock.Lock()
+	defer t.randLock.Unlock()
+	return t.rand.Float64() < t.sampleRate
+}
+
+func (t *tracer) newTraceID() (id [16]byte) {
+	t.randLock.Lock()
+	defer t.randLock.Unlock()
+	binary.BigEndian.PutUint64(id[:8], t.rand.Uint64())
+	binary.BigEndian.PutUint64(id[8:], t.rand.Uint64())
+	return
+}
+
+func (t *tracer) newSpanID() (id [8]byte) {
+	t.randLock.Lock()
+	defer t.randLock.Unlock()
+	for id == [8]byte{} {
+		binary.BigEndian.PutUint64(id[:], t.rand.Uint64())
+	}
+	return
+}
+
+func (t *tracer) newSpan(parent SpanContext, name string, kind int) *Span {
+	return &Span{
+		tracer: t,
+		sc:     SpanContext{TraceID: parent.TraceID, SpanID: t.newSpanID(), Flags: parent.Flags},
+		parent: parent.SpanID,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+	}
+}
+
+// export queues the span, which is dropped if the exporter falls behind.
+func (t *tracer) export(span *Span) {
+	select {
+	case t.spans <- span:
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+	}
+}
+
+func (t *tracer) run() {
+	defer close(t.doneC)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, exportBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.export(t.marshal(batch)); err != nil {
+			log.LogWarnf("trace: export spans(%v) err(%v)", len(batch), err)
+		}
+		if dropped := atomic.SwapUint64(&t.dropped, 0); dropped > 0 {
+			log.LogWarnf("trace: dropped spans(%v)", dropped)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case span := <-t.spans:
+			batch = append(batch, span)
+			if len(batch) >= exportBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.stopC:
+			for {
+				select {
+				case span := <-t.spans:
+					batch = append(batch, span)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// The spans are encoded in the OTLP/JSON format.
+type (
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpScopeSpans struct {
+		Scope struct {
+			Name string `json:"name"`
+		} `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpResourceSpans struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpTraces struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+)
+
+const otlpStatusError = 2
+
+func (t *tracer) marshal(spans []*Span) []byte {
+	scope := otlpScopeSpans{Spans: make([]otlpSpan, 0, len(spans))}
+	scope.Scope.Name = "cubefs"
+	for _, span := range spans {
+		s := otlpSpan{
+			TraceID:           hex.EncodeToString(span.sc.TraceID[:]),
+			SpanID:            hex.EncodeToString(span.sc.SpanID[:]),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		}
+		if span.parent != [8]byte{} {
+			s.ParentSpanID = hex.EncodeToString(span.parent[:])
+		}
+		span.Lock()
+		for key, value := range span.tags {
+			s.Attributes = append(s.Attributes, otlpAttribute{Key: key, Value: otlpValue{StringValue: value}})
+		}
+		if span.err != "" {
+			s.Status = otlpStatus{Code: otlpStatusError, Message: span.err}
+		}
+		span.Unlock()
+		scope.Spans = append(scope.Spans, s)
+	}
+	resource := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scope}}
+	resource.Resource.Attributes = []otlpAttribute{{Key: "service.name", Value: otlpValue{StringValue: t.service}}}
+	data, _ := json.Marshal(&otlpTraces{ResourceSpans: []otlpResourceSpans{resource}})
+	return data
+}
+
+// fileExporter appends the batches of the spans to the file, one JSON document per line.
+type fileExporter struct {
+	file *os.File
+}
+
+func newFileExporter(filename string) (*fileExporter, error) {
+	if filename == "" {
+		return nil, fmt.Errorf("%v is required by the file exporter", ConfigKeyTraceFile)
+	}
+	if err := os.MkdirAll(path.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileExporter{file: file}, nil
+}
+
+func (e *fileExporter) export(data []byte) error {
+	_, err := e.file.Write(append(data, '\n'))
+	return err
+}
+
+func (e *fileExporter) close() {
+	e.file.Close()
+}
+
+// otlpExporter posts the batches of the spans to the OTLP/HTTP collector, e.g. the OpenTelemetry collector
+// or Jaeger, which accepts the JSON encoding.
+type otlpExporter struct {
+	url    string
+	client *http.Client
+}
+
+func newOTLPExporter(endpoint string) (*otlpExporter, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("%v is required by the otlp exporter", ConfigKeyTraceEndpoint)
+	}
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		endpoint = "http://" + endpoint
+	}
+	return &otlpExporter{
+		url:    strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		client: &http.Client{Timeout: exportTimeout},
+	}, nil
+}
+
+func (e *otlpExporter) export(data []byte) error {
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status(%v)", resp.Status)
+	}
+	return nil
+}
+
+func (e *otlpExporter) close() {}